@@ -0,0 +1,58 @@
+// Package primary contains the primary adapters (implementing input ports)
+package primary
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"mxclone/domain/autoconfig"
+	"mxclone/ports/output"
+)
+
+// AutoconfigAdapter implements the autoconfiguration input port
+type AutoconfigAdapter struct {
+	autoconfigService *autoconfig.Service
+	repository        output.AutoconfigRepository
+}
+
+// NewAutoconfigAdapter creates a new autoconfiguration adapter
+func NewAutoconfigAdapter(repository output.AutoconfigRepository) *AutoconfigAdapter {
+	return &AutoconfigAdapter{
+		autoconfigService: autoconfig.NewService(),
+		repository:        repository,
+	}
+}
+
+// CheckDomain discovers the client settings of a domain and probes the advertised servers concurrently
+func (a *AutoconfigAdapter) CheckDomain(ctx context.Context, req *autoconfig.CheckRequest) (*autoconfig.CheckResult, error) {
+	if req.Timeout == 0 {
+		req.Timeout = 10 * time.Second
+	}
+
+	result, err := a.repository.Discover(ctx, req)
+	if result == nil {
+		return nil, err
+	}
+
+	if req.Verify && err == nil {
+		servers := a.autoconfigService.ServersToProbe(result)
+		result.Probes = make([]*autoconfig.ServerProbe, len(servers))
+		var wg sync.WaitGroup
+		for i, server := range servers {
+			wg.Add(1)
+			go func(i int, server autoconfig.ServerSetting) {
+				defer wg.Done()
+				result.Probes[i] = a.repository.ProbeServer(ctx, server, req.Timeout)
+			}(i, server)
+		}
+		wg.Wait()
+	}
+
+	return a.autoconfigService.ProcessCheckResult(result, err, time.Now()), nil
+}
+
+// GetCheckSummary returns a human-readable summary of an autoconfiguration check
+func (a *AutoconfigAdapter) GetCheckSummary(result *autoconfig.CheckResult) string {
+	return a.autoconfigService.FormatCheckSummary(result)
+}
@@ -2,8 +2,11 @@
 package primary
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"net"
+	"strings"
 	"sync"
 	"time"
 
@@ -13,18 +16,25 @@ import (
 
 // EmailAuthAdapter implements the EmailAuth input port
 type EmailAuthAdapter struct {
-	authService *emailauth.Service
-	repository  output.EmailAuthRepository
+	authService  *emailauth.Service
+	repository   output.EmailAuthRepository
+	knownSenders []string
 }
 
 // NewEmailAuthAdapter creates a new EmailAuth adapter
-func NewEmailAuthAdapter(repository output.EmailAuthRepository) *EmailAuthAdapter {
+// knownSenders are the senders DMARC report summaries always treat as known, in addition to
+// those of each query
+func NewEmailAuthAdapter(repository output.EmailAuthRepository, knownSenders []string) *EmailAuthAdapter {
 	return &EmailAuthAdapter{
-		authService: emailauth.NewService(),
-		repository:  repository,
+		authService:  emailauth.NewService(),
+		repository:   repository,
+		knownSenders: knownSenders,
 	}
 }
 
+// sourceLookupConcurrency is the number of report sources looked up at the same time
+const sourceLookupConcurrency = 10
+
 // CheckSPF checks SPF (Sender Policy Framework) records for a domain
 func (a *EmailAuthAdapter) CheckSPF(ctx context.Context, domain string, timeout time.Duration) (*emailauth.SPFResult, error) {
 	// Get SPF record
@@ -45,19 +55,53 @@ func (a *EmailAuthAdapter) CheckSPF(ctx context.Context, domain string, timeout
 	return a.authService.ProcessSPFResult(true, record, isValid, mechanisms, err), nil
 }
 
-// CheckDKIM checks DKIM (DomainKeys Identified Mail) records for a domain
-func (a *EmailAuthAdapter) CheckDKIM(ctx context.Context, domain string, selectors []string, timeout time.Duration) (*emailauth.DKIMResult, error) {
-	// If no selectors provided, use common ones
-	if len(selectors) == 0 {
-		selectors = []string{"default", "selector1", "selector2", "dkim", "mail"}
+// EvaluateSPF evaluates the SPF policy for a message from ip, returning the result and the evaluation trace
+func (a *EmailAuthAdapter) EvaluateSPF(ctx context.Context, ip, domain, sender, helo string, timeout time.Duration) (*emailauth.SPFEvaluation, error) {
+	if net.ParseIP(ip) == nil {
+		return nil, fmt.Errorf("invalid IP address: %s", ip)
+	}
+	if domain == "" {
+		if at := strings.LastIndexByte(sender, '@'); at >= 0 {
+			domain = sender[at+1:]
+		} else {
+			domain = helo
+		}
 	}
+	if domain == "" {
+		return nil, fmt.Errorf("a domain, sender or HELO name is required")
+	}
+
+	return a.repository.EvaluateSPF(ctx, ip, domain, sender, helo, timeout)
+}
 
+// AnalyzeSPFLookups expands the include/redirect tree of a domain's SPF record, counts its DNS and void
+// lookups against the limits and generates a flattened candidate record
+func (a *EmailAuthAdapter) AnalyzeSPFLookups(ctx context.Context, domain string, timeout time.Duration) (*emailauth.SPFLookupAnalysis, error) {
+	return a.repository.AnalyzeSPFLookups(ctx, domain, timeout)
+}
+
+// CheckDKIM checks DKIM (DomainKeys Identified Mail) records for a domain
+func (a *EmailAuthAdapter) CheckDKIM(ctx context.Context, domain string, selectors []string, timeout time.Duration) (*emailauth.DKIMResult, error) {
 	// Get DKIM records for each selector
 	records := make(map[string]string)
 	var wg sync.WaitGroup
 	var mu sync.Mutex
 	var firstErr error
 
+	// If no selectors provided, discover them
+	if len(selectors) == 0 {
+		discovery, err := a.repository.DiscoverDKIMSelectors(ctx, domain, nil, timeout)
+		if err != nil {
+			return nil, err
+		}
+		for _, hit := range discovery.Selectors {
+			records[hit.Selector] = hit.Record
+		}
+		if len(records) == 0 && discovery.Error != "" {
+			firstErr = fmt.Errorf("DKIM selector discovery: %s", discovery.Error)
+		}
+	}
+
 	for _, selector := range selectors {
 		wg.Add(1)
 		go func(sel string) {
@@ -89,8 +133,9 @@ func (a *EmailAuthAdapter) CheckDKIM(ctx context.Context, domain string, selecto
 	// Check if we found any DKIM records
 	hasRecords := len(records) > 0
 
-	// Validate all found DKIM records
+	// Validate all found DKIM records and audit their keys
 	isValid := true
+	keys := make(map[string]*emailauth.DKIMKey)
 	for selector, record := range records {
 		valid, err := a.repository.ValidateDKIMRecord(record)
 		if err != nil {
@@ -101,30 +146,85 @@ func (a *EmailAuthAdapter) CheckDKIM(ctx context.Context, domain string, selecto
 		} else if !valid {
 			isValid = false
 		}
+
+		if key, err := a.repository.AnalyzeDKIMKey(record); err == nil {
+			keys[selector] = key
+		}
 	}
 
 	// Process and return the result
-	return a.authService.ProcessDKIMResult(hasRecords, records, isValid, firstErr), nil
+	return a.authService.ProcessDKIMResult(hasRecords, records, keys, isValid, firstErr), nil
+}
+
+// DiscoverDKIMSelectors probes a domain for DKIM selectors: the given ones, those of the sending services
+// detected from its MX and SPF records, a bundled dictionary and dated selectors
+func (a *EmailAuthAdapter) DiscoverDKIMSelectors(ctx context.Context, domain string, selectors []string, timeout time.Duration) (*emailauth.DKIMDiscovery, error) {
+	return a.repository.DiscoverDKIMSelectors(ctx, domain, selectors, timeout)
+}
+
+// VerifyDKIM verifies every DKIM-Signature header of a raw RFC 5322 message against the published keys
+func (a *EmailAuthAdapter) VerifyDKIM(ctx context.Context, raw []byte, timeout time.Duration) (*emailauth.DKIMVerification, error) {
+	if len(bytes.TrimSpace(raw)) == 0 {
+		return nil, fmt.Errorf("the message is empty")
+	}
+
+	return a.repository.VerifyDKIM(ctx, raw, timeout)
+}
+
+// VerifyARC validates the Authenticated Received Chain (RFC 8617) of a raw message: every ARC-Seal,
+// the ARC-Message-Signatures and the chain validation status, with the results of each intermediary
+func (a *EmailAuthAdapter) VerifyARC(ctx context.Context, raw []byte, timeout time.Duration) (*emailauth.ARCValidation, error) {
+	if len(bytes.TrimSpace(raw)) == 0 {
+		return nil, fmt.Errorf("the message is empty")
+	}
+
+	return a.repository.VerifyARC(ctx, raw, timeout)
+}
+
+// AnalyzeHeaders analyzes the header section of a message: Received hops with delays and TLS,
+// Authentication-Results, ARC, Return-Path and List-* headers
+func (a *EmailAuthAdapter) AnalyzeHeaders(ctx context.Context, raw []byte) (*emailauth.HeaderAnalysis, error) {
+	if len(bytes.TrimSpace(raw)) == 0 {
+		return nil, fmt.Errorf("the message headers are empty")
+	}
+
+	return a.repository.AnalyzeHeaders(raw), nil
+}
+
+// CheckBIMI checks the BIMI setup of a domain for a selector (default "default"): the assertion record,
+// the DMARC prerequisite, the SVG Tiny PS logo and the VMC or CMC with its embedded logo
+func (a *EmailAuthAdapter) CheckBIMI(ctx context.Context, domain, selector string, timeout time.Duration) (*emailauth.BIMIResult, error) {
+	if strings.TrimSpace(domain) == "" {
+		return nil, fmt.Errorf("the domain is empty")
+	}
+
+	return a.repository.CheckBIMI(ctx, domain, selector, timeout)
 }
 
 // CheckDMARC checks DMARC (Domain-based Message Authentication) records for a domain
 func (a *EmailAuthAdapter) CheckDMARC(ctx context.Context, domain string, timeout time.Duration) (*emailauth.DMARCResult, error) {
-	// Get DMARC record
-	record, hasRecord, err := a.repository.GetDMARCRecord(ctx, domain, timeout)
+	// Find the record that applies: the domain's own, or one inherited from a parent domain
+	discovery, err := a.repository.DiscoverDMARCPolicy(ctx, domain, timeout)
 	if err != nil {
 		return a.authService.ProcessDMARCResult(false, "", false, "", "", 0, err), err
 	}
+	if discovery.Error != "" {
+		err := fmt.Errorf("%s", discovery.Error)
+		return a.authService.ProcessDMARCDiscovery(a.authService.ProcessDMARCResult(false, "", false, "", "", 0, err), discovery), err
+	}
 
 	// If no DMARC record found, return result with hasRecord=false
-	if !hasRecord {
-		return a.authService.ProcessDMARCResult(false, "", false, "", "", 0, nil), nil
+	if discovery.Record == "" {
+		return a.authService.ProcessDMARCDiscovery(a.authService.ProcessDMARCResult(false, "", false, "", "", 0, nil), discovery), nil
 	}
 
-	// Parse DMARC record
-	isValid, policy, subdomainPolicy, percentage, err := a.repository.ParseDMARCRecord(record)
+	// Parse every tag of the DMARC record and verify external report destinations
+	result, err := a.repository.AnalyzeDMARCRecord(ctx, discovery.PolicyDomain, discovery.Record, timeout)
+	if err != nil {
+		result = a.authService.ProcessDMARCResult(true, discovery.Record, false, "", "", 0, err)
+	}
 
-	// Process and return the result
-	return a.authService.ProcessDMARCResult(true, record, isValid, policy, subdomainPolicy, percentage, err), nil
+	return a.authService.ProcessDMARCDiscovery(result, discovery), nil
 }
 
 // CheckAll performs SPF, DKIM, and DMARC checks for a domain
@@ -192,3 +292,142 @@ func (a *EmailAuthAdapter) CheckAll(ctx context.Context, domain string, dkimSele
 func (a *EmailAuthAdapter) GetAuthSummary(result *emailauth.AuthResult) string {
 	return a.authService.FormatAuthSummary(result)
 }
+
+// GetSPFEvaluationSummary returns a human-readable summary of an SPF evaluation and its trace
+func (a *EmailAuthAdapter) GetSPFEvaluationSummary(result *emailauth.SPFEvaluation) string {
+	return a.authService.FormatSPFEvaluation(result)
+}
+
+// GetSPFLookupSummary returns the SPF lookup tree as an indented text view with findings and the flattened record
+func (a *EmailAuthAdapter) GetSPFLookupSummary(result *emailauth.SPFLookupAnalysis) string {
+	return a.authService.FormatSPFLookupAnalysis(result)
+}
+
+// GetDKIMVerificationSummary returns a human-readable summary of the DKIM signatures of a message
+func (a *EmailAuthAdapter) GetDKIMVerificationSummary(result *emailauth.DKIMVerification) string {
+	return a.authService.FormatDKIMVerification(result)
+}
+
+// GetDKIMDiscoverySummary returns a human-readable summary of the DKIM selectors found for a domain
+func (a *EmailAuthAdapter) GetDKIMDiscoverySummary(result *emailauth.DKIMDiscovery) string {
+	return a.authService.FormatDKIMDiscovery(result)
+}
+
+// IngestDMARCReports parses DMARC aggregate report files and stores the reports not stored yet
+func (a *EmailAuthAdapter) IngestDMARCReports(ctx context.Context, files []emailauth.DMARCReportUpload) (*emailauth.DMARCIngestResult, error) {
+	results := make([]emailauth.DMARCReportFile, 0, len(files))
+	for _, file := range files {
+		result := emailauth.DMARCReportFile{Name: file.Name}
+		reports, err := a.repository.ParseDMARCReports(file.Name, file.Data)
+		if err != nil {
+			// An unreadable file does not stop the others
+			result.Error = err.Error()
+			results = append(results, result)
+			continue
+		}
+
+		result.Reports = len(reports)
+		result.Added, result.Duplicates, err = a.repository.StoreDMARCReports(ctx, reports)
+		if err != nil {
+			return nil, fmt.Errorf("failed to store the reports of %s: %w", file.Name, err)
+		}
+		results = append(results, result)
+	}
+
+	return a.authService.ProcessDMARCIngest(results, a.repository.DMARCReportDatabase()), nil
+}
+
+// SummarizeDMARCReports summarizes the stored aggregate reports per source IP, ASN and reverse DNS domain
+func (a *EmailAuthAdapter) SummarizeDMARCReports(ctx context.Context, query emailauth.DMARCReportQuery, timeout time.Duration) (*emailauth.DMARCReportSummary, error) {
+	reports, err := a.repository.LoadDMARCReports(ctx, query.Domain, query.Since, query.Until)
+	if err != nil {
+		return nil, err
+	}
+
+	origins := make(map[string]*emailauth.SourceOrigin)
+	if query.Lookup {
+		seen := make(map[string]bool)
+		var ips []string
+		for _, report := range reports {
+			for _, record := range report.Records {
+				if !seen[record.SourceIP] {
+					seen[record.SourceIP] = true
+					ips = append(ips, record.SourceIP)
+				}
+			}
+		}
+
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		semaphore := make(chan struct{}, sourceLookupConcurrency)
+		for _, ip := range ips {
+			wg.Add(1)
+			go func(ip string) {
+				defer wg.Done()
+				semaphore <- struct{}{}
+				defer func() { <-semaphore }()
+
+				// A failed lookup leaves the source without origin rather than failing the summary
+				origin, _ := a.repository.LookupSourceIP(ctx, ip, timeout)
+				if origin != nil {
+					mu.Lock()
+					origins[ip] = origin
+					mu.Unlock()
+				}
+			}(ip)
+		}
+		wg.Wait()
+	}
+
+	query.KnownSenders = append(append([]string{}, a.knownSenders...), query.KnownSenders...)
+	summary := a.authService.SummarizeDMARCReports(reports, origins, query)
+	summary.Database = a.repository.DMARCReportDatabase()
+	return summary, nil
+}
+
+// AnalyzeForensicReports parses ARF failure report files and groups them by campaign
+func (a *EmailAuthAdapter) AnalyzeForensicReports(ctx context.Context, files []emailauth.DMARCReportUpload) (*emailauth.ForensicReportAnalysis, error) {
+	results := make([]emailauth.ForensicReportFile, 0, len(files))
+	for _, file := range files {
+		result := emailauth.ForensicReportFile{Name: file.Name}
+		report, err := a.repository.ParseForensicReport(file.Name, file.Data)
+		if err != nil {
+			// An unreadable file does not stop the others
+			result.Error = err.Error()
+		}
+		result.Report = report
+		results = append(results, result)
+	}
+
+	return a.authService.ProcessForensicReports(results), nil
+}
+
+// GetARCValidationSummary returns a human-readable summary of the ARC chain of a message
+func (a *EmailAuthAdapter) GetARCValidationSummary(result *emailauth.ARCValidation) string {
+	return a.authService.FormatARCValidation(result)
+}
+
+// GetHeaderAnalysisSummary returns a human-readable report of a message header analysis
+func (a *EmailAuthAdapter) GetHeaderAnalysisSummary(result *emailauth.HeaderAnalysis) string {
+	return a.authService.FormatHeaderAnalysis(result)
+}
+
+// GetBIMISummary returns a human-readable summary of the BIMI check of a domain
+func (a *EmailAuthAdapter) GetBIMISummary(result *emailauth.BIMIResult) string {
+	return a.authService.FormatBIMIResult(result)
+}
+
+// GetDMARCIngestSummary returns a human-readable summary of a report ingestion
+func (a *EmailAuthAdapter) GetDMARCIngestSummary(result *emailauth.DMARCIngestResult) string {
+	return a.authService.FormatDMARCIngest(result)
+}
+
+// GetDMARCReportSummary returns a human-readable summary of aggregate report analytics
+func (a *EmailAuthAdapter) GetDMARCReportSummary(result *emailauth.DMARCReportSummary) string {
+	return a.authService.FormatDMARCReportSummary(result)
+}
+
+// GetForensicReportSummary returns a human-readable summary of failure reports by campaign
+func (a *EmailAuthAdapter) GetForensicReportSummary(result *emailauth.ForensicReportAnalysis) string {
+	return a.authService.FormatForensicReports(result)
+}
@@ -0,0 +1,87 @@
+// Package primary contains the primary adapters (implementing input ports)
+package primary
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"mxclone/domain/mailaccess"
+	"mxclone/ports/output"
+)
+
+// MailAccessAdapter implements the mail access input port
+type MailAccessAdapter struct {
+	mailAccessService *mailaccess.Service
+	repository        output.MailAccessRepository
+}
+
+// NewMailAccessAdapter creates a new mail access adapter
+func NewMailAccessAdapter(repository output.MailAccessRepository) *MailAccessAdapter {
+	return &MailAccessAdapter{
+		mailAccessService: mailaccess.NewService(),
+		repository:        repository,
+	}
+}
+
+// CheckServer checks one IMAP or POP3 port
+func (a *MailAccessAdapter) CheckServer(ctx context.Context, req *mailaccess.CheckRequest) (*mailaccess.CheckResult, error) {
+	ports := mailaccess.DefaultPorts(req.Protocol)
+	if ports == nil {
+		return nil, fmt.Errorf("unsupported protocol: %s", req.Protocol)
+	}
+	if req.Port == 0 {
+		req.Port = ports[0]
+	}
+	if req.Timeout == 0 {
+		req.Timeout = 10 * time.Second
+	}
+
+	var result *mailaccess.CheckResult
+	var err error
+	if req.Protocol == mailaccess.ProtocolIMAP {
+		result, err = a.repository.CheckIMAP(ctx, req)
+	} else {
+		result, err = a.repository.CheckPOP3(ctx, req)
+	}
+
+	// A failed session is part of the result, not an error of the check
+	return a.mailAccessService.ProcessCheckResult(result, err, time.Now()), nil
+}
+
+// CheckHost checks the STARTTLS and implicit TLS ports of a protocol on a host concurrently
+func (a *MailAccessAdapter) CheckHost(ctx context.Context, protocol, host string, timeout time.Duration) (*mailaccess.HostCheckResult, error) {
+	ports := mailaccess.DefaultPorts(protocol)
+	if ports == nil {
+		return nil, fmt.Errorf("unsupported protocol: %s", protocol)
+	}
+
+	results := make([]*mailaccess.CheckResult, len(ports))
+	var wg sync.WaitGroup
+	for i, port := range ports {
+		wg.Add(1)
+		go func(i, port int) {
+			defer wg.Done()
+			results[i], _ = a.CheckServer(ctx, &mailaccess.CheckRequest{
+				Protocol: protocol,
+				Host:     host,
+				Port:     port,
+				Timeout:  timeout,
+			})
+		}(i, port)
+	}
+	wg.Wait()
+
+	return a.mailAccessService.ProcessHostCheckResult(protocol, host, results), nil
+}
+
+// GetCheckSummary returns a human-readable summary of a server check
+func (a *MailAccessAdapter) GetCheckSummary(result *mailaccess.CheckResult) string {
+	return a.mailAccessService.FormatCheckSummary(result)
+}
+
+// GetHostCheckSummary returns a human-readable summary of the checks of a host
+func (a *MailAccessAdapter) GetHostCheckSummary(result *mailaccess.HostCheckResult) string {
+	return a.mailAccessService.FormatHostCheckSummary(result)
+}
@@ -4,6 +4,9 @@ package primary
 import (
 	"context"
 	"fmt"
+	"net"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -15,17 +18,35 @@ import (
 type SMTPAdapter struct {
 	smtpService *smtp.Service
 	repository  output.SMTPRepository
+	ports       []int
+	// Sink settings used for the fields a StartSink call leaves empty
+	sinkDefaults smtp.SinkConfig
 }
 
 // NewSMTPAdapter creates a new SMTP adapter
-func NewSMTPAdapter(repository output.SMTPRepository) *SMTPAdapter {
+// ports are the SMTP ports tested by CheckSMTP, and by the MX matrix when a request does not name any
+func NewSMTPAdapter(repository output.SMTPRepository, ports []int) *SMTPAdapter {
+	if len(ports) == 0 {
+		ports = []int{25, 465, 587}
+	}
 	return &SMTPAdapter{
 		smtpService: smtp.NewService(),
 		repository:  repository,
+		ports:       ports,
 	}
 }
 
+// WithSinkDefaults sets the sink settings used for the fields a StartSink call leaves empty
+func (a *SMTPAdapter) WithSinkDefaults(defaults smtp.SinkConfig) *SMTPAdapter {
+	a.sinkDefaults = defaults
+	return a
+}
+
+// smtpConcurrency is the number of SMTP sessions CheckSMTP and CheckSMTPMatrix open at the same time
+const smtpConcurrency = 10
+
 // CheckSMTP performs a comprehensive SMTP check for a domain
+// Each MX server is tested on each configured port; results are keyed by host:port
 func (a *SMTPAdapter) CheckSMTP(ctx context.Context, domain string, timeout time.Duration) (*smtp.SMTPResult, error) {
 	// Get MX records for the domain
 	mxRecords, err := a.repository.GetMXRecords(ctx, domain)
@@ -38,35 +59,54 @@ func (a *SMTPAdapter) CheckSMTP(ctx context.Context, domain string, timeout time
 		return a.smtpService.ProcessSMTPResult(domain, mxRecords, nil, "", fmt.Errorf("no MX records found for domain")), nil
 	}
 
-	// Test connection to each MX server
+	// Test connection to each MX server on each configured port
 	connectionResults := make(map[string]*smtp.ConnectionResult)
+	sem := make(chan struct{}, smtpConcurrency)
 	var wg sync.WaitGroup
 	var mu sync.Mutex
 
 	for _, server := range mxRecords {
-		wg.Add(1)
-		go func(srv string) {
-			defer wg.Done()
+		for _, port := range a.ports {
+			wg.Add(1)
+			go func(srv string, port int) {
+				defer wg.Done()
+				sem <- struct{}{}
+				defer func() { <-sem }()
+
+				// Test connection to this server
+				connResult, _ := a.TestSMTPConnection(ctx, srv, port, timeout)
+
+				// Analyze how the server greets and answers clients
+				if connResult.Connected {
+					analysis, _ := a.repository.AnalyzeBanner(ctx, srv, port, domain, timeout)
+					connResult.BannerAnalysis = analysis
+				}
 
-			// Test connection to this server
-			connResult, _ := a.TestSMTPConnection(ctx, srv, 25, timeout)
+				// Check that STARTTLS cannot be bypassed or used to inject commands
+				if connResult.Connected && connResult.SupportsStartTLS && port != 465 {
+					security, _ := a.repository.CheckSTARTTLSSecurity(ctx, srv, port, timeout)
+					connResult.STARTTLSSecurity = security
+				}
 
-			// Store connection result
-			mu.Lock()
-			connectionResults[srv] = connResult
-			mu.Unlock()
-		}(server)
+				// Store connection result
+				mu.Lock()
+				connectionResults[net.JoinHostPort(srv, strconv.Itoa(port))] = connResult
+				mu.Unlock()
+			}(server, port)
+		}
 	}
 
 	// Wait for all connection tests to complete
 	wg.Wait()
 
-	// Extract banner from the first successful connection
+	// Extract banner from the first successful connection, in MX and port order
 	var banner string
-	for _, result := range connectionResults {
-		if result.Connected && result.Banner != "" {
-			banner = result.Banner
-			break
+	for _, server := range mxRecords {
+		for _, port := range a.ports {
+			result := connectionResults[net.JoinHostPort(server, strconv.Itoa(port))]
+			if banner == "" && result != nil && result.Connected && result.Banner != "" {
+				banner = result.Banner
+			}
 		}
 	}
 
@@ -77,14 +117,305 @@ func (a *SMTPAdapter) CheckSMTP(ctx context.Context, domain string, timeout time
 // TestSMTPConnection tests connection to a specific SMTP server
 func (a *SMTPAdapter) TestSMTPConnection(ctx context.Context, server string, port int, timeout time.Duration) (*smtp.ConnectionResult, error) {
 	// Connect to SMTP server and test capabilities
-	connected, latency, supportsStartTLS, authMethods, banner, err := a.repository.ConnectToSMTPServer(ctx, server, port, timeout)
+	session, err := a.repository.ConnectToSMTPServer(ctx, server, port, timeout)
 
 	// Process and return the connection result
-	connResult := a.smtpService.CreateConnectionResult(server, connected, latency, supportsStartTLS, authMethods, banner, err)
+	connResult := a.smtpService.CreateConnectionResult(server, port, session, err)
 
 	return connResult, nil
 }
 
+// CheckSMTPMatrix tests every address of every MX host of a domain on each port
+func (a *SMTPAdapter) CheckSMTPMatrix(ctx context.Context, domain string, ports []int, timeout time.Duration) (*smtp.MatrixResult, error) {
+	if len(ports) == 0 {
+		ports = a.ports
+	}
+
+	mxHosts, err := a.repository.GetMXHosts(ctx, domain)
+	if err != nil {
+		return a.smtpService.ProcessMatrixResult(domain, nil, ports, nil, nil, fmt.Errorf("failed to retrieve MX records: %w", err)), err
+	}
+	if len(mxHosts) == 0 {
+		return a.smtpService.ProcessMatrixResult(domain, mxHosts, ports, nil, nil, fmt.Errorf("no MX records found for domain")), nil
+	}
+
+	var cells []*smtp.MatrixCell
+	resolveErrors := make(map[string]string)
+	sem := make(chan struct{}, smtpConcurrency)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+
+	for _, mx := range mxHosts {
+		addresses, err := a.repository.ResolveHostAddresses(ctx, mx.Host)
+		if err != nil || len(addresses) == 0 {
+			if err == nil {
+				err = fmt.Errorf("no A or AAAA records")
+			}
+			resolveErrors[mx.Host] = err.Error()
+			continue
+		}
+
+		for _, address := range addresses {
+			for _, port := range ports {
+				wg.Add(1)
+				go func(mx smtp.MXHost, address string, port int) {
+					defer wg.Done()
+					sem <- struct{}{}
+					defer func() { <-sem }()
+
+					session, err := a.repository.ConnectToSMTPAddress(ctx, mx.Host, address, port, timeout)
+					cell := a.smtpService.CreateMatrixCell(mx, address, port, session, err)
+
+					mu.Lock()
+					cells = append(cells, cell)
+					mu.Unlock()
+				}(mx, address, port)
+			}
+		}
+	}
+
+	// Wait for all probes to complete
+	wg.Wait()
+
+	return a.smtpService.ProcessMatrixResult(domain, mxHosts, ports, cells, resolveErrors, nil), nil
+}
+
+// GetSMTPMatrixSummary returns the MX matrix as a human-readable grid
+func (a *SMTPAdapter) GetSMTPMatrixSummary(result *smtp.MatrixResult) string {
+	return a.smtpService.FormatMatrixSummary(result)
+}
+
+// TestOpenRelay runs the open relay test battery against an SMTP server
+func (a *SMTPAdapter) TestOpenRelay(ctx context.Context, req *smtp.RelayTestRequest) (*smtp.RelayTestResult, error) {
+	banner, tests, authenticated, err := a.repository.RunRelayTests(ctx, req)
+
+	// Process and return the relay test result
+	return a.smtpService.ProcessRelayTestResult(req, banner, tests, authenticated, err), nil
+}
+
+// GetRelayTestSummary returns a human-readable summary of an open relay test
+func (a *SMTPAdapter) GetRelayTestSummary(result *smtp.RelayTestResult) string {
+	return a.smtpService.FormatRelayTestSummary(result)
+}
+
+// TestSubmission runs an authenticated submission test against a server
+func (a *SMTPAdapter) TestSubmission(ctx context.Context, req *smtp.SubmissionTestRequest) (*smtp.SubmissionTestResult, error) {
+	result, err := a.repository.RunSubmissionTest(ctx, req)
+
+	// Process and return the submission test result
+	return a.smtpService.ProcessSubmissionTestResult(req, result, err), nil
+}
+
+// GetSubmissionTestSummary returns a human-readable summary of a submission test
+func (a *SMTPAdapter) GetSubmissionTestSummary(result *smtp.SubmissionTestResult) string {
+	return a.smtpService.FormatSubmissionTestSummary(result)
+}
+
+// TestSmuggling probes a mail exchanger of a domain for SMTP smuggling
+func (a *SMTPAdapter) TestSmuggling(ctx context.Context, req *smtp.SmugglingTestRequest) (*smtp.SmugglingTestResult, error) {
+	// Nothing is sent without explicit opt-in
+	if !req.Confirm {
+		return a.smtpService.ProcessSmugglingTestResult(req, nil, fmt.Errorf("the smuggling probe must be explicitly confirmed")), nil
+	}
+
+	// Probe messages only go to the domain's postmaster, so only its mail exchangers are probed:
+	// the most preferred MX, or the given host once it is found in the MX set
+	mxHosts, err := a.repository.GetMXHosts(ctx, req.Domain)
+	if err != nil {
+		return a.smtpService.ProcessSmugglingTestResult(req, nil, fmt.Errorf("failed to retrieve MX records: %w", err)), nil
+	}
+	probe := *req
+	probe.Host = ""
+	want := strings.ToLower(strings.TrimSuffix(req.Host, "."))
+	for _, mx := range mxHosts {
+		host := strings.ToLower(strings.TrimSuffix(mx.Host, "."))
+		if host != "" && (want == "" || host == want) {
+			probe.Host = mx.Host
+			break
+		}
+	}
+	if probe.Host == "" {
+		if want != "" {
+			return a.smtpService.ProcessSmugglingTestResult(req, nil, fmt.Errorf("host %s is not an MX of %s", req.Host, req.Domain)), nil
+		}
+		return a.smtpService.ProcessSmugglingTestResult(req, nil, fmt.Errorf("no MX records found for domain")), nil
+	}
+
+	result, err := a.repository.RunSmugglingTest(ctx, &probe)
+
+	// Process and return the smuggling probe result
+	return a.smtpService.ProcessSmugglingTestResult(&probe, result, err), nil
+}
+
+// GetSmugglingTestSummary returns a human-readable summary of an SMTP smuggling probe
+func (a *SMTPAdapter) GetSmugglingTestSummary(result *smtp.SmugglingTestResult) string {
+	return a.smtpService.FormatSmugglingTestSummary(result)
+}
+
+// verifyMaxMXAttempts is the number of MX hosts tried, in preference order, when a probe cannot connect
+const verifyMaxMXAttempts = 3
+
+// verifyConcurrency is the number of addresses verified at the same time by VerifyEmails
+const verifyConcurrency = 5
+
+// VerifyEmail checks the syntax, domain and mailbox of an email address without sending mail
+func (a *SMTPAdapter) VerifyEmail(ctx context.Context, address string, timeout time.Duration) (*smtp.EmailVerificationResult, error) {
+	// Check syntax and normalize the domain
+	normalized, err := a.repository.NormalizeEmailAddress(address)
+	result := a.smtpService.CreateEmailVerificationResult(address, normalized, err)
+	if !result.ValidSyntax {
+		return a.smtpService.ProcessEmailVerificationResult(result, nil), nil
+	}
+
+	// Find the mail exchangers, falling back to the domain itself (implicit MX) when it has none
+	mxHosts, mxErr := a.repository.GetMXHosts(ctx, result.Domain)
+	var servers []string
+	for _, mx := range mxHosts {
+		// A null MX (RFC 7505) means the domain accepts no mail
+		if mx.Host == "" || mx.Host == "." {
+			continue
+		}
+		result.MXHosts = append(result.MXHosts, mx)
+		servers = append(servers, mx.Host)
+	}
+	if len(mxHosts) == 0 {
+		addresses, err := a.repository.ResolveHostAddresses(ctx, result.Domain)
+		if len(addresses) > 0 {
+			result.ImplicitMX = true
+			servers = append(servers, result.Domain)
+		} else if err != nil && mxErr != nil {
+			return a.smtpService.ProcessEmailVerificationResult(result, fmt.Errorf("DNS lookup failed: %w", err)), nil
+		}
+	}
+	if len(servers) == 0 {
+		return a.smtpService.ProcessEmailVerificationResult(result, nil), nil
+	}
+
+	// Probe the address together with a random one to detect catch-all domains
+	catchAll := a.smtpService.CatchAllProbeAddress(result.Domain)
+	recipients := []string{result.NormalizedAddress, catchAll}
+	var probeErr error
+	for i, server := range servers {
+		if i == verifyMaxMXAttempts {
+			break
+		}
+		var probe *smtp.RecipientProbe
+		probe, probeErr = a.repository.ProbeRecipients(ctx, server, recipients, timeout)
+		a.smtpService.ApplyRecipientProbe(result, probe, catchAll)
+		if probe != nil && len(probe.Replies) > 0 {
+			break
+		}
+	}
+
+	return a.smtpService.ProcessEmailVerificationResult(result, probeErr), nil
+}
+
+// VerifyEmails verifies several email addresses concurrently
+func (a *SMTPAdapter) VerifyEmails(ctx context.Context, addresses []string, timeout time.Duration) (*smtp.BulkEmailVerificationResult, error) {
+	results := make([]*smtp.EmailVerificationResult, len(addresses))
+	semaphore := make(chan struct{}, verifyConcurrency)
+	var wg sync.WaitGroup
+
+	for i, address := range addresses {
+		wg.Add(1)
+		go func(i int, address string) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			results[i], _ = a.VerifyEmail(ctx, address, timeout)
+		}(i, address)
+	}
+
+	wg.Wait()
+
+	return a.smtpService.ProcessBulkEmailVerificationResult(results), nil
+}
+
+// GetEmailVerificationSummary returns a human-readable summary of an email verification
+func (a *SMTPAdapter) GetEmailVerificationSummary(result *smtp.EmailVerificationResult) string {
+	return a.smtpService.FormatEmailVerificationSummary(result)
+}
+
+// GetBulkEmailVerificationSummary returns a human-readable summary of a bulk email verification
+func (a *SMTPAdapter) GetBulkEmailVerificationSummary(result *smtp.BulkEmailVerificationResult) string {
+	return a.smtpService.FormatBulkEmailVerificationSummary(result)
+}
+
+// StartSink starts the receive-only SMTP sink
+// The configured sink settings apply to the fields the config leaves empty
+func (a *SMTPAdapter) StartSink(ctx context.Context, config *smtp.SinkConfig) (*smtp.SinkStatus, error) {
+	merged := *config
+	if merged.Address == "" {
+		merged.Address = a.sinkDefaults.Address
+	}
+	if merged.Domain == "" {
+		merged.Domain = a.sinkDefaults.Domain
+	}
+	if merged.Hostname == "" {
+		merged.Hostname = a.sinkDefaults.Hostname
+	}
+	if merged.CertFile == "" && merged.KeyFile == "" {
+		merged.CertFile, merged.KeyFile = a.sinkDefaults.CertFile, a.sinkDefaults.KeyFile
+	}
+	if merged.TestTTL <= 0 {
+		merged.TestTTL = a.sinkDefaults.TestTTL
+	}
+	if merged.MaxTestMessages <= 0 {
+		merged.MaxTestMessages = a.sinkDefaults.MaxTestMessages
+	}
+	return a.repository.StartSink(ctx, &merged)
+}
+
+// StopSink stops the SMTP sink
+func (a *SMTPAdapter) StopSink(ctx context.Context) error {
+	return a.repository.StopSink(ctx)
+}
+
+// GetSinkStatus describes the SMTP sink
+func (a *SMTPAdapter) GetSinkStatus(ctx context.Context) *smtp.SinkStatus {
+	return a.repository.GetSinkStatus(ctx)
+}
+
+// CreateSinkTest generates a unique test address on the running sink
+func (a *SMTPAdapter) CreateSinkTest(ctx context.Context) (*smtp.SinkTest, error) {
+	return a.repository.CreateSinkTest(ctx)
+}
+
+// GetSinkTest returns the messages received for a sink test with their authentication verdicts
+func (a *SMTPAdapter) GetSinkTest(ctx context.Context, id string) (*smtp.SinkTest, error) {
+	test, err := a.repository.GetSinkTest(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, message := range test.Messages {
+		a.smtpService.ProcessSinkMessageReport(message)
+	}
+
+	return test, nil
+}
+
+// GetSinkStatusSummary returns a human-readable description of the SMTP sink
+func (a *SMTPAdapter) GetSinkStatusSummary(status *smtp.SinkStatus) string {
+	return a.smtpService.FormatSinkStatus(status)
+}
+
+// GetSinkTestSummary returns a human-readable summary of a sink test
+func (a *SMTPAdapter) GetSinkTestSummary(test *smtp.SinkTest) string {
+	return a.smtpService.FormatSinkTestSummary(test)
+}
+
+// ExplainReply classifies an SMTP reply or bounce text
+func (a *SMTPAdapter) ExplainReply(reply string) *smtp.ReplyExplanation {
+	return a.smtpService.ExplainReply(reply)
+}
+
+// GetReplyExplanationSummary returns a human-readable explanation of an SMTP reply
+func (a *SMTPAdapter) GetReplyExplanationSummary(explanation *smtp.ReplyExplanation) string {
+	return a.smtpService.FormatReplyExplanation(explanation)
+}
+
 // GetSMTPSummary returns a human-readable summary of SMTP check results
 func (a *SMTPAdapter) GetSMTPSummary(result *smtp.SMTPResult) string {
 	return a.smtpService.FormatSMTPSummary(result)
@@ -0,0 +1,254 @@
+package primary
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"mxclone/domain/smtp"
+	"mxclone/ports/output"
+)
+
+// stubSMTPRepository answers the MX, address and connection lookups of the SMTP adapter
+// Methods the tests do not use are left to the embedded nil interface
+type stubSMTPRepository struct {
+	output.SMTPRepository
+
+	mxHosts   []smtp.MXHost
+	addresses map[string][]string
+	// Ports that accept connections
+	open map[int]bool
+	// Delay of each connection, so concurrent probes overlap
+	delay time.Duration
+
+	// Config of the last StartSink call
+	sinkConfig *smtp.SinkConfig
+
+	mu       sync.Mutex
+	inFlight int
+	peak     int
+	dialed   []string
+}
+
+func (r *stubSMTPRepository) GetMXRecords(ctx context.Context, domain string) ([]string, error) {
+	var records []string
+	for _, mx := range r.mxHosts {
+		records = append(records, mx.Host)
+	}
+	return records, nil
+}
+
+func (r *stubSMTPRepository) GetMXHosts(ctx context.Context, domain string) ([]smtp.MXHost, error) {
+	return r.mxHosts, nil
+}
+
+func (r *stubSMTPRepository) ResolveHostAddresses(ctx context.Context, host string) ([]string, error) {
+	return r.addresses[host], nil
+}
+
+func (r *stubSMTPRepository) ConnectToSMTPServer(ctx context.Context, server string, port int, timeout time.Duration) (*smtp.SessionInfo, error) {
+	return r.ConnectToSMTPAddress(ctx, server, server, port, timeout)
+}
+
+func (r *stubSMTPRepository) ConnectToSMTPAddress(ctx context.Context, server string, address string, port int, timeout time.Duration) (*smtp.SessionInfo, error) {
+	r.mu.Lock()
+	r.inFlight++
+	if r.inFlight > r.peak {
+		r.peak = r.inFlight
+	}
+	r.dialed = append(r.dialed, address)
+	r.mu.Unlock()
+
+	time.Sleep(r.delay)
+
+	r.mu.Lock()
+	r.inFlight--
+	r.mu.Unlock()
+
+	if !r.open[port] {
+		return &smtp.SessionInfo{}, errors.New("failed to connect: connection refused")
+	}
+	return &smtp.SessionInfo{Connected: true, Banner: "220 " + server + " ESMTP", SupportsStartTLS: port != 465}, nil
+}
+
+func (r *stubSMTPRepository) AnalyzeBanner(ctx context.Context, server string, port int, recipientDomain string, timeout time.Duration) (*smtp.BannerAnalysis, error) {
+	return &smtp.BannerAnalysis{}, nil
+}
+
+func (r *stubSMTPRepository) StartSink(ctx context.Context, config *smtp.SinkConfig) (*smtp.SinkStatus, error) {
+	r.sinkConfig = config
+	return &smtp.SinkStatus{Running: true, Address: config.Address, Domain: config.Domain}, nil
+}
+
+func (r *stubSMTPRepository) RunSmugglingTest(ctx context.Context, req *smtp.SmugglingTestRequest) (*smtp.SmugglingTestResult, error) {
+	r.mu.Lock()
+	r.dialed = append(r.dialed, req.Host)
+	r.mu.Unlock()
+	return &smtp.SmugglingTestResult{Host: req.Host}, nil
+}
+
+func (r *stubSMTPRepository) CheckSTARTTLSSecurity(ctx context.Context, server string, port int, timeout time.Duration) (*smtp.STARTTLSSecurity, error) {
+	return &smtp.STARTTLSSecurity{Advertised: true, HandshakeSucceeded: true}, nil
+}
+
+// TestCheckSMTPPorts tests that every MX server is checked on each configured port
+func TestCheckSMTPPorts(t *testing.T) {
+	repository := &stubSMTPRepository{
+		mxHosts: []smtp.MXHost{{Host: "mx1.example.com", Preference: 10}, {Host: "mx2.example.com", Preference: 20}},
+		open:    map[int]bool{25: true, 465: true},
+	}
+	adapter := NewSMTPAdapter(repository, []int{25, 465, 2525})
+
+	result, err := adapter.CheckSMTP(context.Background(), "example.com", time.Second)
+	if err != nil {
+		t.Fatalf("CheckSMTP() error = %v", err)
+	}
+
+	var keys []string
+	for key := range result.ConnectionResults {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	want := []string{
+		"mx1.example.com:25", "mx1.example.com:2525", "mx1.example.com:465",
+		"mx2.example.com:25", "mx2.example.com:2525", "mx2.example.com:465",
+	}
+	if len(keys) != len(want) {
+		t.Fatalf("ConnectionResults keys = %v, want %v", keys, want)
+	}
+	for i := range want {
+		if keys[i] != want[i] {
+			t.Fatalf("ConnectionResults keys = %v, want %v", keys, want)
+		}
+	}
+
+	plain := result.ConnectionResults["mx1.example.com:25"]
+	if !plain.Connected || plain.Port != 25 || plain.BannerAnalysis == nil || plain.STARTTLSSecurity == nil {
+		t.Errorf("port 25 result = %+v", plain)
+	}
+	implicit := result.ConnectionResults["mx1.example.com:465"]
+	if !implicit.Connected || implicit.STARTTLSSecurity != nil {
+		t.Errorf("port 465 result = %+v, want no STARTTLS check", implicit)
+	}
+	closed := result.ConnectionResults["mx2.example.com:2525"]
+	if closed.Connected || closed.Error == "" || closed.BannerAnalysis != nil {
+		t.Errorf("port 2525 result = %+v", closed)
+	}
+	if result.Banner != "220 mx1.example.com ESMTP" {
+		t.Errorf("Banner = %q, want the greeting of the preferred MX", result.Banner)
+	}
+}
+
+// TestCheckSMTPMatrix tests that the matrix probes every address on every port with bounded concurrency
+func TestCheckSMTPMatrix(t *testing.T) {
+	repository := &stubSMTPRepository{
+		mxHosts: []smtp.MXHost{
+			{Host: "mx2.example.com", Preference: 20},
+			{Host: "mx1.example.com", Preference: 10},
+			{Host: "mx3.example.com", Preference: 30},
+		},
+		addresses: map[string][]string{
+			"mx1.example.com": {"192.0.2.1", "2001:db8::1", "192.0.2.2"},
+			"mx2.example.com": {"192.0.2.3", "2001:db8::3"},
+		},
+		open:  map[int]bool{25: true},
+		delay: 20 * time.Millisecond,
+	}
+	adapter := NewSMTPAdapter(repository, []int{25, 465, 587, 2525})
+
+	result, err := adapter.CheckSMTPMatrix(context.Background(), "example.com", nil, time.Second)
+	if err != nil {
+		t.Fatalf("CheckSMTPMatrix() error = %v", err)
+	}
+
+	// 5 addresses on the 4 configured ports
+	if len(result.Cells) != 20 || len(repository.dialed) != 20 {
+		t.Fatalf("%d cells for %d probes, want 20", len(result.Cells), len(repository.dialed))
+	}
+	if repository.peak > smtpConcurrency {
+		t.Errorf("%d probes ran at the same time, want at most %d", repository.peak, smtpConcurrency)
+	}
+	if first := result.Cells[0]; first.MXHost != "mx1.example.com" || first.Address != "192.0.2.1" || first.Port != 25 || !first.Connected {
+		t.Errorf("first cell = %+v", first)
+	}
+	if result.ResolveErrors["mx3.example.com"] != "no A or AAAA records" {
+		t.Errorf("ResolveErrors = %v", result.ResolveErrors)
+	}
+
+	// Ports of a request override the configured ones
+	result, err = adapter.CheckSMTPMatrix(context.Background(), "example.com", []int{587}, time.Second)
+	if err != nil || len(result.Cells) != 5 || result.Cells[0].Port != 587 || result.Cells[0].Connected {
+		t.Errorf("CheckSMTPMatrix(587) = %+v, %v", result, err)
+	}
+}
+
+// TestStartSinkDefaults tests that the configured sink settings fill the fields a start request leaves empty
+func TestStartSinkDefaults(t *testing.T) {
+	repository := &stubSMTPRepository{}
+	adapter := NewSMTPAdapter(repository, nil).WithSinkDefaults(smtp.SinkConfig{
+		Address:         "192.0.2.1:2525",
+		Domain:          "sink.example.com",
+		CertFile:        "sink.pem",
+		KeyFile:         "sink.key",
+		TestTTL:         time.Hour,
+		MaxTestMessages: 5,
+	})
+
+	if _, err := adapter.StartSink(context.Background(), &smtp.SinkConfig{Domain: "other.example.com", Timeout: time.Minute}); err != nil {
+		t.Fatalf("StartSink() error = %v", err)
+	}
+	want := smtp.SinkConfig{
+		Address:         "192.0.2.1:2525",
+		Domain:          "other.example.com",
+		CertFile:        "sink.pem",
+		KeyFile:         "sink.key",
+		Timeout:         time.Minute,
+		TestTTL:         time.Hour,
+		MaxTestMessages: 5,
+	}
+	if *repository.sinkConfig != want {
+		t.Errorf("StartSink() config = %+v, want %+v", *repository.sinkConfig, want)
+	}
+}
+
+// TestSmugglingHost tests that the smuggling probe only connects to a mail exchanger of the domain
+func TestSmugglingHost(t *testing.T) {
+	tests := []struct {
+		name    string
+		host    string
+		want    string // Host probed; empty when nothing may be probed
+		wantErr string
+	}{
+		{"most preferred MX", "", "mx1.example.com", ""},
+		{"other MX", "MX2.Example.com.", "mx2.example.com", ""},
+		{"foreign host", "mx.victim.example", "", "host mx.victim.example is not an MX of example.com"},
+		{"IP literal", "192.0.2.25", "", "is not an MX of example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository := &stubSMTPRepository{
+				mxHosts: []smtp.MXHost{{Host: "mx1.example.com", Preference: 10}, {Host: "mx2.example.com", Preference: 20}},
+			}
+			adapter := NewSMTPAdapter(repository, nil)
+
+			result, err := adapter.TestSmuggling(context.Background(), &smtp.SmugglingTestRequest{Domain: "example.com", Host: tt.host, Confirm: true})
+			if err != nil {
+				t.Fatalf("TestSmuggling() error = %v", err)
+			}
+			if tt.want == "" {
+				if len(repository.dialed) != 0 || !strings.Contains(result.Error, tt.wantErr) {
+					t.Errorf("probed %v, Error = %q; want no probe and %q", repository.dialed, result.Error, tt.wantErr)
+				}
+				return
+			}
+			if len(repository.dialed) != 1 || repository.dialed[0] != tt.want || result.Host != tt.want || result.Error != "" {
+				t.Errorf("probed %v, result = %+v; want %s", repository.dialed, result, tt.want)
+			}
+		})
+	}
+}
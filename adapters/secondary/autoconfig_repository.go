@@ -0,0 +1,123 @@
+// Package secondary contains the secondary adapters (implementing output ports)
+package secondary
+
+import (
+	"context"
+	"time"
+
+	"mxclone/domain/autoconfig"
+	autoconfigclient "mxclone/pkg/autoconfig"
+	"mxclone/pkg/types"
+)
+
+// AutoconfigRepository implements the autoconfiguration repository output port
+type AutoconfigRepository struct{}
+
+// NewAutoconfigRepository creates a new autoconfiguration repository
+func NewAutoconfigRepository() *AutoconfigRepository {
+	return &AutoconfigRepository{}
+}
+
+// Discover looks up the SRV records and fetches the autoconfig and Autodiscover documents
+func (r *AutoconfigRepository) Discover(ctx context.Context, req *autoconfig.CheckRequest) (*autoconfig.CheckResult, error) {
+	discovery, err := autoconfigclient.Discover(ctx, autoconfigclient.Options{
+		Domain:  req.Domain,
+		Email:   req.Email,
+		Timeout: req.Timeout,
+	})
+
+	result := &autoconfig.CheckResult{
+		Domain:       discovery.Domain,
+		Email:        discovery.Email,
+		Autoconfig:   toDomainDocuments(discovery.Autoconfig),
+		Autodiscover: toDomainDocuments(discovery.Autodiscover),
+	}
+	for _, svc := range discovery.SRV {
+		result.SRV = append(result.SRV, autoconfig.SRVService{
+			Service:     svc.Service,
+			Name:        svc.Name,
+			Found:       svc.Found,
+			NotProvided: svc.NotProvided,
+			Servers:     toDomainServerSettings(svc.Servers),
+			Error:       svc.Error,
+		})
+	}
+
+	return result, err
+}
+
+// ProbeServer connects to an advertised server with the security it is advertised with
+func (r *AutoconfigRepository) ProbeServer(ctx context.Context, server autoconfig.ServerSetting, timeout time.Duration) *autoconfig.ServerProbe {
+	probe := autoconfigclient.ProbeServer(ctx, types.MailServerSetting{
+		Source:   server.Source,
+		Protocol: server.Protocol,
+		Host:     server.Host,
+		Port:     server.Port,
+		Security: server.Security,
+	}, autoconfigclient.ProbeOptions{Timeout: timeout})
+
+	result := &autoconfig.ServerProbe{
+		Protocol:   probe.Protocol,
+		Host:       probe.Host,
+		Port:       probe.Port,
+		Security:   probe.Security,
+		Connected:  probe.Connected,
+		Banner:     probe.Banner,
+		STARTTLS:   probe.STARTTLS,
+		TLS:        probe.TLS,
+		TLSVersion: probe.TLSVersion,
+		Error:      probe.Error,
+	}
+	if cert := probe.Certificate; cert != nil {
+		result.Certificate = &autoconfig.Certificate{
+			Subject:       cert.Subject,
+			Issuer:        cert.Issuer,
+			DNSNames:      cert.DNSNames,
+			NotBefore:     cert.NotBefore,
+			NotAfter:      cert.NotAfter,
+			SelfSigned:    cert.SelfSigned,
+			HostnameMatch: cert.HostnameMatch,
+			Trusted:       cert.Trusted,
+			VerifyError:   cert.VerifyError,
+		}
+	}
+	return result
+}
+
+// toDomainDocuments converts autoconfig or Autodiscover documents to the domain model
+func toDomainDocuments(documents []types.AutoconfigDocument) []autoconfig.Document {
+	result := make([]autoconfig.Document, 0, len(documents))
+	for _, document := range documents {
+		result = append(result, autoconfig.Document{
+			Source:       document.Source,
+			URL:          document.URL,
+			Found:        document.Found,
+			StatusCode:   document.StatusCode,
+			RequiresAuth: document.RequiresAuth,
+			DisplayName:  document.DisplayName,
+			Redirect:     document.Redirect,
+			Servers:      toDomainServerSettings(document.Servers),
+			Error:        document.Error,
+		})
+	}
+	return result
+}
+
+// toDomainServerSettings converts advertised servers to the domain model
+func toDomainServerSettings(servers []types.MailServerSetting) []autoconfig.ServerSetting {
+	result := make([]autoconfig.ServerSetting, 0, len(servers))
+	for _, server := range servers {
+		result = append(result, autoconfig.ServerSetting{
+			Source:         server.Source,
+			Protocol:       server.Protocol,
+			Host:           server.Host,
+			Port:           server.Port,
+			Security:       server.Security,
+			Username:       server.Username,
+			Authentication: server.Authentication,
+			Priority:       server.Priority,
+			Weight:         server.Weight,
+		})
+	}
+	return result
+}
@@ -4,24 +4,29 @@ package secondary
 import (
 	"context"
 	"fmt"
-	"regexp"
-	"strconv"
+	"net"
 	"strings"
 	"time"
 
 	"mxclone/domain/dns"
+	"mxclone/domain/emailauth"
+	authpkg "mxclone/pkg/emailauth"
+	"mxclone/pkg/types"
 	"mxclone/ports/input"
 )
 
 // EmailAuthRepository implements the EmailAuth repository output port
 type EmailAuthRepository struct {
-	dnsService input.DNSPort
+	dnsService  input.DNSPort
+	reportStore *authpkg.DMARCReportStore
 }
 
 // NewEmailAuthRepository creates a new EmailAuth repository
-func NewEmailAuthRepository(dnsService input.DNSPort) *EmailAuthRepository {
+// dmarcReportDB is the file DMARC aggregate reports are stored in
+func NewEmailAuthRepository(dnsService input.DNSPort, dmarcReportDB string) *EmailAuthRepository {
 	return &EmailAuthRepository{
-		dnsService: dnsService,
+		dnsService:  dnsService,
+		reportStore: authpkg.NewDMARCReportStore(dmarcReportDB),
 	}
 }
 
@@ -48,48 +53,127 @@ func (r *EmailAuthRepository) GetSPFRecord(ctx context.Context, domain string, t
 	return "", false, nil
 }
 
-// ValidateSPFRecord validates the SPF record and extracts mechanisms
+// ValidateSPFRecord validates the syntax of every term of the SPF record and extracts mechanisms
 func (r *EmailAuthRepository) ValidateSPFRecord(record string) (bool, []string, error) {
-	// Basic validation checks
-	if !strings.HasPrefix(strings.ToLower(record), "v=spf1") {
-		return false, nil, fmt.Errorf("invalid SPF record: does not start with v=spf1")
+	terms, err := authpkg.CheckSPFSyntax(record)
+	if err != nil {
+		return false, terms, fmt.Errorf("invalid SPF record: %w", err)
 	}
 
-	// Extract mechanisms (like ip4:, ip6:, include:, etc.)
-	parts := strings.Fields(record)
-	mechanisms := make([]string, 0)
+	return true, terms, nil
+}
 
-	for _, part := range parts[1:] { // Skip "v=spf1"
-		// Validate common mechanisms
-		if strings.HasPrefix(part, "ip4:") ||
-			strings.HasPrefix(part, "ip6:") ||
-			strings.HasPrefix(part, "a:") ||
-			strings.HasPrefix(part, "mx:") ||
-			strings.HasPrefix(part, "include:") ||
-			strings.HasPrefix(part, "exists:") ||
-			part == "all" ||
-			part == "+all" ||
-			part == "-all" ||
-			part == "~all" ||
-			part == "?all" {
-			mechanisms = append(mechanisms, part)
-		}
+// EvaluateSPF evaluates the SPF policy of domain for a message from ip (check_host, RFC 7208)
+func (r *EmailAuthRepository) EvaluateSPF(ctx context.Context, ip, domain, sender, helo string, timeout time.Duration) (*emailauth.SPFEvaluation, error) {
+	// The timeout bounds the whole evaluation, including nested include and redirect lookups
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	evaluation := authpkg.CheckHost(ctxWithTimeout, authpkg.SPFCheckOptions{
+		IP:     net.ParseIP(ip),
+		Domain: domain,
+		Sender: sender,
+		Helo:   helo,
+	})
+
+	result := &emailauth.SPFEvaluation{
+		Domain:      evaluation.Domain,
+		IP:          evaluation.IP,
+		Sender:      evaluation.Sender,
+		Helo:        evaluation.Helo,
+		Result:      evaluation.Result,
+		Record:      evaluation.Record,
+		Mechanism:   evaluation.Mechanism,
+		Lookups:     evaluation.Lookups,
+		VoidLookups: evaluation.VoidLookups,
+		Explanation: evaluation.Explanation,
+	}
+	for _, step := range evaluation.Trace {
+		result.Trace = append(result.Trace, emailauth.SPFTraceStep{
+			Depth:  step.Depth,
+			Domain: step.Domain,
+			Term:   step.Term,
+			Target: step.Target,
+			Result: step.Result,
+			Detail: step.Detail,
+		})
 	}
 
-	// Check for valid termination with "all" mechanism
-	hasAllMechanism := false
-	for _, mechanism := range mechanisms {
-		if strings.HasSuffix(mechanism, "all") {
-			hasAllMechanism = true
-			break
+	return result, nil
+}
+
+// AnalyzeSPFLookups expands the include/redirect tree of a domain's SPF record and counts its DNS lookups
+func (r *EmailAuthRepository) AnalyzeSPFLookups(ctx context.Context, domain string, timeout time.Duration) (*emailauth.SPFLookupAnalysis, error) {
+	// The timeout bounds the whole analysis, including every record of the tree
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	analysis := authpkg.AnalyzeSPFLookups(ctxWithTimeout, domain, authpkg.SPFAnalysisOptions{})
+
+	result := &emailauth.SPFLookupAnalysis{
+		Domain:          analysis.Domain,
+		Tree:            toDomainSPFTreeNode(analysis.Tree),
+		Lookups:         analysis.Lookups,
+		LookupLimit:     analysis.LookupLimit,
+		VoidLookups:     analysis.VoidLookups,
+		VoidLookupLimit: analysis.VoidLookupLimit,
+		RecordLength:    analysis.RecordLength,
+		Error:           analysis.Error,
+	}
+	for _, finding := range analysis.Findings {
+		result.Findings = append(result.Findings, emailauth.SPFFinding{
+			Severity: finding.Severity,
+			Code:     finding.Code,
+			Domain:   finding.Host,
+			Message:  finding.Message,
+		})
+	}
+	if flattened := analysis.Flattened; flattened != nil {
+		result.Flattened = &emailauth.SPFFlattening{
+			Networks: flattened.Networks,
+			Lookups:  flattened.Lookups,
+			Warnings: flattened.Warnings,
+		}
+		for _, record := range flattened.Records {
+			result.Flattened.Records = append(result.Flattened.Records, emailauth.SPFFlattenedRecord{
+				Name:   record.Name,
+				Record: record.Record,
+				Length: record.Length,
+			})
 		}
 	}
 
-	if !hasAllMechanism {
-		return false, mechanisms, fmt.Errorf("invalid SPF record: missing terminating 'all' mechanism")
+	return result, nil
+}
+
+// toDomainSPFTreeNode converts an SPF lookup tree to the domain model
+func toDomainSPFTreeNode(node *types.SPFTreeNode) *emailauth.SPFTreeNode {
+	if node == nil {
+		return nil
 	}
 
-	return true, mechanisms, nil
+	result := &emailauth.SPFTreeNode{
+		Domain:        node.Domain,
+		Record:        node.Record,
+		Length:        node.Length,
+		StringLengths: node.StringLengths,
+		Lookups:       node.Lookups,
+		VoidLookups:   node.VoidLookups,
+		Error:         node.Error,
+	}
+	for _, term := range node.Terms {
+		result.Terms = append(result.Terms, emailauth.SPFTermAnalysis{
+			Term:        term.Term,
+			Lookups:     term.Lookups,
+			VoidLookups: term.VoidLookups,
+			MXHosts:     term.MXHosts,
+			Networks:    term.Networks,
+			Target:      toDomainSPFTreeNode(term.Target),
+			Note:        term.Note,
+		})
+	}
+
+	return result
 }
 
 // GetDKIMRecord retrieves the DKIM record for a domain and selector
@@ -110,8 +194,8 @@ func (r *EmailAuthRepository) GetDKIMRecord(ctx context.Context, domain string,
 	// Check if we found a DKIM record
 	if len(result.Lookups["TXT"]) > 0 {
 		for _, record := range result.Lookups["TXT"] {
-			// Basic validation: check if it looks like a DKIM record (contains v=DKIM1)
-			if strings.Contains(record, "v=DKIM1") {
+			// Basic validation: check if it looks like a DKIM record; v=DKIM1 is optional, p= is required
+			if strings.Contains(record, "v=DKIM1") || strings.Contains(record, "p=") {
 				return record, true, nil
 			}
 		}
@@ -120,24 +204,301 @@ func (r *EmailAuthRepository) GetDKIMRecord(ctx context.Context, domain string,
 	return "", false, nil
 }
 
-// ValidateDKIMRecord validates a DKIM record
-func (r *EmailAuthRepository) ValidateDKIMRecord(record string) (bool, error) {
-	// Basic DKIM record validation
-	if !strings.Contains(record, "v=DKIM1") {
-		return false, fmt.Errorf("invalid DKIM record: missing v=DKIM1")
+// DiscoverDKIMSelectors probes a domain for DKIM selectors, with provider guesses and wildcard detection
+func (r *EmailAuthRepository) DiscoverDKIMSelectors(ctx context.Context, domain string, selectors []string, timeout time.Duration) (*emailauth.DKIMDiscovery, error) {
+	// The timeout applies to each lookup; the lookups run ten at a time
+	discovery := authpkg.DiscoverDKIMSelectors(ctx, domain, authpkg.DKIMDiscoveryOptions{
+		Selectors: selectors,
+		Timeout:   timeout,
+	})
+
+	result := &emailauth.DKIMDiscovery{
+		Domain:         discovery.Domain,
+		Providers:      discovery.Providers,
+		Tried:          discovery.Tried,
+		Wildcard:       discovery.Wildcard,
+		WildcardRecord: discovery.WildcardRecord,
+		Error:          discovery.Error,
+	}
+	for _, hit := range discovery.Selectors {
+		result.Selectors = append(result.Selectors, emailauth.DKIMSelector{
+			Selector:    hit.Selector,
+			Record:      hit.Record,
+			CNAME:       hit.CNAME,
+			Source:      hit.Source,
+			Provider:    hit.Provider,
+			Attribution: hit.Attribution,
+			KeyType:     hit.KeyType,
+			KeyBits:     hit.KeyBits,
+			KeyStrength: hit.KeyStrength,
+		})
+	}
+
+	return result, nil
+}
+
+// VerifyDKIM verifies the DKIM signatures of a raw message, fetching keys with GetDKIMRecord
+func (r *EmailAuthRepository) VerifyDKIM(ctx context.Context, raw []byte, timeout time.Duration) (*emailauth.DKIMVerification, error) {
+	result := &emailauth.DKIMVerification{}
+	for _, sig := range authpkg.VerifyDKIM(ctx, raw, r.dkimKeyLookup(timeout), timeout) {
+		if sig.Result == authpkg.DKIMPass {
+			result.Passed++
+		}
+		result.Signatures = append(result.Signatures, toDomainDKIMSignature(sig))
+	}
+
+	return result, nil
+}
+
+// VerifyARC validates the ARC chain of a raw message, fetching keys with GetDKIMRecord
+func (r *EmailAuthRepository) VerifyARC(ctx context.Context, raw []byte, timeout time.Duration) (*emailauth.ARCValidation, error) {
+	validation := authpkg.VerifyARC(ctx, raw, r.dkimKeyLookup(timeout), timeout)
+
+	result := &emailauth.ARCValidation{
+		Result:     validation.Result,
+		Reason:     validation.Reason,
+		Instances:  validation.Instances,
+		OldestPass: validation.OldestPass,
+		Findings:   validation.Findings,
+	}
+	for _, set := range validation.Sets {
+		item := emailauth.ARCSetVerification{
+			Instance:        set.Instance,
+			SealDomain:      set.SealDomain,
+			SealSelector:    set.SealSelector,
+			SealAlgorithm:   set.SealAlgorithm,
+			SealTimestamp:   set.SealTimestamp,
+			ChainValidation: set.ChainValidation,
+			SealResult:      set.SealResult,
+			SealReason:      set.SealReason,
+		}
+		if set.MessageSignature != nil {
+			signature := toDomainDKIMSignature(*set.MessageSignature)
+			item.MessageSignature = &signature
+		}
+		if set.AuthenticationResults != nil {
+			item.AuthenticationResults = &toDomainAuthResults([]types.AuthenticationResults{*set.AuthenticationResults})[0]
+		}
+		result.Sets = append(result.Sets, item)
+	}
+
+	return result, nil
+}
+
+// CheckBIMI looks up the BIMI record of a domain and validates its DMARC policy, logo and mark certificate
+func (r *EmailAuthRepository) CheckBIMI(ctx context.Context, domain, selector string, timeout time.Duration) (*emailauth.BIMIResult, error) {
+	checked := authpkg.CheckBIMI(ctx, domain, authpkg.BIMIOptions{Selector: selector, Timeout: timeout})
+
+	result := &emailauth.BIMIResult{
+		Domain:     checked.Domain,
+		Selector:   checked.Selector,
+		RecordName: checked.RecordName,
+		Ready:      checked.Ready,
+		Findings:   checked.Findings,
+		Error:      checked.Error,
+	}
+	if checked.Record != nil {
+		record := emailauth.BIMIRecord(*checked.Record)
+		result.Record = &record
+	}
+	if checked.DMARC != nil {
+		dmarc := emailauth.BIMIDMARCCheck(*checked.DMARC)
+		result.DMARC = &dmarc
+	}
+	if checked.Logo != nil {
+		logo := emailauth.BIMILogo(*checked.Logo)
+		result.Logo = &logo
+	}
+	if checked.Certificate != nil {
+		cert := emailauth.BIMICertificate(*checked.Certificate)
+		result.Certificate = &cert
 	}
 
-	// Check for required tags (k=, p=)
-	hasKTag := strings.Contains(record, "k=")
-	hasPTag := strings.Contains(record, "p=")
+	return result, nil
+}
 
-	if !hasKTag || !hasPTag {
-		return false, fmt.Errorf("invalid DKIM record: missing required tags")
+// dkimKeyLookup returns a key lookup for signature verification backed by GetDKIMRecord
+func (r *EmailAuthRepository) dkimKeyLookup(timeout time.Duration) authpkg.DKIMKeyLookup {
+	return func(ctx context.Context, domain, selector string) (string, error) {
+		record, found, err := r.GetDKIMRecord(ctx, domain, selector, timeout)
+		if err != nil {
+			return "", err
+		}
+		if !found {
+			return "", authpkg.ErrNoDKIMRecord
+		}
+		return record, nil
+	}
+}
+
+// toDomainDKIMSignature converts the verification of one DKIM-Signature or ARC-Message-Signature
+func toDomainDKIMSignature(sig types.DKIMSignatureResult) emailauth.DKIMSignatureVerification {
+	return emailauth.DKIMSignatureVerification{
+		Domain:            sig.Domain,
+		Selector:          sig.Selector,
+		Algorithm:         sig.Algorithm,
+		Canonicalization:  sig.Canonicalization,
+		SignedHeaders:     sig.SignedHeaders,
+		Identity:          sig.Identity,
+		Timestamp:         sig.Timestamp,
+		Expiration:        sig.Expiration,
+		BodyLength:        sig.BodyLength,
+		UnsignedBodyBytes: sig.UnsignedBodyBytes,
+		UnsignedHeaders:   sig.UnsignedHeaders,
+		Warnings:          sig.Warnings,
+		Result:            sig.Result,
+		Reason:            sig.Reason,
+	}
+}
+
+// ValidateDKIMRecord validates a DKIM record: the record is invalid when its key has a critical problem
+func (r *EmailAuthRepository) ValidateDKIMRecord(record string) (bool, error) {
+	parsed, err := authpkg.ParseDKIMRecord(record)
+	if err != nil {
+		return false, fmt.Errorf("invalid DKIM record: %w", err)
+	}
+
+	for _, finding := range parsed.Findings {
+		if finding.Severity == types.SeverityCritical {
+			return false, fmt.Errorf("invalid DKIM record: %s", finding.Message)
+		}
 	}
 
 	return true, nil
 }
 
+// AnalyzeDKIMKey decodes the public key of a DKIM record and reports its type, size, strength and problems
+func (r *EmailAuthRepository) AnalyzeDKIMKey(record string) (*emailauth.DKIMKey, error) {
+	parsed, err := authpkg.ParseDKIMRecord(record)
+	if err != nil {
+		return nil, err
+	}
+
+	key := &emailauth.DKIMKey{
+		KeyType:  parsed.KeyType,
+		KeyBits:  parsed.KeyBits,
+		Strength: parsed.KeyStrength,
+		Revoked:  parsed.Revoked,
+		Testing:  parsed.Testing,
+	}
+	if parsed.HashAlgorithms != "" {
+		key.HashAlgorithms = strings.Split(parsed.HashAlgorithms, ":")
+	}
+	if parsed.Service != "" && parsed.Service != "*" {
+		key.Services = strings.Split(parsed.Service, ":")
+	}
+	for _, finding := range parsed.Findings {
+		key.Findings = append(key.Findings, emailauth.DKIMFinding{
+			Severity: finding.Severity,
+			Code:     finding.Code,
+			Message:  finding.Message,
+		})
+	}
+
+	return key, nil
+}
+
+// AnalyzeHeaders parses the header section of a message
+func (r *EmailAuthRepository) AnalyzeHeaders(raw []byte) *emailauth.HeaderAnalysis {
+	parsed := authpkg.AnalyzeHeaders(raw)
+
+	result := &emailauth.HeaderAnalysis{
+		From:                  parsed.From,
+		FromDomain:            parsed.FromDomain,
+		To:                    parsed.To,
+		Subject:               parsed.Subject,
+		Date:                  parsed.Date,
+		MessageID:             parsed.MessageID,
+		ReturnPath:            parsed.ReturnPath,
+		ReturnPathDomain:      parsed.ReturnPathDomain,
+		ReplyTo:               parsed.ReplyTo,
+		TotalDelaySeconds:     parsed.TotalDelaySeconds,
+		AuthenticationResults: toDomainAuthResults(parsed.AuthenticationResults),
+		DKIMDomains:           parsed.DKIMDomains,
+		Headers:               make([]emailauth.HeaderField, 0, len(parsed.Headers)),
+		Findings:              parsed.Findings,
+	}
+	for _, hop := range parsed.Hops {
+		result.Hops = append(result.Hops, emailauth.ReceivedHop{
+			Hop:          hop.Hop,
+			From:         hop.From,
+			FromHost:     hop.FromHost,
+			FromIP:       hop.FromIP,
+			By:           hop.By,
+			With:         hop.With,
+			ID:           hop.ID,
+			For:          hop.For,
+			Timestamp:    hop.Timestamp,
+			DelaySeconds: hop.DelaySeconds,
+			ClockSkew:    hop.ClockSkew,
+			TLS:          hop.TLS,
+			TLSDetails:   hop.TLSDetails,
+			Raw:          hop.Raw,
+		})
+	}
+	for _, set := range parsed.ARC {
+		arcSet := emailauth.ARCSetHeaders{
+			Instance:          set.Instance,
+			SealDomain:        set.SealDomain,
+			SealSelector:      set.SealSelector,
+			ChainValidation:   set.ChainValidation,
+			SignatureDomain:   set.SignatureDomain,
+			SignatureSelector: set.SignatureSelector,
+		}
+		if set.AuthenticationResults != nil {
+			arcSet.AuthenticationResults = &toDomainAuthResults([]types.AuthenticationResults{*set.AuthenticationResults})[0]
+		}
+		result.ARC = append(result.ARC, arcSet)
+	}
+	if list := parsed.List; list != nil {
+		result.List = &emailauth.ListHeaders{
+			ID:              list.ID,
+			Unsubscribe:     list.Unsubscribe,
+			UnsubscribePost: list.UnsubscribePost,
+			Subscribe:       list.Subscribe,
+			Post:            list.Post,
+			Help:            list.Help,
+			Owner:           list.Owner,
+			Archive:         list.Archive,
+		}
+	}
+	for _, field := range parsed.Headers {
+		result.Headers = append(result.Headers, emailauth.HeaderField{Name: field.Name, Value: field.Value})
+	}
+	return result
+}
+
+// DiscoverDMARCPolicy finds the DMARC record that applies to a domain, walking up its parent domains
+func (r *EmailAuthRepository) DiscoverDMARCPolicy(ctx context.Context, domain string, timeout time.Duration) (*emailauth.DMARCDiscovery, error) {
+	// The timeout bounds the whole walk, at most eight queries plus the existence checks
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	discovery := authpkg.DiscoverDMARCPolicy(ctxWithTimeout, nil, domain)
+
+	result := &emailauth.DMARCDiscovery{
+		Domain:               discovery.Domain,
+		OrganizationalDomain: discovery.OrganizationalDomain,
+		PolicyDomain:         discovery.PolicyDomain,
+		Record:               discovery.Record,
+		Inherited:            discovery.Inherited,
+		NonExistent:          discovery.NonExistent,
+		AppliedTag:           discovery.AppliedTag,
+		AppliedPolicy:        discovery.AppliedPolicy,
+		Reason:               discovery.Reason,
+		Error:                discovery.Error,
+	}
+	for _, lookup := range discovery.Lookups {
+		result.Lookups = append(result.Lookups, emailauth.DMARCLookup{
+			Name:   lookup.Name,
+			Result: lookup.Result,
+			Record: lookup.Record,
+		})
+	}
+
+	return result, nil
+}
+
 // GetDMARCRecord retrieves the DMARC record for a domain
 func (r *EmailAuthRepository) GetDMARCRecord(ctx context.Context, domain string, timeout time.Duration) (string, bool, error) {
 	// Create a context with timeout
@@ -168,50 +529,303 @@ func (r *EmailAuthRepository) GetDMARCRecord(ctx context.Context, domain string,
 
 // ParseDMARCRecord parses a DMARC record to extract policy information
 func (r *EmailAuthRepository) ParseDMARCRecord(record string) (bool, string, string, int, error) {
-	// Basic validation
-	if !strings.HasPrefix(strings.ToLower(record), "v=dmarc1") {
-		return false, "", "", 0, fmt.Errorf("invalid DMARC record: does not start with v=DMARC1")
+	parsed, err := authpkg.ParseDMARCRecord(record)
+	if err != nil {
+		return false, "", "", 0, err
 	}
 
-	// Extract policy
-	policyMatch := regexp.MustCompile(`p=([^;]+)`).FindStringSubmatch(record)
-	if len(policyMatch) < 2 {
-		return false, "", "", 0, fmt.Errorf("invalid DMARC record: missing required p= tag")
+	// The record is invalid when any tag is
+	if len(parsed.TagErrors) > 0 {
+		tagErr := parsed.TagErrors[0]
+		return false, parsed.Policy, parsed.SubPolicy, parsed.Pct, fmt.Errorf("invalid DMARC %s= tag: %s", tagErr.Tag, tagErr.Message)
 	}
-	policy := strings.ToLower(policyMatch[1])
 
-	// Valid policies are "none", "quarantine", or "reject"
-	if policy != "none" && policy != "quarantine" && policy != "reject" {
-		return false, policy, "", 0, fmt.Errorf("invalid DMARC policy value: %s", policy)
+	return true, parsed.Policy, parsed.SubPolicy, parsed.Pct, nil
+}
+
+// AnalyzeDMARCRecord parses every tag of a DMARC record and verifies that external report
+// destinations authorize the reports of the domain
+func (r *EmailAuthRepository) AnalyzeDMARCRecord(ctx context.Context, domain, record string, timeout time.Duration) (*emailauth.DMARCResult, error) {
+	parsed, err := authpkg.ParseDMARCRecord(record)
+	if err != nil {
+		return nil, err
 	}
 
-	// Extract subdomain policy (optional)
-	subdomainPolicy := ""
-	spMatch := regexp.MustCompile(`sp=([^;]+)`).FindStringSubmatch(record)
-	if len(spMatch) >= 2 {
-		subdomainPolicy = strings.ToLower(spMatch[1])
+	// The timeout bounds all the authorization lookups
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	authpkg.VerifyDMARCReportDestinations(ctxWithTimeout, nil, domain, parsed)
+
+	result := &emailauth.DMARCResult{
+		HasRecord:         true,
+		Record:            record,
+		IsValid:           len(parsed.TagErrors) == 0,
+		Policy:            parsed.Policy,
+		SubdomainPolicy:   parsed.SubPolicy,
+		Percentage:        parsed.Pct,
+		NonexistentPolicy: parsed.NPPolicy,
+		PSD:               parsed.PSD,
+		AlignmentDKIM:     parsed.ADKIM,
+		AlignmentSPF:      parsed.ASPF,
+		FailureOptions:    parsed.FailureOptions,
+		ReportFormats:     parsed.ReportFormats,
+		ReportInterval:    parsed.ReportInterval,
+		AggregateReports:  convertDMARCReportURIs(parsed.AggregateURIs),
+		FailureReports:    convertDMARCReportURIs(parsed.FailureURIs),
+		UnknownTags:       parsed.UnknownTags,
+	}
+	// Alignment modes default to relaxed
+	if result.AlignmentDKIM == "" {
+		result.AlignmentDKIM = "r"
+	}
+	if result.AlignmentSPF == "" {
+		result.AlignmentSPF = "r"
+	}
 
-		// Valid subdomain policies are also "none", "quarantine", or "reject"
-		if subdomainPolicy != "none" && subdomainPolicy != "quarantine" && subdomainPolicy != "reject" {
-			return false, policy, subdomainPolicy, 0, fmt.Errorf("invalid DMARC subdomain policy value: %s", subdomainPolicy)
-		}
+	var messages []string
+	for _, tagErr := range parsed.TagErrors {
+		result.TagErrors = append(result.TagErrors, emailauth.DMARCTagError{
+			Tag:     tagErr.Tag,
+			Value:   tagErr.Value,
+			Message: tagErr.Message,
+		})
+		messages = append(messages, fmt.Sprintf("%s=: %s", tagErr.Tag, tagErr.Message))
+	}
+	if len(messages) > 0 {
+		result.Error = "invalid DMARC record: " + strings.Join(messages, "; ")
 	}
 
-	// Extract percentage (optional, defaults to 100)
-	percentage := 100
-	pctMatch := regexp.MustCompile(`pct=(\d+)`).FindStringSubmatch(record)
-	if len(pctMatch) >= 2 {
-		var err error
-		percentage, err = strconv.Atoi(pctMatch[1])
-		if err != nil {
-			return false, policy, subdomainPolicy, 0, fmt.Errorf("invalid DMARC percentage value: %s", pctMatch[1])
+	return result, nil
+}
+
+// convertDMARCReportURIs converts parsed report destinations to the domain model
+func convertDMARCReportURIs(uris []types.DMARCReportURI) []emailauth.DMARCReportURI {
+	var result []emailauth.DMARCReportURI
+	for _, uri := range uris {
+		result = append(result, emailauth.DMARCReportURI{
+			URI:                 uri.URI,
+			Address:             uri.Address,
+			Domain:              uri.Domain,
+			MaxSize:             uri.MaxSize,
+			External:            uri.External,
+			Authorized:          uri.Authorized,
+			AuthorizationRecord: uri.AuthorizationRecord,
+			Error:               uri.Error,
+		})
+	}
+	return result
+}
+
+// ParseDMARCReports reads the aggregate reports of a file: plain XML, gzip or a zip archive
+func (r *EmailAuthRepository) ParseDMARCReports(name string, data []byte) ([]*emailauth.DMARCAggregateReport, error) {
+	parsed, err := authpkg.ParseDMARCAggregateReports(name, data)
+	if err != nil {
+		return nil, err
+	}
+	reports := make([]*emailauth.DMARCAggregateReport, 0, len(parsed))
+	for _, report := range parsed {
+		reports = append(reports, toDomainDMARCReport(report))
+	}
+	return reports, nil
+}
+
+// StoreDMARCReports adds reports to the local report database, skipping those already stored
+func (r *EmailAuthRepository) StoreDMARCReports(ctx context.Context, reports []*emailauth.DMARCAggregateReport) (int, int, error) {
+	stored := make([]*types.DMARCAggregateReport, 0, len(reports))
+	for _, report := range reports {
+		stored = append(stored, fromDomainDMARCReport(report))
+	}
+	return r.reportStore.Add(stored)
+}
+
+// LoadDMARCReports returns the stored reports of a domain that overlap a date range
+func (r *EmailAuthRepository) LoadDMARCReports(ctx context.Context, domain string, since, until time.Time) ([]*emailauth.DMARCAggregateReport, error) {
+	stored, err := r.reportStore.Reports(authpkg.DMARCReportFilter{Domain: domain, Since: since, Until: until})
+	if err != nil {
+		return nil, err
+	}
+	reports := make([]*emailauth.DMARCAggregateReport, 0, len(stored))
+	for _, report := range stored {
+		reports = append(reports, toDomainDMARCReport(report))
+	}
+	return reports, nil
+}
+
+// DMARCReportDatabase returns where the reports are stored
+func (r *EmailAuthRepository) DMARCReportDatabase() string {
+	return r.reportStore.Path()
+}
+
+// ParseForensicReport reads an ARF feedback report, such as a DMARC failure report
+func (r *EmailAuthRepository) ParseForensicReport(name string, data []byte) (*emailauth.ForensicReport, error) {
+	parsed, err := authpkg.ParseFeedbackReport(data)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &emailauth.ForensicReport{
+		ReportFrom:            parsed.ReportFrom,
+		ReportSubject:         parsed.ReportSubject,
+		ReportDate:            parsed.ReportDate,
+		HumanReadable:         parsed.HumanReadable,
+		FeedbackType:          parsed.FeedbackType,
+		UserAgent:             parsed.UserAgent,
+		Version:               parsed.Version,
+		AuthFailure:           parsed.AuthFailure,
+		IdentityAlignment:     parsed.IdentityAlignment,
+		SourceIP:              parsed.SourceIP,
+		ReportingMTA:          parsed.ReportingMTA,
+		ArrivalDate:           parsed.ArrivalDate,
+		OriginalMailFrom:      parsed.OriginalMailFrom,
+		OriginalRcptTo:        parsed.OriginalRcptTo,
+		ReportedDomain:        parsed.ReportedDomain,
+		DeliveryResult:        parsed.DeliveryResult,
+		Incidents:             parsed.Incidents,
+		DKIMDomain:            parsed.DKIMDomain,
+		DKIMSelector:          parsed.DKIMSelector,
+		DKIMIdentity:          parsed.DKIMIdentity,
+		SPFDNS:                parsed.SPFDNS,
+		AuthenticationResults: toDomainAuthResults(parsed.AuthenticationResults),
+		Fields:                parsed.Fields,
+		Warnings:              parsed.Warnings,
+	}
+	if original := parsed.Original; original != nil {
+		report.Original = &emailauth.ForensicOriginalMessage{
+			HeadersOnly:           original.HeadersOnly,
+			From:                  original.From,
+			FromDomain:            original.FromDomain,
+			ReturnPath:            original.ReturnPath,
+			To:                    original.To,
+			Subject:               original.Subject,
+			Date:                  original.Date,
+			MessageID:             original.MessageID,
+			DKIMDomains:           original.DKIMDomains,
+			AuthenticationResults: toDomainAuthResults(original.AuthenticationResults),
 		}
+		if original.Headers != nil {
+			report.Original.ReceivedHops = original.Headers.ReceivedHops
+			report.Original.HeaderFindings = original.Headers.Findings
+		}
+	}
+	return report, nil
+}
 
-		// Percentage should be between 0 and 100
-		if percentage < 0 || percentage > 100 {
-			return false, policy, subdomainPolicy, percentage, fmt.Errorf("invalid DMARC percentage value: %d (should be 0-100)", percentage)
+// toDomainAuthResults converts parsed Authentication-Results headers to the domain model
+func toDomainAuthResults(headers []types.AuthenticationResults) []emailauth.AuthenticationResults {
+	results := make([]emailauth.AuthenticationResults, 0, len(headers))
+	for _, header := range headers {
+		converted := emailauth.AuthenticationResults{AuthServID: header.AuthServID}
+		for _, result := range header.Results {
+			converted.Results = append(converted.Results, emailauth.AuthResultsEntry{
+				Method:     result.Method,
+				Result:     result.Result,
+				Reason:     result.Reason,
+				Properties: result.Properties,
+			})
 		}
+		results = append(results, converted)
+	}
+	return results
+}
+
+// LookupSourceIP finds the reverse DNS name and the ASN of a report source
+func (r *EmailAuthRepository) LookupSourceIP(ctx context.Context, ip string, timeout time.Duration) (*emailauth.SourceOrigin, error) {
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	origin, err := authpkg.LookupIPOrigin(ctxWithTimeout, nil, ip)
+	if origin == nil {
+		return nil, err
+	}
+	result := &emailauth.SourceOrigin{
+		PTR:     origin.PTR,
+		ASN:     origin.ASN,
+		ASName:  origin.ASName,
+		Country: origin.Country,
+		Prefix:  origin.Prefix,
+	}
+	if origin.PTR != "" {
+		result.PTRDomain = authpkg.OrganizationalDomain(origin.PTR)
+	}
+	return result, err
+}
+
+// toDomainDMARCReport converts a parsed aggregate report to the domain model
+func toDomainDMARCReport(report *types.DMARCAggregateReport) *emailauth.DMARCAggregateReport {
+	result := &emailauth.DMARCAggregateReport{
+		OrgName:   report.OrgName,
+		Email:     report.Email,
+		ReportID:  report.ReportID,
+		Begin:     report.Begin,
+		End:       report.End,
+		Domain:    report.Domain,
+		ADKIM:     report.ADKIM,
+		ASPF:      report.ASPF,
+		Policy:    report.Policy,
+		SubPolicy: report.SubPolicy,
+		Percent:   report.Percent,
+		Records:   make([]emailauth.DMARCAggregateRecord, 0, len(report.Records)),
+	}
+	for _, record := range report.Records {
+		result.Records = append(result.Records, emailauth.DMARCAggregateRecord{
+			SourceIP:     record.SourceIP,
+			Count:        record.Count,
+			Disposition:  record.Disposition,
+			DKIM:         record.DKIM,
+			SPF:          record.SPF,
+			Reasons:      record.Reasons,
+			HeaderFrom:   record.HeaderFrom,
+			EnvelopeFrom: record.EnvelopeFrom,
+			DKIMResults:  toDomainDMARCAuthResults(record.DKIMResults),
+			SPFResults:   toDomainDMARCAuthResults(record.SPFResults),
+		})
 	}
+	return result
+}
 
-	return true, policy, subdomainPolicy, percentage, nil
+// toDomainDMARCAuthResults converts raw report results to the domain model
+func toDomainDMARCAuthResults(results []types.DMARCAuthResult) []emailauth.DMARCAuthResult {
+	converted := make([]emailauth.DMARCAuthResult, 0, len(results))
+	for _, result := range results {
+		converted = append(converted, emailauth.DMARCAuthResult(result))
+	}
+	return converted
+}
+
+// fromDomainDMARCReport converts a domain aggregate report to the stored form
+func fromDomainDMARCReport(report *emailauth.DMARCAggregateReport) *types.DMARCAggregateReport {
+	result := &types.DMARCAggregateReport{
+		OrgName:   report.OrgName,
+		Email:     report.Email,
+		ReportID:  report.ReportID,
+		Begin:     report.Begin,
+		End:       report.End,
+		Domain:    report.Domain,
+		ADKIM:     report.ADKIM,
+		ASPF:      report.ASPF,
+		Policy:    report.Policy,
+		SubPolicy: report.SubPolicy,
+		Percent:   report.Percent,
+		Records:   make([]types.DMARCAggregateRecord, 0, len(report.Records)),
+	}
+	for _, record := range report.Records {
+		stored := types.DMARCAggregateRecord{
+			SourceIP:     record.SourceIP,
+			Count:        record.Count,
+			Disposition:  record.Disposition,
+			DKIM:         record.DKIM,
+			SPF:          record.SPF,
+			Reasons:      record.Reasons,
+			HeaderFrom:   record.HeaderFrom,
+			EnvelopeFrom: record.EnvelopeFrom,
+		}
+		for _, dkim := range record.DKIMResults {
+			stored.DKIMResults = append(stored.DKIMResults, types.DMARCAuthResult(dkim))
+		}
+		for _, spf := range record.SPFResults {
+			stored.SPFResults = append(stored.SPFResults, types.DMARCAuthResult(spf))
+		}
+		result.Records = append(result.Records, stored)
+	}
+	return result
 }
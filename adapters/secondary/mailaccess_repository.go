@@ -0,0 +1,101 @@
+// Package secondary contains the secondary adapters (implementing output ports)
+package secondary
+
+import (
+	"context"
+
+	"mxclone/domain/mailaccess"
+	mailaccessclient "mxclone/pkg/mailaccess"
+	"mxclone/pkg/types"
+)
+
+// MailAccessRepository implements the mail access repository output port
+type MailAccessRepository struct{}
+
+// NewMailAccessRepository creates a new mail access repository
+func NewMailAccessRepository() *MailAccessRepository {
+	return &MailAccessRepository{}
+}
+
+// CheckIMAP runs an IMAP session against a server
+func (r *MailAccessRepository) CheckIMAP(ctx context.Context, req *mailaccess.CheckRequest) (*mailaccess.CheckResult, error) {
+	report, err := mailaccessclient.CheckIMAP(ctx, toCheckOptions(req))
+	return toDomainCheckResult(report), err
+}
+
+// CheckPOP3 runs a POP3 session against a server
+func (r *MailAccessRepository) CheckPOP3(ctx context.Context, req *mailaccess.CheckRequest) (*mailaccess.CheckResult, error) {
+	report, err := mailaccessclient.CheckPOP3(ctx, toCheckOptions(req))
+	return toDomainCheckResult(report), err
+}
+
+// toCheckOptions converts a domain check request to client options
+func toCheckOptions(req *mailaccess.CheckRequest) mailaccessclient.CheckOptions {
+	return mailaccessclient.CheckOptions{
+		Host:     req.Host,
+		Port:     req.Port,
+		Username: req.Username,
+		Password: req.Password,
+		Timeout:  req.Timeout,
+	}
+}
+
+// toDomainCheckResult converts a client report to the domain model
+func toDomainCheckResult(report *types.MailAccessReport) *mailaccess.CheckResult {
+	result := &mailaccess.CheckResult{
+		Protocol:        report.Protocol,
+		Host:            report.Host,
+		Port:            report.Port,
+		ImplicitTLS:     report.ImplicitTLS,
+		Connected:       report.Connected,
+		Latency:         report.Latency,
+		Banner:          report.Banner,
+		Capabilities:    report.Capabilities,
+		TLSCapabilities: report.TLSCapabilities,
+		STARTTLS:        report.STARTTLS,
+		STARTTLSError:   report.STARTTLSError,
+		TLS:             report.TLS,
+		TLSVersion:      report.TLSVersion,
+		TLSCipher:       report.TLSCipher,
+		PlaintextAuth:   report.PlaintextAuth,
+		AuthMechanisms:  report.AuthMechanisms,
+		LoginDisabled:   report.LoginDisabled,
+		LoginTested:     report.LoginTested,
+		LoginSucceeded:  report.LoginSucceeded,
+		LoginMethod:     report.LoginMethod,
+		LoginResponse:   report.LoginResponse,
+		Error:           report.Error,
+	}
+
+	if cert := report.Certificate; cert != nil {
+		result.Certificate = &mailaccess.Certificate{
+			Subject:            cert.Subject,
+			Issuer:             cert.Issuer,
+			DNSNames:           cert.DNSNames,
+			SerialNumber:       cert.SerialNumber,
+			NotBefore:          cert.NotBefore,
+			NotAfter:           cert.NotAfter,
+			SignatureAlgorithm: cert.SignatureAlgorithm,
+			PublicKeyAlgorithm: cert.PublicKeyAlgorithm,
+			PublicKeyBits:      cert.PublicKeyBits,
+			SelfSigned:         cert.SelfSigned,
+			HostnameMatch:      cert.HostnameMatch,
+			Trusted:            cert.Trusted,
+			VerifyError:        cert.VerifyError,
+			ChainLength:        cert.ChainLength,
+		}
+	}
+
+	result.Transcript = make([]mailaccess.TranscriptEntry, 0, len(report.Transcript))
+	for _, entry := range report.Transcript {
+		result.Transcript = append(result.Transcript, mailaccess.TranscriptEntry{
+			Timestamp: entry.Timestamp,
+			Elapsed:   entry.Elapsed,
+			Direction: entry.Direction,
+			Line:      entry.Line,
+			Duration:  entry.Duration,
+		})
+	}
+
+	return result
+}
@@ -3,18 +3,32 @@ package secondary
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"net"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"mxclone/domain/dns"
+	"mxclone/domain/smtp"
+	"mxclone/pkg/emailauth"
+	smtpclient "mxclone/pkg/smtp"
+	"mxclone/pkg/types"
+	"mxclone/pkg/validation"
 	"mxclone/ports/input"
 )
 
 // SMTPRepository implements the SMTP repository output port
 type SMTPRepository struct {
 	dnsService input.DNSPort
+
+	// SMTP sink, when running
+	sinkMu         sync.Mutex
+	sink           *smtpclient.Sink
+	sinkSelfSigned bool
 }
 
 // NewSMTPRepository creates a new SMTP repository
@@ -24,124 +38,564 @@ func NewSMTPRepository(dnsService input.DNSPort) *SMTPRepository {
 	}
 }
 
-// GetMXRecords retrieves the MX records for a domain
+// GetMXRecords retrieves the MX host names of a domain in preference order
+// A null MX (RFC 7505) is left out, so a domain that accepts no mail has no records
 func (r *SMTPRepository) GetMXRecords(ctx context.Context, domain string) ([]string, error) {
-	// Create a context with timeout
+	mxHosts, err := r.GetMXHosts(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	mxRecords := make([]string, 0, len(mxHosts))
+	for _, mx := range mxHosts {
+		if mx.Host == "" || mx.Host == "." {
+			continue
+		}
+		mxRecords = append(mxRecords, mx.Host)
+	}
+
+	return mxRecords, nil
+}
+
+// GetMXHosts retrieves the MX hosts of a domain with their preferences, sorted by preference
+func (r *SMTPRepository) GetMXHosts(ctx context.Context, domain string) ([]smtp.MXHost, error) {
 	ctxWithTimeout, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
-	// Use our DNS service to look up MX records
 	result, err := r.dnsService.Lookup(ctxWithTimeout, domain, dns.TypeMX)
 	if err != nil {
 		return nil, err
 	}
 
-	// Extract MX server hostnames from the lookup results
-	mxRecords := make([]string, 0)
+	// Records are formatted as "mail.example.com. (priority: 10)"
+	mxHosts := make([]smtp.MXHost, 0, len(result.Lookups["MX"]))
 	for _, mx := range result.Lookups["MX"] {
-		// Extract hostname from the MX record (e.g., "mail.example.com (priority: 10)" -> "mail.example.com")
-		parts := strings.Split(mx, " ")
-		if len(parts) > 0 {
-			mxHost := parts[0]
-			// Remove any trailing dot
-			mxHost = strings.TrimSuffix(mxHost, ".")
-			mxRecords = append(mxRecords, mxHost)
+		var host string
+		var preference uint16
+		if _, err := fmt.Sscanf(mx, "%s (priority: %d)", &host, &preference); err != nil {
+			continue
 		}
+		mxHosts = append(mxHosts, smtp.MXHost{
+			Host:       strings.TrimSuffix(host, "."),
+			Preference: preference,
+		})
 	}
 
-	return mxRecords, nil
+	sort.SliceStable(mxHosts, func(i, j int) bool {
+		return mxHosts[i].Preference < mxHosts[j].Preference
+	})
+
+	return mxHosts, nil
+}
+
+// ResolveHostAddresses resolves the IPv4 and IPv6 addresses of a host
+func (r *SMTPRepository) ResolveHostAddresses(ctx context.Context, host string) ([]string, error) {
+	if net.ParseIP(host) != nil {
+		return []string{host}, nil
+	}
+
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	addresses := []string{}
+	var lastErr error
+	for _, recordType := range []dns.RecordType{dns.TypeA, dns.TypeAAAA} {
+		result, err := r.dnsService.Lookup(ctxWithTimeout, host, recordType)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		addresses = append(addresses, result.Lookups[string(recordType)]...)
+	}
+
+	if len(addresses) == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+	return addresses, nil
 }
 
 // ConnectToSMTPServer connects to an SMTP server and tests its capabilities
-// Returns: connected, latency, supportsStartTLS, authMethods, banner, error
-func (r *SMTPRepository) ConnectToSMTPServer(ctx context.Context, server string, port int, timeout time.Duration) (bool, time.Duration, bool, []string, string, error) {
-	// Establish a TCP connection to the SMTP server
+// The session is upgraded with STARTTLS when offered, so the transcript covers the whole dialogue
+func (r *SMTPRepository) ConnectToSMTPServer(ctx context.Context, server string, port int, timeout time.Duration) (*smtp.SessionInfo, error) {
+	return r.ConnectToSMTPAddress(ctx, server, server, port, timeout)
+}
+
+// ConnectToSMTPAddress is like ConnectToSMTPServer but dials a specific address of the server
+func (r *SMTPRepository) ConnectToSMTPAddress(ctx context.Context, server string, address string, port int, timeout time.Duration) (*smtp.SessionInfo, error) {
+	session := &smtp.SessionInfo{}
+
 	startTime := time.Now()
+	client, err := smtpclient.DialAddress(ctx, server, net.JoinHostPort(address, strconv.Itoa(port)), timeout, port == 465)
+	if err != nil {
+		return session, fmt.Errorf("failed to connect: %w", err)
+	}
+	defer client.Close()
+	defer func() {
+		session.Transcript = toDomainTranscript(client.Transcript)
+	}()
 
-	// Create a dialer with timeout
-	dialer := &net.Dialer{
-		Timeout: timeout,
+	session.Connected = true
+	session.Latency = time.Since(startTime)
+	session.Banner = client.Banner.Text()
+
+	if _, err := client.Hello(""); err != nil {
+		// We still report the connection even if EHLO failed
+		return session, nil
 	}
+	session.SupportsStartTLS, _ = client.Extension("STARTTLS")
+	session.AuthMethods = client.AuthMechanisms()
 
-	// Connect to the server
-	conn, err := dialer.DialContext(ctx, "tcp", fmt.Sprintf("%s:%d", server, port))
-	if err != nil {
-		return false, 0, false, nil, "", fmt.Errorf("failed to connect: %w", err)
+	if session.SupportsStartTLS && !client.TLS() {
+		reply, err := client.StartTLS(nil)
+		if err != nil || reply.Code != 220 {
+			return session, nil
+		}
+		// Mechanisms are often only offered once the session is encrypted
+		if _, err := client.Hello(""); err == nil {
+			session.AuthMethods = client.AuthMechanisms()
+		}
 	}
-	defer conn.Close()
 
-	// Calculate connection latency
-	latency := time.Since(startTime)
+	if state, ok := client.TLSState(); ok {
+		session.TLSVersion = tls.VersionName(state.Version)
+		session.TLSCipher = tls.CipherSuiteName(state.CipherSuite)
+	}
 
-	// Set read deadline for receiving the banner
-	conn.SetReadDeadline(time.Now().Add(timeout))
+	client.Quit()
+	return session, nil
+}
 
-	// Read the welcome banner
-	banner, err := readResponse(conn)
-	if err != nil {
-		return true, latency, false, nil, "", fmt.Errorf("failed to read banner: %w", err)
+// toDomainTranscript converts a protocol transcript to the domain model
+func toDomainTranscript(entries []types.TranscriptEntry) []smtp.TranscriptEntry {
+	transcript := make([]smtp.TranscriptEntry, 0, len(entries))
+	for _, entry := range entries {
+		transcript = append(transcript, smtp.TranscriptEntry{
+			Timestamp:    entry.Timestamp,
+			Elapsed:      entry.Elapsed,
+			Direction:    entry.Direction,
+			Line:         entry.Line,
+			Code:         entry.Code,
+			EnhancedCode: entry.EnhancedCode,
+			Duration:     entry.Duration,
+		})
 	}
+	return transcript
+}
 
-	// Check for STARTTLS support
-	supportsStartTLS, err := checkStartTLS(conn, timeout)
+// RunRelayTests runs the open relay test battery against a server
+// Returns: banner, test results, authenticated, error
+func (r *SMTPRepository) RunRelayTests(ctx context.Context, req *smtp.RelayTestRequest) (string, []*smtp.RelayTestCase, bool, error) {
+	report, err := smtpclient.RunRelayTests(ctx, smtpclient.RelayTestOptions{
+		Host:         req.Host,
+		Port:         req.Port,
+		FromAddress:  req.FromAddress,
+		ToAddress:    req.ToAddress,
+		TargetDomain: req.TargetDomain,
+		Username:     req.Username,
+		Password:     req.Password,
+		Timeout:      req.Timeout,
+	})
 	if err != nil {
-		// We still return true for connected even if checking STARTTLS failed
-		return true, latency, false, nil, banner, nil
+		return "", nil, false, err
 	}
 
-	// Check for supported authentication methods
-	authMethods, err := checkAuthMethods(conn, timeout)
-	if err != nil {
-		// We still return success with the info we have
-		return true, latency, supportsStartTLS, nil, banner, nil
+	tests := make([]*smtp.RelayTestCase, 0, len(report.Tests))
+	for _, test := range report.Tests {
+		tests = append(tests, &smtp.RelayTestCase{
+			Name:         test.Name,
+			Description:  test.Description,
+			MailFrom:     test.MailFrom,
+			RcptTo:       test.RcptTo,
+			Accepted:     test.Accepted,
+			Verdict:      test.Verdict,
+			ResponseCode: test.ResponseCode,
+			Response:     test.Response,
+			Transcript:   toDomainTranscript(test.Transcript),
+			Error:        test.Error,
+		})
 	}
 
-	return true, latency, supportsStartTLS, authMethods, banner, nil
+	return report.Banner, tests, report.Authenticated, nil
 }
 
-// readResponse reads a response from an SMTP server
-func readResponse(conn net.Conn) (string, error) {
-	buffer := make([]byte, 1024)
-	n, err := conn.Read(buffer)
-	if err != nil {
-		return "", err
+// RunSubmissionTest runs an authenticated submission test against a server
+func (r *SMTPRepository) RunSubmissionTest(ctx context.Context, req *smtp.SubmissionTestRequest) (*smtp.SubmissionTestResult, error) {
+	report, err := smtpclient.RunSubmissionTest(ctx, smtpclient.SubmissionTestOptions{
+		Host:               req.Host,
+		Port:               req.Port,
+		Username:           req.Username,
+		Password:           req.Password,
+		Mechanism:          req.Mechanism,
+		AllowPlaintextAuth: req.AllowPlaintextAuth,
+		FromAddress:        req.FromAddress,
+		ToAddress:          req.ToAddress,
+		SendMessage:        req.SendMessage,
+		Timeout:            req.Timeout,
+	})
+
+	result := &smtp.SubmissionTestResult{
+		Host:           report.Host,
+		Port:           report.Port,
+		ImplicitTLS:    report.ImplicitTLS,
+		TLSVersion:     report.TLSVersion,
+		AuthMechanisms: report.AuthMechanisms,
+		Mechanism:      report.Mechanism,
+		Authenticated:  report.Authenticated,
+		MessageSent:    report.MessageSent,
+		MessageID:      report.MessageID,
+		TrackingID:     report.TrackingID,
+		Stages:         make([]*smtp.SubmissionStage, 0, len(report.Stages)),
+		Transcript:     toDomainTranscript(report.Transcript),
+		Error:          report.Error,
+	}
+	for _, stage := range report.Stages {
+		result.Stages = append(result.Stages, &smtp.SubmissionStage{
+			Name:         stage.Name,
+			Success:      stage.Success,
+			Skipped:      stage.Skipped,
+			ResponseCode: stage.ResponseCode,
+			EnhancedCode: stage.EnhancedCode,
+			Response:     stage.Response,
+			Duration:     stage.Duration,
+			Error:        stage.Error,
+		})
 	}
 
-	return string(buffer[:n]), nil
+	return result, err
 }
 
-// checkStartTLS checks if the server supports STARTTLS
-func checkStartTLS(conn net.Conn, timeout time.Duration) (bool, error) {
-	// Send EHLO command
-	conn.SetWriteDeadline(time.Now().Add(timeout))
-	_, err := conn.Write([]byte("EHLO mxclone.example.com\r\n"))
-	if err != nil {
-		return false, err
+// AnalyzeBanner analyzes the greeting and reply behavior of an SMTP server
+func (r *SMTPRepository) AnalyzeBanner(ctx context.Context, server string, port int, recipientDomain string, timeout time.Duration) (*smtp.BannerAnalysis, error) {
+	analysis, err := smtpclient.AnalyzeBanner(ctx, smtpclient.BannerOptions{
+		Host:            server,
+		Port:            port,
+		MXHost:          server,
+		RecipientDomain: recipientDomain,
+		Timeout:         timeout,
+	})
+	if analysis == nil {
+		return nil, err
+	}
+
+	return &smtp.BannerAnalysis{
+		Banner:              analysis.Banner,
+		GreetingCode:        analysis.GreetingCode,
+		GreetingDelay:       analysis.GreetingDelay,
+		MultiLineGreeting:   analysis.MultiLineGreeting,
+		PreGreetPause:       analysis.PreGreetPause,
+		EarlyTalkerTested:   analysis.EarlyTalkerTested,
+		EarlyTalkerRejected: analysis.EarlyTalkerRejected,
+		AverageResponseTime: analysis.AverageResponseTime,
+		Tarpitting:          analysis.Tarpitting,
+		RcptCode:            analysis.RcptCode,
+		RcptResponse:        analysis.RcptResponse,
+		GreylistSuspected:   analysis.GreylistSuspected,
+		Greylisting:         analysis.Greylisting,
+		RetryCode:           analysis.RetryCode,
+		BannerHostname:      analysis.BannerHostname,
+		ServerIP:            analysis.ServerIP,
+		PTRNames:            analysis.PTRNames,
+		MatchesPTR:          analysis.MatchesPTR,
+		MatchesMX:           analysis.MatchesMX,
+		Software:            analysis.Software,
+		SoftwareVersion:     analysis.SoftwareVersion,
+		LeaksVersion:        analysis.LeaksVersion,
+		Findings:            analysis.Findings,
+		Error:               analysis.Error,
+	}, err
+}
+
+// RunSmugglingTest probes how a mail exchanger handles non-standard end-of-data sequences
+func (r *SMTPRepository) RunSmugglingTest(ctx context.Context, req *smtp.SmugglingTestRequest) (*smtp.SmugglingTestResult, error) {
+	report, err := smtpclient.RunSmugglingProbe(ctx, smtpclient.SmugglingProbeOptions{
+		Host:        req.Host,
+		Port:        req.Port,
+		Domain:      req.Domain,
+		FromAddress: req.FromAddress,
+		Confirm:     req.Confirm,
+		Timeout:     req.Timeout,
+	})
+
+	result := &smtp.SmugglingTestResult{
+		Host:                  report.Host,
+		Port:                  report.Port,
+		Recipient:             report.Recipient,
+		BareLFCommand:         report.BareLFCommand,
+		BareLFCommandCode:     report.BareLFCommandCode,
+		BareLFCommandResponse: report.BareLFCommandResponse,
+		Sequences:             make([]*smtp.SmugglingSequenceResult, 0, len(report.Sequences)),
+		Vulnerable:            report.Vulnerable,
+		Findings:              report.Findings,
+		Transcript:            toDomainTranscript(report.Transcript),
+		Error:                 report.Error,
+	}
+	for _, seq := range report.Sequences {
+		result.Sequences = append(result.Sequences, &smtp.SmugglingSequenceResult{
+			Name:       seq.Name,
+			Outcome:    seq.Outcome,
+			Code:       seq.Code,
+			Response:   seq.Response,
+			TrackingID: seq.TrackingID,
+			Error:      seq.Error,
+		})
+	}
+
+	return result, err
+}
+
+// NormalizeEmailAddress checks the syntax of an email address and returns it with an ASCII domain
+func (r *SMTPRepository) NormalizeEmailAddress(address string) (string, error) {
+	return validation.NormalizeEmail(address)
+}
+
+// ProbeRecipients offers each recipient to a mail exchanger in one RCPT TO transaction without sending mail
+func (r *SMTPRepository) ProbeRecipients(ctx context.Context, host string, recipients []string, timeout time.Duration) (*smtp.RecipientProbe, error) {
+	report, err := smtpclient.ProbeRecipients(ctx, smtpclient.RecipientProbeOptions{
+		Host:       host,
+		Recipients: recipients,
+		Timeout:    timeout,
+	})
+
+	probe := &smtp.RecipientProbe{
+		Host:       report.Host,
+		TLS:        report.TLS,
+		Replies:    make([]smtp.RecipientReply, 0, len(report.Results)),
+		Transcript: toDomainTranscript(report.Transcript),
+		Error:      report.Error,
+	}
+	for _, result := range report.Results {
+		probe.Replies = append(probe.Replies, smtp.RecipientReply{
+			Address:      result.Address,
+			Code:         result.Code,
+			EnhancedCode: result.EnhancedCode,
+			Response:     result.Response,
+			Accepted:     result.Accepted,
+			Deferred:     result.Deferred,
+			Rejected:     result.Rejected,
+			Error:        result.Error,
+		})
+	}
+
+	return probe, err
+}
+
+// CheckSTARTTLSSecurity checks an SMTP server for STARTTLS command injection and a failing STARTTLS
+func (r *SMTPRepository) CheckSTARTTLSSecurity(ctx context.Context, server string, port int, timeout time.Duration) (*smtp.STARTTLSSecurity, error) {
+	report, err := smtpclient.CheckSTARTTLSSecurity(ctx, smtpclient.STARTTLSSecurityOptions{
+		Host:    server,
+		Port:    port,
+		Timeout: timeout,
+	})
+	if report == nil {
+		return nil, err
+	}
+
+	return &smtp.STARTTLSSecurity{
+		Advertised:          report.Advertised,
+		HandshakeSucceeded:  report.HandshakeSucceeded,
+		HandshakeError:      report.HandshakeError,
+		TLSVersion:          report.TLSVersion,
+		InjectionTested:     report.InjectionTested,
+		InjectionVulnerable: report.InjectionVulnerable,
+		InjectionResponse:   report.InjectionResponse,
+		Findings:            report.Findings,
+		Transcript:          toDomainTranscript(report.Transcript),
+		Error:               report.Error,
+	}, err
+}
+
+// StartSink starts the receive-only SMTP sink
+func (r *SMTPRepository) StartSink(ctx context.Context, config *smtp.SinkConfig) (*smtp.SinkStatus, error) {
+	r.sinkMu.Lock()
+	defer r.sinkMu.Unlock()
+	if r.sink != nil {
+		return nil, smtp.ErrSinkRunning
 	}
 
-	// Read server response
-	conn.SetReadDeadline(time.Now().Add(timeout))
-	response, err := readResponse(conn)
+	opts := smtpclient.SinkOptions{
+		Address:         config.Address,
+		Domain:          config.Domain,
+		Hostname:        config.Hostname,
+		Timeout:         config.Timeout,
+		TestTTL:         config.TestTTL,
+		MaxTestMessages: config.MaxTestMessages,
+		Analyze:         analyzeSinkMessage,
+	}
+	if config.CertFile != "" || config.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the sink certificate: %w", err)
+		}
+		opts.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+	}
+
+	sink, err := smtpclient.NewSink(opts)
 	if err != nil {
-		return false, err
+		return nil, err
+	}
+	if err := sink.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start the SMTP sink: %w", err)
+	}
+	r.sink = sink
+	r.sinkSelfSigned = opts.TLSConfig == nil
+
+	return r.sinkStatus(), nil
+}
+
+// StopSink stops the SMTP sink
+func (r *SMTPRepository) StopSink(ctx context.Context) error {
+	r.sinkMu.Lock()
+	defer r.sinkMu.Unlock()
+	if r.sink == nil {
+		return smtp.ErrSinkNotRunning
+	}
+	err := r.sink.Close()
+	r.sink = nil
+	return err
+}
+
+// GetSinkStatus describes the SMTP sink
+func (r *SMTPRepository) GetSinkStatus(ctx context.Context) *smtp.SinkStatus {
+	r.sinkMu.Lock()
+	defer r.sinkMu.Unlock()
+	return r.sinkStatus()
+}
+
+// sinkStatus describes the sink; the caller holds sinkMu
+func (r *SMTPRepository) sinkStatus() *smtp.SinkStatus {
+	if r.sink == nil {
+		return &smtp.SinkStatus{}
 	}
+	return &smtp.SinkStatus{
+		Running:    true,
+		Address:    r.sink.Addr(),
+		Domain:     r.sink.Domain(),
+		SelfSigned: r.sinkSelfSigned,
+	}
+}
 
-	// Check if STARTTLS is in the response
-	return strings.Contains(strings.ToUpper(response), "STARTTLS"), nil
+// CreateSinkTest generates a test address on the running sink
+func (r *SMTPRepository) CreateSinkTest(ctx context.Context) (*smtp.SinkTest, error) {
+	r.sinkMu.Lock()
+	defer r.sinkMu.Unlock()
+	if r.sink == nil {
+		return nil, smtp.ErrSinkNotRunning
+	}
+
+	id, address, err := r.sink.NewTest()
+	if err != nil {
+		return nil, err
+	}
+	return &smtp.SinkTest{ID: id, Address: address}, nil
 }
 
-// checkAuthMethods checks which authentication methods are supported
-func checkAuthMethods(conn net.Conn, timeout time.Duration) ([]string, error) {
-	// We already sent EHLO in checkStartTLS, so we just need to check the response
-	// But the response might have been consumed, so we might need to send EHLO again
+// GetSinkTest returns a sink test with the reports of the messages it received
+func (r *SMTPRepository) GetSinkTest(ctx context.Context, id string) (*smtp.SinkTest, error) {
+	r.sinkMu.Lock()
+	sink := r.sink
+	r.sinkMu.Unlock()
+	if sink == nil {
+		return nil, smtp.ErrSinkNotRunning
+	}
 
-	authMethods := []string{}
+	messages, ok := sink.Messages(id)
+	if !ok {
+		return nil, smtp.ErrSinkTestNotFound
+	}
 
-	// Check for "AUTH " in the response (from previous EHLO)
-	// We would need to keep the full response from the previous EHLO to do this properly
+	test := &smtp.SinkTest{ID: strings.ToLower(id), Address: sink.TestAddress(id)}
+	for _, message := range messages {
+		test.Messages = append(test.Messages, toDomainSinkMessage(message))
+	}
+
+	return test, nil
+}
 
-	// This is a simplified implementation - in a real application,
-	// we would parse the full EHLO response and extract all auth methods
+// analyzeSinkMessage attaches the SPF, DKIM, DMARC and header report to a received message
+func analyzeSinkMessage(ctx context.Context, message *types.SinkMessage) {
+	message.Auth = emailauth.AnalyzeMessage(ctx, emailauth.MessageAnalysisOptions{
+		ClientIP: message.ClientIP,
+		Helo:     message.Helo,
+		MailFrom: message.MailFrom,
+		Raw:      message.Raw,
+		Timeout:  10 * time.Second,
+	})
+}
+
+// toDomainSinkMessage converts a received message and its authentication report to the domain model
+func toDomainSinkMessage(message *types.SinkMessage) *smtp.SinkMessageReport {
+	report := &smtp.SinkMessageReport{
+		ReceivedAt: message.ReceivedAt,
+		ClientIP:   message.ClientIP,
+		Helo:       message.Helo,
+		MailFrom:   message.MailFrom,
+		Recipients: message.Recipients,
+		TLS:        message.TLS,
+		TLSVersion: message.TLSVersion,
+		Size:       message.Size,
+	}
+
+	auth := message.Auth
+	if auth == nil {
+		return report
+	}
+	report.Error = auth.Error
+	if spf := auth.SPF; spf != nil {
+		report.SPF = &smtp.SinkSPFResult{
+			Domain:      spf.Domain,
+			IP:          spf.IP,
+			Result:      spf.Result,
+			Record:      spf.Record,
+			Mechanism:   spf.Mechanism,
+			Lookups:     spf.Lookups,
+			Explanation: spf.Explanation,
+		}
+	}
+	for _, sig := range auth.DKIM {
+		report.DKIM = append(report.DKIM, smtp.SinkDKIMSignature{
+			Domain:           sig.Domain,
+			Selector:         sig.Selector,
+			Algorithm:        sig.Algorithm,
+			Canonicalization: sig.Canonicalization,
+			SignedHeaders:    sig.SignedHeaders,
+			Result:           sig.Result,
+			Reason:           sig.Reason,
+		})
+	}
+	if dmarc := auth.DMARC; dmarc != nil {
+		report.DMARC = &smtp.SinkDMARCResult{
+			FromDomain:   dmarc.FromDomain,
+			PolicyDomain: dmarc.PolicyDomain,
+			Record:       dmarc.Record,
+			Policy:       dmarc.Policy,
+			SPFAligned:   dmarc.SPFAligned,
+			DKIMAligned:  dmarc.DKIMAligned,
+			Result:       dmarc.Result,
+			Disposition:  dmarc.Disposition,
+			Error:        dmarc.Error,
+		}
+		for _, lookalike := range dmarc.Lookalikes {
+			report.DMARC.Lookalikes = append(report.DMARC.Lookalikes, smtp.SinkLookalikeDomain{
+				Domain:               lookalike.Domain,
+				Source:               lookalike.Source,
+				OrganizationalDomain: lookalike.OrganizationalDomain,
+				ImitatedDomain:       lookalike.ImitatedDomain,
+				Distance:             lookalike.Distance,
+				Reason:               lookalike.Reason,
+			})
+		}
+	}
+	if headers := auth.Headers; headers != nil {
+		report.Headers = &smtp.SinkHeaderReport{
+			From:         headers.From,
+			To:           headers.To,
+			Subject:      headers.Subject,
+			Date:         headers.Date,
+			MessageID:    headers.MessageID,
+			ReceivedHops: headers.ReceivedHops,
+			Findings:     headers.Findings,
+		}
+	}
 
-	return authMethods, nil
+	return report
 }
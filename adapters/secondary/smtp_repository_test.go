@@ -0,0 +1,123 @@
+package secondary
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"mxclone/domain/dns"
+	"mxclone/domain/smtp"
+)
+
+// stubDNS answers lookups from a fixed table keyed by name and record type
+type stubDNS struct {
+	records map[string][]string
+	errs    map[string]error
+	lookups []string
+}
+
+func (d *stubDNS) Lookup(ctx context.Context, domain string, recordType dns.RecordType) (*dns.DNSResult, error) {
+	key := domain + " " + string(recordType)
+	d.lookups = append(d.lookups, key)
+	if err := d.errs[key]; err != nil {
+		return nil, err
+	}
+	return &dns.DNSResult{Lookups: map[string][]string{string(recordType): d.records[key]}}, nil
+}
+
+func (d *stubDNS) LookupAll(ctx context.Context, domain string) (*dns.DNSResult, error) {
+	return nil, errors.New("not implemented")
+}
+
+// TestGetMXHosts tests parsing and preference ordering of MX records
+func TestGetMXHosts(t *testing.T) {
+	repository := NewSMTPRepository(&stubDNS{
+		records: map[string][]string{
+			"example.com MX": {
+				"mx3.example.com. (priority: 30)",
+				"mx1.example.com. (priority: 10)",
+				"not an mx record",
+				"mx2.example.com. (priority: 10)",
+			},
+			"null.example MX": {". (priority: 0)"},
+		},
+		errs: map[string]error{"broken.example MX": errors.New("SERVFAIL")},
+	})
+
+	mxHosts, err := repository.GetMXHosts(context.Background(), "example.com")
+	if err != nil {
+		t.Fatalf("GetMXHosts() error = %v", err)
+	}
+	want := []smtp.MXHost{
+		{Host: "mx1.example.com", Preference: 10},
+		{Host: "mx2.example.com", Preference: 10},
+		{Host: "mx3.example.com", Preference: 30},
+	}
+	if !reflect.DeepEqual(mxHosts, want) {
+		t.Errorf("GetMXHosts() = %v, want %v", mxHosts, want)
+	}
+
+	// A null MX is returned as a host, but left out of the MX records
+	mxHosts, err = repository.GetMXHosts(context.Background(), "null.example")
+	if err != nil || len(mxHosts) != 1 || mxHosts[0].Host != "" {
+		t.Errorf("GetMXHosts(null MX) = %v, %v", mxHosts, err)
+	}
+	mxRecords, err := repository.GetMXRecords(context.Background(), "null.example")
+	if err != nil || len(mxRecords) != 0 {
+		t.Errorf("GetMXRecords(null MX) = %v, %v", mxRecords, err)
+	}
+
+	if _, err := repository.GetMXHosts(context.Background(), "broken.example"); err == nil {
+		t.Error("GetMXHosts() did not return the lookup error")
+	}
+}
+
+// TestResolveHostAddresses tests that IPv4 and IPv6 addresses are combined and partial failures tolerated
+func TestResolveHostAddresses(t *testing.T) {
+	resolver := &stubDNS{
+		records: map[string][]string{
+			"mx1.example.com A":    {"192.0.2.25", "192.0.2.26"},
+			"mx1.example.com AAAA": {"2001:db8::25"},
+			"mx2.example.com AAAA": {"2001:db8::26"},
+		},
+		errs: map[string]error{
+			"mx2.example.com A":    errors.New("SERVFAIL"),
+			"mx3.example.com A":    errors.New("SERVFAIL"),
+			"mx3.example.com AAAA": errors.New("NXDOMAIN"),
+		},
+	}
+	repository := NewSMTPRepository(resolver)
+
+	tests := []struct {
+		host    string
+		want    []string
+		wantErr bool
+	}{
+		{"mx1.example.com", []string{"192.0.2.25", "192.0.2.26", "2001:db8::25"}, false},
+		{"mx2.example.com", []string{"2001:db8::26"}, false},
+		{"mx3.example.com", nil, true},
+		{"mx4.example.com", []string{}, false},
+		{"192.0.2.1", []string{"192.0.2.1"}, false},
+		{"2001:db8::1", []string{"2001:db8::1"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			addresses, err := repository.ResolveHostAddresses(context.Background(), tt.host)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ResolveHostAddresses() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(addresses, tt.want) {
+				t.Errorf("ResolveHostAddresses() = %v, want %v", addresses, tt.want)
+			}
+		})
+	}
+
+	// IP literals are not looked up
+	for _, lookup := range resolver.lookups {
+		if lookup[0] >= '0' && lookup[0] <= '9' {
+			t.Errorf("IP literal was looked up: %s", lookup)
+		}
+	}
+}
@@ -1,9 +1,12 @@
 package commands
 
 import (
+	"context"
+	"mxclone/domain/smtp"
 	"mxclone/internal"
 	"mxclone/internal/api"
 	"mxclone/internal/config"
+	"time"
 
 	"github.com/spf13/cobra"
 )
@@ -29,8 +32,25 @@ var ApiCmd = &cobra.Command{
 		smtpService := Container.GetSMTPService()
 		emailAuthService := Container.GetEmailAuthService()
 		networkToolsService := Container.GetNetworkToolsService()
+		mailAccessService := Container.GetMailAccessService()
 		logger := Container.GetLogger()
 
+		// Start the SMTP sink so inbound test messages can be authenticated through the API
+		if cfg.SinkEnabled {
+			status, err := smtpService.StartSink(context.Background(), &smtp.SinkConfig{
+				Address:  cfg.SinkAddress,
+				Domain:   cfg.SinkDomain,
+				Hostname: cfg.SinkHostname,
+				CertFile: cfg.SinkCertFile,
+				KeyFile:  cfg.SinkKeyFile,
+				Timeout:  time.Duration(cfg.SMTPTimeout) * time.Second,
+			})
+			if err != nil {
+				logger.Fatal("Failed to start SMTP sink: %v", err)
+			}
+			logger.Info("SMTP sink listening on %s for @%s", status.Address, status.Domain)
+		}
+
 		// Start API server with dependencies
 		err = api.StartAPIServer(
 			dnsService,
@@ -38,6 +58,7 @@ var ApiCmd = &cobra.Command{
 			smtpService,
 			emailAuthService,
 			networkToolsService,
+			mailAccessService,
 			logger,
 		)
 
@@ -5,11 +5,15 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"net"
 	"os"
 	"time"
 
 	"github.com/spf13/cobra"
 
+	"mxclone/internal/config"
+	"mxclone/pkg/emailauth"
 	"mxclone/pkg/validation"
 )
 
@@ -62,9 +66,8 @@ This includes SPF, DKIM, and DMARC record retrieval and validation.`,
 				fmt.Printf("Checking email authentication with DKIM for selector %s...\n", selector)
 				dkimSelectors = []string{selector}
 			} else {
-				// Try with common selectors
-				fmt.Printf("No selector provided, trying with default selectors...\n")
-				dkimSelectors = []string{"mail", "default", "google", "selector1", "dkim"}
+				// Without selectors the service discovers them
+				fmt.Printf("No selector provided, discovering selectors...\n")
 			}
 		}
 
@@ -91,10 +94,416 @@ This includes SPF, DKIM, and DMARC record retrieval and validation.`,
 	},
 }
 
+// AuthSPFCmd evaluates SPF for a connecting IP
+var AuthSPFCmd = &cobra.Command{
+	Use:   "spf [domain]",
+	Short: "Evaluate SPF for a sending IP",
+	Long: `Evaluate the SPF policy for a message sent from --ip (check_host, RFC 7208).
+Include and redirect are followed recursively, macros are expanded and the DNS lookup
+limits are enforced. The domain defaults to the domain of --sender, then to --helo.
+The result, the exp= explanation and the full evaluation trace are printed.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		// Get command flags
+		ip, _ := cmd.Flags().GetString("ip")
+		sender, _ := cmd.Flags().GetString("sender")
+		helo, _ := cmd.Flags().GetString("helo")
+		timeout, _ := cmd.Flags().GetInt("timeout")
+		outputFormat, _ := cmd.Flags().GetString("output")
+
+		if net.ParseIP(ip) == nil {
+			fmt.Fprintf(os.Stderr, "Error: --ip must be a valid IP address\n")
+			os.Exit(1)
+		}
+
+		domain := ""
+		if len(args) > 0 {
+			domain = validation.SanitizeDomain(args[0])
+			if err := validation.ValidateDomain(domain); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+		} else if sender == "" && helo == "" {
+			fmt.Fprintf(os.Stderr, "Error: a domain, --sender or --helo is required\n")
+			os.Exit(1)
+		}
+
+		ctx := context.Background()
+		timeoutDuration := time.Duration(timeout) * time.Second
+
+		// Get the EmailAuth service from the dependency injection container
+		emailAuthService := Container.GetEmailAuthService()
+
+		result, err := emailAuthService.EvaluateSPF(ctx, ip, domain, sender, helo, timeoutDuration)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error evaluating SPF: %v\n", err)
+			os.Exit(1)
+		}
+
+		// Output the result
+		if outputFormat == "json" {
+			jsonOutput, err := json.MarshalIndent(result, "", "  ")
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error marshaling JSON: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Println(string(jsonOutput))
+		} else {
+			fmt.Println(emailAuthService.GetSPFEvaluationSummary(result))
+		}
+	},
+}
+
+// AuthSPFLookupsCmd analyzes the DNS lookup budget of an SPF record
+var AuthSPFLookupsCmd = &cobra.Command{
+	Use:   "spf-lookups [domain]",
+	Short: "Analyze the SPF lookup budget and suggest a flattened record",
+	Long: `Expand the full include/redirect tree of a domain's SPF record and count the
+DNS-querying terms against the limit of 10 and the void lookups against the limit of 2.
+The mx and ptr sub-limits and the record length (all TXT strings) are checked, and a
+flattened candidate record is generated, split into include: chunks when too long.
+The tree is printed as an indented text view, or as JSON with --output json.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		// Get and validate domain
+		domain := validation.SanitizeDomain(args[0])
+		if err := validation.ValidateDomain(domain); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		timeout, _ := cmd.Flags().GetInt("timeout")
+		outputFormat, _ := cmd.Flags().GetString("output")
+
+		ctx := context.Background()
+		timeoutDuration := time.Duration(timeout) * time.Second
+
+		// Get the EmailAuth service from the dependency injection container
+		emailAuthService := Container.GetEmailAuthService()
+
+		result, err := emailAuthService.AnalyzeSPFLookups(ctx, domain, timeoutDuration)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error analyzing SPF lookups: %v\n", err)
+			os.Exit(1)
+		}
+
+		// Output the result
+		if outputFormat == "json" {
+			jsonOutput, err := json.MarshalIndent(result, "", "  ")
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error marshaling JSON: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Println(string(jsonOutput))
+		} else {
+			fmt.Println(emailAuthService.GetSPFLookupSummary(result))
+		}
+	},
+}
+
+// AuthDKIMVerifyCmd verifies the DKIM signatures of a raw message
+var AuthDKIMVerifyCmd = &cobra.Command{
+	Use:   "dkim-verify [file.eml]",
+	Short: "Verify the DKIM signatures of a raw message",
+	Long: `Verify every DKIM-Signature header of a raw RFC 5322 message. Headers and body are
+canonicalized (simple or relaxed), the selector key is fetched from DNS and the body hash
+and the RSA-SHA256 or Ed25519 signature are checked.
+
+Each signature is reported as pass or fail with the reason, along with weaknesses that do
+not break it: an l= body length limit with unsigned content, expiry (x=) and recommended
+headers such as Subject or Reply-To that the signature does not cover.
+
+Without an argument, or with "-", the message is read from stdin.`,
+	Example: `  mxclone auth dkim-verify message.eml
+  mxclone auth dkim-verify < message.eml`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		timeout, _ := cmd.Flags().GetInt("timeout")
+		outputFormat, _ := cmd.Flags().GetString("output")
+
+		var raw []byte
+		var err error
+		if len(args) == 0 || args[0] == "-" {
+			raw, err = io.ReadAll(os.Stdin)
+		} else {
+			raw, err = os.ReadFile(args[0])
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading message: %v\n", err)
+			os.Exit(1)
+		}
+
+		ctx := context.Background()
+		timeoutDuration := time.Duration(timeout) * time.Second
+
+		// Get the EmailAuth service from the dependency injection container
+		emailAuthService := Container.GetEmailAuthService()
+
+		result, err := emailAuthService.VerifyDKIM(ctx, raw, timeoutDuration)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error verifying DKIM: %v\n", err)
+			os.Exit(1)
+		}
+
+		// Output the result
+		if outputFormat == "json" {
+			jsonOutput, err := json.MarshalIndent(result, "", "  ")
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error marshaling JSON: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Println(string(jsonOutput))
+		} else {
+			fmt.Println(emailAuthService.GetDKIMVerificationSummary(result))
+		}
+	},
+}
+
+// AuthARCVerifyCmd validates the ARC chain of a raw message
+var AuthARCVerifyCmd = &cobra.Command{
+	Use:   "arc-verify [file.eml]",
+	Short: "Validate the ARC chain of a raw message",
+	Long: `Validate the Authenticated Received Chain (RFC 8617) of a raw RFC 5322 message, as
+added by mailing lists and forwarders. The chain must have one ARC-Seal,
+ARC-Message-Signature and ARC-Authentication-Results header per instance from i=1, the
+first seal must record cv=none and the later ones cv=pass.
+
+The latest ARC-Message-Signature and every ARC-Seal are verified against the keys in DNS,
+with the DKIM canonicalization rules. Each ARC set is reported with the results its
+intermediary recorded, for instance that a mailing list saw DKIM and DMARC pass before
+it rewrote the subject, along with the oldest set whose signature still validates.
+
+Without an argument, or with "-", the message is read from stdin.`,
+	Example: `  mxclone auth arc-verify message.eml
+  mxclone auth arc-verify < message.eml`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		timeout, _ := cmd.Flags().GetInt("timeout")
+		outputFormat, _ := cmd.Flags().GetString("output")
+
+		var raw []byte
+		var err error
+		if len(args) == 0 || args[0] == "-" {
+			raw, err = io.ReadAll(os.Stdin)
+		} else {
+			raw, err = os.ReadFile(args[0])
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading message: %v\n", err)
+			os.Exit(1)
+		}
+
+		ctx := context.Background()
+		timeoutDuration := time.Duration(timeout) * time.Second
+
+		// Get the EmailAuth service from the dependency injection container
+		emailAuthService := Container.GetEmailAuthService()
+
+		result, err := emailAuthService.VerifyARC(ctx, raw, timeoutDuration)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error validating ARC: %v\n", err)
+			os.Exit(1)
+		}
+
+		// Output the result
+		if outputFormat == "json" {
+			jsonOutput, err := json.MarshalIndent(result, "", "  ")
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error marshaling JSON: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Println(string(jsonOutput))
+		} else {
+			fmt.Println(emailAuthService.GetARCValidationSummary(result))
+		}
+	},
+}
+
+// AuthDKIMDiscoverCmd discovers the DKIM selectors of a domain
+var AuthDKIMDiscoverCmd = &cobra.Command{
+	Use:   "dkim-discover [domain]",
+	Short: "Discover the DKIM selectors of a domain",
+	Long: `Probe a domain for published DKIM keys. The selectors given with --selector are tried
+first, then those of the sending services recognized in the domain's MX and SPF records
+(Google Workspace, Microsoft 365, Amazon SES, Mailchimp, SendGrid...), a bundled dictionary
+of common selectors and dated selectors such as 202405 or s2024.
+
+A random selector is queried first: when the domain answers every selector with the same
+wildcard record, that record is not reported as hits. Each selector found is attributed to
+a provider when its CNAME target, the detected services or its name identify one, and its
+key type, size and strength are reported.`,
+	Example: `  mxclone auth dkim-discover example.com
+  mxclone auth dkim-discover example.com --selector marketing --selector news`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		// Get and validate domain
+		domain := validation.SanitizeDomain(args[0])
+		if err := validation.ValidateDomain(domain); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		timeout, _ := cmd.Flags().GetInt("timeout")
+		outputFormat, _ := cmd.Flags().GetString("output")
+		selectors, _ := cmd.Flags().GetStringSlice("selector")
+		for i, selector := range selectors {
+			selectors[i] = validation.SanitizeSelector(selector)
+			if err := validation.ValidateSelector(selectors[i]); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+
+		ctx := context.Background()
+		timeoutDuration := time.Duration(timeout) * time.Second
+
+		// Get the EmailAuth service from the dependency injection container
+		emailAuthService := Container.GetEmailAuthService()
+
+		result, err := emailAuthService.DiscoverDKIMSelectors(ctx, domain, selectors, timeoutDuration)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error discovering DKIM selectors: %v\n", err)
+			os.Exit(1)
+		}
+
+		// Output the result
+		if outputFormat == "json" {
+			jsonOutput, err := json.MarshalIndent(result, "", "  ")
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error marshaling JSON: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Println(string(jsonOutput))
+		} else {
+			fmt.Println(emailAuthService.GetDKIMDiscoverySummary(result))
+		}
+	},
+}
+
+// AuthBIMICmd checks the BIMI setup of a domain
+var AuthBIMICmd = &cobra.Command{
+	Use:   "bimi [domain]",
+	Short: "Check the BIMI record, logo and mark certificate of a domain",
+	Long: `Check the BIMI (Brand Indicators for Message Identification) setup of a domain.
+The assertion record is looked up at <selector>._bimi.<domain>, or at the organizational
+domain when the domain has none, and its l= and a= tags are parsed.
+
+The DMARC policy that applies to the domain must be quarantine or reject at pct=100.
+The SVG logo of l= is fetched and validated against the SVG Tiny Portable/Secure
+profile, and the VMC or CMC of a= is fetched: its chain, validity dates, extended key
+usage and names are verified, and the logo it embeds must match its hash and the l= logo.`,
+	Example: `  mxclone auth bimi example.com
+  mxclone auth bimi example.com --selector brand`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		// Get and validate domain
+		domain := validation.SanitizeDomain(args[0])
+		if err := validation.ValidateDomain(domain); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		timeout, _ := cmd.Flags().GetInt("timeout")
+		outputFormat, _ := cmd.Flags().GetString("output")
+		selector, _ := cmd.Flags().GetString("selector")
+		selector = validation.SanitizeSelector(selector)
+		if err := validation.ValidateSelector(selector); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		ctx := context.Background()
+		timeoutDuration := time.Duration(timeout) * time.Second
+
+		// Get the EmailAuth service from the dependency injection container
+		emailAuthService := Container.GetEmailAuthService()
+
+		result, err := emailAuthService.CheckBIMI(ctx, domain, selector, timeoutDuration)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error checking BIMI: %v\n", err)
+			os.Exit(1)
+		}
+
+		// Output the result
+		if outputFormat == "json" {
+			jsonOutput, err := json.MarshalIndent(result, "", "  ")
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error marshaling JSON: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Println(string(jsonOutput))
+		} else {
+			fmt.Println(emailAuthService.GetBIMISummary(result))
+		}
+	},
+}
+
+// AuthPSLUpdateCmd downloads the latest Public Suffix List
+var AuthPSLUpdateCmd = &cobra.Command{
+	Use:   "psl-update",
+	Short: "Update the Public Suffix List used to find organizational domains",
+	Long: `Download the Public Suffix List from publicsuffix.org and save it to the psl_file
+configuration setting (MXCLONE_PSL_FILE), or to --file. The list determines the
+organizational domain of DMARC policy discovery, alignment checks and report destination
+checks; a snapshot is bundled with the binary and used until a list is downloaded.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		file, _ := cmd.Flags().GetString("file")
+		url, _ := cmd.Flags().GetString("url")
+		timeout, _ := cmd.Flags().GetInt("timeout")
+
+		if file == "" {
+			cfg, err := config.LoadConfig("")
+			if err != nil {
+				cfg = config.DefaultConfig()
+			}
+			file = cfg.PSLFile
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+		defer cancel()
+
+		rules, err := emailauth.UpdatePublicSuffixList(ctx, url, file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error updating the public suffix list: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Saved %d public suffix rules to %s\n", rules, file)
+	},
+}
+
 func init() {
 	AuthCmd.Flags().IntP("timeout", "t", 10, "Timeout in seconds for DNS operations")
 	AuthCmd.Flags().StringP("selector", "s", "", "DKIM selector to check")
-	AuthCmd.Flags().BoolP("check-dkim", "d", false, "Check DKIM record (requires selector)")
+	AuthCmd.Flags().BoolP("check-dkim", "d", false, "Check DKIM record (selectors are discovered unless --selector is given)")
+
+	AuthSPFCmd.Flags().String("ip", "", "IP address of the sending host (required)")
+	AuthSPFCmd.Flags().String("sender", "", "MAIL FROM address")
+	AuthSPFCmd.Flags().String("helo", "", "HELO/EHLO name of the sending host")
+	AuthSPFCmd.Flags().IntP("timeout", "t", 10, "Timeout in seconds for the whole evaluation")
+	AuthCmd.AddCommand(AuthSPFCmd)
+
+	AuthSPFLookupsCmd.Flags().IntP("timeout", "t", 30, "Timeout in seconds for the whole analysis")
+	AuthCmd.AddCommand(AuthSPFLookupsCmd)
+
+	AuthDKIMVerifyCmd.Flags().IntP("timeout", "t", 10, "Timeout in seconds for each key lookup")
+	AuthCmd.AddCommand(AuthDKIMVerifyCmd)
+
+	AuthARCVerifyCmd.Flags().IntP("timeout", "t", 10, "Timeout in seconds for each key lookup")
+	AuthCmd.AddCommand(AuthARCVerifyCmd)
+
+	AuthDKIMDiscoverCmd.Flags().StringSlice("selector", nil, "Selector to try before the guessed ones (repeatable)")
+	AuthDKIMDiscoverCmd.Flags().IntP("timeout", "t", 5, "Timeout in seconds for each lookup")
+	AuthCmd.AddCommand(AuthDKIMDiscoverCmd)
+
+	AuthBIMICmd.Flags().StringP("selector", "s", "default", "BIMI selector")
+	AuthBIMICmd.Flags().IntP("timeout", "t", 10, "Timeout in seconds for each lookup and download")
+	AuthCmd.AddCommand(AuthBIMICmd)
+
+	AuthPSLUpdateCmd.Flags().String("file", "", "File to save the list to (default: the psl_file setting)")
+	AuthPSLUpdateCmd.Flags().String("url", emailauth.PublicSuffixListURL, "URL of the list")
+	AuthPSLUpdateCmd.Flags().IntP("timeout", "t", 60, "Timeout in seconds for the download")
+	AuthCmd.AddCommand(AuthPSLUpdateCmd)
 
 	// Add the command to the root command
 	rootCmd.AddCommand(AuthCmd)
@@ -0,0 +1,82 @@
+// Package commands contains the CLI commands for the MXToolbox clone.
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"mxclone/domain/autoconfig"
+	"mxclone/pkg/validation"
+)
+
+// AutoconfigCmd represents the autoconfig command
+var AutoconfigCmd = &cobra.Command{
+	Use:   "autoconfig [domain]",
+	Short: "Check mail client autoconfiguration",
+	Long: `Check how mail clients discover the server settings of a domain.
+This looks up the RFC 6186 / RFC 8314 SRV records (_submission._tcp, _imaps._tcp, _pop3s._tcp, ...),
+fetches the Thunderbird autoconfig document (autoconfig.<domain>/mail/config-v1.1.xml) and queries
+the Microsoft Autodiscover endpoints. Every advertised server is then probed to verify that it
+answers on the advertised port with valid TLS.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		domain := args[0]
+		if err := validation.ValidateDomain(domain); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		// Get command flags
+		email, _ := cmd.Flags().GetString("email")
+		skipProbes, _ := cmd.Flags().GetBool("skip-probes")
+		timeout, _ := cmd.Flags().GetInt("timeout")
+		outputFormat, _ := cmd.Flags().GetString("output")
+
+		if email != "" {
+			if err := validation.ValidateEmail(email); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+
+		fmt.Printf("Checking mail client autoconfiguration for %s...\n", domain)
+
+		// Get the autoconfiguration service from the dependency injection container
+		autoconfigService := Container.GetAutoconfigService()
+
+		result, err := autoconfigService.CheckDomain(context.Background(), &autoconfig.CheckRequest{
+			Domain:  domain,
+			Email:   email,
+			Verify:  !skipProbes,
+			Timeout: time.Duration(timeout) * time.Second,
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error checking autoconfiguration for %s: %v\n", domain, err)
+			os.Exit(1)
+		}
+
+		// Output the result
+		if outputFormat == "json" {
+			jsonOutput, err := json.MarshalIndent(result, "", "  ")
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error marshaling JSON: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Println(string(jsonOutput))
+		} else {
+			// Text output
+			fmt.Println(autoconfigService.GetCheckSummary(result))
+		}
+	},
+}
+
+func init() {
+	AutoconfigCmd.Flags().StringP("email", "e", "", "Address sent to autoconfig and Autodiscover (default: postmaster@domain)")
+	AutoconfigCmd.Flags().Bool("skip-probes", false, "Only discover the settings, do not connect to the advertised servers")
+	AutoconfigCmd.Flags().IntP("timeout", "t", 10, "Timeout in seconds for each lookup, request and connection")
+}
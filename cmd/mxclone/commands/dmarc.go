@@ -0,0 +1,264 @@
+// Package commands contains the CLI commands for the MXToolbox clone.
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"mxclone/domain/emailauth"
+
+	"github.com/spf13/cobra"
+)
+
+// DMARCCmd represents the dmarc command
+var DMARCCmd = &cobra.Command{
+	Use:   "dmarc",
+	Short: "Ingest and analyze DMARC reports",
+	Long: `Ingest DMARC aggregate (rua) reports into a local database and summarize them, and
+analyze DMARC failure (ruf) reports.
+Aggregate reports are stored in the file set by dmarc_report_db (MXCLONE_DMARC_REPORT_DB),
+with an index (.idx) and a lock file (.lock) next to it. Stored reports are never expired;
+remove the file and its index to start over.`,
+}
+
+// dmarcIngestCmd represents the dmarc ingest command
+var dmarcIngestCmd = &cobra.Command{
+	Use:   "ingest <dir|file>...",
+	Short: "Store DMARC aggregate reports",
+	Long: `Parse DMARC aggregate reports and store them in the local database.
+Files can be plain XML, gzip-compressed XML or zip archives, as sent by mailbox providers.
+A file may decompress to at most 64 MB; archives inside an archive are not read.
+Directories are searched recursively for .xml, .gz and .zip files.
+Reports already stored (same reporter, report ID and domain) are skipped.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		outputFormat, _ := cmd.Flags().GetString("output")
+
+		paths, err := collectDMARCReportFiles(args, ".xml", ".gz", ".zip")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if len(paths) == 0 {
+			fmt.Fprintln(os.Stderr, "Error: no .xml, .gz or .zip files found")
+			os.Exit(1)
+		}
+
+		files := make([]emailauth.DMARCReportUpload, 0, len(paths))
+		for _, path := range paths {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", path, err)
+				os.Exit(1)
+			}
+			files = append(files, emailauth.DMARCReportUpload{Name: path, Data: data})
+		}
+
+		// Get the email authentication service from the dependency injection container
+		emailAuthService := Container.GetEmailAuthService()
+
+		result, err := emailAuthService.IngestDMARCReports(context.Background(), files)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error ingesting reports: %v\n", err)
+			os.Exit(1)
+		}
+
+		// Output the result
+		if outputFormat == "json" {
+			jsonOutput, err := json.MarshalIndent(result, "", "  ")
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error marshaling JSON: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Println(string(jsonOutput))
+		} else {
+			// Text output
+			fmt.Println(emailAuthService.GetDMARCIngestSummary(result))
+		}
+		if result.Failed > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+// dmarcSummaryCmd represents the dmarc summary command
+var dmarcSummaryCmd = &cobra.Command{
+	Use:   "summary [domain]",
+	Short: "Summarize stored DMARC aggregate reports",
+	Long: `Summarize the stored DMARC aggregate reports, optionally for one domain and its subdomains.
+Messages are counted per source IP with SPF and DKIM pass and alignment rates and dispositions,
+and grouped by ASN and reverse DNS domain. Sources that never pass DMARC and are not listed
+with --known (or dmarc_known_senders) are reported as unknown senders failing alignment.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		// Get command flags
+		timeout, _ := cmd.Flags().GetInt("timeout")
+		outputFormat, _ := cmd.Flags().GetString("output")
+		days, _ := cmd.Flags().GetInt("days")
+		sinceStr, _ := cmd.Flags().GetString("since")
+		untilStr, _ := cmd.Flags().GetString("until")
+		top, _ := cmd.Flags().GetInt("top")
+		noLookup, _ := cmd.Flags().GetBool("no-lookup")
+		known, _ := cmd.Flags().GetStringSlice("known")
+
+		query := emailauth.DMARCReportQuery{
+			Top:          top,
+			Lookup:       !noLookup,
+			KnownSenders: known,
+		}
+		if len(args) > 0 {
+			query.Domain = args[0]
+		}
+
+		var err error
+		if query.Since, err = emailauth.ParseReportDate(sinceStr, false); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid --since: %v\n", err)
+			os.Exit(1)
+		}
+		if query.Until, err = emailauth.ParseReportDate(untilStr, true); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid --until: %v\n", err)
+			os.Exit(1)
+		}
+		if days > 0 && query.Since.IsZero() {
+			query.Since = time.Now().AddDate(0, 0, -days)
+		}
+
+		// Get the email authentication service from the dependency injection container
+		emailAuthService := Container.GetEmailAuthService()
+
+		result, err := emailAuthService.SummarizeDMARCReports(context.Background(), query, time.Duration(timeout)*time.Second)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error summarizing reports: %v\n", err)
+			os.Exit(1)
+		}
+
+		// Output the result
+		if outputFormat == "json" {
+			jsonOutput, err := json.MarshalIndent(result, "", "  ")
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error marshaling JSON: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Println(string(jsonOutput))
+		} else {
+			// Text output
+			fmt.Println(emailAuthService.GetDMARCReportSummary(result))
+		}
+	},
+}
+
+// dmarcForensicCmd represents the dmarc forensic command
+var dmarcForensicCmd = &cobra.Command{
+	Use:   "forensic <dir|file>...",
+	Short: "Analyze DMARC failure reports",
+	Long: `Parse DMARC failure (ruf) reports and other ARF feedback reports (RFC 5965, RFC 6591) and
+group them by campaign: the From domain and subject of the failing messages, with numbers masked.
+Each campaign lists the failing source IPs, the failed mechanisms, the envelope senders and DKIM
+domains, and the likely cause. Files are complete report messages as received (.eml);
+directories are searched recursively for .eml, .arf and .txt files.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		outputFormat, _ := cmd.Flags().GetString("output")
+
+		paths, err := collectDMARCReportFiles(args, ".eml", ".arf", ".txt")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if len(paths) == 0 {
+			fmt.Fprintln(os.Stderr, "Error: no .eml, .arf or .txt files found")
+			os.Exit(1)
+		}
+
+		files := make([]emailauth.DMARCReportUpload, 0, len(paths))
+		for _, path := range paths {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", path, err)
+				os.Exit(1)
+			}
+			files = append(files, emailauth.DMARCReportUpload{Name: path, Data: data})
+		}
+
+		// Get the email authentication service from the dependency injection container
+		emailAuthService := Container.GetEmailAuthService()
+
+		result, err := emailAuthService.AnalyzeForensicReports(context.Background(), files)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error analyzing reports: %v\n", err)
+			os.Exit(1)
+		}
+
+		// Output the result
+		if outputFormat == "json" {
+			jsonOutput, err := json.MarshalIndent(result, "", "  ")
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error marshaling JSON: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Println(string(jsonOutput))
+		} else {
+			// Text output
+			fmt.Println(emailAuthService.GetForensicReportSummary(result))
+		}
+		if result.Failed > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+// collectDMARCReportFiles expands directories to the files with one of the extensions they
+// contain; files named explicitly are always included
+func collectDMARCReportFiles(args []string, extensions ...string) ([]string, error) {
+	var paths []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			paths = append(paths, arg)
+			continue
+		}
+		err = filepath.WalkDir(arg, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+			ext := strings.ToLower(filepath.Ext(path))
+			for _, extension := range extensions {
+				if ext == extension {
+					paths = append(paths, path)
+					break
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return paths, nil
+}
+
+func init() {
+	DMARCCmd.AddCommand(dmarcIngestCmd)
+	DMARCCmd.AddCommand(dmarcSummaryCmd)
+	DMARCCmd.AddCommand(dmarcForensicCmd)
+
+	dmarcSummaryCmd.Flags().IntP("timeout", "t", 5, "Timeout in seconds for each source lookup")
+	dmarcSummaryCmd.Flags().Int("days", 0, "Only reports of the last N days")
+	dmarcSummaryCmd.Flags().String("since", "", "Only reports ending on or after this date (YYYY-MM-DD)")
+	dmarcSummaryCmd.Flags().String("until", "", "Only reports starting on or before this date (YYYY-MM-DD)")
+	dmarcSummaryCmd.Flags().Int("top", 20, "Number of sources listed, by volume (0 for all)")
+	dmarcSummaryCmd.Flags().Bool("no-lookup", false, "Skip the reverse DNS and ASN lookups of the sources")
+	dmarcSummaryCmd.Flags().StringSlice("known", nil, "Known senders: IP addresses, CIDR ranges, ASNs (AS64500) or reverse DNS domains")
+}
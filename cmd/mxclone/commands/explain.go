@@ -0,0 +1,61 @@
+// Package commands contains the CLI commands for the MXToolbox clone.
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// ExplainCmd represents the explain command
+var ExplainCmd = &cobra.Command{
+	Use:   "explain [smtp reply]",
+	Short: "Explain an SMTP reply or bounce",
+	Long: `Explain an SMTP reply or bounce message.
+The reply code and the RFC 3463 enhanced status code are decoded, the reply is classified as
+transient or permanent and as a policy, reputation, authentication, mailbox, content, system
+or protocol problem, and rejection texts of common providers and blocklists (Spamhaus,
+Microsoft, Gmail, Yahoo, ...) are recognized to suggest a remediation.
+
+Without an argument, or with "-", the reply or a complete bounce message is read from stdin.`,
+	Example: `  mxclone explain "550 5.7.26 This mail is unauthenticated, which poses a security risk to the sender and Gmail users"
+  mxclone explain < bounce.eml`,
+	Run: func(cmd *cobra.Command, args []string) {
+		outputFormat, _ := cmd.Flags().GetString("output")
+
+		reply := strings.Join(args, " ")
+		if len(args) == 0 || reply == "-" {
+			data, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error reading stdin: %v\n", err)
+				os.Exit(1)
+			}
+			reply = string(data)
+		}
+		if strings.TrimSpace(reply) == "" {
+			fmt.Fprintln(os.Stderr, "Error: an SMTP reply is required")
+			os.Exit(1)
+		}
+
+		// Get the SMTP service from the dependency injection container
+		smtpService := Container.GetSMTPService()
+		explanation := smtpService.ExplainReply(reply)
+
+		// Output the result
+		if outputFormat == "json" {
+			jsonOutput, err := json.MarshalIndent(explanation, "", "  ")
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error marshaling JSON: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Println(string(jsonOutput))
+		} else {
+			// Text output
+			fmt.Println(smtpService.GetReplyExplanationSummary(explanation))
+		}
+	},
+}
@@ -0,0 +1,64 @@
+// Package commands contains the CLI commands for the MXToolbox clone.
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// HeadersCmd represents the headers command
+var HeadersCmd = &cobra.Command{
+	Use:   "headers [file]",
+	Short: "Analyze the headers of a message",
+	Long: `Analyze the header section of a message, or a complete message.
+Every Received hop is listed in transit order with its hosts, client IP, protocol and queue ID,
+the delay since the previous hop, clock skew between hosts and whether TLS was used.
+Authentication-Results (RFC 8601) are parsed with their properties, along with the ARC sets,
+the Return-Path and its alignment with the From domain, and the List-* headers.
+
+Without an argument, or with "-", the headers are read from stdin.`,
+	Example: `  mxclone headers message.eml
+  pbpaste | mxclone headers`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		outputFormat, _ := cmd.Flags().GetString("output")
+
+		var raw []byte
+		var err error
+		if len(args) == 0 || args[0] == "-" {
+			raw, err = io.ReadAll(os.Stdin)
+		} else {
+			raw, err = os.ReadFile(args[0])
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading headers: %v\n", err)
+			os.Exit(1)
+		}
+
+		// Get the EmailAuth service from the dependency injection container
+		emailAuthService := Container.GetEmailAuthService()
+
+		result, err := emailAuthService.AnalyzeHeaders(context.Background(), raw)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error analyzing headers: %v\n", err)
+			os.Exit(1)
+		}
+
+		// Output the result
+		if outputFormat == "json" {
+			jsonOutput, err := json.MarshalIndent(result, "", "  ")
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error marshaling JSON: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Println(string(jsonOutput))
+		} else {
+			fmt.Println(emailAuthService.GetHeaderAnalysisSummary(result))
+		}
+	},
+}
@@ -23,13 +23,14 @@ var HealthCmd = &cobra.Command{
 	Use:   "health [domain]",
 	Short: "Perform comprehensive domain health check",
 	Long: `Perform a comprehensive health check for a domain.
-This combines DNS, blacklist, SMTP, and email authentication checks
+This combines DNS, MX, blacklist, SMTP, and email authentication checks
 to provide an overall assessment of the domain's health.`,
 	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		domain := args[0]
 		timeout, _ := cmd.Flags().GetInt("timeout")
 		checkDNS, _ := cmd.Flags().GetBool("check-dns")
+		checkMX, _ := cmd.Flags().GetBool("check-mx")
 		checkBlacklist, _ := cmd.Flags().GetBool("check-blacklist")
 		checkSMTP, _ := cmd.Flags().GetBool("check-smtp")
 		checkAuth, _ := cmd.Flags().GetBool("check-auth")
@@ -51,7 +52,7 @@ to provide an overall assessment of the domain's health.`,
 			err  error
 		}
 
-		resultsCh := make(chan result, 4)
+		resultsCh := make(chan result, 5)
 		checks := 0
 
 		if checkDNS {
@@ -62,6 +63,14 @@ to provide an overall assessment of the domain's health.`,
 				resultsCh <- result{name: "dns", val: dnsResult, err: err}
 			}()
 		}
+		if checkMX {
+			checks++
+			go func() {
+				fmt.Println("Performing MX checks...")
+				mxResult, err := performMXCheck(ctx, domain, timeoutDuration)
+				resultsCh <- result{name: "mx", val: mxResult, err: err}
+			}()
+		}
 		if checkBlacklist {
 			checks++
 			go func() {
@@ -96,6 +105,12 @@ to provide an overall assessment of the domain's health.`,
 				} else if dnsRes, ok := res.val.(*types.DNSResult); ok {
 					report.DNS = dnsRes
 				}
+			case "mx":
+				if res.err != nil {
+					fmt.Fprintf(os.Stderr, "Error performing MX check: %v\n", res.err)
+				} else if mxRes, ok := res.val.(*types.MXAnalysis); ok {
+					report.MX = mxRes
+				}
 			case "blacklist":
 				if res.err != nil {
 					fmt.Fprintf(os.Stderr, "Error performing blacklist check: %v\n", res.err)
@@ -138,6 +153,14 @@ func performDNSCheck(ctx context.Context, domain string, timeout time.Duration)
 	return dns.LookupAll(ctx, domain)
 }
 
+// performMXCheck analyzes the MX records of a domain, including whether each exchanger accepts connections.
+func performMXCheck(ctx context.Context, domain string, timeout time.Duration) (*types.MXAnalysis, error) {
+	return dns.AnalyzeMX(ctx, nil, domain, dns.MXAnalysisOptions{
+		CheckReachability: true,
+		Timeout:           timeout,
+	}), nil
+}
+
 // performBlacklistCheck performs blacklist checks for a domain.
 func performBlacklistCheck(ctx context.Context, domain string, timeout time.Duration) (*types.BlacklistResult, error) {
 	// First, get the IP addresses for the domain
@@ -167,11 +190,20 @@ func performSMTPCheck(ctx context.Context, domain string, timeout time.Duration)
 		return nil, fmt.Errorf("failed to get MX records: %v", err)
 	}
 
-	// Extract the hostname from the first MX record
-	mxRecord := mxResult.Lookups["MX"][0]
-	hostname := mxRecord
-	if idx := strings.Index(mxRecord, " (priority:"); idx > 0 {
-		hostname = mxRecord[:idx]
+	// Extract the hostname from the first MX record, skipping a null MX (RFC 7505)
+	var hostname string
+	for _, mxRecord := range mxResult.Lookups["MX"] {
+		host := mxRecord
+		if idx := strings.Index(mxRecord, " (priority:"); idx > 0 {
+			host = mxRecord[:idx]
+		}
+		if host != "." && host != "" {
+			hostname = host
+			break
+		}
+	}
+	if hostname == "" {
+		return nil, fmt.Errorf("domain publishes a null MX and accepts no mail")
 	}
 
 	// Perform SMTP check on the MX server
@@ -195,6 +227,16 @@ func calculateOverallStatus(report *types.DomainHealthReport) string {
 		issues = append(issues, "DNS issues found")
 	}
 
+	// Check MX results; info findings such as a deliberate null MX do not count
+	if report.MX != nil {
+		for _, finding := range report.MX.Findings {
+			if finding.Severity == types.SeverityCritical || finding.Severity == types.SeverityWarning {
+				issues = append(issues, "MX configuration issues found")
+				break
+			}
+		}
+	}
+
 	// Check blacklist results
 	if report.Blacklist != nil && len(report.Blacklist.ListedOn) > 0 {
 		issues = append(issues, "Domain is listed on blacklists")
@@ -213,6 +255,10 @@ func calculateOverallStatus(report *types.DomainHealthReport) string {
 			if report.SMTP.IsOpenRelay != nil && *report.SMTP.IsOpenRelay {
 				issues = append(issues, "SMTP server is an open relay")
 			}
+			// Check for STARTTLS weaknesses
+			if len(report.SMTP.SecurityFindings) > 0 {
+				issues = append(issues, "SMTP security issues found")
+			}
 		}
 	}
 
@@ -262,6 +308,33 @@ func formatHealthReport(report *types.DomainHealthReport) string {
 		output += "\n"
 	}
 
+	// MX results
+	if report.MX != nil {
+		output += "MX Results:\n"
+		if report.MX.NullMX {
+			output += "  Null MX: domain accepts no mail\n"
+		} else {
+			output += fmt.Sprintf("  Accepts mail: %t\n", report.MX.AcceptsMail)
+		}
+		if report.MX.ImplicitMX {
+			output += "  Implicit MX (no MX records, A/AAAA used)\n"
+		}
+		for _, host := range report.MX.Hosts {
+			line := fmt.Sprintf("  %d %s", host.Preference, host.Host)
+			if len(host.Addresses) > 0 {
+				line += fmt.Sprintf(" [%s]", strings.Join(host.Addresses, ", "))
+			}
+			if host.Reachable != nil {
+				line += fmt.Sprintf(" reachable: %t", *host.Reachable)
+			}
+			output += line + "\n"
+		}
+		for _, finding := range report.MX.Findings {
+			output += fmt.Sprintf("  [%s] %s\n", finding.Severity, finding.Message)
+		}
+		output += "\n"
+	}
+
 	// Blacklist results
 	if report.Blacklist != nil {
 		output += "Blacklist Results:\n"
@@ -287,10 +360,20 @@ func formatHealthReport(report *types.DomainHealthReport) string {
 	if report.SMTP != nil {
 		output += "SMTP Results:\n"
 		output += fmt.Sprintf("  Connection: %t\n", report.SMTP.ConnectSuccess)
+		for _, port := range report.SMTP.Ports {
+			switch {
+			case !port.ConnectSuccess:
+				output += fmt.Sprintf("  Port %d: failed (%s)\n", port.Port, port.ConnectError)
+			case port.SupportsSTARTTLS != nil:
+				output += fmt.Sprintf("  Port %d: connected, STARTTLS: %t\n", port.Port, *port.SupportsSTARTTLS)
+			default:
+				output += fmt.Sprintf("  Port %d: connected\n", port.Port)
+			}
+		}
 		if !report.SMTP.ConnectSuccess {
 			output += fmt.Sprintf("  Connection error: %s\n", report.SMTP.ConnectError)
 		} else {
-			output += fmt.Sprintf("  Response time: %s\n", report.SMTP.ResponseTime)
+			output += fmt.Sprintf("  Response time (port %d): %s\n", report.SMTP.Port, report.SMTP.ResponseTime)
 			if report.SMTP.SupportsSTARTTLS != nil {
 				output += fmt.Sprintf("  Supports STARTTLS: %t\n", *report.SMTP.SupportsSTARTTLS)
 				if report.SMTP.STARTTLSError != "" {
@@ -303,6 +386,15 @@ func formatHealthReport(report *types.DomainHealthReport) string {
 					output += fmt.Sprintf("  Relay check error: %s\n", report.SMTP.RelayCheckError)
 				}
 			}
+			if report.SMTP.STARTTLSInjection != nil {
+				output += fmt.Sprintf("  STARTTLS command injection: %t\n", *report.SMTP.STARTTLSInjection)
+			}
+			if len(report.SMTP.SecurityFindings) > 0 {
+				output += "  Security findings:\n"
+				for _, finding := range report.SMTP.SecurityFindings {
+					output += fmt.Sprintf("    ! %s\n", finding)
+				}
+			}
 		}
 		output += "\n"
 	}
@@ -316,7 +408,10 @@ func formatHealthReport(report *types.DomainHealthReport) string {
 		if report.Auth.SPFRecord != "" {
 			output += fmt.Sprintf("    Record: %s\n", report.Auth.SPFRecord)
 			if report.Auth.SPFResult != "" {
-				output += fmt.Sprintf("    Result: %s\n", report.Auth.SPFResult)
+				output += fmt.Sprintf("    Syntax: %s\n", report.Auth.SPFResult)
+			}
+			if report.Auth.SPFError != "" {
+				output += fmt.Sprintf("    Error: %s\n", report.Auth.SPFError)
 			}
 		} else {
 			output += fmt.Sprintf("    Error: %s\n", report.Auth.SPFError)
@@ -353,6 +448,7 @@ func formatHealthReport(report *types.DomainHealthReport) string {
 func init() {
 	HealthCmd.Flags().IntP("timeout", "t", 30, "Timeout in seconds for each check")
 	HealthCmd.Flags().BoolP("check-dns", "d", true, "Perform DNS checks")
+	HealthCmd.Flags().BoolP("check-mx", "m", true, "Perform MX record checks")
 	HealthCmd.Flags().BoolP("check-blacklist", "b", true, "Perform blacklist checks")
 	HealthCmd.Flags().BoolP("check-smtp", "s", true, "Perform SMTP checks")
 	HealthCmd.Flags().BoolP("check-auth", "a", true, "Perform email authentication checks")
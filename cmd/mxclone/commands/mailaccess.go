@@ -0,0 +1,131 @@
+// Package commands contains the CLI commands for the MXToolbox clone.
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"mxclone/domain/mailaccess"
+	"mxclone/pkg/validation"
+)
+
+// IMAPCmd represents the imap command
+var IMAPCmd = &cobra.Command{
+	Use:   "imap [host]",
+	Short: "Perform IMAP diagnostics",
+	Long: `Perform IMAP diagnostics on a mail server.
+This checks the greeting and CAPABILITY, STARTTLS, the TLS certificate, the login methods
+offered before and after TLS (including LOGINDISABLED) on ports 143 and 993.
+With --username and --password, a login is tested on one port; credentials are only sent
+over TLS.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		runMailAccessCheck(cmd, mailaccess.ProtocolIMAP, args[0])
+	},
+}
+
+// POP3Cmd represents the pop3 command
+var POP3Cmd = &cobra.Command{
+	Use:   "pop3 [host]",
+	Short: "Perform POP3 diagnostics",
+	Long: `Perform POP3 diagnostics on a mail server.
+This checks the greeting and CAPA, STLS, the TLS certificate and the login methods
+offered before and after TLS on ports 110 and 995.
+With --username and --password, a login is tested on one port; credentials are only sent
+over TLS.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		runMailAccessCheck(cmd, mailaccess.ProtocolPOP3, args[0])
+	},
+}
+
+// runMailAccessCheck checks one port, or both default ports of the protocol when none is given.
+func runMailAccessCheck(cmd *cobra.Command, protocol, host string) {
+	if err := validation.ValidateHost(host); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Get command flags
+	port, _ := cmd.Flags().GetInt("port")
+	timeout, _ := cmd.Flags().GetInt("timeout")
+	username, _ := cmd.Flags().GetString("username")
+	password, _ := cmd.Flags().GetString("password")
+	outputFormat, _ := cmd.Flags().GetString("output")
+	showTranscript, _ := cmd.Flags().GetBool("transcript")
+
+	ports := mailaccess.DefaultPorts(protocol)
+	if (username != "" || password != "") && port == 0 {
+		// The login test runs on the implicit TLS port unless another port is given
+		port = ports[1]
+	}
+
+	fmt.Printf("Performing %s diagnostics for %s...\n", mailaccess.ProtocolName(protocol), host)
+
+	ctx := context.Background()
+	timeoutDuration := time.Duration(timeout) * time.Second
+
+	// Get the mail access service from the dependency injection container
+	mailAccessService := Container.GetMailAccessService()
+
+	var result interface{}
+	var summary string
+	if port == 0 {
+		hostResult, err := mailAccessService.CheckHost(ctx, protocol, host, timeoutDuration)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error checking %s for %s: %v\n", mailaccess.ProtocolName(protocol), host, err)
+			os.Exit(1)
+		}
+		if !showTranscript {
+			for _, check := range hostResult.Results {
+				check.Transcript = nil
+			}
+		}
+		result, summary = hostResult, mailAccessService.GetHostCheckSummary(hostResult)
+	} else {
+		check, err := mailAccessService.CheckServer(ctx, &mailaccess.CheckRequest{
+			Protocol: protocol,
+			Host:     host,
+			Port:     port,
+			Username: username,
+			Password: password,
+			Timeout:  timeoutDuration,
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error checking %s for %s: %v\n", mailaccess.ProtocolName(protocol), host, err)
+			os.Exit(1)
+		}
+		if !showTranscript {
+			check.Transcript = nil
+		}
+		result, summary = check, mailAccessService.GetCheckSummary(check)
+	}
+
+	// Output the result
+	if outputFormat == "json" {
+		jsonOutput, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error marshaling JSON: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(string(jsonOutput))
+	} else {
+		// Text output
+		fmt.Println(summary)
+	}
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{IMAPCmd, POP3Cmd} {
+		cmd.Flags().IntP("port", "p", 0, "Port to check (default: both the STARTTLS and the implicit TLS port)")
+		cmd.Flags().IntP("timeout", "t", 10, "Timeout in seconds for each connection and command")
+		cmd.Flags().StringP("username", "u", "", "Username for the login test (optional)")
+		cmd.Flags().String("password", "", "Password for the login test (optional)")
+		cmd.Flags().Bool("transcript", false, "Include the full session transcript")
+	}
+}
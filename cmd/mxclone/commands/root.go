@@ -49,7 +49,14 @@ func init() {
 	rootCmd.AddCommand(DnsCmd)
 	rootCmd.AddCommand(BlacklistCmd)
 	rootCmd.AddCommand(AuthCmd)
+	rootCmd.AddCommand(DMARCCmd)
+	rootCmd.AddCommand(HeadersCmd)
 	rootCmd.AddCommand(SMTPCmd)
+	rootCmd.AddCommand(IMAPCmd)
+	rootCmd.AddCommand(POP3Cmd)
+	rootCmd.AddCommand(AutoconfigCmd)
 	rootCmd.AddCommand(HealthCmd)
 	rootCmd.AddCommand(NetworkCmd)
+	rootCmd.AddCommand(VerifyCmd)
+	rootCmd.AddCommand(ExplainCmd)
 }
@@ -6,10 +6,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/spf13/cobra"
 
+	"mxclone/domain/smtp"
 	"mxclone/pkg/validation"
 )
 
@@ -32,6 +36,7 @@ This checks connectivity, STARTTLS support, and other SMTP capabilities.`,
 		// Get command flags
 		timeout, _ := cmd.Flags().GetInt("timeout")
 		outputFormat, _ := cmd.Flags().GetString("output")
+		showTranscript, _ := cmd.Flags().GetBool("transcript")
 
 		fmt.Printf("Performing SMTP diagnostics for %s...\n", domain)
 
@@ -47,6 +52,14 @@ This checks connectivity, STARTTLS support, and other SMTP capabilities.`,
 			fmt.Fprintf(os.Stderr, "Error checking SMTP for %s: %v\n", domain, err)
 			os.Exit(1)
 		}
+		if !showTranscript {
+			for _, connResult := range result.ConnectionResults {
+				connResult.Transcript = nil
+				if connResult.STARTTLSSecurity != nil {
+					connResult.STARTTLSSecurity.Transcript = nil
+				}
+			}
+		}
 
 		// Output the result
 		if outputFormat == "json" {
@@ -64,7 +77,473 @@ This checks connectivity, STARTTLS support, and other SMTP capabilities.`,
 	},
 }
 
+// SMTPMatrixCmd represents the smtp matrix command
+var SMTPMatrixCmd = &cobra.Command{
+	Use:   "matrix [domain]",
+	Short: "Test every MX address on every SMTP port",
+	Long: `Test each MX host of a domain (sorted by preference) on each of its IPv4 and IPv6
+addresses and on each SMTP port (default: the configured smtp_ports, 25/465/587).
+Port 465 uses implicit TLS; other ports are upgraded with STARTTLS when offered.
+The result is a grid of reachability, latency and TLS per address and port, which
+shows a single broken backend hidden behind an MX name.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		// Get and validate domain
+		domain := args[0]
+		if err := validation.ValidateDomain(domain); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		// Get command flags
+		ports, _ := cmd.Flags().GetIntSlice("ports")
+		timeout, _ := cmd.Flags().GetInt("timeout")
+		outputFormat, _ := cmd.Flags().GetString("output")
+
+		fmt.Printf("Testing every MX address of %s...\n", domain)
+
+		ctx := context.Background()
+
+		// Get the SMTP service from the dependency injection container
+		smtpService := Container.GetSMTPService()
+
+		// Perform the matrix check; no ports means the configured ones
+		result, err := smtpService.CheckSMTPMatrix(ctx, domain, ports, time.Duration(timeout)*time.Second)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error checking SMTP matrix for %s: %v\n", domain, err)
+			os.Exit(1)
+		}
+
+		// Output the result
+		if outputFormat == "json" {
+			jsonOutput, err := json.MarshalIndent(result, "", "  ")
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error marshaling JSON: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Println(string(jsonOutput))
+		} else {
+			// Text output
+			fmt.Println(smtpService.GetSMTPMatrixSummary(result))
+		}
+	},
+}
+
+// SMTPSubmitCmd represents the smtp submit command
+var SMTPSubmitCmd = &cobra.Command{
+	Use:   "submit [host]",
+	Short: "Test authenticated submission on an SMTP server",
+	Long: `Test mail submission on port 587 (STARTTLS) or 465 (implicit TLS).
+The test secures the session, authenticates with PLAIN, LOGIN, CRAM-MD5 or XOAUTH2
+(the password is the access token for XOAUTH2), and with --send submits a generated
+test message carrying a unique Message-ID and an X-MXClone-Test-ID tracking header.
+The outcome of each stage is reported. Credentials are never sent over an unencrypted
+session unless --allow-plaintext is given.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		// Get and validate host
+		host := args[0]
+		if err := validation.ValidateHost(host); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		// Get command flags
+		port, _ := cmd.Flags().GetInt("port")
+		timeout, _ := cmd.Flags().GetInt("timeout")
+		username, _ := cmd.Flags().GetString("username")
+		password, _ := cmd.Flags().GetString("password")
+		mechanism, _ := cmd.Flags().GetString("mechanism")
+		allowPlaintext, _ := cmd.Flags().GetBool("allow-plaintext")
+		from, _ := cmd.Flags().GetString("from")
+		to, _ := cmd.Flags().GetString("to")
+		send, _ := cmd.Flags().GetBool("send")
+		outputFormat, _ := cmd.Flags().GetString("output")
+		showTranscript, _ := cmd.Flags().GetBool("transcript")
+
+		if username == "" || password == "" {
+			fmt.Fprintf(os.Stderr, "Error: --username and --password are required\n")
+			os.Exit(1)
+		}
+		if send && to == "" {
+			fmt.Fprintf(os.Stderr, "Error: --to is required with --send\n")
+			os.Exit(1)
+		}
+		for _, address := range []string{from, to} {
+			if address == "" {
+				continue
+			}
+			if err := validation.ValidateEmail(address); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+
+		fmt.Printf("Testing authenticated submission on %s:%d...\n", host, port)
+
+		ctx := context.Background()
+
+		// Get the SMTP service from the dependency injection container
+		smtpService := Container.GetSMTPService()
+
+		// Perform the submission test
+		result, err := smtpService.TestSubmission(ctx, &smtp.SubmissionTestRequest{
+			Host:               host,
+			Port:               port,
+			Username:           username,
+			Password:           password,
+			Mechanism:          mechanism,
+			AllowPlaintextAuth: allowPlaintext,
+			FromAddress:        from,
+			ToAddress:          to,
+			SendMessage:        send,
+			Timeout:            time.Duration(timeout) * time.Second,
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error testing submission on %s: %v\n", host, err)
+			os.Exit(1)
+		}
+		if !showTranscript {
+			result.Transcript = nil
+		}
+
+		// Output the result
+		if outputFormat == "json" {
+			jsonOutput, err := json.MarshalIndent(result, "", "  ")
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error marshaling JSON: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Println(string(jsonOutput))
+		} else {
+			// Text output
+			fmt.Println(smtpService.GetSubmissionTestSummary(result))
+		}
+	},
+}
+
+// SMTPRelayCmd represents the smtp relay command
+var SMTPRelayCmd = &cobra.Command{
+	Use:   "relay [host]",
+	Short: "Test an SMTP server for open relay",
+	Long: `Run a battery of open relay tests against an SMTP server.
+Each test issues MAIL FROM and RCPT TO with a different address trick (percent hack,
+bang paths, quoted local parts, source routes, null sender, local sender) followed
+by RSET. DATA is never sent, so no mail is delivered.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		// Get and validate host
+		host := args[0]
+		if err := validation.ValidateHost(host); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		// Get command flags
+		port, _ := cmd.Flags().GetInt("port")
+		timeout, _ := cmd.Flags().GetInt("timeout")
+		from, _ := cmd.Flags().GetString("from")
+		to, _ := cmd.Flags().GetString("to")
+		targetDomain, _ := cmd.Flags().GetString("target-domain")
+		username, _ := cmd.Flags().GetString("username")
+		password, _ := cmd.Flags().GetString("password")
+		outputFormat, _ := cmd.Flags().GetString("output")
+		showTranscript, _ := cmd.Flags().GetBool("transcript")
+
+		for _, address := range []string{from, to} {
+			if err := validation.ValidateEmail(address); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		if targetDomain != "" {
+			if err := validation.ValidateDomain(targetDomain); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+
+		fmt.Printf("Running open relay tests against %s:%d...\n", host, port)
+
+		ctx := context.Background()
+
+		// Get the SMTP service from the dependency injection container
+		smtpService := Container.GetSMTPService()
+
+		// Perform the relay test
+		result, err := smtpService.TestOpenRelay(ctx, &smtp.RelayTestRequest{
+			Host:         host,
+			Port:         port,
+			FromAddress:  from,
+			ToAddress:    to,
+			TargetDomain: targetDomain,
+			Username:     username,
+			Password:     password,
+			Timeout:      time.Duration(timeout) * time.Second,
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error testing open relay on %s: %v\n", host, err)
+			os.Exit(1)
+		}
+		if !showTranscript {
+			for _, test := range result.Tests {
+				test.Transcript = nil
+			}
+		}
+
+		// Output the result
+		if outputFormat == "json" {
+			jsonOutput, err := json.MarshalIndent(result, "", "  ")
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error marshaling JSON: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Println(string(jsonOutput))
+		} else {
+			// Text output
+			fmt.Println(smtpService.GetRelayTestSummary(result))
+		}
+	},
+}
+
+// SMTPSmugglingCmd represents the smtp smuggling command
+var SMTPSmugglingCmd = &cobra.Command{
+	Use:   "smuggling [domain]",
+	Short: "Probe a mail exchanger for SMTP smuggling",
+	Long: `Check how the most preferred MX of a domain (or --host) handles non-standard
+end-of-data sequences (<LF>.<LF>, <CR>.<CR>, <LF>.<CR><LF>, ...) and commands terminated
+by a bare LF, the behaviors behind SMTP smuggling (CVE-2023-51766 class).
+Each sequence ends a short message to postmaster@domain, the only recipient used. A server
+that treats a sequence as the end of data delivers that message, so the probe only runs
+with --confirm. Messages the server keeps waiting on are abandoned, not delivered.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		// Get and validate domain
+		domain := args[0]
+		if err := validation.ValidateDomain(domain); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		// Get command flags
+		host, _ := cmd.Flags().GetString("host")
+		port, _ := cmd.Flags().GetInt("port")
+		timeout, _ := cmd.Flags().GetInt("timeout")
+		from, _ := cmd.Flags().GetString("from")
+		confirm, _ := cmd.Flags().GetBool("confirm")
+		outputFormat, _ := cmd.Flags().GetString("output")
+		showTranscript, _ := cmd.Flags().GetBool("transcript")
+
+		if !confirm {
+			fmt.Fprintf(os.Stderr, "Error: the probe may deliver test messages to postmaster@%s; rerun with --confirm\n", domain)
+			os.Exit(1)
+		}
+		if host != "" {
+			if err := validation.ValidateDomain(strings.TrimSuffix(host, ".")); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: --host must be the name of an MX of %s: %v\n", domain, err)
+				os.Exit(1)
+			}
+		}
+		if from != "" {
+			if err := validation.ValidateEmail(from); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+
+		fmt.Printf("Probing %s for SMTP smuggling...\n", domain)
+
+		ctx := context.Background()
+
+		// Get the SMTP service from the dependency injection container
+		smtpService := Container.GetSMTPService()
+
+		// Perform the smuggling probe
+		result, err := smtpService.TestSmuggling(ctx, &smtp.SmugglingTestRequest{
+			Domain:      domain,
+			Host:        host,
+			Port:        port,
+			FromAddress: from,
+			Confirm:     confirm,
+			Timeout:     time.Duration(timeout) * time.Second,
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error probing %s: %v\n", domain, err)
+			os.Exit(1)
+		}
+		if !showTranscript {
+			result.Transcript = nil
+		}
+
+		// Output the result
+		if outputFormat == "json" {
+			jsonOutput, err := json.MarshalIndent(result, "", "  ")
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error marshaling JSON: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Println(string(jsonOutput))
+		} else {
+			// Text output
+			fmt.Println(smtpService.GetSmugglingTestSummary(result))
+		}
+	},
+}
+
+// SMTPListenCmd runs the SMTP sink for inbound authentication testing
+var SMTPListenCmd = &cobra.Command{
+	Use:   "listen",
+	Short: "Receive test messages and report their SPF, DKIM and DMARC verdict",
+	Long: `Start a receive-only SMTP server that accepts mail for generated test addresses.
+Each received message is checked for SPF (connecting IP), DKIM signatures, DMARC
+alignment with the From header and header problems, and its verdict is printed.
+Point the MX of --domain (or send directly to --listen) and mail the printed addresses
+from the platform under test. STARTTLS uses --cert/--key, or a self-signed certificate.
+Press Ctrl+C to stop.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		// Get command flags
+		listen, _ := cmd.Flags().GetString("listen")
+		domain, _ := cmd.Flags().GetString("domain")
+		hostname, _ := cmd.Flags().GetString("hostname")
+		certFile, _ := cmd.Flags().GetString("cert")
+		keyFile, _ := cmd.Flags().GetString("key")
+		count, _ := cmd.Flags().GetInt("count")
+		timeout, _ := cmd.Flags().GetInt("timeout")
+		outputFormat, _ := cmd.Flags().GetString("output")
+
+		if domain != "" {
+			if err := validation.ValidateDomain(domain); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		if (certFile == "") != (keyFile == "") {
+			fmt.Fprintln(os.Stderr, "Error: --cert and --key must be used together")
+			os.Exit(1)
+		}
+		if count < 1 {
+			count = 1
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		// Get the SMTP service from the dependency injection container
+		smtpService := Container.GetSMTPService()
+
+		status, err := smtpService.StartSink(ctx, &smtp.SinkConfig{
+			Address:  listen,
+			Domain:   domain,
+			Hostname: hostname,
+			CertFile: certFile,
+			KeyFile:  keyFile,
+			Timeout:  time.Duration(timeout) * time.Second,
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error starting SMTP sink: %v\n", err)
+			os.Exit(1)
+		}
+		defer smtpService.StopSink(context.Background())
+
+		tests := make([]*smtp.SinkTest, 0, count)
+		for i := 0; i < count; i++ {
+			test, err := smtpService.CreateSinkTest(ctx)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error creating test address: %v\n", err)
+				os.Exit(1)
+			}
+			tests = append(tests, test)
+		}
+
+		if outputFormat != "json" {
+			fmt.Print(smtpService.GetSinkStatusSummary(status))
+			fmt.Println("Send test messages to:")
+			for _, test := range tests {
+				fmt.Printf("  %s\n", test.Address)
+			}
+			fmt.Println("Waiting for messages (Ctrl+C to stop)...")
+		}
+
+		// Poll the tests and print each message once
+		seen := make(map[string]int)
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			for _, test := range tests {
+				result, err := smtpService.GetSinkTest(ctx, test.ID)
+				if err != nil || len(result.Messages) == seen[test.ID] {
+					continue
+				}
+				newMessages := &smtp.SinkTest{ID: result.ID, Address: result.Address, Messages: result.Messages[seen[test.ID]:]}
+				seen[test.ID] = len(result.Messages)
+
+				if outputFormat == "json" {
+					jsonOutput, err := json.MarshalIndent(newMessages, "", "  ")
+					if err != nil {
+						fmt.Fprintf(os.Stderr, "Error marshaling JSON: %v\n", err)
+						continue
+					}
+					fmt.Println(string(jsonOutput))
+				} else {
+					fmt.Println(smtpService.GetSinkTestSummary(newMessages))
+				}
+			}
+		}
+	},
+}
+
 func init() {
 	SMTPCmd.Flags().IntP("port", "p", 25, "SMTP port to check")
 	SMTPCmd.Flags().IntP("timeout", "t", 10, "Timeout in seconds for SMTP operations")
+	SMTPCmd.Flags().Bool("transcript", false, "Include the full SMTP session transcript")
+
+	SMTPRelayCmd.Flags().IntP("port", "p", 25, "SMTP port to test")
+	SMTPRelayCmd.Flags().IntP("timeout", "t", 10, "Timeout in seconds for SMTP operations")
+	SMTPRelayCmd.Flags().String("from", "test@example.com", "External sender address used for the tests")
+	SMTPRelayCmd.Flags().String("to", "test@example.org", "External recipient address used for the tests")
+	SMTPRelayCmd.Flags().String("target-domain", "", "Domain the server receives mail for (default: the host's organizational domain)")
+	SMTPRelayCmd.Flags().StringP("username", "u", "", "Username for SMTP AUTH (optional)")
+	SMTPRelayCmd.Flags().String("password", "", "Password for SMTP AUTH (optional)")
+	SMTPRelayCmd.Flags().Bool("transcript", false, "Include the full SMTP session transcript of each test")
+	SMTPMatrixCmd.Flags().IntSlice("ports", nil, "SMTP ports to test (default: configured smtp_ports)")
+	SMTPMatrixCmd.Flags().IntP("timeout", "t", 10, "Timeout in seconds for each connection")
+
+	SMTPSubmitCmd.Flags().IntP("port", "p", 587, "Submission port (465 uses implicit TLS)")
+	SMTPSubmitCmd.Flags().IntP("timeout", "t", 10, "Timeout in seconds for SMTP operations")
+	SMTPSubmitCmd.Flags().StringP("username", "u", "", "Username for SMTP AUTH")
+	SMTPSubmitCmd.Flags().String("password", "", "Password for SMTP AUTH (access token for XOAUTH2)")
+	SMTPSubmitCmd.Flags().String("mechanism", "", "SASL mechanism: PLAIN, LOGIN, CRAM-MD5 or XOAUTH2 (default: best offered)")
+	SMTPSubmitCmd.Flags().Bool("allow-plaintext", false, "Allow AUTH when the session cannot be encrypted")
+	SMTPSubmitCmd.Flags().String("from", "", "Sender address of the test message (default: username)")
+	SMTPSubmitCmd.Flags().String("to", "", "Recipient address; MAIL FROM and RCPT TO are tested when set")
+	SMTPSubmitCmd.Flags().Bool("send", false, "Send a generated test message to --to")
+	SMTPSubmitCmd.Flags().Bool("transcript", false, "Include the full SMTP session transcript")
+
+	SMTPSmugglingCmd.Flags().String("host", "", "MX of the domain to probe (default: most preferred MX)")
+	SMTPSmugglingCmd.Flags().IntP("port", "p", 25, "SMTP port to probe")
+	SMTPSmugglingCmd.Flags().IntP("timeout", "t", 10, "Timeout in seconds for SMTP operations")
+	SMTPSmugglingCmd.Flags().String("from", "", "Envelope sender (default: null reverse-path)")
+	SMTPSmugglingCmd.Flags().Bool("confirm", false, "Confirm that probe messages may be delivered to the domain's postmaster")
+	SMTPSmugglingCmd.Flags().Bool("transcript", false, "Include the SMTP session transcripts")
+
+	SMTPCmd.AddCommand(SMTPRelayCmd)
+	SMTPCmd.AddCommand(SMTPSubmitCmd)
+	SMTPCmd.AddCommand(SMTPMatrixCmd)
+	SMTPCmd.AddCommand(SMTPSmugglingCmd)
+
+	SMTPListenCmd.Flags().String("listen", ":2525", "Address to listen on (host:port)")
+	SMTPListenCmd.Flags().String("domain", "", "Domain of the test addresses (default: hostname)")
+	SMTPListenCmd.Flags().String("hostname", "", "Name announced in the greeting (default: system hostname)")
+	SMTPListenCmd.Flags().String("cert", "", "PEM certificate for STARTTLS (default: self-signed)")
+	SMTPListenCmd.Flags().String("key", "", "PEM private key for --cert")
+	SMTPListenCmd.Flags().Int("count", 1, "Number of test addresses to generate")
+	SMTPListenCmd.Flags().IntP("timeout", "t", 300, "Timeout in seconds for each SMTP command")
+	SMTPCmd.AddCommand(SMTPListenCmd)
 }
@@ -0,0 +1,127 @@
+// Package commands contains the CLI commands for the MXToolbox clone.
+package commands
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// VerifyCmd represents the verify command
+var VerifyCmd = &cobra.Command{
+	Use:   "verify [address...]",
+	Short: "Verify email addresses",
+	Long: `Verify that email addresses can receive mail without sending any.
+Each address is checked for RFC 5322 syntax (internationalized domains are normalized),
+its domain's MX hosts are resolved (falling back to the domain's A/AAAA records), and the
+mail exchanger is asked with MAIL FROM:<> and RCPT TO whether it accepts the address.
+A random address at the same domain is offered too, to detect catch-all domains.
+Role accounts and disposable-domain addresses are flagged. DATA is never sent.
+
+Several addresses, or --file with one address per line, run a bulk verification.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		// Get command flags
+		timeout, _ := cmd.Flags().GetInt("timeout")
+		file, _ := cmd.Flags().GetString("file")
+		outputFormat, _ := cmd.Flags().GetString("output")
+		showTranscript, _ := cmd.Flags().GetBool("transcript")
+
+		addresses := args
+		if file != "" {
+			fileAddresses, err := readAddressFile(file)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", file, err)
+				os.Exit(1)
+			}
+			addresses = append(addresses, fileAddresses...)
+		}
+		if len(addresses) == 0 {
+			fmt.Fprintln(os.Stderr, "Error: at least one address (or --file) is required")
+			os.Exit(1)
+		}
+
+		ctx := context.Background()
+		timeoutDuration := time.Duration(timeout) * time.Second
+
+		// Get the SMTP service from the dependency injection container
+		smtpService := Container.GetSMTPService()
+
+		var output interface{}
+		var summary string
+		if len(addresses) == 1 {
+			fmt.Printf("Verifying %s...\n", addresses[0])
+			result, err := smtpService.VerifyEmail(ctx, addresses[0], timeoutDuration)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error verifying %s: %v\n", addresses[0], err)
+				os.Exit(1)
+			}
+			if !showTranscript {
+				result.Transcript = nil
+			}
+			output, summary = result, smtpService.GetEmailVerificationSummary(result)
+		} else {
+			fmt.Printf("Verifying %d addresses...\n", len(addresses))
+			result, err := smtpService.VerifyEmails(ctx, addresses, timeoutDuration)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error verifying addresses: %v\n", err)
+				os.Exit(1)
+			}
+			if !showTranscript {
+				for _, r := range result.Results {
+					r.Transcript = nil
+				}
+			}
+			output, summary = result, smtpService.GetBulkEmailVerificationSummary(result)
+		}
+
+		// Output the result
+		if outputFormat == "json" {
+			jsonOutput, err := json.MarshalIndent(output, "", "  ")
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error marshaling JSON: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Println(string(jsonOutput))
+		} else {
+			// Text output
+			fmt.Println(summary)
+		}
+	},
+}
+
+// readAddressFile reads one address per line from a file ("-" for standard input),
+// skipping blank lines and # comments
+func readAddressFile(path string) ([]string, error) {
+	f := os.Stdin
+	if path != "-" {
+		var err error
+		f, err = os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+	}
+
+	var addresses []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		addresses = append(addresses, line)
+	}
+	return addresses, scanner.Err()
+}
+
+func init() {
+	VerifyCmd.Flags().IntP("timeout", "t", 10, "Timeout in seconds for SMTP operations")
+	VerifyCmd.Flags().StringP("file", "f", "", "File with one address per line (- for standard input)")
+	VerifyCmd.Flags().Bool("transcript", false, "Include the full SMTP session transcript")
+}
@@ -0,0 +1,475 @@
+// Package autoconfig contains the core domain logic for mail client autoconfiguration checks
+package autoconfig
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Discovery sources
+const (
+	SourceSRV          = "srv"
+	SourceAutoconfig   = "autoconfig"
+	SourceAutodiscover = "autodiscover"
+)
+
+// Connection security of the advertised servers
+const (
+	SecurityTLS      = "tls"
+	SecuritySTARTTLS = "starttls"
+	SecurityNone     = "none"
+)
+
+// CheckRequest describes an autoconfiguration check of a domain
+type CheckRequest struct {
+	// Mail domain to check
+	Domain string
+	// Address sent to autoconfig and Autodiscover (default: postmaster@domain)
+	Email string
+	// Whether to connect to the advertised servers
+	Verify bool
+	// Timeout for each lookup, request and connection
+	Timeout time.Duration
+}
+
+// ServerSetting represents a server that mail clients are told to use
+type ServerSetting struct {
+	// Where the setting was found (srv, autoconfig, autodiscover)
+	Source string
+	// Protocol (imap, pop3, smtp)
+	Protocol string
+	Host     string
+	Port     int
+	// Connection security (tls, starttls, none)
+	Security string
+	// Username template and authentication methods, when advertised
+	Username       string
+	Authentication []string
+	// SRV priority and weight
+	Priority int
+	Weight   int
+}
+
+// SRVService represents the lookup of one SRV service
+type SRVService struct {
+	// Service label (e.g. _imaps._tcp) and full name
+	Service string
+	Name    string
+	// Whether records exist, and whether they say the service is not provided (target ".")
+	Found       bool
+	NotProvided bool
+	Servers     []ServerSetting
+	// Error message if the lookup failed
+	Error string
+}
+
+// Document represents one autoconfig or Autodiscover URL that was tried
+type Document struct {
+	// Source (autoconfig, autodiscover) and URL
+	Source string
+	URL    string
+	// Whether a valid document was returned
+	Found bool
+	// HTTP status code, and whether the endpoint asked for credentials
+	StatusCode   int
+	RequiresAuth bool
+	// Display name of the provider or user
+	DisplayName string
+	// Autodiscover redirect address or URL
+	Redirect string
+	Servers  []ServerSetting
+	// Error message if the request or the document failed
+	Error string
+}
+
+// Certificate describes the certificate presented by an advertised server
+type Certificate struct {
+	Subject       string
+	Issuer        string
+	DNSNames      []string
+	NotBefore     time.Time
+	NotAfter      time.Time
+	SelfSigned    bool
+	HostnameMatch bool
+	Trusted       bool
+	VerifyError   string
+}
+
+// ServerProbe represents the connection test of an advertised server
+type ServerProbe struct {
+	Protocol string
+	Host     string
+	Port     int
+	Security string
+	// Sources that advertise this server
+	Sources []string
+	// Whether the server answered, and its greeting
+	Connected bool
+	Banner    string
+	// Whether the server offers STARTTLS (STLS for POP3)
+	STARTTLS bool
+	// Negotiated TLS version and certificate, if the session was encrypted
+	TLS         bool
+	TLSVersion  string
+	Certificate *Certificate
+	// Whether the server works as advertised with a valid certificate
+	Valid bool
+	// Error message if any
+	Error string
+}
+
+// CheckResult represents the autoconfiguration check of a domain
+type CheckResult struct {
+	Domain string
+	Email  string
+	// RFC 6186 / RFC 8314 SRV services
+	SRV []SRVService
+	// Thunderbird autoconfig and Microsoft Autodiscover URLs
+	Autoconfig   []Document
+	Autodiscover []Document
+	// Connection tests of the advertised servers
+	Probes []*ServerProbe
+	// Human-readable observations
+	Findings []string
+	// Error message if any
+	Error string
+}
+
+// Service defines the core autoconfiguration business logic operations
+type Service struct{}
+
+// NewService creates a new autoconfiguration service
+func NewService() *Service {
+	return &Service{}
+}
+
+// Servers returns every server advertised by the sources that were found
+func (s *Service) Servers(result *CheckResult) []ServerSetting {
+	var servers []ServerSetting
+	for _, svc := range result.SRV {
+		servers = append(servers, svc.Servers...)
+	}
+	for _, document := range append(append([]Document{}, result.Autoconfig...), result.Autodiscover...) {
+		if document.Found {
+			servers = append(servers, document.Servers...)
+		}
+	}
+	return servers
+}
+
+// ServersToProbe returns each advertised protocol, host, port and security once, in the order
+// they were first advertised
+func (s *Service) ServersToProbe(result *CheckResult) []ServerSetting {
+	seen := make(map[string]bool)
+	var unique []ServerSetting
+	for _, server := range s.Servers(result) {
+		key := serverKey(server)
+		if server.Host == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		unique = append(unique, server)
+	}
+	return unique
+}
+
+// serverKey identifies a server setting regardless of its source
+func serverKey(server ServerSetting) string {
+	return fmt.Sprintf("%s|%s|%d|%s", server.Protocol, strings.ToLower(strings.TrimSuffix(server.Host, ".")), server.Port, server.Security)
+}
+
+// ProcessCheckResult records the error of a check, attributes the probes to their sources and
+// derives the findings
+func (s *Service) ProcessCheckResult(result *CheckResult, err error, now time.Time) *CheckResult {
+	if err != nil && result.Error == "" {
+		result.Error = err.Error()
+	}
+
+	servers := s.Servers(result)
+	for _, probe := range result.Probes {
+		probe.Sources = nil
+		key := serverKey(ServerSetting{Protocol: probe.Protocol, Host: probe.Host, Port: probe.Port, Security: probe.Security})
+		for _, server := range servers {
+			if serverKey(server) == key && !contains(probe.Sources, server.Source) {
+				probe.Sources = append(probe.Sources, server.Source)
+			}
+		}
+		probe.Valid = probeValid(probe, now)
+	}
+
+	result.Findings = nil
+	result.Findings = append(result.Findings, discoveryFindings(result)...)
+	for _, server := range servers {
+		finding := fmt.Sprintf("%s advertises %s on %s:%d without TLS", sourceName(server.Source), strings.ToUpper(server.Protocol), server.Host, server.Port)
+		if server.Security == SecurityNone && !contains(result.Findings, finding) {
+			result.Findings = append(result.Findings, finding)
+		}
+	}
+	result.Findings = append(result.Findings, consistencyFindings(servers)...)
+	for _, probe := range result.Probes {
+		result.Findings = append(result.Findings, probeFindings(probe, now)...)
+	}
+
+	return result
+}
+
+// discoveryFindings reports missing, insecure or broken discovery sources
+func discoveryFindings(result *CheckResult) []string {
+	var findings []string
+
+	srvFound := false
+	for _, svc := range result.SRV {
+		if svc.Error != "" {
+			findings = append(findings, fmt.Sprintf("SRV lookup of %s failed: %s", svc.Name, svc.Error))
+		}
+		srvFound = srvFound || len(svc.Servers) > 0
+	}
+
+	autoconfigFound, autoconfigHTTPS := false, false
+	for _, document := range result.Autoconfig {
+		if document.Found {
+			autoconfigFound = true
+			autoconfigHTTPS = autoconfigHTTPS || strings.HasPrefix(document.URL, "https://")
+		}
+		if document.StatusCode == 200 && document.Error != "" {
+			findings = append(findings, fmt.Sprintf("Invalid autoconfig document at %s: %s", document.URL, document.Error))
+		}
+	}
+	if autoconfigFound && !autoconfigHTTPS {
+		findings = append(findings, "Autoconfig is only served over plain HTTP: the settings can be tampered with in transit")
+	}
+
+	autodiscoverFound := false
+	for _, document := range result.Autodiscover {
+		autodiscoverFound = autodiscoverFound || document.Found || document.RequiresAuth
+		if document.StatusCode == 200 && document.Error != "" {
+			findings = append(findings, fmt.Sprintf("Invalid Autodiscover response at %s: %s", document.URL, document.Error))
+		}
+	}
+
+	if !srvFound && !autoconfigFound && !autodiscoverFound {
+		findings = append([]string{"No client autoconfiguration found: users must enter the server settings manually"}, findings...)
+	}
+	return findings
+}
+
+// consistencyFindings reports protocols for which the sources advertise different servers
+func consistencyFindings(servers []ServerSetting) []string {
+	// protocol -> source -> sorted host:port list
+	advertised := make(map[string]map[string][]string)
+	for _, server := range servers {
+		if advertised[server.Protocol] == nil {
+			advertised[server.Protocol] = make(map[string][]string)
+		}
+		endpoint := fmt.Sprintf("%s:%d", strings.ToLower(server.Host), server.Port)
+		if !contains(advertised[server.Protocol][server.Source], endpoint) {
+			advertised[server.Protocol][server.Source] = append(advertised[server.Protocol][server.Source], endpoint)
+		}
+	}
+
+	var findings []string
+	for _, protocol := range []string{"imap", "pop3", "smtp"} {
+		bySource := advertised[protocol]
+		if len(bySource) < 2 {
+			continue
+		}
+		sources := make([]string, 0, len(bySource))
+		for source := range bySource {
+			sort.Strings(bySource[source])
+			sources = append(sources, source)
+		}
+		sort.Strings(sources)
+
+		consistent := true
+		for _, source := range sources[1:] {
+			if !overlaps(bySource[source], bySource[sources[0]]) {
+				consistent = false
+			}
+		}
+		if consistent {
+			continue
+		}
+		parts := make([]string, 0, len(sources))
+		for _, source := range sources {
+			parts = append(parts, fmt.Sprintf("%s %s", sourceName(source), strings.Join(bySource[source], ", ")))
+		}
+		findings = append(findings, fmt.Sprintf("%s servers differ between sources: %s", strings.ToUpper(protocol), strings.Join(parts, "; ")))
+	}
+	return findings
+}
+
+// probeValid reports whether a server works as advertised with a valid certificate
+func probeValid(probe *ServerProbe, now time.Time) bool {
+	if !probe.Connected || probe.Error != "" {
+		return false
+	}
+	if probe.Security == SecurityNone {
+		return true
+	}
+	cert := probe.Certificate
+	return probe.TLS && cert != nil && cert.Trusted && cert.HostnameMatch && !now.After(cert.NotAfter) && !now.Before(cert.NotBefore)
+}
+
+// probeFindings reports how an advertised server differs from what clients are told
+func probeFindings(probe *ServerProbe, now time.Time) []string {
+	server := fmt.Sprintf("%s %s:%d (%s)", strings.ToUpper(probe.Protocol), probe.Host, probe.Port, strings.Join(probe.Sources, ", "))
+	switch {
+	case !probe.Connected:
+		return []string{fmt.Sprintf("%s does not answer: %s", server, probe.Error)}
+	case probe.Security == SecuritySTARTTLS && !probe.STARTTLS:
+		return []string{fmt.Sprintf("%s is advertised with STARTTLS but the server does not offer it", server)}
+	case probe.Security != SecurityNone && !probe.TLS:
+		return []string{fmt.Sprintf("%s: TLS could not be negotiated: %s", server, probe.Error)}
+	case probe.Error != "":
+		return []string{fmt.Sprintf("%s: %s", server, probe.Error)}
+	}
+
+	var findings []string
+	if cert := probe.Certificate; cert != nil {
+		switch {
+		case now.After(cert.NotAfter):
+			findings = append(findings, fmt.Sprintf("%s: certificate expired on %s", server, cert.NotAfter.Format("2006-01-02")))
+		case now.Before(cert.NotBefore):
+			findings = append(findings, fmt.Sprintf("%s: certificate is not valid before %s", server, cert.NotBefore.Format("2006-01-02")))
+		}
+		if !cert.HostnameMatch {
+			findings = append(findings, fmt.Sprintf("%s: certificate is not valid for %s", server, probe.Host))
+		}
+		if cert.SelfSigned {
+			findings = append(findings, fmt.Sprintf("%s: certificate is self-signed", server))
+		} else if !cert.Trusted && cert.HostnameMatch {
+			findings = append(findings, fmt.Sprintf("%s: certificate chain is not trusted: %s", server, cert.VerifyError))
+		}
+	}
+	return findings
+}
+
+// FormatCheckSummary returns a human-readable summary of an autoconfiguration check
+func (s *Service) FormatCheckSummary(result *CheckResult) string {
+	if result == nil {
+		return "No result available"
+	}
+
+	summary := fmt.Sprintf("Mail client autoconfiguration for %s (%s)\n", result.Domain, result.Email)
+
+	summary += "\nSRV records:\n"
+	for _, svc := range result.SRV {
+		switch {
+		case svc.Error != "":
+			summary += fmt.Sprintf("  %-18s lookup failed: %s\n", svc.Service, svc.Error)
+		case svc.NotProvided:
+			summary += fmt.Sprintf("  %-18s not provided (target \".\")\n", svc.Service)
+		case !svc.Found:
+			summary += fmt.Sprintf("  %-18s none\n", svc.Service)
+		default:
+			for _, server := range svc.Servers {
+				summary += fmt.Sprintf("  %-18s %s:%d (priority %d, weight %d)\n", svc.Service, server.Host, server.Port, server.Priority, server.Weight)
+			}
+		}
+	}
+
+	summary += "\nThunderbird autoconfig:\n" + formatDocuments(result.Autoconfig)
+	summary += "\nMicrosoft Autodiscover:\n" + formatDocuments(result.Autodiscover)
+
+	if len(result.Probes) > 0 {
+		summary += "\nAdvertised servers:\n"
+		for _, probe := range result.Probes {
+			status := "OK"
+			if !probe.Valid {
+				status = "FAIL"
+			}
+			summary += fmt.Sprintf("  [%s] %s %s:%d %s (%s)\n", status, strings.ToUpper(probe.Protocol), probe.Host, probe.Port, probe.Security, strings.Join(probe.Sources, ", "))
+			if probe.TLS {
+				summary += fmt.Sprintf("    TLS: %s\n", probe.TLSVersion)
+			}
+			if cert := probe.Certificate; cert != nil {
+				summary += fmt.Sprintf("    Certificate: %s (issuer %s), valid to %s, trusted: %t, matches host: %t\n", cert.Subject, cert.Issuer, cert.NotAfter.Format("2006-01-02"), cert.Trusted, cert.HostnameMatch)
+			}
+			if probe.Error != "" {
+				summary += fmt.Sprintf("    Error: %s\n", probe.Error)
+			}
+		}
+	}
+
+	if len(result.Findings) > 0 {
+		summary += "\nFindings:\n"
+		for _, finding := range result.Findings {
+			summary += fmt.Sprintf("  ! %s\n", finding)
+		}
+	}
+	if result.Error != "" {
+		summary += fmt.Sprintf("\nError: %s\n", result.Error)
+	}
+
+	return summary
+}
+
+// formatDocuments renders the autoconfig or Autodiscover URLs that were tried
+func formatDocuments(documents []Document) string {
+	var b strings.Builder
+	for _, document := range documents {
+		switch {
+		case document.Found:
+			fmt.Fprintf(&b, "  %s: found", document.URL)
+			if document.DisplayName != "" {
+				fmt.Fprintf(&b, " (%s)", document.DisplayName)
+			}
+			b.WriteString("\n")
+			if document.Redirect != "" {
+				fmt.Fprintf(&b, "    Redirect: %s\n", document.Redirect)
+			}
+			for _, server := range document.Servers {
+				fmt.Fprintf(&b, "    %-4s %s:%d %s", strings.ToUpper(server.Protocol), server.Host, server.Port, server.Security)
+				if server.Username != "" {
+					fmt.Fprintf(&b, ", username %s", server.Username)
+				}
+				if len(server.Authentication) > 0 {
+					fmt.Fprintf(&b, ", auth %s", strings.Join(server.Authentication, ", "))
+				}
+				b.WriteString("\n")
+			}
+		case document.RequiresAuth:
+			fmt.Fprintf(&b, "  %s: requires authentication (HTTP %d)\n", document.URL, document.StatusCode)
+		case document.Error != "":
+			fmt.Fprintf(&b, "  %s: %s\n", document.URL, document.Error)
+		default:
+			fmt.Fprintf(&b, "  %s: HTTP %d\n", document.URL, document.StatusCode)
+		}
+	}
+	return b.String()
+}
+
+// sourceName returns the display name of a discovery source
+func sourceName(source string) string {
+	switch source {
+	case SourceSRV:
+		return "SRV"
+	case SourceAutoconfig:
+		return "Autoconfig"
+	case SourceAutodiscover:
+		return "Autodiscover"
+	}
+	return source
+}
+
+// overlaps reports whether two lists share a value
+func overlaps(a, b []string) bool {
+	for _, value := range a {
+		if contains(b, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// contains reports whether list contains value
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
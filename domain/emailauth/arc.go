@@ -0,0 +1,104 @@
+// Package emailauth contains the core domain logic for email authentication operations
+package emailauth
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ARCValidation represents the validation of the Authenticated Received Chain of a message (RFC 8617)
+type ARCValidation struct {
+	// none, pass or fail
+	Result string
+	// Why the chain did not pass
+	Reason string
+	// Highest instance, the number of intermediaries that sealed the message
+	Instances int
+	// Oldest instance whose ARC-Message-Signature still validates, 0 when all do; set when the chain passes
+	OldestPass int
+	// One entry per ARC set, by instance
+	Sets []ARCSetVerification
+	// What the chain says about forwarding and mailing lists
+	Findings []string
+}
+
+// ARCSetVerification represents the verification of one ARC set
+type ARCSetVerification struct {
+	// i= tag
+	Instance int
+	// Sealing domain (d=), selector (s=), algorithm (a=) and signing time (t=) of the ARC-Seal
+	SealDomain    string
+	SealSelector  string
+	SealAlgorithm string
+	SealTimestamp *time.Time
+	// Chain validation status (cv=) recorded by the sealer: none, pass or fail
+	ChainValidation string
+	// pass, fail, permerror or temperror, and why the seal did not pass
+	SealResult string
+	SealReason string
+	// Verification of the ARC-Message-Signature, nil when missing
+	MessageSignature *DKIMSignatureVerification
+	// Results the intermediary recorded in its ARC-Authentication-Results, nil when missing
+	AuthenticationResults *AuthenticationResults
+}
+
+// FormatARCValidation returns a human-readable summary of the ARC chain of a message
+func (s *Service) FormatARCValidation(result *ARCValidation) string {
+	if result == nil {
+		return "No ARC validation available"
+	}
+
+	if len(result.Sets) == 0 && result.Result == "none" {
+		return "No ARC headers found in the message\n"
+	}
+
+	intermediaries := "intermediaries"
+	if result.Instances == 1 {
+		intermediaries = "intermediary"
+	}
+	summary := fmt.Sprintf("ARC chain: %s (%d %s)\n", strings.ToUpper(result.Result), result.Instances, intermediaries)
+	if result.Reason != "" {
+		summary += fmt.Sprintf("  Reason: %s\n", result.Reason)
+	}
+	if result.Result == "pass" && result.OldestPass > 0 {
+		summary += fmt.Sprintf("  Oldest pass: i=%d\n", result.OldestPass)
+	}
+
+	for _, set := range result.Sets {
+		summary += fmt.Sprintf("\nARC set i=%d: %s\n", set.Instance, valueOr(set.SealDomain, "unknown sealer"))
+		seal := fmt.Sprintf("  Seal: %s", strings.ToUpper(set.SealResult))
+		if set.SealSelector != "" {
+			seal += fmt.Sprintf(" (selector %s, %s, cv=%s)", set.SealSelector, set.SealAlgorithm, set.ChainValidation)
+		}
+		summary += seal + "\n"
+		if set.SealTimestamp != nil {
+			summary += fmt.Sprintf("  Sealed at: %s\n", set.SealTimestamp.Format(time.RFC3339))
+		}
+		if set.SealReason != "" {
+			summary += fmt.Sprintf("    Reason: %s\n", set.SealReason)
+		}
+		if sig := set.MessageSignature; sig != nil {
+			summary += fmt.Sprintf("  Message signature: %s (%s, selector %s, %s)\n", strings.ToUpper(sig.Result), sig.Domain, sig.Selector, sig.Canonicalization)
+			if sig.Reason != "" {
+				summary += fmt.Sprintf("    Reason: %s\n", sig.Reason)
+			}
+		} else {
+			summary += "  Message signature: missing\n"
+		}
+		if results := set.AuthenticationResults; results != nil {
+			summary += fmt.Sprintf("  Results seen by %s: %s\n", valueOr(results.AuthServID, "unknown host"), formatAuthResultsEntries(results.Results))
+		} else {
+			summary += "  Results: missing\n"
+		}
+	}
+
+	if len(result.Findings) > 0 {
+		summary += "\nFindings:\n"
+		for _, finding := range result.Findings {
+			summary += fmt.Sprintf("  - %s\n", finding)
+		}
+	}
+
+	return summary
+}
@@ -0,0 +1,215 @@
+// Package emailauth contains the core domain logic for email authentication operations
+package emailauth
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// BIMIResult represents the BIMI (Brand Indicators for Message Identification) check of a domain
+type BIMIResult struct {
+	Domain   string
+	Selector string
+	// Name the record was found at, the domain's or its organizational domain's
+	RecordName string
+	// Parsed assertion record, nil when there is none
+	Record *BIMIRecord
+	// DMARC prerequisite
+	DMARC *BIMIDMARCCheck
+	// Validation of the l= logo, nil when there is none
+	Logo *BIMILogo
+	// Validation of the a= mark certificate, nil when there is none
+	Certificate *BIMICertificate
+	// Every check passed: mailbox providers can show the logo
+	Ready    bool
+	Findings []string
+	Error    string
+}
+
+// BIMIRecord represents a BIMI assertion record
+type BIMIRecord struct {
+	Raw string
+	// l= and a= tags: the SVG logo and the VMC or CMC
+	LogoURL      string
+	AuthorityURL string
+	// avp= tag: brand or personal
+	AvatarPreference string
+	// Empty l= and a=: the domain declines to show a logo
+	Declined    bool
+	UnknownTags []string
+}
+
+// BIMIDMARCCheck represents the DMARC prerequisite of BIMI: quarantine or reject at pct=100
+type BIMIDMARCCheck struct {
+	PolicyDomain string
+	Record       string
+	// p, sp or np
+	AppliedTag string
+	Policy     string
+	Pct        int
+	Enforced   bool
+	Reason     string
+}
+
+// BIMILogo represents the validation of a BIMI logo against the SVG Tiny Portable/Secure profile
+type BIMILogo struct {
+	URL         string
+	ContentType string
+	// Bytes as served, and whether they were gzip-compressed
+	Size        int
+	Compressed  bool
+	SHA256      string
+	Title       string
+	Version     string
+	BaseProfile string
+	ViewBox     string
+	Square      bool
+	Valid       bool
+	// Violations of the profile and recommendations not followed
+	Errors   []string
+	Warnings []string
+	// Why the logo could not be fetched
+	Error string
+}
+
+// BIMICertificate represents the validation of a Verified Mark Certificate or Common Mark Certificate
+type BIMICertificate struct {
+	URL string
+	// VMC or CMC, from the mark type (Registered Mark, Government Mark, Prior Use Mark...)
+	Type         string
+	MarkType     string
+	Subject      string
+	Organization string
+	Issuer       string
+	SerialNumber string
+	DNSNames     []string
+	NotBefore    *time.Time
+	NotAfter     *time.Time
+	Expired      bool
+	// Subjects from the leaf to the root
+	Chain      []string
+	ChainValid bool
+	ChainError string
+	// Extended key usage, subject alternative names and embedded logotype checks
+	BIMIUsage     bool
+	DomainCovered bool
+	LogoEmbedded  bool
+	LogoHashValid bool
+	LogoMatches   bool
+	Valid         bool
+	Errors        []string
+	// Why the certificate could not be fetched or parsed
+	Error string
+}
+
+// FormatBIMIResult returns a human-readable summary of the BIMI check of a domain
+func (s *Service) FormatBIMIResult(result *BIMIResult) string {
+	if result == nil {
+		return "No BIMI result available"
+	}
+
+	status := "NOT READY"
+	if result.Ready {
+		status = "READY"
+	}
+	summary := fmt.Sprintf("BIMI for %s (selector %s): %s\n", result.Domain, result.Selector, status)
+	if result.Error != "" {
+		summary += fmt.Sprintf("  Error: %s\n", result.Error)
+	}
+
+	if record := result.Record; record != nil {
+		summary += fmt.Sprintf("\nRecord at %s:\n  %s\n", result.RecordName, record.Raw)
+		if record.Declined {
+			summary += "  The domain declines to show a logo\n"
+		} else {
+			summary += fmt.Sprintf("  Logo (l=): %s\n", valueOr(record.LogoURL, "none"))
+			summary += fmt.Sprintf("  Certificate (a=): %s\n", valueOr(record.AuthorityURL, "none"))
+		}
+		if record.AvatarPreference != "" {
+			summary += fmt.Sprintf("  Avatar preference: %s\n", record.AvatarPreference)
+		}
+	}
+
+	if dmarc := result.DMARC; dmarc != nil {
+		enforced := "ENFORCED"
+		if !dmarc.Enforced {
+			enforced = "NOT ENFORCED"
+		}
+		summary += fmt.Sprintf("\nDMARC: %s", enforced)
+		if dmarc.Policy != "" {
+			summary += fmt.Sprintf(" (%s=%s, pct=%d at %s)", dmarc.AppliedTag, dmarc.Policy, dmarc.Pct, dmarc.PolicyDomain)
+		}
+		summary += "\n"
+		if dmarc.Reason != "" {
+			summary += fmt.Sprintf("  Reason: %s\n", dmarc.Reason)
+		}
+	}
+
+	if logo := result.Logo; logo != nil {
+		summary += fmt.Sprintf("\nLogo: %s\n", logo.URL)
+		if logo.Error != "" {
+			summary += fmt.Sprintf("  Error: %s\n", logo.Error)
+		} else {
+			valid := "VALID"
+			if !logo.Valid {
+				valid = "INVALID"
+			}
+			summary += fmt.Sprintf("  SVG Tiny PS: %s\n", valid)
+			summary += fmt.Sprintf("  Title: %s\n", valueOr(logo.Title, "none"))
+			size := fmt.Sprintf("%d bytes", logo.Size)
+			if logo.Compressed {
+				size += ", gzip-compressed"
+			}
+			summary += fmt.Sprintf("  Size: %s\n", size)
+			summary += formatBIMIList("Error", logo.Errors)
+			summary += formatBIMIList("Warning", logo.Warnings)
+		}
+	}
+
+	if cert := result.Certificate; cert != nil {
+		summary += fmt.Sprintf("\nCertificate: %s\n", cert.URL)
+		if cert.Error != "" {
+			summary += fmt.Sprintf("  Error: %s\n", cert.Error)
+		} else {
+			valid := "VALID"
+			if !cert.Valid {
+				valid = "INVALID"
+			}
+			summary += fmt.Sprintf("  %s: %s\n", valueOr(cert.Type, "Mark certificate"), valid)
+			if cert.MarkType != "" {
+				summary += fmt.Sprintf("  Mark type: %s\n", cert.MarkType)
+			}
+			summary += fmt.Sprintf("  Organization: %s\n", valueOr(cert.Organization, "unknown"))
+			summary += fmt.Sprintf("  Issuer: %s\n", cert.Issuer)
+			if cert.NotBefore != nil && cert.NotAfter != nil {
+				summary += fmt.Sprintf("  Valid: %s to %s\n", cert.NotBefore.Format("2006-01-02"), cert.NotAfter.Format("2006-01-02"))
+			}
+			if len(cert.DNSNames) > 0 {
+				summary += fmt.Sprintf("  Names: %s\n", strings.Join(cert.DNSNames, ", "))
+			}
+			if cert.ChainValid {
+				summary += fmt.Sprintf("  Chain: %s\n", strings.Join(cert.Chain, " -> "))
+			}
+			summary += formatBIMIList("Error", cert.Errors)
+		}
+	}
+
+	if len(result.Findings) > 0 {
+		summary += "\nFindings:\n"
+		for _, finding := range result.Findings {
+			summary += fmt.Sprintf("  - %s\n", finding)
+		}
+	}
+
+	return summary
+}
+
+// formatBIMIList formats the errors or warnings of a logo or certificate, one per line
+func formatBIMIList(label string, items []string) string {
+	var list string
+	for _, item := range items {
+		list += fmt.Sprintf("  %s: %s\n", label, item)
+	}
+	return list
+}
@@ -0,0 +1,658 @@
+// Package emailauth contains the core domain logic for email authentication operations
+package emailauth
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxKnownFailingFindings is the number of known sources with failures named in the findings
+const maxKnownFailingFindings = 5
+
+// DMARCReportUpload is a file holding DMARC reports: aggregate reports as XML, gzip or zip, or
+// a failure report message
+type DMARCReportUpload struct {
+	Name string
+	Data []byte
+}
+
+// DMARCReportFile represents the ingestion of one file
+type DMARCReportFile struct {
+	Name string
+	// Reports found in the file, and how many were new
+	Reports    int
+	Added      int
+	Duplicates int
+	// Error message if the file could not be read
+	Error string
+}
+
+// DMARCIngestResult represents the ingestion of a set of report files
+type DMARCIngestResult struct {
+	Files      []DMARCReportFile
+	Reports    int
+	Added      int
+	Duplicates int
+	// Files that could not be read
+	Failed int
+	// Where the reports are stored
+	Database string
+}
+
+// DMARCAggregateReport represents a DMARC aggregate (rua) report
+type DMARCAggregateReport struct {
+	OrgName   string
+	Email     string
+	ReportID  string
+	Begin     time.Time
+	End       time.Time
+	Domain    string
+	ADKIM     string
+	ASPF      string
+	Policy    string
+	SubPolicy string
+	Percent   int
+	Records   []DMARCAggregateRecord
+}
+
+// DMARCAggregateRecord represents the messages of one source with the same results
+type DMARCAggregateRecord struct {
+	SourceIP    string
+	Count       int
+	Disposition string
+	// DMARC-aligned DKIM and SPF results (pass or fail)
+	DKIM         string
+	SPF          string
+	Reasons      []string
+	HeaderFrom   string
+	EnvelopeFrom string
+	// Raw DKIM and SPF results, aligned or not
+	DKIMResults []DMARCAuthResult
+	SPFResults  []DMARCAuthResult
+}
+
+// DMARCAuthResult represents a raw DKIM or SPF result of a report record
+type DMARCAuthResult struct {
+	Domain   string
+	Selector string
+	Scope    string
+	Result   string
+}
+
+// DMARCReportQuery selects the stored reports to summarize
+type DMARCReportQuery struct {
+	// Published domain; subdomains match too
+	Domain string
+	// Reports whose date range overlaps [Since, Until]; zero means unbounded
+	Since time.Time
+	Until time.Time
+	// Number of sources listed, by volume; 0 lists all
+	Top int
+	// Whether to look up the reverse DNS name and ASN of every source
+	Lookup bool
+	// Senders known to send for the domain: IP addresses, CIDR ranges, ASNs (AS64500) or
+	// reverse DNS domains
+	KnownSenders []string
+}
+
+// ParseReportDate parses a report query bound: a YYYY-MM-DD date or an RFC 3339 time. A date
+// used as an upper bound covers the whole day.
+func ParseReportDate(value string, endOfDay bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not a YYYY-MM-DD date", value)
+	}
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Second)
+	}
+	return t, nil
+}
+
+// SourceOrigin describes who operates a source IP
+type SourceOrigin struct {
+	PTR string
+	// Organizational domain of the PTR name
+	PTRDomain string
+	ASN       int
+	ASName    string
+	Country   string
+	Prefix    string
+}
+
+// DMARCTally counts messages by authentication outcome
+type DMARCTally struct {
+	Messages int
+	// Messages with a passing SPF or DKIM result, aligned or not
+	SPFPass  int
+	DKIMPass int
+	// Messages whose SPF or DKIM result is aligned with the header From domain
+	SPFAligned  int
+	DKIMAligned int
+	// Messages passing DMARC: aligned SPF or aligned DKIM
+	DMARCPass int
+	// Messages by disposition applied by the receiver
+	Dispositions map[string]int
+}
+
+// DMARCSourceStats represents the messages reported for one source IP
+type DMARCSourceStats struct {
+	SourceIP string
+	Origin   *SourceOrigin
+	DMARCTally
+	// Header From domains and reporters seen for the source
+	HeaderFromDomains []string
+	Reporters         []string
+	// Whether the source is a known sender for the domain, and why
+	Known   bool
+	KnownBy string
+}
+
+// DMARCSourceGroup represents the sources of one ASN or reverse DNS domain
+type DMARCSourceGroup struct {
+	Name    string
+	Sources int
+	DMARCTally
+}
+
+// DMARCReportSummary represents the analytics of a set of aggregate reports
+type DMARCReportSummary struct {
+	Query DMARCReportQuery
+	// Reports summarized, reporting organizations, published domains and date range
+	Reports   int
+	Reporters []string
+	Domains   []string
+	Begin     time.Time
+	End       time.Time
+	// Totals over every source
+	DMARCTally
+	// Sources by message volume, limited to Query.Top
+	Sources      []*DMARCSourceStats
+	TotalSources int
+	// Sources grouped by ASN and by reverse DNS domain, by message volume
+	ByASN        []*DMARCSourceGroup
+	ByReverseDNS []*DMARCSourceGroup
+	// Sources that are not known senders and fail DMARC alignment, by message volume
+	UnknownFailing []*DMARCSourceStats
+	// Human-readable observations
+	Findings []string
+	// Where the reports are stored
+	Database string
+}
+
+// add counts the messages of a report record
+func (t *DMARCTally) add(record *DMARCAggregateRecord) {
+	if t.Dispositions == nil {
+		t.Dispositions = make(map[string]int)
+	}
+	n := record.Count
+	t.Messages += n
+	if hasPassingResult(record.SPFResults) {
+		t.SPFPass += n
+	}
+	if hasPassingResult(record.DKIMResults) {
+		t.DKIMPass += n
+	}
+	spfAligned, dkimAligned := record.SPF == "pass", record.DKIM == "pass"
+	if spfAligned {
+		t.SPFAligned += n
+	}
+	if dkimAligned {
+		t.DKIMAligned += n
+	}
+	if spfAligned || dkimAligned {
+		t.DMARCPass += n
+	}
+	disposition := record.Disposition
+	if disposition == "" {
+		disposition = "none"
+	}
+	t.Dispositions[disposition] += n
+}
+
+// merge adds the counts of another tally
+func (t *DMARCTally) merge(other *DMARCTally) {
+	if t.Dispositions == nil {
+		t.Dispositions = make(map[string]int)
+	}
+	t.Messages += other.Messages
+	t.SPFPass += other.SPFPass
+	t.DKIMPass += other.DKIMPass
+	t.SPFAligned += other.SPFAligned
+	t.DKIMAligned += other.DKIMAligned
+	t.DMARCPass += other.DMARCPass
+	for disposition, n := range other.Dispositions {
+		t.Dispositions[disposition] += n
+	}
+}
+
+// Rate returns n as a percentage of the messages of the tally
+func (t *DMARCTally) Rate(n int) float64 {
+	if t.Messages == 0 {
+		return 0
+	}
+	return float64(n) * 100 / float64(t.Messages)
+}
+
+// hasPassingResult reports whether any raw result passed
+func hasPassingResult(results []DMARCAuthResult) bool {
+	for _, result := range results {
+		if result.Result == "pass" {
+			return true
+		}
+	}
+	return false
+}
+
+// SummarizeDMARCReports computes per-source analytics of aggregate reports. A source is a known
+// sender when it matches query.KnownSenders or when any of its messages passed DMARC, which
+// takes a key or an SPF authorization of the domain; the other sources with failing messages
+// are the unknown senders to investigate. origins holds the reverse DNS and ASN of the sources
+// that were looked up.
+func (s *Service) SummarizeDMARCReports(reports []*DMARCAggregateReport, origins map[string]*SourceOrigin, query DMARCReportQuery) *DMARCReportSummary {
+	summary := &DMARCReportSummary{
+		Query:      query,
+		Reports:    len(reports),
+		DMARCTally: DMARCTally{Dispositions: make(map[string]int)},
+	}
+
+	reporters := make(map[string]bool)
+	domains := make(map[string]bool)
+	sources := make(map[string]*DMARCSourceStats)
+	sourceSets := make(map[string]map[string]map[string]bool) // IP -> "from"/"reporter" -> values
+	policyNone, partialPct := 0, 0
+
+	for _, report := range reports {
+		reporters[report.OrgName] = true
+		domains[report.Domain] = true
+		if summary.Begin.IsZero() || (!report.Begin.IsZero() && report.Begin.Before(summary.Begin)) {
+			summary.Begin = report.Begin
+		}
+		if report.End.After(summary.End) {
+			summary.End = report.End
+		}
+		if report.Policy == "none" {
+			policyNone++
+		}
+		if report.Percent > 0 && report.Percent < 100 {
+			partialPct++
+		}
+
+		for i := range report.Records {
+			record := &report.Records[i]
+			source, ok := sources[record.SourceIP]
+			if !ok {
+				source = &DMARCSourceStats{SourceIP: record.SourceIP, Origin: origins[record.SourceIP]}
+				sources[record.SourceIP] = source
+				sourceSets[record.SourceIP] = map[string]map[string]bool{"from": {}, "reporter": {}}
+			}
+			source.add(record)
+			summary.add(record)
+			if record.HeaderFrom != "" {
+				sourceSets[record.SourceIP]["from"][record.HeaderFrom] = true
+			}
+			sourceSets[record.SourceIP]["reporter"][report.OrgName] = true
+		}
+	}
+
+	summary.Reporters = sortedKeys(reporters)
+	summary.Domains = sortedKeys(domains)
+
+	known := parseKnownSenders(query.KnownSenders)
+	all := make([]*DMARCSourceStats, 0, len(sources))
+	for ip, source := range sources {
+		source.HeaderFromDomains = sortedKeys(sourceSets[ip]["from"])
+		source.Reporters = sortedKeys(sourceSets[ip]["reporter"])
+		if by := known.match(source); by != "" {
+			source.Known, source.KnownBy = true, by
+		} else if source.DMARCPass > 0 {
+			source.Known, source.KnownBy = true, "passes DMARC"
+		}
+		all = append(all, source)
+	}
+	sortSources(all)
+	summary.TotalSources = len(all)
+
+	asnGroups := make(map[string]*DMARCSourceGroup)
+	ptrGroups := make(map[string]*DMARCSourceGroup)
+	unknownMessages := 0
+	var knownFailing []*DMARCSourceStats
+	for _, source := range all {
+		if source.Origin != nil {
+			if source.Origin.ASN > 0 {
+				name := fmt.Sprintf("AS%d", source.Origin.ASN)
+				if source.Origin.ASName != "" {
+					name += " " + source.Origin.ASName
+				}
+				addToGroup(asnGroups, name, source)
+			}
+			if source.Origin.PTRDomain != "" {
+				addToGroup(ptrGroups, source.Origin.PTRDomain, source)
+			}
+		}
+
+		failing := source.Messages - source.DMARCPass
+		switch {
+		case failing == 0:
+		case !source.Known:
+			summary.UnknownFailing = append(summary.UnknownFailing, source)
+			unknownMessages += failing
+		default:
+			knownFailing = append(knownFailing, source)
+		}
+	}
+	summary.ByASN = sortedGroups(asnGroups)
+	summary.ByReverseDNS = sortedGroups(ptrGroups)
+
+	summary.Sources = all
+	if query.Top > 0 && len(all) > query.Top {
+		summary.Sources = all[:query.Top]
+	}
+
+	// Findings
+	if len(summary.UnknownFailing) > 0 {
+		summary.Findings = append(summary.Findings, fmt.Sprintf("%d messages (%.1f%%) from %d unknown sources failed DMARC alignment; check that they are not legitimate senders missing SPF or DKIM before enforcing the policy",
+			unknownMessages, summary.Rate(unknownMessages), len(summary.UnknownFailing)))
+	}
+	for i, source := range knownFailing {
+		if i == maxKnownFailingFindings {
+			summary.Findings = append(summary.Findings, fmt.Sprintf("%d more known sources have failing messages", len(knownFailing)-i))
+			break
+		}
+		summary.Findings = append(summary.Findings, fmt.Sprintf("Known source %s has %d of %d messages failing DMARC alignment (forwarding, or a sender not signing every message)",
+			sourceLabel(source), source.Messages-source.DMARCPass, source.Messages))
+	}
+	if unaligned := summary.SPFPass - summary.SPFAligned; unaligned > 0 && summary.SPFPass > 0 {
+		summary.Findings = append(summary.Findings, fmt.Sprintf("SPF passes for %d messages whose envelope domain is not aligned with the header From domain; those senders need aligned DKIM signatures",
+			unaligned))
+	}
+	if policyNone > 0 && summary.Messages > 0 && summary.Rate(summary.DMARCPass) >= 98 && len(summary.UnknownFailing) == 0 {
+		summary.Findings = append(summary.Findings, fmt.Sprintf("%.1f%% of messages pass DMARC under p=none; the policy can move to quarantine", summary.Rate(summary.DMARCPass)))
+	}
+	if partialPct > 0 {
+		summary.Findings = append(summary.Findings, fmt.Sprintf("%d reports were generated under a pct= below 100; part of the failing mail was not subject to the policy", partialPct))
+	}
+
+	return summary
+}
+
+// addToGroup adds the counts of a source to its group
+func addToGroup(groups map[string]*DMARCSourceGroup, name string, source *DMARCSourceStats) {
+	group, ok := groups[name]
+	if !ok {
+		group = &DMARCSourceGroup{Name: name}
+		groups[name] = group
+	}
+	group.Sources++
+	group.merge(&source.DMARCTally)
+}
+
+// sortSources orders sources by message volume, then by IP
+func sortSources(sources []*DMARCSourceStats) {
+	sort.Slice(sources, func(i, j int) bool {
+		if sources[i].Messages != sources[j].Messages {
+			return sources[i].Messages > sources[j].Messages
+		}
+		return sources[i].SourceIP < sources[j].SourceIP
+	})
+}
+
+// sortedGroups returns groups by message volume, then by name
+func sortedGroups(groups map[string]*DMARCSourceGroup) []*DMARCSourceGroup {
+	sorted := make([]*DMARCSourceGroup, 0, len(groups))
+	for _, group := range groups {
+		sorted = append(sorted, group)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Messages != sorted[j].Messages {
+			return sorted[i].Messages > sorted[j].Messages
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+	return sorted
+}
+
+// sortedKeys returns the keys of a set in order
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// knownSenders holds the parsed known sender list
+type knownSenders struct {
+	ips     map[string]string
+	nets    []*net.IPNet
+	asns    map[int]string
+	domains []string
+}
+
+// parseKnownSenders sorts known sender entries into IP addresses, CIDR ranges, ASNs and domains
+func parseKnownSenders(entries []string) *knownSenders {
+	known := &knownSenders{ips: make(map[string]string), asns: make(map[int]string)}
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if ip := net.ParseIP(entry); ip != nil {
+			known.ips[ip.String()] = entry
+			continue
+		}
+		if _, ipNet, err := net.ParseCIDR(entry); err == nil {
+			known.nets = append(known.nets, ipNet)
+			continue
+		}
+		upper := strings.ToUpper(entry)
+		if strings.HasPrefix(upper, "AS") {
+			if asn, err := strconv.Atoi(upper[2:]); err == nil {
+				known.asns[asn] = entry
+				continue
+			}
+		}
+		known.domains = append(known.domains, strings.TrimSuffix(strings.ToLower(entry), "."))
+	}
+	return known
+}
+
+// match returns the known sender entry a source matches, or an empty string
+func (k *knownSenders) match(source *DMARCSourceStats) string {
+	if entry, ok := k.ips[source.SourceIP]; ok {
+		return "known sender " + entry
+	}
+	if ip := net.ParseIP(source.SourceIP); ip != nil {
+		for _, ipNet := range k.nets {
+			if ipNet.Contains(ip) {
+				return "known sender " + ipNet.String()
+			}
+		}
+	}
+	if source.Origin == nil {
+		return ""
+	}
+	if entry, ok := k.asns[source.Origin.ASN]; ok && source.Origin.ASN > 0 {
+		return "known sender " + entry
+	}
+	if ptr := source.Origin.PTR; ptr != "" {
+		for _, domain := range k.domains {
+			if ptr == domain || strings.HasSuffix(ptr, "."+domain) {
+				return "known sender " + domain
+			}
+		}
+	}
+	return ""
+}
+
+// sourceLabel names a source with its reverse DNS name when known
+func sourceLabel(source *DMARCSourceStats) string {
+	if source.Origin != nil && source.Origin.PTR != "" {
+		return fmt.Sprintf("%s (%s)", source.SourceIP, source.Origin.PTR)
+	}
+	return source.SourceIP
+}
+
+// ProcessDMARCIngest totals the ingestion of a set of files
+func (s *Service) ProcessDMARCIngest(files []DMARCReportFile, database string) *DMARCIngestResult {
+	result := &DMARCIngestResult{Files: files, Database: database}
+	for _, file := range files {
+		if file.Error != "" {
+			result.Failed++
+			continue
+		}
+		result.Reports += file.Reports
+		result.Added += file.Added
+		result.Duplicates += file.Duplicates
+	}
+	return result
+}
+
+// FormatDMARCIngest returns a human-readable summary of a report ingestion
+func (s *Service) FormatDMARCIngest(result *DMARCIngestResult) string {
+	if result == nil {
+		return "No DMARC report ingestion available"
+	}
+
+	summary := fmt.Sprintf("DMARC aggregate reports: %d files, %d reports, %d added, %d already stored\n",
+		len(result.Files), result.Reports, result.Added, result.Duplicates)
+	if result.Database != "" {
+		summary += fmt.Sprintf("  Database: %s\n", result.Database)
+	}
+	if result.Failed > 0 {
+		summary += fmt.Sprintf("\nFiles that could not be read (%d):\n", result.Failed)
+		for _, file := range result.Files {
+			// Parse errors start with the file name
+			if file.Error != "" {
+				summary += fmt.Sprintf("  %s\n", file.Error)
+			}
+		}
+	}
+
+	return summary
+}
+
+// FormatDMARCReportSummary returns a human-readable summary of aggregate report analytics
+func (s *Service) FormatDMARCReportSummary(result *DMARCReportSummary) string {
+	if result == nil {
+		return "No DMARC report summary available"
+	}
+	if result.Reports == 0 {
+		return "No DMARC aggregate reports match; ingest reports with \"dmarc ingest\"\n"
+	}
+
+	summary := fmt.Sprintf("DMARC aggregate reports: %d reports from %s\n", result.Reports, strings.Join(result.Reporters, ", "))
+	summary += fmt.Sprintf("  Domains: %s\n", strings.Join(result.Domains, ", "))
+	summary += fmt.Sprintf("  Period: %s to %s\n", result.Begin.Format("2006-01-02"), result.End.Format("2006-01-02"))
+	summary += fmt.Sprintf("  Messages: %d from %d sources\n", result.Messages, result.TotalSources)
+	summary += fmt.Sprintf("  DMARC pass: %s\n", formatTallyRate(&result.DMARCTally, result.DMARCPass))
+	summary += fmt.Sprintf("  SPF: %s pass, %s aligned\n", formatTallyRate(&result.DMARCTally, result.SPFPass), formatTallyRate(&result.DMARCTally, result.SPFAligned))
+	summary += fmt.Sprintf("  DKIM: %s pass, %s aligned\n", formatTallyRate(&result.DMARCTally, result.DKIMPass), formatTallyRate(&result.DMARCTally, result.DKIMAligned))
+	summary += fmt.Sprintf("  Disposition: %s\n", formatDispositions(result.Dispositions))
+
+	if len(result.UnknownFailing) > 0 {
+		summary += fmt.Sprintf("\nUnknown senders failing alignment (%d):\n", len(result.UnknownFailing))
+		for _, source := range result.UnknownFailing {
+			summary += formatSourceLine(source)
+		}
+	}
+
+	title := "Sources"
+	if len(result.Sources) < result.TotalSources {
+		title = fmt.Sprintf("Top %d of %d sources", len(result.Sources), result.TotalSources)
+	}
+	summary += fmt.Sprintf("\n%s:\n", title)
+	for _, source := range result.Sources {
+		summary += formatSourceLine(source)
+		summary += fmt.Sprintf("    SPF %.1f%% pass, %.1f%% aligned; DKIM %.1f%% pass, %.1f%% aligned; %s\n",
+			source.Rate(source.SPFPass), source.Rate(source.SPFAligned), source.Rate(source.DKIMPass), source.Rate(source.DKIMAligned),
+			formatDispositions(source.Dispositions))
+		if source.Known {
+			summary += fmt.Sprintf("    Known: %s\n", source.KnownBy)
+		}
+	}
+
+	if len(result.ByASN) > 0 {
+		summary += "\nBy ASN:\n"
+		for _, group := range result.ByASN {
+			summary += fmt.Sprintf("  %s: %d messages from %d sources, DMARC pass %.1f%%\n", group.Name, group.Messages, group.Sources, group.Rate(group.DMARCPass))
+		}
+	}
+	if len(result.ByReverseDNS) > 0 {
+		summary += "\nBy reverse DNS domain:\n"
+		for _, group := range result.ByReverseDNS {
+			summary += fmt.Sprintf("  %s: %d messages from %d sources, DMARC pass %.1f%%\n", group.Name, group.Messages, group.Sources, group.Rate(group.DMARCPass))
+		}
+	}
+
+	if len(result.Findings) > 0 {
+		summary += "\nFindings:\n"
+		for _, finding := range result.Findings {
+			summary += fmt.Sprintf("  - %s\n", finding)
+		}
+	}
+
+	return summary
+}
+
+// formatSourceLine returns the first line describing a source
+func formatSourceLine(source *DMARCSourceStats) string {
+	line := "  " + sourceLabel(source)
+	if origin := source.Origin; origin != nil && origin.ASN > 0 {
+		line += fmt.Sprintf(" AS%d", origin.ASN)
+		if origin.ASName != "" {
+			line += " " + origin.ASName
+		}
+	}
+	line += fmt.Sprintf(": %d messages, DMARC pass %.1f%%", source.Messages, source.Rate(source.DMARCPass))
+	if len(source.HeaderFromDomains) > 0 {
+		line += fmt.Sprintf(" [From: %s]", strings.Join(source.HeaderFromDomains, ", "))
+	}
+	return line + "\n"
+}
+
+// formatTallyRate formats a count with its percentage of the messages
+func formatTallyRate(tally *DMARCTally, n int) string {
+	return fmt.Sprintf("%d (%.1f%%)", n, tally.Rate(n))
+}
+
+// formatDispositions formats message counts by disposition
+func formatDispositions(dispositions map[string]int) string {
+	var parts []string
+	for _, disposition := range []string{"none", "quarantine", "reject"} {
+		if n := dispositions[disposition]; n > 0 {
+			parts = append(parts, fmt.Sprintf("%s %d", disposition, n))
+		}
+	}
+	for _, disposition := range sortedDispositions(dispositions) {
+		parts = append(parts, fmt.Sprintf("%s %d", disposition, dispositions[disposition]))
+	}
+	if len(parts) == 0 {
+		return "no messages"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// sortedDispositions returns the non-standard dispositions some reporters use, in order
+func sortedDispositions(dispositions map[string]int) []string {
+	var other []string
+	for disposition := range dispositions {
+		switch disposition {
+		case "none", "quarantine", "reject":
+		default:
+			other = append(other, disposition)
+		}
+	}
+	sort.Strings(other)
+	return other
+}
@@ -0,0 +1,384 @@
+// Package emailauth contains the core domain logic for email authentication operations
+package emailauth
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ForensicReportFile represents the parsing of one failure report file
+type ForensicReportFile struct {
+	Name   string
+	Report *ForensicReport
+	// Error message if the file is not an ARF report
+	Error string
+}
+
+// ForensicReport represents an ARF feedback report (RFC 5965), usually a DMARC failure (ruf)
+// report (RFC 6591)
+type ForensicReport struct {
+	// Headers of the report message itself
+	ReportFrom    string
+	ReportSubject string
+	ReportDate    string
+	HumanReadable string
+	// Machine-readable fields: abuse, fraud, auth-failure, ...
+	FeedbackType string
+	UserAgent    string
+	Version      string
+	// dmarc, dkim, spf, adsp or bodyhash, for auth-failure reports
+	AuthFailure       string
+	IdentityAlignment string
+	SourceIP          string
+	ReportingMTA      string
+	ArrivalDate       *time.Time
+	OriginalMailFrom  string
+	OriginalRcptTo    []string
+	ReportedDomain    []string
+	DeliveryResult    string
+	Incidents         int
+	DKIMDomain        string
+	DKIMSelector      string
+	DKIMIdentity      string
+	SPFDNS            string
+	// Authentication-Results fields of the report
+	AuthenticationResults []AuthenticationResults
+	// Every feedback field by lowercase name, including those not listed above
+	Fields map[string][]string
+	// The message the report was generated for, nil when not included
+	Original *ForensicOriginalMessage
+	// Deviations from RFC 5965 and RFC 6591 that did not prevent parsing
+	Warnings []string
+}
+
+// ForensicOriginalMessage represents the message, or only its headers, of a failure report
+type ForensicOriginalMessage struct {
+	HeadersOnly bool
+	From        string
+	FromDomain  string
+	ReturnPath  string
+	To          string
+	Subject     string
+	Date        string
+	MessageID   string
+	// Signing domains (d=) of the DKIM-Signature headers
+	DKIMDomains           []string
+	AuthenticationResults []AuthenticationResults
+	// Number of Received headers and header problems found by message analysis
+	ReceivedHops   int
+	HeaderFindings []string
+}
+
+// ForensicCampaign represents the failure reports of messages with the same From domain and
+// subject, once numbers are masked
+type ForensicCampaign struct {
+	FromDomain string
+	// Normalized subject the campaign is keyed on, and the first subject seen
+	SubjectPattern string
+	Subject        string
+	Reports        int
+	Incidents      int
+	// Failing sources, reporters and envelope senders seen
+	SourceIPs         []string
+	Reporters         []string
+	ReturnPathDomains []string
+	DKIMDomains       []string
+	// Incidents by failed mechanism and by delivery result
+	AuthFailures    map[string]int
+	DeliveryResults map[string]int
+	// Arrival of the first and last reported message, zero when unknown
+	FirstSeen time.Time
+	LastSeen  time.Time
+	// Likely cause of the failures
+	Assessment string
+}
+
+// ForensicReportAnalysis represents a set of failure reports grouped by campaign
+type ForensicReportAnalysis struct {
+	Files     []ForensicReportFile
+	Reports   int
+	Incidents int
+	// Files that could not be read
+	Failed int
+	// Distinct failing source IPs over every report
+	SourceIPs int
+	// Campaigns by incidents
+	Campaigns []*ForensicCampaign
+	// Human-readable observations
+	Findings []string
+}
+
+// Patterns masked when grouping subjects: runs of digits and long hexadecimal tokens
+var (
+	subjectHexPattern    = regexp.MustCompile(`\b[0-9a-f]{8,}\b`)
+	subjectNumberPattern = regexp.MustCompile(`[0-9]+`)
+	subjectPrefixPattern = regexp.MustCompile(`^((re|fw|fwd|aw|tr)\s*:\s*)+`)
+)
+
+// normalizeSubject masks the parts of a subject that vary between messages of a campaign
+func normalizeSubject(subject string) string {
+	subject = strings.ToLower(strings.Join(strings.Fields(subject), " "))
+	subject = subjectPrefixPattern.ReplaceAllString(subject, "")
+	subject = subjectHexPattern.ReplaceAllString(subject, "#")
+	return subjectNumberPattern.ReplaceAllString(subject, "#")
+}
+
+// reportedFromDomain returns the author domain of the reported message
+func reportedFromDomain(report *ForensicReport) string {
+	if report.Original != nil && report.Original.FromDomain != "" {
+		return report.Original.FromDomain
+	}
+	if len(report.ReportedDomain) > 0 {
+		return report.ReportedDomain[0]
+	}
+	return "unknown"
+}
+
+// reporterName returns who sent a report: the domain of its From address, or the reporting MTA
+func reporterName(report *ForensicReport) string {
+	from := strings.TrimSuffix(report.ReportFrom, ">")
+	if at := strings.LastIndexByte(from, '@'); at >= 0 {
+		return strings.ToLower(from[at+1:])
+	}
+	if report.ReportingMTA != "" {
+		return report.ReportingMTA
+	}
+	return "unknown"
+}
+
+// ProcessForensicReports groups the parsed failure reports into campaigns
+func (s *Service) ProcessForensicReports(files []ForensicReportFile) *ForensicReportAnalysis {
+	result := &ForensicReportAnalysis{Files: files}
+	campaigns := make(map[string]*ForensicCampaign)
+	type campaignSets struct {
+		sources, reporters, returnPaths, dkimDomains map[string]bool
+		signed, signatureFailed                      bool
+	}
+	sets := make(map[*ForensicCampaign]*campaignSets)
+	allSources := make(map[string]bool)
+
+	for _, file := range files {
+		if file.Error != "" || file.Report == nil {
+			result.Failed++
+			continue
+		}
+		report := file.Report
+		result.Reports++
+		result.Incidents += report.Incidents
+
+		fromDomain := reportedFromDomain(report)
+		subject := ""
+		if report.Original != nil {
+			subject = report.Original.Subject
+		}
+		pattern := normalizeSubject(subject)
+		key := fromDomain + "\x00" + pattern
+		campaign, ok := campaigns[key]
+		if !ok {
+			campaign = &ForensicCampaign{
+				FromDomain:      fromDomain,
+				SubjectPattern:  pattern,
+				Subject:         subject,
+				AuthFailures:    make(map[string]int),
+				DeliveryResults: make(map[string]int),
+			}
+			campaigns[key] = campaign
+			sets[campaign] = &campaignSets{
+				sources: make(map[string]bool), reporters: make(map[string]bool),
+				returnPaths: make(map[string]bool), dkimDomains: make(map[string]bool),
+			}
+		}
+		set := sets[campaign]
+
+		campaign.Reports++
+		campaign.Incidents += report.Incidents
+		if report.SourceIP != "" {
+			set.sources[report.SourceIP] = true
+			allSources[report.SourceIP] = true
+		}
+		set.reporters[reporterName(report)] = true
+		if report.AuthFailure != "" {
+			campaign.AuthFailures[report.AuthFailure] += report.Incidents
+		}
+		if report.DeliveryResult != "" {
+			campaign.DeliveryResults[report.DeliveryResult] += report.Incidents
+		}
+		if report.ArrivalDate != nil {
+			if campaign.FirstSeen.IsZero() || report.ArrivalDate.Before(campaign.FirstSeen) {
+				campaign.FirstSeen = *report.ArrivalDate
+			}
+			if report.ArrivalDate.After(campaign.LastSeen) {
+				campaign.LastSeen = *report.ArrivalDate
+			}
+		}
+
+		mailFrom := report.OriginalMailFrom
+		if mailFrom == "" && report.Original != nil {
+			mailFrom = report.Original.ReturnPath
+		}
+		if at := strings.LastIndexByte(mailFrom, '@'); at >= 0 {
+			set.returnPaths[strings.ToLower(mailFrom[at+1:])] = true
+		}
+
+		var dkimDomains []string
+		if report.Original != nil {
+			dkimDomains = append(dkimDomains, report.Original.DKIMDomains...)
+		}
+		if report.DKIMDomain != "" {
+			dkimDomains = append(dkimDomains, report.DKIMDomain)
+		}
+		for _, domain := range dkimDomains {
+			set.dkimDomains[domain] = true
+			if domain == fromDomain || strings.HasSuffix(domain, "."+fromDomain) || strings.HasSuffix(fromDomain, "."+domain) {
+				set.signed = true
+			}
+		}
+		if report.AuthFailure == "dkim" || report.AuthFailure == "bodyhash" || hasFailedDKIM(report.AuthenticationResults) {
+			set.signatureFailed = true
+		}
+	}
+
+	for _, campaign := range campaigns {
+		set := sets[campaign]
+		campaign.SourceIPs = sortedKeys(set.sources)
+		campaign.Reporters = sortedKeys(set.reporters)
+		campaign.ReturnPathDomains = sortedKeys(set.returnPaths)
+		campaign.DKIMDomains = sortedKeys(set.dkimDomains)
+
+		switch {
+		case !set.signed:
+			campaign.Assessment = fmt.Sprintf("Not DKIM-signed by %s: spoofing, or a sender that is not set up to sign for the domain", campaign.FromDomain)
+		case set.signatureFailed:
+			campaign.Assessment = "Signed by the domain but the signature fails: likely modified in transit, e.g. by forwarding or a mailing list"
+		default:
+			campaign.Assessment = "Signed by the domain: check the alignment of the DKIM and SPF identifiers"
+		}
+		result.Campaigns = append(result.Campaigns, campaign)
+	}
+	sort.Slice(result.Campaigns, func(i, j int) bool {
+		a, b := result.Campaigns[i], result.Campaigns[j]
+		if a.Incidents != b.Incidents {
+			return a.Incidents > b.Incidents
+		}
+		if a.FromDomain != b.FromDomain {
+			return a.FromDomain < b.FromDomain
+		}
+		return a.SubjectPattern < b.SubjectPattern
+	})
+	result.SourceIPs = len(allSources)
+
+	for _, campaign := range result.Campaigns {
+		if len(sets[campaign].dkimDomains) == 0 && len(campaign.SourceIPs) > 1 {
+			result.Findings = append(result.Findings, fmt.Sprintf("Unsigned messages claiming to be from %s are sent from %d source IPs",
+				campaign.FromDomain, len(campaign.SourceIPs)))
+		}
+	}
+
+	return result
+}
+
+// hasFailedDKIM reports whether an Authentication-Results header has a DKIM result other than pass
+func hasFailedDKIM(results []AuthenticationResults) bool {
+	for _, header := range results {
+		for _, result := range header.Results {
+			if result.Method == "dkim" && result.Result != "pass" && result.Result != "none" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// FormatForensicReports returns a human-readable summary of failure reports grouped by campaign
+func (s *Service) FormatForensicReports(result *ForensicReportAnalysis) string {
+	if result == nil {
+		return "No DMARC failure report analysis available"
+	}
+
+	summary := fmt.Sprintf("DMARC failure reports: %d files, %d reports, %d incidents from %d source IPs, %d campaigns\n",
+		len(result.Files), result.Reports, result.Incidents, result.SourceIPs, len(result.Campaigns))
+
+	for _, campaign := range result.Campaigns {
+		subject := campaign.Subject
+		if subject == "" {
+			subject = "(no subject)"
+		}
+		summary += fmt.Sprintf("\n%s %q: %d reports, %d incidents\n", campaign.FromDomain, subject, campaign.Reports, campaign.Incidents)
+		if len(campaign.SourceIPs) > 0 {
+			summary += fmt.Sprintf("  Sources: %s\n", strings.Join(campaign.SourceIPs, ", "))
+		}
+		if len(campaign.AuthFailures) > 0 {
+			summary += fmt.Sprintf("  Failed: %s\n", formatCounts(campaign.AuthFailures))
+		}
+		if len(campaign.DeliveryResults) > 0 {
+			summary += fmt.Sprintf("  Delivery: %s\n", formatCounts(campaign.DeliveryResults))
+		}
+		if len(campaign.ReturnPathDomains) > 0 {
+			summary += fmt.Sprintf("  Envelope senders: %s\n", strings.Join(campaign.ReturnPathDomains, ", "))
+		}
+		if len(campaign.DKIMDomains) > 0 {
+			summary += fmt.Sprintf("  DKIM domains: %s\n", strings.Join(campaign.DKIMDomains, ", "))
+		}
+		if !campaign.FirstSeen.IsZero() {
+			summary += fmt.Sprintf("  Seen: %s to %s\n", campaign.FirstSeen.UTC().Format("2006-01-02 15:04"), campaign.LastSeen.UTC().Format("2006-01-02 15:04"))
+		}
+		summary += fmt.Sprintf("  Reporters: %s\n", strings.Join(campaign.Reporters, ", "))
+		summary += fmt.Sprintf("  %s\n", campaign.Assessment)
+	}
+
+	var warnings []string
+	for _, file := range result.Files {
+		if file.Report == nil {
+			continue
+		}
+		for _, warning := range file.Report.Warnings {
+			warnings = append(warnings, fmt.Sprintf("%s: %s", file.Name, warning))
+		}
+	}
+	if len(warnings) > 0 {
+		summary += fmt.Sprintf("\nWarnings (%d):\n", len(warnings))
+		for _, warning := range warnings {
+			summary += fmt.Sprintf("  %s\n", warning)
+		}
+	}
+
+	if result.Failed > 0 {
+		summary += fmt.Sprintf("\nFiles that could not be read (%d):\n", result.Failed)
+		for _, file := range result.Files {
+			if file.Error != "" {
+				summary += fmt.Sprintf("  %s: %s\n", file.Name, file.Error)
+			}
+		}
+	}
+
+	if len(result.Findings) > 0 {
+		summary += "\nFindings:\n"
+		for _, finding := range result.Findings {
+			summary += fmt.Sprintf("  - %s\n", finding)
+		}
+	}
+
+	return summary
+}
+
+// formatCounts formats counts by name, largest first
+func formatCounts(counts map[string]int) string {
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if counts[names[i]] != counts[names[j]] {
+			return counts[names[i]] > counts[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%s %d", name, counts[name])
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,254 @@
+// Package emailauth contains the core domain logic for email authentication operations
+package emailauth
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AuthenticationResults represents an Authentication-Results header (RFC 8601)
+type AuthenticationResults struct {
+	// Host that evaluated the message
+	AuthServID string
+	Results    []AuthResultsEntry
+}
+
+// AuthResultsEntry represents one method result of an Authentication-Results header
+type AuthResultsEntry struct {
+	Method string
+	Result string
+	Reason string
+	// Properties such as header.d or smtp.mailfrom
+	Properties map[string]string
+}
+
+// HeaderField represents one unfolded header field
+type HeaderField struct {
+	Name  string
+	Value string
+}
+
+// ReceivedHop represents one Received header, in transit order
+type ReceivedHop struct {
+	// 1 for the first relay after the sender
+	Hop int
+	// Name the client announced, reverse DNS name and address recorded by the receiver
+	From     string
+	FromHost string
+	FromIP   string
+	// Receiving host, protocol, queue ID and recipient
+	By   string
+	With string
+	ID   string
+	For  string
+	// Time the receiver recorded, nil when missing or invalid
+	Timestamp *time.Time
+	// Seconds since the previous hop, or since the Date header for the first hop
+	DelaySeconds *float64
+	// Whether the hop is timestamped before the previous one
+	ClockSkew bool
+	// Whether the hop used TLS, and the cipher details the receiver recorded
+	TLS        bool
+	TLSDetails string
+	Raw        string
+}
+
+// ARCSetHeaders represents the headers of one ARC set as found in a message
+type ARCSetHeaders struct {
+	Instance int
+	// Sealing domain, selector and chain validation status (cv=) of the ARC-Seal
+	SealDomain      string
+	SealSelector    string
+	ChainValidation string
+	// Signing domain and selector of the ARC-Message-Signature
+	SignatureDomain   string
+	SignatureSelector string
+	// Results recorded in the ARC-Authentication-Results, nil when missing
+	AuthenticationResults *AuthenticationResults
+}
+
+// ListHeaders represents the mailing list headers of a message
+type ListHeaders struct {
+	ID string
+	// URIs of the List-Unsubscribe header, and the List-Unsubscribe-Post value
+	Unsubscribe     []string
+	UnsubscribePost string
+	Subscribe       []string
+	Post            []string
+	Help            []string
+	Owner           []string
+	Archive         []string
+}
+
+// HeaderAnalysis represents the analysis of the header section of a message
+type HeaderAnalysis struct {
+	From             string
+	FromDomain       string
+	To               string
+	Subject          string
+	Date             string
+	MessageID        string
+	ReturnPath       string
+	ReturnPathDomain string
+	ReplyTo          string
+	// Received hops, first relay first
+	Hops []ReceivedHop
+	// Seconds from the Date header, or the first hop, to the last hop
+	TotalDelaySeconds     *float64
+	AuthenticationResults []AuthenticationResults
+	// ARC sets by instance
+	ARC []ARCSetHeaders
+	// Signing domains (d=) of the DKIM-Signature headers
+	DKIMDomains []string
+	// Mailing list headers, nil when there are none
+	List *ListHeaders
+	// Every header field, in message order
+	Headers []HeaderField
+	// Human-readable observations
+	Findings []string
+}
+
+// FormatHeaderAnalysis returns a human-readable report of a message header analysis
+func (s *Service) FormatHeaderAnalysis(result *HeaderAnalysis) string {
+	if result == nil {
+		return "No header analysis available"
+	}
+
+	summary := fmt.Sprintf("Message headers: %d fields\n", len(result.Headers))
+	for _, field := range []struct{ name, value string }{
+		{"From", result.From},
+		{"To", result.To},
+		{"Subject", result.Subject},
+		{"Date", result.Date},
+		{"Message-ID", result.MessageID},
+		{"Return-Path", result.ReturnPath},
+		{"Reply-To", result.ReplyTo},
+	} {
+		if field.value != "" {
+			summary += fmt.Sprintf("  %s: %s\n", field.name, field.value)
+		}
+	}
+
+	if len(result.Hops) > 0 {
+		summary += fmt.Sprintf("\nReceived hops (%d", len(result.Hops))
+		if result.TotalDelaySeconds != nil {
+			summary += ", total " + formatSeconds(*result.TotalDelaySeconds)
+		}
+		summary += "):\n"
+		for _, hop := range result.Hops {
+			summary += formatHop(&hop)
+		}
+	}
+
+	if len(result.AuthenticationResults) > 0 {
+		summary += "\nAuthentication-Results:\n"
+		for _, header := range result.AuthenticationResults {
+			summary += fmt.Sprintf("  %s: %s\n", header.AuthServID, formatAuthResultsEntries(header.Results))
+		}
+	}
+
+	if len(result.ARC) > 0 {
+		summary += "\nARC sets:\n"
+		for _, set := range result.ARC {
+			summary += fmt.Sprintf("  i=%d sealed by %s (cv=%s), signed by %s\n", set.Instance, valueOr(set.SealDomain, "?"),
+				valueOr(set.ChainValidation, "?"), valueOr(set.SignatureDomain, "?"))
+			if set.AuthenticationResults != nil {
+				summary += fmt.Sprintf("    %s: %s\n", set.AuthenticationResults.AuthServID, formatAuthResultsEntries(set.AuthenticationResults.Results))
+			}
+		}
+	}
+
+	if len(result.DKIMDomains) > 0 {
+		summary += fmt.Sprintf("\nDKIM signatures: %s\n", strings.Join(result.DKIMDomains, ", "))
+	}
+
+	if list := result.List; list != nil {
+		summary += "\nMailing list:\n"
+		if list.ID != "" {
+			summary += fmt.Sprintf("  List-Id: %s\n", list.ID)
+		}
+		if len(list.Unsubscribe) > 0 {
+			summary += fmt.Sprintf("  Unsubscribe: %s\n", strings.Join(list.Unsubscribe, ", "))
+		}
+		if list.UnsubscribePost != "" {
+			summary += fmt.Sprintf("  Unsubscribe-Post: %s\n", list.UnsubscribePost)
+		}
+	}
+
+	if len(result.Findings) > 0 {
+		summary += "\nFindings:\n"
+		for _, finding := range result.Findings {
+			summary += fmt.Sprintf("  - %s\n", finding)
+		}
+	}
+
+	return summary
+}
+
+// formatHop returns the lines describing a Received hop
+func formatHop(hop *ReceivedHop) string {
+	from := valueOr(hop.From, "?")
+	if hop.FromIP != "" && hop.FromIP != strings.Trim(hop.From, "[]") {
+		from += " [" + hop.FromIP + "]"
+	}
+	line := fmt.Sprintf("  %d. %s -> %s", hop.Hop, from, valueOr(hop.By, "?"))
+	if hop.With != "" {
+		line += " with " + hop.With
+	}
+	if hop.TLS {
+		line += ", TLS"
+	}
+	line += "\n     "
+	if hop.Timestamp != nil {
+		line += hop.Timestamp.UTC().Format("2006-01-02 15:04:05 UTC")
+	} else {
+		line += "no timestamp"
+	}
+	if hop.DelaySeconds != nil {
+		line += " (" + formatSeconds(*hop.DelaySeconds) + ")"
+	}
+	if hop.ClockSkew {
+		line += " clock skew"
+	}
+	return line + "\n"
+}
+
+// formatSeconds formats a signed delay, e.g. +1m30s or -5s
+func formatSeconds(seconds float64) string {
+	delay := time.Duration(seconds * float64(time.Second)).Round(time.Second)
+	if delay < 0 {
+		return delay.String()
+	}
+	return "+" + delay.String()
+}
+
+// formatAuthResultsEntries formats method results as method=result (properties)
+func formatAuthResultsEntries(results []AuthResultsEntry) string {
+	if len(results) == 0 {
+		return "none"
+	}
+	parts := make([]string, 0, len(results))
+	for _, result := range results {
+		part := result.Method + "=" + result.Result
+		var properties []string
+		for name, value := range result.Properties {
+			properties = append(properties, name+"="+value)
+		}
+		sort.Strings(properties)
+		if len(properties) > 0 {
+			part += " (" + strings.Join(properties, " ") + ")"
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// valueOr returns a value, or a placeholder when it is empty
+func valueOr(value, placeholder string) string {
+	if value == "" {
+		return placeholder
+	}
+	return value
+}
@@ -3,6 +3,8 @@ package emailauth
 
 import (
 	"fmt"
+	"strings"
+	"time"
 )
 
 // AuthResult represents the result of email authentication checks (SPF, DKIM, DMARC)
@@ -41,10 +43,75 @@ type DKIMResult struct {
 	Records map[string]string
 	// Whether the DKIM records are valid
 	IsValid bool
+	// Map of selector to the decoded key and its problems
+	Keys map[string]*DKIMKey
 	// Error message if any
 	Error string
 }
 
+// DKIMKey represents the public key published by a DKIM selector
+type DKIMKey struct {
+	// Key type (k=): rsa or ed25519
+	KeyType string
+	// Size of the public key in bits, 0 when it cannot be decoded
+	KeyBits int
+	// strong, weak (RSA under 2048 bits) or failing (RSA under 1024 bits, revoked or malformed)
+	Strength string
+	// Hash algorithms allowed by h=, empty when unrestricted
+	HashAlgorithms []string
+	// Services allowed by s=, empty when unrestricted
+	Services []string
+	// Empty p=
+	Revoked bool
+	// t=y
+	Testing bool
+	// Problems found in the key record
+	Findings []DKIMFinding
+}
+
+// DKIMFinding represents a problem found in a DKIM key record
+type DKIMFinding struct {
+	// critical, warning or info
+	Severity string
+	// Stable identifier, e.g. dkim-key-weak
+	Code    string
+	Message string
+}
+
+// DKIMDiscovery represents the DKIM selectors found by probing a domain
+type DKIMDiscovery struct {
+	// Domain that was probed
+	Domain string
+	// Sending services detected from the MX and SPF records
+	Providers []string
+	// Number of selectors tried
+	Tried int
+	// Whether every selector answers with the same wildcard record, which is not reported as a hit
+	Wildcard       bool
+	WildcardRecord string
+	// Selectors that publish a key, custom and provider guesses first
+	Selectors []DKIMSelector
+	// Error message if any
+	Error string
+}
+
+// DKIMSelector represents a DKIM selector found to publish a key
+type DKIMSelector struct {
+	Selector string
+	Record   string
+	// Target when the selector is delegated with a CNAME
+	CNAME string
+	// Why the selector was tried: custom, provider, dictionary or dated
+	Source string
+	// Sending service the key belongs to, and how it was identified: cname, mx/spf or selector
+	Provider    string
+	Attribution string
+	// Decoded key type, size and strength
+	KeyType     string
+	KeyBits     int
+	KeyStrength string
+}
+
 // DMARCResult represents the result of a DMARC check
 type DMARCResult struct {
 	// Whether the domain has a DMARC record
@@ -59,10 +126,245 @@ type DMARCResult struct {
 	SubdomainPolicy string
 	// Percentage of messages to which the policy applies
 	Percentage int
+	// Policy for non-existent subdomains (np=) and public suffix flag (psd=)
+	NonexistentPolicy string
+	PSD               string
+	// DKIM and SPF alignment modes: r (relaxed) or s (strict)
+	AlignmentDKIM string
+	AlignmentSPF  string
+	// Failure reporting options (fo=), report formats (rf=) and aggregate report interval in seconds (ri=)
+	FailureOptions []string
+	ReportFormats  []string
+	ReportInterval int
+	// Aggregate (rua=) and failure (ruf=) report destinations
+	AggregateReports []DMARCReportURI
+	FailureReports   []DMARCReportURI
+	// Tags that are not defined by DMARC, ignored by receivers
+	UnknownTags []string
+	// Invalid tags of the record
+	TagErrors []DMARCTagError
+	// Organizational domain of the checked domain, from the Public Suffix List
+	OrganizationalDomain string
+	// Domain whose record applies, and whether it is inherited from a parent domain
+	PolicyDomain string
+	Inherited    bool
+	// Policy applied to the checked domain, the tag it comes from (p, sp or np) and why
+	AppliedPolicy string
+	AppliedTag    string
+	PolicyReason  string
+	// DNS queries of the policy discovery
+	Lookups []DMARCLookup
 	// Error message if any
 	Error string
 }
 
+// DMARCLookup represents one DNS query of the DMARC policy discovery
+type DMARCLookup struct {
+	Name string
+	// record, none, multiple or error
+	Result string
+	Record string
+}
+
+// DMARCDiscovery represents the DMARC record that applies to a domain, found with the DMARCbis tree walk
+type DMARCDiscovery struct {
+	Domain               string
+	OrganizationalDomain string
+	PolicyDomain         string
+	Record               string
+	Inherited            bool
+	NonExistent          bool
+	AppliedTag           string
+	AppliedPolicy        string
+	Reason               string
+	Lookups              []DMARCLookup
+	Error                string
+}
+
+// DMARCReportURI represents a destination of DMARC reports
+type DMARCReportURI struct {
+	URI     string
+	Address string
+	Domain  string
+	// Size limit in bytes (!size suffix), 0 when unlimited
+	MaxSize int64
+	// Whether the destination is outside the organizational domain, and whether it
+	// authorizes the reports with a <domain>._report._dmarc.<destination> record
+	External            bool
+	Authorized          bool
+	AuthorizationRecord string
+	Error               string
+}
+
+// DMARCTagError represents an invalid tag of a DMARC record
+type DMARCTagError struct {
+	Tag     string
+	Value   string
+	Message string
+}
+
+// SPFEvaluation represents the result of evaluating SPF for a connecting IP (check_host, RFC 7208)
+type SPFEvaluation struct {
+	// Domain whose SPF record was evaluated
+	Domain string
+	// IP address of the SMTP client
+	IP string
+	// MAIL FROM address
+	Sender string
+	// HELO/EHLO name
+	Helo string
+	// SPF result (pass, fail, softfail, neutral, none, permerror, temperror)
+	Result string
+	// The SPF record of Domain
+	Record string
+	// Mechanism that determined the result
+	Mechanism string
+	// Number of DNS-querying terms evaluated
+	Lookups int
+	// Number of lookups that returned no answer
+	VoidLookups int
+	// exp= explanation for a fail result, or the reason for an error result
+	Explanation string
+	// Every record and term evaluated, in order
+	Trace []SPFTraceStep
+	// Error message if any
+	Error string
+}
+
+// SPFTraceStep represents one record or term evaluated during an SPF check
+type SPFTraceStep struct {
+	// Nesting depth of include and redirect
+	Depth int
+	// Domain whose record contains the term
+	Domain string
+	// The term; empty for the record itself
+	Term string
+	// Domain the term was evaluated against, after macro expansion
+	Target string
+	// record, match, no-match or an SPF result
+	Result string
+	// The record text, or details of the result
+	Detail string
+}
+
+// SPFLookupAnalysis represents the include/redirect tree of an SPF record and its DNS lookup budget
+type SPFLookupAnalysis struct {
+	// Domain that was analyzed
+	Domain string
+	// The domain's record and the records it includes or redirects to
+	Tree *SPFTreeNode
+	// DNS-querying terms in the whole tree, and the limit (10)
+	Lookups     int
+	LookupLimit int
+	// Lookups that return no answer, and the limit (2)
+	VoidLookups     int
+	VoidLookupLimit int
+	// Length of the domain's own record, all TXT strings joined
+	RecordLength int
+	// Candidate record with include, a and mx terms replaced by addresses
+	Flattened *SPFFlattening
+	// Problems found in the records of the tree
+	Findings []SPFFinding
+	// Error message if any
+	Error string
+}
+
+// SPFTreeNode represents an SPF record in the include/redirect tree
+type SPFTreeNode struct {
+	Domain string
+	Record string
+	// Characters of the record, and of each TXT character-string
+	Length        int
+	StringLengths []int
+	// Lookups in this record and below
+	Lookups     int
+	VoidLookups int
+	Terms       []SPFTermAnalysis
+	// Error message if any
+	Error string
+}
+
+// SPFTermAnalysis represents one term of an SPF record in the lookup tree
+type SPFTermAnalysis struct {
+	Term        string
+	Lookups     int
+	VoidLookups int
+	// MX names resolved by an mx term (limit 10)
+	MXHosts int
+	// Addresses the term authorizes, as CIDRs
+	Networks []string
+	// Record of an include or redirect target
+	Target *SPFTreeNode
+	Note   string
+}
+
+// SPFFlattening represents a flattened candidate SPF policy
+type SPFFlattening struct {
+	// The domain's record first, then the include: chunks
+	Records []SPFFlattenedRecord
+	// ip4 and ip6 terms across all records
+	Networks int
+	// DNS-querying terms left after flattening
+	Lookups  int
+	Warnings []string
+}
+
+// SPFFlattenedRecord represents one TXT record of a flattened SPF policy
+type SPFFlattenedRecord struct {
+	Name   string
+	Record string
+	Length int
+}
+
+// SPFFinding represents a problem found in an SPF record
+type SPFFinding struct {
+	// critical, warning or info
+	Severity string
+	// Stable identifier, e.g. spf-lookup-limit
+	Code string
+	// Domain whose record has the problem
+	Domain  string
+	Message string
+}
+
+// DKIMVerification represents the verification of the DKIM signatures of a message
+type DKIMVerification struct {
+	// One entry per DKIM-Signature header, in message order
+	Signatures []DKIMSignatureVerification
+	// Number of signatures that passed
+	Passed int
+	// Error message if any
+	Error string
+}
+
+// DKIMSignatureVerification represents the verification of one DKIM-Signature header
+type DKIMSignatureVerification struct {
+	// Signing domain (d=) and selector (s=)
+	Domain   string
+	Selector string
+	// Signing algorithm (a=) and header/body canonicalization (c=)
+	Algorithm        string
+	Canonicalization string
+	// Header fields covered by the signature (h=)
+	SignedHeaders []string
+	// Agent or user identifier (i=)
+	Identity string
+	// Signing time (t=) and expiration (x=), nil when absent
+	Timestamp  *time.Time
+	Expiration *time.Time
+	// Body length limit (l=), nil when absent, and the canonical body bytes past it
+	BodyLength        *int64
+	UnsignedBodyBytes int64
+	// Recommended headers present in the message but not signed
+	UnsignedHeaders []string
+	// Weaknesses that do not change the result, e.g. l= or header coverage gaps
+	Warnings []string
+	// pass, fail, neutral, permerror or temperror
+	Result string
+	// Why the signature did not pass
+	Reason string
+}
+
 // Service defines the core EmailAuth business logic operations
 type Service struct {
 	// You can inject dependencies here if needed
@@ -90,11 +392,12 @@ func (s *Service) ProcessSPFResult(hasRecord bool, record string, isValid bool,
 }
 
 // ProcessDKIMResult processes DKIM check results
-func (s *Service) ProcessDKIMResult(hasRecords bool, records map[string]string, isValid bool, err error) *DKIMResult {
+func (s *Service) ProcessDKIMResult(hasRecords bool, records map[string]string, keys map[string]*DKIMKey, isValid bool, err error) *DKIMResult {
 	result := &DKIMResult{
 		HasRecords: hasRecords,
 		Records:    records,
 		IsValid:    isValid,
+		Keys:       keys,
 	}
 
 	if err != nil {
@@ -122,6 +425,22 @@ func (s *Service) ProcessDMARCResult(hasRecord bool, record string, isValid bool
 	return result
 }
 
+// ProcessDMARCDiscovery records on a DMARC result which record applies to the checked domain and why
+func (s *Service) ProcessDMARCDiscovery(result *DMARCResult, discovery *DMARCDiscovery) *DMARCResult {
+	result.OrganizationalDomain = discovery.OrganizationalDomain
+	result.PolicyDomain = discovery.PolicyDomain
+	result.Inherited = discovery.Inherited
+	result.AppliedPolicy = discovery.AppliedPolicy
+	result.AppliedTag = discovery.AppliedTag
+	result.PolicyReason = discovery.Reason
+	result.Lookups = discovery.Lookups
+	if discovery.Error != "" && result.Error == "" {
+		result.Error = discovery.Error
+	}
+
+	return result
+}
+
 // ProcessAuthResult processes overall email authentication check results
 func (s *Service) ProcessAuthResult(domain string, spf *SPFResult, dkim *DKIMResult, dmarc *DMARCResult, err error) *AuthResult {
 	result := &AuthResult{
@@ -183,6 +502,12 @@ func (s *Service) FormatAuthSummary(result *AuthResult) string {
 			for selector, record := range result.DKIM.Records {
 				summary += fmt.Sprintf("  Selector: %s\n", selector)
 				summary += fmt.Sprintf("  Record: %s\n", record)
+				if key := result.DKIM.Keys[selector]; key != nil {
+					summary += fmt.Sprintf("  Key: %s\n", formatDKIMKey(key))
+					for _, finding := range key.Findings {
+						summary += fmt.Sprintf("    [%s] %s: %s\n", finding.Severity, finding.Code, finding.Message)
+					}
+				}
 			}
 
 			if result.DKIM.IsValid {
@@ -210,14 +535,43 @@ func (s *Service) FormatAuthSummary(result *AuthResult) string {
 				summary += "  Status: Invalid\n"
 			}
 
+			if result.DMARC.Inherited {
+				summary += fmt.Sprintf("  Inherited from: _dmarc.%s\n", result.DMARC.PolicyDomain)
+			}
 			summary += fmt.Sprintf("  Policy: %s\n", result.DMARC.Policy)
 			if result.DMARC.SubdomainPolicy != "" {
 				summary += fmt.Sprintf("  Subdomain Policy: %s\n", result.DMARC.SubdomainPolicy)
 			}
 			summary += fmt.Sprintf("  Percentage: %d%%\n", result.DMARC.Percentage)
+			if result.DMARC.NonexistentPolicy != "" {
+				summary += fmt.Sprintf("  Non-existent Subdomain Policy: %s\n", result.DMARC.NonexistentPolicy)
+			}
+			if result.DMARC.AlignmentDKIM != "" || result.DMARC.AlignmentSPF != "" {
+				summary += fmt.Sprintf("  Alignment: adkim=%s, aspf=%s\n", result.DMARC.AlignmentDKIM, result.DMARC.AlignmentSPF)
+			}
+			if len(result.DMARC.FailureOptions) > 0 {
+				summary += fmt.Sprintf("  Failure Options: %s, Report Interval: %ds\n", strings.Join(result.DMARC.FailureOptions, ":"), result.DMARC.ReportInterval)
+			}
+			summary += formatDMARCReportURIs("Aggregate Reports", result.DMARC.AggregateReports)
+			summary += formatDMARCReportURIs("Failure Reports", result.DMARC.FailureReports)
+			for _, tagErr := range result.DMARC.TagErrors {
+				summary += fmt.Sprintf("    [invalid] %s=%s: %s\n", tagErr.Tag, tagErr.Value, tagErr.Message)
+			}
+			if result.DMARC.AppliedTag != "" {
+				summary += fmt.Sprintf("  Applied Policy: %s (%s=)\n", result.DMARC.AppliedPolicy, result.DMARC.AppliedTag)
+			}
 		} else {
 			summary += "  No DMARC record found\n"
 		}
+		if result.DMARC.OrganizationalDomain != "" {
+			summary += fmt.Sprintf("  Organizational Domain: %s\n", result.DMARC.OrganizationalDomain)
+		}
+		if result.DMARC.PolicyReason != "" {
+			summary += fmt.Sprintf("  Reason: %s\n", result.DMARC.PolicyReason)
+		}
+		for _, lookup := range result.DMARC.Lookups {
+			summary += fmt.Sprintf("    %s: %s\n", lookup.Name, lookup.Result)
+		}
 
 		if result.DMARC.Error != "" {
 			summary += fmt.Sprintf("  Error: %s\n", result.DMARC.Error)
@@ -230,3 +584,273 @@ func (s *Service) FormatAuthSummary(result *AuthResult) string {
 
 	return summary
 }
+
+// formatDMARCReportURIs lists report destinations with their size limit and external authorization
+func formatDMARCReportURIs(label string, uris []DMARCReportURI) string {
+	if len(uris) == 0 {
+		return ""
+	}
+
+	summary := fmt.Sprintf("  %s:\n", label)
+	for _, uri := range uris {
+		line := "    " + uri.URI
+		if uri.MaxSize > 0 {
+			line += fmt.Sprintf(" (max %d bytes)", uri.MaxSize)
+		}
+		if uri.External {
+			if uri.Authorized {
+				line += " [external, authorized]"
+			} else {
+				line += " [external, NOT authorized]"
+			}
+		}
+		if uri.Error != "" {
+			line += ": " + uri.Error
+		}
+		summary += line + "\n"
+	}
+	return summary
+}
+
+// formatDKIMKey describes a DKIM key as its type, size and strength
+func formatDKIMKey(key *DKIMKey) string {
+	if key.Revoked {
+		return "revoked"
+	}
+	if key.KeyBits == 0 {
+		return fmt.Sprintf("%s (%s)", key.KeyType, key.Strength)
+	}
+	return fmt.Sprintf("%s %d bits (%s)", key.KeyType, key.KeyBits, key.Strength)
+}
+
+// FormatSPFEvaluation returns a human-readable summary of an SPF evaluation and its trace
+func (s *Service) FormatSPFEvaluation(result *SPFEvaluation) string {
+	if result == nil {
+		return "No SPF evaluation available"
+	}
+
+	summary := fmt.Sprintf("SPF evaluation of %s for %s:\n", result.Domain, result.IP)
+	if result.Sender != "" {
+		summary += fmt.Sprintf("  Sender: %s\n", result.Sender)
+	}
+	if result.Helo != "" {
+		summary += fmt.Sprintf("  HELO: %s\n", result.Helo)
+	}
+	summary += fmt.Sprintf("  Result: %s\n", result.Result)
+	if result.Mechanism != "" {
+		summary += fmt.Sprintf("  Mechanism: %s\n", result.Mechanism)
+	}
+	if result.Explanation != "" {
+		summary += fmt.Sprintf("  Explanation: %s\n", result.Explanation)
+	}
+	summary += fmt.Sprintf("  DNS lookups: %d (void: %d)\n", result.Lookups, result.VoidLookups)
+
+	if len(result.Trace) > 0 {
+		summary += "\nTrace:\n"
+		for _, step := range result.Trace {
+			indent := strings.Repeat("  ", step.Depth+1)
+			if step.Term == "" {
+				if step.Result == "record" {
+					summary += fmt.Sprintf("%s%s: %s\n", indent, step.Domain, step.Detail)
+				} else {
+					summary += fmt.Sprintf("%s%s: %s (%s)\n", indent, step.Domain, step.Result, step.Detail)
+				}
+				continue
+			}
+			line := fmt.Sprintf("%s  %s", indent, step.Term)
+			if step.Target != "" && !strings.Contains(step.Term, step.Target) {
+				line += fmt.Sprintf(" [%s]", step.Target)
+			}
+			line += " -> " + step.Result
+			if step.Detail != "" {
+				line += fmt.Sprintf(" (%s)", step.Detail)
+			}
+			summary += line + "\n"
+		}
+	}
+
+	if result.Error != "" {
+		summary += fmt.Sprintf("\nError: %s\n", result.Error)
+	}
+
+	return summary
+}
+
+// FormatSPFLookupAnalysis returns the SPF lookup tree as an indented text view, with the findings
+// and the flattened candidate record
+func (s *Service) FormatSPFLookupAnalysis(result *SPFLookupAnalysis) string {
+	if result == nil {
+		return "No SPF lookup analysis available"
+	}
+
+	summary := fmt.Sprintf("SPF lookup analysis for %s:\n", result.Domain)
+	if result.Error != "" {
+		summary += fmt.Sprintf("  Error: %s\n", result.Error)
+	}
+	summary += fmt.Sprintf("  DNS lookups: %d/%d\n", result.Lookups, result.LookupLimit)
+	summary += fmt.Sprintf("  Void lookups: %d/%d\n", result.VoidLookups, result.VoidLookupLimit)
+	summary += fmt.Sprintf("  Record length: %d\n", result.RecordLength)
+
+	if result.Tree != nil {
+		summary += "\nTree:\n" + s.FormatSPFTree(result.Tree)
+	}
+
+	if len(result.Findings) > 0 {
+		summary += "\nFindings:\n"
+		for _, finding := range result.Findings {
+			summary += fmt.Sprintf("  [%s] %s: %s\n", finding.Severity, finding.Code, finding.Message)
+		}
+	}
+
+	if result.Flattened != nil {
+		summary += fmt.Sprintf("\nFlattened candidate (%d networks, %d lookups):\n", result.Flattened.Networks, result.Flattened.Lookups)
+		for _, record := range result.Flattened.Records {
+			summary += fmt.Sprintf("  %s TXT (%d characters)\n    %s\n", record.Name, record.Length, record.Record)
+		}
+		for _, warning := range result.Flattened.Warnings {
+			summary += fmt.Sprintf("  Warning: %s\n", warning)
+		}
+	}
+
+	return summary
+}
+
+// FormatSPFTree returns an SPF record and the records below it as an indented text view
+func (s *Service) FormatSPFTree(node *SPFTreeNode) string {
+	var b strings.Builder
+	formatSPFTreeNode(&b, node, 1)
+	return b.String()
+}
+
+// formatSPFTreeNode writes one record and its terms at the given indentation level
+func formatSPFTreeNode(b *strings.Builder, node *SPFTreeNode, level int) {
+	indent := strings.Repeat("  ", level)
+	if node.Record == "" {
+		fmt.Fprintf(b, "%s%s: %s\n", indent, node.Domain, node.Error)
+		return
+	}
+
+	details := fmt.Sprintf("%d lookups, %d characters", node.Lookups, node.Length)
+	if len(node.StringLengths) > 1 {
+		details += fmt.Sprintf(" in %d strings", len(node.StringLengths))
+	}
+	if node.VoidLookups > 0 {
+		details += fmt.Sprintf(", %d void", node.VoidLookups)
+	}
+	fmt.Fprintf(b, "%s%s (%s)\n", indent, node.Domain, details)
+
+	for _, term := range node.Terms {
+		line := indent + "  " + term.Term
+		var notes []string
+		if term.Lookups > 0 {
+			notes = append(notes, fmt.Sprintf("%d lookup", term.Lookups))
+		}
+		if term.MXHosts > 0 {
+			notes = append(notes, fmt.Sprintf("%d MX hosts", term.MXHosts))
+		}
+		if term.VoidLookups > 0 {
+			notes = append(notes, "void")
+		}
+		if term.Note != "" {
+			notes = append(notes, term.Note)
+		}
+		if len(notes) > 0 {
+			line += " [" + strings.Join(notes, ", ") + "]"
+		}
+		mechanism := strings.ToLower(strings.TrimLeft(term.Term, "+-~?"))
+		if len(term.Networks) > 0 && !strings.HasPrefix(mechanism, "ip4:") && !strings.HasPrefix(mechanism, "ip6:") {
+			line += " -> " + strings.Join(term.Networks, ", ")
+		}
+		b.WriteString(line + "\n")
+		if term.Target != nil {
+			formatSPFTreeNode(b, term.Target, level+2)
+		}
+	}
+}
+
+// FormatDKIMVerification returns a human-readable summary of the DKIM signatures of a message
+func (s *Service) FormatDKIMVerification(result *DKIMVerification) string {
+	if result == nil {
+		return "No DKIM verification available"
+	}
+
+	if result.Error != "" {
+		return fmt.Sprintf("DKIM verification error: %s\n", result.Error)
+	}
+	if len(result.Signatures) == 0 {
+		return "No DKIM-Signature headers found in the message\n"
+	}
+
+	summary := fmt.Sprintf("DKIM verification: %d of %d signatures passed\n", result.Passed, len(result.Signatures))
+	for i, sig := range result.Signatures {
+		summary += fmt.Sprintf("\nSignature %d: %s\n", i+1, strings.ToUpper(sig.Result))
+		summary += fmt.Sprintf("  Domain: %s (selector %s)\n", sig.Domain, sig.Selector)
+		summary += fmt.Sprintf("  Algorithm: %s, canonicalization %s\n", sig.Algorithm, sig.Canonicalization)
+		if sig.Identity != "" {
+			summary += fmt.Sprintf("  Identity: %s\n", sig.Identity)
+		}
+		if len(sig.SignedHeaders) > 0 {
+			summary += fmt.Sprintf("  Signed headers: %s\n", strings.Join(sig.SignedHeaders, ", "))
+		}
+		if sig.Timestamp != nil {
+			summary += fmt.Sprintf("  Signed at: %s\n", sig.Timestamp.Format(time.RFC3339))
+		}
+		if sig.Expiration != nil {
+			summary += fmt.Sprintf("  Expires: %s\n", sig.Expiration.Format(time.RFC3339))
+		}
+		if sig.BodyLength != nil {
+			summary += fmt.Sprintf("  Body length: l=%d (%d bytes unsigned)\n", *sig.BodyLength, sig.UnsignedBodyBytes)
+		}
+		if sig.Reason != "" {
+			summary += fmt.Sprintf("  Reason: %s\n", sig.Reason)
+		}
+		for _, warning := range sig.Warnings {
+			summary += fmt.Sprintf("  Warning: %s\n", warning)
+		}
+	}
+
+	return summary
+}
+
+// FormatDKIMDiscovery returns a human-readable summary of the DKIM selectors found for a domain
+func (s *Service) FormatDKIMDiscovery(result *DKIMDiscovery) string {
+	if result == nil {
+		return "No DKIM discovery available"
+	}
+
+	summary := fmt.Sprintf("DKIM selector discovery for %s:\n", result.Domain)
+	if len(result.Providers) > 0 {
+		summary += fmt.Sprintf("  Providers (MX/SPF): %s\n", strings.Join(result.Providers, ", "))
+	}
+	summary += fmt.Sprintf("  Selectors tried: %d\n", result.Tried)
+	if result.Wildcard {
+		summary += fmt.Sprintf("  Wildcard record: %s\n", result.WildcardRecord)
+	}
+	if result.Error != "" {
+		summary += fmt.Sprintf("  Error: %s\n", result.Error)
+	}
+
+	if len(result.Selectors) == 0 {
+		summary += "\nNo DKIM selectors found\n"
+		return summary
+	}
+
+	summary += fmt.Sprintf("\nSelectors found (%d):\n", len(result.Selectors))
+	for _, selector := range result.Selectors {
+		line := fmt.Sprintf("  %s (%s)", selector.Selector, selector.Source)
+		if selector.Provider != "" {
+			line += fmt.Sprintf(" - %s [%s]", selector.Provider, selector.Attribution)
+		}
+		summary += line + "\n"
+		if selector.CNAME != "" {
+			summary += fmt.Sprintf("    CNAME: %s\n", selector.CNAME)
+		}
+		if selector.KeyBits > 0 {
+			summary += fmt.Sprintf("    Key: %s %d bits (%s)\n", selector.KeyType, selector.KeyBits, selector.KeyStrength)
+		} else if selector.KeyStrength != "" {
+			summary += fmt.Sprintf("    Key: %s (%s)\n", selector.KeyType, selector.KeyStrength)
+		}
+	}
+
+	return summary
+}
@@ -0,0 +1,334 @@
+// Package mailaccess contains the core domain logic for IMAP and POP3 diagnostics
+package mailaccess
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Protocols
+const (
+	ProtocolIMAP = "imap"
+	ProtocolPOP3 = "pop3"
+)
+
+// certificateExpiryWarning is how close to its expiry a certificate is reported
+const certificateExpiryWarning = 30 * 24 * time.Hour
+
+// DefaultPorts returns the cleartext (STARTTLS) and implicit TLS ports of a protocol
+func DefaultPorts(protocol string) []int {
+	switch protocol {
+	case ProtocolIMAP:
+		return []int{143, 993}
+	case ProtocolPOP3:
+		return []int{110, 995}
+	}
+	return nil
+}
+
+// CheckRequest describes an IMAP or POP3 server check
+type CheckRequest struct {
+	// Protocol to check (imap, pop3)
+	Protocol string
+	// Server to check
+	Host string
+	// Port to check; defaults to the protocol's STARTTLS port
+	Port int
+	// Credentials for the optional login test; only sent over TLS
+	Username string
+	Password string
+	// Timeout for the connection and each command
+	Timeout time.Duration
+}
+
+// Certificate describes the certificate presented by a server
+type Certificate struct {
+	Subject            string
+	Issuer             string
+	DNSNames           []string
+	SerialNumber       string
+	NotBefore          time.Time
+	NotAfter           time.Time
+	SignatureAlgorithm string
+	PublicKeyAlgorithm string
+	PublicKeyBits      int
+	SelfSigned         bool
+	// Whether the certificate is valid for the host name
+	HostnameMatch bool
+	// Whether the chain verifies against the trusted roots, and why not
+	Trusted     bool
+	VerifyError string
+	// Number of certificates sent by the server
+	ChainLength int
+}
+
+// TranscriptEntry represents a single event of an IMAP or POP3 session
+type TranscriptEntry struct {
+	// When the event happened
+	Timestamp time.Time
+	// Time since the session started
+	Elapsed time.Duration
+	// Direction of the event (client, server, tls, info)
+	Direction string
+	// Command or response line as sent on the wire (credentials redacted)
+	Line string
+	// For server responses, time since the command was sent
+	Duration time.Duration
+}
+
+// CheckResult represents the result of an IMAP or POP3 server check
+type CheckResult struct {
+	// Protocol, server and port that were checked
+	Protocol string
+	Host     string
+	Port     int
+	// Whether TLS was negotiated before the greeting (993, 995)
+	ImplicitTLS bool
+	// Whether the connection was successful, and how long it took
+	Connected bool
+	Latency   time.Duration
+	// Greeting sent by the server
+	Banner string
+	// Capabilities before STARTTLS/STLS (or on the implicit TLS session), and after it
+	Capabilities    []string
+	TLSCapabilities []string
+	// Whether STARTTLS (IMAP) or STLS (POP3) is advertised, and why the upgrade failed
+	STARTTLS      bool
+	STARTTLSError string
+	// Negotiated TLS parameters and certificate, if the session was encrypted
+	TLS         bool
+	TLSVersion  string
+	TLSCipher   string
+	Certificate *Certificate
+	// Login methods offered on the unencrypted session
+	PlaintextAuth []string
+	// SASL mechanisms offered on the most secure session
+	AuthMechanisms []string
+	// Whether IMAP LOGIN is disabled before TLS
+	LoginDisabled bool
+	// Outcome of the optional login test
+	LoginTested    bool
+	LoginSucceeded bool
+	LoginMethod    string
+	LoginResponse  string
+	// Human-readable observations
+	Findings []string
+	// Full session transcript
+	Transcript []TranscriptEntry
+	// Error message if any
+	Error string
+}
+
+// HostCheckResult represents the checks of every default port of a protocol on a host
+type HostCheckResult struct {
+	Protocol string
+	Host     string
+	Results  []*CheckResult
+}
+
+// Service defines the core IMAP and POP3 business logic operations
+type Service struct{}
+
+// NewService creates a new mail access service
+func NewService() *Service {
+	return &Service{}
+}
+
+// ProtocolName returns the display name of a protocol
+func ProtocolName(protocol string) string {
+	return strings.ToUpper(protocol)
+}
+
+// startTLSCommand returns the name of the TLS upgrade command of a protocol
+func startTLSCommand(protocol string) string {
+	if protocol == ProtocolPOP3 {
+		return "STLS"
+	}
+	return "STARTTLS"
+}
+
+// ProcessCheckResult records the error of a check and derives its findings
+func (s *Service) ProcessCheckResult(result *CheckResult, err error, now time.Time) *CheckResult {
+	if err != nil && result.Error == "" {
+		result.Error = err.Error()
+	}
+
+	result.Findings = nil
+	if !result.Connected {
+		return result
+	}
+
+	command := startTLSCommand(result.Protocol)
+	switch {
+	case result.ImplicitTLS && !result.TLS:
+		result.Findings = append(result.Findings, "TLS handshake failed on the implicit TLS port")
+	case !result.ImplicitTLS && !result.STARTTLS && result.Banner != "":
+		result.Findings = append(result.Findings, fmt.Sprintf("%s is not offered: credentials and mail travel in cleartext", command))
+	case result.STARTTLSError != "":
+		result.Findings = append(result.Findings, fmt.Sprintf("%s is advertised but failed: %s", command, result.STARTTLSError))
+	}
+	if len(result.PlaintextAuth) > 0 {
+		result.Findings = append(result.Findings, fmt.Sprintf("Login is offered before TLS (%s): clients may send cleartext passwords", strings.Join(result.PlaintextAuth, ", ")))
+	}
+	if result.TLS && (result.TLSVersion == "TLS 1.0" || result.TLSVersion == "TLS 1.1") {
+		result.Findings = append(result.Findings, fmt.Sprintf("Outdated TLS version negotiated: %s", result.TLSVersion))
+	}
+
+	if cert := result.Certificate; cert != nil {
+		switch {
+		case now.After(cert.NotAfter):
+			result.Findings = append(result.Findings, fmt.Sprintf("Certificate expired on %s", cert.NotAfter.Format("2006-01-02")))
+		case now.Before(cert.NotBefore):
+			result.Findings = append(result.Findings, fmt.Sprintf("Certificate is not valid before %s", cert.NotBefore.Format("2006-01-02")))
+		case cert.NotAfter.Sub(now) < certificateExpiryWarning:
+			result.Findings = append(result.Findings, fmt.Sprintf("Certificate expires in %d days", int(cert.NotAfter.Sub(now).Hours()/24)))
+		}
+		if !cert.HostnameMatch {
+			result.Findings = append(result.Findings, fmt.Sprintf("Certificate is not valid for %s", result.Host))
+		}
+		if cert.SelfSigned {
+			result.Findings = append(result.Findings, "Certificate is self-signed")
+		} else if !cert.Trusted && cert.HostnameMatch {
+			result.Findings = append(result.Findings, "Certificate chain is not trusted: "+cert.VerifyError)
+		}
+	}
+
+	tlsCapabilities := result.TLSCapabilities
+	if result.ImplicitTLS {
+		tlsCapabilities = result.Capabilities
+	}
+	if result.Protocol == ProtocolIMAP && result.TLS && len(result.AuthMechanisms) == 0 && containsFold(tlsCapabilities, "LOGINDISABLED") {
+		result.Findings = append(result.Findings, "No login method is offered after TLS")
+	}
+	if result.LoginTested && !result.LoginSucceeded {
+		result.Findings = append(result.Findings, fmt.Sprintf("Login failed with %s: %s", result.LoginMethod, result.LoginResponse))
+	}
+
+	return result
+}
+
+// ProcessHostCheckResult groups the checks of a host in port order
+func (s *Service) ProcessHostCheckResult(protocol, host string, results []*CheckResult) *HostCheckResult {
+	return &HostCheckResult{
+		Protocol: protocol,
+		Host:     host,
+		Results:  results,
+	}
+}
+
+// FormatCheckSummary returns a human-readable summary of an IMAP or POP3 check
+func (s *Service) FormatCheckSummary(result *CheckResult) string {
+	if result == nil {
+		return "No result available"
+	}
+
+	summary := fmt.Sprintf("%s check for %s port %d", ProtocolName(result.Protocol), result.Host, result.Port)
+	if result.ImplicitTLS {
+		summary += " (implicit TLS)"
+	}
+	summary += "\n"
+	if !result.Connected {
+		return summary + fmt.Sprintf("  Connection failed: %s\n", result.Error)
+	}
+
+	summary += fmt.Sprintf("  Connected in %s\n", result.Latency.Round(time.Millisecond))
+	summary += fmt.Sprintf("  Banner: %s\n", result.Banner)
+	if len(result.Capabilities) > 0 {
+		summary += fmt.Sprintf("  Capabilities: %s\n", strings.Join(result.Capabilities, ", "))
+	}
+	if !result.ImplicitTLS {
+		summary += fmt.Sprintf("  %s: %t\n", startTLSCommand(result.Protocol), result.STARTTLS)
+	}
+	if len(result.TLSCapabilities) > 0 {
+		summary += fmt.Sprintf("  Capabilities after TLS: %s\n", strings.Join(result.TLSCapabilities, ", "))
+	}
+	if result.TLS {
+		summary += fmt.Sprintf("  TLS: %s, %s\n", result.TLSVersion, result.TLSCipher)
+	}
+	if cert := result.Certificate; cert != nil {
+		summary += fmt.Sprintf("  Certificate: %s (issuer %s)\n", cert.Subject, cert.Issuer)
+		if len(cert.DNSNames) > 0 {
+			summary += fmt.Sprintf("    Names: %s\n", strings.Join(cert.DNSNames, ", "))
+		}
+		summary += fmt.Sprintf("    Valid: %s to %s\n", cert.NotBefore.Format("2006-01-02"), cert.NotAfter.Format("2006-01-02"))
+		summary += fmt.Sprintf("    Key: %s %d bits, signature %s\n", cert.PublicKeyAlgorithm, cert.PublicKeyBits, cert.SignatureAlgorithm)
+		summary += fmt.Sprintf("    Trusted: %t, matches host: %t\n", cert.Trusted, cert.HostnameMatch)
+	}
+	if result.Protocol == ProtocolIMAP && !result.ImplicitTLS {
+		summary += fmt.Sprintf("  LOGINDISABLED before TLS: %t\n", result.LoginDisabled)
+	}
+	if len(result.PlaintextAuth) > 0 {
+		summary += fmt.Sprintf("  Login before TLS: %s\n", strings.Join(result.PlaintextAuth, ", "))
+	}
+	if len(result.AuthMechanisms) > 0 {
+		summary += fmt.Sprintf("  SASL mechanisms: %s\n", strings.Join(result.AuthMechanisms, ", "))
+	}
+	if result.LoginTested {
+		status := "failed"
+		if result.LoginSucceeded {
+			status = "succeeded"
+		}
+		summary += fmt.Sprintf("  Login (%s): %s - %s\n", result.LoginMethod, status, result.LoginResponse)
+	} else if result.LoginResponse != "" {
+		summary += fmt.Sprintf("  Login: %s\n", result.LoginResponse)
+	}
+	for _, finding := range result.Findings {
+		summary += fmt.Sprintf("  ! %s\n", finding)
+	}
+	if result.Error != "" {
+		summary += fmt.Sprintf("  Error: %s\n", result.Error)
+	}
+	if len(result.Transcript) > 0 {
+		summary += "  Transcript:\n" + FormatTranscript(result.Transcript, "    ")
+	}
+
+	return summary
+}
+
+// FormatHostCheckSummary returns a human-readable summary of the checks of a host
+func (s *Service) FormatHostCheckSummary(result *HostCheckResult) string {
+	if result == nil {
+		return "No result available"
+	}
+
+	summaries := make([]string, 0, len(result.Results))
+	for _, check := range result.Results {
+		summaries = append(summaries, s.FormatCheckSummary(check))
+	}
+	return strings.Join(summaries, "\n")
+}
+
+// FormatTranscript renders transcript entries as text, one indented line per entry
+func FormatTranscript(entries []TranscriptEntry, indent string) string {
+	var b strings.Builder
+	for _, entry := range entries {
+		prefix := "  "
+		switch entry.Direction {
+		case "client":
+			prefix = "C:"
+		case "server":
+			prefix = "S:"
+		case "tls":
+			prefix = "**"
+		case "info":
+			prefix = "--"
+		}
+		fmt.Fprintf(&b, "%s[%8s] %s %s", indent, entry.Elapsed.Round(time.Millisecond), prefix, entry.Line)
+		if entry.Direction == "server" && entry.Duration > 0 {
+			fmt.Fprintf(&b, " (%s)", entry.Duration.Round(time.Millisecond))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// containsFold reports whether list contains value, ignoring case
+func containsFold(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,115 @@
+package smtp
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestCreateMatrixCell tests that a cell takes the MX host, address family and session of one probe
+func TestCreateMatrixCell(t *testing.T) {
+	s := NewService()
+	mx := MXHost{Host: "mx1.example.com", Preference: 10}
+
+	session := &SessionInfo{
+		Connected:        true,
+		Latency:          42 * time.Millisecond,
+		Banner:           "220 mx1.example.com ESMTP",
+		SupportsStartTLS: true,
+		TLSVersion:       "TLS 1.3",
+		TLSCipher:        "TLS_AES_128_GCM_SHA256",
+	}
+	cell := s.CreateMatrixCell(mx, "192.0.2.25", 25, session, nil)
+	if cell.MXHost != "mx1.example.com" || cell.Preference != 10 || cell.Address != "192.0.2.25" || cell.Port != 25 {
+		t.Errorf("CreateMatrixCell() = %+v", cell)
+	}
+	if cell.Family != "ipv4" || cell.ImplicitTLS {
+		t.Errorf("Family = %q, ImplicitTLS = %t, want ipv4, false", cell.Family, cell.ImplicitTLS)
+	}
+	if !cell.Connected || cell.Latency != session.Latency || cell.TLSVersion != "TLS 1.3" || cell.Banner != session.Banner || cell.Error != "" {
+		t.Errorf("CreateMatrixCell() did not take the session: %+v", cell)
+	}
+
+	cell = s.CreateMatrixCell(mx, "2001:db8::25", 465, &SessionInfo{}, errors.New("failed to connect: i/o timeout"))
+	if cell.Family != "ipv6" || !cell.ImplicitTLS {
+		t.Errorf("Family = %q, ImplicitTLS = %t, want ipv6, true", cell.Family, cell.ImplicitTLS)
+	}
+	if cell.Connected || cell.Error != "failed to connect: i/o timeout" {
+		t.Errorf("Connected = %t, Error = %q", cell.Connected, cell.Error)
+	}
+
+	cell = s.CreateMatrixCell(mx, "192.0.2.25", 587, nil, errors.New("refused"))
+	if cell.Connected || cell.Error != "refused" {
+		t.Errorf("CreateMatrixCell() with no session = %+v", cell)
+	}
+}
+
+// TestFormatMatrixSummary tests the grid rows and columns, failures and resolution errors
+func TestFormatMatrixSummary(t *testing.T) {
+	s := NewService()
+	mx1 := MXHost{Host: "mx1.example.com", Preference: 10}
+	mx2 := MXHost{Host: "mx2.example.com", Preference: 20}
+	ports := []int{25, 587}
+
+	cells := []*MatrixCell{
+		s.CreateMatrixCell(mx1, "2001:db8::25", 25, &SessionInfo{Connected: true, Latency: 30 * time.Millisecond}, nil),
+		s.CreateMatrixCell(mx1, "192.0.2.25", 587, nil, errors.New("connection refused")),
+		s.CreateMatrixCell(mx1, "192.0.2.25", 25, &SessionInfo{Connected: true, Latency: 12 * time.Millisecond, SupportsStartTLS: true, TLSVersion: "TLS 1.3"}, nil),
+	}
+	result := s.ProcessMatrixResult("example.com", []MXHost{mx2, mx1}, ports, cells, map[string]string{"mx2.example.com": "no A or AAAA records"}, nil)
+
+	summary := s.FormatMatrixSummary(result)
+	lines := strings.Split(summary, "\n")
+	if len(lines) < 5 {
+		t.Fatalf("FormatMatrixSummary() = %q", summary)
+	}
+
+	header := lines[2]
+	if !strings.HasPrefix(header, "MX / address") || !strings.Contains(header, "port 25") || !strings.Contains(header, "port 587") {
+		t.Errorf("header = %q", header)
+	}
+
+	// IPv4 rows come before IPv6 rows; a port that was not probed is shown as "-"
+	wantRows := []struct {
+		label string
+		cells []string
+	}{
+		{"mx1.example.com (10) 192.0.2.25", []string{"OK 12ms TLS 1.3", "FAIL"}},
+		{"mx1.example.com (10) 2001:db8::25", []string{"OK 30ms no TLS", "-"}},
+	}
+	for i, want := range wantRows {
+		row := lines[3+i]
+		if !strings.HasPrefix(row, want.label) {
+			t.Errorf("row %d = %q, want label %q", i, row, want.label)
+			continue
+		}
+		fields := strings.Split(strings.TrimSpace(strings.TrimPrefix(row, want.label)), "  ")
+		got := []string{}
+		for _, field := range fields {
+			if field = strings.TrimSpace(field); field != "" {
+				got = append(got, field)
+			}
+		}
+		if strings.Join(got, "|") != strings.Join(want.cells, "|") {
+			t.Errorf("row %q cells = %q, want %q", want.label, got, want.cells)
+		}
+	}
+
+	for _, want := range []string{
+		"Failures:\n- mx1.example.com [192.0.2.25]:587: connection refused\n",
+		"Resolution errors:\n- mx2.example.com: no A or AAAA records\n",
+	} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("FormatMatrixSummary() is missing %q:\n%s", want, summary)
+		}
+	}
+
+	empty := s.FormatMatrixSummary(s.ProcessMatrixResult("example.net", nil, ports, nil, nil, errors.New("no MX records found for domain")))
+	if !strings.Contains(empty, "No MX records found") || !strings.Contains(empty, "Errors: no MX records found for domain") {
+		t.Errorf("FormatMatrixSummary() without MX = %q", empty)
+	}
+	if s.FormatMatrixSummary(nil) != "No SMTP matrix results available" {
+		t.Error("FormatMatrixSummary(nil) did not report missing results")
+	}
+}
@@ -3,6 +3,8 @@ package smtp
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 )
 
@@ -12,7 +14,7 @@ type SMTPResult struct {
 	Domain string
 	// The MX records for the domain
 	MXRecords []string
-	// Connection results for each MX server
+	// Connection results for each MX server and port, keyed by host:port
 	ConnectionResults map[string]*ConnectionResult
 	// Response code and banner from SMTP server
 	Banner string
@@ -34,6 +36,354 @@ type ConnectionResult struct {
 	AuthMethods []string
 	// Banner message
 	Banner string
+	// Port that was tested
+	Port int
+	// Negotiated TLS version and cipher suite, if the session was encrypted
+	TLSVersion string
+	TLSCipher  string
+	// Full session transcript
+	Transcript []TranscriptEntry
+	// Greeting and reply behavior of the server
+	BannerAnalysis *BannerAnalysis
+	// STARTTLS injection and downgrade checks
+	STARTTLSSecurity *STARTTLSSecurity
+	// Error message if any
+	Error string
+}
+
+// STARTTLSSecurity describes whether STARTTLS on a server can be bypassed or abused
+type STARTTLSSecurity struct {
+	// Whether STARTTLS is advertised and a TLS handshake completes after it
+	Advertised         bool
+	HandshakeSucceeded bool
+	HandshakeError     string
+	TLSVersion         string
+	// Whether a command pipelined with STARTTLS was answered inside the encrypted session
+	InjectionTested     bool
+	InjectionVulnerable bool
+	InjectionResponse   string
+	// Human-readable observations
+	Findings []string
+	// Full session transcript
+	Transcript []TranscriptEntry
+	// Error message if any
+	Error string
+}
+
+// BannerAnalysis describes how an SMTP server greets and answers a client
+type BannerAnalysis struct {
+	// Greeting text and reply code
+	Banner       string
+	GreetingCode int
+	// Time between the connection and the greeting
+	GreetingDelay time.Duration
+	// Whether the greeting spans several lines
+	MultiLineGreeting bool
+	// Whether the greeting was deliberately delayed
+	PreGreetPause bool
+	// Whether clients sending commands before the greeting are rejected
+	EarlyTalkerTested   bool
+	EarlyTalkerRejected bool
+	// Average command response time and whether it indicates tarpitting
+	AverageResponseTime time.Duration
+	Tarpitting          bool
+	// Reply to RCPT TO:<postmaster@domain>
+	RcptCode     int
+	RcptResponse string
+	// Whether the recipient was temporarily refused, and whether a retry confirmed greylisting
+	GreylistSuspected bool
+	Greylisting       bool
+	RetryCode         int
+	// Hostname announced in the banner and how it compares with the PTR and MX names
+	BannerHostname string
+	ServerIP       string
+	PTRNames       []string
+	MatchesPTR     bool
+	MatchesMX      bool
+	// Software identified in the banner and whether its version is disclosed
+	Software        string
+	SoftwareVersion string
+	LeaksVersion    bool
+	// Human-readable observations
+	Findings []string
+	// Error message if any
+	Error string
+}
+
+// TranscriptEntry represents a single event of an SMTP session
+type TranscriptEntry struct {
+	// When the event happened
+	Timestamp time.Time
+	// Time since the session started
+	Elapsed time.Duration
+	// Direction of the event (client, server, tls, info)
+	Direction string
+	// Command or reply line as sent on the wire (credentials redacted)
+	Line string
+	// Reply code and RFC 3463 enhanced status code for server replies
+	Code         int
+	EnhancedCode string
+	// For server replies, time since the command was sent
+	Duration time.Duration
+}
+
+// SessionInfo describes what was learned from a single SMTP session
+type SessionInfo struct {
+	// Whether the TCP connection was established
+	Connected bool
+	// Time to establish the connection
+	Latency time.Duration
+	// Greeting of the server
+	Banner string
+	// Whether STARTTLS was advertised
+	SupportsStartTLS bool
+	// Authentication mechanisms advertised
+	AuthMethods []string
+	// Negotiated TLS version and cipher suite, if the session was encrypted
+	TLSVersion string
+	TLSCipher  string
+	// Full session transcript
+	Transcript []TranscriptEntry
+}
+
+// MXHost represents a mail exchanger and its preference
+type MXHost struct {
+	// Host name of the mail exchanger
+	Host string
+	// MX preference (lower is preferred)
+	Preference uint16
+}
+
+// MatrixCell represents one probe of the MX matrix: a single address of an MX host on a single port
+type MatrixCell struct {
+	// MX host name and preference
+	MXHost     string
+	Preference uint16
+	// Address that was dialed
+	Address string
+	// Address family (ipv4 or ipv6)
+	Family string
+	// Port that was tested
+	Port int
+	// Whether TLS was negotiated before the greeting (port 465)
+	ImplicitTLS bool
+	// Whether the connection and greeting succeeded
+	Connected bool
+	// Connection latency
+	Latency time.Duration
+	// Whether STARTTLS was advertised
+	SupportsStartTLS bool
+	// Negotiated TLS version and cipher suite, if the session was encrypted
+	TLSVersion string
+	TLSCipher  string
+	// Greeting of the server
+	Banner string
+	// Error message if any
+	Error string
+}
+
+// MatrixResult represents the result of testing every MX address on every port
+type MatrixResult struct {
+	// The domain that was checked
+	Domain string
+	// MX hosts sorted by preference
+	MXHosts []MXHost
+	// Ports that were tested
+	Ports []int
+	// One cell per MX address and port, in MX preference, address and port order
+	Cells []*MatrixCell
+	// Errors resolving individual MX hosts, keyed by host name
+	ResolveErrors map[string]string
+	// Error message if any
+	Error string
+}
+
+// RelayTestRequest describes an open relay test to perform
+type RelayTestRequest struct {
+	// SMTP server to test
+	Host string
+	// SMTP port (465 uses implicit TLS)
+	Port int
+	// External sender address used for the probes
+	FromAddress string
+	// External recipient the server must not relay to
+	ToAddress string
+	// Domain the server receives mail for; the organizational domain of Host when empty
+	TargetDomain string
+	// Optional credentials; when set the tests run authenticated
+	Username string
+	Password string
+	// Timeout for the connection and each command
+	Timeout time.Duration
+}
+
+// RelayTestCase represents a single probe of the open relay test battery
+type RelayTestCase struct {
+	// Short identifier of the probe (e.g. percent-hack)
+	Name string
+	// Human-readable description of the probe
+	Description string
+	// Reverse-path sent in MAIL FROM
+	MailFrom string
+	// Forward-path sent in RCPT TO
+	RcptTo string
+	// Whether the server accepted the recipient
+	Accepted bool
+	// Verdict of the probe (relayed, rejected, tempfail, error)
+	Verdict string
+	// Final reply code and text
+	ResponseCode int
+	Response     string
+	// Classification of a refused or deferred reply
+	Explanation *ReplyExplanation
+	// Commands and replies exchanged for this probe
+	Transcript []TranscriptEntry
+	// Error message if any
+	Error string
+}
+
+// RelayTestResult represents the result of an open relay test battery
+type RelayTestResult struct {
+	// Server that was tested
+	Host string
+	// Port that was tested
+	Port int
+	// Greeting of the server
+	Banner string
+	// Whether any probe was relayed without authentication
+	IsOpenRelay bool
+	// Whether the server asked for authentication
+	AuthRequired bool
+	// Whether the tests ran on an authenticated session
+	Authenticated bool
+	// Individual probe results
+	Tests []*RelayTestCase
+	// Error message if any
+	Error string
+}
+
+// SubmissionTestRequest describes an authenticated submission test to perform
+type SubmissionTestRequest struct {
+	// Submission server to test
+	Host string
+	// Submission port (587 with STARTTLS, 465 with implicit TLS)
+	Port int
+	// Credentials; for XOAUTH2 the password is the access token
+	Username string
+	Password string
+	// SASL mechanism to use (PLAIN, LOGIN, CRAM-MD5, XOAUTH2); empty picks the best offered
+	Mechanism string
+	// Whether credentials may be sent when the session could not be encrypted
+	AllowPlaintextAuth bool
+	// Envelope and header addresses of the test message
+	FromAddress string
+	ToAddress   string
+	// Whether to send a generated test message
+	SendMessage bool
+	// Timeout for the connection and each command
+	Timeout time.Duration
+}
+
+// SubmissionStage represents the outcome of one stage of a submission test
+type SubmissionStage struct {
+	// Stage name (connect, ehlo, tls, auth, mail, rcpt, data)
+	Name string
+	// Whether the stage succeeded
+	Success bool
+	// Whether the stage did not run because an earlier stage failed or it was not requested
+	Skipped bool
+	// Server reply for the stage
+	ResponseCode int
+	EnhancedCode string
+	Response     string
+	// Classification of a refused or deferred reply
+	Explanation *ReplyExplanation
+	// Time taken by the stage
+	Duration time.Duration
+	// Error message if any
+	Error string
+}
+
+// SubmissionTestResult represents the result of an authenticated submission test
+type SubmissionTestResult struct {
+	// Server that was tested
+	Host string
+	// Port that was tested
+	Port int
+	// Whether TLS was negotiated before the greeting
+	ImplicitTLS bool
+	// Negotiated TLS version, if the session was encrypted
+	TLSVersion string
+	// SASL mechanisms offered by the server
+	AuthMechanisms []string
+	// Mechanism used to authenticate
+	Mechanism string
+	// Whether authentication succeeded
+	Authenticated bool
+	// Whether the test message was accepted
+	MessageSent bool
+	// Message-ID and tracking ID of the test message
+	MessageID  string
+	TrackingID string
+	// Outcome of every stage, in order
+	Stages []*SubmissionStage
+	// Full session transcript
+	Transcript []TranscriptEntry
+	// Error message if any
+	Error string
+}
+
+// SmugglingTestRequest describes an SMTP smuggling probe to perform
+type SmugglingTestRequest struct {
+	// Domain whose postmaster receives the probe messages
+	Domain string
+	// Mail exchanger to probe, which must be an MX of the domain; empty uses the most preferred MX
+	Host string
+	// SMTP port (default 25)
+	Port int
+	// Envelope sender; empty uses the null reverse-path
+	FromAddress string
+	// Explicit opt-in: a vulnerable server delivers probe messages to the postmaster
+	Confirm bool
+	// Timeout for the connection and each command
+	Timeout time.Duration
+}
+
+// SmugglingSequenceResult represents how a server handled one non-standard end-of-data sequence
+type SmugglingSequenceResult struct {
+	// Name of the sequence (e.g. <LF>.<LF>)
+	Name string
+	// Outcome (end-of-data, rejected, ignored, error)
+	Outcome string
+	// Server reply after the sequence, if any
+	Code     int
+	Response string
+	// Tracking ID of the probe message, when the server accepted it
+	TrackingID string
+	// Error message if any
+	Error string
+}
+
+// SmugglingTestResult represents the result of an SMTP smuggling probe
+type SmugglingTestResult struct {
+	// Domain and server that were probed
+	Domain string
+	Host   string
+	Port   int
+	// Only recipient of the probe messages
+	Recipient string
+	// How a command terminated by a bare LF was handled (accepted, rejected, ignored)
+	BareLFCommand         string
+	BareLFCommandCode     int
+	BareLFCommandResponse string
+	// Outcome for each sequence
+	Sequences []*SmugglingSequenceResult
+	// Whether any non-standard sequence ended the message
+	Vulnerable bool
+	// Human-readable observations
+	Findings []string
+	// Transcript of every probe session
+	Transcript []TranscriptEntry
 	// Error message if any
 	Error string
 }
@@ -65,14 +415,20 @@ func (s *Service) ProcessSMTPResult(domain string, mxRecords []string, connectio
 }
 
 // CreateConnectionResult creates a connection result for a specific server
-func (s *Service) CreateConnectionResult(server string, connected bool, latency time.Duration, supportsStartTLS bool, authMethods []string, banner string, err error) *ConnectionResult {
+func (s *Service) CreateConnectionResult(server string, port int, session *SessionInfo, err error) *ConnectionResult {
 	result := &ConnectionResult{
-		Server:           server,
-		Connected:        connected,
-		Latency:          latency,
-		SupportsStartTLS: supportsStartTLS,
-		AuthMethods:      authMethods,
-		Banner:           banner,
+		Server: server,
+		Port:   port,
+	}
+	if session != nil {
+		result.Connected = session.Connected
+		result.Latency = session.Latency
+		result.SupportsStartTLS = session.SupportsStartTLS
+		result.AuthMethods = session.AuthMethods
+		result.Banner = session.Banner
+		result.TLSVersion = session.TLSVersion
+		result.TLSCipher = session.TLSCipher
+		result.Transcript = session.Transcript
 	}
 
 	if err != nil {
@@ -101,7 +457,13 @@ func (s *Service) FormatSMTPSummary(result *SMTPResult) string {
 
 	if len(result.ConnectionResults) > 0 {
 		summary += "\nConnection results:\n"
-		for server, connResult := range result.ConnectionResults {
+		servers := make([]string, 0, len(result.ConnectionResults))
+		for server := range result.ConnectionResults {
+			servers = append(servers, server)
+		}
+		sort.Strings(servers)
+		for _, server := range servers {
+			connResult := result.ConnectionResults[server]
 			summary += fmt.Sprintf("- %s: ", server)
 			if connResult.Connected {
 				summary += fmt.Sprintf("Connected (latency: %v)\n", connResult.Latency)
@@ -120,15 +482,491 @@ func (s *Service) FormatSMTPSummary(result *SMTPResult) string {
 				if connResult.Banner != "" {
 					summary += fmt.Sprintf("  Banner: %s\n", connResult.Banner)
 				}
+				if connResult.TLSVersion != "" {
+					summary += fmt.Sprintf("  TLS: %s (%s)\n", connResult.TLSVersion, connResult.TLSCipher)
+				}
+				if analysis := connResult.BannerAnalysis; analysis != nil {
+					summary += fmt.Sprintf("  Greeting delay: %v\n", analysis.GreetingDelay.Round(time.Millisecond))
+					for _, finding := range analysis.Findings {
+						summary += fmt.Sprintf("  ! %s\n", finding)
+					}
+				}
+				if security := connResult.STARTTLSSecurity; security != nil {
+					if security.InjectionTested {
+						summary += fmt.Sprintf("  STARTTLS command injection: %t\n", security.InjectionVulnerable)
+					}
+					for _, finding := range security.Findings {
+						summary += fmt.Sprintf("  ! %s\n", finding)
+					}
+				}
 			} else {
 				summary += fmt.Sprintf("Failed to connect: %s\n", connResult.Error)
 			}
+			if len(connResult.Transcript) > 0 {
+				summary += "  Transcript:\n" + FormatTranscript(connResult.Transcript, "    ")
+			}
+		}
+	}
+
+	if result.Error != "" {
+		summary += fmt.Sprintf("\nErrors: %s\n", result.Error)
+	}
+
+	return summary
+}
+
+// CreateMatrixCell creates a matrix cell from the session probing one address and port
+func (s *Service) CreateMatrixCell(mx MXHost, address string, port int, session *SessionInfo, err error) *MatrixCell {
+	cell := &MatrixCell{
+		MXHost:      mx.Host,
+		Preference:  mx.Preference,
+		Address:     address,
+		Family:      AddressFamily(address),
+		Port:        port,
+		ImplicitTLS: port == 465,
+	}
+	if session != nil {
+		cell.Connected = session.Connected
+		cell.Latency = session.Latency
+		cell.SupportsStartTLS = session.SupportsStartTLS
+		cell.TLSVersion = session.TLSVersion
+		cell.TLSCipher = session.TLSCipher
+		cell.Banner = session.Banner
+	}
+
+	if err != nil {
+		cell.Error = err.Error()
+	}
+
+	return cell
+}
+
+// ProcessMatrixResult orders the matrix cells by MX preference, address and port
+func (s *Service) ProcessMatrixResult(domain string, mxHosts []MXHost, ports []int, cells []*MatrixCell, resolveErrors map[string]string, err error) *MatrixResult {
+	sortMXHosts(mxHosts)
+
+	portIndex := make(map[int]int, len(ports))
+	for i, port := range ports {
+		portIndex[port] = i
+	}
+	sort.SliceStable(cells, func(i, j int) bool {
+		a, b := cells[i], cells[j]
+		if a.Preference != b.Preference {
+			return a.Preference < b.Preference
+		}
+		if a.MXHost != b.MXHost {
+			return a.MXHost < b.MXHost
+		}
+		if a.Family != b.Family {
+			// IPv4 rows before IPv6 rows
+			return a.Family < b.Family
+		}
+		if a.Address != b.Address {
+			return a.Address < b.Address
+		}
+		return portIndex[a.Port] < portIndex[b.Port]
+	})
+
+	result := &MatrixResult{
+		Domain:        domain,
+		MXHosts:       mxHosts,
+		Ports:         ports,
+		Cells:         cells,
+		ResolveErrors: resolveErrors,
+	}
+
+	if err != nil {
+		result.Error = err.Error()
+	}
+
+	return result
+}
+
+// FormatMatrixSummary returns the MX matrix as a text grid with one row per MX address and one column per port
+func (s *Service) FormatMatrixSummary(result *MatrixResult) string {
+	if result == nil {
+		return "No SMTP matrix results available"
+	}
+
+	summary := fmt.Sprintf("SMTP matrix for domain %s:\n", result.Domain)
+	if len(result.MXHosts) == 0 {
+		summary += "No MX records found\n"
+	}
+
+	// Group the cells into rows keyed by MX host and address, keeping their order
+	type row struct {
+		label string
+		cells map[int]*MatrixCell
+	}
+	rows := []*row{}
+	rowIndex := map[string]*row{}
+	for _, cell := range result.Cells {
+		key := cell.MXHost + " " + cell.Address
+		r, ok := rowIndex[key]
+		if !ok {
+			r = &row{
+				label: fmt.Sprintf("%s (%d) %s", cell.MXHost, cell.Preference, cell.Address),
+				cells: map[int]*MatrixCell{},
+			}
+			rowIndex[key] = r
+			rows = append(rows, r)
+		}
+		r.cells[cell.Port] = cell
+	}
+
+	if len(rows) > 0 {
+		width := len("MX / address")
+		for _, r := range rows {
+			if len(r.label) > width {
+				width = len(r.label)
+			}
+		}
+
+		summary += fmt.Sprintf("\n%-*s", width, "MX / address")
+		for _, port := range result.Ports {
+			summary += fmt.Sprintf("  %-22s", fmt.Sprintf("port %d", port))
+		}
+		summary += "\n"
+
+		for _, r := range rows {
+			summary += fmt.Sprintf("%-*s", width, r.label)
+			for _, port := range result.Ports {
+				summary += fmt.Sprintf("  %-22s", formatMatrixCell(r.cells[port]))
+			}
+			summary += "\n"
+		}
+	}
+
+	failures := ""
+	for _, cell := range result.Cells {
+		if !cell.Connected && cell.Error != "" {
+			failures += fmt.Sprintf("- %s [%s]:%d: %s\n", cell.MXHost, cell.Address, cell.Port, cell.Error)
+		}
+	}
+	if failures != "" {
+		summary += "\nFailures:\n" + failures
+	}
+
+	if len(result.ResolveErrors) > 0 {
+		summary += "\nResolution errors:\n"
+		for _, mx := range result.MXHosts {
+			if resolveErr, ok := result.ResolveErrors[mx.Host]; ok {
+				summary += fmt.Sprintf("- %s: %s\n", mx.Host, resolveErr)
+			}
+		}
+	}
+
+	if result.Error != "" {
+		summary += fmt.Sprintf("\nErrors: %s\n", result.Error)
+	}
+
+	return summary
+}
+
+// formatMatrixCell renders a single grid cell (reachability, latency, TLS)
+func formatMatrixCell(cell *MatrixCell) string {
+	if cell == nil {
+		return "-"
+	}
+	if !cell.Connected {
+		return "FAIL"
+	}
+
+	tls := "no TLS"
+	switch {
+	case cell.TLSVersion != "":
+		tls = cell.TLSVersion
+	case cell.SupportsStartTLS:
+		tls = "STARTTLS failed"
+	}
+	return fmt.Sprintf("OK %v %s", cell.Latency.Round(time.Millisecond), tls)
+}
+
+// sortMXHosts sorts MX hosts by preference, then by name
+func sortMXHosts(mxHosts []MXHost) {
+	sort.SliceStable(mxHosts, func(i, j int) bool {
+		if mxHosts[i].Preference != mxHosts[j].Preference {
+			return mxHosts[i].Preference < mxHosts[j].Preference
+		}
+		return mxHosts[i].Host < mxHosts[j].Host
+	})
+}
+
+// AddressFamily returns ipv4 or ipv6 for an IP address
+func AddressFamily(address string) string {
+	if strings.Contains(address, ":") {
+		return "ipv6"
+	}
+	return "ipv4"
+}
+
+// ProcessSubmissionTestResult completes a submission test result with the request and error
+func (s *Service) ProcessSubmissionTestResult(req *SubmissionTestRequest, result *SubmissionTestResult, err error) *SubmissionTestResult {
+	if result == nil {
+		result = &SubmissionTestResult{}
+	}
+	if result.Host == "" {
+		result.Host = req.Host
+	}
+	if result.Port == 0 {
+		result.Port = req.Port
+	}
+
+	if err != nil && result.Error == "" {
+		result.Error = err.Error()
+	}
+
+	for _, stage := range result.Stages {
+		stage.Explanation = s.explainFailure(stage.ResponseCode, stage.EnhancedCode, stage.Response)
+	}
+
+	return result
+}
+
+// FormatSubmissionTestSummary returns a human-readable summary of a submission test
+func (s *Service) FormatSubmissionTestSummary(result *SubmissionTestResult) string {
+	if result == nil {
+		return "No submission test results available"
+	}
+
+	summary := fmt.Sprintf("Submission test results for %s:%d:\n", result.Host, result.Port)
+	if result.ImplicitTLS {
+		summary += "TLS: implicit"
+	} else {
+		summary += "TLS: STARTTLS"
+	}
+	if result.TLSVersion != "" {
+		summary += fmt.Sprintf(" (%s)", result.TLSVersion)
+	}
+	summary += "\n"
+	if len(result.AuthMechanisms) > 0 {
+		summary += fmt.Sprintf("Auth mechanisms offered: %s\n", strings.Join(result.AuthMechanisms, " "))
+	}
+
+	if len(result.Stages) > 0 {
+		summary += "\nStages:\n"
+		for _, stage := range result.Stages {
+			status := "FAIL"
+			switch {
+			case stage.Skipped:
+				status = "SKIPPED"
+			case stage.Success:
+				status = "OK"
+			}
+			summary += fmt.Sprintf("- %-8s %-8s", stage.Name, status)
+			if stage.Duration > 0 {
+				summary += fmt.Sprintf(" %v", stage.Duration.Round(time.Millisecond))
+			}
+			if stage.Name == "auth" && result.Mechanism != "" {
+				summary += fmt.Sprintf(" [%s]", result.Mechanism)
+			}
+			summary += "\n"
+			if stage.ResponseCode != 0 {
+				summary += fmt.Sprintf("    Response: %d %s\n", stage.ResponseCode, stage.Response)
+			}
+			summary += formatExplanationLines(stage.Explanation, "    ")
+			if stage.Error != "" {
+				summary += fmt.Sprintf("    Error: %s\n", stage.Error)
+			}
 		}
 	}
 
+	if result.MessageSent {
+		summary += fmt.Sprintf("\nTest message sent: Message-ID %s, tracking ID %s\n", result.MessageID, result.TrackingID)
+	}
+
+	if len(result.Transcript) > 0 {
+		summary += "\nTranscript:\n" + FormatTranscript(result.Transcript, "  ")
+	}
+
 	if result.Error != "" {
 		summary += fmt.Sprintf("\nErrors: %s\n", result.Error)
 	}
 
 	return summary
 }
+
+// ProcessSmugglingTestResult completes a smuggling probe result with the request and error
+func (s *Service) ProcessSmugglingTestResult(req *SmugglingTestRequest, result *SmugglingTestResult, err error) *SmugglingTestResult {
+	if result == nil {
+		result = &SmugglingTestResult{}
+	}
+	result.Domain = req.Domain
+	if result.Host == "" {
+		result.Host = req.Host
+	}
+	if result.Port == 0 {
+		result.Port = req.Port
+	}
+
+	if err != nil && result.Error == "" {
+		result.Error = err.Error()
+	}
+
+	return result
+}
+
+// FormatSmugglingTestSummary returns a human-readable summary of an SMTP smuggling probe
+func (s *Service) FormatSmugglingTestSummary(result *SmugglingTestResult) string {
+	if result == nil {
+		return "No smuggling probe results available"
+	}
+
+	summary := fmt.Sprintf("SMTP smuggling probe for %s (%s:%d):\n", result.Domain, result.Host, result.Port)
+	if result.Recipient != "" {
+		summary += fmt.Sprintf("Recipient: %s\n", result.Recipient)
+	}
+	if len(result.Sequences) > 0 {
+		if result.Vulnerable {
+			summary += "Verdict: VULNERABLE - non-standard end-of-data sequences are accepted\n"
+		} else {
+			summary += "Verdict: not vulnerable\n"
+		}
+	}
+	if result.BareLFCommand != "" {
+		summary += fmt.Sprintf("Bare LF in commands: %s", result.BareLFCommand)
+		if result.BareLFCommandCode != 0 {
+			summary += fmt.Sprintf(" (%d %s)", result.BareLFCommandCode, result.BareLFCommandResponse)
+		}
+		summary += "\n"
+	}
+
+	if len(result.Sequences) > 0 {
+		summary += "\nEnd-of-data sequences:\n"
+		for _, seq := range result.Sequences {
+			summary += fmt.Sprintf("- %-15s %s", seq.Name, seq.Outcome)
+			if seq.Code != 0 {
+				summary += fmt.Sprintf(" (%d %s)", seq.Code, seq.Response)
+			}
+			if seq.TrackingID != "" {
+				summary += fmt.Sprintf(" [message %s delivered]", seq.TrackingID)
+			}
+			summary += "\n"
+			if seq.Error != "" {
+				summary += fmt.Sprintf("    Error: %s\n", seq.Error)
+			}
+		}
+	}
+
+	if len(result.Findings) > 0 {
+		summary += "\nFindings:\n"
+		for _, finding := range result.Findings {
+			summary += fmt.Sprintf("- %s\n", finding)
+		}
+	}
+
+	if len(result.Transcript) > 0 {
+		summary += "\nTranscript:\n" + FormatTranscript(result.Transcript, "  ")
+	}
+
+	if result.Error != "" {
+		summary += fmt.Sprintf("\nErrors: %s\n", result.Error)
+	}
+
+	return summary
+}
+
+// ProcessRelayTestResult builds the verdict of an open relay test from the individual probes
+func (s *Service) ProcessRelayTestResult(req *RelayTestRequest, banner string, tests []*RelayTestCase, authenticated bool, err error) *RelayTestResult {
+	result := &RelayTestResult{
+		Host:          req.Host,
+		Port:          req.Port,
+		Banner:        banner,
+		Authenticated: authenticated,
+		Tests:         tests,
+	}
+
+	for _, test := range tests {
+		test.Explanation = s.explainFailure(test.ResponseCode, "", test.Response)
+
+		// Relaying on an authenticated session is the expected behavior of a submission server
+		if test.Accepted && !authenticated {
+			result.IsOpenRelay = true
+		}
+		if test.ResponseCode == 530 || (test.ResponseCode >= 500 && strings.Contains(strings.ToLower(test.Response), "auth")) {
+			result.AuthRequired = true
+		}
+	}
+
+	if err != nil {
+		result.Error = err.Error()
+	}
+
+	return result
+}
+
+// FormatRelayTestSummary returns a human-readable summary of an open relay test
+func (s *Service) FormatRelayTestSummary(result *RelayTestResult) string {
+	if result == nil {
+		return "No relay test results available"
+	}
+
+	summary := fmt.Sprintf("Open relay test results for %s:%d:\n", result.Host, result.Port)
+	if result.Banner != "" {
+		summary += fmt.Sprintf("Banner: %s\n", result.Banner)
+	}
+	if result.Authenticated {
+		summary += "Session: authenticated\n"
+	}
+
+	if len(result.Tests) > 0 {
+		summary += "\nTests:\n"
+		for _, test := range result.Tests {
+			summary += fmt.Sprintf("- %-28s %-9s MAIL FROM:<%s> RCPT TO:<%s>\n", test.Name, strings.ToUpper(test.Verdict), test.MailFrom, test.RcptTo)
+			if test.Error != "" {
+				summary += fmt.Sprintf("    Error: %s\n", test.Error)
+			} else if test.ResponseCode != 0 {
+				summary += fmt.Sprintf("    Response: %d %s\n", test.ResponseCode, test.Response)
+				summary += formatExplanationLines(test.Explanation, "    ")
+			}
+			if len(test.Transcript) > 0 {
+				summary += FormatTranscript(test.Transcript, "    ")
+			}
+		}
+	}
+
+	summary += "\nVerdict: "
+	switch {
+	case result.IsOpenRelay:
+		summary += "OPEN RELAY - the server accepted mail for external recipients without authentication\n"
+	case result.Authenticated:
+		summary += "Authenticated relaying tested; the server is not an open relay by this test\n"
+	case len(result.Tests) == 0:
+		summary += "Inconclusive\n"
+	default:
+		summary += "Not an open relay\n"
+	}
+	if result.AuthRequired {
+		summary += "The server requires authentication to relay\n"
+	}
+
+	if result.Error != "" {
+		summary += fmt.Sprintf("\nErrors: %s\n", result.Error)
+	}
+
+	return summary
+}
+
+// FormatTranscript renders transcript entries as text, one indented line per entry
+func FormatTranscript(entries []TranscriptEntry, indent string) string {
+	var b strings.Builder
+	for _, entry := range entries {
+		prefix := "  "
+		switch entry.Direction {
+		case "client":
+			prefix = "C:"
+		case "server":
+			prefix = "S:"
+		case "tls":
+			prefix = "**"
+		case "info":
+			prefix = "--"
+		}
+		fmt.Fprintf(&b, "%s[%8s] %s %s", indent, entry.Elapsed.Round(time.Millisecond), prefix, entry.Line)
+		if entry.Direction == "server" && entry.Duration > 0 {
+			fmt.Fprintf(&b, " (%s)", entry.Duration.Round(time.Millisecond))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
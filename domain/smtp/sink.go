@@ -0,0 +1,269 @@
+package smtp
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Errors of the SMTP sink
+var (
+	// ErrSinkNotRunning is returned when a sink test is requested while the sink is stopped
+	ErrSinkNotRunning = errors.New("the SMTP sink is not running")
+	// ErrSinkRunning is returned when the sink is started twice
+	ErrSinkRunning = errors.New("the SMTP sink is already running")
+	// ErrSinkTestNotFound is returned for an unknown test ID
+	ErrSinkTestNotFound = errors.New("sink test not found")
+)
+
+// Sink message verdicts
+const (
+	// SinkVerdictPass means the message passed DMARC
+	SinkVerdictPass = "pass"
+	// SinkVerdictFail means the message failed DMARC, or neither SPF nor DKIM passed
+	SinkVerdictFail = "fail"
+	// SinkVerdictNone means the From domain publishes no DMARC policy but SPF or DKIM passed
+	SinkVerdictNone = "none"
+	// SinkVerdictTempError means a lookup failed and the verdict could not be determined
+	SinkVerdictTempError = "temperror"
+)
+
+// SinkConfig configures the SMTP sink
+type SinkConfig struct {
+	// Address to listen on, host:port
+	Address string
+	// Domain of the generated test addresses; its MX must point to the sink
+	Domain string
+	// Name announced in the greeting and used for the self-signed certificate
+	Hostname string
+	// PEM certificate and key for STARTTLS; a self-signed certificate is generated when empty
+	CertFile string
+	KeyFile  string
+	// Timeout for each command of a session
+	Timeout time.Duration
+	// How long a test address receives and keeps messages
+	TestTTL time.Duration
+	// Number of messages kept for each test
+	MaxTestMessages int
+}
+
+// SinkStatus describes the SMTP sink
+type SinkStatus struct {
+	Running bool
+	Address string
+	Domain  string
+	// Whether STARTTLS uses a generated self-signed certificate
+	SelfSigned bool
+}
+
+// SinkTest represents a test address of the sink and the messages it received
+type SinkTest struct {
+	ID       string
+	Address  string
+	Messages []*SinkMessageReport
+}
+
+// SinkMessageReport represents the authentication verdict of a message received by the sink
+type SinkMessageReport struct {
+	ReceivedAt time.Time
+	// SMTP session: client address, HELO name, envelope and TLS
+	ClientIP   string
+	Helo       string
+	MailFrom   string
+	Recipients []string
+	TLS        bool
+	TLSVersion string
+	Size       int
+	// Authentication results
+	SPF     *SinkSPFResult
+	DKIM    []SinkDKIMSignature
+	DMARC   *SinkDMARCResult
+	Headers *SinkHeaderReport
+	// Overall verdict (pass, fail, none, temperror) and the reasons behind it
+	Verdict string
+	Reasons []string
+	// Error message if any
+	Error string
+}
+
+// SinkSPFResult represents the SPF result for the connecting IP
+type SinkSPFResult struct {
+	Domain      string
+	IP          string
+	Result      string
+	Record      string
+	Mechanism   string
+	Lookups     int
+	Explanation string
+}
+
+// SinkDKIMSignature represents the verification of one DKIM signature
+type SinkDKIMSignature struct {
+	Domain           string
+	Selector         string
+	Algorithm        string
+	Canonicalization string
+	SignedHeaders    []string
+	Result           string
+	Reason           string
+}
+
+// SinkDMARCResult represents DMARC alignment for the From domain
+type SinkDMARCResult struct {
+	FromDomain   string
+	PolicyDomain string
+	Record       string
+	Policy       string
+	SPFAligned   bool
+	DKIMAligned  bool
+	Result       string
+	Disposition  string
+	Lookalikes   []SinkLookalikeDomain
+	Error        string
+}
+
+// SinkLookalikeDomain represents an SPF or DKIM domain that imitates the From domain
+type SinkLookalikeDomain struct {
+	Domain               string
+	Source               string
+	OrganizationalDomain string
+	ImitatedDomain       string
+	Distance             int
+	Reason               string
+}
+
+// SinkHeaderReport represents the header checks of a received message
+type SinkHeaderReport struct {
+	From         string
+	To           string
+	Subject      string
+	Date         string
+	MessageID    string
+	ReceivedHops int
+	Findings     []string
+}
+
+// ProcessSinkMessageReport sets the verdict of a received message from its authentication results
+func (s *Service) ProcessSinkMessageReport(report *SinkMessageReport) *SinkMessageReport {
+	report.Reasons = nil
+
+	spfPass := report.SPF != nil && report.SPF.Result == "pass"
+	dkimPass := false
+	for _, sig := range report.DKIM {
+		dkimPass = dkimPass || sig.Result == "pass"
+	}
+
+	dmarc := report.DMARC
+	switch {
+	case dmarc != nil && dmarc.Result == "pass":
+		report.Verdict = SinkVerdictPass
+	case dmarc != nil && dmarc.Result == "temperror":
+		report.Verdict = SinkVerdictTempError
+		report.Reasons = append(report.Reasons, "DMARC policy could not be retrieved: "+dmarc.Error)
+	case dmarc != nil && (dmarc.Result == "fail" || dmarc.Result == "permerror"):
+		report.Verdict = SinkVerdictFail
+		if dmarc.Result == "permerror" {
+			report.Reasons = append(report.Reasons, "DMARC could not be evaluated: "+dmarc.Error)
+		} else if dmarc.Disposition != "" && dmarc.Disposition != "none" {
+			report.Reasons = append(report.Reasons, fmt.Sprintf("Receivers applying DMARC would %s this message", dmarc.Disposition))
+		}
+	case spfPass || dkimPass:
+		report.Verdict = SinkVerdictNone
+		report.Reasons = append(report.Reasons, "From domain publishes no DMARC policy")
+	default:
+		report.Verdict = SinkVerdictFail
+		report.Reasons = append(report.Reasons, "From domain publishes no DMARC policy")
+	}
+
+	if dmarc != nil && dmarc.Result == "fail" {
+		if spfPass && !dmarc.SPFAligned {
+			report.Reasons = append(report.Reasons, fmt.Sprintf("SPF passed for %s, which is not aligned with the From domain %s", report.SPF.Domain, dmarc.FromDomain))
+		}
+		if dkimPass && !dmarc.DKIMAligned {
+			report.Reasons = append(report.Reasons, fmt.Sprintf("No passing DKIM signature is aligned with the From domain %s", dmarc.FromDomain))
+		}
+	}
+	if dmarc != nil {
+		for _, lookalike := range dmarc.Lookalikes {
+			report.Reasons = append(report.Reasons, fmt.Sprintf("%s domain %s looks like the From domain %s: %s",
+				strings.ToUpper(lookalike.Source), lookalike.Domain, lookalike.ImitatedDomain, lookalike.Reason))
+		}
+	}
+	if report.SPF != nil && !spfPass {
+		report.Reasons = append(report.Reasons, fmt.Sprintf("SPF %s for %s from %s", report.SPF.Result, report.SPF.Domain, report.SPF.IP))
+	}
+	for _, sig := range report.DKIM {
+		if sig.Result != "pass" {
+			report.Reasons = append(report.Reasons, fmt.Sprintf("DKIM %s for d=%s s=%s: %s", sig.Result, sig.Domain, sig.Selector, sig.Reason))
+		}
+	}
+	if !report.TLS {
+		report.Reasons = append(report.Reasons, "Message was delivered without TLS")
+	}
+
+	return report
+}
+
+// FormatSinkStatus returns a human-readable description of the sink
+func (s *Service) FormatSinkStatus(status *SinkStatus) string {
+	if status == nil || !status.Running {
+		return "SMTP sink is not running"
+	}
+	summary := fmt.Sprintf("SMTP sink listening on %s for test addresses @%s\n", status.Address, status.Domain)
+	if status.SelfSigned {
+		summary += "STARTTLS uses a self-signed certificate\n"
+	}
+	return summary
+}
+
+// FormatSinkTestSummary returns a human-readable summary of the messages received for a sink test
+func (s *Service) FormatSinkTestSummary(test *SinkTest) string {
+	if test == nil {
+		return "No sink test available"
+	}
+
+	summary := fmt.Sprintf("Sink test %s (%s): %d message(s)\n", test.ID, test.Address, len(test.Messages))
+	for i, m := range test.Messages {
+		summary += fmt.Sprintf("\nMessage %d received %s from %s (HELO %s): %s\n", i+1, m.ReceivedAt.Format(time.RFC3339), m.ClientIP, m.Helo, strings.ToUpper(m.Verdict))
+		mailFrom := m.MailFrom
+		if mailFrom == "" {
+			mailFrom = "<>"
+		}
+		summary += fmt.Sprintf("  MAIL FROM: %s\n", mailFrom)
+		if m.TLS {
+			summary += fmt.Sprintf("  TLS: %s\n", m.TLSVersion)
+		} else {
+			summary += "  TLS: none\n"
+		}
+		if m.Headers != nil {
+			summary += fmt.Sprintf("  From: %s\n", m.Headers.From)
+			summary += fmt.Sprintf("  Subject: %s\n", m.Headers.Subject)
+		}
+		if m.SPF != nil {
+			summary += fmt.Sprintf("  SPF: %s (%s, %s)\n", m.SPF.Result, m.SPF.Domain, m.SPF.IP)
+		}
+		for _, sig := range m.DKIM {
+			summary += fmt.Sprintf("  DKIM: %s (d=%s s=%s %s)\n", sig.Result, sig.Domain, sig.Selector, sig.Algorithm)
+		}
+		if m.DMARC != nil && m.DMARC.Record == "" {
+			summary += fmt.Sprintf("  DMARC: %s (%s)\n", m.DMARC.Result, m.DMARC.FromDomain)
+		} else if m.DMARC != nil {
+			summary += fmt.Sprintf("  DMARC: %s (%s, policy %s, SPF aligned %t, DKIM aligned %t)\n",
+				m.DMARC.Result, m.DMARC.FromDomain, m.DMARC.Policy, m.DMARC.SPFAligned, m.DMARC.DKIMAligned)
+		}
+		for _, reason := range m.Reasons {
+			summary += fmt.Sprintf("  - %s\n", reason)
+		}
+		if m.Headers != nil {
+			for _, finding := range m.Headers.Findings {
+				summary += fmt.Sprintf("  ! %s\n", finding)
+			}
+		}
+		if m.Error != "" {
+			summary += fmt.Sprintf("  Error: %s\n", m.Error)
+		}
+	}
+
+	return summary
+}
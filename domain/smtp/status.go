@@ -0,0 +1,582 @@
+package smtp
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Reply classes, from the first digit of the reply or enhanced status code
+const (
+	ReplyClassSuccess      = "success"
+	ReplyClassIntermediate = "intermediate"
+	ReplyClassTransient    = "transient"
+	ReplyClassPermanent    = "permanent"
+)
+
+// Reply categories: what the receiving side objects to
+const (
+	// ReplyCategoryPolicy is a local policy decision (relaying, TLS, rate limits, greylisting)
+	ReplyCategoryPolicy = "policy"
+	// ReplyCategoryReputation is a block of the sending IP or domain (blocklists, complaints)
+	ReplyCategoryReputation = "reputation"
+	// ReplyCategoryAuth is a failed sender authentication (SPF, DKIM, DMARC, reverse DNS, SMTP AUTH)
+	ReplyCategoryAuth = "auth"
+	// ReplyCategoryMailbox is a problem with the recipient address or mailbox
+	ReplyCategoryMailbox = "mailbox"
+	// ReplyCategoryContent is a problem with the message itself (size, format, spam content)
+	ReplyCategoryContent = "content"
+	// ReplyCategorySystem is a problem of the receiving mail system or the network
+	ReplyCategorySystem = "system"
+	// ReplyCategoryProtocol is an SMTP protocol error of the client
+	ReplyCategoryProtocol = "protocol"
+	// ReplyCategoryUnknown is used when nothing in the reply identifies the cause
+	ReplyCategoryUnknown = "unknown"
+)
+
+// ReplyExplanation classifies an SMTP reply or bounce and suggests a remediation
+type ReplyExplanation struct {
+	// Reply text that was explained
+	Reply string
+	// Basic reply code and RFC 3463 enhanced status code, when present
+	Code         int
+	EnhancedCode string
+	// Class (success, intermediate, transient, permanent) and category (policy, reputation, auth, mailbox, ...)
+	Class    string
+	Category string
+	// RFC 3463 descriptions of the subject and detail of the enhanced status code
+	StatusSubject string
+	StatusDetail  string
+	// Provider whose rejection text was recognized (Spamhaus, Microsoft, Gmail, ...)
+	Provider string
+	// What the reply means, and what the sender can do about it
+	Meaning string
+	Hint    string
+}
+
+// enhancedStatusSubjects are the RFC 3463 subject descriptions (X.Y)
+var enhancedStatusSubjects = map[int]string{
+	0: "Other or undefined status",
+	1: "Addressing status",
+	2: "Mailbox status",
+	3: "Mail system status",
+	4: "Network and routing status",
+	5: "Mail delivery protocol status",
+	6: "Message content or media status",
+	7: "Security or policy status",
+}
+
+// enhancedStatusDetails are the descriptions of the registered status codes (X.Y.Z), from
+// RFC 3463 and the later additions of the IANA registry (RFC 4954, 5248, 6710, 7293, 7372, 7505, 8689)
+var enhancedStatusDetails = map[string]string{
+	"0.0":  "Other undefined status",
+	"1.0":  "Other address status",
+	"1.1":  "Bad destination mailbox address",
+	"1.2":  "Bad destination system address",
+	"1.3":  "Bad destination mailbox address syntax",
+	"1.4":  "Destination mailbox address ambiguous",
+	"1.5":  "Destination address valid",
+	"1.6":  "Destination mailbox has moved, no forwarding address",
+	"1.7":  "Bad sender's mailbox address syntax",
+	"1.8":  "Bad sender's system address",
+	"1.9":  "Message relayed to non-compliant mailer",
+	"1.10": "Recipient address has null MX",
+	"2.0":  "Other or undefined mailbox status",
+	"2.1":  "Mailbox disabled, not accepting messages",
+	"2.2":  "Mailbox full",
+	"2.3":  "Message length exceeds administrative limit",
+	"2.4":  "Mailing list expansion problem",
+	"3.0":  "Other or undefined mail system status",
+	"3.1":  "Mail system full",
+	"3.2":  "System not accepting network messages",
+	"3.3":  "System not capable of selected features",
+	"3.4":  "Message too big for system",
+	"3.5":  "System incorrectly configured",
+	"3.6":  "Requested priority was changed",
+	"4.0":  "Other or undefined network or routing status",
+	"4.1":  "No answer from host",
+	"4.2":  "Bad connection",
+	"4.3":  "Directory server failure",
+	"4.4":  "Unable to route",
+	"4.5":  "Mail system congestion",
+	"4.6":  "Routing loop detected",
+	"4.7":  "Delivery time expired",
+	"5.0":  "Other or undefined protocol status",
+	"5.1":  "Invalid command",
+	"5.2":  "Syntax error",
+	"5.3":  "Too many recipients",
+	"5.4":  "Invalid command arguments",
+	"5.5":  "Wrong protocol version",
+	"5.6":  "Authentication exchange line is too long",
+	"6.0":  "Other or undefined media error",
+	"6.1":  "Media not supported",
+	"6.2":  "Conversion required and prohibited",
+	"6.3":  "Conversion required but not supported",
+	"6.4":  "Conversion with loss performed",
+	"6.5":  "Conversion failed",
+	"6.6":  "Message content not available",
+	"6.7":  "Non-ASCII addresses not permitted for that sender/recipient",
+	"6.8":  "UTF-8 string reply is required, but not permitted by the SMTP client",
+	"6.9":  "UTF-8 header message cannot be transferred to one or more recipients",
+	"7.0":  "Other or undefined security status",
+	"7.1":  "Delivery not authorized, message refused",
+	"7.2":  "Mailing list expansion prohibited",
+	"7.3":  "Security conversion required but not possible",
+	"7.4":  "Security features not supported",
+	"7.5":  "Cryptographic failure",
+	"7.6":  "Cryptographic algorithm not supported",
+	"7.7":  "Message integrity failure",
+	"7.8":  "Authentication credentials invalid",
+	"7.9":  "Authentication mechanism is too weak",
+	"7.10": "Encryption needed",
+	"7.11": "Encryption required for requested authentication mechanism",
+	"7.12": "A password transition is needed",
+	"7.13": "User account disabled",
+	"7.14": "Trust relationship required",
+	"7.15": "Priority level is too low",
+	"7.16": "Message is too big for the specified priority",
+	"7.17": "Mailbox owner has changed",
+	"7.18": "Domain owner has changed",
+	"7.19": "RRVS test cannot be completed",
+	"7.20": "No passing DKIM signature found",
+	"7.21": "No acceptable DKIM signature found",
+	"7.22": "No valid author-matched DKIM signature found",
+	"7.23": "SPF validation failed",
+	"7.24": "SPF validation error",
+	"7.25": "Reverse DNS validation failed",
+	"7.26": "Multiple authentication checks failed",
+	"7.27": "Sender address has null MX",
+	"7.28": "Mail flood detected",
+	"7.29": "ARC validation failure",
+	"7.30": "REQUIRETLS support required",
+}
+
+// enhancedStatusCategories overrides the category implied by the subject of a status code
+var enhancedStatusCategories = map[string]string{
+	"2.3":  ReplyCategoryContent,
+	"3.4":  ReplyCategoryContent,
+	"7.8":  ReplyCategoryAuth,
+	"7.9":  ReplyCategoryAuth,
+	"7.11": ReplyCategoryAuth,
+	"7.12": ReplyCategoryAuth,
+	"7.13": ReplyCategoryAuth,
+	"7.20": ReplyCategoryAuth,
+	"7.21": ReplyCategoryAuth,
+	"7.22": ReplyCategoryAuth,
+	"7.23": ReplyCategoryAuth,
+	"7.24": ReplyCategoryAuth,
+	"7.25": ReplyCategoryAuth,
+	"7.26": ReplyCategoryAuth,
+	"7.27": ReplyCategoryAuth,
+	"7.28": ReplyCategoryReputation,
+	"7.29": ReplyCategoryAuth,
+}
+
+// subjectCategories is the category implied by the subject of a status code
+var subjectCategories = map[int]string{
+	1: ReplyCategoryMailbox,
+	2: ReplyCategoryMailbox,
+	3: ReplyCategorySystem,
+	4: ReplyCategorySystem,
+	5: ReplyCategoryProtocol,
+	6: ReplyCategoryContent,
+	7: ReplyCategoryPolicy,
+}
+
+// categoryHints are the remediation hints used when no catalog entry matches
+var categoryHints = map[string]string{
+	ReplyCategoryPolicy:     "The receiving server refused the message by local policy; the rest of the reply or the recipient's postmaster can tell which rule applies.",
+	ReplyCategoryReputation: "The sending IP or domain is blocked; check it against blocklists (mxclone blacklist) and fix the cause before requesting delisting.",
+	ReplyCategoryAuth:       "Check the SPF, DKIM and DMARC records of the sending domain (mxclone auth) and the reverse DNS of the sending IP.",
+	ReplyCategoryMailbox:    "Check the recipient address; remove addresses that are refused permanently from your lists.",
+	ReplyCategoryContent:    "Reduce the message size or fix its format, and review the content for spam signals.",
+	ReplyCategorySystem:     "The receiving system or network has a problem; a transient failure is retried automatically, a permanent one needs the recipient's postmaster.",
+	ReplyCategoryProtocol:   "The sending software issued a command the server did not accept; check the client configuration or software.",
+}
+
+// replyRule recognizes a provider or common rejection text
+type replyRule struct {
+	pattern  *regexp.Regexp
+	provider string
+	category string
+	meaning  string
+	hint     string
+}
+
+// replyRules is the catalog of rejection texts, most specific first
+var replyRules = []replyRule{
+	// Spamhaus
+	{
+		pattern:  regexp.MustCompile(`(?i)spamhaus.*\bpbl\b|\bpbl\b.*spamhaus|spamhaus\.org/(query/)?pbl`),
+		provider: "Spamhaus",
+		category: ReplyCategoryReputation,
+		meaning:  "The sending IP is on the Spamhaus Policy Block List: it is in a range that should not send mail directly (dynamic or end-user addresses).",
+		hint:     "Send through your provider's or ISP's authenticated smarthost, or have the owner of the IP range remove it from the PBL at check.spamhaus.org.",
+	},
+	{
+		pattern:  regexp.MustCompile(`(?i)spamhaus`),
+		provider: "Spamhaus",
+		category: ReplyCategoryReputation,
+		meaning:  "The sending IP or domain is listed on a Spamhaus blocklist (SBL, XBL, CSS or DBL).",
+		hint:     "Look the IP up at check.spamhaus.org (or mxclone blacklist), fix the cause (compromised host, open relay, spam complaints) and then request removal.",
+	},
+	{
+		pattern:  regexp.MustCompile(`(?i)spamcop`),
+		provider: "SpamCop",
+		category: ReplyCategoryReputation,
+		meaning:  "The sending IP is listed on the SpamCop blocklist after spam reports.",
+		hint:     "Find out why the IP was reported; SpamCop listings expire automatically about 24 hours after the reports stop.",
+	},
+	{
+		pattern:  regexp.MustCompile(`(?i)barracuda`),
+		provider: "Barracuda",
+		category: ReplyCategoryReputation,
+		meaning:  "The sending IP has a poor Barracuda reputation.",
+		hint:     "Check and request removal at barracudacentral.org/lookups once the cause is fixed.",
+	},
+	// Microsoft (Outlook.com, Exchange Online)
+	{
+		pattern:  regexp.MustCompile(`(?i)\bS3150\b|\bS3140\b|\bS3115\b`),
+		provider: "Microsoft",
+		category: ReplyCategoryReputation,
+		meaning:  "Outlook.com blocked the sending IP because of its reputation.",
+		hint:     "Request delisting through the Outlook.com sender support form (sender.office.com) and enroll the IP in SNDS/JMRP to monitor complaints.",
+	},
+	{
+		pattern:  regexp.MustCompile(`(?i)5\.7\.(606|511)|banned sending ip`),
+		provider: "Microsoft",
+		category: ReplyCategoryReputation,
+		meaning:  "Exchange Online banned the sending IP.",
+		hint:     "Request delisting at sender.office.com after fixing the cause.",
+	},
+	{
+		pattern:  regexp.MustCompile(`(?i)5\.7\.708|traffic not accepted from this ip`),
+		provider: "Microsoft",
+		category: ReplyCategoryReputation,
+		meaning:  "Exchange Online does not accept traffic from this IP, typically one with no sending history used by a new tenant.",
+		hint:     "Send through a relay with an established reputation or contact Microsoft support to get the tenant unblocked.",
+	},
+	{
+		pattern:  regexp.MustCompile(`(?i)5\.7\.750|unregistered domain`),
+		provider: "Microsoft",
+		category: ReplyCategoryPolicy,
+		meaning:  "Exchange Online refuses to relay for a sending domain that is not registered in the tenant.",
+		hint:     "Add and verify the sending domain in the Microsoft 365 tenant.",
+	},
+	{
+		pattern:  regexp.MustCompile(`(?i)AS\(201806281\)|directory based edge blocking|\bDBEB\b`),
+		provider: "Microsoft",
+		category: ReplyCategoryMailbox,
+		meaning:  "Exchange Online rejected the recipient because it does not exist in the directory (Directory-Based Edge Blocking).",
+		hint:     "Check the recipient address; if it should exist, the recipient's admin must create the mailbox or contact.",
+	},
+	{
+		pattern:  regexp.MustCompile(`(?i)5\.7\.(515|509)|access denied.*(authentication|dmarc|spf|dkim)`),
+		provider: "Microsoft",
+		category: ReplyCategoryAuth,
+		meaning:  "Outlook.com refused the message because the sending domain does not meet its authentication requirements (SPF, DKIM and DMARC).",
+		hint:     "Publish SPF and DMARC, sign with DKIM aligned with the From domain, and verify with mxclone auth.",
+	},
+	// Gmail
+	{
+		pattern:  regexp.MustCompile(`(?i)5\.7\.26.*(google|gmail)|(google|gmail).*5\.7\.26|unauthenticated.*(google|gmail)`),
+		provider: "Gmail",
+		category: ReplyCategoryAuth,
+		meaning:  "Gmail rejected the message because it failed authentication: neither SPF nor DKIM passed, or DMARC failed for the From domain.",
+		hint:     "Publish an SPF record covering the sending IP, sign with DKIM, publish DMARC and make sure SPF or DKIM aligns with the From domain (bulk senders need both). Verify with mxclone auth.",
+	},
+	{
+		pattern:  regexp.MustCompile(`(?i)5\.7\.25|ptr record.*(sending|ip)|reverse dns`),
+		category: ReplyCategoryAuth,
+		meaning:  "The sending IP has no reverse DNS (PTR) record, or its PTR name does not resolve back to the IP.",
+		hint:     "Have the owner of the IP set a PTR record whose name resolves to the same IP (forward-confirmed reverse DNS).",
+	},
+	{
+		pattern:  regexp.MustCompile(`(?i)unusual rate of unsolicited mail|4\.7\.28|5\.7\.28`),
+		provider: "Gmail",
+		category: ReplyCategoryReputation,
+		meaning:  "Gmail limits the sender because of an unusual rate of unsolicited mail from the IP or domain.",
+		hint:     "Slow down, send only to engaged recipients and monitor the domain in Google Postmaster Tools.",
+	},
+	{
+		pattern:  regexp.MustCompile(`(?i)likely unsolicited mail|message has been blocked.*spam|detected as spam`),
+		category: ReplyCategoryContent,
+		meaning:  "The receiving server classified the message as spam.",
+		hint:     "Review the content and links, authenticate the domain and check its reputation in the receiver's postmaster tools.",
+	},
+	{
+		pattern:  regexp.MustCompile(`(?i)4\.2\.1.*rate|receiving mail at a rate|receiving mail too quickly`),
+		category: ReplyCategoryMailbox,
+		meaning:  "The recipient is receiving mail faster than the provider allows; the message is deferred.",
+		hint:     "Nothing to fix on the sending side; the message is retried later.",
+	},
+	// Yahoo
+	{
+		pattern:  regexp.MustCompile(`(?i)\bTSS?0[1-9]\b`),
+		provider: "Yahoo",
+		category: ReplyCategoryReputation,
+		meaning:  "Yahoo deferred the message because of unexpected volume or user complaints from the sending IP.",
+		hint:     "Reduce the sending rate, honour unsubscribes and check the complaint feedback loop at senders.yahooinc.com.",
+	},
+	// Common rejection texts
+	{
+		pattern:  regexp.MustCompile(`(?i)greylist|graylist|try again later.*(grey|gray)`),
+		category: ReplyCategoryPolicy,
+		meaning:  "The server greylists unknown senders: the first attempt is deferred on purpose.",
+		hint:     "A compliant mail server retries after a few minutes and the message is then accepted.",
+	},
+	{
+		pattern:  regexp.MustCompile(`(?i)relay(ing)? (access )?(denied|not permitted|prohibited)|not permitted to relay|unable to relay|relay not permitted`),
+		category: ReplyCategoryPolicy,
+		meaning:  "The server does not relay mail for this recipient domain from this client.",
+		hint:     "Authenticate (SMTP AUTH on the submission port) or send to the recipient domain's own MX.",
+	},
+	{
+		pattern:  regexp.MustCompile(`(?i)authentication required|must authenticate|auth(entication)? (is )?needed|5\.7\.0 .*auth`),
+		category: ReplyCategoryAuth,
+		meaning:  "The server requires SMTP authentication before accepting this message.",
+		hint:     "Configure the client to authenticate with valid credentials over TLS.",
+	},
+	{
+		pattern:  regexp.MustCompile(`(?i)(must issue a )?starttls (first|required)|tls (is )?required|encryption required`),
+		category: ReplyCategoryPolicy,
+		meaning:  "The server requires the session to be encrypted.",
+		hint:     "Enable STARTTLS (or implicit TLS on port 465) in the sending client.",
+	},
+	{
+		pattern:  regexp.MustCompile(`(?i)\bdmarc\b`),
+		category: ReplyCategoryAuth,
+		meaning:  "The message failed the DMARC policy of the From domain.",
+		hint:     "Make sure SPF or DKIM passes with a domain aligned with the From domain (mxclone auth).",
+	},
+	{
+		pattern:  regexp.MustCompile(`(?i)\bspf\b`),
+		category: ReplyCategoryAuth,
+		meaning:  "The sending IP is not authorized by the SPF record of the envelope sender domain.",
+		hint:     "Add the sending IP or its provider's include to the SPF record (mxclone auth).",
+	},
+	{
+		pattern:  regexp.MustCompile(`(?i)\bdkim\b`),
+		category: ReplyCategoryAuth,
+		meaning:  "The DKIM signature of the message is missing or does not verify.",
+		hint:     "Sign outgoing mail with DKIM and check the published key (mxclone auth --check-dkim).",
+	},
+	{
+		pattern:  regexp.MustCompile(`(?i)block ?list|black ?list|\blisted\b|\brbl\b|\bdnsbl\b|blocked using`),
+		category: ReplyCategoryReputation,
+		meaning:  "The sending IP or domain is on a blocklist used by the receiving server.",
+		hint:     "Check the IP with mxclone blacklist, fix the cause and request removal from the lists.",
+	},
+	{
+		pattern:  regexp.MustCompile(`(?i)mailbox (is )?full|over (the )?quota|quota exceeded|insufficient storage`),
+		category: ReplyCategoryMailbox,
+		meaning:  "The recipient's mailbox is full.",
+		hint:     "Nothing to fix on the sending side; the recipient must free space. Persistent failures mean an abandoned mailbox.",
+	},
+	{
+		pattern:  regexp.MustCompile(`(?i)sender address rejected|sender domain|domain (name )?not found|sender verify failed`),
+		category: ReplyCategoryPolicy,
+		meaning:  "The server rejected the envelope sender address, typically because its domain does not resolve or the address could not be verified.",
+		hint:     "Use a MAIL FROM address whose domain has MX or address records and whose mailbox accepts mail.",
+	},
+	{
+		pattern:  regexp.MustCompile(`(?i)user unknown|unknown user|no such (user|recipient|mailbox)|does not exist|doesn't exist|unknown recipient|invalid recipient|recipient (address )?rejected|mailbox unavailable|address rejected|not found`),
+		category: ReplyCategoryMailbox,
+		meaning:  "The recipient address does not exist on the receiving server.",
+		hint:     "Check the address for typos and remove it from your lists if it keeps failing.",
+	},
+	{
+		pattern:  regexp.MustCompile(`(?i)too many (connections|messages|recipients)|rate limit|rate-limit|throttl`),
+		category: ReplyCategoryPolicy,
+		meaning:  "The server limits how fast or how much the client may send.",
+		hint:     "Lower the sending rate or the number of recipients per message; deferred messages are retried.",
+	},
+	{
+		pattern:  regexp.MustCompile(`(?i)message (size|too large|too big)|size limit|exceeds.*(size|limit)`),
+		category: ReplyCategoryContent,
+		meaning:  "The message is larger than the receiving server accepts.",
+		hint:     "Reduce the message size, for example by sharing attachments as links.",
+	},
+}
+
+// replyCodeRegex finds a reply code, optionally followed by an enhanced status code, in a reply
+// or in a bounce (Diagnostic-Code fields, "host said:" lines)
+var replyCodeRegex = regexp.MustCompile(`(?m)(?:^|[\s;:(])([2345][0-5]\d)(?:[ -]|$)(?:\s*#?([245]\.\d{1,3}\.\d{1,3})\b)?`)
+
+// enhancedStatusRegex matches an RFC 3463 enhanced status code. It is only applied where an
+// enhanced code is expected: numbers elsewhere in a reply are often IP addresses or versions.
+var enhancedStatusRegex = regexp.MustCompile(`\b([245])\.(\d{1,3})\.(\d{1,3})\b`)
+
+// dsnStatusRegex finds the Status field of a delivery status notification
+var dsnStatusRegex = regexp.MustCompile(`(?im)^\s*Status:\s*([245]\.\d{1,3}\.\d{1,3})\b`)
+
+// ParseReply extracts the reply code and enhanced status code of an SMTP reply or bounce.
+// Multi-line replies ("550-5.7.26 ...") and bounce texts ("Status: 5.1.1",
+// "Diagnostic-Code: smtp; 550 5.1.1 ...") are accepted. The enhanced code is only taken from
+// right after the reply code or from a Status: line. Missing values are zero or empty.
+func ParseReply(text string) (int, string) {
+	code, enhanced := 0, ""
+	if m := replyCodeRegex.FindStringSubmatch(text); m != nil {
+		code, _ = strconv.Atoi(m[1])
+		enhanced = m[2]
+	}
+	if enhanced == "" {
+		if m := dsnStatusRegex.FindStringSubmatch(text); m != nil {
+			enhanced = m[1]
+		}
+	}
+	return code, enhanced
+}
+
+// ExplainReply classifies an SMTP reply or bounce text
+func (s *Service) ExplainReply(reply string) *ReplyExplanation {
+	code, enhanced := ParseReply(reply)
+	return s.ExplainCode(code, enhanced, reply)
+}
+
+// ExplainCode classifies a reply from its code, enhanced status code and text. The enhanced code
+// is parsed from the text when it is not given, and ignored when its class contradicts the code.
+func (s *Service) ExplainCode(code int, enhanced, text string) *ReplyExplanation {
+	if enhanced == "" {
+		_, enhanced = ParseReply(text)
+	}
+	// The class of the enhanced code must match the reply code (RFC 3463 section 2)
+	if m := enhancedStatusRegex.FindStringSubmatch(enhanced); m == nil || (code != 0 && m[1] != strconv.Itoa(code/100)) {
+		enhanced = ""
+	}
+
+	explanation := &ReplyExplanation{
+		Reply:        strings.TrimSpace(text),
+		Code:         code,
+		EnhancedCode: enhanced,
+		Category:     ReplyCategoryUnknown,
+	}
+
+	// The enhanced status code refines the basic reply code (RFC 3463 section 3.1); without a
+	// reply code, as in a Status: line of a bounce, its class is used
+	class := code / 100
+	if m := enhancedStatusRegex.FindStringSubmatch(enhanced); m != nil {
+		if code == 0 {
+			class, _ = strconv.Atoi(m[1])
+		}
+		subject, _ := strconv.Atoi(m[2])
+		detail := fmt.Sprintf("%s.%s", m[2], strings.TrimLeft(m[3], "0"))
+		if strings.TrimLeft(m[3], "0") == "" {
+			detail = m[2] + ".0"
+		}
+
+		explanation.StatusSubject = enhancedStatusSubjects[subject]
+		explanation.StatusDetail = enhancedStatusDetails[detail]
+		if category, ok := enhancedStatusCategories[detail]; ok {
+			explanation.Category = category
+		} else if category, ok := subjectCategories[subject]; ok {
+			explanation.Category = category
+		}
+	}
+	switch class {
+	case 2:
+		explanation.Class = ReplyClassSuccess
+	case 3:
+		explanation.Class = ReplyClassIntermediate
+	case 4:
+		explanation.Class = ReplyClassTransient
+	case 5:
+		explanation.Class = ReplyClassPermanent
+	}
+
+	if explanation.Class == ReplyClassSuccess || explanation.Class == ReplyClassIntermediate {
+		explanation.Category = ""
+		explanation.Meaning = "The server accepted the command."
+		return explanation
+	}
+
+	for _, rule := range replyRules {
+		if rule.pattern.MatchString(text) {
+			explanation.Provider = rule.provider
+			explanation.Category = rule.category
+			explanation.Meaning = rule.meaning
+			explanation.Hint = rule.hint
+			break
+		}
+	}
+
+	if explanation.Meaning == "" && explanation.StatusDetail != "" {
+		explanation.Meaning = explanation.StatusDetail + "."
+	}
+	if explanation.Hint == "" {
+		explanation.Hint = categoryHints[explanation.Category]
+	}
+	if explanation.Class == ReplyClassTransient && !strings.Contains(strings.ToLower(explanation.Hint), "retr") {
+		explanation.Hint = strings.TrimSpace("The failure is temporary and the message is retried. " + explanation.Hint)
+	}
+
+	return explanation
+}
+
+// explainFailure explains a reply when it is a failure (4xx or 5xx), and returns nil otherwise
+func (s *Service) explainFailure(code int, enhanced, text string) *ReplyExplanation {
+	if code < 400 {
+		return nil
+	}
+	return s.ExplainCode(code, enhanced, text)
+}
+
+// FormatReplyExplanation returns a human-readable explanation of an SMTP reply
+func (s *Service) FormatReplyExplanation(explanation *ReplyExplanation) string {
+	if explanation == nil {
+		return "No explanation available"
+	}
+
+	// Continuation lines of multi-line replies and bounces are aligned under the first one
+	summary := fmt.Sprintf("Reply: %s\n", strings.ReplaceAll(explanation.Reply, "\n", "\n       "))
+	if explanation.Code != 0 {
+		summary += fmt.Sprintf("Code: %d\n", explanation.Code)
+	}
+	if explanation.EnhancedCode != "" {
+		summary += fmt.Sprintf("Enhanced status: %s", explanation.EnhancedCode)
+		if explanation.StatusDetail != "" {
+			summary += fmt.Sprintf(" - %s (%s)", explanation.StatusDetail, explanation.StatusSubject)
+		} else if explanation.StatusSubject != "" {
+			summary += fmt.Sprintf(" (%s)", explanation.StatusSubject)
+		}
+		summary += "\n"
+	}
+	if explanation.Class == "" {
+		summary += "Class: unknown (no reply or status code found)\n"
+	} else {
+		summary += fmt.Sprintf("Class: %s\n", explanation.Class)
+	}
+	if explanation.Category != "" {
+		summary += fmt.Sprintf("Category: %s\n", explanation.Category)
+	}
+	if explanation.Provider != "" {
+		summary += fmt.Sprintf("Recognized: %s\n", explanation.Provider)
+	}
+	if explanation.Meaning != "" {
+		summary += fmt.Sprintf("Meaning: %s\n", explanation.Meaning)
+	}
+	if explanation.Hint != "" {
+		summary += fmt.Sprintf("Hint: %s\n", explanation.Hint)
+	}
+	return summary
+}
+
+// formatExplanationLines renders the category and hint of an explanation under a reply
+func formatExplanationLines(explanation *ReplyExplanation, indent string) string {
+	if explanation == nil {
+		return ""
+	}
+	line := fmt.Sprintf("%sExplanation: %s, %s", indent, explanation.Class, explanation.Category)
+	if explanation.Provider != "" {
+		line += " (" + explanation.Provider + ")"
+	}
+	if explanation.Meaning != "" {
+		line += " - " + explanation.Meaning
+	}
+	line += "\n"
+	if explanation.Hint != "" {
+		line += fmt.Sprintf("%sHint: %s\n", indent, explanation.Hint)
+	}
+	return line
+}
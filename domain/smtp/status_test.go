@@ -0,0 +1,129 @@
+package smtp
+
+import "testing"
+
+// TestParseReply tests extraction of reply and enhanced status codes from replies and bounces
+func TestParseReply(t *testing.T) {
+	tests := []struct {
+		name         string
+		text         string
+		wantCode     int
+		wantEnhanced string
+	}{
+		{"single line", "250 2.0.0 Ok: queued as 4F2B81C0A2", 250, "2.0.0"},
+		{"no enhanced code", "421 mx.example.com Service not available, closing transmission channel", 421, ""},
+		{"multi-line", "550-5.7.26 This mail is unauthenticated, which poses a security risk to the\r\n550-5.7.26 sender and Gmail users, and has been blocked.\r\n550 5.7.26 https://support.google.com/mail/answer/81126", 550, "5.7.26"},
+		{"IPv4 in the text", "550 Mail from 2.56.1.3 rejected by policy", 550, ""},
+		{"bracketed IPv4 in the text", "554 Service unavailable; Client host [4.3.2.1] blocked using zen.spamhaus.org", 554, ""},
+		{"version in the text", "421 4.3.2 Service shutting down (Postfix 3.7.10)", 421, "4.3.2"},
+		{"DSN body", "Reporting-MTA: dns; mx.example.com\r\nFinal-Recipient: rfc822; bob@example.net\r\nAction: failed\r\nStatus: 5.1.1\r\nDiagnostic-Code: smtp; 550 5.1.1 <bob@example.net>: Recipient address rejected: User unknown", 550, "5.1.1"},
+		{"DSN without enhanced code in the diagnostic", "Action: failed\r\nStatus: 5.0.0\r\nDiagnostic-Code: smtp; 550 rejected by 192.0.2.1", 550, "5.0.0"},
+		{"DSN status only", "Action: delayed\r\nStatus: 4.4.1\r\nWill-Retry-Until: Fri, 1 Mar 2024 10:00:00 +0000", 0, "4.4.1"},
+		{"host said", "<bob@example.net>: host mx.example.net[192.0.2.25] said: 552 5.2.2 Mailbox full (in reply to RCPT TO command)", 552, "5.2.2"},
+		{"no code", "Connection timed out", 0, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, enhanced := ParseReply(tt.text)
+			if code != tt.wantCode || enhanced != tt.wantEnhanced {
+				t.Errorf("ParseReply() = %d, %q, want %d, %q", code, enhanced, tt.wantCode, tt.wantEnhanced)
+			}
+		})
+	}
+}
+
+// TestExplainReply tests the class and category of replies, and that addresses in the text do not
+// change them
+func TestExplainReply(t *testing.T) {
+	s := NewService()
+
+	tests := []struct {
+		name         string
+		reply        string
+		wantClass    string
+		wantCategory string
+		wantEnhanced string
+		wantProvider string
+	}{
+		{"accepted", "250 2.0.0 Ok: queued", ReplyClassSuccess, "", "2.0.0", ""},
+		{"IPv4 in the text", "550 Mail from 2.56.1.3 rejected by policy", ReplyClassPermanent, ReplyCategoryUnknown, "", ""},
+		{"bracketed IPv4 in the text", "554 Service unavailable; Client host [4.3.2.1] blocked using zen.spamhaus.org", ReplyClassPermanent, ReplyCategoryReputation, "", "Spamhaus"},
+		{"contradicting enhanced code", "550 4.7.1 Try again later", ReplyClassPermanent, ReplyCategoryUnknown, "", ""},
+		{"transient", "450 4.2.0 Mailbox busy", ReplyClassTransient, ReplyCategoryMailbox, "4.2.0", ""},
+		{"multi-line", "550-5.7.26 This mail is unauthenticated, which poses a security risk to the\r\n550-5.7.26 sender and Gmail users, and has been blocked.", ReplyClassPermanent, ReplyCategoryAuth, "5.7.26", "Gmail"},
+		{"DSN body", "Action: failed\r\nStatus: 5.1.1\r\nDiagnostic-Code: smtp; 550 5.1.1 <bob@example.net>: User unknown", ReplyClassPermanent, ReplyCategoryMailbox, "5.1.1", ""},
+		{"DSN status only", "Action: delayed\r\nStatus: 4.4.1", ReplyClassTransient, ReplyCategorySystem, "4.4.1", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			explanation := s.ExplainReply(tt.reply)
+			if explanation.Class != tt.wantClass {
+				t.Errorf("Class = %q, want %q", explanation.Class, tt.wantClass)
+			}
+			if explanation.Category != tt.wantCategory {
+				t.Errorf("Category = %q, want %q", explanation.Category, tt.wantCategory)
+			}
+			if explanation.EnhancedCode != tt.wantEnhanced {
+				t.Errorf("EnhancedCode = %q, want %q", explanation.EnhancedCode, tt.wantEnhanced)
+			}
+			if explanation.Provider != tt.wantProvider {
+				t.Errorf("Provider = %q, want %q", explanation.Provider, tt.wantProvider)
+			}
+		})
+	}
+}
+
+// TestExplainReplyRules tests that each provider and rejection text rule recognizes its reply
+func TestExplainReplyRules(t *testing.T) {
+	s := NewService()
+
+	// One reply per entry of replyRules, in the same order
+	replies := []string{
+		"554 5.7.1 Service unavailable; Client host [192.0.2.1] blocked using zen.spamhaus.org; https://www.spamhaus.org/query/ip/192.0.2.1 PBL",
+		"554 5.7.1 Service unavailable; Client host [192.0.2.1] blocked using zen.spamhaus.org",
+		"554 5.7.1 Blocked - see https://www.spamcop.net/bl.shtml?192.0.2.1",
+		"554 5.7.1 Service unavailable; Client host [192.0.2.1] blocked using Barracuda Reputation",
+		"550 5.7.1 Unfortunately, messages from [192.0.2.1] weren't sent. Part of their network is on our block list (S3150).",
+		"550 5.7.606 Access denied, banned sending IP [192.0.2.1].",
+		"550 5.7.708 Service unavailable. Access denied, traffic not accepted from this IP.",
+		"550 5.7.750 Service unavailable. Client blocked from sending from unregistered domains.",
+		"550 5.4.1 Recipient address rejected: Access denied. AS(201806281)",
+		"550 5.7.515 Access denied, sending domain example.com does not meet the required authentication level.",
+		"550-5.7.26 This mail is unauthenticated, which poses a security risk to the\r\n550-5.7.26 sender and Gmail users, and has been blocked.",
+		"550-5.7.25 The IP address sending this message does not have a PTR record setup.",
+		"421-4.7.28 Gmail has detected an unusual rate of unsolicited mail originating from your IP address.",
+		"550 5.7.1 Our system has detected that this message is likely unsolicited mail.",
+		"450-4.2.1 The user you are trying to contact is receiving mail at a rate that prevents additional messages from being delivered.",
+		"421 4.7.0 [TSS04] Messages from 192.0.2.1 temporarily deferred due to unexpected volume or user complaints",
+		"451 4.7.1 Greylisting in action, please come back later",
+		"554 5.7.1 <bob@example.net>: Relay access denied",
+		"530 5.7.0 Authentication required",
+		"530 5.7.0 STARTTLS required",
+		"550 5.7.1 Unauthenticated email from example.com is not accepted due to domain's DMARC policy",
+		"550 5.7.1 SPF check failed for 192.0.2.1",
+		"550 5.7.1 DKIM signature verification failed",
+		"554 5.7.1 Service unavailable; Client host [192.0.2.1] blocked using bl.example.net",
+		"452 4.2.2 Mailbox full",
+		"553 5.1.8 <alice@example.invalid>: Sender address rejected: Domain not found",
+		"550 5.1.1 <bob@example.net>: Recipient address rejected: User unknown in local recipient table",
+		"421 4.7.0 Too many connections from 192.0.2.1",
+		"552 5.3.4 Message size exceeds fixed limit",
+	}
+	if len(replies) != len(replyRules) {
+		t.Fatalf("%d replies for %d rules", len(replies), len(replyRules))
+	}
+
+	for i, reply := range replies {
+		rule := replyRules[i]
+		explanation := s.ExplainReply(reply)
+		if explanation.Meaning != rule.meaning || explanation.Provider != rule.provider || explanation.Category != rule.category {
+			t.Errorf("ExplainReply(%q) = %s %s %q, want rule %d (%s %s %q)", reply,
+				explanation.Provider, explanation.Category, explanation.Meaning, i, rule.provider, rule.category, rule.meaning)
+		}
+		if explanation.Class != ReplyClassPermanent && explanation.Class != ReplyClassTransient {
+			t.Errorf("ExplainReply(%q) Class = %q", reply, explanation.Class)
+		}
+	}
+}
@@ -0,0 +1,332 @@
+package smtp
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"math/rand"
+	"strings"
+)
+
+// Email verification verdicts
+const (
+	// VerdictDeliverable means the mail exchanger accepted the address and is not a catch-all
+	VerdictDeliverable = "deliverable"
+	// VerdictUndeliverable means the address is malformed, the domain cannot receive mail or the address was refused
+	VerdictUndeliverable = "undeliverable"
+	// VerdictRisky means the address was accepted but may not reach a person (catch-all or disposable domain)
+	VerdictRisky = "risky"
+	// VerdictUnknown means the mail exchanger could not be reached or deferred the answer
+	VerdictUnknown = "unknown"
+)
+
+// roleAccounts are local parts that address a function rather than a person (RFC 2142 and common practice)
+var roleAccounts = map[string]bool{
+	"abuse": true, "admin": true, "administrator": true, "billing": true, "contact": true,
+	"help": true, "hostmaster": true, "info": true, "mailer-daemon": true, "marketing": true,
+	"news": true, "noc": true, "no-reply": true, "noreply": true, "office": true,
+	"postmaster": true, "root": true, "sales": true, "security": true, "support": true,
+	"sysadmin": true, "team": true, "usenet": true, "uucp": true, "webmaster": true, "www": true,
+}
+
+//go:embed disposable_domains.txt
+var disposableDomainList string
+
+// disposableDomains is the bundled list of disposable (throwaway) mailbox providers
+var disposableDomains = parseDomainList(disposableDomainList)
+
+// parseDomainList reads one domain per line, ignoring blank lines and # comments
+func parseDomainList(list string) map[string]bool {
+	domains := make(map[string]bool)
+	scanner := bufio.NewScanner(strings.NewReader(list))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		domains[strings.ToLower(line)] = true
+	}
+	return domains
+}
+
+// RecipientReply represents the reply of a mail exchanger to one RCPT TO
+type RecipientReply struct {
+	Address      string
+	Code         int
+	EnhancedCode string
+	Response     string
+	Accepted     bool
+	Deferred     bool
+	Rejected     bool
+	Error        string
+}
+
+// RecipientProbe represents the outcome of an RCPT TO probe against a mail exchanger
+type RecipientProbe struct {
+	// Mail exchanger that was probed
+	Host string
+	// Whether the session was encrypted with STARTTLS
+	TLS bool
+	// Replies to each RCPT TO, in the order they were sent
+	Replies []RecipientReply
+	// Full session transcript
+	Transcript []TranscriptEntry
+	// Error message if any
+	Error string
+}
+
+// EmailVerificationResult represents the result of verifying an email address
+type EmailVerificationResult struct {
+	// Address as given and in normalized form (ASCII domain)
+	Address           string
+	NormalizedAddress string
+	LocalPart         string
+	Domain            string
+	// Whether the address is a valid RFC 5322 addr-spec
+	ValidSyntax bool
+	SyntaxError string
+	// Whether the local part is a role account (postmaster, info, ...)
+	RoleAccount bool
+	// Whether the domain is a known disposable mailbox provider
+	Disposable bool
+	// MX hosts of the domain, and whether the domain itself was used for lack of MX records
+	MXHosts    []MXHost
+	ImplicitMX bool
+	// Mail exchanger that answered the probe
+	MailServer string
+	// Reply to RCPT TO for the address
+	RcptCode     int
+	EnhancedCode string
+	RcptResponse string
+	Accepted     bool
+	// Classification of a refused or deferred RCPT TO reply
+	Explanation *ReplyExplanation
+	// Whether a random address at the domain was tested and accepted
+	CatchAllTested bool
+	CatchAll       bool
+	// Verdict (deliverable, undeliverable, risky, unknown) and the reasons behind it
+	Verdict string
+	Reasons []string
+	// Full session transcript of the probe
+	Transcript []TranscriptEntry
+	// Error message if any
+	Error string
+}
+
+// BulkEmailVerificationResult represents the result of verifying several email addresses
+type BulkEmailVerificationResult struct {
+	// Results in the order the addresses were given
+	Results []*EmailVerificationResult
+	// Number of results per verdict
+	Counts map[string]int
+}
+
+// IsRoleAccount reports whether a local part addresses a function rather than a person.
+// Subaddresses (role+tag) are matched on the part before the separator.
+func (s *Service) IsRoleAccount(localPart string) bool {
+	local := strings.ToLower(localPart)
+	if i := strings.IndexByte(local, '+'); i > 0 {
+		local = local[:i]
+	}
+	return roleAccounts[local]
+}
+
+// IsDisposableDomain reports whether a domain, or a parent of it, is a known disposable mailbox provider
+func (s *Service) IsDisposableDomain(domain string) bool {
+	domain = strings.TrimSuffix(strings.ToLower(domain), ".")
+	for {
+		if disposableDomains[domain] {
+			return true
+		}
+		i := strings.IndexByte(domain, '.')
+		if i < 0 {
+			return false
+		}
+		domain = domain[i+1:]
+	}
+}
+
+// CatchAllProbeAddress returns a random address at a domain that is very unlikely to exist
+func (s *Service) CatchAllProbeAddress(domain string) string {
+	const letters = "abcdefghijklmnopqrstuvwxyz0123456789"
+	local := make([]byte, 20)
+	for i := range local {
+		local[i] = letters[rand.Intn(len(letters))]
+	}
+	return "mxclone-" + string(local) + "@" + domain
+}
+
+// CreateEmailVerificationResult starts a verification result from the syntax check of an address
+func (s *Service) CreateEmailVerificationResult(address, normalized string, syntaxErr error) *EmailVerificationResult {
+	result := &EmailVerificationResult{
+		Address:     address,
+		ValidSyntax: syntaxErr == nil,
+	}
+	if syntaxErr != nil {
+		result.SyntaxError = syntaxErr.Error()
+		return result
+	}
+
+	at := strings.LastIndex(normalized, "@")
+	result.NormalizedAddress = normalized
+	result.LocalPart = normalized[:at]
+	result.Domain = normalized[at+1:]
+	result.RoleAccount = s.IsRoleAccount(result.LocalPart)
+	result.Disposable = s.IsDisposableDomain(result.Domain)
+
+	return result
+}
+
+// ApplyRecipientProbe records the replies of a probe; catchAllAddress is the random address offered with it, if any
+func (s *Service) ApplyRecipientProbe(result *EmailVerificationResult, probe *RecipientProbe, catchAllAddress string) {
+	if probe == nil {
+		return
+	}
+	result.MailServer = probe.Host
+	result.Transcript = probe.Transcript
+	result.Error = probe.Error
+
+	for _, reply := range probe.Replies {
+		switch reply.Address {
+		case result.NormalizedAddress:
+			result.RcptCode = reply.Code
+			result.EnhancedCode = reply.EnhancedCode
+			result.RcptResponse = reply.Response
+			result.Accepted = reply.Accepted
+		case catchAllAddress:
+			result.CatchAllTested = reply.Code != 0
+			result.CatchAll = reply.Accepted
+		}
+	}
+}
+
+// ProcessEmailVerificationResult sets the verdict of a verification result from what was learned
+func (s *Service) ProcessEmailVerificationResult(result *EmailVerificationResult, err error) *EmailVerificationResult {
+	if err != nil && result.Error == "" {
+		result.Error = err.Error()
+	}
+
+	result.Reasons = nil
+	result.Explanation = s.explainFailure(result.RcptCode, result.EnhancedCode, result.RcptResponse)
+	switch {
+	case !result.ValidSyntax:
+		result.Verdict = VerdictUndeliverable
+		result.Reasons = append(result.Reasons, "Invalid address syntax: "+result.SyntaxError)
+	case result.MailServer == "" && len(result.MXHosts) == 0 && !result.ImplicitMX && result.Error != "":
+		result.Verdict = VerdictUnknown
+		result.Reasons = append(result.Reasons, "Mail exchangers of the domain could not be determined")
+	case result.MailServer == "" && len(result.MXHosts) == 0 && !result.ImplicitMX:
+		result.Verdict = VerdictUndeliverable
+		result.Reasons = append(result.Reasons, "Domain has no MX or address records")
+	case result.RcptCode == 0:
+		result.Verdict = VerdictUnknown
+		result.Reasons = append(result.Reasons, "Mail exchanger could not be probed")
+	case result.RcptCode >= 500:
+		result.Verdict = VerdictUndeliverable
+		result.Reasons = append(result.Reasons, fmt.Sprintf("Recipient refused: %d %s", result.RcptCode, result.RcptResponse))
+	case result.RcptCode >= 400:
+		result.Verdict = VerdictUnknown
+		result.Reasons = append(result.Reasons, fmt.Sprintf("Recipient deferred (possibly greylisting): %d %s", result.RcptCode, result.RcptResponse))
+	case result.CatchAll:
+		result.Verdict = VerdictRisky
+		result.Reasons = append(result.Reasons, "Domain accepts any recipient (catch-all); the mailbox may not exist")
+	case result.Disposable:
+		result.Verdict = VerdictRisky
+	default:
+		result.Verdict = VerdictDeliverable
+	}
+
+	if result.Disposable {
+		result.Reasons = append(result.Reasons, "Domain is a disposable mailbox provider")
+	}
+	if result.RoleAccount {
+		result.Reasons = append(result.Reasons, "Address is a role account")
+	}
+	if result.ImplicitMX {
+		result.Reasons = append(result.Reasons, "Domain has no MX records; its address records were used")
+	}
+
+	return result
+}
+
+// ProcessBulkEmailVerificationResult counts the verdicts of several verification results
+func (s *Service) ProcessBulkEmailVerificationResult(results []*EmailVerificationResult) *BulkEmailVerificationResult {
+	bulk := &BulkEmailVerificationResult{
+		Results: results,
+		Counts:  make(map[string]int),
+	}
+	for _, result := range results {
+		bulk.Counts[result.Verdict]++
+	}
+	return bulk
+}
+
+// FormatEmailVerificationSummary returns a human-readable summary of an email verification
+func (s *Service) FormatEmailVerificationSummary(result *EmailVerificationResult) string {
+	if result == nil {
+		return "No verification results available"
+	}
+
+	summary := fmt.Sprintf("Verification of %s: %s\n", result.Address, strings.ToUpper(result.Verdict))
+	if result.NormalizedAddress != "" && result.NormalizedAddress != result.Address {
+		summary += fmt.Sprintf("Normalized: %s\n", result.NormalizedAddress)
+	}
+	if result.ValidSyntax {
+		summary += fmt.Sprintf("Role account: %t\n", result.RoleAccount)
+		summary += fmt.Sprintf("Disposable domain: %t\n", result.Disposable)
+	}
+	if len(result.MXHosts) > 0 {
+		hosts := make([]string, 0, len(result.MXHosts))
+		for _, mx := range result.MXHosts {
+			hosts = append(hosts, fmt.Sprintf("%s (%d)", mx.Host, mx.Preference))
+		}
+		summary += fmt.Sprintf("MX hosts: %s\n", strings.Join(hosts, ", "))
+	}
+	if result.MailServer != "" {
+		summary += fmt.Sprintf("Mail server: %s\n", result.MailServer)
+	}
+	if result.RcptCode != 0 {
+		summary += fmt.Sprintf("RCPT TO: %d %s\n", result.RcptCode, result.RcptResponse)
+	}
+	summary += formatExplanationLines(result.Explanation, "")
+	if result.CatchAllTested {
+		summary += fmt.Sprintf("Catch-all: %t\n", result.CatchAll)
+	}
+	for _, reason := range result.Reasons {
+		summary += fmt.Sprintf("- %s\n", reason)
+	}
+
+	if len(result.Transcript) > 0 {
+		summary += "\nTranscript:\n" + FormatTranscript(result.Transcript, "  ")
+	}
+
+	if result.Error != "" {
+		summary += fmt.Sprintf("\nErrors: %s\n", result.Error)
+	}
+
+	return summary
+}
+
+// FormatBulkEmailVerificationSummary returns a one-line-per-address summary of a bulk verification
+func (s *Service) FormatBulkEmailVerificationSummary(result *BulkEmailVerificationResult) string {
+	if result == nil || len(result.Results) == 0 {
+		return "No verification results available"
+	}
+
+	summary := fmt.Sprintf("Verified %d addresses:\n", len(result.Results))
+	for _, r := range result.Results {
+		line := fmt.Sprintf("%-40s %-13s", r.Address, r.Verdict)
+		if len(r.Reasons) > 0 {
+			line += " " + r.Reasons[0]
+		}
+		summary += line + "\n"
+	}
+
+	summary += "\nTotals:"
+	for _, verdict := range []string{VerdictDeliverable, VerdictRisky, VerdictUndeliverable, VerdictUnknown} {
+		summary += fmt.Sprintf(" %s=%d", verdict, result.Counts[verdict])
+	}
+	summary += "\n"
+
+	return summary
+}
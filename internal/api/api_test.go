@@ -7,9 +7,11 @@ import (
 	"mxclone/domain/dns"
 	"mxclone/domain/dnsbl"
 	"mxclone/domain/emailauth"
+	"mxclone/domain/mailaccess"
 	"mxclone/domain/networktools"
 	"mxclone/domain/smtp"
 	"mxclone/internal/api"
+	"mxclone/internal/api/handlers"
 	"mxclone/internal/api/models"
 	"mxclone/pkg/logging"
 	"net/http"
@@ -69,7 +71,8 @@ func (m *MockDNSBLService) CheckMultipleDNSBLHealth(ctx context.Context, zones [
 
 // MockSMTPService is a mock implementation of input.SMTPPort
 type MockSMTPService struct {
-	// Add mock fields as needed
+	// Config of the last StartSink call
+	sinkConfig *smtp.SinkConfig
 }
 
 func (m *MockSMTPService) CheckSMTP(ctx context.Context, domain string, timeout time.Duration) (*smtp.SMTPResult, error) {
@@ -77,7 +80,101 @@ func (m *MockSMTPService) CheckSMTP(ctx context.Context, domain string, timeout
 }
 
 func (m *MockSMTPService) TestSMTPConnection(ctx context.Context, server string, port int, timeout time.Duration) (*smtp.ConnectionResult, error) {
-	return &smtp.ConnectionResult{Server: server, Connected: true}, nil
+	return &smtp.ConnectionResult{
+		Server:           server,
+		Connected:        true,
+		Transcript:       []smtp.TranscriptEntry{{Direction: "server", Line: "220 mx.example.com ESMTP"}},
+		STARTTLSSecurity: &smtp.STARTTLSSecurity{Advertised: true, Transcript: []smtp.TranscriptEntry{{Direction: "client", Line: "STARTTLS"}}},
+	}, nil
+}
+
+func (m *MockSMTPService) CheckSMTPMatrix(ctx context.Context, domain string, ports []int, timeout time.Duration) (*smtp.MatrixResult, error) {
+	return &smtp.MatrixResult{Domain: domain, Ports: ports}, nil
+}
+
+func (m *MockSMTPService) GetSMTPMatrixSummary(result *smtp.MatrixResult) string {
+	return "SMTP matrix summary"
+}
+
+func (m *MockSMTPService) TestOpenRelay(ctx context.Context, req *smtp.RelayTestRequest) (*smtp.RelayTestResult, error) {
+	return &smtp.RelayTestResult{Host: req.Host, Port: req.Port}, nil
+}
+
+func (m *MockSMTPService) GetRelayTestSummary(result *smtp.RelayTestResult) string {
+	return "Relay test summary"
+}
+
+func (m *MockSMTPService) TestSubmission(ctx context.Context, req *smtp.SubmissionTestRequest) (*smtp.SubmissionTestResult, error) {
+	return &smtp.SubmissionTestResult{Host: req.Host, Port: req.Port}, nil
+}
+
+func (m *MockSMTPService) GetSubmissionTestSummary(result *smtp.SubmissionTestResult) string {
+	return "Submission test summary"
+}
+
+func (m *MockSMTPService) TestSmuggling(ctx context.Context, req *smtp.SmugglingTestRequest) (*smtp.SmugglingTestResult, error) {
+	return &smtp.SmugglingTestResult{Domain: req.Domain, Host: req.Host, Port: req.Port}, nil
+}
+
+func (m *MockSMTPService) GetSmugglingTestSummary(result *smtp.SmugglingTestResult) string {
+	return "Smuggling test summary"
+}
+
+func (m *MockSMTPService) VerifyEmail(ctx context.Context, address string, timeout time.Duration) (*smtp.EmailVerificationResult, error) {
+	return &smtp.EmailVerificationResult{
+		Address:    address,
+		Verdict:    smtp.VerdictUnknown,
+		Transcript: []smtp.TranscriptEntry{{Direction: "client", Line: "RCPT TO:<" + address + ">"}},
+	}, nil
+}
+
+func (m *MockSMTPService) VerifyEmails(ctx context.Context, addresses []string, timeout time.Duration) (*smtp.BulkEmailVerificationResult, error) {
+	return &smtp.BulkEmailVerificationResult{}, nil
+}
+
+func (m *MockSMTPService) GetEmailVerificationSummary(result *smtp.EmailVerificationResult) string {
+	return "Email verification summary"
+}
+
+func (m *MockSMTPService) GetBulkEmailVerificationSummary(result *smtp.BulkEmailVerificationResult) string {
+	return "Bulk email verification summary"
+}
+
+func (m *MockSMTPService) StartSink(ctx context.Context, config *smtp.SinkConfig) (*smtp.SinkStatus, error) {
+	m.sinkConfig = config
+	return &smtp.SinkStatus{Running: true, Address: config.Address, Domain: config.Domain}, nil
+}
+
+func (m *MockSMTPService) StopSink(ctx context.Context) error {
+	return nil
+}
+
+func (m *MockSMTPService) GetSinkStatus(ctx context.Context) *smtp.SinkStatus {
+	return &smtp.SinkStatus{}
+}
+
+func (m *MockSMTPService) CreateSinkTest(ctx context.Context) (*smtp.SinkTest, error) {
+	return nil, smtp.ErrSinkNotRunning
+}
+
+func (m *MockSMTPService) GetSinkTest(ctx context.Context, id string) (*smtp.SinkTest, error) {
+	return nil, smtp.ErrSinkNotRunning
+}
+
+func (m *MockSMTPService) GetSinkStatusSummary(status *smtp.SinkStatus) string {
+	return "Sink status summary"
+}
+
+func (m *MockSMTPService) GetSinkTestSummary(test *smtp.SinkTest) string {
+	return "Sink test summary"
+}
+
+func (m *MockSMTPService) ExplainReply(reply string) *smtp.ReplyExplanation {
+	return &smtp.ReplyExplanation{Reply: reply}
+}
+
+func (m *MockSMTPService) GetReplyExplanationSummary(explanation *smtp.ReplyExplanation) string {
+	return "Reply explanation summary"
 }
 
 func (m *MockSMTPService) GetSMTPSummary(result *smtp.SMTPResult) string {
@@ -109,6 +206,86 @@ func (m *MockEmailAuthService) GetAuthSummary(result *emailauth.AuthResult) stri
 	return "Auth summary"
 }
 
+func (m *MockEmailAuthService) EvaluateSPF(ctx context.Context, ip, domain, sender, helo string, timeout time.Duration) (*emailauth.SPFEvaluation, error) {
+	return &emailauth.SPFEvaluation{Domain: domain, IP: ip, Result: "pass"}, nil
+}
+
+func (m *MockEmailAuthService) GetSPFEvaluationSummary(result *emailauth.SPFEvaluation) string {
+	return "SPF evaluation summary"
+}
+
+func (m *MockEmailAuthService) AnalyzeSPFLookups(ctx context.Context, domain string, timeout time.Duration) (*emailauth.SPFLookupAnalysis, error) {
+	return &emailauth.SPFLookupAnalysis{Domain: domain, LookupLimit: 10, VoidLookupLimit: 2}, nil
+}
+
+func (m *MockEmailAuthService) GetSPFLookupSummary(result *emailauth.SPFLookupAnalysis) string {
+	return "SPF lookup summary"
+}
+
+func (m *MockEmailAuthService) VerifyDKIM(ctx context.Context, raw []byte, timeout time.Duration) (*emailauth.DKIMVerification, error) {
+	return &emailauth.DKIMVerification{}, nil
+}
+
+func (m *MockEmailAuthService) GetDKIMVerificationSummary(result *emailauth.DKIMVerification) string {
+	return "DKIM verification summary"
+}
+
+func (m *MockEmailAuthService) DiscoverDKIMSelectors(ctx context.Context, domain string, selectors []string, timeout time.Duration) (*emailauth.DKIMDiscovery, error) {
+	return &emailauth.DKIMDiscovery{Domain: domain}, nil
+}
+
+func (m *MockEmailAuthService) GetDKIMDiscoverySummary(result *emailauth.DKIMDiscovery) string {
+	return "DKIM discovery summary"
+}
+
+func (m *MockEmailAuthService) IngestDMARCReports(ctx context.Context, files []emailauth.DMARCReportUpload) (*emailauth.DMARCIngestResult, error) {
+	return &emailauth.DMARCIngestResult{}, nil
+}
+
+func (m *MockEmailAuthService) SummarizeDMARCReports(ctx context.Context, query emailauth.DMARCReportQuery, timeout time.Duration) (*emailauth.DMARCReportSummary, error) {
+	return &emailauth.DMARCReportSummary{Query: query}, nil
+}
+
+func (m *MockEmailAuthService) GetDMARCIngestSummary(result *emailauth.DMARCIngestResult) string {
+	return "DMARC ingest summary"
+}
+
+func (m *MockEmailAuthService) GetDMARCReportSummary(result *emailauth.DMARCReportSummary) string {
+	return "DMARC report summary"
+}
+
+func (m *MockEmailAuthService) VerifyARC(ctx context.Context, raw []byte, timeout time.Duration) (*emailauth.ARCValidation, error) {
+	return &emailauth.ARCValidation{Result: "none"}, nil
+}
+
+func (m *MockEmailAuthService) GetARCValidationSummary(result *emailauth.ARCValidation) string {
+	return "ARC validation"
+}
+
+func (m *MockEmailAuthService) AnalyzeHeaders(ctx context.Context, raw []byte) (*emailauth.HeaderAnalysis, error) {
+	return &emailauth.HeaderAnalysis{}, nil
+}
+
+func (m *MockEmailAuthService) GetHeaderAnalysisSummary(result *emailauth.HeaderAnalysis) string {
+	return "Header analysis"
+}
+
+func (m *MockEmailAuthService) CheckBIMI(ctx context.Context, domain, selector string, timeout time.Duration) (*emailauth.BIMIResult, error) {
+	return &emailauth.BIMIResult{Domain: domain, Selector: selector}, nil
+}
+
+func (m *MockEmailAuthService) GetBIMISummary(result *emailauth.BIMIResult) string {
+	return "BIMI summary"
+}
+
+func (m *MockEmailAuthService) AnalyzeForensicReports(ctx context.Context, files []emailauth.DMARCReportUpload) (*emailauth.ForensicReportAnalysis, error) {
+	return &emailauth.ForensicReportAnalysis{}, nil
+}
+
+func (m *MockEmailAuthService) GetForensicReportSummary(result *emailauth.ForensicReportAnalysis) string {
+	return "DMARC failure report summary"
+}
+
 // MockNetworkToolsService is a mock implementation of input.NetworkToolsPort
 type MockNetworkToolsService struct {
 	// Add mock fields as needed
@@ -146,6 +323,27 @@ func (m *MockNetworkToolsService) TracerouteHop(ctx context.Context, target stri
 	return networktools.TracerouteHop{Number: ttl, IP: "1.1.1.1", RTT: 10 * time.Millisecond}, true, nil
 }
 
+// MockMailAccessService is a mock implementation of input.MailAccessPort
+type MockMailAccessService struct {
+	// Add mock fields as needed
+}
+
+func (m *MockMailAccessService) CheckServer(ctx context.Context, req *mailaccess.CheckRequest) (*mailaccess.CheckResult, error) {
+	return &mailaccess.CheckResult{Protocol: req.Protocol, Host: req.Host, Port: req.Port, Connected: true}, nil
+}
+
+func (m *MockMailAccessService) CheckHost(ctx context.Context, protocol, host string, timeout time.Duration) (*mailaccess.HostCheckResult, error) {
+	return &mailaccess.HostCheckResult{Protocol: protocol, Host: host}, nil
+}
+
+func (m *MockMailAccessService) GetCheckSummary(result *mailaccess.CheckResult) string {
+	return "Mail access check summary"
+}
+
+func (m *MockMailAccessService) GetHostCheckSummary(result *mailaccess.HostCheckResult) string {
+	return "Mail access host check summary"
+}
+
 func TestDNSHandler(t *testing.T) {
 	// Create mock services
 	mockDNSService := &MockDNSService{
@@ -160,6 +358,7 @@ func TestDNSHandler(t *testing.T) {
 	mockSMTPService := &MockSMTPService{}
 	mockEmailAuthService := &MockEmailAuthService{}
 	mockNetworkToolsService := &MockNetworkToolsService{}
+	mockMailAccessService := &MockMailAccessService{}
 
 	// Create a logger
 	logger := logging.NewLogger("test", logging.LevelDebug, os.Stderr)
@@ -171,6 +370,7 @@ func TestDNSHandler(t *testing.T) {
 		mockSMTPService,
 		mockEmailAuthService,
 		mockNetworkToolsService,
+		mockMailAccessService,
 		logger,
 	)
 
@@ -294,3 +494,66 @@ func TestDNSHandler(t *testing.T) {
 		})
 	}
 }
+
+// TestTranscriptOption tests that session transcripts are only returned when requested
+func TestTranscriptOption(t *testing.T) {
+	handler := handlers.NewSMTPHandler(&MockSMTPService{})
+
+	for _, transcript := range []bool{false, true} {
+		body, _ := json.Marshal(models.EmailVerifyRequest{Address: "user@example.com", Transcript: transcript})
+		recorder := httptest.NewRecorder()
+		handler.HandleEmailVerify(recorder, httptest.NewRequest("POST", "/email/verify", bytes.NewReader(body)))
+
+		var response models.EmailVerifyResponse
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Error unmarshaling response: %v", err)
+		}
+		if got := len(response.Transcript) > 0; got != transcript {
+			t.Errorf("verify with transcript=%v: transcript returned = %v", transcript, got)
+		}
+	}
+
+	for query, want := range map[string]bool{"": false, "?transcript=false": false, "?transcript=true": true} {
+		request := httptest.NewRequest("POST", "/smtp/connect/mx.example.com"+query, nil)
+		request.SetPathValue("host", "mx.example.com")
+		recorder := httptest.NewRecorder()
+		handler.HandleSMTPConnect(recorder, request)
+
+		var response models.SMTPConnectionResponse
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Error unmarshaling response: %v", err)
+		}
+		if response.STARTTLSSecurity == nil {
+			t.Fatalf("connect%s: no STARTTLS security result", query)
+		}
+		if got := len(response.Transcript) > 0 && len(response.STARTTLSSecurity.Transcript) > 0; got != want {
+			t.Errorf("connect%s: transcript returned = %v, want %v", query, got, want)
+		}
+	}
+}
+
+// TestSMTPSinkStartAddress tests that API callers cannot choose the address the sink listens on
+func TestSMTPSinkStartAddress(t *testing.T) {
+	service := &MockSMTPService{}
+	handler := handlers.NewSMTPHandler(service)
+
+	recorder := httptest.NewRecorder()
+	body := `{"address": "0.0.0.0:25", "domain": "sink.example.com"}`
+	handler.HandleSMTPSinkStart(recorder, httptest.NewRequest("POST", "/smtp/sink", bytes.NewReader([]byte(body))))
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("start with an address: status = %d, want %d", recorder.Code, http.StatusBadRequest)
+	}
+	if service.sinkConfig != nil {
+		t.Errorf("start with an address: the sink was started with %+v", service.sinkConfig)
+	}
+
+	recorder = httptest.NewRecorder()
+	body = `{"domain": "sink.example.com"}`
+	handler.HandleSMTPSinkStart(recorder, httptest.NewRequest("POST", "/smtp/sink", bytes.NewReader([]byte(body))))
+	if recorder.Code != http.StatusCreated {
+		t.Fatalf("start without an address: status = %d, want %d", recorder.Code, http.StatusCreated)
+	}
+	if service.sinkConfig == nil || service.sinkConfig.Address != "" || service.sinkConfig.Domain != "sink.example.com" {
+		t.Errorf("start without an address: config = %+v, want the configured address left to the service", service.sinkConfig)
+	}
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"io" // Changed from ioutil
 	"mxclone/domain/emailauth"
 	"mxclone/internal/api/models"
@@ -66,6 +67,434 @@ func (h *EmailAuthHandler) HandleSPFCheck(w http.ResponseWriter, r *http.Request
 	json.NewEncoder(w).Encode(response)
 }
 
+// HandleSPFEvaluate handles SPF evaluation requests and returns the evaluation trace
+func (h *EmailAuthHandler) HandleSPFEvaluate(w http.ResponseWriter, r *http.Request) {
+	// Read and parse request body
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.APIError{
+			Error:   "Invalid request body",
+			Code:    http.StatusBadRequest,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	var req models.SPFEvaluateRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.APIError{
+			Error:   "Invalid JSON format",
+			Code:    http.StatusBadRequest,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	// Validate the request
+	validationResult := apivalidation.ValidateSPFEvaluateRequest(&req)
+	if !validationResult.Valid {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":       "Validation failed",
+			"code":        http.StatusBadRequest,
+			"validations": validationResult.Errors,
+		})
+		return
+	}
+
+	// Default timeout is 10 seconds
+	timeout := 10 * time.Second
+	if req.Timeout > 0 {
+		timeout = time.Duration(req.Timeout) * time.Second
+	}
+
+	// Use the email authentication service through the port interface
+	result, err := h.emailAuthService.EvaluateSPF(r.Context(), req.IP, req.Domain, req.Sender, req.Helo, timeout)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(models.APIError{
+			Error:   "SPF evaluation failed",
+			Code:    http.StatusInternalServerError,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	// Convert result to API response
+	response := models.FromSPFEvaluation(result)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// HandleSPFLookupAnalysis handles SPF lookup budget analysis requests and returns the tree as JSON and text
+func (h *EmailAuthHandler) HandleSPFLookupAnalysis(w http.ResponseWriter, r *http.Request) {
+	// Read and parse request body
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.APIError{
+			Error:   "Invalid request body",
+			Code:    http.StatusBadRequest,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	var req models.SPFLookupAnalysisRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.APIError{
+			Error:   "Invalid JSON format",
+			Code:    http.StatusBadRequest,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	// Validate the request
+	validationResult := apivalidation.ValidateSPFLookupAnalysisRequest(&req)
+	if !validationResult.Valid {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":       "Validation failed",
+			"code":        http.StatusBadRequest,
+			"validations": validationResult.Errors,
+		})
+		return
+	}
+
+	// Default timeout is 30 seconds: large trees need many lookups
+	timeout := 30 * time.Second
+	if req.Timeout > 0 {
+		timeout = time.Duration(req.Timeout) * time.Second
+	}
+
+	// Use the email authentication service through the port interface
+	result, err := h.emailAuthService.AnalyzeSPFLookups(r.Context(), req.Domain, timeout)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(models.APIError{
+			Error:   "SPF lookup analysis failed",
+			Code:    http.StatusInternalServerError,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	// Convert result to API response, with the indented text view of the tree
+	response := models.FromSPFLookupAnalysis(result, h.emailAuthService.GetSPFLookupSummary(result))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// HandleDKIMVerify handles verification of the DKIM signatures of an uploaded message
+func (h *EmailAuthHandler) HandleDKIMVerify(w http.ResponseWriter, r *http.Request) {
+	// Read and parse request body
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.APIError{
+			Error:   "Invalid request body",
+			Code:    http.StatusBadRequest,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	var req models.DKIMVerifyRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.APIError{
+			Error:   "Invalid JSON format",
+			Code:    http.StatusBadRequest,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	// Validate the request
+	validationResult := apivalidation.ValidateDKIMVerifyRequest(&req)
+	if !validationResult.Valid {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":       "Validation failed",
+			"code":        http.StatusBadRequest,
+			"validations": validationResult.Errors,
+		})
+		return
+	}
+
+	// Default timeout is 10 seconds per key lookup
+	timeout := 10 * time.Second
+	if req.Timeout > 0 {
+		timeout = time.Duration(req.Timeout) * time.Second
+	}
+
+	// Use the email authentication service through the port interface
+	result, err := h.emailAuthService.VerifyDKIM(r.Context(), []byte(req.Message), timeout)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(models.APIError{
+			Error:   "DKIM verification failed",
+			Code:    http.StatusInternalServerError,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	// Convert result to API response, with the human-readable report
+	response := models.FromDKIMVerification(result, h.emailAuthService.GetDKIMVerificationSummary(result))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// HandleARCVerify handles validation of the ARC chain of an uploaded message
+func (h *EmailAuthHandler) HandleARCVerify(w http.ResponseWriter, r *http.Request) {
+	// Read and parse request body
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.APIError{
+			Error:   "Invalid request body",
+			Code:    http.StatusBadRequest,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	var req models.ARCVerifyRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.APIError{
+			Error:   "Invalid JSON format",
+			Code:    http.StatusBadRequest,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	// Validate the request
+	validationResult := apivalidation.ValidateARCVerifyRequest(&req)
+	if !validationResult.Valid {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":       "Validation failed",
+			"code":        http.StatusBadRequest,
+			"validations": validationResult.Errors,
+		})
+		return
+	}
+
+	// Default timeout is 10 seconds per key lookup
+	timeout := 10 * time.Second
+	if req.Timeout > 0 {
+		timeout = time.Duration(req.Timeout) * time.Second
+	}
+
+	// Use the email authentication service through the port interface
+	result, err := h.emailAuthService.VerifyARC(r.Context(), []byte(req.Message), timeout)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(models.APIError{
+			Error:   "ARC validation failed",
+			Code:    http.StatusInternalServerError,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	// Convert result to API response, with the human-readable report
+	response := models.FromARCValidation(result, h.emailAuthService.GetARCValidationSummary(result))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// HandleHeaderAnalyze handles the analysis of the headers of a message
+func (h *EmailAuthHandler) HandleHeaderAnalyze(w http.ResponseWriter, r *http.Request) {
+	// Read and parse request body
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.APIError{
+			Error:   "Invalid request body",
+			Code:    http.StatusBadRequest,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	var req models.HeaderAnalyzeRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.APIError{
+			Error:   "Invalid JSON format",
+			Code:    http.StatusBadRequest,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	// Validate the request
+	validationResult := apivalidation.ValidateHeaderAnalyzeRequest(&req)
+	if !validationResult.Valid {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":       "Validation failed",
+			"code":        http.StatusBadRequest,
+			"validations": validationResult.Errors,
+		})
+		return
+	}
+
+	// Use the email authentication service through the port interface
+	result, err := h.emailAuthService.AnalyzeHeaders(r.Context(), []byte(req.Headers))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(models.APIError{
+			Error:   "Header analysis failed",
+			Code:    http.StatusInternalServerError,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	// Convert result to API response, with the human-readable report
+	response := models.FromHeaderAnalysis(result, h.emailAuthService.GetHeaderAnalysisSummary(result))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// HandleDKIMDiscover handles discovery of the DKIM selectors of a domain
+func (h *EmailAuthHandler) HandleDKIMDiscover(w http.ResponseWriter, r *http.Request) {
+	// Read and parse request body
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.APIError{
+			Error:   "Invalid request body",
+			Code:    http.StatusBadRequest,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	var req models.DKIMDiscoverRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.APIError{
+			Error:   "Invalid JSON format",
+			Code:    http.StatusBadRequest,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	// Validate the request
+	validationResult := apivalidation.ValidateDKIMDiscoverRequest(&req)
+	if !validationResult.Valid {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":       "Validation failed",
+			"code":        http.StatusBadRequest,
+			"validations": validationResult.Errors,
+		})
+		return
+	}
+
+	// Default timeout is 5 seconds per lookup
+	timeout := 5 * time.Second
+	if req.Timeout > 0 {
+		timeout = time.Duration(req.Timeout) * time.Second
+	}
+
+	// Use the email authentication service through the port interface
+	result, err := h.emailAuthService.DiscoverDKIMSelectors(r.Context(), req.Domain, req.Selectors, timeout)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(models.APIError{
+			Error:   "DKIM selector discovery failed",
+			Code:    http.StatusInternalServerError,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	// Convert result to API response, with the human-readable report
+	response := models.FromDKIMDiscovery(result, h.emailAuthService.GetDKIMDiscoverySummary(result))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// HandleBIMICheck handles BIMI checks of a domain: record, DMARC prerequisite, logo and mark certificate
+func (h *EmailAuthHandler) HandleBIMICheck(w http.ResponseWriter, r *http.Request) {
+	// Read and parse request body
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.APIError{
+			Error:   "Invalid request body",
+			Code:    http.StatusBadRequest,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	var req models.BIMICheckRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.APIError{
+			Error:   "Invalid JSON format",
+			Code:    http.StatusBadRequest,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	// Validate the request
+	validationResult := apivalidation.ValidateBIMICheckRequest(&req)
+	if !validationResult.Valid {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":       "Validation failed",
+			"code":        http.StatusBadRequest,
+			"validations": validationResult.Errors,
+		})
+		return
+	}
+
+	// Default timeout is 10 seconds per lookup and download
+	timeout := 10 * time.Second
+	if req.Timeout > 0 {
+		timeout = time.Duration(req.Timeout) * time.Second
+	}
+
+	// Use the email authentication service through the port interface
+	result, err := h.emailAuthService.CheckBIMI(r.Context(), req.Domain, req.Selector, timeout)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(models.APIError{
+			Error:   "BIMI check failed",
+			Code:    http.StatusInternalServerError,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	// Convert result to API response, with the human-readable report
+	response := models.FromBIMIResult(result, h.emailAuthService.GetBIMISummary(result))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
 // HandleDKIMCheck handles DKIM record check requests
 func (h *EmailAuthHandler) HandleDKIMCheck(w http.ResponseWriter, r *http.Request) {
 	domain := r.PathValue("domain")
@@ -91,18 +520,33 @@ func (h *EmailAuthHandler) HandleDKIMCheck(w http.ResponseWriter, r *http.Reques
 		}
 	}
 
-	// If no selector is provided, try all default selectors
+	// If no selector is provided, check the discovered selectors
 	if selector == "" {
-		defaultSelectors := []string{"mail", "google"}
+		discovery, err := h.emailAuthService.DiscoverDKIMSelectors(r.Context(), domain, nil, timeout)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(models.APIError{
+				Error:   "DKIM selector discovery failed",
+				Code:    http.StatusInternalServerError,
+				Details: err.Error(),
+			})
+			return
+		}
+		defaultSelectors := make([]string, 0, len(discovery.Selectors))
+		for _, hit := range discovery.Selectors {
+			defaultSelectors = append(defaultSelectors, hit.Selector)
+		}
 		var combinedResults []*emailauth.DKIMResult
 		var lastError error
 		foundAnyValid := false
 
-		// Try each default selector and collect all results
+		// Check each discovered selector and collect all results
+		var checkedSelectors []string
 		for _, defaultSelector := range defaultSelectors {
 			result, err := h.emailAuthService.CheckDKIM(r.Context(), domain, []string{defaultSelector}, timeout)
 			if err == nil && result != nil {
 				combinedResults = append(combinedResults, result)
+				checkedSelectors = append(checkedSelectors, defaultSelector)
 				if result.IsValid {
 					foundAnyValid = true
 
@@ -114,7 +558,7 @@ func (h *EmailAuthHandler) HandleDKIMCheck(w http.ResponseWriter, r *http.Reques
 
 		// If we couldn't find any valid DKIM record with default selectors
 		if !foundAnyValid {
-			errorMsg := "DKIM check failed: No valid DKIM records found with default selectors"
+			errorMsg := "DKIM check failed: No valid DKIM records found with discovered selectors"
 			if lastError != nil {
 				errorMsg = lastError.Error()
 			}
@@ -123,7 +567,7 @@ func (h *EmailAuthHandler) HandleDKIMCheck(w http.ResponseWriter, r *http.Reques
 			json.NewEncoder(w).Encode(models.APIError{
 				Error:   errorMsg,
 				Code:    http.StatusNotFound,
-				Details: "Tried selectors: mail, google",
+				Details: fmt.Sprintf("Tried %d selectors, found: %s", discovery.Tried, strings.Join(defaultSelectors, ", ")),
 			})
 			return
 		}
@@ -133,12 +577,12 @@ func (h *EmailAuthHandler) HandleDKIMCheck(w http.ResponseWriter, r *http.Reques
 			Domain:    domain,
 			Results:   make([]models.DKIMResponse, 0, len(combinedResults)),
 			IsValid:   foundAnyValid,
-			Selectors: defaultSelectors,
+			Selectors: checkedSelectors,
 		}
 
 		for i, result := range combinedResults {
 			response := models.FromDKIMResult(result)
-			response.Selector = defaultSelectors[i]
+			response.Selector = checkedSelectors[i]
 			combinedResponse.Results = append(combinedResponse.Results, *response)
 		}
 
@@ -292,3 +736,211 @@ func (h *EmailAuthHandler) HandleEmailAuth(w http.ResponseWriter, r *http.Reques
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
+
+// HandleDMARCReportIngest handles uploads of DMARC aggregate report files
+func (h *EmailAuthHandler) HandleDMARCReportIngest(w http.ResponseWriter, r *http.Request) {
+	// Read and parse request body
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.APIError{
+			Error:   "Invalid request body",
+			Code:    http.StatusBadRequest,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	var req models.DMARCReportIngestRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.APIError{
+			Error:   "Invalid JSON format",
+			Code:    http.StatusBadRequest,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	// Validate the request
+	validationResult := apivalidation.ValidateDMARCReportIngestRequest(&req)
+	if !validationResult.Valid {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":       "Validation failed",
+			"code":        http.StatusBadRequest,
+			"validations": validationResult.Errors,
+		})
+		return
+	}
+
+	files := make([]emailauth.DMARCReportUpload, 0, len(req.Files))
+	for i, file := range req.Files {
+		name := file.Name
+		if name == "" {
+			name = fmt.Sprintf("file %d", i+1)
+		}
+		files = append(files, emailauth.DMARCReportUpload{Name: name, Data: file.Content})
+	}
+
+	// Use the email authentication service through the port interface
+	result, err := h.emailAuthService.IngestDMARCReports(r.Context(), files)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(models.APIError{
+			Error:   "DMARC report ingestion failed",
+			Code:    http.StatusInternalServerError,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	// Convert result to API response, with the human-readable report
+	response := models.FromDMARCIngest(result, h.emailAuthService.GetDMARCIngestSummary(result))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// HandleDMARCForensic handles uploads of DMARC failure (ARF) reports to group by campaign
+func (h *EmailAuthHandler) HandleDMARCForensic(w http.ResponseWriter, r *http.Request) {
+	// Read and parse request body
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.APIError{
+			Error:   "Invalid request body",
+			Code:    http.StatusBadRequest,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	var req models.DMARCForensicRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.APIError{
+			Error:   "Invalid JSON format",
+			Code:    http.StatusBadRequest,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	// Validate the request
+	validationResult := apivalidation.ValidateDMARCForensicRequest(&req)
+	if !validationResult.Valid {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":       "Validation failed",
+			"code":        http.StatusBadRequest,
+			"validations": validationResult.Errors,
+		})
+		return
+	}
+
+	files := make([]emailauth.DMARCReportUpload, 0, len(req.Files))
+	for i, file := range req.Files {
+		name := file.Name
+		if name == "" {
+			name = fmt.Sprintf("file %d", i+1)
+		}
+		files = append(files, emailauth.DMARCReportUpload{Name: name, Data: file.Content})
+	}
+
+	// Use the email authentication service through the port interface
+	result, err := h.emailAuthService.AnalyzeForensicReports(r.Context(), files)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(models.APIError{
+			Error:   "DMARC failure report analysis failed",
+			Code:    http.StatusInternalServerError,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	// Convert result to API response, with the human-readable report
+	response := models.FromForensicReports(result, h.emailAuthService.GetForensicReportSummary(result))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// HandleDMARCReportSummary handles summaries of the stored DMARC aggregate reports
+func (h *EmailAuthHandler) HandleDMARCReportSummary(w http.ResponseWriter, r *http.Request) {
+	// Read and parse request body
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.APIError{
+			Error:   "Invalid request body",
+			Code:    http.StatusBadRequest,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	var req models.DMARCReportSummaryRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.APIError{
+			Error:   "Invalid JSON format",
+			Code:    http.StatusBadRequest,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	// Validate the request
+	validationResult := apivalidation.ValidateDMARCReportSummaryRequest(&req)
+	if !validationResult.Valid {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":       "Validation failed",
+			"code":        http.StatusBadRequest,
+			"validations": validationResult.Errors,
+		})
+		return
+	}
+
+	// Dates were checked by the validation
+	query := emailauth.DMARCReportQuery{
+		Domain:       req.Domain,
+		Top:          req.Top,
+		Lookup:       !req.NoLookup,
+		KnownSenders: req.KnownSenders,
+	}
+	query.Since, _ = emailauth.ParseReportDate(req.Since, false)
+	query.Until, _ = emailauth.ParseReportDate(req.Until, true)
+	if req.Days > 0 && query.Since.IsZero() {
+		query.Since = time.Now().AddDate(0, 0, -req.Days)
+	}
+	if query.Top == 0 {
+		query.Top = 20
+	}
+
+	// Default timeout is 5 seconds per source lookup
+	timeout := 5 * time.Second
+	if req.Timeout > 0 {
+		timeout = time.Duration(req.Timeout) * time.Second
+	}
+
+	// Use the email authentication service through the port interface
+	result, err := h.emailAuthService.SummarizeDMARCReports(r.Context(), query, timeout)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(models.APIError{
+			Error:   "DMARC report summary failed",
+			Code:    http.StatusInternalServerError,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	// Convert result to API response, with the human-readable report
+	response := models.FromDMARCReportSummary(result, h.emailAuthService.GetDMARCReportSummary(result))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
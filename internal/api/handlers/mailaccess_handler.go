@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"mxclone/domain/mailaccess"
+	"mxclone/internal/api/models"
+	apivalidation "mxclone/internal/api/validation"
+	"mxclone/ports/input"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// MailAccessHandler encapsulates handlers for IMAP and POP3 operations
+type MailAccessHandler struct {
+	mailAccessService input.MailAccessPort // Using the interface (port) instead of direct implementation
+}
+
+// NewMailAccessHandler creates a new mail access handler with the given service
+func NewMailAccessHandler(mailAccessService input.MailAccessPort) *MailAccessHandler {
+	return &MailAccessHandler{
+		mailAccessService: mailAccessService,
+	}
+}
+
+// HandleIMAPConnect handles IMAP connection check requests
+func (h *MailAccessHandler) HandleIMAPConnect(w http.ResponseWriter, r *http.Request) {
+	h.handleConnect(w, r, mailaccess.ProtocolIMAP)
+}
+
+// HandlePOP3Connect handles POP3 connection check requests
+func (h *MailAccessHandler) HandlePOP3Connect(w http.ResponseWriter, r *http.Request) {
+	h.handleConnect(w, r, mailaccess.ProtocolPOP3)
+}
+
+// HandleIMAPCheck handles IMAP check requests with an optional login test
+func (h *MailAccessHandler) HandleIMAPCheck(w http.ResponseWriter, r *http.Request) {
+	h.handleCheck(w, r, mailaccess.ProtocolIMAP)
+}
+
+// HandlePOP3Check handles POP3 check requests with an optional login test
+func (h *MailAccessHandler) HandlePOP3Check(w http.ResponseWriter, r *http.Request) {
+	h.handleCheck(w, r, mailaccess.ProtocolPOP3)
+}
+
+// handleConnect checks one port of a host given in the path, without credentials
+func (h *MailAccessHandler) handleConnect(w http.ResponseWriter, r *http.Request, protocol string) {
+	host := r.PathValue("host")
+
+	if host == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.APIError{
+			Error: "Host path parameter is required",
+			Code:  http.StatusBadRequest,
+		})
+		return
+	}
+
+	// Default port is the protocol's STARTTLS port if not specified
+	port := 0
+	portStr := r.URL.Query().Get("port")
+	if portStr != "" {
+		var err error
+		port, err = strconv.Atoi(portStr)
+		if err != nil || port < 0 || port > 65535 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(models.APIError{
+				Error: "Invalid port parameter",
+				Code:  http.StatusBadRequest,
+			})
+			return
+		}
+	}
+
+	// Default timeout is 10 seconds
+	timeout := 10 * time.Second
+	timeoutStr := r.URL.Query().Get("timeout")
+	if timeoutStr != "" {
+		timeoutDuration, err := time.ParseDuration(timeoutStr)
+		if err == nil {
+			timeout = timeoutDuration
+		}
+	}
+
+	transcript, _ := strconv.ParseBool(r.URL.Query().Get("transcript"))
+	h.check(w, r, &mailaccess.CheckRequest{
+		Protocol: protocol,
+		Host:     host,
+		Port:     port,
+		Timeout:  timeout,
+	}, transcript)
+}
+
+// handleCheck checks one port of a host given in the body, with an optional login test
+func (h *MailAccessHandler) handleCheck(w http.ResponseWriter, r *http.Request, protocol string) {
+	// Read and parse request body
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.APIError{
+			Error:   "Invalid request body",
+			Code:    http.StatusBadRequest,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	var req models.MailAccessCheckRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.APIError{
+			Error:   "Invalid JSON format",
+			Code:    http.StatusBadRequest,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	// Validate the request
+	validationResult := apivalidation.ValidateMailAccessCheckRequest(&req)
+	if !validationResult.Valid {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":       "Validation failed",
+			"code":        http.StatusBadRequest,
+			"validations": validationResult.Errors,
+		})
+		return
+	}
+
+	// Default timeout is 10 seconds
+	timeout := 10 * time.Second
+	if req.Timeout > 0 {
+		timeout = time.Duration(req.Timeout) * time.Second
+	}
+
+	h.check(w, r, &mailaccess.CheckRequest{
+		Protocol: protocol,
+		Host:     req.Host,
+		Port:     req.Port,
+		Username: req.Username,
+		Password: req.Password,
+		Timeout:  timeout,
+	}, req.Transcript)
+}
+
+// check runs a check through the port interface and writes the response, with the session
+// transcript when it was requested
+func (h *MailAccessHandler) check(w http.ResponseWriter, r *http.Request, req *mailaccess.CheckRequest, transcript bool) {
+	result, err := h.mailAccessService.CheckServer(r.Context(), req)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(models.APIError{
+			Error:   mailaccess.ProtocolName(req.Protocol) + " check failed",
+			Code:    http.StatusInternalServerError,
+			Details: err.Error(),
+		})
+		return
+	}
+	if !transcript {
+		result.Transcript = nil
+	}
+
+	// Convert domain result to API response
+	response := models.FromMailAccessCheckResult(result)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -2,12 +2,16 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"mxclone/domain/smtp"
 	"mxclone/internal/api/models"
 	apivalidation "mxclone/internal/api/validation"
 	"mxclone/ports/input"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -75,6 +79,9 @@ func (h *SMTPHandler) HandleSMTPConnect(w http.ResponseWriter, r *http.Request)
 		})
 		return
 	}
+	if transcript, _ := strconv.ParseBool(r.URL.Query().Get("transcript")); !transcript {
+		dropConnectionTranscripts(result)
+	}
 
 	// Convert domain result to API response
 	response := models.FromSMTPConnectionResult(result)
@@ -118,6 +125,11 @@ func (h *SMTPHandler) HandleSMTPStartTLS(w http.ResponseWriter, r *http.Request)
 		})
 		return
 	}
+	if transcript, _ := strconv.ParseBool(r.URL.Query().Get("transcript")); !transcript {
+		for _, connResult := range result.ConnectionResults {
+			dropConnectionTranscripts(connResult)
+		}
+	}
 
 	// Get only the STARTTLS-related information from the result
 	response := models.FromSMTPStartTLSResult(result)
@@ -126,6 +138,66 @@ func (h *SMTPHandler) HandleSMTPStartTLS(w http.ResponseWriter, r *http.Request)
 	json.NewEncoder(w).Encode(response)
 }
 
+// HandleSMTPMatrix handles MX matrix requests (every MX address on every port)
+func (h *SMTPHandler) HandleSMTPMatrix(w http.ResponseWriter, r *http.Request) {
+	domain := r.PathValue("domain")
+
+	if domain == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.APIError{
+			Error: "Domain path parameter is required",
+			Code:  http.StatusBadRequest,
+		})
+		return
+	}
+
+	// Ports default to the configured SMTP ports
+	var ports []int
+	if portsStr := r.URL.Query().Get("ports"); portsStr != "" {
+		for _, portStr := range strings.Split(portsStr, ",") {
+			port, err := strconv.Atoi(strings.TrimSpace(portStr))
+			if err != nil || port < 1 || port > 65535 {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(models.APIError{
+					Error:   "Invalid ports parameter",
+					Code:    http.StatusBadRequest,
+					Details: fmt.Sprintf("invalid port %q", portStr),
+				})
+				return
+			}
+			ports = append(ports, port)
+		}
+	}
+
+	// Default timeout is 10 seconds
+	timeout := 10 * time.Second
+	timeoutStr := r.URL.Query().Get("timeout")
+	if timeoutStr != "" {
+		timeoutDuration, err := time.ParseDuration(timeoutStr)
+		if err == nil {
+			timeout = timeoutDuration
+		}
+	}
+
+	// Use the SMTP service through the port interface
+	result, err := h.smtpService.CheckSMTPMatrix(r.Context(), domain, ports, timeout)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(models.APIError{
+			Error:   "SMTP matrix check failed",
+			Code:    http.StatusInternalServerError,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	// Convert domain result to API response
+	response := models.FromSMTPMatrixResult(result)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
 // HandleSMTPRelayTest handles SMTP open relay test requests
 func (h *SMTPHandler) HandleSMTPRelayTest(w http.ResponseWriter, r *http.Request) {
 	// Read and parse request body
@@ -163,10 +235,31 @@ func (h *SMTPHandler) HandleSMTPRelayTest(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	// Default port is 25 and default timeout is 10 seconds
+	port := req.Port
+	if port == 0 {
+		port = 25
+	}
+	timeout := 10 * time.Second
+	if req.Timeout > 0 {
+		timeout = time.Duration(req.Timeout) * time.Second
+	}
+
+	relayRequest := &smtp.RelayTestRequest{
+		Host:         req.Host,
+		Port:         port,
+		FromAddress:  req.FromAddress,
+		ToAddress:    req.ToAddress,
+		TargetDomain: req.TargetDomain,
+		Timeout:      timeout,
+	}
+	if req.Authentication {
+		relayRequest.Username = req.Username
+		relayRequest.Password = req.Password
+	}
+
 	// Use the SMTP service through the port interface
-	// Note: Currently, there's no direct method for relay testing in the port
-	// This would require extending the SMTPPort interface or using a specialized service
-	result, err := h.smtpService.CheckSMTP(r.Context(), req.Host, time.Duration(req.Timeout)*time.Second)
+	result, err := h.smtpService.TestOpenRelay(r.Context(), relayRequest)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(models.APIError{
@@ -177,14 +270,325 @@ func (h *SMTPHandler) HandleSMTPRelayTest(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// For now, extract relay test info from the comprehensive result
-	// In a real implementation, you would have a dedicated relay test method
+	if !req.Transcript {
+		for _, test := range result.Tests {
+			test.Transcript = nil
+		}
+	}
+
+	// Convert domain result to API response
 	response := models.FromSMTPRelayTestResult(result)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
+// HandleSMTPSubmissionTest handles authenticated submission test requests
+func (h *SMTPHandler) HandleSMTPSubmissionTest(w http.ResponseWriter, r *http.Request) {
+	// Read and parse request body
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.APIError{
+			Error:   "Invalid request body",
+			Code:    http.StatusBadRequest,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	var req models.SMTPSubmissionTestRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.APIError{
+			Error:   "Invalid JSON format",
+			Code:    http.StatusBadRequest,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	// Validate the request
+	validationResult := apivalidation.ValidateSMTPSubmissionTestRequest(&req)
+	if !validationResult.Valid {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":       "Validation failed",
+			"code":        http.StatusBadRequest,
+			"validations": validationResult.Errors,
+		})
+		return
+	}
+
+	// Default port is 587 and default timeout is 10 seconds
+	port := req.Port
+	if port == 0 {
+		port = 587
+	}
+	timeout := 10 * time.Second
+	if req.Timeout > 0 {
+		timeout = time.Duration(req.Timeout) * time.Second
+	}
+
+	// Use the SMTP service through the port interface
+	result, err := h.smtpService.TestSubmission(r.Context(), &smtp.SubmissionTestRequest{
+		Host:               req.Host,
+		Port:               port,
+		Username:           req.Username,
+		Password:           req.Password,
+		Mechanism:          req.Mechanism,
+		AllowPlaintextAuth: req.AllowPlaintextAuth,
+		FromAddress:        req.FromAddress,
+		ToAddress:          req.ToAddress,
+		SendMessage:        req.SendMessage,
+		Timeout:            timeout,
+	})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(models.APIError{
+			Error:   "SMTP submission test failed",
+			Code:    http.StatusInternalServerError,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	if !req.Transcript {
+		result.Transcript = nil
+	}
+
+	// Convert domain result to API response
+	response := models.FromSMTPSubmissionTestResult(result)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// HandleSMTPSmugglingTest handles SMTP smuggling probe requests
+func (h *SMTPHandler) HandleSMTPSmugglingTest(w http.ResponseWriter, r *http.Request) {
+	// Read and parse request body
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.APIError{
+			Error:   "Invalid request body",
+			Code:    http.StatusBadRequest,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	var req models.SMTPSmugglingTestRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.APIError{
+			Error:   "Invalid JSON format",
+			Code:    http.StatusBadRequest,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	// Validate the request
+	validationResult := apivalidation.ValidateSMTPSmugglingTestRequest(&req)
+	if !validationResult.Valid {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":       "Validation failed",
+			"code":        http.StatusBadRequest,
+			"validations": validationResult.Errors,
+		})
+		return
+	}
+
+	// Default port is 25 and default timeout is 10 seconds
+	port := req.Port
+	if port == 0 {
+		port = 25
+	}
+	timeout := 10 * time.Second
+	if req.Timeout > 0 {
+		timeout = time.Duration(req.Timeout) * time.Second
+	}
+
+	// Use the SMTP service through the port interface
+	result, err := h.smtpService.TestSmuggling(r.Context(), &smtp.SmugglingTestRequest{
+		Domain:      req.Domain,
+		Host:        req.Host,
+		Port:        port,
+		FromAddress: req.FromAddress,
+		Confirm:     req.Confirm,
+		Timeout:     timeout,
+	})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(models.APIError{
+			Error:   "SMTP smuggling probe failed",
+			Code:    http.StatusInternalServerError,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	if !req.Transcript {
+		result.Transcript = nil
+	}
+
+	// Convert domain result to API response
+	response := models.FromSMTPSmugglingTestResult(result)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// HandleSMTPSinkStatus describes the SMTP sink
+func (h *SMTPHandler) HandleSMTPSinkStatus(w http.ResponseWriter, r *http.Request) {
+	status := h.smtpService.GetSinkStatus(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.FromSMTPSinkStatus(status))
+}
+
+// HandleSMTPSinkStart starts the SMTP sink
+func (h *SMTPHandler) HandleSMTPSinkStart(w http.ResponseWriter, r *http.Request) {
+	// Read and parse request body; an empty body starts the sink with defaults
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.APIError{
+			Error:   "Invalid request body",
+			Code:    http.StatusBadRequest,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	var req models.SMTPSinkStartRequest
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(models.APIError{
+				Error:   "Invalid JSON format",
+				Code:    http.StatusBadRequest,
+				Details: err.Error(),
+			})
+			return
+		}
+	}
+
+	// Validate the request
+	validationResult := apivalidation.ValidateSMTPSinkStartRequest(&req)
+	if !validationResult.Valid {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":       "Validation failed",
+			"code":        http.StatusBadRequest,
+			"validations": validationResult.Errors,
+		})
+		return
+	}
+
+	// The address, certificate and test limits come from the configuration
+	status, err := h.smtpService.StartSink(r.Context(), &smtp.SinkConfig{
+		Domain:   req.Domain,
+		Hostname: req.Hostname,
+		Timeout:  time.Duration(req.Timeout) * time.Second,
+	})
+	if err != nil {
+		code := http.StatusInternalServerError
+		if errors.Is(err, smtp.ErrSinkRunning) {
+			code = http.StatusConflict
+		}
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(models.APIError{
+			Error:   "Failed to start SMTP sink",
+			Code:    code,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(models.FromSMTPSinkStatus(status))
+}
+
+// HandleSMTPSinkStop stops the SMTP sink
+func (h *SMTPHandler) HandleSMTPSinkStop(w http.ResponseWriter, r *http.Request) {
+	if err := h.smtpService.StopSink(r.Context()); err != nil {
+		code := http.StatusInternalServerError
+		if errors.Is(err, smtp.ErrSinkNotRunning) {
+			code = http.StatusConflict
+		}
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(models.APIError{
+			Error:   "Failed to stop SMTP sink",
+			Code:    code,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.FromSMTPSinkStatus(h.smtpService.GetSinkStatus(r.Context())))
+}
+
+// HandleSMTPSinkCreateTest generates a test address on the running SMTP sink
+func (h *SMTPHandler) HandleSMTPSinkCreateTest(w http.ResponseWriter, r *http.Request) {
+	test, err := h.smtpService.CreateSinkTest(r.Context())
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, smtp.ErrSinkNotRunning) {
+			status = http.StatusServiceUnavailable
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(models.APIError{
+			Error:   "Failed to create sink test",
+			Code:    status,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(models.FromSMTPSinkTest(test))
+}
+
+// HandleSMTPSinkTestResult returns the messages received for a sink test
+func (h *SMTPHandler) HandleSMTPSinkTestResult(w http.ResponseWriter, r *http.Request) {
+	testId, ok := r.Context().Value("testId").(string)
+	if !ok || testId == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.APIError{
+			Error: "testId parameter is required",
+			Code:  http.StatusBadRequest,
+		})
+		return
+	}
+
+	test, err := h.smtpService.GetSinkTest(r.Context(), testId)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, smtp.ErrSinkTestNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, smtp.ErrSinkNotRunning):
+			status = http.StatusServiceUnavailable
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(models.APIError{
+			Error:   "Failed to retrieve sink test",
+			Code:    status,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.FromSMTPSinkTest(test))
+}
+
 // HandleSMTPCheck handles SMTP check requests
 func (h *SMTPHandler) HandleSMTPCheck(w http.ResponseWriter, r *http.Request) {
 	// Read and parse request body
@@ -243,3 +647,149 @@ func (h *SMTPHandler) HandleSMTPCheck(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
+
+// HandleEmailVerify handles email address verification requests
+func (h *SMTPHandler) HandleEmailVerify(w http.ResponseWriter, r *http.Request) {
+	// Read and parse request body
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.APIError{
+			Error:   "Invalid request body",
+			Code:    http.StatusBadRequest,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	var req models.EmailVerifyRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.APIError{
+			Error:   "Invalid JSON format",
+			Code:    http.StatusBadRequest,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	// Validate the request
+	validationResult := apivalidation.ValidateEmailVerifyRequest(&req)
+	if !validationResult.Valid {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":       "Validation failed",
+			"code":        http.StatusBadRequest,
+			"validations": validationResult.Errors,
+		})
+		return
+	}
+
+	// Default timeout is 10 seconds
+	timeout := 10 * time.Second
+	if req.Timeout > 0 {
+		timeout = time.Duration(req.Timeout) * time.Second
+	}
+
+	// Use the SMTP service through the port interface
+	result, err := h.smtpService.VerifyEmail(r.Context(), req.Address, timeout)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(models.APIError{
+			Error:   "Email verification failed",
+			Code:    http.StatusInternalServerError,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	if !req.Transcript {
+		result.Transcript = nil
+	}
+
+	// Convert domain result to API response
+	response := models.FromEmailVerificationResult(result)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// HandleEmailVerifyBulk handles bulk email address verification requests
+func (h *SMTPHandler) HandleEmailVerifyBulk(w http.ResponseWriter, r *http.Request) {
+	// Read and parse request body
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.APIError{
+			Error:   "Invalid request body",
+			Code:    http.StatusBadRequest,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	var req models.EmailVerifyBulkRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.APIError{
+			Error:   "Invalid JSON format",
+			Code:    http.StatusBadRequest,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	// Validate the request
+	validationResult := apivalidation.ValidateEmailVerifyBulkRequest(&req)
+	if !validationResult.Valid {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":       "Validation failed",
+			"code":        http.StatusBadRequest,
+			"validations": validationResult.Errors,
+		})
+		return
+	}
+
+	// Default timeout is 10 seconds per address
+	timeout := 10 * time.Second
+	if req.Timeout > 0 {
+		timeout = time.Duration(req.Timeout) * time.Second
+	}
+
+	// Use the SMTP service through the port interface
+	result, err := h.smtpService.VerifyEmails(r.Context(), req.Addresses, timeout)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(models.APIError{
+			Error:   "Bulk email verification failed",
+			Code:    http.StatusInternalServerError,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	if !req.Transcript {
+		for _, verification := range result.Results {
+			verification.Transcript = nil
+		}
+	}
+
+	// Convert domain result to API response
+	response := models.FromBulkEmailVerificationResult(result)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// dropConnectionTranscripts removes the session transcripts of a connection check, which are only
+// returned on request
+func dropConnectionTranscripts(result *smtp.ConnectionResult) {
+	if result == nil {
+		return
+	}
+	result.Transcript = nil
+	if result.STARTTLSSecurity != nil {
+		result.STARTTLSSecurity.Transcript = nil
+	}
+}
@@ -1,12 +1,15 @@
 package models
 
 import (
+	"fmt"
 	"mxclone/domain/dns"
 	"mxclone/domain/dnsbl"
 	"mxclone/domain/emailauth"
+	"mxclone/domain/mailaccess"
 	"mxclone/domain/networktools"
 	"mxclone/domain/smtp"
 	"strings"
+	"time"
 )
 
 // APIError represents an API error response
@@ -89,14 +92,153 @@ func FromSMTPResult(result *smtp.SMTPResult) *SMTPResponse {
 
 // SMTPConnectionResponse represents the result of an SMTP connection check
 type SMTPConnectionResponse struct {
-	Host             string   `json:"host"`
-	Port             int      `json:"port"`
-	Connected        bool     `json:"connected"`
-	Latency          string   `json:"latency,omitempty"`
-	SupportsStartTLS bool     `json:"supportsStartTLS"`
-	AuthMethods      []string `json:"authMethods,omitempty"`
-	Banner           string   `json:"banner,omitempty"`
-	Error            string   `json:"error,omitempty"`
+	Host             string                        `json:"host"`
+	Port             int                           `json:"port"`
+	Connected        bool                          `json:"connected"`
+	Latency          string                        `json:"latency,omitempty"`
+	SupportsStartTLS bool                          `json:"supportsStartTLS"`
+	AuthMethods      []string                      `json:"authMethods,omitempty"`
+	Banner           string                        `json:"banner,omitempty"`
+	TLSVersion       string                        `json:"tlsVersion,omitempty"`
+	TLSCipher        string                        `json:"tlsCipher,omitempty"`
+	Transcript       []SMTPTranscriptEntryResponse `json:"transcript,omitempty"`
+	BannerAnalysis   *SMTPBannerAnalysisResponse   `json:"bannerAnalysis,omitempty"`
+	STARTTLSSecurity *SMTPSTARTTLSSecurityResponse `json:"starttlsSecurity,omitempty"`
+	Error            string                        `json:"error,omitempty"`
+}
+
+// SMTPSTARTTLSSecurityResponse represents the STARTTLS injection and downgrade checks of an SMTP server
+type SMTPSTARTTLSSecurityResponse struct {
+	Advertised          bool                          `json:"advertised"`
+	HandshakeSucceeded  bool                          `json:"handshakeSucceeded"`
+	HandshakeError      string                        `json:"handshakeError,omitempty"`
+	TLSVersion          string                        `json:"tlsVersion,omitempty"`
+	InjectionTested     bool                          `json:"injectionTested"`
+	InjectionVulnerable bool                          `json:"injectionVulnerable"`
+	InjectionResponse   string                        `json:"injectionResponse,omitempty"`
+	Findings            []string                      `json:"findings,omitempty"`
+	Transcript          []SMTPTranscriptEntryResponse `json:"transcript,omitempty"`
+	Error               string                        `json:"error,omitempty"`
+}
+
+// FromSMTPSTARTTLSSecurity converts a domain STARTTLS security check to an API response
+func FromSMTPSTARTTLSSecurity(security *smtp.STARTTLSSecurity) *SMTPSTARTTLSSecurityResponse {
+	if security == nil {
+		return nil
+	}
+
+	return &SMTPSTARTTLSSecurityResponse{
+		Advertised:          security.Advertised,
+		HandshakeSucceeded:  security.HandshakeSucceeded,
+		HandshakeError:      security.HandshakeError,
+		TLSVersion:          security.TLSVersion,
+		InjectionTested:     security.InjectionTested,
+		InjectionVulnerable: security.InjectionVulnerable,
+		InjectionResponse:   security.InjectionResponse,
+		Findings:            security.Findings,
+		Transcript:          FromSMTPTranscript(security.Transcript),
+		Error:               security.Error,
+	}
+}
+
+// SMTPBannerAnalysisResponse represents the greeting and reply behavior of an SMTP server
+type SMTPBannerAnalysisResponse struct {
+	Banner              string   `json:"banner,omitempty"`
+	GreetingCode        int      `json:"greetingCode,omitempty"`
+	GreetingDelay       string   `json:"greetingDelay"`
+	MultiLineGreeting   bool     `json:"multiLineGreeting"`
+	PreGreetPause       bool     `json:"preGreetPause"`
+	EarlyTalkerTested   bool     `json:"earlyTalkerTested"`
+	EarlyTalkerRejected bool     `json:"earlyTalkerRejected"`
+	AverageResponseTime string   `json:"averageResponseTime,omitempty"`
+	Tarpitting          bool     `json:"tarpitting"`
+	RcptCode            int      `json:"rcptCode,omitempty"`
+	RcptResponse        string   `json:"rcptResponse,omitempty"`
+	GreylistSuspected   bool     `json:"greylistSuspected"`
+	Greylisting         bool     `json:"greylisting"`
+	RetryCode           int      `json:"retryCode,omitempty"`
+	BannerHostname      string   `json:"bannerHostname,omitempty"`
+	ServerIP            string   `json:"serverIP,omitempty"`
+	PTRNames            []string `json:"ptrNames,omitempty"`
+	MatchesPTR          bool     `json:"matchesPTR"`
+	MatchesMX           bool     `json:"matchesMX"`
+	Software            string   `json:"software,omitempty"`
+	SoftwareVersion     string   `json:"softwareVersion,omitempty"`
+	LeaksVersion        bool     `json:"leaksVersion"`
+	Findings            []string `json:"findings,omitempty"`
+	Error               string   `json:"error,omitempty"`
+}
+
+// FromSMTPBannerAnalysis converts a domain banner analysis to an API response
+func FromSMTPBannerAnalysis(analysis *smtp.BannerAnalysis) *SMTPBannerAnalysisResponse {
+	if analysis == nil {
+		return nil
+	}
+
+	response := &SMTPBannerAnalysisResponse{
+		Banner:              analysis.Banner,
+		GreetingCode:        analysis.GreetingCode,
+		GreetingDelay:       analysis.GreetingDelay.String(),
+		MultiLineGreeting:   analysis.MultiLineGreeting,
+		PreGreetPause:       analysis.PreGreetPause,
+		EarlyTalkerTested:   analysis.EarlyTalkerTested,
+		EarlyTalkerRejected: analysis.EarlyTalkerRejected,
+		Tarpitting:          analysis.Tarpitting,
+		RcptCode:            analysis.RcptCode,
+		RcptResponse:        analysis.RcptResponse,
+		GreylistSuspected:   analysis.GreylistSuspected,
+		Greylisting:         analysis.Greylisting,
+		RetryCode:           analysis.RetryCode,
+		BannerHostname:      analysis.BannerHostname,
+		ServerIP:            analysis.ServerIP,
+		PTRNames:            analysis.PTRNames,
+		MatchesPTR:          analysis.MatchesPTR,
+		MatchesMX:           analysis.MatchesMX,
+		Software:            analysis.Software,
+		SoftwareVersion:     analysis.SoftwareVersion,
+		LeaksVersion:        analysis.LeaksVersion,
+		Findings:            analysis.Findings,
+		Error:               analysis.Error,
+	}
+	if analysis.AverageResponseTime > 0 {
+		response.AverageResponseTime = analysis.AverageResponseTime.String()
+	}
+	return response
+}
+
+// SMTPTranscriptEntryResponse represents a single event of an SMTP session transcript
+type SMTPTranscriptEntryResponse struct {
+	Timestamp    time.Time `json:"timestamp"`
+	Elapsed      string    `json:"elapsed"`
+	Direction    string    `json:"direction"`
+	Line         string    `json:"line"`
+	Code         int       `json:"code,omitempty"`
+	EnhancedCode string    `json:"enhancedCode,omitempty"`
+	Duration     string    `json:"duration,omitempty"`
+}
+
+// FromSMTPTranscript converts a domain SMTP transcript to API response entries
+func FromSMTPTranscript(transcript []smtp.TranscriptEntry) []SMTPTranscriptEntryResponse {
+	if len(transcript) == 0 {
+		return nil
+	}
+
+	entries := make([]SMTPTranscriptEntryResponse, 0, len(transcript))
+	for _, entry := range transcript {
+		response := SMTPTranscriptEntryResponse{
+			Timestamp:    entry.Timestamp,
+			Elapsed:      entry.Elapsed.String(),
+			Direction:    entry.Direction,
+			Line:         entry.Line,
+			Code:         entry.Code,
+			EnhancedCode: entry.EnhancedCode,
+		}
+		if entry.Duration > 0 {
+			response.Duration = entry.Duration.String()
+		}
+		entries = append(entries, response)
+	}
+	return entries
 }
 
 // FromSMTPConnectionResult converts a domain SMTP connection result to an API response
@@ -109,10 +251,16 @@ func FromSMTPConnectionResult(result *smtp.ConnectionResult) *SMTPConnectionResp
 
 	response := &SMTPConnectionResponse{
 		Host:             result.Server,
+		Port:             result.Port,
 		Connected:        result.Connected,
 		SupportsStartTLS: result.SupportsStartTLS,
 		AuthMethods:      result.AuthMethods,
 		Banner:           result.Banner,
+		TLSVersion:       result.TLSVersion,
+		TLSCipher:        result.TLSCipher,
+		Transcript:       FromSMTPTranscript(result.Transcript),
+		BannerAnalysis:   FromSMTPBannerAnalysis(result.BannerAnalysis),
+		STARTTLSSecurity: FromSMTPSTARTTLSSecurity(result.STARTTLSSecurity),
 	}
 
 	if result.Latency > 0 {
@@ -162,76 +310,295 @@ func FromSMTPStartTLSResult(result *smtp.SMTPResult) *SMTPStartTLSResponse {
 	return response
 }
 
+// SMTPMatrixCellResponse represents one MX address and port of an MX matrix
+type SMTPMatrixCellResponse struct {
+	MXHost           string `json:"mxHost"`
+	Preference       uint16 `json:"preference"`
+	Address          string `json:"address"`
+	Family           string `json:"family"`
+	Port             int    `json:"port"`
+	ImplicitTLS      bool   `json:"implicitTLS"`
+	Connected        bool   `json:"connected"`
+	Latency          string `json:"latency,omitempty"`
+	SupportsStartTLS bool   `json:"supportsStartTLS"`
+	TLSVersion       string `json:"tlsVersion,omitempty"`
+	TLSCipher        string `json:"tlsCipher,omitempty"`
+	Banner           string `json:"banner,omitempty"`
+	Error            string `json:"error,omitempty"`
+}
+
+// SMTPMXHostResponse represents an MX host and its preference
+type SMTPMXHostResponse struct {
+	Host       string `json:"host"`
+	Preference uint16 `json:"preference"`
+}
+
+// SMTPMatrixResponse represents the result of an MX matrix check
+type SMTPMatrixResponse struct {
+	Domain        string                   `json:"domain"`
+	MXHosts       []SMTPMXHostResponse     `json:"mxHosts"`
+	Ports         []int                    `json:"ports"`
+	Cells         []SMTPMatrixCellResponse `json:"cells"`
+	ResolveErrors map[string]string        `json:"resolveErrors,omitempty"`
+	Error         string                   `json:"error,omitempty"`
+}
+
+// FromSMTPMatrixResult converts a domain MX matrix result to an API response
+func FromSMTPMatrixResult(result *smtp.MatrixResult) *SMTPMatrixResponse {
+	if result == nil {
+		return &SMTPMatrixResponse{
+			Error: "no result available",
+		}
+	}
+
+	response := &SMTPMatrixResponse{
+		Domain:        result.Domain,
+		MXHosts:       make([]SMTPMXHostResponse, 0, len(result.MXHosts)),
+		Ports:         result.Ports,
+		Cells:         make([]SMTPMatrixCellResponse, 0, len(result.Cells)),
+		ResolveErrors: result.ResolveErrors,
+		Error:         result.Error,
+	}
+
+	for _, mx := range result.MXHosts {
+		response.MXHosts = append(response.MXHosts, SMTPMXHostResponse{
+			Host:       mx.Host,
+			Preference: mx.Preference,
+		})
+	}
+
+	for _, cell := range result.Cells {
+		cellResponse := SMTPMatrixCellResponse{
+			MXHost:           cell.MXHost,
+			Preference:       cell.Preference,
+			Address:          cell.Address,
+			Family:           cell.Family,
+			Port:             cell.Port,
+			ImplicitTLS:      cell.ImplicitTLS,
+			Connected:        cell.Connected,
+			SupportsStartTLS: cell.SupportsStartTLS,
+			TLSVersion:       cell.TLSVersion,
+			TLSCipher:        cell.TLSCipher,
+			Banner:           cell.Banner,
+			Error:            cell.Error,
+		}
+		if cell.Latency > 0 {
+			cellResponse.Latency = cell.Latency.String()
+		}
+		response.Cells = append(response.Cells, cellResponse)
+	}
+
+	return response
+}
+
 // SMTPRelayTestRequest represents a request to test an SMTP server for open relay
 type SMTPRelayTestRequest struct {
 	Host           string `json:"host"`
 	Port           int    `json:"port,omitempty"` // Default to 25 if not specified
 	FromAddress    string `json:"fromAddress"`
 	ToAddress      string `json:"toAddress"`
-	Timeout        int    `json:"timeout,omitempty"` // In seconds, default to 10
+	TargetDomain   string `json:"targetDomain,omitempty"` // Domain the server receives mail for, default to the host's organizational domain
+	Timeout        int    `json:"timeout,omitempty"`      // In seconds, default to 10
 	Authentication bool   `json:"authentication,omitempty"`
 	Username       string `json:"username,omitempty"`
 	Password       string `json:"password,omitempty"`
+	Transcript     bool   `json:"transcript,omitempty"` // Include the session transcript of each test
+}
+
+// SMTPSubmissionTestRequest represents a request to test authenticated submission on an SMTP server
+type SMTPSubmissionTestRequest struct {
+	Host               string `json:"host"`
+	Port               int    `json:"port,omitempty"` // Default to 587 if not specified; 465 uses implicit TLS
+	Username           string `json:"username"`
+	Password           string `json:"password"`            // Access token for XOAUTH2
+	Mechanism          string `json:"mechanism,omitempty"` // PLAIN, LOGIN, CRAM-MD5 or XOAUTH2
+	AllowPlaintextAuth bool   `json:"allowPlaintextAuth,omitempty"`
+	FromAddress        string `json:"fromAddress,omitempty"`
+	ToAddress          string `json:"toAddress,omitempty"`
+	SendMessage        bool   `json:"sendMessage,omitempty"`
+	Timeout            int    `json:"timeout,omitempty"`    // In seconds, default to 10
+	Transcript         bool   `json:"transcript,omitempty"` // Include the session transcript
+}
+
+// SMTPSubmissionStageResponse represents one stage of a submission test
+type SMTPSubmissionStageResponse struct {
+	Name         string                        `json:"name"`
+	Success      bool                          `json:"success"`
+	Skipped      bool                          `json:"skipped,omitempty"`
+	ResponseCode int                           `json:"responseCode,omitempty"`
+	EnhancedCode string                        `json:"enhancedCode,omitempty"`
+	Response     string                        `json:"response,omitempty"`
+	Duration     string                        `json:"duration,omitempty"`
+	Explanation  *SMTPReplyExplanationResponse `json:"explanation,omitempty"`
+	Error        string                        `json:"error,omitempty"`
+}
+
+// SMTPSubmissionTestResponse represents the result of a submission test
+type SMTPSubmissionTestResponse struct {
+	Host           string                        `json:"host"`
+	Port           int                           `json:"port"`
+	ImplicitTLS    bool                          `json:"implicitTLS"`
+	TLSVersion     string                        `json:"tlsVersion,omitempty"`
+	AuthMechanisms []string                      `json:"authMechanisms,omitempty"`
+	Mechanism      string                        `json:"mechanism,omitempty"`
+	Authenticated  bool                          `json:"authenticated"`
+	MessageSent    bool                          `json:"messageSent"`
+	MessageID      string                        `json:"messageId,omitempty"`
+	TrackingID     string                        `json:"trackingId,omitempty"`
+	Stages         []SMTPSubmissionStageResponse `json:"stages"`
+	Transcript     []SMTPTranscriptEntryResponse `json:"transcript,omitempty"`
+	Error          string                        `json:"error,omitempty"`
+}
+
+// FromSMTPSubmissionTestResult converts a domain submission test result to an API response
+func FromSMTPSubmissionTestResult(result *smtp.SubmissionTestResult) *SMTPSubmissionTestResponse {
+	if result == nil {
+		return &SMTPSubmissionTestResponse{
+			Error: "no result available",
+		}
+	}
+
+	response := &SMTPSubmissionTestResponse{
+		Host:           result.Host,
+		Port:           result.Port,
+		ImplicitTLS:    result.ImplicitTLS,
+		TLSVersion:     result.TLSVersion,
+		AuthMechanisms: result.AuthMechanisms,
+		Mechanism:      result.Mechanism,
+		Authenticated:  result.Authenticated,
+		MessageSent:    result.MessageSent,
+		MessageID:      result.MessageID,
+		TrackingID:     result.TrackingID,
+		Stages:         make([]SMTPSubmissionStageResponse, 0, len(result.Stages)),
+		Transcript:     FromSMTPTranscript(result.Transcript),
+		Error:          result.Error,
+	}
+
+	for _, stage := range result.Stages {
+		stageResponse := SMTPSubmissionStageResponse{
+			Name:         stage.Name,
+			Success:      stage.Success,
+			Skipped:      stage.Skipped,
+			ResponseCode: stage.ResponseCode,
+			EnhancedCode: stage.EnhancedCode,
+			Response:     stage.Response,
+			Explanation:  FromSMTPReplyExplanation(stage.Explanation),
+			Error:        stage.Error,
+		}
+		if stage.Duration > 0 {
+			stageResponse.Duration = stage.Duration.String()
+		}
+		response.Stages = append(response.Stages, stageResponse)
+	}
+
+	return response
+}
+
+// SMTPReplyExplanationResponse represents the classification of an SMTP reply or bounce
+type SMTPReplyExplanationResponse struct {
+	Code          int    `json:"code,omitempty"`
+	EnhancedCode  string `json:"enhancedCode,omitempty"`
+	Class         string `json:"class"` // success, intermediate, transient or permanent
+	Category      string `json:"category,omitempty"`
+	StatusSubject string `json:"statusSubject,omitempty"`
+	StatusDetail  string `json:"statusDetail,omitempty"`
+	Provider      string `json:"provider,omitempty"`
+	Meaning       string `json:"meaning,omitempty"`
+	Hint          string `json:"hint,omitempty"`
+}
+
+// FromSMTPReplyExplanation converts a domain reply explanation to an API response
+func FromSMTPReplyExplanation(explanation *smtp.ReplyExplanation) *SMTPReplyExplanationResponse {
+	if explanation == nil {
+		return nil
+	}
+	return &SMTPReplyExplanationResponse{
+		Code:          explanation.Code,
+		EnhancedCode:  explanation.EnhancedCode,
+		Class:         explanation.Class,
+		Category:      explanation.Category,
+		StatusSubject: explanation.StatusSubject,
+		StatusDetail:  explanation.StatusDetail,
+		Provider:      explanation.Provider,
+		Meaning:       explanation.Meaning,
+		Hint:          explanation.Hint,
+	}
+}
+
+// SMTPRelayTestCaseResponse represents a single probe of an open relay test
+type SMTPRelayTestCaseResponse struct {
+	Name         string                        `json:"name"`
+	Description  string                        `json:"description,omitempty"`
+	MailFrom     string                        `json:"mailFrom"`
+	RcptTo       string                        `json:"rcptTo"`
+	Accepted     bool                          `json:"accepted"`
+	Verdict      string                        `json:"verdict"`
+	ResponseCode int                           `json:"responseCode,omitempty"`
+	ResponseText string                        `json:"responseText,omitempty"`
+	Explanation  *SMTPReplyExplanationResponse `json:"explanation,omitempty"`
+	Transcript   []SMTPTranscriptEntryResponse `json:"transcript,omitempty"`
+	Error        string                        `json:"error,omitempty"`
 }
 
 // SMTPRelayTestResponse represents the result of an SMTP open relay test
 type SMTPRelayTestResponse struct {
-	Host         string `json:"host"`
-	Port         int    `json:"port"`
-	IsOpenRelay  bool   `json:"isOpenRelay"`
-	AuthRequired bool   `json:"authRequired"`
-	ResponseCode int    `json:"responseCode,omitempty"`
-	ResponseText string `json:"responseText,omitempty"`
-	TestDetails  string `json:"testDetails,omitempty"`
-	Error        string `json:"error,omitempty"`
-}
-
-// FromSMTPRelayTestResult extracts relay test information from the SMTP check
-func FromSMTPRelayTestResult(result *smtp.SMTPResult) *SMTPRelayTestResponse {
+	Host          string                      `json:"host"`
+	Port          int                         `json:"port"`
+	Banner        string                      `json:"banner,omitempty"`
+	IsOpenRelay   bool                        `json:"isOpenRelay"`
+	AuthRequired  bool                        `json:"authRequired"`
+	Authenticated bool                        `json:"authenticated"`
+	ResponseCode  int                         `json:"responseCode,omitempty"`
+	ResponseText  string                      `json:"responseText,omitempty"`
+	Tests         []SMTPRelayTestCaseResponse `json:"tests,omitempty"`
+	TestDetails   string                      `json:"testDetails,omitempty"`
+	Error         string                      `json:"error,omitempty"`
+}
+
+// FromSMTPRelayTestResult converts a domain relay test result to an API response
+func FromSMTPRelayTestResult(result *smtp.RelayTestResult) *SMTPRelayTestResponse {
 	if result == nil {
 		return &SMTPRelayTestResponse{
 			Error: "no result available",
 		}
 	}
 
-	// For now, we assume the relay test result based on available data
-	// In a real implementation, SMTP service would have a dedicated relay test method
 	response := &SMTPRelayTestResponse{
-		Host: result.Domain,
-		Port: 25, // Default value, would get from actual test
+		Host:          result.Host,
+		Port:          result.Port,
+		Banner:        result.Banner,
+		IsOpenRelay:   result.IsOpenRelay,
+		AuthRequired:  result.AuthRequired,
+		Authenticated: result.Authenticated,
+		Tests:         make([]SMTPRelayTestCaseResponse, 0, len(result.Tests)),
+		Error:         result.Error,
 	}
 
-	// Simple determination - not a real relay test
-	// This should be replaced with actual relay test logic
-	isOpenRelay := false
-	authRequired := true
-	testDetails := "Relay test simulated - not actual test results"
-
-	if len(result.ConnectionResults) > 0 {
-		for _, connResult := range result.ConnectionResults {
-			// Just use the first result for this demo
-			response.ResponseText = connResult.Banner
-
-			// Simple check for now - in reality, need to try to relay mail
-			if len(connResult.AuthMethods) == 0 && connResult.Connected {
-				authRequired = false
-				// Only mark as potential open relay if no auth methods AND connected
-				if connResult.Connected {
-					isOpenRelay = true
-					testDetails += " - Potential security issue: Server connected with no authentication methods required"
-				}
-			}
-
-			break
+	relayed := 0
+	for _, test := range result.Tests {
+		if test.Accepted {
+			relayed++
 		}
+		response.Tests = append(response.Tests, SMTPRelayTestCaseResponse{
+			Name:         test.Name,
+			Description:  test.Description,
+			MailFrom:     test.MailFrom,
+			RcptTo:       test.RcptTo,
+			Accepted:     test.Accepted,
+			Verdict:      test.Verdict,
+			ResponseCode: test.ResponseCode,
+			ResponseText: test.Response,
+			Explanation:  FromSMTPReplyExplanation(test.Explanation),
+			Transcript:   FromSMTPTranscript(test.Transcript),
+			Error:        test.Error,
+		})
 	}
 
-	response.IsOpenRelay = isOpenRelay
-	response.AuthRequired = authRequired
-	response.TestDetails = testDetails
-
-	if result.Error != "" {
-		response.Error = result.Error
+	// The first probe (external sender to external recipient) is the classic relay test
+	if len(result.Tests) > 0 {
+		response.ResponseCode = result.Tests[0].ResponseCode
+		response.ResponseText = result.Tests[0].Response
+		response.TestDetails = fmt.Sprintf("%d of %d relay tests accepted the external recipient", relayed, len(result.Tests))
 	}
 
 	return response
@@ -274,14 +641,583 @@ func FromSPFResult(result *emailauth.SPFResult) *SPFResponse {
 	}
 }
 
+// SPFEvaluateRequest represents an SPF evaluation (check_host) for a sending IP
+type SPFEvaluateRequest struct {
+	IP      string `json:"ip"`
+	Domain  string `json:"domain,omitempty"`  // Default to the domain of sender, then to helo
+	Sender  string `json:"sender,omitempty"`  // MAIL FROM address
+	Helo    string `json:"helo,omitempty"`    // HELO/EHLO name
+	Timeout int    `json:"timeout,omitempty"` // In seconds, default to 10
+}
+
+// SPFTraceStepResponse represents one record or term evaluated during an SPF evaluation
+type SPFTraceStepResponse struct {
+	Depth  int    `json:"depth"`
+	Domain string `json:"domain"`
+	Term   string `json:"term,omitempty"`
+	Target string `json:"target,omitempty"`
+	Result string `json:"result"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// SPFEvaluationResponse represents the result of an SPF evaluation with its trace
+type SPFEvaluationResponse struct {
+	Domain      string                 `json:"domain"`
+	IP          string                 `json:"ip"`
+	Sender      string                 `json:"sender,omitempty"`
+	Helo        string                 `json:"helo,omitempty"`
+	Result      string                 `json:"result"`
+	Record      string                 `json:"record,omitempty"`
+	Mechanism   string                 `json:"mechanism,omitempty"`
+	Lookups     int                    `json:"lookups"`
+	VoidLookups int                    `json:"voidLookups"`
+	Explanation string                 `json:"explanation,omitempty"`
+	Trace       []SPFTraceStepResponse `json:"trace"`
+	Error       string                 `json:"error,omitempty"`
+}
+
+// FromSPFEvaluation converts a domain SPF evaluation to an API response
+func FromSPFEvaluation(result *emailauth.SPFEvaluation) *SPFEvaluationResponse {
+	if result == nil {
+		return &SPFEvaluationResponse{
+			Error: "no result available",
+		}
+	}
+
+	response := &SPFEvaluationResponse{
+		Domain:      result.Domain,
+		IP:          result.IP,
+		Sender:      result.Sender,
+		Helo:        result.Helo,
+		Result:      result.Result,
+		Record:      result.Record,
+		Mechanism:   result.Mechanism,
+		Lookups:     result.Lookups,
+		VoidLookups: result.VoidLookups,
+		Explanation: result.Explanation,
+		Trace:       make([]SPFTraceStepResponse, 0, len(result.Trace)),
+		Error:       result.Error,
+	}
+	for _, step := range result.Trace {
+		response.Trace = append(response.Trace, SPFTraceStepResponse{
+			Depth:  step.Depth,
+			Domain: step.Domain,
+			Term:   step.Term,
+			Target: step.Target,
+			Result: step.Result,
+			Detail: step.Detail,
+		})
+	}
+
+	return response
+}
+
+// SPFLookupAnalysisRequest represents an SPF lookup budget analysis
+type SPFLookupAnalysisRequest struct {
+	Domain  string `json:"domain"`
+	Timeout int    `json:"timeout,omitempty"` // In seconds, default to 30
+}
+
+// SPFTermResponse represents one term of an SPF record in the lookup tree
+type SPFTermResponse struct {
+	Term        string           `json:"term"`
+	Lookups     int              `json:"lookups"`
+	VoidLookups int              `json:"voidLookups,omitempty"`
+	MXHosts     int              `json:"mxHosts,omitempty"`
+	Networks    []string         `json:"networks,omitempty"`
+	Target      *SPFTreeResponse `json:"target,omitempty"` // Record of an include or redirect target
+	Note        string           `json:"note,omitempty"`
+}
+
+// SPFTreeResponse represents an SPF record in the include/redirect tree
+type SPFTreeResponse struct {
+	Domain        string            `json:"domain"`
+	Record        string            `json:"record,omitempty"`
+	Length        int               `json:"length"`
+	StringLengths []int             `json:"stringLengths,omitempty"`
+	Lookups       int               `json:"lookups"`
+	VoidLookups   int               `json:"voidLookups"`
+	Terms         []SPFTermResponse `json:"terms,omitempty"`
+	Error         string            `json:"error,omitempty"`
+}
+
+// SPFFlattenedRecordResponse represents one TXT record of a flattened SPF policy
+type SPFFlattenedRecordResponse struct {
+	Name   string `json:"name"`
+	Record string `json:"record"`
+	Length int    `json:"length"`
+}
+
+// SPFFlatteningResponse represents a flattened candidate SPF policy
+type SPFFlatteningResponse struct {
+	Records  []SPFFlattenedRecordResponse `json:"records"`
+	Networks int                          `json:"networks"`
+	Lookups  int                          `json:"lookups"`
+	Warnings []string                     `json:"warnings,omitempty"`
+}
+
+// SPFFindingResponse represents a problem found in an SPF record
+type SPFFindingResponse struct {
+	Severity string `json:"severity"`
+	Code     string `json:"code"`
+	Domain   string `json:"domain,omitempty"`
+	Message  string `json:"message"`
+}
+
+// SPFLookupAnalysisResponse represents the SPF lookup tree, its budget and the flattened candidate
+type SPFLookupAnalysisResponse struct {
+	Domain          string                 `json:"domain"`
+	Tree            *SPFTreeResponse       `json:"tree,omitempty"`
+	TreeText        string                 `json:"treeText,omitempty"` // Indented text view of the tree
+	Lookups         int                    `json:"lookups"`
+	LookupLimit     int                    `json:"lookupLimit"`
+	VoidLookups     int                    `json:"voidLookups"`
+	VoidLookupLimit int                    `json:"voidLookupLimit"`
+	RecordLength    int                    `json:"recordLength"`
+	Flattened       *SPFFlatteningResponse `json:"flattened,omitempty"`
+	Findings        []SPFFindingResponse   `json:"findings"`
+	Error           string                 `json:"error,omitempty"`
+}
+
+// FromSPFLookupAnalysis converts a domain SPF lookup analysis to an API response
+func FromSPFLookupAnalysis(result *emailauth.SPFLookupAnalysis, treeText string) *SPFLookupAnalysisResponse {
+	if result == nil {
+		return &SPFLookupAnalysisResponse{
+			Error: "no result available",
+		}
+	}
+
+	response := &SPFLookupAnalysisResponse{
+		Domain:          result.Domain,
+		Tree:            fromSPFTreeNode(result.Tree),
+		TreeText:        treeText,
+		Lookups:         result.Lookups,
+		LookupLimit:     result.LookupLimit,
+		VoidLookups:     result.VoidLookups,
+		VoidLookupLimit: result.VoidLookupLimit,
+		RecordLength:    result.RecordLength,
+		Findings:        make([]SPFFindingResponse, 0, len(result.Findings)),
+		Error:           result.Error,
+	}
+	for _, finding := range result.Findings {
+		response.Findings = append(response.Findings, SPFFindingResponse{
+			Severity: finding.Severity,
+			Code:     finding.Code,
+			Domain:   finding.Domain,
+			Message:  finding.Message,
+		})
+	}
+	if flattened := result.Flattened; flattened != nil {
+		response.Flattened = &SPFFlatteningResponse{
+			Records:  make([]SPFFlattenedRecordResponse, 0, len(flattened.Records)),
+			Networks: flattened.Networks,
+			Lookups:  flattened.Lookups,
+			Warnings: flattened.Warnings,
+		}
+		for _, record := range flattened.Records {
+			response.Flattened.Records = append(response.Flattened.Records, SPFFlattenedRecordResponse{
+				Name:   record.Name,
+				Record: record.Record,
+				Length: record.Length,
+			})
+		}
+	}
+
+	return response
+}
+
+// fromSPFTreeNode converts an SPF lookup tree to an API response
+func fromSPFTreeNode(node *emailauth.SPFTreeNode) *SPFTreeResponse {
+	if node == nil {
+		return nil
+	}
+
+	response := &SPFTreeResponse{
+		Domain:        node.Domain,
+		Record:        node.Record,
+		Length:        node.Length,
+		StringLengths: node.StringLengths,
+		Lookups:       node.Lookups,
+		VoidLookups:   node.VoidLookups,
+		Error:         node.Error,
+	}
+	for _, term := range node.Terms {
+		response.Terms = append(response.Terms, SPFTermResponse{
+			Term:        term.Term,
+			Lookups:     term.Lookups,
+			VoidLookups: term.VoidLookups,
+			MXHosts:     term.MXHosts,
+			Networks:    term.Networks,
+			Target:      fromSPFTreeNode(term.Target),
+			Note:        term.Note,
+		})
+	}
+
+	return response
+}
+
+// DKIMVerifyRequest represents the verification of the DKIM signatures of an uploaded message
+type DKIMVerifyRequest struct {
+	Message string `json:"message"`           // Raw RFC 5322 message (.eml), headers and body
+	Timeout int    `json:"timeout,omitempty"` // In seconds, for each key lookup, default to 10
+}
+
+// DKIMSignatureVerificationResponse represents the verification of one DKIM-Signature header
+type DKIMSignatureVerificationResponse struct {
+	Domain            string     `json:"domain"`
+	Selector          string     `json:"selector"`
+	Algorithm         string     `json:"algorithm,omitempty"`
+	Canonicalization  string     `json:"canonicalization,omitempty"`
+	SignedHeaders     []string   `json:"signedHeaders,omitempty"`
+	Identity          string     `json:"identity,omitempty"`
+	Timestamp         *time.Time `json:"timestamp,omitempty"`
+	Expiration        *time.Time `json:"expiration,omitempty"`
+	BodyLength        *int64     `json:"bodyLength,omitempty"` // l= tag
+	UnsignedBodyBytes int64      `json:"unsignedBodyBytes,omitempty"`
+	UnsignedHeaders   []string   `json:"unsignedHeaders,omitempty"`
+	Warnings          []string   `json:"warnings,omitempty"`
+	Result            string     `json:"result"`
+	Reason            string     `json:"reason,omitempty"`
+}
+
+// DKIMVerificationResponse represents the verification of every DKIM signature of a message
+type DKIMVerificationResponse struct {
+	Signatures []DKIMSignatureVerificationResponse `json:"signatures"`
+	Passed     int                                 `json:"passed"`
+	Summary    string                              `json:"summary,omitempty"` // Human-readable report
+	Error      string                              `json:"error,omitempty"`
+}
+
+// FromDKIMVerification converts a domain DKIM verification to an API response
+func FromDKIMVerification(result *emailauth.DKIMVerification, summary string) *DKIMVerificationResponse {
+	if result == nil {
+		return &DKIMVerificationResponse{
+			Error: "no result available",
+		}
+	}
+
+	response := &DKIMVerificationResponse{
+		Signatures: make([]DKIMSignatureVerificationResponse, 0, len(result.Signatures)),
+		Passed:     result.Passed,
+		Summary:    summary,
+		Error:      result.Error,
+	}
+	for _, sig := range result.Signatures {
+		response.Signatures = append(response.Signatures, fromDKIMSignature(sig))
+	}
+
+	return response
+}
+
+// fromDKIMSignature converts the verification of one DKIM-Signature or ARC-Message-Signature header
+func fromDKIMSignature(sig emailauth.DKIMSignatureVerification) DKIMSignatureVerificationResponse {
+	return DKIMSignatureVerificationResponse{
+		Domain:            sig.Domain,
+		Selector:          sig.Selector,
+		Algorithm:         sig.Algorithm,
+		Canonicalization:  sig.Canonicalization,
+		SignedHeaders:     sig.SignedHeaders,
+		Identity:          sig.Identity,
+		Timestamp:         sig.Timestamp,
+		Expiration:        sig.Expiration,
+		BodyLength:        sig.BodyLength,
+		UnsignedBodyBytes: sig.UnsignedBodyBytes,
+		UnsignedHeaders:   sig.UnsignedHeaders,
+		Warnings:          sig.Warnings,
+		Result:            sig.Result,
+		Reason:            sig.Reason,
+	}
+}
+
+// ARCVerifyRequest represents the validation of the ARC chain of an uploaded message
+type ARCVerifyRequest struct {
+	Message string `json:"message"`           // Raw RFC 5322 message (.eml), headers and body
+	Timeout int    `json:"timeout,omitempty"` // In seconds, for each key lookup, default to 10
+}
+
+// ARCSetVerificationResponse represents the verification of one ARC set
+type ARCSetVerificationResponse struct {
+	Instance              int                                `json:"instance"`
+	SealDomain            string                             `json:"sealDomain,omitempty"`
+	SealSelector          string                             `json:"sealSelector,omitempty"`
+	SealAlgorithm         string                             `json:"sealAlgorithm,omitempty"`
+	SealTimestamp         *time.Time                         `json:"sealTimestamp,omitempty"`
+	ChainValidation       string                             `json:"chainValidation,omitempty"` // cv= recorded by the sealer
+	SealResult            string                             `json:"sealResult"`
+	SealReason            string                             `json:"sealReason,omitempty"`
+	MessageSignature      *DKIMSignatureVerificationResponse `json:"messageSignature,omitempty"`
+	AuthenticationResults *AuthResultsResponse               `json:"authenticationResults,omitempty"` // ARC-Authentication-Results
+}
+
+// ARCValidationResponse represents the validation of the ARC chain of a message
+type ARCValidationResponse struct {
+	Result     string                       `json:"result"` // none, pass or fail
+	Reason     string                       `json:"reason,omitempty"`
+	Instances  int                          `json:"instances"`
+	OldestPass int                          `json:"oldestPass"`
+	Sets       []ARCSetVerificationResponse `json:"sets"`
+	Findings   []string                     `json:"findings,omitempty"`
+	Summary    string                       `json:"summary,omitempty"` // Human-readable report
+}
+
+// FromARCValidation converts a domain ARC validation to an API response
+func FromARCValidation(result *emailauth.ARCValidation, summary string) *ARCValidationResponse {
+	if result == nil {
+		return &ARCValidationResponse{Result: "none", Sets: []ARCSetVerificationResponse{}}
+	}
+
+	response := &ARCValidationResponse{
+		Result:     result.Result,
+		Reason:     result.Reason,
+		Instances:  result.Instances,
+		OldestPass: result.OldestPass,
+		Sets:       make([]ARCSetVerificationResponse, 0, len(result.Sets)),
+		Findings:   result.Findings,
+		Summary:    summary,
+	}
+	for _, set := range result.Sets {
+		item := ARCSetVerificationResponse{
+			Instance:        set.Instance,
+			SealDomain:      set.SealDomain,
+			SealSelector:    set.SealSelector,
+			SealAlgorithm:   set.SealAlgorithm,
+			SealTimestamp:   set.SealTimestamp,
+			ChainValidation: set.ChainValidation,
+			SealResult:      set.SealResult,
+			SealReason:      set.SealReason,
+		}
+		if set.MessageSignature != nil {
+			signature := fromDKIMSignature(*set.MessageSignature)
+			item.MessageSignature = &signature
+		}
+		if set.AuthenticationResults != nil {
+			item.AuthenticationResults = &fromAuthResults([]emailauth.AuthenticationResults{*set.AuthenticationResults})[0]
+		}
+		response.Sets = append(response.Sets, item)
+	}
+
+	return response
+}
+
+// DKIMDiscoverRequest represents the discovery of the DKIM selectors of a domain
+type DKIMDiscoverRequest struct {
+	Domain    string   `json:"domain"`
+	Selectors []string `json:"selectors,omitempty"` // Tried before the guessed ones
+	Timeout   int      `json:"timeout,omitempty"`   // In seconds, for each lookup, default to 5
+}
+
+// DKIMSelectorResponse represents a DKIM selector found by discovery
+type DKIMSelectorResponse struct {
+	Selector    string `json:"selector"`
+	Record      string `json:"record"`
+	CNAME       string `json:"cname,omitempty"`
+	Source      string `json:"source"` // custom, provider, dictionary or dated
+	Provider    string `json:"provider,omitempty"`
+	Attribution string `json:"attribution,omitempty"` // cname, mx/spf or selector
+	KeyType     string `json:"keyType,omitempty"`
+	KeyBits     int    `json:"keyBits,omitempty"`
+	KeyStrength string `json:"keyStrength,omitempty"`
+}
+
+// DKIMDiscoveryResponse represents the DKIM selectors found for a domain
+type DKIMDiscoveryResponse struct {
+	Domain         string                 `json:"domain"`
+	Providers      []string               `json:"providers,omitempty"` // Detected from MX and SPF
+	Tried          int                    `json:"tried"`
+	Wildcard       bool                   `json:"wildcard"`
+	WildcardRecord string                 `json:"wildcardRecord,omitempty"`
+	Selectors      []DKIMSelectorResponse `json:"selectors"`
+	Summary        string                 `json:"summary,omitempty"` // Human-readable report
+	Error          string                 `json:"error,omitempty"`
+}
+
+// FromDKIMDiscovery converts a domain DKIM selector discovery to an API response
+func FromDKIMDiscovery(result *emailauth.DKIMDiscovery, summary string) *DKIMDiscoveryResponse {
+	if result == nil {
+		return &DKIMDiscoveryResponse{
+			Error: "no result available",
+		}
+	}
+
+	response := &DKIMDiscoveryResponse{
+		Domain:         result.Domain,
+		Providers:      result.Providers,
+		Tried:          result.Tried,
+		Wildcard:       result.Wildcard,
+		WildcardRecord: result.WildcardRecord,
+		Selectors:      make([]DKIMSelectorResponse, 0, len(result.Selectors)),
+		Summary:        summary,
+		Error:          result.Error,
+	}
+	for _, hit := range result.Selectors {
+		response.Selectors = append(response.Selectors, DKIMSelectorResponse{
+			Selector:    hit.Selector,
+			Record:      hit.Record,
+			CNAME:       hit.CNAME,
+			Source:      hit.Source,
+			Provider:    hit.Provider,
+			Attribution: hit.Attribution,
+			KeyType:     hit.KeyType,
+			KeyBits:     hit.KeyBits,
+			KeyStrength: hit.KeyStrength,
+		})
+	}
+
+	return response
+}
+
+// BIMICheckRequest represents the BIMI check of a domain
+type BIMICheckRequest struct {
+	Domain   string `json:"domain"`
+	Selector string `json:"selector,omitempty"` // Default to "default"
+	Timeout  int    `json:"timeout,omitempty"`  // In seconds, for each lookup and download, default to 10
+}
+
+// BIMIRecordResponse represents a BIMI assertion record
+type BIMIRecordResponse struct {
+	Raw              string   `json:"raw"`
+	LogoURL          string   `json:"logoUrl,omitempty"`          // l= tag
+	AuthorityURL     string   `json:"authorityUrl,omitempty"`     // a= tag
+	AvatarPreference string   `json:"avatarPreference,omitempty"` // avp= tag
+	Declined         bool     `json:"declined"`
+	UnknownTags      []string `json:"unknownTags,omitempty"`
+}
+
+// BIMIDMARCResponse represents the DMARC prerequisite of BIMI
+type BIMIDMARCResponse struct {
+	PolicyDomain string `json:"policyDomain,omitempty"`
+	Record       string `json:"record,omitempty"`
+	AppliedTag   string `json:"appliedTag,omitempty"` // p, sp or np
+	Policy       string `json:"policy,omitempty"`
+	Pct          int    `json:"pct"`
+	Enforced     bool   `json:"enforced"`
+	Reason       string `json:"reason,omitempty"`
+}
+
+// BIMILogoResponse represents the validation of a BIMI logo against the SVG Tiny PS profile
+type BIMILogoResponse struct {
+	URL         string   `json:"url"`
+	ContentType string   `json:"contentType,omitempty"`
+	Size        int      `json:"size"`
+	Compressed  bool     `json:"compressed"`
+	SHA256      string   `json:"sha256,omitempty"`
+	Title       string   `json:"title,omitempty"`
+	Version     string   `json:"version,omitempty"`
+	BaseProfile string   `json:"baseProfile,omitempty"`
+	ViewBox     string   `json:"viewBox,omitempty"`
+	Square      bool     `json:"square"`
+	Valid       bool     `json:"valid"`
+	Errors      []string `json:"errors,omitempty"`
+	Warnings    []string `json:"warnings,omitempty"`
+	Error       string   `json:"error,omitempty"`
+}
+
+// BIMICertificateResponse represents the validation of a VMC or CMC
+type BIMICertificateResponse struct {
+	URL           string     `json:"url"`
+	Type          string     `json:"type,omitempty"` // VMC or CMC
+	MarkType      string     `json:"markType,omitempty"`
+	Subject       string     `json:"subject,omitempty"`
+	Organization  string     `json:"organization,omitempty"`
+	Issuer        string     `json:"issuer,omitempty"`
+	SerialNumber  string     `json:"serialNumber,omitempty"`
+	DNSNames      []string   `json:"dnsNames,omitempty"`
+	NotBefore     *time.Time `json:"notBefore,omitempty"`
+	NotAfter      *time.Time `json:"notAfter,omitempty"`
+	Expired       bool       `json:"expired"`
+	Chain         []string   `json:"chain,omitempty"` // Subjects from the leaf to the root
+	ChainValid    bool       `json:"chainValid"`
+	ChainError    string     `json:"chainError,omitempty"`
+	BIMIUsage     bool       `json:"bimiUsage"`
+	DomainCovered bool       `json:"domainCovered"`
+	LogoEmbedded  bool       `json:"logoEmbedded"`
+	LogoHashValid bool       `json:"logoHashValid"`
+	LogoMatches   bool       `json:"logoMatches"`
+	Valid         bool       `json:"valid"`
+	Errors        []string   `json:"errors,omitempty"`
+	Error         string     `json:"error,omitempty"`
+}
+
+// BIMIResponse represents the BIMI check of a domain
+type BIMIResponse struct {
+	Domain      string                   `json:"domain"`
+	Selector    string                   `json:"selector"`
+	RecordName  string                   `json:"recordName,omitempty"`
+	Record      *BIMIRecordResponse      `json:"record,omitempty"`
+	DMARC       *BIMIDMARCResponse       `json:"dmarc,omitempty"`
+	Logo        *BIMILogoResponse        `json:"logo,omitempty"`
+	Certificate *BIMICertificateResponse `json:"certificate,omitempty"`
+	Ready       bool                     `json:"ready"` // Every check passed
+	Findings    []string                 `json:"findings,omitempty"`
+	Summary     string                   `json:"summary,omitempty"` // Human-readable report
+	Error       string                   `json:"error,omitempty"`
+}
+
+// FromBIMIResult converts a domain BIMI check to an API response
+func FromBIMIResult(result *emailauth.BIMIResult, summary string) *BIMIResponse {
+	if result == nil {
+		return &BIMIResponse{
+			Error: "no result available",
+		}
+	}
+
+	response := &BIMIResponse{
+		Domain:     result.Domain,
+		Selector:   result.Selector,
+		RecordName: result.RecordName,
+		Ready:      result.Ready,
+		Findings:   result.Findings,
+		Summary:    summary,
+		Error:      result.Error,
+	}
+	if result.Record != nil {
+		record := BIMIRecordResponse(*result.Record)
+		response.Record = &record
+	}
+	if result.DMARC != nil {
+		dmarc := BIMIDMARCResponse(*result.DMARC)
+		response.DMARC = &dmarc
+	}
+	if result.Logo != nil {
+		logo := BIMILogoResponse(*result.Logo)
+		response.Logo = &logo
+	}
+	if result.Certificate != nil {
+		cert := BIMICertificateResponse(*result.Certificate)
+		response.Certificate = &cert
+	}
+
+	return response
+}
+
 // DKIMResponse represents the result of a DKIM record check
 type DKIMResponse struct {
-	Domain     string            `json:"domain"`
-	Selector   string            `json:"selector"`
-	HasRecords bool              `json:"hasRecords"`
-	Records    map[string]string `json:"records,omitempty"`
-	IsValid    bool              `json:"isValid"`
-	Error      string            `json:"error,omitempty"`
+	Domain     string                     `json:"domain"`
+	Selector   string                     `json:"selector"`
+	HasRecords bool                       `json:"hasRecords"`
+	Records    map[string]string          `json:"records,omitempty"`
+	IsValid    bool                       `json:"isValid"`
+	Keys       map[string]DKIMKeyResponse `json:"keys,omitempty"` // Selector to decoded key
+	Error      string                     `json:"error,omitempty"`
+}
+
+// DKIMKeyResponse represents the public key published by a DKIM selector
+type DKIMKeyResponse struct {
+	KeyType        string                `json:"keyType"`
+	KeyBits        int                   `json:"keyBits,omitempty"`
+	Strength       string                `json:"strength"` // strong, weak or failing
+	HashAlgorithms []string              `json:"hashAlgorithms,omitempty"`
+	Services       []string              `json:"services,omitempty"`
+	Revoked        bool                  `json:"revoked"`
+	Testing        bool                  `json:"testing"`
+	Findings       []DKIMFindingResponse `json:"findings"`
+}
+
+// DKIMFindingResponse represents a problem found in a DKIM key record
+type DKIMFindingResponse struct {
+	Severity string `json:"severity"`
+	Code     string `json:"code"`
+	Message  string `json:"message"`
 }
 
 // CombinedDKIMResponse represents results from multiple DKIM selectors
@@ -311,25 +1247,94 @@ func FromDKIMResult(result *emailauth.DKIMResult) *DKIMResponse {
 		}
 	}
 
-	return &DKIMResponse{
+	response := &DKIMResponse{
 		Selector:   selector,
 		HasRecords: result.HasRecords,
 		Records:    result.Records,
 		IsValid:    result.IsValid,
 		Error:      result.Error,
 	}
+	if len(result.Keys) > 0 {
+		response.Keys = make(map[string]DKIMKeyResponse, len(result.Keys))
+		for keySelector, key := range result.Keys {
+			keyResponse := DKIMKeyResponse{
+				KeyType:        key.KeyType,
+				KeyBits:        key.KeyBits,
+				Strength:       key.Strength,
+				HashAlgorithms: key.HashAlgorithms,
+				Services:       key.Services,
+				Revoked:        key.Revoked,
+				Testing:        key.Testing,
+				Findings:       make([]DKIMFindingResponse, 0, len(key.Findings)),
+			}
+			for _, finding := range key.Findings {
+				keyResponse.Findings = append(keyResponse.Findings, DKIMFindingResponse{
+					Severity: finding.Severity,
+					Code:     finding.Code,
+					Message:  finding.Message,
+				})
+			}
+			response.Keys[keySelector] = keyResponse
+		}
+	}
+
+	return response
 }
 
 // DMARCResponse represents the result of a DMARC record check
 type DMARCResponse struct {
-	Domain          string `json:"domain"`
-	HasRecord       bool   `json:"hasRecord"`
-	Record          string `json:"record,omitempty"`
-	IsValid         bool   `json:"isValid"`
-	Policy          string `json:"policy,omitempty"`
-	SubdomainPolicy string `json:"subdomainPolicy,omitempty"`
-	Percentage      int    `json:"percentage,omitempty"`
-	Error           string `json:"error,omitempty"`
+	Domain               string                   `json:"domain"`
+	HasRecord            bool                     `json:"hasRecord"`
+	Record               string                   `json:"record,omitempty"`
+	IsValid              bool                     `json:"isValid"`
+	Policy               string                   `json:"policy,omitempty"`
+	SubdomainPolicy      string                   `json:"subdomainPolicy,omitempty"`
+	Percentage           int                      `json:"percentage,omitempty"`
+	NonexistentPolicy    string                   `json:"nonexistentPolicy,omitempty"` // np tag
+	PSD                  string                   `json:"psd,omitempty"`
+	AlignmentDKIM        string                   `json:"alignmentDkim,omitempty"`
+	AlignmentSPF         string                   `json:"alignmentSpf,omitempty"`
+	FailureOptions       []string                 `json:"failureOptions,omitempty"`
+	ReportFormats        []string                 `json:"reportFormats,omitempty"`
+	ReportInterval       int                      `json:"reportInterval,omitempty"` // In seconds
+	AggregateReports     []DMARCReportURIResponse `json:"aggregateReports,omitempty"`
+	FailureReports       []DMARCReportURIResponse `json:"failureReports,omitempty"`
+	UnknownTags          []string                 `json:"unknownTags,omitempty"`
+	TagErrors            []DMARCTagErrorResponse  `json:"tagErrors,omitempty"`
+	OrganizationalDomain string                   `json:"organizationalDomain,omitempty"`
+	PolicyDomain         string                   `json:"policyDomain,omitempty"` // Domain whose record applies
+	Inherited            bool                     `json:"inherited"`
+	AppliedPolicy        string                   `json:"appliedPolicy,omitempty"`
+	AppliedTag           string                   `json:"appliedTag,omitempty"` // p, sp or np
+	PolicyReason         string                   `json:"policyReason,omitempty"`
+	Lookups              []DMARCLookupResponse    `json:"lookups,omitempty"`
+	Error                string                   `json:"error,omitempty"`
+}
+
+// DMARCLookupResponse represents one DNS query of the DMARC policy discovery
+type DMARCLookupResponse struct {
+	Name   string `json:"name"`
+	Result string `json:"result"` // record, none, multiple or error
+	Record string `json:"record,omitempty"`
+}
+
+// DMARCReportURIResponse represents a destination of DMARC reports
+type DMARCReportURIResponse struct {
+	URI                 string `json:"uri"`
+	Address             string `json:"address,omitempty"`
+	Domain              string `json:"domain,omitempty"`
+	MaxSize             int64  `json:"maxSize,omitempty"` // In bytes
+	External            bool   `json:"external"`
+	Authorized          bool   `json:"authorized"` // For external destinations
+	AuthorizationRecord string `json:"authorizationRecord,omitempty"`
+	Error               string `json:"error,omitempty"`
+}
+
+// DMARCTagErrorResponse represents an invalid tag of a DMARC record
+type DMARCTagErrorResponse struct {
+	Tag     string `json:"tag"`
+	Value   string `json:"value,omitempty"`
+	Message string `json:"message"`
 }
 
 // FromDMARCResult converts a domain DMARC result to an API response
@@ -340,15 +1345,618 @@ func FromDMARCResult(result *emailauth.DMARCResult) *DMARCResponse {
 		}
 	}
 
-	return &DMARCResponse{
-		HasRecord:       result.HasRecord,
-		Record:          result.Record,
-		IsValid:         result.IsValid,
-		Policy:          result.Policy,
-		SubdomainPolicy: result.SubdomainPolicy,
-		Percentage:      result.Percentage,
-		Error:           result.Error,
+	response := &DMARCResponse{
+		HasRecord:            result.HasRecord,
+		Record:               result.Record,
+		IsValid:              result.IsValid,
+		Policy:               result.Policy,
+		SubdomainPolicy:      result.SubdomainPolicy,
+		Percentage:           result.Percentage,
+		NonexistentPolicy:    result.NonexistentPolicy,
+		PSD:                  result.PSD,
+		AlignmentDKIM:        result.AlignmentDKIM,
+		AlignmentSPF:         result.AlignmentSPF,
+		FailureOptions:       result.FailureOptions,
+		ReportFormats:        result.ReportFormats,
+		ReportInterval:       result.ReportInterval,
+		AggregateReports:     fromDMARCReportURIs(result.AggregateReports),
+		FailureReports:       fromDMARCReportURIs(result.FailureReports),
+		UnknownTags:          result.UnknownTags,
+		OrganizationalDomain: result.OrganizationalDomain,
+		PolicyDomain:         result.PolicyDomain,
+		Inherited:            result.Inherited,
+		AppliedPolicy:        result.AppliedPolicy,
+		AppliedTag:           result.AppliedTag,
+		PolicyReason:         result.PolicyReason,
+		Error:                result.Error,
+	}
+	for _, lookup := range result.Lookups {
+		response.Lookups = append(response.Lookups, DMARCLookupResponse{
+			Name:   lookup.Name,
+			Result: lookup.Result,
+			Record: lookup.Record,
+		})
+	}
+	for _, tagErr := range result.TagErrors {
+		response.TagErrors = append(response.TagErrors, DMARCTagErrorResponse{
+			Tag:     tagErr.Tag,
+			Value:   tagErr.Value,
+			Message: tagErr.Message,
+		})
+	}
+
+	return response
+}
+
+// fromDMARCReportURIs converts DMARC report destinations to API responses
+func fromDMARCReportURIs(uris []emailauth.DMARCReportURI) []DMARCReportURIResponse {
+	var response []DMARCReportURIResponse
+	for _, uri := range uris {
+		response = append(response, DMARCReportURIResponse{
+			URI:                 uri.URI,
+			Address:             uri.Address,
+			Domain:              uri.Domain,
+			MaxSize:             uri.MaxSize,
+			External:            uri.External,
+			Authorized:          uri.Authorized,
+			AuthorizationRecord: uri.AuthorizationRecord,
+			Error:               uri.Error,
+		})
+	}
+	return response
+}
+
+// DMARCReportUploadFile represents an uploaded DMARC report file
+type DMARCReportUploadFile struct {
+	Name    string `json:"name"`
+	Content []byte `json:"content"` // Base64: plain XML, gzip or zip aggregate reports, or an ARF message
+}
+
+// DMARCReportIngestRequest represents a request to store DMARC aggregate reports
+type DMARCReportIngestRequest struct {
+	Files []DMARCReportUploadFile `json:"files"`
+}
+
+// DMARCReportFileResponse represents the ingestion of one file
+type DMARCReportFileResponse struct {
+	Name       string `json:"name"`
+	Reports    int    `json:"reports"`
+	Added      int    `json:"added"`
+	Duplicates int    `json:"duplicates"`
+	Error      string `json:"error,omitempty"`
+}
+
+// DMARCReportIngestResponse represents the ingestion of a set of report files
+type DMARCReportIngestResponse struct {
+	Files      []DMARCReportFileResponse `json:"files"`
+	Reports    int                       `json:"reports"`
+	Added      int                       `json:"added"`
+	Duplicates int                       `json:"duplicates"`
+	Failed     int                       `json:"failed"`
+	Summary    string                    `json:"summary,omitempty"` // Human-readable report
+}
+
+// FromDMARCIngest converts a domain report ingestion to an API response
+func FromDMARCIngest(result *emailauth.DMARCIngestResult, summary string) *DMARCReportIngestResponse {
+	if result == nil {
+		return &DMARCReportIngestResponse{}
+	}
+
+	response := &DMARCReportIngestResponse{
+		Files:      make([]DMARCReportFileResponse, 0, len(result.Files)),
+		Reports:    result.Reports,
+		Added:      result.Added,
+		Duplicates: result.Duplicates,
+		Failed:     result.Failed,
+		Summary:    summary,
+	}
+	for _, file := range result.Files {
+		response.Files = append(response.Files, DMARCReportFileResponse{
+			Name:       file.Name,
+			Reports:    file.Reports,
+			Added:      file.Added,
+			Duplicates: file.Duplicates,
+			Error:      file.Error,
+		})
+	}
+	return response
+}
+
+// DMARCReportSummaryRequest represents a request to summarize the stored aggregate reports
+type DMARCReportSummaryRequest struct {
+	Domain       string   `json:"domain,omitempty"` // Subdomains match too
+	Since        string   `json:"since,omitempty"`  // YYYY-MM-DD or RFC 3339
+	Until        string   `json:"until,omitempty"`
+	Days         int      `json:"days,omitempty"`         // Reports of the last N days, when since is not given
+	Top          int      `json:"top,omitempty"`          // Sources listed, default to 20
+	NoLookup     bool     `json:"noLookup,omitempty"`     // Skip the reverse DNS and ASN lookups
+	KnownSenders []string `json:"knownSenders,omitempty"` // IPs, CIDR ranges, ASNs (AS64500) or reverse DNS domains
+	Timeout      int      `json:"timeout,omitempty"`      // In seconds, for each source lookup, default to 5
+}
+
+// DMARCTallyResponse represents message counts by authentication outcome, with percentages
+type DMARCTallyResponse struct {
+	Messages        int            `json:"messages"`
+	SPFPass         int            `json:"spfPass"`
+	SPFPassRate     float64        `json:"spfPassRate"`
+	SPFAligned      int            `json:"spfAligned"`
+	SPFAlignedRate  float64        `json:"spfAlignedRate"`
+	DKIMPass        int            `json:"dkimPass"`
+	DKIMPassRate    float64        `json:"dkimPassRate"`
+	DKIMAligned     int            `json:"dkimAligned"`
+	DKIMAlignedRate float64        `json:"dkimAlignedRate"`
+	DMARCPass       int            `json:"dmarcPass"`
+	DMARCPassRate   float64        `json:"dmarcPassRate"`
+	Dispositions    map[string]int `json:"dispositions"`
+}
+
+// DMARCSourceResponse represents the messages reported for one source IP
+type DMARCSourceResponse struct {
+	SourceIP  string `json:"sourceIp"`
+	PTR       string `json:"ptr,omitempty"`
+	PTRDomain string `json:"ptrDomain,omitempty"`
+	ASN       int    `json:"asn,omitempty"`
+	ASName    string `json:"asName,omitempty"`
+	Country   string `json:"country,omitempty"`
+	DMARCTallyResponse
+	HeaderFromDomains []string `json:"headerFromDomains,omitempty"`
+	Reporters         []string `json:"reporters,omitempty"`
+	Known             bool     `json:"known"`
+	KnownBy           string   `json:"knownBy,omitempty"`
+}
+
+// DMARCSourceGroupResponse represents the sources of one ASN or reverse DNS domain
+type DMARCSourceGroupResponse struct {
+	Name    string `json:"name"`
+	Sources int    `json:"sources"`
+	DMARCTallyResponse
+}
+
+// DMARCReportSummaryResponse represents the analytics of the stored aggregate reports
+type DMARCReportSummaryResponse struct {
+	Reports   int       `json:"reports"`
+	Reporters []string  `json:"reporters"`
+	Domains   []string  `json:"domains"`
+	Begin     time.Time `json:"begin"`
+	End       time.Time `json:"end"`
+	DMARCTallyResponse
+	TotalSources   int                        `json:"totalSources"`
+	Sources        []DMARCSourceResponse      `json:"sources"`
+	ByASN          []DMARCSourceGroupResponse `json:"byAsn,omitempty"`
+	ByReverseDNS   []DMARCSourceGroupResponse `json:"byReverseDns,omitempty"`
+	UnknownFailing []DMARCSourceResponse      `json:"unknownFailing"` // Unknown senders failing alignment
+	Findings       []string                   `json:"findings,omitempty"`
+	Summary        string                     `json:"summary,omitempty"` // Human-readable report
+}
+
+// FromDMARCReportSummary converts domain aggregate report analytics to an API response
+func FromDMARCReportSummary(result *emailauth.DMARCReportSummary, summary string) *DMARCReportSummaryResponse {
+	if result == nil {
+		return &DMARCReportSummaryResponse{}
+	}
+
+	response := &DMARCReportSummaryResponse{
+		Reports:            result.Reports,
+		Reporters:          result.Reporters,
+		Domains:            result.Domains,
+		Begin:              result.Begin,
+		End:                result.End,
+		DMARCTallyResponse: fromDMARCTally(&result.DMARCTally),
+		TotalSources:       result.TotalSources,
+		Sources:            fromDMARCSources(result.Sources),
+		UnknownFailing:     fromDMARCSources(result.UnknownFailing),
+		Findings:           result.Findings,
+		Summary:            summary,
+	}
+	for _, group := range result.ByASN {
+		response.ByASN = append(response.ByASN, DMARCSourceGroupResponse{Name: group.Name, Sources: group.Sources, DMARCTallyResponse: fromDMARCTally(&group.DMARCTally)})
+	}
+	for _, group := range result.ByReverseDNS {
+		response.ByReverseDNS = append(response.ByReverseDNS, DMARCSourceGroupResponse{Name: group.Name, Sources: group.Sources, DMARCTallyResponse: fromDMARCTally(&group.DMARCTally)})
+	}
+	return response
+}
+
+// fromDMARCTally converts message counts to an API response with percentages
+func fromDMARCTally(tally *emailauth.DMARCTally) DMARCTallyResponse {
+	return DMARCTallyResponse{
+		Messages:        tally.Messages,
+		SPFPass:         tally.SPFPass,
+		SPFPassRate:     tally.Rate(tally.SPFPass),
+		SPFAligned:      tally.SPFAligned,
+		SPFAlignedRate:  tally.Rate(tally.SPFAligned),
+		DKIMPass:        tally.DKIMPass,
+		DKIMPassRate:    tally.Rate(tally.DKIMPass),
+		DKIMAligned:     tally.DKIMAligned,
+		DKIMAlignedRate: tally.Rate(tally.DKIMAligned),
+		DMARCPass:       tally.DMARCPass,
+		DMARCPassRate:   tally.Rate(tally.DMARCPass),
+		Dispositions:    tally.Dispositions,
+	}
+}
+
+// fromDMARCSources converts report sources to API responses
+func fromDMARCSources(sources []*emailauth.DMARCSourceStats) []DMARCSourceResponse {
+	response := make([]DMARCSourceResponse, 0, len(sources))
+	for _, source := range sources {
+		item := DMARCSourceResponse{
+			SourceIP:           source.SourceIP,
+			DMARCTallyResponse: fromDMARCTally(&source.DMARCTally),
+			HeaderFromDomains:  source.HeaderFromDomains,
+			Reporters:          source.Reporters,
+			Known:              source.Known,
+			KnownBy:            source.KnownBy,
+		}
+		if origin := source.Origin; origin != nil {
+			item.PTR, item.PTRDomain = origin.PTR, origin.PTRDomain
+			item.ASN, item.ASName, item.Country = origin.ASN, origin.ASName, origin.Country
+		}
+		response = append(response, item)
+	}
+	return response
+}
+
+// HeaderAnalyzeRequest represents the analysis of the headers of a message
+type HeaderAnalyzeRequest struct {
+	Headers string `json:"headers"` // Header section of a message, or a complete message
+}
+
+// ReceivedHopResponse represents one Received header, in transit order
+type ReceivedHopResponse struct {
+	Hop          int        `json:"hop"` // 1 for the first relay after the sender
+	From         string     `json:"from,omitempty"`
+	FromHost     string     `json:"fromHost,omitempty"`
+	FromIP       string     `json:"fromIp,omitempty"`
+	By           string     `json:"by,omitempty"`
+	With         string     `json:"with,omitempty"`
+	ID           string     `json:"id,omitempty"`
+	For          string     `json:"for,omitempty"`
+	Timestamp    *time.Time `json:"timestamp,omitempty"`
+	DelaySeconds *float64   `json:"delaySeconds,omitempty"` // Since the previous hop, or the Date header for the first
+	ClockSkew    bool       `json:"clockSkew"`
+	TLS          bool       `json:"tls"`
+	TLSDetails   string     `json:"tlsDetails,omitempty"`
+	Raw          string     `json:"raw"`
+}
+
+// ARCSetHeadersResponse represents the headers of one ARC set
+type ARCSetHeadersResponse struct {
+	Instance              int                  `json:"instance"`
+	SealDomain            string               `json:"sealDomain,omitempty"`
+	SealSelector          string               `json:"sealSelector,omitempty"`
+	ChainValidation       string               `json:"chainValidation,omitempty"` // cv= tag
+	SignatureDomain       string               `json:"signatureDomain,omitempty"`
+	SignatureSelector     string               `json:"signatureSelector,omitempty"`
+	AuthenticationResults *AuthResultsResponse `json:"authenticationResults,omitempty"`
+}
+
+// ListHeadersResponse represents the mailing list headers of a message
+type ListHeadersResponse struct {
+	ID              string   `json:"id,omitempty"`
+	Unsubscribe     []string `json:"unsubscribe,omitempty"`
+	UnsubscribePost string   `json:"unsubscribePost,omitempty"`
+	Subscribe       []string `json:"subscribe,omitempty"`
+	Post            []string `json:"post,omitempty"`
+	Help            []string `json:"help,omitempty"`
+	Owner           []string `json:"owner,omitempty"`
+	Archive         []string `json:"archive,omitempty"`
+}
+
+// HeaderFieldResponse represents one unfolded header field
+type HeaderFieldResponse struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// HeaderAnalysisResponse represents the analysis of the headers of a message
+type HeaderAnalysisResponse struct {
+	From                  string                  `json:"from,omitempty"`
+	FromDomain            string                  `json:"fromDomain,omitempty"`
+	To                    string                  `json:"to,omitempty"`
+	Subject               string                  `json:"subject,omitempty"`
+	Date                  string                  `json:"date,omitempty"`
+	MessageID             string                  `json:"messageId,omitempty"`
+	ReturnPath            string                  `json:"returnPath,omitempty"`
+	ReturnPathDomain      string                  `json:"returnPathDomain,omitempty"`
+	ReplyTo               string                  `json:"replyTo,omitempty"`
+	Hops                  []ReceivedHopResponse   `json:"hops"`
+	TotalDelaySeconds     *float64                `json:"totalDelaySeconds,omitempty"`
+	AuthenticationResults []AuthResultsResponse   `json:"authenticationResults,omitempty"`
+	ARC                   []ARCSetHeadersResponse `json:"arc,omitempty"`
+	DKIMDomains           []string                `json:"dkimDomains,omitempty"`
+	List                  *ListHeadersResponse    `json:"list,omitempty"`
+	Headers               []HeaderFieldResponse   `json:"headers"`
+	Findings              []string                `json:"findings,omitempty"`
+	Summary               string                  `json:"summary,omitempty"` // Human-readable report
+}
+
+// FromHeaderAnalysis converts a domain header analysis to an API response
+func FromHeaderAnalysis(result *emailauth.HeaderAnalysis, summary string) *HeaderAnalysisResponse {
+	if result == nil {
+		return &HeaderAnalysisResponse{}
+	}
+
+	response := &HeaderAnalysisResponse{
+		From:                  result.From,
+		FromDomain:            result.FromDomain,
+		To:                    result.To,
+		Subject:               result.Subject,
+		Date:                  result.Date,
+		MessageID:             result.MessageID,
+		ReturnPath:            result.ReturnPath,
+		ReturnPathDomain:      result.ReturnPathDomain,
+		ReplyTo:               result.ReplyTo,
+		Hops:                  make([]ReceivedHopResponse, 0, len(result.Hops)),
+		TotalDelaySeconds:     result.TotalDelaySeconds,
+		AuthenticationResults: fromAuthResults(result.AuthenticationResults),
+		DKIMDomains:           result.DKIMDomains,
+		Headers:               make([]HeaderFieldResponse, 0, len(result.Headers)),
+		Findings:              result.Findings,
+		Summary:               summary,
+	}
+	for _, hop := range result.Hops {
+		response.Hops = append(response.Hops, ReceivedHopResponse{
+			Hop:          hop.Hop,
+			From:         hop.From,
+			FromHost:     hop.FromHost,
+			FromIP:       hop.FromIP,
+			By:           hop.By,
+			With:         hop.With,
+			ID:           hop.ID,
+			For:          hop.For,
+			Timestamp:    hop.Timestamp,
+			DelaySeconds: hop.DelaySeconds,
+			ClockSkew:    hop.ClockSkew,
+			TLS:          hop.TLS,
+			TLSDetails:   hop.TLSDetails,
+			Raw:          hop.Raw,
+		})
+	}
+	for _, set := range result.ARC {
+		item := ARCSetHeadersResponse{
+			Instance:          set.Instance,
+			SealDomain:        set.SealDomain,
+			SealSelector:      set.SealSelector,
+			ChainValidation:   set.ChainValidation,
+			SignatureDomain:   set.SignatureDomain,
+			SignatureSelector: set.SignatureSelector,
+		}
+		if set.AuthenticationResults != nil {
+			item.AuthenticationResults = &fromAuthResults([]emailauth.AuthenticationResults{*set.AuthenticationResults})[0]
+		}
+		response.ARC = append(response.ARC, item)
+	}
+	if list := result.List; list != nil {
+		response.List = &ListHeadersResponse{
+			ID:              list.ID,
+			Unsubscribe:     list.Unsubscribe,
+			UnsubscribePost: list.UnsubscribePost,
+			Subscribe:       list.Subscribe,
+			Post:            list.Post,
+			Help:            list.Help,
+			Owner:           list.Owner,
+			Archive:         list.Archive,
+		}
+	}
+	for _, field := range result.Headers {
+		response.Headers = append(response.Headers, HeaderFieldResponse{Name: field.Name, Value: field.Value})
+	}
+	return response
+}
+
+// DMARCForensicRequest represents a request to analyze DMARC failure (ruf) reports
+type DMARCForensicRequest struct {
+	Files []DMARCReportUploadFile `json:"files"` // Base64 ARF messages
+}
+
+// AuthResultsResponse represents an Authentication-Results header
+type AuthResultsResponse struct {
+	AuthServID string               `json:"authServId"`
+	Results    []AuthResultResponse `json:"results"`
+}
+
+// AuthResultResponse represents one method result of an Authentication-Results header
+type AuthResultResponse struct {
+	Method     string            `json:"method"`
+	Result     string            `json:"result"`
+	Reason     string            `json:"reason,omitempty"`
+	Properties map[string]string `json:"properties,omitempty"`
+}
+
+// DMARCForensicOriginalResponse represents the message a failure report was generated for
+type DMARCForensicOriginalResponse struct {
+	HeadersOnly           bool                  `json:"headersOnly"`
+	From                  string                `json:"from,omitempty"`
+	FromDomain            string                `json:"fromDomain,omitempty"`
+	ReturnPath            string                `json:"returnPath,omitempty"`
+	To                    string                `json:"to,omitempty"`
+	Subject               string                `json:"subject,omitempty"`
+	Date                  string                `json:"date,omitempty"`
+	MessageID             string                `json:"messageId,omitempty"`
+	DKIMDomains           []string              `json:"dkimDomains,omitempty"`
+	AuthenticationResults []AuthResultsResponse `json:"authenticationResults,omitempty"`
+	ReceivedHops          int                   `json:"receivedHops"`
+	HeaderFindings        []string              `json:"headerFindings,omitempty"`
+}
+
+// DMARCForensicReportResponse represents one ARF feedback report
+type DMARCForensicReportResponse struct {
+	ReportFrom            string                         `json:"reportFrom,omitempty"`
+	ReportSubject         string                         `json:"reportSubject,omitempty"`
+	ReportDate            string                         `json:"reportDate,omitempty"`
+	FeedbackType          string                         `json:"feedbackType"`
+	UserAgent             string                         `json:"userAgent,omitempty"`
+	Version               string                         `json:"version,omitempty"`
+	AuthFailure           string                         `json:"authFailure,omitempty"` // dmarc, dkim, spf, ...
+	IdentityAlignment     string                         `json:"identityAlignment,omitempty"`
+	SourceIP              string                         `json:"sourceIp,omitempty"`
+	ReportingMTA          string                         `json:"reportingMta,omitempty"`
+	ArrivalDate           *time.Time                     `json:"arrivalDate,omitempty"`
+	OriginalMailFrom      string                         `json:"originalMailFrom,omitempty"`
+	OriginalRcptTo        []string                       `json:"originalRcptTo,omitempty"`
+	ReportedDomain        []string                       `json:"reportedDomain,omitempty"`
+	DeliveryResult        string                         `json:"deliveryResult,omitempty"`
+	Incidents             int                            `json:"incidents"`
+	DKIMDomain            string                         `json:"dkimDomain,omitempty"`
+	DKIMSelector          string                         `json:"dkimSelector,omitempty"`
+	DKIMIdentity          string                         `json:"dkimIdentity,omitempty"`
+	SPFDNS                string                         `json:"spfDns,omitempty"`
+	AuthenticationResults []AuthResultsResponse          `json:"authenticationResults,omitempty"`
+	Fields                map[string][]string            `json:"fields,omitempty"` // Every feedback field by lowercase name
+	Original              *DMARCForensicOriginalResponse `json:"original,omitempty"`
+	Warnings              []string                       `json:"warnings,omitempty"`
+}
+
+// DMARCForensicFileResponse represents the parsing of one failure report file
+type DMARCForensicFileResponse struct {
+	Name   string                       `json:"name"`
+	Report *DMARCForensicReportResponse `json:"report,omitempty"`
+	Error  string                       `json:"error,omitempty"`
+}
+
+// DMARCForensicCampaignResponse represents the failure reports of one From domain and subject
+type DMARCForensicCampaignResponse struct {
+	FromDomain        string         `json:"fromDomain"`
+	SubjectPattern    string         `json:"subjectPattern"` // Lowercase, numbers masked as #
+	Subject           string         `json:"subject,omitempty"`
+	Reports           int            `json:"reports"`
+	Incidents         int            `json:"incidents"`
+	SourceIPs         []string       `json:"sourceIps"`
+	Reporters         []string       `json:"reporters"`
+	ReturnPathDomains []string       `json:"returnPathDomains,omitempty"`
+	DKIMDomains       []string       `json:"dkimDomains,omitempty"`
+	AuthFailures      map[string]int `json:"authFailures,omitempty"`
+	DeliveryResults   map[string]int `json:"deliveryResults,omitempty"`
+	FirstSeen         *time.Time     `json:"firstSeen,omitempty"`
+	LastSeen          *time.Time     `json:"lastSeen,omitempty"`
+	Assessment        string         `json:"assessment"`
+}
+
+// DMARCForensicResponse represents a set of failure reports grouped by campaign
+type DMARCForensicResponse struct {
+	Files     []DMARCForensicFileResponse     `json:"files"`
+	Reports   int                             `json:"reports"`
+	Incidents int                             `json:"incidents"`
+	Failed    int                             `json:"failed"`
+	SourceIPs int                             `json:"sourceIps"` // Distinct failing sources
+	Campaigns []DMARCForensicCampaignResponse `json:"campaigns"`
+	Findings  []string                        `json:"findings,omitempty"`
+	Summary   string                          `json:"summary,omitempty"` // Human-readable report
+}
+
+// FromForensicReports converts a domain failure report analysis to an API response
+func FromForensicReports(result *emailauth.ForensicReportAnalysis, summary string) *DMARCForensicResponse {
+	if result == nil {
+		return &DMARCForensicResponse{}
+	}
+
+	response := &DMARCForensicResponse{
+		Files:     make([]DMARCForensicFileResponse, 0, len(result.Files)),
+		Reports:   result.Reports,
+		Incidents: result.Incidents,
+		Failed:    result.Failed,
+		SourceIPs: result.SourceIPs,
+		Campaigns: make([]DMARCForensicCampaignResponse, 0, len(result.Campaigns)),
+		Findings:  result.Findings,
+		Summary:   summary,
+	}
+	for _, file := range result.Files {
+		response.Files = append(response.Files, DMARCForensicFileResponse{
+			Name:   file.Name,
+			Report: fromForensicReport(file.Report),
+			Error:  file.Error,
+		})
+	}
+	for _, campaign := range result.Campaigns {
+		item := DMARCForensicCampaignResponse{
+			FromDomain:        campaign.FromDomain,
+			SubjectPattern:    campaign.SubjectPattern,
+			Subject:           campaign.Subject,
+			Reports:           campaign.Reports,
+			Incidents:         campaign.Incidents,
+			SourceIPs:         campaign.SourceIPs,
+			Reporters:         campaign.Reporters,
+			ReturnPathDomains: campaign.ReturnPathDomains,
+			DKIMDomains:       campaign.DKIMDomains,
+			AuthFailures:      campaign.AuthFailures,
+			DeliveryResults:   campaign.DeliveryResults,
+			Assessment:        campaign.Assessment,
+		}
+		if !campaign.FirstSeen.IsZero() {
+			firstSeen, lastSeen := campaign.FirstSeen, campaign.LastSeen
+			item.FirstSeen, item.LastSeen = &firstSeen, &lastSeen
+		}
+		response.Campaigns = append(response.Campaigns, item)
+	}
+	return response
+}
+
+// fromForensicReport converts one ARF feedback report to an API response
+func fromForensicReport(report *emailauth.ForensicReport) *DMARCForensicReportResponse {
+	if report == nil {
+		return nil
+	}
+
+	response := &DMARCForensicReportResponse{
+		ReportFrom:            report.ReportFrom,
+		ReportSubject:         report.ReportSubject,
+		ReportDate:            report.ReportDate,
+		FeedbackType:          report.FeedbackType,
+		UserAgent:             report.UserAgent,
+		Version:               report.Version,
+		AuthFailure:           report.AuthFailure,
+		IdentityAlignment:     report.IdentityAlignment,
+		SourceIP:              report.SourceIP,
+		ReportingMTA:          report.ReportingMTA,
+		ArrivalDate:           report.ArrivalDate,
+		OriginalMailFrom:      report.OriginalMailFrom,
+		OriginalRcptTo:        report.OriginalRcptTo,
+		ReportedDomain:        report.ReportedDomain,
+		DeliveryResult:        report.DeliveryResult,
+		Incidents:             report.Incidents,
+		DKIMDomain:            report.DKIMDomain,
+		DKIMSelector:          report.DKIMSelector,
+		DKIMIdentity:          report.DKIMIdentity,
+		SPFDNS:                report.SPFDNS,
+		AuthenticationResults: fromAuthResults(report.AuthenticationResults),
+		Fields:                report.Fields,
+		Warnings:              report.Warnings,
+	}
+	if original := report.Original; original != nil {
+		response.Original = &DMARCForensicOriginalResponse{
+			HeadersOnly:           original.HeadersOnly,
+			From:                  original.From,
+			FromDomain:            original.FromDomain,
+			ReturnPath:            original.ReturnPath,
+			To:                    original.To,
+			Subject:               original.Subject,
+			Date:                  original.Date,
+			MessageID:             original.MessageID,
+			DKIMDomains:           original.DKIMDomains,
+			AuthenticationResults: fromAuthResults(original.AuthenticationResults),
+			ReceivedHops:          original.ReceivedHops,
+			HeaderFindings:        original.HeaderFindings,
+		}
 	}
+	return response
+}
+
+// fromAuthResults converts Authentication-Results headers to API responses
+func fromAuthResults(headers []emailauth.AuthenticationResults) []AuthResultsResponse {
+	var response []AuthResultsResponse
+	for _, header := range headers {
+		item := AuthResultsResponse{AuthServID: header.AuthServID, Results: make([]AuthResultResponse, 0, len(header.Results))}
+		for _, result := range header.Results {
+			item.Results = append(item.Results, AuthResultResponse{
+				Method:     result.Method,
+				Result:     result.Result,
+				Reason:     result.Reason,
+				Properties: result.Properties,
+			})
+		}
+		response = append(response, item)
+	}
+	return response
 }
 
 // NetworkToolResponse wraps the domain network tool result for API responses
@@ -516,3 +2124,516 @@ func FromNetworkToolResult(result *networktools.NetworkToolResult) *NetworkToolR
 
 	return response
 }
+
+// SMTPSmugglingTestRequest represents a request to probe a mail exchanger for SMTP smuggling
+type SMTPSmugglingTestRequest struct {
+	Domain      string `json:"domain"`                // Probe messages go to postmaster@domain only
+	Host        string `json:"host,omitempty"`        // An MX of the domain, default to the most preferred one
+	Port        int    `json:"port,omitempty"`        // Default to 25 if not specified
+	FromAddress string `json:"fromAddress,omitempty"` // Default to the null reverse-path
+	Confirm     bool   `json:"confirm"`               // Must be true: a vulnerable server delivers probe messages
+	Timeout     int    `json:"timeout,omitempty"`     // In seconds, default to 10
+	Transcript  bool   `json:"transcript,omitempty"`  // Include the session transcripts
+}
+
+// SMTPSmugglingSequenceResponse represents how a server handled one end-of-data sequence
+type SMTPSmugglingSequenceResponse struct {
+	Name       string `json:"name"`
+	Outcome    string `json:"outcome"` // end-of-data, rejected, ignored or error
+	Code       int    `json:"code,omitempty"`
+	Response   string `json:"response,omitempty"`
+	TrackingID string `json:"trackingId,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// SMTPSmugglingTestResponse represents the result of an SMTP smuggling probe
+type SMTPSmugglingTestResponse struct {
+	Domain                string                          `json:"domain"`
+	Host                  string                          `json:"host"`
+	Port                  int                             `json:"port"`
+	Recipient             string                          `json:"recipient,omitempty"`
+	BareLFCommand         string                          `json:"bareLFCommand,omitempty"`
+	BareLFCommandCode     int                             `json:"bareLFCommandCode,omitempty"`
+	BareLFCommandResponse string                          `json:"bareLFCommandResponse,omitempty"`
+	Sequences             []SMTPSmugglingSequenceResponse `json:"sequences"`
+	Vulnerable            bool                            `json:"vulnerable"`
+	Findings              []string                        `json:"findings,omitempty"`
+	Transcript            []SMTPTranscriptEntryResponse   `json:"transcript,omitempty"`
+	Error                 string                          `json:"error,omitempty"`
+}
+
+// FromSMTPSmugglingTestResult converts a domain smuggling probe result to an API response
+func FromSMTPSmugglingTestResult(result *smtp.SmugglingTestResult) *SMTPSmugglingTestResponse {
+	if result == nil {
+		return &SMTPSmugglingTestResponse{
+			Error: "no result available",
+		}
+	}
+
+	response := &SMTPSmugglingTestResponse{
+		Domain:                result.Domain,
+		Host:                  result.Host,
+		Port:                  result.Port,
+		Recipient:             result.Recipient,
+		BareLFCommand:         result.BareLFCommand,
+		BareLFCommandCode:     result.BareLFCommandCode,
+		BareLFCommandResponse: result.BareLFCommandResponse,
+		Sequences:             make([]SMTPSmugglingSequenceResponse, 0, len(result.Sequences)),
+		Vulnerable:            result.Vulnerable,
+		Findings:              result.Findings,
+		Transcript:            FromSMTPTranscript(result.Transcript),
+		Error:                 result.Error,
+	}
+	for _, seq := range result.Sequences {
+		response.Sequences = append(response.Sequences, SMTPSmugglingSequenceResponse{
+			Name:       seq.Name,
+			Outcome:    seq.Outcome,
+			Code:       seq.Code,
+			Response:   seq.Response,
+			TrackingID: seq.TrackingID,
+			Error:      seq.Error,
+		})
+	}
+
+	return response
+}
+
+// SMTPSinkStartRequest represents a request to start the SMTP sink
+// The sink listens on the sink_address setting, and a STARTTLS certificate is only read from the
+// sink_cert_file and sink_key_file settings
+type SMTPSinkStartRequest struct {
+	Address  string `json:"address,omitempty"`  // Rejected: the listen address is only set in the configuration
+	Domain   string `json:"domain,omitempty"`   // Domain of the test addresses, default to the hostname
+	Hostname string `json:"hostname,omitempty"` // Name announced in the greeting, default to the system hostname
+	Timeout  int    `json:"timeout,omitempty"`  // Per-command timeout in seconds, default to 300
+}
+
+// SMTPSinkStatusResponse represents the state of the SMTP sink
+type SMTPSinkStatusResponse struct {
+	Running    bool   `json:"running"`
+	Address    string `json:"address,omitempty"`
+	Domain     string `json:"domain,omitempty"`
+	SelfSigned bool   `json:"selfSigned,omitempty"`
+}
+
+// FromSMTPSinkStatus converts a domain sink status to an API response
+func FromSMTPSinkStatus(status *smtp.SinkStatus) *SMTPSinkStatusResponse {
+	if status == nil {
+		return &SMTPSinkStatusResponse{}
+	}
+	return &SMTPSinkStatusResponse{
+		Running:    status.Running,
+		Address:    status.Address,
+		Domain:     status.Domain,
+		SelfSigned: status.SelfSigned,
+	}
+}
+
+// SMTPSinkSPFResponse represents the SPF result of a received message
+type SMTPSinkSPFResponse struct {
+	Domain      string `json:"domain"`
+	IP          string `json:"ip"`
+	Result      string `json:"result"`
+	Record      string `json:"record,omitempty"`
+	Mechanism   string `json:"mechanism,omitempty"`
+	Lookups     int    `json:"lookups"`
+	Explanation string `json:"explanation,omitempty"`
+}
+
+// SMTPSinkDKIMResponse represents the verification of one DKIM signature of a received message
+type SMTPSinkDKIMResponse struct {
+	Domain           string   `json:"domain"`
+	Selector         string   `json:"selector"`
+	Algorithm        string   `json:"algorithm,omitempty"`
+	Canonicalization string   `json:"canonicalization,omitempty"`
+	SignedHeaders    []string `json:"signedHeaders,omitempty"`
+	Result           string   `json:"result"`
+	Reason           string   `json:"reason,omitempty"`
+}
+
+// SMTPSinkDMARCResponse represents DMARC alignment of a received message
+type SMTPSinkDMARCResponse struct {
+	FromDomain   string                      `json:"fromDomain,omitempty"`
+	PolicyDomain string                      `json:"policyDomain,omitempty"`
+	Record       string                      `json:"record,omitempty"`
+	Policy       string                      `json:"policy,omitempty"`
+	SPFAligned   bool                        `json:"spfAligned"`
+	DKIMAligned  bool                        `json:"dkimAligned"`
+	Result       string                      `json:"result"`
+	Disposition  string                      `json:"disposition,omitempty"`
+	Lookalikes   []SMTPSinkLookalikeResponse `json:"lookalikes,omitempty"`
+	Error        string                      `json:"error,omitempty"`
+}
+
+// SMTPSinkLookalikeResponse represents an SPF or DKIM domain that imitates the From domain
+type SMTPSinkLookalikeResponse struct {
+	Domain               string `json:"domain"`
+	Source               string `json:"source"`
+	OrganizationalDomain string `json:"organizationalDomain"`
+	ImitatedDomain       string `json:"imitatedDomain"`
+	Distance             int    `json:"distance"`
+	Reason               string `json:"reason"`
+}
+
+// SMTPSinkHeadersResponse represents the header checks of a received message
+type SMTPSinkHeadersResponse struct {
+	From         string   `json:"from,omitempty"`
+	To           string   `json:"to,omitempty"`
+	Subject      string   `json:"subject,omitempty"`
+	Date         string   `json:"date,omitempty"`
+	MessageID    string   `json:"messageId,omitempty"`
+	ReceivedHops int      `json:"receivedHops"`
+	Findings     []string `json:"findings,omitempty"`
+}
+
+// SMTPSinkMessageResponse represents a message received by the sink and its authentication verdict
+type SMTPSinkMessageResponse struct {
+	ReceivedAt time.Time                `json:"receivedAt"`
+	ClientIP   string                   `json:"clientIp"`
+	Helo       string                   `json:"helo"`
+	MailFrom   string                   `json:"mailFrom"`
+	Recipients []string                 `json:"recipients"`
+	TLS        bool                     `json:"tls"`
+	TLSVersion string                   `json:"tlsVersion,omitempty"`
+	Size       int                      `json:"size"`
+	SPF        *SMTPSinkSPFResponse     `json:"spf,omitempty"`
+	DKIM       []SMTPSinkDKIMResponse   `json:"dkim"`
+	DMARC      *SMTPSinkDMARCResponse   `json:"dmarc,omitempty"`
+	Headers    *SMTPSinkHeadersResponse `json:"headers,omitempty"`
+	Verdict    string                   `json:"verdict"`
+	Reasons    []string                 `json:"reasons,omitempty"`
+	Error      string                   `json:"error,omitempty"`
+}
+
+// SMTPSinkTestResponse represents a sink test address and the messages it received
+type SMTPSinkTestResponse struct {
+	ID       string                    `json:"id"`
+	Address  string                    `json:"address"`
+	Messages []SMTPSinkMessageResponse `json:"messages"`
+}
+
+// FromSMTPSinkTest converts a domain sink test to an API response
+func FromSMTPSinkTest(test *smtp.SinkTest) *SMTPSinkTestResponse {
+	if test == nil {
+		return &SMTPSinkTestResponse{Messages: []SMTPSinkMessageResponse{}}
+	}
+
+	response := &SMTPSinkTestResponse{
+		ID:       test.ID,
+		Address:  test.Address,
+		Messages: make([]SMTPSinkMessageResponse, 0, len(test.Messages)),
+	}
+	for _, m := range test.Messages {
+		message := SMTPSinkMessageResponse{
+			ReceivedAt: m.ReceivedAt,
+			ClientIP:   m.ClientIP,
+			Helo:       m.Helo,
+			MailFrom:   m.MailFrom,
+			Recipients: m.Recipients,
+			TLS:        m.TLS,
+			TLSVersion: m.TLSVersion,
+			Size:       m.Size,
+			DKIM:       make([]SMTPSinkDKIMResponse, 0, len(m.DKIM)),
+			Verdict:    m.Verdict,
+			Reasons:    m.Reasons,
+			Error:      m.Error,
+		}
+		if m.SPF != nil {
+			message.SPF = &SMTPSinkSPFResponse{
+				Domain:      m.SPF.Domain,
+				IP:          m.SPF.IP,
+				Result:      m.SPF.Result,
+				Record:      m.SPF.Record,
+				Mechanism:   m.SPF.Mechanism,
+				Lookups:     m.SPF.Lookups,
+				Explanation: m.SPF.Explanation,
+			}
+		}
+		for _, sig := range m.DKIM {
+			message.DKIM = append(message.DKIM, SMTPSinkDKIMResponse{
+				Domain:           sig.Domain,
+				Selector:         sig.Selector,
+				Algorithm:        sig.Algorithm,
+				Canonicalization: sig.Canonicalization,
+				SignedHeaders:    sig.SignedHeaders,
+				Result:           sig.Result,
+				Reason:           sig.Reason,
+			})
+		}
+		if m.DMARC != nil {
+			message.DMARC = &SMTPSinkDMARCResponse{
+				FromDomain:   m.DMARC.FromDomain,
+				PolicyDomain: m.DMARC.PolicyDomain,
+				Record:       m.DMARC.Record,
+				Policy:       m.DMARC.Policy,
+				SPFAligned:   m.DMARC.SPFAligned,
+				DKIMAligned:  m.DMARC.DKIMAligned,
+				Result:       m.DMARC.Result,
+				Disposition:  m.DMARC.Disposition,
+				Error:        m.DMARC.Error,
+			}
+			for _, lookalike := range m.DMARC.Lookalikes {
+				message.DMARC.Lookalikes = append(message.DMARC.Lookalikes, SMTPSinkLookalikeResponse{
+					Domain:               lookalike.Domain,
+					Source:               lookalike.Source,
+					OrganizationalDomain: lookalike.OrganizationalDomain,
+					ImitatedDomain:       lookalike.ImitatedDomain,
+					Distance:             lookalike.Distance,
+					Reason:               lookalike.Reason,
+				})
+			}
+		}
+		if m.Headers != nil {
+			message.Headers = &SMTPSinkHeadersResponse{
+				From:         m.Headers.From,
+				To:           m.Headers.To,
+				Subject:      m.Headers.Subject,
+				Date:         m.Headers.Date,
+				MessageID:    m.Headers.MessageID,
+				ReceivedHops: m.Headers.ReceivedHops,
+				Findings:     m.Headers.Findings,
+			}
+		}
+		response.Messages = append(response.Messages, message)
+	}
+
+	return response
+}
+
+// EmailVerifyRequest represents a request to verify an email address
+type EmailVerifyRequest struct {
+	Address    string `json:"address"`
+	Timeout    int    `json:"timeout,omitempty"`    // In seconds, default to 10
+	Transcript bool   `json:"transcript,omitempty"` // Include the session transcript
+}
+
+// EmailVerifyBulkRequest represents a request to verify several email addresses
+type EmailVerifyBulkRequest struct {
+	Addresses  []string `json:"addresses"`
+	Timeout    int      `json:"timeout,omitempty"`    // In seconds per address, default to 10
+	Transcript bool     `json:"transcript,omitempty"` // Include the session transcript of each address
+}
+
+// EmailVerifyResponse represents the result of an email address verification
+type EmailVerifyResponse struct {
+	Address           string                        `json:"address"`
+	NormalizedAddress string                        `json:"normalizedAddress,omitempty"`
+	Verdict           string                        `json:"verdict"` // deliverable, undeliverable, risky or unknown
+	Reasons           []string                      `json:"reasons,omitempty"`
+	ValidSyntax       bool                          `json:"validSyntax"`
+	SyntaxError       string                        `json:"syntaxError,omitempty"`
+	RoleAccount       bool                          `json:"roleAccount"`
+	Disposable        bool                          `json:"disposable"`
+	MXHosts           []SMTPMXHostResponse          `json:"mxHosts,omitempty"`
+	ImplicitMX        bool                          `json:"implicitMX"`
+	MailServer        string                        `json:"mailServer,omitempty"`
+	RcptCode          int                           `json:"rcptCode,omitempty"`
+	EnhancedCode      string                        `json:"enhancedCode,omitempty"`
+	RcptResponse      string                        `json:"rcptResponse,omitempty"`
+	Explanation       *SMTPReplyExplanationResponse `json:"explanation,omitempty"`
+	Accepted          bool                          `json:"accepted"`
+	CatchAllTested    bool                          `json:"catchAllTested"`
+	CatchAll          bool                          `json:"catchAll"`
+	Transcript        []SMTPTranscriptEntryResponse `json:"transcript,omitempty"`
+	Error             string                        `json:"error,omitempty"`
+}
+
+// EmailVerifyBulkResponse represents the result of verifying several email addresses
+type EmailVerifyBulkResponse struct {
+	Results []*EmailVerifyResponse `json:"results"`
+	Counts  map[string]int         `json:"counts"`
+}
+
+// FromEmailVerificationResult converts a domain email verification result to an API response
+func FromEmailVerificationResult(result *smtp.EmailVerificationResult) *EmailVerifyResponse {
+	if result == nil {
+		return &EmailVerifyResponse{
+			Error: "no result available",
+		}
+	}
+
+	response := &EmailVerifyResponse{
+		Address:           result.Address,
+		NormalizedAddress: result.NormalizedAddress,
+		Verdict:           result.Verdict,
+		Reasons:           result.Reasons,
+		ValidSyntax:       result.ValidSyntax,
+		SyntaxError:       result.SyntaxError,
+		RoleAccount:       result.RoleAccount,
+		Disposable:        result.Disposable,
+		ImplicitMX:        result.ImplicitMX,
+		MailServer:        result.MailServer,
+		RcptCode:          result.RcptCode,
+		EnhancedCode:      result.EnhancedCode,
+		RcptResponse:      result.RcptResponse,
+		Explanation:       FromSMTPReplyExplanation(result.Explanation),
+		Accepted:          result.Accepted,
+		CatchAllTested:    result.CatchAllTested,
+		CatchAll:          result.CatchAll,
+		Transcript:        FromSMTPTranscript(result.Transcript),
+		Error:             result.Error,
+	}
+	for _, mx := range result.MXHosts {
+		response.MXHosts = append(response.MXHosts, SMTPMXHostResponse{
+			Host:       mx.Host,
+			Preference: mx.Preference,
+		})
+	}
+
+	return response
+}
+
+// FromBulkEmailVerificationResult converts a domain bulk email verification result to an API response
+func FromBulkEmailVerificationResult(result *smtp.BulkEmailVerificationResult) *EmailVerifyBulkResponse {
+	response := &EmailVerifyBulkResponse{
+		Results: []*EmailVerifyResponse{},
+		Counts:  map[string]int{},
+	}
+	if result == nil {
+		return response
+	}
+
+	for _, r := range result.Results {
+		response.Results = append(response.Results, FromEmailVerificationResult(r))
+	}
+	for verdict, count := range result.Counts {
+		response.Counts[verdict] = count
+	}
+
+	return response
+}
+
+// MailAccessCheckRequest represents an IMAP or POP3 check with an optional login test
+type MailAccessCheckRequest struct {
+	Host       string `json:"host"`
+	Port       int    `json:"port,omitempty"`       // Default to 143 (IMAP) or 110 (POP3); 993 and 995 use implicit TLS
+	Username   string `json:"username,omitempty"`   // Login is tested when username and password are set
+	Password   string `json:"password,omitempty"`   // Only sent over TLS
+	Timeout    int    `json:"timeout,omitempty"`    // In seconds, default to 10
+	Transcript bool   `json:"transcript,omitempty"` // Include the session transcript
+}
+
+// MailAccessCertificateResponse represents the certificate presented by an IMAP or POP3 server
+type MailAccessCertificateResponse struct {
+	Subject            string    `json:"subject"`
+	Issuer             string    `json:"issuer"`
+	DNSNames           []string  `json:"dnsNames,omitempty"`
+	SerialNumber       string    `json:"serialNumber"`
+	NotBefore          time.Time `json:"notBefore"`
+	NotAfter           time.Time `json:"notAfter"`
+	SignatureAlgorithm string    `json:"signatureAlgorithm"`
+	PublicKeyAlgorithm string    `json:"publicKeyAlgorithm"`
+	PublicKeyBits      int       `json:"publicKeyBits,omitempty"`
+	SelfSigned         bool      `json:"selfSigned"`
+	HostnameMatch      bool      `json:"hostnameMatch"`
+	Trusted            bool      `json:"trusted"`
+	VerifyError        string    `json:"verifyError,omitempty"`
+	ChainLength        int       `json:"chainLength"`
+}
+
+// MailAccessTranscriptEntryResponse represents a single event of an IMAP or POP3 session
+type MailAccessTranscriptEntryResponse struct {
+	Timestamp time.Time `json:"timestamp"`
+	Elapsed   string    `json:"elapsed"`
+	Direction string    `json:"direction"`
+	Line      string    `json:"line"`
+	Duration  string    `json:"duration,omitempty"`
+}
+
+// MailAccessCheckResponse represents the result of an IMAP or POP3 check
+type MailAccessCheckResponse struct {
+	Protocol        string                              `json:"protocol"`
+	Host            string                              `json:"host"`
+	Port            int                                 `json:"port"`
+	ImplicitTLS     bool                                `json:"implicitTls"`
+	Connected       bool                                `json:"connected"`
+	Latency         string                              `json:"latency,omitempty"`
+	Banner          string                              `json:"banner,omitempty"`
+	Capabilities    []string                            `json:"capabilities,omitempty"`
+	TLSCapabilities []string                            `json:"tlsCapabilities,omitempty"`
+	STARTTLS        bool                                `json:"starttls"`
+	STARTTLSError   string                              `json:"starttlsError,omitempty"`
+	TLS             bool                                `json:"tls"`
+	TLSVersion      string                              `json:"tlsVersion,omitempty"`
+	TLSCipher       string                              `json:"tlsCipher,omitempty"`
+	Certificate     *MailAccessCertificateResponse      `json:"certificate,omitempty"`
+	PlaintextAuth   []string                            `json:"plaintextAuth,omitempty"`
+	AuthMechanisms  []string                            `json:"authMechanisms,omitempty"`
+	LoginDisabled   bool                                `json:"loginDisabled"`
+	LoginTested     bool                                `json:"loginTested"`
+	LoginSucceeded  bool                                `json:"loginSucceeded"`
+	LoginMethod     string                              `json:"loginMethod,omitempty"`
+	LoginResponse   string                              `json:"loginResponse,omitempty"`
+	Findings        []string                            `json:"findings,omitempty"`
+	Transcript      []MailAccessTranscriptEntryResponse `json:"transcript,omitempty"`
+	Error           string                              `json:"error,omitempty"`
+}
+
+// FromMailAccessCheckResult converts a domain IMAP or POP3 check result to an API response
+func FromMailAccessCheckResult(result *mailaccess.CheckResult) *MailAccessCheckResponse {
+	if result == nil {
+		return &MailAccessCheckResponse{
+			Error: "no result available",
+		}
+	}
+
+	response := &MailAccessCheckResponse{
+		Protocol:        result.Protocol,
+		Host:            result.Host,
+		Port:            result.Port,
+		ImplicitTLS:     result.ImplicitTLS,
+		Connected:       result.Connected,
+		Banner:          result.Banner,
+		Capabilities:    result.Capabilities,
+		TLSCapabilities: result.TLSCapabilities,
+		STARTTLS:        result.STARTTLS,
+		STARTTLSError:   result.STARTTLSError,
+		TLS:             result.TLS,
+		TLSVersion:      result.TLSVersion,
+		TLSCipher:       result.TLSCipher,
+		PlaintextAuth:   result.PlaintextAuth,
+		AuthMechanisms:  result.AuthMechanisms,
+		LoginDisabled:   result.LoginDisabled,
+		LoginTested:     result.LoginTested,
+		LoginSucceeded:  result.LoginSucceeded,
+		LoginMethod:     result.LoginMethod,
+		LoginResponse:   result.LoginResponse,
+		Findings:        result.Findings,
+		Error:           result.Error,
+	}
+	if result.Latency > 0 {
+		response.Latency = result.Latency.String()
+	}
+	if cert := result.Certificate; cert != nil {
+		response.Certificate = &MailAccessCertificateResponse{
+			Subject:            cert.Subject,
+			Issuer:             cert.Issuer,
+			DNSNames:           cert.DNSNames,
+			SerialNumber:       cert.SerialNumber,
+			NotBefore:          cert.NotBefore,
+			NotAfter:           cert.NotAfter,
+			SignatureAlgorithm: cert.SignatureAlgorithm,
+			PublicKeyAlgorithm: cert.PublicKeyAlgorithm,
+			PublicKeyBits:      cert.PublicKeyBits,
+			SelfSigned:         cert.SelfSigned,
+			HostnameMatch:      cert.HostnameMatch,
+			Trusted:            cert.Trusted,
+			VerifyError:        cert.VerifyError,
+			ChainLength:        cert.ChainLength,
+		}
+	}
+	for _, entry := range result.Transcript {
+		transcriptEntry := MailAccessTranscriptEntryResponse{
+			Timestamp: entry.Timestamp,
+			Elapsed:   entry.Elapsed.String(),
+			Direction: entry.Direction,
+			Line:      entry.Line,
+		}
+		if entry.Duration > 0 {
+			transcriptEntry.Duration = entry.Duration.String()
+		}
+		response.Transcript = append(response.Transcript, transcriptEntry)
+	}
+
+	return response
+}
@@ -23,6 +23,7 @@ type Server struct {
 	smtpHandler         *handlers.SMTPHandler
 	emailAuthHandler    *handlers.EmailAuthHandler
 	networkToolsHandler *handlers.NetworkToolsHandler
+	mailAccessHandler   *handlers.MailAccessHandler
 	docsHandler         *handlers.DocsHandler
 	// Add other handlers here
 
@@ -49,6 +50,7 @@ func NewServer(
 	smtpService input.SMTPPort,
 	emailAuthService input.EmailAuthPort,
 	networkToolsService input.NetworkToolsPort,
+	mailAccessService input.MailAccessPort,
 	// Add other services here
 	logger *logging.Logger,
 ) *Server {
@@ -66,6 +68,7 @@ func NewServer(
 		smtpHandler:         handlers.NewSMTPHandler(smtpService),
 		emailAuthHandler:    handlers.NewEmailAuthHandler(emailAuthService),
 		networkToolsHandler: handlers.NewNetworkToolsHandler(networkToolsService),
+		mailAccessHandler:   handlers.NewMailAccessHandler(mailAccessService),
 		docsHandler:         handlers.NewDocsHandler(logger),
 		// Initialize other handlers
 		rateLimiter:      rateLimiter,
@@ -92,6 +95,7 @@ func (s *Server) setupVersionedRoutes() {
 		s.smtpHandler,
 		s.emailAuthHandler,
 		s.networkToolsHandler,
+		s.mailAccessHandler,
 		s.docsHandler,
 		// Other handlers would be added here
 		s.validator,
@@ -217,6 +221,7 @@ func StartAPIServer(
 	smtpService input.SMTPPort,
 	emailAuthService input.EmailAuthPort,
 	networkToolsService input.NetworkToolsPort,
+	mailAccessService input.MailAccessPort,
 	// Other services
 	logger *logging.Logger,
 ) error {
@@ -226,6 +231,7 @@ func StartAPIServer(
 		smtpService,
 		emailAuthService,
 		networkToolsService,
+		mailAccessService,
 		// Other services
 		logger,
 	)
@@ -17,6 +17,7 @@ type Router struct {
 	smtpHandler         *handlers.SMTPHandler
 	emailAuthHandler    *handlers.EmailAuthHandler
 	networkToolsHandler *handlers.NetworkToolsHandler
+	mailAccessHandler   *handlers.MailAccessHandler
 	docsHandler         *handlers.DocsHandler
 	validator           *middleware.Validator
 	jsonValidator       *validation.JSONValidator
@@ -33,6 +34,7 @@ func NewRouter(
 	smtpHandler *handlers.SMTPHandler,
 	emailAuthHandler *handlers.EmailAuthHandler,
 	networkToolsHandler *handlers.NetworkToolsHandler,
+	mailAccessHandler *handlers.MailAccessHandler,
 	docsHandler *handlers.DocsHandler,
 	validator *middleware.Validator,
 	jsonValidator *validation.JSONValidator,
@@ -48,6 +50,7 @@ func NewRouter(
 		smtpHandler:         smtpHandler,
 		emailAuthHandler:    emailAuthHandler,
 		networkToolsHandler: networkToolsHandler,
+		mailAccessHandler:   mailAccessHandler,
 		docsHandler:         docsHandler,
 		validator:           validator,
 		jsonValidator:       jsonValidator,
@@ -120,9 +123,77 @@ func (r *Router) setupRoutes() {
 		r.smtpHandler.HandleSMTPStartTLS(w, req)
 	})
 
+	r.mux.HandleFunc("POST /smtp/matrix/{domain}", func(w http.ResponseWriter, req *http.Request) {
+		domain := req.PathValue("domain")
+		params := map[string]string{"domain": domain}
+		valid, errs := r.paramValidator.ValidateDomainParam(params)
+		if !valid {
+			r.errorHandler.HandleValidationError(w, "Invalid domain parameter", errs)
+			return
+		}
+		r.smtpHandler.HandleSMTPMatrix(w, req)
+	})
+
 	r.mux.HandleFunc("POST /smtp/relay-test", r.withValidation(r.smtpHandler.HandleSMTPRelayTest, r.jsonValidator.ValidateSMTPRelayTestRequestJSON))
+	r.mux.HandleFunc("POST /smtp/submission-test", r.withValidation(r.smtpHandler.HandleSMTPSubmissionTest, r.jsonValidator.ValidateSMTPSubmissionTestRequestJSON))
+	r.mux.HandleFunc("POST /smtp/smuggling-test", r.withValidation(r.smtpHandler.HandleSMTPSmugglingTest, r.jsonValidator.ValidateSMTPSmugglingTestRequestJSON))
+
+	// SMTP sink routes
+	r.mux.HandleFunc("GET /smtp/sink", r.smtpHandler.HandleSMTPSinkStatus)
+	r.mux.HandleFunc("POST /smtp/sink", r.withValidation(r.smtpHandler.HandleSMTPSinkStart, r.jsonValidator.ValidateSMTPSinkStartRequestJSON))
+	r.mux.HandleFunc("DELETE /smtp/sink", r.smtpHandler.HandleSMTPSinkStop)
+	r.mux.HandleFunc("POST /smtp/sink/tests", r.smtpHandler.HandleSMTPSinkCreateTest)
+	r.mux.HandleFunc("GET /smtp/sink/tests/{testId}", func(w http.ResponseWriter, req *http.Request) {
+		testId := req.PathValue("testId")
+		if testId == "" {
+			r.errorHandler.HandleValidationError(w, "testId parameter is required", nil)
+			return
+		}
+		ctx := context.WithValue(req.Context(), "testId", testId)
+		r.smtpHandler.HandleSMTPSinkTestResult(w, req.WithContext(ctx))
+	})
+
+	// IMAP and POP3 routes
+	r.mux.HandleFunc("POST /imap/connect/{host}", func(w http.ResponseWriter, req *http.Request) {
+		host := req.PathValue("host")
+		params := map[string]string{"host": host}
+		valid, errs := r.paramValidator.ValidateHostParam(params)
+		if !valid {
+			r.errorHandler.HandleValidationError(w, "Invalid host parameter", errs)
+			return
+		}
+		r.mailAccessHandler.HandleIMAPConnect(w, req)
+	})
+
+	r.mux.HandleFunc("POST /pop3/connect/{host}", func(w http.ResponseWriter, req *http.Request) {
+		host := req.PathValue("host")
+		params := map[string]string{"host": host}
+		valid, errs := r.paramValidator.ValidateHostParam(params)
+		if !valid {
+			r.errorHandler.HandleValidationError(w, "Invalid host parameter", errs)
+			return
+		}
+		r.mailAccessHandler.HandlePOP3Connect(w, req)
+	})
+
+	r.mux.HandleFunc("POST /imap/check", r.withValidation(r.mailAccessHandler.HandleIMAPCheck, r.jsonValidator.ValidateMailAccessCheckRequestJSON))
+	r.mux.HandleFunc("POST /pop3/check", r.withValidation(r.mailAccessHandler.HandlePOP3Check, r.jsonValidator.ValidateMailAccessCheckRequestJSON))
+
+	// Email verification routes
+	r.mux.HandleFunc("POST /email/verify", r.withValidation(r.smtpHandler.HandleEmailVerify, r.jsonValidator.ValidateEmailVerifyRequestJSON))
+	r.mux.HandleFunc("POST /email/verify/bulk", r.withValidation(r.smtpHandler.HandleEmailVerifyBulk, r.jsonValidator.ValidateEmailVerifyBulkRequestJSON))
 
 	// Email Authentication routes
+	r.mux.HandleFunc("POST /auth/spf/evaluate", r.withValidation(r.emailAuthHandler.HandleSPFEvaluate, r.jsonValidator.ValidateSPFEvaluateRequestJSON))
+	r.mux.HandleFunc("POST /auth/spf/lookups", r.withValidation(r.emailAuthHandler.HandleSPFLookupAnalysis, r.jsonValidator.ValidateSPFLookupAnalysisRequestJSON))
+	r.mux.HandleFunc("POST /auth/dkim/verify", r.withValidation(r.emailAuthHandler.HandleDKIMVerify, r.jsonValidator.ValidateDKIMVerifyRequestJSON))
+	r.mux.HandleFunc("POST /auth/arc/verify", r.withValidation(r.emailAuthHandler.HandleARCVerify, r.jsonValidator.ValidateARCVerifyRequestJSON))
+	r.mux.HandleFunc("POST /auth/dkim/discover", r.withValidation(r.emailAuthHandler.HandleDKIMDiscover, r.jsonValidator.ValidateDKIMDiscoverRequestJSON))
+	r.mux.HandleFunc("POST /auth/bimi", r.withValidation(r.emailAuthHandler.HandleBIMICheck, r.jsonValidator.ValidateBIMICheckRequestJSON))
+	r.mux.HandleFunc("POST /headers/analyze", r.withValidation(r.emailAuthHandler.HandleHeaderAnalyze, r.jsonValidator.ValidateHeaderAnalyzeRequestJSON))
+	r.mux.HandleFunc("POST /dmarc/reports", r.withValidation(r.emailAuthHandler.HandleDMARCReportIngest, r.jsonValidator.ValidateDMARCReportIngestRequestJSON))
+	r.mux.HandleFunc("POST /dmarc/reports/summary", r.withValidation(r.emailAuthHandler.HandleDMARCReportSummary, r.jsonValidator.ValidateDMARCReportSummaryRequestJSON))
+	r.mux.HandleFunc("POST /dmarc/forensic", r.withValidation(r.emailAuthHandler.HandleDMARCForensic, r.jsonValidator.ValidateDMARCForensicRequestJSON))
 	r.mux.HandleFunc("POST /auth/spf/{domain}", func(w http.ResponseWriter, req *http.Request) {
 		domain := req.PathValue("domain")
 		if domain == "" {
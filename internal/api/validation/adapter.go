@@ -67,6 +67,74 @@ func (v *JSONValidator) ValidateSMTPRelayTestRequestJSON(body []byte) (bool, map
 	return result.Valid, v.formatErrors(result)
 }
 
+// ValidateSMTPSubmissionTestRequestJSON validates an SMTP submission test request from JSON
+func (v *JSONValidator) ValidateSMTPSubmissionTestRequestJSON(body []byte) (bool, map[string]interface{}) {
+	var req models.SMTPSubmissionTestRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return false, map[string]interface{}{
+			"error": "Invalid JSON format: " + err.Error(),
+		}
+	}
+
+	result := ValidateSMTPSubmissionTestRequest(&req)
+	return result.Valid, v.formatErrors(result)
+}
+
+// ValidateSMTPSmugglingTestRequestJSON validates an SMTP smuggling probe request from JSON
+func (v *JSONValidator) ValidateSMTPSmugglingTestRequestJSON(body []byte) (bool, map[string]interface{}) {
+	var req models.SMTPSmugglingTestRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return false, map[string]interface{}{
+			"error": "Invalid JSON format: " + err.Error(),
+		}
+	}
+
+	result := ValidateSMTPSmugglingTestRequest(&req)
+	return result.Valid, v.formatErrors(result)
+}
+
+// ValidateSMTPSinkStartRequestJSON validates a request to start the SMTP sink from JSON
+func (v *JSONValidator) ValidateSMTPSinkStartRequestJSON(body []byte) (bool, map[string]interface{}) {
+	var req models.SMTPSinkStartRequest
+	if len(body) == 0 {
+		body = []byte("{}")
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return false, map[string]interface{}{
+			"error": "Invalid JSON format: " + err.Error(),
+		}
+	}
+
+	result := ValidateSMTPSinkStartRequest(&req)
+	return result.Valid, v.formatErrors(result)
+}
+
+// ValidateEmailVerifyRequestJSON validates an email verification request from JSON
+func (v *JSONValidator) ValidateEmailVerifyRequestJSON(body []byte) (bool, map[string]interface{}) {
+	var req models.EmailVerifyRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return false, map[string]interface{}{
+			"error": "Invalid JSON format: " + err.Error(),
+		}
+	}
+
+	result := ValidateEmailVerifyRequest(&req)
+	return result.Valid, v.formatErrors(result)
+}
+
+// ValidateEmailVerifyBulkRequestJSON validates a bulk email verification request from JSON
+func (v *JSONValidator) ValidateEmailVerifyBulkRequestJSON(body []byte) (bool, map[string]interface{}) {
+	var req models.EmailVerifyBulkRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return false, map[string]interface{}{
+			"error": "Invalid JSON format: " + err.Error(),
+		}
+	}
+
+	result := ValidateEmailVerifyBulkRequest(&req)
+	return result.Valid, v.formatErrors(result)
+}
+
 // ValidateEmailAuthRequestJSON validates an email authentication request from JSON
 func (v *JSONValidator) ValidateEmailAuthRequestJSON(body []byte) (bool, map[string]interface{}) {
 	var req models.CheckRequest
@@ -200,3 +268,146 @@ func formatErrors(result *ValidationResult) map[string]interface{} {
 	}
 	return errorMap
 }
+
+// ValidateMailAccessCheckRequestJSON validates an IMAP or POP3 check request from JSON
+func (v *JSONValidator) ValidateMailAccessCheckRequestJSON(body []byte) (bool, map[string]interface{}) {
+	var req models.MailAccessCheckRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return false, map[string]interface{}{
+			"error": "Invalid JSON format: " + err.Error(),
+		}
+	}
+
+	result := ValidateMailAccessCheckRequest(&req)
+	return result.Valid, v.formatErrors(result)
+}
+
+// ValidateSPFEvaluateRequestJSON validates an SPF evaluation request from JSON
+func (v *JSONValidator) ValidateSPFEvaluateRequestJSON(body []byte) (bool, map[string]interface{}) {
+	var req models.SPFEvaluateRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return false, map[string]interface{}{
+			"error": "Invalid JSON format: " + err.Error(),
+		}
+	}
+
+	result := ValidateSPFEvaluateRequest(&req)
+	return result.Valid, v.formatErrors(result)
+}
+
+// ValidateSPFLookupAnalysisRequestJSON validates an SPF lookup analysis request from JSON
+func (v *JSONValidator) ValidateSPFLookupAnalysisRequestJSON(body []byte) (bool, map[string]interface{}) {
+	var req models.SPFLookupAnalysisRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return false, map[string]interface{}{
+			"error": "Invalid JSON format: " + err.Error(),
+		}
+	}
+
+	result := ValidateSPFLookupAnalysisRequest(&req)
+	return result.Valid, v.formatErrors(result)
+}
+
+// ValidateDKIMVerifyRequestJSON validates a DKIM message verification request from JSON
+func (v *JSONValidator) ValidateDKIMVerifyRequestJSON(body []byte) (bool, map[string]interface{}) {
+	var req models.DKIMVerifyRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return false, map[string]interface{}{
+			"error": "Invalid JSON format: " + err.Error(),
+		}
+	}
+
+	result := ValidateDKIMVerifyRequest(&req)
+	return result.Valid, v.formatErrors(result)
+}
+
+// ValidateARCVerifyRequestJSON validates an ARC chain validation request from JSON
+func (v *JSONValidator) ValidateARCVerifyRequestJSON(body []byte) (bool, map[string]interface{}) {
+	var req models.ARCVerifyRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return false, map[string]interface{}{
+			"error": "Invalid JSON format: " + err.Error(),
+		}
+	}
+
+	result := ValidateARCVerifyRequest(&req)
+	return result.Valid, v.formatErrors(result)
+}
+
+// ValidateDKIMDiscoverRequestJSON validates a DKIM selector discovery request from JSON
+func (v *JSONValidator) ValidateDKIMDiscoverRequestJSON(body []byte) (bool, map[string]interface{}) {
+	var req models.DKIMDiscoverRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return false, map[string]interface{}{
+			"error": "Invalid JSON format: " + err.Error(),
+		}
+	}
+
+	result := ValidateDKIMDiscoverRequest(&req)
+	return result.Valid, v.formatErrors(result)
+}
+
+// ValidateBIMICheckRequestJSON validates a BIMI check request from JSON
+func (v *JSONValidator) ValidateBIMICheckRequestJSON(body []byte) (bool, map[string]interface{}) {
+	var req models.BIMICheckRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return false, map[string]interface{}{
+			"error": "Invalid JSON format: " + err.Error(),
+		}
+	}
+
+	result := ValidateBIMICheckRequest(&req)
+	return result.Valid, v.formatErrors(result)
+}
+
+// ValidateHeaderAnalyzeRequestJSON validates a message header analysis request from JSON
+func (v *JSONValidator) ValidateHeaderAnalyzeRequestJSON(body []byte) (bool, map[string]interface{}) {
+	var req models.HeaderAnalyzeRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return false, map[string]interface{}{
+			"error": "Invalid JSON format: " + err.Error(),
+		}
+	}
+
+	result := ValidateHeaderAnalyzeRequest(&req)
+	return result.Valid, v.formatErrors(result)
+}
+
+// ValidateDMARCReportIngestRequestJSON validates a DMARC aggregate report upload from JSON
+func (v *JSONValidator) ValidateDMARCReportIngestRequestJSON(body []byte) (bool, map[string]interface{}) {
+	var req models.DMARCReportIngestRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return false, map[string]interface{}{
+			"error": "Invalid JSON format: " + err.Error(),
+		}
+	}
+
+	result := ValidateDMARCReportIngestRequest(&req)
+	return result.Valid, v.formatErrors(result)
+}
+
+// ValidateDMARCForensicRequestJSON validates a DMARC failure report upload from JSON
+func (v *JSONValidator) ValidateDMARCForensicRequestJSON(body []byte) (bool, map[string]interface{}) {
+	var req models.DMARCForensicRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return false, map[string]interface{}{
+			"error": "Invalid JSON format: " + err.Error(),
+		}
+	}
+
+	result := ValidateDMARCForensicRequest(&req)
+	return result.Valid, v.formatErrors(result)
+}
+
+// ValidateDMARCReportSummaryRequestJSON validates a DMARC aggregate report summary request from JSON
+func (v *JSONValidator) ValidateDMARCReportSummaryRequestJSON(body []byte) (bool, map[string]interface{}) {
+	var req models.DMARCReportSummaryRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return false, map[string]interface{}{
+			"error": "Invalid JSON format: " + err.Error(),
+		}
+	}
+
+	result := ValidateDMARCReportSummaryRequest(&req)
+	return result.Valid, v.formatErrors(result)
+}
@@ -2,6 +2,7 @@ package validation
 
 import (
 	"fmt"
+	"mxclone/domain/emailauth"
 	"mxclone/internal/api/models"
 	"mxclone/pkg/validation"
 	"net"
@@ -212,6 +213,17 @@ func ValidateSMTPRelayTestRequest(req *models.SMTPRelayTestRequest) *ValidationR
 		})
 	}
 
+	// Check targetDomain is a valid domain (if specified)
+	if req.TargetDomain != "" {
+		if err := validation.ValidateDomain(req.TargetDomain); err != nil {
+			result.Valid = false
+			result.Errors = append(result.Errors, ValidationError{
+				Field:   "targetDomain",
+				Message: "invalid domain: " + err.Error(),
+			})
+		}
+	}
+
 	// Check port is valid (if specified)
 	if req.Port < 0 || req.Port > 65535 {
 		result.Valid = false
@@ -242,3 +254,671 @@ func ValidateSMTPRelayTestRequest(req *models.SMTPRelayTestRequest) *ValidationR
 
 	return result
 }
+
+// ValidateSMTPSubmissionTestRequest validates an SMTP submission test request
+func ValidateSMTPSubmissionTestRequest(req *models.SMTPSubmissionTestRequest) *ValidationResult {
+	result := &ValidationResult{Valid: true}
+
+	// Check if host is empty
+	if req.Host == "" {
+		result.Valid = false
+		result.Errors = append(result.Errors, ValidationError{
+			Field:   "host",
+			Message: "host cannot be empty",
+		})
+	} else if err := validation.ValidateDomain(req.Host); err != nil {
+		if err := validation.ValidateIP(req.Host); err != nil {
+			result.Valid = false
+			result.Errors = append(result.Errors, ValidationError{
+				Field:   "host",
+				Message: "invalid domain or IP address",
+			})
+		}
+	}
+
+	// Check port is valid (if specified)
+	if req.Port < 0 || req.Port > 65535 {
+		result.Valid = false
+		result.Errors = append(result.Errors, ValidationError{
+			Field:   "port",
+			Message: "port must be between 0 and 65535",
+		})
+	}
+
+	// Credentials are the point of the test
+	if req.Username == "" {
+		result.Valid = false
+		result.Errors = append(result.Errors, ValidationError{
+			Field:   "username",
+			Message: "username cannot be empty",
+		})
+	}
+	if req.Password == "" {
+		result.Valid = false
+		result.Errors = append(result.Errors, ValidationError{
+			Field:   "password",
+			Message: "password cannot be empty",
+		})
+	}
+
+	switch strings.ToUpper(req.Mechanism) {
+	case "", "PLAIN", "LOGIN", "CRAM-MD5", "XOAUTH2":
+	default:
+		result.Valid = false
+		result.Errors = append(result.Errors, ValidationError{
+			Field:   "mechanism",
+			Message: "mechanism must be one of PLAIN, LOGIN, CRAM-MD5, XOAUTH2",
+		})
+	}
+
+	// Check addresses are valid emails (if specified)
+	addresses := []struct{ field, address string }{
+		{"fromAddress", req.FromAddress},
+		{"toAddress", req.ToAddress},
+	}
+	for _, a := range addresses {
+		if a.address == "" {
+			continue
+		}
+		if err := validation.ValidateEmail(a.address); err != nil {
+			result.Valid = false
+			result.Errors = append(result.Errors, ValidationError{
+				Field:   a.field,
+				Message: "invalid email address: " + err.Error(),
+			})
+		}
+	}
+
+	// A test message needs a recipient
+	if req.SendMessage && req.ToAddress == "" {
+		result.Valid = false
+		result.Errors = append(result.Errors, ValidationError{
+			Field:   "toAddress",
+			Message: "toAddress is required when sendMessage is enabled",
+		})
+	}
+
+	return result
+}
+
+// ValidateSMTPSmugglingTestRequest validates an SMTP smuggling probe request
+func ValidateSMTPSmugglingTestRequest(req *models.SMTPSmugglingTestRequest) *ValidationResult {
+	result := &ValidationResult{Valid: true}
+
+	// Check if domain is empty
+	if req.Domain == "" {
+		result.Valid = false
+		result.Errors = append(result.Errors, ValidationError{
+			Field:   "domain",
+			Message: "domain cannot be empty",
+		})
+	} else if err := validation.ValidateDomain(req.Domain); err != nil {
+		result.Valid = false
+		result.Errors = append(result.Errors, ValidationError{
+			Field:   "domain",
+			Message: "invalid domain",
+		})
+	}
+
+	// Check host is a host name (if specified); it must also be an MX of the domain, which the
+	// probe checks before connecting
+	if req.Host != "" {
+		if err := validation.ValidateDomain(strings.TrimSuffix(req.Host, ".")); err != nil {
+			result.Valid = false
+			result.Errors = append(result.Errors, ValidationError{
+				Field:   "host",
+				Message: "host must be the name of an MX of the domain",
+			})
+		}
+	}
+
+	// Check port is valid (if specified)
+	if req.Port < 0 || req.Port > 65535 {
+		result.Valid = false
+		result.Errors = append(result.Errors, ValidationError{
+			Field:   "port",
+			Message: "port must be between 0 and 65535",
+		})
+	}
+
+	// Check sender is a valid email (if specified)
+	if req.FromAddress != "" {
+		if err := validation.ValidateEmail(req.FromAddress); err != nil {
+			result.Valid = false
+			result.Errors = append(result.Errors, ValidationError{
+				Field:   "fromAddress",
+				Message: "invalid email address: " + err.Error(),
+			})
+		}
+	}
+
+	// The probe delivers messages to the postmaster of a vulnerable server
+	if !req.Confirm {
+		result.Valid = false
+		result.Errors = append(result.Errors, ValidationError{
+			Field:   "confirm",
+			Message: "confirm must be true: a vulnerable server delivers probe messages to the domain's postmaster",
+		})
+	}
+
+	return result
+}
+
+// ValidateSMTPSinkStartRequest validates a request to start the SMTP sink
+func ValidateSMTPSinkStartRequest(req *models.SMTPSinkStartRequest) *ValidationResult {
+	result := &ValidationResult{Valid: true}
+
+	// The sink only listens on the configured address: API callers cannot open listeners
+	if req.Address != "" {
+		result.Valid = false
+		result.Errors = append(result.Errors, ValidationError{
+			Field:   "address",
+			Message: "address cannot be chosen through the API: the sink listens on the sink_address setting",
+		})
+	}
+
+	// Check domain and hostname are valid (if specified)
+	if req.Domain != "" {
+		if err := validation.ValidateDomain(req.Domain); err != nil {
+			result.Valid = false
+			result.Errors = append(result.Errors, ValidationError{
+				Field:   "domain",
+				Message: "invalid domain",
+			})
+		}
+	}
+	if req.Hostname != "" {
+		if err := validation.ValidateDomain(req.Hostname); err != nil {
+			result.Valid = false
+			result.Errors = append(result.Errors, ValidationError{
+				Field:   "hostname",
+				Message: "invalid hostname",
+			})
+		}
+	}
+
+	if req.Timeout < 0 {
+		result.Valid = false
+		result.Errors = append(result.Errors, ValidationError{
+			Field:   "timeout",
+			Message: "timeout cannot be negative",
+		})
+	}
+
+	return result
+}
+
+// MaxBulkVerifyAddresses is the largest number of addresses accepted by a bulk verification request
+const MaxBulkVerifyAddresses = 100
+
+// ValidateEmailVerifyRequest validates an email verification request
+// The address syntax is not checked here: reporting it is part of the verification
+func ValidateEmailVerifyRequest(req *models.EmailVerifyRequest) *ValidationResult {
+	result := &ValidationResult{Valid: true}
+
+	if strings.TrimSpace(req.Address) == "" {
+		result.Valid = false
+		result.Errors = append(result.Errors, ValidationError{
+			Field:   "address",
+			Message: "address cannot be empty",
+		})
+	}
+
+	if req.Timeout < 0 {
+		result.Valid = false
+		result.Errors = append(result.Errors, ValidationError{
+			Field:   "timeout",
+			Message: "timeout cannot be negative",
+		})
+	}
+
+	return result
+}
+
+// ValidateEmailVerifyBulkRequest validates a bulk email verification request
+func ValidateEmailVerifyBulkRequest(req *models.EmailVerifyBulkRequest) *ValidationResult {
+	result := &ValidationResult{Valid: true}
+
+	switch {
+	case len(req.Addresses) == 0:
+		result.Valid = false
+		result.Errors = append(result.Errors, ValidationError{
+			Field:   "addresses",
+			Message: "addresses cannot be empty",
+		})
+	case len(req.Addresses) > MaxBulkVerifyAddresses:
+		result.Valid = false
+		result.Errors = append(result.Errors, ValidationError{
+			Field:   "addresses",
+			Message: fmt.Sprintf("at most %d addresses can be verified per request", MaxBulkVerifyAddresses),
+		})
+	}
+
+	for i, address := range req.Addresses {
+		if strings.TrimSpace(address) == "" {
+			result.Valid = false
+			result.Errors = append(result.Errors, ValidationError{
+				Field:   fmt.Sprintf("addresses[%d]", i),
+				Message: "address cannot be empty",
+			})
+		}
+	}
+
+	if req.Timeout < 0 {
+		result.Valid = false
+		result.Errors = append(result.Errors, ValidationError{
+			Field:   "timeout",
+			Message: "timeout cannot be negative",
+		})
+	}
+
+	return result
+}
+
+// ValidateMailAccessCheckRequest validates an IMAP or POP3 check request
+func ValidateMailAccessCheckRequest(req *models.MailAccessCheckRequest) *ValidationResult {
+	result := &ValidationResult{Valid: true}
+
+	// Check if host is empty
+	if req.Host == "" {
+		result.Valid = false
+		result.Errors = append(result.Errors, ValidationError{
+			Field:   "host",
+			Message: "host cannot be empty",
+		})
+	} else if err := validation.ValidateHost(req.Host); err != nil {
+		result.Valid = false
+		result.Errors = append(result.Errors, ValidationError{
+			Field:   "host",
+			Message: "invalid domain or IP address",
+		})
+	}
+
+	// Check port is valid (if specified)
+	if req.Port < 0 || req.Port > 65535 {
+		result.Valid = false
+		result.Errors = append(result.Errors, ValidationError{
+			Field:   "port",
+			Message: "port must be between 0 and 65535",
+		})
+	}
+
+	// The login test needs both credentials
+	if (req.Username == "") != (req.Password == "") {
+		result.Valid = false
+		result.Errors = append(result.Errors, ValidationError{
+			Field:   "password",
+			Message: "username and password must be given together",
+		})
+	}
+
+	if req.Timeout < 0 {
+		result.Valid = false
+		result.Errors = append(result.Errors, ValidationError{
+			Field:   "timeout",
+			Message: "timeout cannot be negative",
+		})
+	}
+
+	return result
+}
+
+// ValidateSPFEvaluateRequest validates an SPF evaluation request
+func ValidateSPFEvaluateRequest(req *models.SPFEvaluateRequest) *ValidationResult {
+	result := &ValidationResult{Valid: true}
+
+	// Check if IP is empty or invalid
+	if req.IP == "" {
+		result.Valid = false
+		result.Errors = append(result.Errors, ValidationError{
+			Field:   "ip",
+			Message: "ip cannot be empty",
+		})
+	} else if net.ParseIP(req.IP) == nil {
+		result.Valid = false
+		result.Errors = append(result.Errors, ValidationError{
+			Field:   "ip",
+			Message: "invalid IP address",
+		})
+	}
+
+	// The evaluated domain comes from domain, sender or helo
+	if req.Domain == "" && req.Sender == "" && req.Helo == "" {
+		result.Valid = false
+		result.Errors = append(result.Errors, ValidationError{
+			Field:   "domain",
+			Message: "domain, sender or helo is required",
+		})
+	} else if req.Domain != "" {
+		if err := validation.ValidateDomain(req.Domain); err != nil {
+			result.Valid = false
+			result.Errors = append(result.Errors, ValidationError{
+				Field:   "domain",
+				Message: "invalid domain name: " + err.Error(),
+			})
+		}
+	}
+
+	if req.Timeout < 0 {
+		result.Valid = false
+		result.Errors = append(result.Errors, ValidationError{
+			Field:   "timeout",
+			Message: "timeout cannot be negative",
+		})
+	}
+
+	return result
+}
+
+// ValidateSPFLookupAnalysisRequest validates an SPF lookup analysis request
+func ValidateSPFLookupAnalysisRequest(req *models.SPFLookupAnalysisRequest) *ValidationResult {
+	result := &ValidationResult{Valid: true}
+
+	// Check if domain is empty or invalid
+	if req.Domain == "" {
+		result.Valid = false
+		result.Errors = append(result.Errors, ValidationError{
+			Field:   "domain",
+			Message: "domain cannot be empty",
+		})
+	} else if err := validation.ValidateDomain(req.Domain); err != nil {
+		result.Valid = false
+		result.Errors = append(result.Errors, ValidationError{
+			Field:   "domain",
+			Message: "invalid domain name: " + err.Error(),
+		})
+	}
+
+	if req.Timeout < 0 {
+		result.Valid = false
+		result.Errors = append(result.Errors, ValidationError{
+			Field:   "timeout",
+			Message: "timeout cannot be negative",
+		})
+	}
+
+	return result
+}
+
+// MaxDKIMMessageSize is the largest message accepted by a DKIM or ARC verification request, in bytes
+const MaxDKIMMessageSize = 10 << 20
+
+// ValidateDKIMVerifyRequest validates a DKIM message verification request
+func ValidateDKIMVerifyRequest(req *models.DKIMVerifyRequest) *ValidationResult {
+	return validateRawMessage(req.Message, req.Timeout)
+}
+
+// ValidateARCVerifyRequest validates an ARC chain validation request
+func ValidateARCVerifyRequest(req *models.ARCVerifyRequest) *ValidationResult {
+	return validateRawMessage(req.Message, req.Timeout)
+}
+
+// validateRawMessage validates an uploaded raw message and the timeout of its key lookups
+func validateRawMessage(message string, timeout int) *ValidationResult {
+	result := &ValidationResult{Valid: true}
+
+	switch {
+	case strings.TrimSpace(message) == "":
+		result.Valid = false
+		result.Errors = append(result.Errors, ValidationError{
+			Field:   "message",
+			Message: "message cannot be empty",
+		})
+	case len(message) > MaxDKIMMessageSize:
+		result.Valid = false
+		result.Errors = append(result.Errors, ValidationError{
+			Field:   "message",
+			Message: fmt.Sprintf("message cannot be larger than %d bytes", MaxDKIMMessageSize),
+		})
+	case !strings.Contains(message, ":"):
+		result.Valid = false
+		result.Errors = append(result.Errors, ValidationError{
+			Field:   "message",
+			Message: "message has no header fields",
+		})
+	}
+
+	if timeout < 0 {
+		result.Valid = false
+		result.Errors = append(result.Errors, ValidationError{
+			Field:   "timeout",
+			Message: "timeout cannot be negative",
+		})
+	}
+
+	return result
+}
+
+// MaxHeaderAnalyzeSize is the largest header section, or message, accepted for header analysis, in bytes
+const MaxHeaderAnalyzeSize = 10 << 20
+
+// ValidateHeaderAnalyzeRequest validates a message header analysis request
+func ValidateHeaderAnalyzeRequest(req *models.HeaderAnalyzeRequest) *ValidationResult {
+	result := &ValidationResult{Valid: true}
+
+	switch {
+	case strings.TrimSpace(req.Headers) == "":
+		result.Valid = false
+		result.Errors = append(result.Errors, ValidationError{
+			Field:   "headers",
+			Message: "headers cannot be empty",
+		})
+	case len(req.Headers) > MaxHeaderAnalyzeSize:
+		result.Valid = false
+		result.Errors = append(result.Errors, ValidationError{
+			Field:   "headers",
+			Message: fmt.Sprintf("headers cannot be larger than %d bytes", MaxHeaderAnalyzeSize),
+		})
+	case !strings.Contains(req.Headers, ":"):
+		result.Valid = false
+		result.Errors = append(result.Errors, ValidationError{
+			Field:   "headers",
+			Message: "no header fields found",
+		})
+	}
+
+	return result
+}
+
+// MaxDMARCReportUploadFiles is the largest number of files in a DMARC report upload
+const MaxDMARCReportUploadFiles = 100
+
+// MaxDMARCReportUploadSize is the largest total size of the files of a DMARC report upload, in bytes
+const MaxDMARCReportUploadSize = 50 << 20
+
+// ValidateDMARCReportIngestRequest validates a DMARC aggregate report upload
+func ValidateDMARCReportIngestRequest(req *models.DMARCReportIngestRequest) *ValidationResult {
+	result := &ValidationResult{Valid: true}
+	validateDMARCReportUploads(req.Files, result)
+	return result
+}
+
+// ValidateDMARCForensicRequest validates a DMARC failure report upload
+func ValidateDMARCForensicRequest(req *models.DMARCForensicRequest) *ValidationResult {
+	result := &ValidationResult{Valid: true}
+	validateDMARCReportUploads(req.Files, result)
+	return result
+}
+
+// validateDMARCReportUploads checks the number and size of uploaded report files
+func validateDMARCReportUploads(files []models.DMARCReportUploadFile, result *ValidationResult) {
+	switch {
+	case len(files) == 0:
+		result.Valid = false
+		result.Errors = append(result.Errors, ValidationError{
+			Field:   "files",
+			Message: "at least one file is required",
+		})
+	case len(files) > MaxDMARCReportUploadFiles:
+		result.Valid = false
+		result.Errors = append(result.Errors, ValidationError{
+			Field:   "files",
+			Message: fmt.Sprintf("no more than %d files can be uploaded at once", MaxDMARCReportUploadFiles),
+		})
+	}
+
+	total := 0
+	for i, file := range files {
+		if len(file.Content) == 0 {
+			result.Valid = false
+			result.Errors = append(result.Errors, ValidationError{
+				Field:   fmt.Sprintf("files[%d].content", i),
+				Message: "content cannot be empty",
+			})
+		}
+		total += len(file.Content)
+	}
+	if total > MaxDMARCReportUploadSize {
+		result.Valid = false
+		result.Errors = append(result.Errors, ValidationError{
+			Field:   "files",
+			Message: fmt.Sprintf("files cannot be larger than %d bytes in total", MaxDMARCReportUploadSize),
+		})
+	}
+}
+
+// MaxDMARCKnownSenders is the largest number of known senders in a DMARC report summary request
+const MaxDMARCKnownSenders = 100
+
+// ValidateDMARCReportSummaryRequest validates a DMARC aggregate report summary request
+func ValidateDMARCReportSummaryRequest(req *models.DMARCReportSummaryRequest) *ValidationResult {
+	result := &ValidationResult{Valid: true}
+
+	if req.Domain != "" {
+		if err := validation.ValidateDomain(req.Domain); err != nil {
+			result.Valid = false
+			result.Errors = append(result.Errors, ValidationError{
+				Field:   "domain",
+				Message: "invalid domain name: " + err.Error(),
+			})
+		}
+	}
+
+	if _, err := emailauth.ParseReportDate(req.Since, false); err != nil {
+		result.Valid = false
+		result.Errors = append(result.Errors, ValidationError{
+			Field:   "since",
+			Message: err.Error(),
+		})
+	}
+	if _, err := emailauth.ParseReportDate(req.Until, true); err != nil {
+		result.Valid = false
+		result.Errors = append(result.Errors, ValidationError{
+			Field:   "until",
+			Message: err.Error(),
+		})
+	}
+
+	for _, number := range []struct {
+		field string
+		value int
+	}{{"days", req.Days}, {"top", req.Top}, {"timeout", req.Timeout}} {
+		if number.value < 0 {
+			result.Valid = false
+			result.Errors = append(result.Errors, ValidationError{
+				Field:   number.field,
+				Message: number.field + " cannot be negative",
+			})
+		}
+	}
+
+	if len(req.KnownSenders) > MaxDMARCKnownSenders {
+		result.Valid = false
+		result.Errors = append(result.Errors, ValidationError{
+			Field:   "knownSenders",
+			Message: fmt.Sprintf("no more than %d known senders can be given", MaxDMARCKnownSenders),
+		})
+	}
+
+	return result
+}
+
+// ValidateBIMICheckRequest validates a BIMI check request
+func ValidateBIMICheckRequest(req *models.BIMICheckRequest) *ValidationResult {
+	result := &ValidationResult{Valid: true}
+
+	// Check if domain is empty or invalid
+	if req.Domain == "" {
+		result.Valid = false
+		result.Errors = append(result.Errors, ValidationError{
+			Field:   "domain",
+			Message: "domain cannot be empty",
+		})
+	} else if err := validation.ValidateDomain(req.Domain); err != nil {
+		result.Valid = false
+		result.Errors = append(result.Errors, ValidationError{
+			Field:   "domain",
+			Message: "invalid domain name: " + err.Error(),
+		})
+	}
+
+	if req.Selector != "" {
+		if err := validation.ValidateSelector(req.Selector); err != nil {
+			result.Valid = false
+			result.Errors = append(result.Errors, ValidationError{
+				Field:   "selector",
+				Message: "invalid selector: " + err.Error(),
+			})
+		}
+	}
+
+	if req.Timeout < 0 {
+		result.Valid = false
+		result.Errors = append(result.Errors, ValidationError{
+			Field:   "timeout",
+			Message: "timeout cannot be negative",
+		})
+	}
+
+	return result
+}
+
+// MaxDKIMDiscoverSelectors is the largest number of custom selectors in a DKIM discovery request
+const MaxDKIMDiscoverSelectors = 50
+
+// ValidateDKIMDiscoverRequest validates a DKIM selector discovery request
+func ValidateDKIMDiscoverRequest(req *models.DKIMDiscoverRequest) *ValidationResult {
+	result := &ValidationResult{Valid: true}
+
+	// Check if domain is empty or invalid
+	if req.Domain == "" {
+		result.Valid = false
+		result.Errors = append(result.Errors, ValidationError{
+			Field:   "domain",
+			Message: "domain cannot be empty",
+		})
+	} else if err := validation.ValidateDomain(req.Domain); err != nil {
+		result.Valid = false
+		result.Errors = append(result.Errors, ValidationError{
+			Field:   "domain",
+			Message: "invalid domain name: " + err.Error(),
+		})
+	}
+
+	if len(req.Selectors) > MaxDKIMDiscoverSelectors {
+		result.Valid = false
+		result.Errors = append(result.Errors, ValidationError{
+			Field:   "selectors",
+			Message: fmt.Sprintf("no more than %d selectors can be given", MaxDKIMDiscoverSelectors),
+		})
+	}
+	for _, selector := range req.Selectors {
+		if err := validation.ValidateSelector(selector); err != nil {
+			result.Valid = false
+			result.Errors = append(result.Errors, ValidationError{
+				Field:   "selectors",
+				Message: "invalid selector " + selector + ": " + err.Error(),
+			})
+		}
+	}
+
+	if req.Timeout < 0 {
+		result.Valid = false
+		result.Errors = append(result.Errors, ValidationError{
+			Field:   "timeout",
+			Message: "timeout cannot be negative",
+		})
+	}
+
+	return result
+}
@@ -18,6 +18,13 @@ type Config struct {
 	LogLevel    string `mapstructure:"log_level"`
 	CacheDir    string `mapstructure:"cache_dir"`
 
+	// Public Suffix List file, used in place of the bundled snapshot when it exists
+	PSLFile string `mapstructure:"psl_file"`
+
+	// DMARC aggregate report settings
+	DMARCReportDB     string   `mapstructure:"dmarc_report_db"`     // File the ingested reports are stored in
+	DMARCKnownSenders []string `mapstructure:"dmarc_known_senders"` // IPs, CIDR ranges, ASNs (AS64500) or reverse DNS domains
+
 	// DNS settings
 	DNSTimeout   int      `mapstructure:"dns_timeout"`
 	DNSRetries   int      `mapstructure:"dns_retries"`
@@ -33,6 +40,17 @@ type Config struct {
 	SMTPTimeout int   `mapstructure:"smtp_timeout"`
 	SMTPPorts   []int `mapstructure:"smtp_ports"`
 
+	// SMTP sink settings; the API server starts the sink when enabled
+	SinkEnabled  bool   `mapstructure:"sink_enabled"`
+	SinkAddress  string `mapstructure:"sink_address"`
+	SinkDomain   string `mapstructure:"sink_domain"`
+	SinkHostname string `mapstructure:"sink_hostname"`
+	SinkCertFile string `mapstructure:"sink_cert_file"`
+	SinkKeyFile  string `mapstructure:"sink_key_file"`
+	// Seconds a sink test address receives and keeps messages, and the messages kept for each test
+	SinkTestTTL         int `mapstructure:"sink_test_ttl"`
+	SinkMaxTestMessages int `mapstructure:"sink_max_test_messages"`
+
 	// JobStore settings
 	JobStoreType string      `mapstructure:"job_store_type"` // "inmemory" or "redis"
 	Redis        RedisConfig `mapstructure:"redis"`
@@ -65,6 +83,10 @@ func DefaultConfig() *Config {
 		LogLevel:    "info",
 		CacheDir:    filepath.Join(os.TempDir(), "mxclone"),
 
+		PSLFile: filepath.Join(os.TempDir(), "mxclone", "public_suffix_list.dat"),
+
+		DMARCReportDB: filepath.Join(defaultDataDir(), "dmarc_reports.jsonl"),
+
 		DNSTimeout:   5,
 		DNSRetries:   2,
 		DNSResolvers: []string{"8.8.8.8:53", "1.1.1.1:53"},
@@ -81,6 +103,11 @@ func DefaultConfig() *Config {
 		SMTPTimeout: 10,
 		SMTPPorts:   []int{25, 465, 587},
 
+		SinkEnabled:         false,
+		SinkAddress:         ":2525",
+		SinkTestTTL:         86400, // 24 hours
+		SinkMaxTestMessages: 20,
+
 		JobStoreType: "inmemory", // Default to in-memory
 		Redis: RedisConfig{
 			Address:  "redis-service:6379",
@@ -91,6 +118,15 @@ func DefaultConfig() *Config {
 	}
 }
 
+// defaultDataDir returns the directory data that must outlive a run is kept in: ~/.mxclone,
+// or the temporary directory when there is no home directory
+func defaultDataDir() string {
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, ".mxclone")
+	}
+	return filepath.Join(os.TempDir(), "mxclone")
+}
+
 // NewAPIConfig creates a new API configuration with defaults and environment overrides
 func NewAPIConfig() *APIConfig {
 	config := &APIConfig{
@@ -137,6 +173,9 @@ func LoadConfig(configFile string) (*Config, error) {
 	v.SetDefault("worker_count", defaultConfig.WorkerCount)
 	v.SetDefault("log_level", defaultConfig.LogLevel)
 	v.SetDefault("cache_dir", defaultConfig.CacheDir)
+	v.SetDefault("psl_file", defaultConfig.PSLFile)
+	v.SetDefault("dmarc_report_db", defaultConfig.DMARCReportDB)
+	v.SetDefault("dmarc_known_senders", defaultConfig.DMARCKnownSenders)
 	v.SetDefault("dns_timeout", defaultConfig.DNSTimeout)
 	v.SetDefault("dns_retries", defaultConfig.DNSRetries)
 	v.SetDefault("dns_resolvers", defaultConfig.DNSResolvers)
@@ -146,6 +185,14 @@ func LoadConfig(configFile string) (*Config, error) {
 	v.SetDefault("blacklist_cache_ttl", defaultConfig.BlacklistCacheTTL)
 	v.SetDefault("smtp_timeout", defaultConfig.SMTPTimeout)
 	v.SetDefault("smtp_ports", defaultConfig.SMTPPorts)
+	v.SetDefault("sink_enabled", defaultConfig.SinkEnabled)
+	v.SetDefault("sink_address", defaultConfig.SinkAddress)
+	v.SetDefault("sink_domain", defaultConfig.SinkDomain)
+	v.SetDefault("sink_hostname", defaultConfig.SinkHostname)
+	v.SetDefault("sink_cert_file", defaultConfig.SinkCertFile)
+	v.SetDefault("sink_key_file", defaultConfig.SinkKeyFile)
+	v.SetDefault("sink_test_ttl", defaultConfig.SinkTestTTL)
+	v.SetDefault("sink_max_test_messages", defaultConfig.SinkMaxTestMessages)
 	v.SetDefault("job_store_type", defaultConfig.JobStoreType)
 	v.SetDefault("redis.redis_address", defaultConfig.Redis.Address)
 	v.SetDefault("redis.redis_password", defaultConfig.Redis.Password)
@@ -190,6 +237,9 @@ func (c *Config) PrintConfig() {
 	fmt.Printf("  Worker Count: %d\n", c.WorkerCount)
 	fmt.Printf("  Log Level: %s\n", c.LogLevel)
 	fmt.Printf("  Cache Directory: %s\n", c.CacheDir)
+	fmt.Printf("  Public Suffix List: %s\n", c.PSLFile)
+	fmt.Printf("  DMARC Report Database: %s\n", c.DMARCReportDB)
+	fmt.Printf("  DMARC Known Senders: %v\n", c.DMARCKnownSenders)
 	fmt.Printf("  DNS Timeout: %d seconds\n", c.DNSTimeout)
 	fmt.Printf("  DNS Retries: %d\n", c.DNSRetries)
 	fmt.Printf("  DNS Resolvers: %v\n", c.DNSResolvers)
@@ -199,6 +249,11 @@ func (c *Config) PrintConfig() {
 	fmt.Printf("  Blacklist Cache TTL: %d seconds\n", c.BlacklistCacheTTL)
 	fmt.Printf("  SMTP Timeout: %d seconds\n", c.SMTPTimeout)
 	fmt.Printf("  SMTP Ports: %v\n", c.SMTPPorts)
+	fmt.Printf("  SMTP Sink Enabled: %t\n", c.SinkEnabled)
+	if c.SinkEnabled {
+		fmt.Printf("  SMTP Sink Address: %s\n", c.SinkAddress)
+		fmt.Printf("  SMTP Sink Domain: %s\n", c.SinkDomain)
+	}
 	fmt.Printf("  Job Store Type: %s\n", c.JobStoreType)
 	if c.JobStoreType == "redis" {
 		fmt.Printf("  Redis Address: %s\n", c.Redis.Address)
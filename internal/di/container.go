@@ -4,9 +4,13 @@ package di
 import (
 	"mxclone/adapters/primary"
 	"mxclone/adapters/secondary"
+	"mxclone/domain/smtp"
+	"mxclone/internal/config"
+	"mxclone/pkg/emailauth"
 	"mxclone/pkg/logging"
 	"mxclone/ports/input"
 	"os"
+	"time"
 )
 
 // Container represents a simple dependency injection container
@@ -20,6 +24,8 @@ type Container struct {
 	smtpService         input.SMTPPort
 	emailAuthService    input.EmailAuthPort
 	networkToolsService input.NetworkToolsPort
+	mailAccessService   input.MailAccessPort
+	autoconfigService   input.AutoconfigPort
 }
 
 // NewContainer creates a new dependency injection container with all services properly wired up
@@ -27,6 +33,17 @@ func NewContainer(appName string) *Container {
 	// Create logger with proper parameters
 	logger := logging.NewLogger(appName, logging.LevelInfo, os.Stdout)
 
+	// Load configuration, falling back to the defaults when it cannot be read
+	cfg, err := config.LoadConfig("")
+	if err != nil {
+		cfg = config.DefaultConfig()
+	}
+
+	// Use the Public Suffix List downloaded with "auth psl-update" in place of the bundled snapshot
+	if err := emailauth.LoadPublicSuffixList(cfg.PSLFile); err != nil && !os.IsNotExist(err) {
+		logger.Warning("Using the bundled public suffix list: %v", err)
+	}
+
 	// Create repositories (secondary adapters implementing output ports)
 	dnsRepository := secondary.NewDNSRepository()
 
@@ -37,14 +54,28 @@ func NewContainer(appName string) *Container {
 	dnsblService := primary.NewDNSBLAdapter(dnsblRepository)
 
 	smtpRepository := secondary.NewSMTPRepository(dnsService)
-	smtpService := primary.NewSMTPAdapter(smtpRepository)
-
-	emailAuthRepository := secondary.NewEmailAuthRepository(dnsService)
-	emailAuthService := primary.NewEmailAuthAdapter(emailAuthRepository)
+	smtpService := primary.NewSMTPAdapter(smtpRepository, cfg.SMTPPorts).WithSinkDefaults(smtp.SinkConfig{
+		Address:         cfg.SinkAddress,
+		Domain:          cfg.SinkDomain,
+		Hostname:        cfg.SinkHostname,
+		CertFile:        cfg.SinkCertFile,
+		KeyFile:         cfg.SinkKeyFile,
+		TestTTL:         time.Duration(cfg.SinkTestTTL) * time.Second,
+		MaxTestMessages: cfg.SinkMaxTestMessages,
+	})
+
+	emailAuthRepository := secondary.NewEmailAuthRepository(dnsService, cfg.DMARCReportDB)
+	emailAuthService := primary.NewEmailAuthAdapter(emailAuthRepository, cfg.DMARCKnownSenders)
 
 	networkToolsRepository := secondary.NewNetworkToolsRepository()
 	networkToolsService := primary.NewNetworkToolsAdapter(networkToolsRepository)
 
+	mailAccessRepository := secondary.NewMailAccessRepository()
+	mailAccessService := primary.NewMailAccessAdapter(mailAccessRepository)
+
+	autoconfigRepository := secondary.NewAutoconfigRepository()
+	autoconfigService := primary.NewAutoconfigAdapter(autoconfigRepository)
+
 	return &Container{
 		logger:              logger,
 		dnsService:          dnsService,
@@ -52,6 +83,8 @@ func NewContainer(appName string) *Container {
 		smtpService:         smtpService,
 		emailAuthService:    emailAuthService,
 		networkToolsService: networkToolsService,
+		mailAccessService:   mailAccessService,
+		autoconfigService:   autoconfigService,
 	}
 }
 
@@ -84,3 +117,13 @@ func (c *Container) GetEmailAuthService() input.EmailAuthPort {
 func (c *Container) GetNetworkToolsService() input.NetworkToolsPort {
 	return c.networkToolsService
 }
+
+// GetMailAccessService returns the IMAP and POP3 service
+func (c *Container) GetMailAccessService() input.MailAccessPort {
+	return c.mailAccessService
+}
+
+// GetAutoconfigService returns the mail client autoconfiguration service
+func (c *Container) GetAutoconfigService() input.AutoconfigPort {
+	return c.autoconfigService
+}
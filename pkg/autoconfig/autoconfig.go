@@ -0,0 +1,141 @@
+// Package autoconfig discovers the mail server settings that clients are given for a domain:
+// RFC 6186 / RFC 8314 SRV records, Thunderbird autoconfig and Microsoft Autodiscover.
+package autoconfig
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"mxclone/pkg/types"
+)
+
+// Discovery sources
+const (
+	SourceSRV          = "srv"
+	SourceAutoconfig   = "autoconfig"
+	SourceAutodiscover = "autodiscover"
+)
+
+// Protocols of the advertised servers
+const (
+	ProtocolIMAP = "imap"
+	ProtocolPOP3 = "pop3"
+	ProtocolSMTP = "smtp"
+)
+
+// Connection security of the advertised servers
+const (
+	SecurityTLS      = "tls"      // TLS before the greeting (993, 995, 465)
+	SecuritySTARTTLS = "starttls" // STARTTLS or STLS after the greeting
+	SecurityNone     = "none"
+)
+
+// maxDocumentSize limits the autoconfig and Autodiscover responses that are read.
+const maxDocumentSize = 1 << 20
+
+// Options configures a discovery.
+type Options struct {
+	// Domain is the mail domain
+	Domain string
+	// Email is the address sent to autoconfig and Autodiscover (default: postmaster@Domain)
+	Email string
+	// Timeout applies to each lookup and HTTP request
+	Timeout time.Duration
+	// Resolver is used for the SRV lookups (default: net.DefaultResolver)
+	Resolver *net.Resolver
+	// HTTPClient fetches the autoconfig and Autodiscover documents
+	HTTPClient *http.Client
+}
+
+// ProbeOptions configures a connection test of an advertised server.
+type ProbeOptions struct {
+	// Timeout applies to the connection and to each command
+	Timeout time.Duration
+	// HeloName is the name announced in EHLO to SMTP servers
+	HeloName string
+	// RootCAs verifies the server certificate (default: system roots)
+	RootCAs *x509.CertPool
+}
+
+// Discover looks up the SRV records and fetches the autoconfig and Autodiscover documents of a
+// domain concurrently. Unreachable sources are reported in the result, not as errors.
+func Discover(ctx context.Context, opts Options) (*types.AutoconfigDiscovery, error) {
+	opts = withDefaults(opts)
+
+	discovery := &types.AutoconfigDiscovery{
+		Domain: opts.Domain,
+		Email:  opts.Email,
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		discovery.SRV = LookupSRV(ctx, opts)
+	}()
+	go func() {
+		defer wg.Done()
+		discovery.Autoconfig = FetchAutoconfig(ctx, opts)
+	}()
+	go func() {
+		defer wg.Done()
+		discovery.Autodiscover = FetchAutodiscover(ctx, opts)
+	}()
+	wg.Wait()
+
+	return discovery, ctx.Err()
+}
+
+// withDefaults fills in the unset options.
+func withDefaults(opts Options) Options {
+	opts.Domain = strings.TrimSuffix(strings.ToLower(opts.Domain), ".")
+	if opts.Email == "" {
+		opts.Email = "postmaster@" + opts.Domain
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	if opts.Resolver == nil {
+		opts.Resolver = net.DefaultResolver
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = &http.Client{Timeout: opts.Timeout}
+	}
+	return opts
+}
+
+// defaultSecurity returns the security implied by a well-known port.
+func defaultSecurity(port int) string {
+	switch port {
+	case 993, 995, 465:
+		return SecurityTLS
+	}
+	return SecuritySTARTTLS
+}
+
+// expandPlaceholders replaces the autoconfig placeholders of an email address.
+func expandPlaceholders(value, email string) string {
+	local, domain, _ := strings.Cut(email, "@")
+	return strings.NewReplacer(
+		"%EMAILADDRESS%", email,
+		"%EMAILLOCALPART%", local,
+		"%EMAILDOMAIN%", domain,
+	).Replace(value)
+}
+
+// requestError returns the message of an HTTP request error without the method and URL, which
+// the document already records.
+func requestError(err error) string {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err.Error()
+	}
+	return err.Error()
+}
@@ -0,0 +1,422 @@
+package autoconfig
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"mxclone/pkg/types"
+)
+
+const testClientConfig = `<?xml version="1.0" encoding="UTF-8"?>
+<clientConfig version="1.1">
+  <emailProvider id="example.com">
+    <domain>example.com</domain>
+    <displayName>Example Mail</displayName>
+    <incomingServer type="imap">
+      <hostname>imap.%EMAILDOMAIN%</hostname>
+      <port>993</port>
+      <socketType>SSL</socketType>
+      <username>%EMAILADDRESS%</username>
+      <authentication>password-cleartext</authentication>
+    </incomingServer>
+    <incomingServer type="pop3">
+      <hostname>pop.example.com</hostname>
+      <port>110</port>
+      <socketType>plain</socketType>
+      <username>%EMAILLOCALPART%</username>
+    </incomingServer>
+    <incomingServer type="exchange">
+      <hostname>outlook.example.com</hostname>
+    </incomingServer>
+    <outgoingServer type="smtp">
+      <hostname>smtp.example.com</hostname>
+      <port>587</port>
+      <socketType>STARTTLS</socketType>
+      <username>%EMAILADDRESS%</username>
+      <authentication>password-cleartext</authentication>
+      <authentication>OAuth2</authentication>
+    </outgoingServer>
+  </emailProvider>
+</clientConfig>`
+
+const testAutodiscoverResponse = `<?xml version="1.0" encoding="utf-8"?>
+<Autodiscover xmlns="http://schemas.microsoft.com/exchange/autodiscover/responseschema/2006">
+  <Response xmlns="http://schemas.microsoft.com/exchange/autodiscover/outlook/responseschema/2006a">
+    <User><DisplayName>Postmaster</DisplayName></User>
+    <Account>
+      <AccountType>email</AccountType>
+      <Action>settings</Action>
+      <Protocol>
+        <Type>IMAP</Type>
+        <Server>imap.example.com</Server>
+        <Port>993</Port>
+        <LoginName>postmaster@example.com</LoginName>
+        <SSL>on</SSL>
+        <SPA>off</SPA>
+      </Protocol>
+      <Protocol>
+        <Type>SMTP</Type>
+        <Server>smtp.example.com</Server>
+        <Port>587</Port>
+        <Encryption>TLS</Encryption>
+        <SPA>on</SPA>
+      </Protocol>
+      <Protocol>
+        <Type>POP3</Type>
+        <Server>pop.example.com</Server>
+        <Port>110</Port>
+        <SSL>off</SSL>
+      </Protocol>
+      <Protocol>
+        <Type>EXCH</Type>
+        <Server>exchange.example.com</Server>
+      </Protocol>
+    </Account>
+  </Response>
+</Autodiscover>`
+
+func TestParseClientConfig(t *testing.T) {
+	var document types.AutoconfigDocument
+	if err := parseClientConfig([]byte(testClientConfig), "alice@example.com", &document); err != nil {
+		t.Fatalf("parseClientConfig() error = %v", err)
+	}
+	if !document.Found || document.DisplayName != "Example Mail" {
+		t.Errorf("document = %+v, want found with display name", document)
+	}
+
+	want := []types.MailServerSetting{
+		{Source: SourceAutoconfig, Protocol: ProtocolIMAP, Host: "imap.example.com", Port: 993, Security: SecurityTLS, Username: "alice@example.com"},
+		{Source: SourceAutoconfig, Protocol: ProtocolPOP3, Host: "pop.example.com", Port: 110, Security: SecurityNone, Username: "alice"},
+		{Source: SourceAutoconfig, Protocol: ProtocolSMTP, Host: "smtp.example.com", Port: 587, Security: SecuritySTARTTLS, Username: "alice@example.com"},
+	}
+	checkServers(t, document.Servers, want)
+	if got := document.Servers[2].Authentication; len(got) != 2 || got[1] != "OAuth2" {
+		t.Errorf("SMTP authentication = %v, want [password-cleartext OAuth2]", got)
+	}
+
+	if err := parseClientConfig([]byte("<html>not found</html>"), "alice@example.com", &types.AutoconfigDocument{}); err == nil {
+		t.Error("parseClientConfig() accepted an HTML page")
+	}
+}
+
+func TestParseAutodiscover(t *testing.T) {
+	var document types.AutoconfigDocument
+	if err := parseAutodiscover([]byte(testAutodiscoverResponse), &document); err != nil {
+		t.Fatalf("parseAutodiscover() error = %v", err)
+	}
+	want := []types.MailServerSetting{
+		{Source: SourceAutodiscover, Protocol: ProtocolIMAP, Host: "imap.example.com", Port: 993, Security: SecurityTLS, Username: "postmaster@example.com"},
+		{Source: SourceAutodiscover, Protocol: ProtocolSMTP, Host: "smtp.example.com", Port: 587, Security: SecuritySTARTTLS},
+		{Source: SourceAutodiscover, Protocol: ProtocolPOP3, Host: "pop.example.com", Port: 110, Security: SecurityNone},
+	}
+	checkServers(t, document.Servers, want)
+	if document.DisplayName != "Postmaster" {
+		t.Errorf("DisplayName = %q, want Postmaster", document.DisplayName)
+	}
+
+	redirect := `<Autodiscover><Response><Account><Action>redirectAddr</Action><RedirectAddr>user@other.example</RedirectAddr></Account></Response></Autodiscover>`
+	document = types.AutoconfigDocument{}
+	if err := parseAutodiscover([]byte(redirect), &document); err != nil || document.Redirect != "user@other.example" {
+		t.Errorf("redirect: Redirect = %q, error = %v", document.Redirect, err)
+	}
+
+	failure := `<Autodiscover><Response><Error><ErrorCode>600</ErrorCode><Message>Invalid Request</Message></Error></Response></Autodiscover>`
+	if err := parseAutodiscover([]byte(failure), &types.AutoconfigDocument{}); err == nil || !strings.Contains(err.Error(), "600") {
+		t.Errorf("error response: error = %v, want Autodiscover error 600", err)
+	}
+}
+
+func TestFetchDocuments(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Host == "autoconfig.example.com" && r.URL.Path == "/mail/config-v1.1.xml":
+			if r.URL.Query().Get("emailaddress") != "alice@example.com" {
+				http.Error(w, "missing address", http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte(testClientConfig))
+		case r.Host == "autodiscover.example.com" && r.URL.Path == autodiscoverPath && r.Method == http.MethodPost:
+			w.Write([]byte(testAutodiscoverResponse))
+		case r.Host == "example.com" && r.URL.Path == autodiscoverPath:
+			w.Header().Set("WWW-Authenticate", "Basic")
+			w.WriteHeader(http.StatusUnauthorized)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	plain := httptest.NewServer(handler)
+	defer plain.Close()
+	secure := httptest.NewTLSServer(handler)
+	defer secure.Close()
+
+	// Every host name resolves to the local test servers
+	dialer := &net.Dialer{}
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, plain.Listener.Addr().String())
+		},
+		DialTLSContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			conn, err := dialer.DialContext(ctx, network, secure.Listener.Addr().String())
+			if err != nil {
+				return nil, err
+			}
+			return tls.Client(conn, &tls.Config{InsecureSkipVerify: true}), nil
+		},
+	}}
+
+	opts := Options{
+		Domain:     "example.com",
+		Email:      "alice@example.com",
+		Timeout:    5 * time.Second,
+		HTTPClient: client,
+		Resolver:   testResolver(t, nil),
+	}
+
+	autoconfig := FetchAutoconfig(context.Background(), opts)
+	if len(autoconfig) != 3 {
+		t.Fatalf("FetchAutoconfig() returned %d documents, want 3", len(autoconfig))
+	}
+	if !autoconfig[0].Found || len(autoconfig[0].Servers) != 3 {
+		t.Errorf("HTTPS autoconfig = %+v, want 3 servers", autoconfig[0])
+	}
+	if autoconfig[1].Found || autoconfig[1].StatusCode != http.StatusNotFound {
+		t.Errorf("well-known autoconfig = %+v, want 404", autoconfig[1])
+	}
+	if !autoconfig[2].Found || !strings.HasPrefix(autoconfig[2].URL, "http://") {
+		t.Errorf("HTTP autoconfig = %+v, want found over plain HTTP", autoconfig[2])
+	}
+
+	autodiscover := FetchAutodiscover(context.Background(), opts)
+	if len(autodiscover) != 2 {
+		t.Fatalf("FetchAutodiscover() returned %d documents, want 2", len(autodiscover))
+	}
+	if autodiscover[0].Found || !autodiscover[0].RequiresAuth {
+		t.Errorf("domain Autodiscover = %+v, want 401", autodiscover[0])
+	}
+	if !autodiscover[1].Found || len(autodiscover[1].Servers) != 3 {
+		t.Errorf("autodiscover subdomain = %+v, want 3 servers", autodiscover[1])
+	}
+}
+
+func TestLookupSRV(t *testing.T) {
+	records := map[string][]dns.RR{
+		"_imaps._tcp.example.com.": {
+			&dns.SRV{Priority: 10, Weight: 5, Port: 993, Target: "imap.example.com."},
+		},
+		"_pop3._tcp.example.com.": {
+			&dns.SRV{Port: 0, Target: "."},
+		},
+		"_submission._tcp.example.com.": {
+			&dns.SRV{Priority: 0, Weight: 1, Port: 587, Target: "smtp.example.com."},
+		},
+		"_autodiscover._tcp.example.com.": {
+			&dns.SRV{Priority: 0, Weight: 1, Port: 8443, Target: "ad.example.com."},
+		},
+	}
+	opts := Options{Domain: "example.com", Timeout: 5 * time.Second, Resolver: testResolver(t, records)}
+
+	results := LookupSRV(context.Background(), opts)
+	if len(results) != len(srvServices) {
+		t.Fatalf("LookupSRV() returned %d results, want %d", len(results), len(srvServices))
+	}
+	byService := make(map[string]types.SRVServiceResult)
+	for _, result := range results {
+		if result.Error != "" {
+			t.Errorf("%s: error = %s", result.Service, result.Error)
+		}
+		byService[result.Service] = result
+	}
+
+	imaps := byService["_imaps._tcp"]
+	checkServers(t, imaps.Servers, []types.MailServerSetting{
+		{Source: SourceSRV, Protocol: ProtocolIMAP, Host: "imap.example.com", Port: 993, Security: SecurityTLS, Priority: 10, Weight: 5},
+	})
+	if pop3 := byService["_pop3._tcp"]; !pop3.Found || !pop3.NotProvided {
+		t.Errorf("_pop3._tcp = %+v, want not provided", pop3)
+	}
+	if submission := byService["_submission._tcp"]; len(submission.Servers) != 1 || submission.Servers[0].Security != SecuritySTARTTLS {
+		t.Errorf("_submission._tcp = %+v, want one STARTTLS server", submission)
+	}
+	if imap := byService["_imap._tcp"]; imap.Found {
+		t.Errorf("_imap._tcp = %+v, want not found", imap)
+	}
+
+	urls := AutodiscoverURLs(context.Background(), opts)
+	if len(urls) != 3 || urls[2] != "https://ad.example.com:8443"+autodiscoverPath {
+		t.Errorf("AutodiscoverURLs() = %v, want the SRV target last", urls)
+	}
+}
+
+func TestProbeServerSMTP(t *testing.T) {
+	cert, roots := testCertificate(t)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	defer listener.Close()
+	go serveSMTP(listener, &tls.Config{Certificates: []tls.Certificate{cert}})
+
+	port := listener.Addr().(*net.TCPAddr).Port
+	setting := types.MailServerSetting{Protocol: ProtocolSMTP, Host: "127.0.0.1", Port: port, Security: SecuritySTARTTLS}
+	probe := ProbeServer(context.Background(), setting, ProbeOptions{Timeout: 5 * time.Second, RootCAs: roots})
+
+	if probe.Error != "" {
+		t.Fatalf("ProbeServer() error = %s", probe.Error)
+	}
+	if !probe.Connected || !probe.STARTTLS || !probe.TLS {
+		t.Errorf("probe = %+v, want connected with STARTTLS", probe)
+	}
+	if probe.Certificate == nil || !probe.Certificate.Trusted || !probe.Certificate.HostnameMatch {
+		t.Errorf("certificate = %+v, want trusted and matching", probe.Certificate)
+	}
+
+	// The same server does not speak TLS before the greeting
+	setting.Security = SecurityTLS
+	probe = ProbeServer(context.Background(), setting, ProbeOptions{Timeout: 2 * time.Second, RootCAs: roots})
+	if probe.TLS || probe.Error == "" {
+		t.Errorf("implicit TLS probe = %+v, want a TLS error", probe)
+	}
+
+	probe = ProbeServer(context.Background(), types.MailServerSetting{Protocol: ProtocolSMTP}, ProbeOptions{})
+	if probe.Error == "" {
+		t.Error("ProbeServer() accepted a setting without host and port")
+	}
+}
+
+// checkServers compares advertised servers, ignoring the authentication methods.
+func checkServers(t *testing.T, got, want []types.MailServerSetting) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d servers %+v, want %d", len(got), got, len(want))
+	}
+	for i := range want {
+		g := got[i]
+		g.Authentication = nil
+		if !reflect.DeepEqual(g, want[i]) {
+			t.Errorf("server %d = %+v, want %+v", i, g, want[i])
+		}
+	}
+}
+
+// testResolver returns a resolver backed by a local DNS server that answers SRV queries from
+// records and NXDOMAIN otherwise.
+func testResolver(t *testing.T, records map[string][]dns.RR) *net.Resolver {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenPacket() error = %v", err)
+	}
+	server := &dns.Server{PacketConn: conn, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		q := r.Question[0]
+		answers, ok := records[strings.ToLower(q.Name)]
+		if !ok {
+			m.Rcode = dns.RcodeNameError
+		}
+		for _, rr := range answers {
+			if q.Qtype != dns.TypeSRV {
+				continue
+			}
+			srv := *rr.(*dns.SRV)
+			srv.Hdr = dns.RR_Header{Name: q.Name, Rrtype: dns.TypeSRV, Class: dns.ClassINET, Ttl: 60}
+			m.Answer = append(m.Answer, &srv)
+		}
+		w.WriteMsg(m)
+	})}
+	go server.ActivateAndServe()
+	t.Cleanup(func() { server.Shutdown() })
+
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "udp", conn.LocalAddr().String())
+		},
+	}
+}
+
+// testCertificate returns a certificate for 127.0.0.1 and a pool that trusts it.
+func testCertificate(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(7),
+		Subject:               pkix.Name{CommonName: "smtp.example"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate() error = %v", err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("ParseCertificate() error = %v", err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, pool
+}
+
+// serveSMTP answers SMTP sessions with STARTTLS until the listener is closed.
+func serveSMTP(listener net.Listener, config *tls.Config) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go func(conn net.Conn) {
+			defer conn.Close()
+			conn.SetDeadline(time.Now().Add(5 * time.Second))
+			reader := bufio.NewReader(conn)
+			conn.Write([]byte("220 smtp.example ESMTP\r\n"))
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				switch command := strings.ToUpper(strings.TrimSpace(line)); {
+				case strings.HasPrefix(command, "EHLO"):
+					conn.Write([]byte("250-smtp.example\r\n250-STARTTLS\r\n250 AUTH PLAIN\r\n"))
+				case command == "STARTTLS":
+					conn.Write([]byte("220 Ready to start TLS\r\n"))
+					tlsConn := tls.Server(conn, config)
+					if tlsConn.Handshake() != nil {
+						return
+					}
+					conn = tlsConn
+					reader = bufio.NewReader(conn)
+				case command == "QUIT":
+					conn.Write([]byte("221 Bye\r\n"))
+					return
+				default:
+					conn.Write([]byte("502 Unknown command\r\n"))
+				}
+			}
+		}(conn)
+	}
+}
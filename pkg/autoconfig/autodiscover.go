@@ -0,0 +1,199 @@
+package autoconfig
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"mxclone/pkg/types"
+)
+
+// autodiscoverPath is the path of the POX Autodiscover endpoint.
+const autodiscoverPath = "/autodiscover/autodiscover.xml"
+
+// autodiscoverRequest is the Outlook POX Autodiscover request.
+const autodiscoverRequest = `<?xml version="1.0" encoding="utf-8"?>
+<Autodiscover xmlns="http://schemas.microsoft.com/exchange/autodiscover/outlook/requestschema/2006">
+  <Request>
+    <EMailAddress>%s</EMailAddress>
+    <AcceptableResponseSchema>http://schemas.microsoft.com/exchange/autodiscover/outlook/responseschema/2006a</AcceptableResponseSchema>
+  </Request>
+</Autodiscover>`
+
+// autodiscoverResponse is the Outlook POX Autodiscover response. Namespaces are ignored.
+type autodiscoverResponse struct {
+	XMLName  xml.Name `xml:"Autodiscover"`
+	Response struct {
+		User struct {
+			DisplayName string `xml:"DisplayName"`
+		} `xml:"User"`
+		Account struct {
+			Action       string                 `xml:"Action"`
+			RedirectAddr string                 `xml:"RedirectAddr"`
+			RedirectURL  string                 `xml:"RedirectUrl"`
+			Protocols    []autodiscoverProtocol `xml:"Protocol"`
+		} `xml:"Account"`
+		Error struct {
+			ErrorCode string `xml:"ErrorCode"`
+			Message   string `xml:"Message"`
+		} `xml:"Error"`
+	} `xml:"Response"`
+}
+
+// autodiscoverProtocol is a Protocol element of an Autodiscover account.
+type autodiscoverProtocol struct {
+	Type       string `xml:"Type"`
+	Server     string `xml:"Server"`
+	Port       string `xml:"Port"`
+	LoginName  string `xml:"LoginName"`
+	SSL        string `xml:"SSL"`
+	Encryption string `xml:"Encryption"`
+	SPA        string `xml:"SPA"`
+}
+
+// AutodiscoverURLs returns the Autodiscover endpoints Outlook tries for a domain, in order,
+// followed by the target of the _autodiscover._tcp SRV record when there is one.
+func AutodiscoverURLs(ctx context.Context, opts Options) []string {
+	opts = withDefaults(opts)
+
+	urls := []string{
+		"https://" + opts.Domain + autodiscoverPath,
+		"https://autodiscover." + opts.Domain + autodiscoverPath,
+	}
+	records, err := lookupSRV(ctx, opts, "_autodiscover._tcp."+opts.Domain)
+	if err == nil && len(records) > 0 && records[0].Target != "." {
+		host := strings.TrimSuffix(records[0].Target, ".")
+		if records[0].Port != 443 {
+			host = net.JoinHostPort(host, strconv.Itoa(int(records[0].Port)))
+		}
+		urls = append(urls, "https://"+host+autodiscoverPath)
+	}
+	return urls
+}
+
+// FetchAutodiscover posts an Autodiscover request to every endpoint of a domain concurrently.
+func FetchAutodiscover(ctx context.Context, opts Options) []types.AutoconfigDocument {
+	opts = withDefaults(opts)
+
+	urls := AutodiscoverURLs(ctx, opts)
+	documents := make([]types.AutoconfigDocument, len(urls))
+	var wg sync.WaitGroup
+	for i, u := range urls {
+		wg.Add(1)
+		go func(i int, u string) {
+			defer wg.Done()
+			documents[i] = fetchAutodiscover(ctx, opts, u)
+		}(i, u)
+	}
+	wg.Wait()
+	return documents
+}
+
+// fetchAutodiscover posts an Autodiscover request to one endpoint and parses the response.
+func fetchAutodiscover(ctx context.Context, opts Options, u string) types.AutoconfigDocument {
+	document := types.AutoconfigDocument{Source: SourceAutodiscover, URL: u}
+
+	body := fmt.Sprintf(autodiscoverRequest, xmlEscape(opts.Email))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, strings.NewReader(body))
+	if err != nil {
+		document.Error = err.Error()
+		return document
+	}
+	req.Header.Set("Content-Type", "text/xml; charset=utf-8")
+
+	resp, err := opts.HTTPClient.Do(req)
+	if err != nil {
+		document.Error = requestError(err)
+		return document
+	}
+	defer resp.Body.Close()
+
+	document.StatusCode = resp.StatusCode
+	if resp.StatusCode != http.StatusOK {
+		// Exchange answers 401 until the client authenticates: the endpoint exists
+		document.RequiresAuth = resp.StatusCode == http.StatusUnauthorized
+		return document
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxDocumentSize))
+	if err != nil {
+		document.Error = err.Error()
+		return document
+	}
+	if err := parseAutodiscover(data, &document); err != nil {
+		document.Error = err.Error()
+	}
+	return document
+}
+
+// parseAutodiscover parses an Autodiscover response into the IMAP, POP3 and SMTP servers it
+// advertises. Exchange protocols (EXCH, EXPR, WEB) are ignored.
+func parseAutodiscover(data []byte, document *types.AutoconfigDocument) error {
+	var response autodiscoverResponse
+	if err := xml.Unmarshal(data, &response); err != nil {
+		return fmt.Errorf("invalid Autodiscover response: %w", err)
+	}
+	if e := response.Response.Error; e.ErrorCode != "" || e.Message != "" {
+		return fmt.Errorf("Autodiscover error %s: %s", e.ErrorCode, e.Message)
+	}
+
+	account := response.Response.Account
+	document.Found = true
+	document.DisplayName = response.Response.User.DisplayName
+	switch strings.ToLower(account.Action) {
+	case "redirectaddr":
+		document.Redirect = account.RedirectAddr
+	case "redirecturl":
+		document.Redirect = account.RedirectURL
+	}
+
+	for _, protocol := range account.Protocols {
+		name := strings.ToLower(protocol.Type)
+		if name != ProtocolIMAP && name != ProtocolPOP3 && name != ProtocolSMTP {
+			continue
+		}
+		port, _ := strconv.Atoi(strings.TrimSpace(protocol.Port))
+
+		// Encryption takes precedence over SSL, which defaults to on
+		security := defaultSecurity(port)
+		switch strings.ToLower(strings.TrimSpace(protocol.Encryption)) {
+		case "ssl":
+			security = SecurityTLS
+		case "tls":
+			security = SecuritySTARTTLS
+		case "none":
+			security = SecurityNone
+		case "", "auto":
+			if strings.EqualFold(strings.TrimSpace(protocol.SSL), "off") {
+				security = SecurityNone
+			}
+		}
+
+		setting := types.MailServerSetting{
+			Source:   SourceAutodiscover,
+			Protocol: name,
+			Host:     strings.TrimSpace(protocol.Server),
+			Port:     port,
+			Security: security,
+			Username: strings.TrimSpace(protocol.LoginName),
+		}
+		if strings.EqualFold(strings.TrimSpace(protocol.SPA), "on") {
+			setting.Authentication = []string{"SPA"}
+		}
+		document.Servers = append(document.Servers, setting)
+	}
+	return nil
+}
+
+// xmlEscape escapes a value for an XML text node.
+func xmlEscape(value string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(value))
+	return b.String()
+}
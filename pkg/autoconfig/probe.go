@@ -0,0 +1,117 @@
+package autoconfig
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"time"
+
+	"mxclone/pkg/mailaccess"
+	"mxclone/pkg/smtp"
+	"mxclone/pkg/types"
+)
+
+// ProbeServer connects to an advertised server with the security it was advertised with and
+// reports whether it answers, whether TLS can be negotiated and the certificate it presents.
+// IMAP and POP3 servers are checked with the mail access probes, SMTP servers with the SMTP
+// client. No credentials are sent.
+func ProbeServer(ctx context.Context, setting types.MailServerSetting, opts ProbeOptions) *types.MailServerProbe {
+	if opts.Timeout == 0 {
+		opts.Timeout = 10 * time.Second
+	}
+
+	probe := &types.MailServerProbe{
+		Protocol: setting.Protocol,
+		Host:     setting.Host,
+		Port:     setting.Port,
+		Security: setting.Security,
+	}
+	if setting.Host == "" || setting.Port == 0 {
+		probe.Error = "incomplete server setting"
+		return probe
+	}
+
+	switch setting.Protocol {
+	case ProtocolIMAP, ProtocolPOP3:
+		probeMailAccess(ctx, setting, opts, probe)
+	case ProtocolSMTP:
+		probeSMTP(ctx, setting, opts, probe)
+	default:
+		probe.Error = fmt.Sprintf("unsupported protocol: %s", setting.Protocol)
+	}
+	return probe
+}
+
+// probeMailAccess checks an IMAP or POP3 server.
+func probeMailAccess(ctx context.Context, setting types.MailServerSetting, opts ProbeOptions, probe *types.MailServerProbe) {
+	checkOpts := mailaccess.CheckOptions{
+		Host:        setting.Host,
+		Port:        setting.Port,
+		ImplicitTLS: setting.Security == SecurityTLS,
+		Timeout:     opts.Timeout,
+		RootCAs:     opts.RootCAs,
+	}
+
+	check := mailaccess.CheckIMAP
+	if setting.Protocol == ProtocolPOP3 {
+		check = mailaccess.CheckPOP3
+	}
+	report, err := check(ctx, checkOpts)
+
+	probe.Connected = report.Connected
+	probe.Banner = report.Banner
+	probe.STARTTLS = report.STARTTLS
+	probe.TLS = report.TLS
+	probe.TLSVersion = report.TLSVersion
+	probe.Certificate = report.Certificate
+	switch {
+	case err != nil:
+		probe.Error = err.Error()
+	case report.STARTTLSError != "":
+		probe.Error = report.STARTTLSError
+	}
+}
+
+// probeSMTP checks an SMTP submission server.
+func probeSMTP(ctx context.Context, setting types.MailServerSetting, opts ProbeOptions, probe *types.MailServerProbe) {
+	client, err := smtp.Dial(ctx, setting.Host, setting.Port, opts.Timeout, setting.Security == SecurityTLS)
+	if err != nil {
+		probe.Error = err.Error()
+		return
+	}
+	defer client.Quit()
+
+	probe.Connected = true
+	probe.Banner = client.Banner.String()
+	if client.Banner.Code != 220 {
+		probe.Error = "server refused the session: " + client.Banner.String()
+		return
+	}
+
+	if _, err := client.Hello(opts.HeloName); err != nil {
+		probe.Error = fmt.Sprintf("EHLO failed: %v", err)
+		return
+	}
+	probe.STARTTLS, _ = client.Extension("STARTTLS")
+
+	if !client.TLS() && probe.STARTTLS && setting.Security != SecurityNone {
+		reply, err := client.StartTLS(nil)
+		switch {
+		case err != nil:
+			probe.Error = err.Error()
+		case reply.Code != 220:
+			probe.Error = "STARTTLS refused: " + reply.String()
+		}
+	}
+
+	if state, ok := client.TLSState(); ok {
+		recordTLS(probe, state, opts)
+	}
+}
+
+// recordTLS stores the negotiated TLS version and the certificate details in the probe.
+func recordTLS(probe *types.MailServerProbe, state tls.ConnectionState, opts ProbeOptions) {
+	probe.TLS = true
+	probe.TLSVersion = tls.VersionName(state.Version)
+	probe.Certificate = mailaccess.CertificateInfo(state.PeerCertificates, probe.Host, opts.RootCAs)
+}
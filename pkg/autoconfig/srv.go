@@ -0,0 +1,78 @@
+package autoconfig
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+
+	"mxclone/pkg/types"
+)
+
+// srvService describes one of the SRV services a mail client looks up.
+type srvService struct {
+	service  string
+	protocol string
+	security string
+}
+
+// srvServices are the RFC 6186 services and their RFC 8314 implicit TLS counterparts.
+var srvServices = []srvService{
+	{"_submissions._tcp", ProtocolSMTP, SecurityTLS},
+	{"_submission._tcp", ProtocolSMTP, SecuritySTARTTLS},
+	{"_imaps._tcp", ProtocolIMAP, SecurityTLS},
+	{"_imap._tcp", ProtocolIMAP, SecuritySTARTTLS},
+	{"_pop3s._tcp", ProtocolPOP3, SecurityTLS},
+	{"_pop3._tcp", ProtocolPOP3, SecuritySTARTTLS},
+}
+
+// LookupSRV looks up the mail client SRV services of a domain in order.
+func LookupSRV(ctx context.Context, opts Options) []types.SRVServiceResult {
+	opts = withDefaults(opts)
+
+	results := make([]types.SRVServiceResult, 0, len(srvServices))
+	for _, svc := range srvServices {
+		result := types.SRVServiceResult{
+			Service: svc.service,
+			Name:    svc.service + "." + opts.Domain,
+		}
+
+		records, err := lookupSRV(ctx, opts, result.Name)
+		switch {
+		case err != nil:
+			result.Error = err.Error()
+		case len(records) == 1 && records[0].Target == ".":
+			// RFC 6186 section 3.4: the service is explicitly not provided
+			result.Found = true
+			result.NotProvided = true
+		default:
+			result.Found = len(records) > 0
+			for _, record := range records {
+				result.Servers = append(result.Servers, types.MailServerSetting{
+					Source:   SourceSRV,
+					Protocol: svc.protocol,
+					Host:     strings.TrimSuffix(record.Target, "."),
+					Port:     int(record.Port),
+					Security: svc.security,
+					Priority: int(record.Priority),
+					Weight:   int(record.Weight),
+				})
+			}
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// lookupSRV resolves a SRV name. A name that does not exist is not an error.
+func lookupSRV(ctx context.Context, opts Options, name string) ([]*net.SRV, error) {
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	_, records, err := opts.Resolver.LookupSRV(ctx, "", "", name)
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		return nil, nil
+	}
+	return records, err
+}
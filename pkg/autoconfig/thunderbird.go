@@ -0,0 +1,137 @@
+package autoconfig
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"mxclone/pkg/types"
+)
+
+// clientConfig is the Thunderbird autoconfig document (config-v1.1.xml).
+type clientConfig struct {
+	XMLName       xml.Name `xml:"clientConfig"`
+	EmailProvider struct {
+		ID              string               `xml:"id,attr"`
+		DisplayName     string               `xml:"displayName"`
+		IncomingServers []clientConfigServer `xml:"incomingServer"`
+		OutgoingServers []clientConfigServer `xml:"outgoingServer"`
+	} `xml:"emailProvider"`
+}
+
+// clientConfigServer is an incomingServer or outgoingServer element.
+type clientConfigServer struct {
+	Type           string   `xml:"type,attr"`
+	Hostname       string   `xml:"hostname"`
+	Port           string   `xml:"port"`
+	SocketType     string   `xml:"socketType"`
+	Username       string   `xml:"username"`
+	Authentication []string `xml:"authentication"`
+}
+
+// AutoconfigURLs returns the URLs Thunderbird tries for a domain, in order. The last one is
+// plain HTTP, which Thunderbird still falls back to.
+func AutoconfigURLs(domain, email string) []string {
+	query := "?emailaddress=" + url.QueryEscape(email)
+	return []string{
+		"https://autoconfig." + domain + "/mail/config-v1.1.xml" + query,
+		"https://" + domain + "/.well-known/autoconfig/mail/config-v1.1.xml" + query,
+		"http://autoconfig." + domain + "/mail/config-v1.1.xml" + query,
+	}
+}
+
+// FetchAutoconfig fetches every autoconfig URL of a domain concurrently.
+func FetchAutoconfig(ctx context.Context, opts Options) []types.AutoconfigDocument {
+	opts = withDefaults(opts)
+
+	urls := AutoconfigURLs(opts.Domain, opts.Email)
+	documents := make([]types.AutoconfigDocument, len(urls))
+	var wg sync.WaitGroup
+	for i, u := range urls {
+		wg.Add(1)
+		go func(i int, u string) {
+			defer wg.Done()
+			documents[i] = fetchAutoconfig(ctx, opts, u)
+		}(i, u)
+	}
+	wg.Wait()
+	return documents
+}
+
+// fetchAutoconfig fetches and parses one autoconfig document.
+func fetchAutoconfig(ctx context.Context, opts Options, u string) types.AutoconfigDocument {
+	document := types.AutoconfigDocument{Source: SourceAutoconfig, URL: u}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		document.Error = err.Error()
+		return document
+	}
+	resp, err := opts.HTTPClient.Do(req)
+	if err != nil {
+		document.Error = requestError(err)
+		return document
+	}
+	defer resp.Body.Close()
+
+	document.StatusCode = resp.StatusCode
+	if resp.StatusCode != http.StatusOK {
+		document.RequiresAuth = resp.StatusCode == http.StatusUnauthorized
+		return document
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxDocumentSize))
+	if err != nil {
+		document.Error = err.Error()
+		return document
+	}
+	if err := parseClientConfig(body, opts.Email, &document); err != nil {
+		document.Error = err.Error()
+	}
+	return document
+}
+
+// parseClientConfig parses a config-v1.1.xml document into the servers it advertises.
+// Servers of other types (exchange, ews, ...) are ignored.
+func parseClientConfig(body []byte, email string, document *types.AutoconfigDocument) error {
+	var config clientConfig
+	if err := xml.Unmarshal(body, &config); err != nil {
+		return fmt.Errorf("invalid autoconfig document: %w", err)
+	}
+	document.Found = true
+	document.DisplayName = config.EmailProvider.DisplayName
+
+	servers := append(config.EmailProvider.IncomingServers, config.EmailProvider.OutgoingServers...)
+	for _, server := range servers {
+		protocol := strings.ToLower(server.Type)
+		if protocol != ProtocolIMAP && protocol != ProtocolPOP3 && protocol != ProtocolSMTP {
+			continue
+		}
+		port, _ := strconv.Atoi(strings.TrimSpace(server.Port))
+
+		security := SecurityNone
+		switch strings.ToUpper(strings.TrimSpace(server.SocketType)) {
+		case "SSL", "TLS":
+			security = SecurityTLS
+		case "STARTTLS":
+			security = SecuritySTARTTLS
+		}
+
+		document.Servers = append(document.Servers, types.MailServerSetting{
+			Source:         SourceAutoconfig,
+			Protocol:       protocol,
+			Host:           expandPlaceholders(strings.TrimSpace(server.Hostname), email),
+			Port:           port,
+			Security:       security,
+			Username:       expandPlaceholders(strings.TrimSpace(server.Username), email),
+			Authentication: server.Authentication,
+		})
+	}
+	return nil
+}
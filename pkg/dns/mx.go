@@ -0,0 +1,343 @@
+// Package dns provides DNS lookup functionality.
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"mxclone/pkg/types"
+)
+
+// MXResolver is the DNS interface used to analyze MX records; *net.Resolver implements it.
+type MXResolver interface {
+	LookupMX(ctx context.Context, name string) ([]*net.MX, error)
+	LookupCNAME(ctx context.Context, host string) (string, error)
+	LookupIP(ctx context.Context, network, host string) ([]net.IP, error)
+}
+
+// MXAnalysisOptions configures AnalyzeMX.
+type MXAnalysisOptions struct {
+	// CheckReachability opens a TCP connection to every mail exchanger
+	CheckReachability bool
+	// Port to test reachability on (default 25)
+	Port int
+	// Timeout of each connection attempt (default 10s)
+	Timeout time.Duration
+	// Dial overrides how connections are opened; nil uses a net.Dialer
+	Dial func(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// AnalyzeMX checks the MX records of a domain: RFC 7505 null MX, the implicit MX fallback to A/AAAA,
+// targets that are aliases, IP literals, non-public or unresolvable, duplicate records and preferences,
+// and, when enabled, whether the primary and backup exchangers accept connections.
+func AnalyzeMX(ctx context.Context, resolver MXResolver, domain string, opts MXAnalysisOptions) *types.MXAnalysis {
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	if opts.Port == 0 {
+		opts.Port = 25
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	domain = strings.TrimSuffix(strings.ToLower(domain), ".")
+
+	analysis := &types.MXAnalysis{Domain: domain}
+
+	mxs, err := resolver.LookupMX(ctx, domain)
+	if err != nil && !isNotFound(err) {
+		analysis.Error = err.Error()
+		addFinding(analysis, types.SeverityCritical, "mx-lookup-failed", "",
+			fmt.Sprintf("MX lookup failed: %v", err))
+		return analysis
+	}
+
+	if len(mxs) == 0 {
+		analyzeImplicitMX(ctx, resolver, analysis, opts)
+		return analysis
+	}
+
+	sort.SliceStable(mxs, func(i, j int) bool { return mxs[i].Pref < mxs[j].Pref })
+
+	// RFC 7505: a single "0 ." record means the domain accepts no mail
+	for _, mx := range mxs {
+		if mx.Host == "." || mx.Host == "" {
+			analysis.NullMX = true
+			if mx.Pref != 0 {
+				addFinding(analysis, types.SeverityWarning, "null-mx-preference", "",
+					fmt.Sprintf("Null MX has preference %d; RFC 7505 requires 0", mx.Pref))
+			}
+		}
+	}
+	if analysis.NullMX {
+		if len(mxs) > 1 {
+			addFinding(analysis, types.SeverityCritical, "null-mx-mixed", "",
+				"Null MX is published alongside other MX records; RFC 7505 requires it to be the only MX record")
+		} else {
+			addFinding(analysis, types.SeverityInfo, "null-mx", "",
+				"Domain publishes a null MX (RFC 7505) and accepts no mail")
+			return analysis
+		}
+	}
+
+	seenHosts := make(map[string]bool)
+	for _, mx := range mxs {
+		if mx.Host == "." || mx.Host == "" {
+			continue
+		}
+		host := strings.TrimSuffix(strings.ToLower(mx.Host), ".")
+		if seenHosts[host] {
+			addFinding(analysis, types.SeverityWarning, "mx-duplicate-host", host,
+				fmt.Sprintf("%s is listed more than once", host))
+			continue
+		}
+		seenHosts[host] = true
+		analysis.Hosts = append(analysis.Hosts, analyzeMXHost(ctx, resolver, analysis, host, mx.Pref))
+	}
+
+	// Exchangers sharing a preference are load-balanced, which is often deliberate
+	preferences := make(map[uint16][]string)
+	for _, host := range analysis.Hosts {
+		preferences[host.Preference] = append(preferences[host.Preference], host.Host)
+	}
+	for _, host := range analysis.Hosts {
+		if hosts := preferences[host.Preference]; len(hosts) > 1 && hosts[0] == host.Host {
+			addFinding(analysis, types.SeverityInfo, "mx-duplicate-preference", "",
+				fmt.Sprintf("Preference %d is shared by %s; mail is spread between them", host.Preference, strings.Join(hosts, ", ")))
+		}
+	}
+
+	if len(analysis.Hosts) == 1 {
+		addFinding(analysis, types.SeverityInfo, "mx-single", analysis.Hosts[0].Host,
+			"Only one mail exchanger is published; there is no backup MX")
+	}
+
+	if !usableMX(analysis) {
+		addFinding(analysis, types.SeverityCritical, "mx-none-usable", "",
+			"None of the mail exchangers resolves to a public address")
+	}
+
+	if opts.CheckReachability {
+		checkMXReachability(ctx, analysis, opts)
+	}
+
+	analysis.AcceptsMail = usableMX(analysis)
+	return analysis
+}
+
+// analyzeImplicitMX handles a domain without MX records, which receives mail on its own A/AAAA (RFC 5321 section 5.1).
+func analyzeImplicitMX(ctx context.Context, resolver MXResolver, analysis *types.MXAnalysis, opts MXAnalysisOptions) {
+	host := analyzeMXHost(ctx, resolver, nil, analysis.Domain, 0)
+	if !host.Resolves {
+		addFinding(analysis, types.SeverityCritical, "no-mx", "",
+			"Domain has no MX records and no A/AAAA records to fall back to; it cannot receive mail")
+		return
+	}
+
+	analysis.ImplicitMX = true
+	analysis.Hosts = []types.MXHostAnalysis{host}
+	addFinding(analysis, types.SeverityWarning, "implicit-mx", "",
+		"Domain has no MX records; mail is delivered to its A/AAAA records (implicit MX)")
+	if len(host.NonPublic) > 0 {
+		addFinding(analysis, types.SeverityCritical, "mx-non-public-address", analysis.Domain,
+			fmt.Sprintf("Implicit MX resolves to non-public addresses: %s", strings.Join(host.NonPublic, ", ")))
+	}
+
+	if opts.CheckReachability {
+		checkMXReachability(ctx, analysis, opts)
+	}
+	analysis.AcceptsMail = usableMX(analysis)
+}
+
+// analyzeMXHost resolves one MX target and records its findings on analysis, when given.
+func analyzeMXHost(ctx context.Context, resolver MXResolver, analysis *types.MXAnalysis, host string, preference uint16) types.MXHostAnalysis {
+	result := types.MXHostAnalysis{Host: host, Preference: preference}
+
+	// An MX target must be a host name (RFC 5321 section 5.1); many MTAs look the literal up as a name
+	if ip := net.ParseIP(strings.Trim(host, "[]")); ip != nil {
+		result.IPLiteral = true
+		result.Resolves = true
+		result.Addresses = []string{ip.String()}
+		if !isPublicIP(ip) {
+			result.NonPublic = result.Addresses
+		}
+		if analysis != nil {
+			addFinding(analysis, types.SeverityCritical, "mx-ip-literal", host,
+				fmt.Sprintf("MX target %s is an IP address, not a host name", host))
+			addNonPublicFinding(analysis, &result)
+		}
+		return result
+	}
+
+	// An MX target must not be an alias (RFC 2181 section 10.3)
+	if cname, err := resolver.LookupCNAME(ctx, host); err == nil {
+		cname = strings.TrimSuffix(strings.ToLower(cname), ".")
+		if cname != "" && cname != host {
+			result.CNAME = cname
+			if analysis != nil {
+				addFinding(analysis, types.SeverityWarning, "mx-cname", host,
+					fmt.Sprintf("MX target %s is a CNAME to %s; MX records must point to the canonical name", host, cname))
+			}
+		}
+	}
+
+	var lastErr error
+	for _, network := range []string{"ip4", "ip6"} {
+		ips, err := resolver.LookupIP(ctx, network, host)
+		if err != nil {
+			if !isNotFound(err) {
+				lastErr = err
+			}
+			continue
+		}
+		for _, ip := range ips {
+			result.Addresses = append(result.Addresses, ip.String())
+			if !isPublicIP(ip) {
+				result.NonPublic = append(result.NonPublic, ip.String())
+			}
+		}
+	}
+	result.Resolves = len(result.Addresses) > 0
+
+	if !result.Resolves {
+		if lastErr != nil {
+			result.ResolveError = lastErr.Error()
+		} else {
+			result.ResolveError = "no A or AAAA records"
+		}
+		if analysis != nil {
+			addFinding(analysis, types.SeverityCritical, "mx-unresolvable", host,
+				fmt.Sprintf("MX target %s does not resolve: %s", host, result.ResolveError))
+		}
+		return result
+	}
+
+	if analysis != nil {
+		addNonPublicFinding(analysis, &result)
+	}
+	return result
+}
+
+// addNonPublicFinding reports an MX target resolving to addresses that cannot be reached from the Internet.
+func addNonPublicFinding(analysis *types.MXAnalysis, host *types.MXHostAnalysis) {
+	if len(host.NonPublic) == 0 {
+		return
+	}
+	severity := types.SeverityWarning
+	if len(host.NonPublic) == len(host.Addresses) {
+		severity = types.SeverityCritical
+	}
+	addFinding(analysis, severity, "mx-non-public-address", host.Host,
+		fmt.Sprintf("MX target %s resolves to non-public addresses: %s", host.Host, strings.Join(host.NonPublic, ", ")))
+}
+
+// checkMXReachability connects to every resolving exchanger; an unreachable primary is critical, an unreachable backup a warning.
+func checkMXReachability(ctx context.Context, analysis *types.MXAnalysis, opts MXAnalysisOptions) {
+	dial := opts.Dial
+	if dial == nil {
+		dialer := &net.Dialer{Timeout: opts.Timeout}
+		dial = dialer.DialContext
+	}
+
+	var wg sync.WaitGroup
+	for i := range analysis.Hosts {
+		host := &analysis.Hosts[i]
+		if !host.Resolves {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reachable, reachErr := dialAny(ctx, dial, host.Addresses, opts.Port, opts.Timeout)
+			host.Reachable = &reachable
+			if reachErr != nil {
+				host.ReachError = reachErr.Error()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(analysis.Hosts) == 0 {
+		return
+	}
+	primary := analysis.Hosts[0].Preference
+	reachableCount := 0
+	for _, host := range analysis.Hosts {
+		if host.Reachable == nil {
+			continue
+		}
+		if *host.Reachable {
+			reachableCount++
+			continue
+		}
+		if host.Preference == primary {
+			addFinding(analysis, types.SeverityCritical, "mx-unreachable", host.Host,
+				fmt.Sprintf("Primary MX %s does not accept connections on port %d: %s", host.Host, opts.Port, host.ReachError))
+		} else {
+			addFinding(analysis, types.SeverityWarning, "backup-mx-unreachable", host.Host,
+				fmt.Sprintf("Backup MX %s does not accept connections on port %d: %s", host.Host, opts.Port, host.ReachError))
+		}
+	}
+	if reachableCount == 0 {
+		addFinding(analysis, types.SeverityCritical, "mx-none-reachable", "",
+			fmt.Sprintf("No mail exchanger accepts connections on port %d", opts.Port))
+	}
+}
+
+// dialAny reports whether a TCP connection can be opened to any of the addresses.
+func dialAny(ctx context.Context, dial func(ctx context.Context, network, address string) (net.Conn, error), addresses []string, port int, timeout time.Duration) (bool, error) {
+	var lastErr error
+	for _, address := range addresses {
+		dialCtx, cancel := context.WithTimeout(ctx, timeout)
+		conn, err := dial(dialCtx, "tcp", net.JoinHostPort(address, strconv.Itoa(port)))
+		cancel()
+		if err == nil {
+			conn.Close()
+			return true, nil
+		}
+		lastErr = err
+	}
+	return false, lastErr
+}
+
+// usableMX reports whether any exchanger resolves to a public address and, when tested, is reachable.
+func usableMX(analysis *types.MXAnalysis) bool {
+	for _, host := range analysis.Hosts {
+		if !host.Resolves || len(host.NonPublic) == len(host.Addresses) {
+			continue
+		}
+		if host.Reachable != nil && !*host.Reachable {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// isPublicIP reports whether an address is routable on the Internet.
+func isPublicIP(ip net.IP) bool {
+	return !(ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsUnspecified() || ip.IsMulticast())
+}
+
+// isNotFound reports whether a lookup error means the name or record does not exist.
+func isNotFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
+
+func addFinding(analysis *types.MXAnalysis, severity, code, host, message string) {
+	analysis.Findings = append(analysis.Findings, types.Finding{
+		Severity: severity,
+		Code:     code,
+		Host:     host,
+		Message:  message,
+	})
+}
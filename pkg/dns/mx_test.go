@@ -0,0 +1,177 @@
+// Package dns provides DNS lookup functionality.
+package dns
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"mxclone/pkg/types"
+)
+
+// fakeMXResolver answers lookups from static tables; missing names are NXDOMAIN.
+type fakeMXResolver struct {
+	mx    map[string][]*net.MX
+	cname map[string]string
+	ip    map[string][]string
+}
+
+func notFound(name string) error {
+	return &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
+
+func (r *fakeMXResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	if mxs, ok := r.mx[name]; ok {
+		return mxs, nil
+	}
+	return nil, notFound(name)
+}
+
+func (r *fakeMXResolver) LookupCNAME(ctx context.Context, host string) (string, error) {
+	if cname, ok := r.cname[host]; ok {
+		return cname + ".", nil
+	}
+	return host + ".", nil
+}
+
+func (r *fakeMXResolver) LookupIP(ctx context.Context, network, host string) ([]net.IP, error) {
+	var ips []net.IP
+	for _, s := range r.ip[host] {
+		ip := net.ParseIP(s)
+		if (network == "ip4") == (ip.To4() != nil) {
+			ips = append(ips, ip)
+		}
+	}
+	if len(ips) == 0 {
+		return nil, notFound(host)
+	}
+	return ips, nil
+}
+
+func findingCodes(analysis *types.MXAnalysis) map[string]string {
+	codes := make(map[string]string)
+	for _, finding := range analysis.Findings {
+		codes[finding.Code] = finding.Severity
+	}
+	return codes
+}
+
+// TestAnalyzeMX tests MX findings and their severities
+func TestAnalyzeMX(t *testing.T) {
+	resolver := &fakeMXResolver{
+		mx: map[string][]*net.MX{
+			"null.example":  {{Host: ".", Pref: 0}},
+			"mixed.example": {{Host: ".", Pref: 0}, {Host: "mx1.example.com.", Pref: 10}},
+			"good.example":  {{Host: "mx2.example.com.", Pref: 20}, {Host: "mx1.example.com.", Pref: 10}},
+			"bad.example": {
+				{Host: "alias.example.com.", Pref: 10},
+				{Host: "192.0.2.25.", Pref: 10},
+				{Host: "internal.example.com.", Pref: 20},
+				{Host: "missing.example.com.", Pref: 30},
+				{Host: "internal.example.com.", Pref: 40},
+			},
+		},
+		cname: map[string]string{"alias.example.com": "mail.provider.example"},
+		ip: map[string][]string{
+			"mx1.example.com":      {"192.0.2.1", "2001:db8::1"},
+			"mx2.example.com":      {"198.51.100.1"},
+			"alias.example.com":    {"203.0.113.5"},
+			"internal.example.com": {"10.0.0.5", "127.0.0.1"},
+			"implicit.example":     {"203.0.113.10"},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		domain      string
+		acceptsMail bool
+		want        map[string]string
+		notWant     []string
+	}{
+		{"null MX", "null.example", false, map[string]string{"null-mx": types.SeverityInfo}, []string{"implicit-mx"}},
+		{"null MX with other records", "mixed.example", true, map[string]string{"null-mx-mixed": types.SeverityCritical}, nil},
+		{"healthy", "good.example", true, nil, []string{"mx-single", "mx-duplicate-preference", "mx-cname"}},
+		{"implicit MX", "implicit.example", true, map[string]string{"implicit-mx": types.SeverityWarning}, nil},
+		{"no mail", "nothing.example", false, map[string]string{"no-mx": types.SeverityCritical}, nil},
+		{"broken records", "bad.example", true, map[string]string{
+			"mx-cname":                types.SeverityWarning,
+			"mx-ip-literal":           types.SeverityCritical,
+			"mx-non-public-address":   types.SeverityCritical,
+			"mx-unresolvable":         types.SeverityCritical,
+			"mx-duplicate-host":       types.SeverityWarning,
+			"mx-duplicate-preference": types.SeverityInfo,
+		}, []string{"mx-none-usable"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			analysis := AnalyzeMX(context.Background(), resolver, tt.domain, MXAnalysisOptions{})
+			if analysis.AcceptsMail != tt.acceptsMail {
+				t.Errorf("AcceptsMail = %t, want %t", analysis.AcceptsMail, tt.acceptsMail)
+			}
+			codes := findingCodes(analysis)
+			for code, severity := range tt.want {
+				if codes[code] != severity {
+					t.Errorf("finding %s = %q, want %q (findings: %+v)", code, codes[code], severity, analysis.Findings)
+				}
+			}
+			for _, code := range tt.notWant {
+				if _, ok := codes[code]; ok {
+					t.Errorf("unexpected finding %s", code)
+				}
+			}
+		})
+	}
+
+	analysis := AnalyzeMX(context.Background(), resolver, "good.example", MXAnalysisOptions{})
+	if len(analysis.Hosts) != 2 || analysis.Hosts[0].Host != "mx1.example.com" || len(analysis.Hosts[0].Addresses) != 2 {
+		t.Errorf("Hosts = %+v, want mx1.example.com with two addresses first", analysis.Hosts)
+	}
+}
+
+// TestAnalyzeMXReachability tests the primary and backup MX reachability findings
+func TestAnalyzeMXReachability(t *testing.T) {
+	resolver := &fakeMXResolver{
+		mx: map[string][]*net.MX{
+			"example.com": {{Host: "mx1.example.com.", Pref: 10}, {Host: "mx2.example.com.", Pref: 20}},
+		},
+		ip: map[string][]string{
+			"mx1.example.com": {"192.0.2.1"},
+			"mx2.example.com": {"192.0.2.2"},
+		},
+	}
+
+	dial := func(up map[string]bool) func(ctx context.Context, network, address string) (net.Conn, error) {
+		return func(ctx context.Context, network, address string) (net.Conn, error) {
+			host, _, _ := net.SplitHostPort(address)
+			if !up[host] {
+				return nil, errors.New("connection refused")
+			}
+			client, server := net.Pipe()
+			server.Close()
+			return client, nil
+		}
+	}
+
+	analysis := AnalyzeMX(context.Background(), resolver, "example.com", MXAnalysisOptions{
+		CheckReachability: true,
+		Dial:              dial(map[string]bool{"192.0.2.1": true}),
+	})
+	codes := findingCodes(analysis)
+	if codes["backup-mx-unreachable"] != types.SeverityWarning || !analysis.AcceptsMail {
+		t.Errorf("backup down: findings %+v, AcceptsMail %t", analysis.Findings, analysis.AcceptsMail)
+	}
+	if analysis.Hosts[0].Reachable == nil || !*analysis.Hosts[0].Reachable {
+		t.Errorf("primary Reachable = %v, want true", analysis.Hosts[0].Reachable)
+	}
+
+	analysis = AnalyzeMX(context.Background(), resolver, "example.com", MXAnalysisOptions{
+		CheckReachability: true,
+		Dial:              dial(map[string]bool{}),
+	})
+	codes = findingCodes(analysis)
+	if codes["mx-unreachable"] != types.SeverityCritical || codes["mx-none-reachable"] != types.SeverityCritical || analysis.AcceptsMail {
+		t.Errorf("all down: findings %+v, AcceptsMail %t", analysis.Findings, analysis.AcceptsMail)
+	}
+}
@@ -0,0 +1,69 @@
+// Package dns provides DNS lookup functionality.
+package dns
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// LookupTXTStrings returns the TXT records of a domain with their character-strings kept apart,
+// unlike net.Resolver.LookupTXT which joins them. Truncated answers are retried over TCP.
+// A name without TXT records returns a *net.DNSError with IsNotFound set.
+func LookupTXTStrings(ctx context.Context, domain string, server string) ([][]string, error) {
+	// If no server is specified, use the system default
+	if server == "" {
+		config, err := dns.ClientConfigFromFile("/etc/resolv.conf")
+		if err != nil {
+			return nil, fmt.Errorf("failed to get DNS config: %w", err)
+		}
+		server = config.Servers[0] + ":" + config.Port
+	} else if !strings.Contains(server, ":") {
+		server = server + ":53"
+	}
+
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(domain), dns.TypeTXT)
+	m.RecursionDesired = true
+	m.SetEdns0(4096, false)
+
+	client := &dns.Client{Timeout: 5 * time.Second}
+	r, _, err := client.ExchangeContext(ctx, m, server)
+	if err == nil && r.Truncated {
+		client.Net = "tcp"
+		r, _, err = client.ExchangeContext(ctx, m, server)
+	}
+	if err != nil {
+		return nil, &net.DNSError{Err: err.Error(), Name: domain, Server: server, IsTimeout: isTimeout(err)}
+	}
+
+	switch r.Rcode {
+	case dns.RcodeSuccess:
+	case dns.RcodeNameError:
+		return nil, &net.DNSError{Err: "no such host", Name: domain, Server: server, IsNotFound: true}
+	default:
+		return nil, &net.DNSError{Err: "server returned " + dns.RcodeToString[r.Rcode], Name: domain, Server: server, IsTemporary: true}
+	}
+
+	var records [][]string
+	for _, answer := range r.Answer {
+		if txt, ok := answer.(*dns.TXT); ok {
+			records = append(records, txt.Txt)
+		}
+	}
+	if len(records) == 0 {
+		return nil, &net.DNSError{Err: "no TXT records", Name: domain, Server: server, IsNotFound: true}
+	}
+
+	return records, nil
+}
+
+// isTimeout reports whether a network error is a timeout.
+func isTimeout(err error) bool {
+	netErr, ok := err.(net.Error)
+	return ok && netErr.Timeout()
+}
@@ -0,0 +1,433 @@
+// Package emailauth provides functionality for checking email authentication mechanisms.
+package emailauth
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"mxclone/pkg/types"
+)
+
+// ARC chain validation results (RFC 8617 section 4.4)
+const (
+	ARCNone = "none"
+	ARCPass = "pass"
+	ARCFail = "fail"
+)
+
+// arcMaxInstances is the highest instance an ARC set may have (RFC 8617 section 4.2.1).
+const arcMaxInstances = 50
+
+// arcSet holds the ARC header fields found for one instance; more than one field of a kind breaks the chain.
+type arcSet struct {
+	seals      []headerField
+	signatures []headerField
+	results    []headerField
+}
+
+// complete reports whether the set has exactly one header field of each kind.
+func (s *arcSet) complete() bool {
+	return s != nil && len(s.seals) == 1 && len(s.signatures) == 1 && len(s.results) == 1
+}
+
+// VerifyARC validates the Authenticated Received Chain of a raw RFC 5322 message (RFC 8617 section 5.2):
+// the structure of the chain, the cv= status recorded by each sealer, the latest ARC-Message-Signature
+// and every ARC-Seal. The ARC-Authentication-Results of each intermediary are reported with its set.
+// Keys are fetched with lookup; GetDKIMRecord is used when it is nil.
+func VerifyARC(ctx context.Context, raw []byte, lookup DKIMKeyLookup, timeout time.Duration) *types.ARCValidation {
+	if lookup == nil {
+		lookup = func(ctx context.Context, domain, selector string) (string, error) {
+			return GetDKIMRecord(ctx, domain, selector, timeout)
+		}
+	}
+
+	headers, body := splitMessage(raw)
+	validation := &types.ARCValidation{Result: ARCNone}
+
+	sets := make(map[int]*arcSet)
+	var structural []string
+	for _, field := range headers {
+		switch field.name {
+		case "arc-seal", "arc-message-signature", "arc-authentication-results":
+		default:
+			continue
+		}
+		instance, err := arcInstance(field)
+		if err != nil {
+			structural = append(structural, fmt.Sprintf("%s: %v", arcHeaderName(field.name), err))
+			continue
+		}
+		set, ok := sets[instance]
+		if !ok {
+			set = &arcSet{}
+			sets[instance] = set
+		}
+		switch field.name {
+		case "arc-seal":
+			set.seals = append(set.seals, field)
+		case "arc-message-signature":
+			set.signatures = append(set.signatures, field)
+		default:
+			set.results = append(set.results, field)
+		}
+	}
+
+	if len(sets) == 0 && len(structural) == 0 {
+		validation.Reason = "the message has no ARC headers"
+		return validation
+	}
+
+	instances := make([]int, 0, len(sets))
+	for instance := range sets {
+		instances = append(instances, instance)
+	}
+	sort.Ints(instances)
+	if len(instances) > 0 {
+		validation.Instances = instances[len(instances)-1]
+	}
+
+	// Structure: instances 1 to N, each with exactly one header field of each kind
+	completeThrough := 0
+	for i := 1; i <= validation.Instances; i++ {
+		set := sets[i]
+		if set == nil {
+			structural = append(structural, fmt.Sprintf("ARC set i=%d is missing", i))
+			continue
+		}
+		for _, kind := range []struct {
+			name   string
+			fields []headerField
+		}{{"ARC-Seal", set.seals}, {"ARC-Message-Signature", set.signatures}, {"ARC-Authentication-Results", set.results}} {
+			switch {
+			case len(kind.fields) == 0:
+				structural = append(structural, fmt.Sprintf("ARC set i=%d has no %s header", i, kind.name))
+			case len(kind.fields) > 1:
+				structural = append(structural, fmt.Sprintf("ARC set i=%d has %d %s headers", i, len(kind.fields), kind.name))
+			}
+		}
+		if set.complete() && completeThrough == i-1 {
+			completeThrough = i
+		}
+	}
+
+	// Verify every set that can be verified, for the report
+	for _, instance := range instances {
+		set := sets[instance]
+		result := types.ARCSetResult{Instance: instance, SealResult: DKIMPermError}
+
+		if len(set.results) > 0 {
+			_, value, _ := strings.Cut(unfoldedValue(set.results[0]), ";")
+			parsed := ParseAuthenticationResults(value)
+			result.AuthenticationResults = &parsed
+		}
+
+		if len(set.signatures) > 0 {
+			signature := verifyARCMessageSignature(ctx, set.signatures[0], headers, body, lookup)
+			result.MessageSignature = &signature
+		}
+
+		if len(set.seals) == 0 {
+			result.SealReason = "no ARC-Seal header"
+		} else {
+			seal, err := parseARCSeal(set.seals[0])
+			if seal != nil {
+				result.SealDomain = seal.domain
+				result.SealSelector = seal.selector
+				result.SealAlgorithm = seal.algorithm
+				result.ChainValidation = seal.tags["cv"]
+				if seal.timestamp > 0 {
+					timestamp := time.Unix(seal.timestamp, 0).UTC()
+					result.SealTimestamp = &timestamp
+				}
+			}
+			switch {
+			case err != nil:
+				result.SealReason = err.Error()
+				structural = append(structural, fmt.Sprintf("ARC-Seal i=%d: %v", instance, err))
+			case completeThrough < instance:
+				result.SealReason = "the ARC sets up to this instance are incomplete, so the seal cannot be verified"
+			default:
+				result.SealResult, result.SealReason = verifyARCSeal(ctx, seal, sets, lookup)
+			}
+		}
+
+		validation.Sets = append(validation.Sets, result)
+	}
+
+	validation.Result, validation.Reason = arcChainResult(validation, structural)
+	if validation.Result == ARCPass {
+		validation.OldestPass = arcOldestPass(validation.Sets)
+	}
+	validation.Findings = arcFindings(validation, structural)
+	return validation
+}
+
+// arcInstance returns the i= instance of an ARC header field.
+func arcInstance(field headerField) (int, error) {
+	var value string
+	if field.name == "arc-authentication-results" {
+		// i=1; mx.example.net; spf=pass ...
+		instance, _, _ := strings.Cut(unfoldedValue(field), ";")
+		name, number, ok := strings.Cut(strings.TrimSpace(instance), "=")
+		if !ok || strings.TrimSpace(name) != "i" {
+			return 0, errors.New("missing instance i=")
+		}
+		value = strings.TrimSpace(number)
+	} else {
+		tags, err := parseTagList(field.value())
+		if err != nil {
+			return 0, err
+		}
+		var ok bool
+		if value, ok = tags["i"]; !ok {
+			return 0, errors.New("missing instance i=")
+		}
+	}
+
+	instance, err := strconv.Atoi(value)
+	if err != nil || instance < 1 || instance > arcMaxInstances {
+		return 0, fmt.Errorf("invalid instance i=%s", value)
+	}
+	return instance, nil
+}
+
+// arcHeaderName returns the usual spelling of a lowercase ARC header field name.
+func arcHeaderName(name string) string {
+	switch name {
+	case "arc-seal":
+		return "ARC-Seal"
+	case "arc-message-signature":
+		return "ARC-Message-Signature"
+	default:
+		return "ARC-Authentication-Results"
+	}
+}
+
+// parseARCMessageSignature parses an ARC-Message-Signature header field (RFC 8617 section 4.1.2).
+// Its tags are those of a DKIM-Signature, with the instance i= in place of v= and the agent identifier.
+func parseARCMessageSignature(field headerField) (*dkimSignature, error) {
+	tags, err := parseTagList(field.value())
+	if err != nil {
+		return nil, err
+	}
+
+	sig := &dkimSignature{
+		field:       field,
+		tags:        tags,
+		domain:      strings.ToLower(tags["d"]),
+		selector:    strings.ToLower(tags["s"]),
+		algorithm:   strings.ToLower(tags["a"]),
+		headerCanon: "simple",
+		bodyCanon:   "simple",
+		bodyLength:  -1,
+	}
+
+	for _, tag := range []string{"i", "a", "b", "bh", "d", "h", "s"} {
+		if _, ok := tags[tag]; !ok {
+			return sig, fmt.Errorf("missing required tag %s=", tag)
+		}
+	}
+	if sig.algorithm != "rsa-sha256" && sig.algorithm != "ed25519-sha256" {
+		return sig, fmt.Errorf("unsupported algorithm a=%s", sig.algorithm)
+	}
+	if err := parseSignatureTags(sig); err != nil {
+		return sig, err
+	}
+	for _, name := range sig.signedHeaders {
+		if name == "arc-seal" {
+			return sig, errors.New("h= signs ARC-Seal, which an ARC-Message-Signature must not cover")
+		}
+	}
+	if t, ok := tags["t"]; ok {
+		if sig.timestamp, err = strconv.ParseInt(t, 10, 64); err != nil || sig.timestamp < 0 {
+			return sig, fmt.Errorf("malformed timestamp t=%s", t)
+		}
+	}
+
+	return sig, nil
+}
+
+// verifyARCMessageSignature verifies an ARC-Message-Signature like a DKIM signature.
+func verifyARCMessageSignature(ctx context.Context, field headerField, headers []headerField, body []byte, lookup DKIMKeyLookup) types.DKIMSignatureResult {
+	sig, err := parseARCMessageSignature(field)
+	result := signatureResult(sig)
+	if err != nil {
+		result.Reason = err.Error()
+		return result
+	}
+
+	checkHashes(ctx, sig, headers, body, lookup, &result)
+	return result
+}
+
+// parseARCSeal parses an ARC-Seal header field (RFC 8617 section 4.1.3).
+func parseARCSeal(field headerField) (*dkimSignature, error) {
+	tags, err := parseTagList(field.value())
+	if err != nil {
+		return nil, err
+	}
+
+	seal := &dkimSignature{
+		field:       field,
+		tags:        tags,
+		domain:      strings.ToLower(tags["d"]),
+		selector:    strings.ToLower(tags["s"]),
+		algorithm:   strings.ToLower(tags["a"]),
+		headerCanon: "relaxed",
+		bodyLength:  -1,
+	}
+	if cv, ok := tags["cv"]; ok {
+		tags["cv"] = strings.ToLower(cv)
+	}
+
+	for _, tag := range []string{"i", "a", "b", "d", "s", "cv"} {
+		if _, ok := tags[tag]; !ok {
+			return seal, fmt.Errorf("missing required tag %s=", tag)
+		}
+	}
+	if _, ok := tags["h"]; ok {
+		return seal, errors.New("an ARC-Seal must not have an h= tag")
+	}
+	if seal.algorithm != "rsa-sha256" && seal.algorithm != "ed25519-sha256" {
+		return seal, fmt.Errorf("unsupported algorithm a=%s", seal.algorithm)
+	}
+	switch tags["cv"] {
+	case ARCNone, ARCPass, ARCFail:
+	default:
+		return seal, fmt.Errorf("invalid chain validation status cv=%s", tags["cv"])
+	}
+	if seal.signature, err = base64.StdEncoding.DecodeString(tags["b"]); err != nil {
+		return seal, errors.New("malformed signature b=")
+	}
+	if t, ok := tags["t"]; ok {
+		if seal.timestamp, err = strconv.ParseInt(t, 10, 64); err != nil || seal.timestamp < 0 {
+			return seal, fmt.Errorf("malformed timestamp t=%s", t)
+		}
+	}
+
+	return seal, nil
+}
+
+// writeARCSealHeaders writes what an ARC-Seal signs (RFC 8617 section 5.1.1): the ARC sets from i=1 to
+// its own instance, each as ARC-Authentication-Results, ARC-Message-Signature and ARC-Seal in relaxed
+// canonicalization, the seal being verified last and without its b= value.
+func writeARCSealHeaders(h hash.Hash, seal *dkimSignature, sets map[int]*arcSet, instance int) {
+	for i := 1; i <= instance; i++ {
+		set := sets[i]
+		h.Write([]byte(canonicalizeHeader(set.results[0].raw, "relaxed")))
+		h.Write([]byte(canonicalizeHeader(set.signatures[0].raw, "relaxed")))
+		if i < instance {
+			h.Write([]byte(canonicalizeHeader(set.seals[0].raw, "relaxed")))
+		}
+	}
+	unsigned := canonicalizeHeader(stripSignatureValue(seal.field.raw), "relaxed")
+	h.Write([]byte(strings.TrimSuffix(unsigned, "\r\n")))
+}
+
+// verifyARCSeal verifies an ARC-Seal over the ARC sets up to its instance.
+func verifyARCSeal(ctx context.Context, seal *dkimSignature, sets map[int]*arcSet, lookup DKIMKeyLookup) (string, string) {
+	instance, _ := strconv.Atoi(seal.tags["i"])
+	newHash, cryptoHash := signatureHash(seal.algorithm)
+	h := newHash()
+	writeARCSealHeaders(h, seal, sets, instance)
+
+	result, reason := checkSignature(ctx, seal, cryptoHash, h.Sum(nil), lookup)
+	if result == DKIMFail {
+		reason = "seal does not verify (ARC headers of this or an earlier instance were modified or the key does not match)"
+	}
+	return result, reason
+}
+
+// arcChainResult decides the chain validation status (RFC 8617 section 5.2).
+func arcChainResult(validation *types.ARCValidation, structural []string) (string, string) {
+	if len(structural) > 0 {
+		return ARCFail, structural[0]
+	}
+
+	sets := validation.Sets
+	latest := sets[len(sets)-1]
+	if latest.ChainValidation == ARCFail {
+		return ARCFail, fmt.Sprintf("the latest ARC-Seal (i=%d, %s) records cv=fail", latest.Instance, latest.SealDomain)
+	}
+	for _, set := range sets {
+		want := ARCPass
+		if set.Instance == 1 {
+			want = ARCNone
+		}
+		if set.ChainValidation != want {
+			return ARCFail, fmt.Sprintf("ARC-Seal i=%d (%s) has cv=%s, want cv=%s", set.Instance, set.SealDomain, set.ChainValidation, want)
+		}
+	}
+
+	if signature := latest.MessageSignature; signature.Result != DKIMPass {
+		return ARCFail, fmt.Sprintf("the latest ARC-Message-Signature (i=%d, %s) does not validate: %s", latest.Instance, signature.Domain, signature.Reason)
+	}
+	for i := len(sets) - 1; i >= 0; i-- {
+		if set := sets[i]; set.SealResult != DKIMPass {
+			return ARCFail, fmt.Sprintf("ARC-Seal i=%d (%s) does not validate: %s", set.Instance, set.SealDomain, set.SealReason)
+		}
+	}
+	return ARCPass, ""
+}
+
+// arcOldestPass returns the oldest instance from which every ARC-Message-Signature still validates,
+// or 0 when all of them do (RFC 8617 section 5.2, step 5).
+func arcOldestPass(sets []types.ARCSetResult) int {
+	for i := len(sets) - 2; i >= 0; i-- {
+		if sets[i].MessageSignature == nil || sets[i].MessageSignature.Result != DKIMPass {
+			return sets[i].Instance + 1
+		}
+	}
+	return 0
+}
+
+// arcFindings explains the chain for forwarded and mailing list messages.
+func arcFindings(validation *types.ARCValidation, structural []string) []string {
+	var findings []string
+	if len(structural) > 1 {
+		findings = append(findings, structural[1:]...)
+	}
+
+	for _, set := range validation.Sets {
+		if set.ChainValidation == ARCFail {
+			findings = append(findings, fmt.Sprintf("ARC set i=%d (%s) records that the chain had already failed when it was sealed", set.Instance, set.SealDomain))
+		}
+		if signature := set.MessageSignature; signature != nil && signature.Result == DKIMTempError {
+			findings = append(findings, fmt.Sprintf("the key of the ARC-Message-Signature i=%d could not be fetched: %s", set.Instance, signature.Reason))
+		}
+		if set.SealResult == DKIMTempError {
+			findings = append(findings, fmt.Sprintf("the key of the ARC-Seal i=%d could not be fetched: %s", set.Instance, set.SealReason))
+		}
+	}
+
+	if validation.Result == ARCPass && validation.OldestPass > 1 {
+		modified := validation.Sets[validation.OldestPass-2]
+		findings = append(findings, fmt.Sprintf("the message was modified after ARC set i=%d (%s): its ARC-Message-Signature no longer validates",
+			modified.Instance, modified.SealDomain))
+	}
+
+	if validation.Result != ARCPass || len(validation.Sets) == 0 || validation.Sets[0].AuthenticationResults == nil {
+		return findings
+	}
+	first := validation.Sets[0]
+	for _, entry := range first.AuthenticationResults.Results {
+		if entry.Method != "dmarc" {
+			continue
+		}
+		switch entry.Result {
+		case "pass":
+			findings = append(findings, fmt.Sprintf("the first intermediary (%s) saw dmarc=pass: a receiver that trusts %s can accept the message even if forwarding broke SPF or DKIM",
+				first.SealDomain, first.SealDomain))
+		case "fail":
+			findings = append(findings, fmt.Sprintf("the message had already failed DMARC when it reached the first intermediary (%s): ARC does not repair that", first.SealDomain))
+		}
+	}
+	return findings
+}
@@ -0,0 +1,236 @@
+// Package emailauth provides functionality for checking email authentication mechanisms.
+package emailauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// arcSeal adds an ARC set to a message, as an intermediary of domain would, signed and sealed with key.
+func arcSeal(t *testing.T, message string, instance int, domain, cv, results string, key crypto.Signer) string {
+	t.Helper()
+	sign := func(digest []byte) string {
+		signature, err := key.Sign(rand.Reader, digest, crypto.SHA256)
+		if err != nil {
+			t.Fatalf("Sign() error = %v", err)
+		}
+		return base64.StdEncoding.EncodeToString(signature)
+	}
+
+	message = fmt.Sprintf("ARC-Authentication-Results: i=%d; mx.%s;\r\n\t%s\r\n", instance, domain, results) + message
+	headers, body := splitMessage([]byte(message))
+	bh := sha256.Sum256(canonicalizeBody(body, "relaxed"))
+	ams := fmt.Sprintf("ARC-Message-Signature: i=%d; a=rsa-sha256; c=relaxed/relaxed; d=%s; s=arc;\r\n\th=from:to:subject:date; bh=%s;\r\n\tb=\r\n",
+		instance, domain, base64.StdEncoding.EncodeToString(bh[:]))
+	h := sha256.New()
+	writeSignedHeaders(h, &dkimSignature{
+		field:         headerField{name: "arc-message-signature", raw: ams},
+		headerCanon:   "relaxed",
+		signedHeaders: []string{"from", "to", "subject", "date"},
+	}, headers)
+	ams = strings.TrimSuffix(ams, "\r\n") + sign(h.Sum(nil)) + "\r\n"
+
+	as := fmt.Sprintf("ARC-Seal: i=%d; a=rsa-sha256; t=1709287200; cv=%s; d=%s; s=arc;\r\n\tb=\r\n", instance, cv, domain)
+	message = as + ams + message
+	headers, _ = splitMessage([]byte(message))
+	sets := make(map[int]*arcSet)
+	for _, field := range headers {
+		if !strings.HasPrefix(field.name, "arc-") {
+			continue
+		}
+		i, err := arcInstance(field)
+		if err != nil {
+			t.Fatalf("arcInstance(%q) error = %v", field.raw, err)
+		}
+		if sets[i] == nil {
+			sets[i] = &arcSet{}
+		}
+		switch field.name {
+		case "arc-seal":
+			sets[i].seals = append(sets[i].seals, field)
+		case "arc-message-signature":
+			sets[i].signatures = append(sets[i].signatures, field)
+		default:
+			sets[i].results = append(sets[i].results, field)
+		}
+	}
+	seal, err := parseARCSeal(headers[0])
+	if err != nil {
+		t.Fatalf("parseARCSeal() error = %v", err)
+	}
+	h = sha256.New()
+	writeARCSealHeaders(h, seal, sets, instance)
+
+	return strings.Replace(message, as, strings.TrimSuffix(as, "\r\n")+sign(h.Sum(nil))+"\r\n", 1)
+}
+
+// TestVerifyARC tests chains built by a mailing list and a forwarder, and the ways they break
+func TestVerifyARC(t *testing.T) {
+	listKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	forwarderKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	keys := make(map[string]string)
+	for domain, key := range map[string]*rsa.PrivateKey{"lists.example.org": listKey, "forwarder.example": forwarderKey} {
+		pub, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+		keys["arc._domainkey."+domain] = "v=DKIM1; k=rsa; p=" + base64.StdEncoding.EncodeToString(pub)
+	}
+	lookup := func(ctx context.Context, domain, selector string) (string, error) {
+		if record, ok := keys[selector+"._domainkey."+domain]; ok {
+			return record, nil
+		}
+		return "", ErrNoDKIMRecord
+	}
+
+	listResults := "dkim=pass header.d=example.com; spf=pass smtp.mailfrom=example.com; dmarc=pass header.from=example.com"
+	// The list tags the subject before sealing, which breaks the author's DKIM signature but not ARC
+	listed := arcSeal(t, strings.Replace(testMessage, "Hello", "[list] Hello", 1), 1, "lists.example.org", ARCNone, listResults, listKey)
+	forwarded := arcSeal(t, listed, 2, "forwarder.example", ARCPass, "arc=pass; dkim=fail header.d=example.com", forwarderKey)
+	// A footer is appended between the list and the forwarder
+	footer := arcSeal(t, strings.Replace(listed, "See you.", "See you.\r\n--\r\nUnsubscribe", 1), 2, "forwarder.example", ARCPass, "arc=pass", forwarderKey)
+
+	tests := []struct {
+		name       string
+		message    string
+		want       string
+		reason     string
+		oldestPass int
+		finding    string
+	}{
+		{"no ARC", testMessage, ARCNone, "no ARC headers", 0, ""},
+		{"mailing list", listed, ARCPass, "", 0, "the first intermediary (lists.example.org) saw dmarc=pass"},
+		{"list then forwarder", forwarded, ARCPass, "", 0, "saw dmarc=pass"},
+		{"modified between intermediaries", footer, ARCPass, "", 2, "modified after ARC set i=1 (lists.example.org)"},
+		{"modified after the last seal", strings.Replace(forwarded, "See you.", "See you!", 1), ARCFail, "latest ARC-Message-Signature (i=2, forwarder.example) does not validate", 0, ""},
+		{"tampered results", strings.Replace(forwarded, "spf=pass smtp.mailfrom=example.com", "spf=fail smtp.mailfrom=example.com", 1), ARCFail, "ARC-Seal i=2 (forwarder.example) does not validate", 0, ""},
+		{"wrong cv", arcSeal(t, listed, 2, "forwarder.example", ARCNone, "arc=pass", forwarderKey), ARCFail, "has cv=none, want cv=pass", 0, ""},
+		{"recorded failure", arcSeal(t, listed, 2, "forwarder.example", ARCFail, "arc=fail", forwarderKey), ARCFail, "records cv=fail", 0, ""},
+		{"missing results", strings.Replace(forwarded, "ARC-Authentication-Results: i=1;", "X-Old-Results: i=1;", 1), ARCFail, "ARC set i=1 has no ARC-Authentication-Results header", 0, ""},
+		{"missing instance", strings.ReplaceAll(forwarded, "i=2;", "i=3;"), ARCFail, "ARC set i=2 is missing", 0, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validation := VerifyARC(context.Background(), []byte(tt.message), lookup, time.Second)
+			if validation.Result != tt.want {
+				t.Fatalf("Result = %q (%s), want %q", validation.Result, validation.Reason, tt.want)
+			}
+			if !strings.Contains(validation.Reason, tt.reason) {
+				t.Errorf("Reason = %q, want it to mention %q", validation.Reason, tt.reason)
+			}
+			if validation.OldestPass != tt.oldestPass {
+				t.Errorf("OldestPass = %d, want %d", validation.OldestPass, tt.oldestPass)
+			}
+			if findings := strings.Join(validation.Findings, "\n"); !strings.Contains(findings, tt.finding) {
+				t.Errorf("Findings = %q, want them to mention %q", findings, tt.finding)
+			}
+		})
+	}
+
+	validation := VerifyARC(context.Background(), []byte(forwarded), lookup, time.Second)
+	if validation.Instances != 2 || len(validation.Sets) != 2 {
+		t.Fatalf("validation = %+v", validation)
+	}
+	first := validation.Sets[0]
+	if first.SealDomain != "lists.example.org" || first.ChainValidation != ARCNone || first.SealResult != DKIMPass ||
+		first.MessageSignature == nil || first.MessageSignature.Result != DKIMPass || first.SealTimestamp == nil {
+		t.Errorf("first set = %+v", first)
+	}
+	if first.AuthenticationResults == nil || first.AuthenticationResults.AuthServID != "mx.lists.example.org" ||
+		len(first.AuthenticationResults.Results) != 3 || first.AuthenticationResults.Results[2].Method != "dmarc" {
+		t.Errorf("first set results = %+v", first.AuthenticationResults)
+	}
+}
+
+// arcVectorMessage went through a mailing list (list.example.org, i=1) that tagged the subject and
+// added a footer, then a forwarder (example.net, i=2). The signatures were made with openssl.
+const arcVectorMessage = "ARC-Seal: i=2; a=rsa-sha256; t=1709287260; cv=pass; d=example.net; s=arc;\r\n" +
+	"\tb=hgxoll5LvIMAtHU24T0RaSEMydS3ReuEFmsUM3mFVvBB0j+VzYstK3Y7KIWxSBCwpEnLiOHweaB+t4cJJpEMbLxIOc9bHv0gi2UV0dSV2/7qVn8q51rqKfk6iySdFvJ1dYBUhNkdPJLsc2h6vQBI/MTT1HBLgYHPoJoaPEONyEanhze2GLgVTvsRUCzGxb+Si7EvNOlYpx01VSpt/yJndQyHAFbZhbou4hBwhy5R7ItPpGcBHVXhXwm1aVgTdFZbuhK02jNWODJT5vV19jY3lFnu9UFU3VCrqzhWPyGP02+xt23l2NXoRd0zcW7gWNzPDY7TwOk+i5qyBujqLESA3w==\r\n" +
+	"ARC-Message-Signature: i=2; a=rsa-sha256; c=relaxed/relaxed; d=example.net; s=arc;\r\n" +
+	"\th=from:to:subject:date:message-id;\r\n" +
+	"\tbh=Ifrr+b3Eyq/Pztjo3IjoLrHiLm2VCayH8Ba8jZL3heY=;\r\n" +
+	"\tb=Rx7/wDVewHuKGKMKJsurcyDKXVJXARZp0DMvcDS+K1HlO5MOv7N6CgDNi4nC7TrVeA/Icu+SaEV5W/L9XcKRjkSsWaPN/I2n0jv7TIn3dbtX/dZB8MhbjOg87NyncbO+lULhnDsLe2RX6YRof8xD7/gZJPojqwCEDRKhqd70pu+40IJE26RqcYBkvAJOVEP5fNF+KKi3GCA6VleDX1R6Cu3nxqB28yFXJj4FfXBjdz9DDAxm7WcHRpV/JmeNqDmtThV9tweL+kwGqy034qpCo1W9/814A84cINTnKfy3AFq8bivihSXZ+Qo23AFGI9DAC6RTrCHYtjb2G5ok0jMJuQ==\r\n" +
+	"ARC-Authentication-Results: i=2; mx.example.net;\r\n" +
+	"\tdkim=fail header.d=example.com header.s=mail;\r\n" +
+	"\tspf=fail smtp.mailfrom=list.example.org;\r\n" +
+	"\tarc=pass (i=1 d=list.example.org)\r\n" +
+	"ARC-Seal: i=1; a=rsa-sha256; t=1709287260; cv=none; d=list.example.org; s=arc;\r\n" +
+	"\tb=GS+oKFoFTENRAEFxz1rxiy66vE5Vm+wC93BVwwLK/3PVt7HPd1vQhoEp6HrXc+T+o9zzQVn5NwWRs5kvppMFO8fazicEf0TEey4IUcIJcPTQKKPAFNdOTb9fenlVV6jovNt+reNrum6b3JRT/ZrHELjT3XQKKK0Qovwcx2jW7pjiyBrBhcCTKIrKZqHT15L39SsdzqVTT2l16VbpttpGgZzNI+GeSzTJAYwrmqpd17bnT6WNzI5JBpaDQdr/VLpMffdT+pf1IYhdHmqhH9FOkzQoYncqzUqWOsu2T7Y1ORh6dGdFpmCNq+rC3IAHVzkAZ1KbJwGN3ylxrV3h2ImdrQ==\r\n" +
+	"ARC-Message-Signature: i=1; a=rsa-sha256; c=relaxed/relaxed; d=list.example.org; s=arc;\r\n" +
+	"\th=from:to:subject:date:message-id;\r\n" +
+	"\tbh=Ifrr+b3Eyq/Pztjo3IjoLrHiLm2VCayH8Ba8jZL3heY=;\r\n" +
+	"\tb=Ig4bP6YapP6o4FeowM6lJ2lU9q9dC+C1f7AbhVYZLXVCiF+U3W3IwKhbyXghOIC5kaCMtHhkT8m5sUW+ItaS30TtOoTiRW3H1cfq/dfxVSXQFR3sX2LttC9bOzX+Qi4oEu3AErzDS68wRE9TPPrDyM+jkymb0mtuflpHTTNa3ihwdVp4ipA3M9IyrQSyLyYu1oThw+v84YPOWShxoqmE8qK12UV3puA8zGY4YV8lz3IVvhPtcFJjJOtgsWHbCZ3fylALUroZBoetNAvcA8vDhb5EVYxoepGfgrVEVWk9ZQVfbhcWy7eydyyGNZmN2QXmXTeGfqfq8UwbErYnVsG1Cg==\r\n" +
+	"ARC-Authentication-Results: i=1; mx.list.example.org;\r\n" +
+	"\tdkim=pass header.d=example.com header.s=mail;\r\n" +
+	"\tspf=pass smtp.mailfrom=example.com;\r\n" +
+	"\tdmarc=pass header.from=example.com\r\n" +
+	"DKIM-Signature: v=1; t=1709287200; a=rsa-sha256; c=relaxed/relaxed; d=example.com; s=mail;\r\n" +
+	"\th=from:to:subject:date:message-id;\r\n" +
+	"\tbh=2jUSOH9NhtVGCQWNr9BrIAPreKQjO6Sn7XIkfJVOzv8=;\r\n" +
+	"\tb=kQsTw24iVK/7PY+IA67+1pDWoLyx6333RjQDIOJ72ZaDftOLaaUuE6bUiR9r1eyRps9HXPntrn5yCYTHBPxtmLyN9N3FJGljPActvRvqHriNryyqU9mphtPQpQWNdL4Xh+U95WUMQyQl0JRr+hXhCEfddb7r1HQFdmFbBXKLgi1VPkfwMOXU1nsi55B5VNE3xgxTQs9OYfIIKmS4953IMqy6UjilY0PMUKwgyXTduocshOg6gsxObkFs7rMkSBNF6ZJi6dR4oP6cUPjstn7gjy7zU4nIKGCNAlYx3W/472vImiB1n+6L8j6d+rGW3CXvn5b4Mq25VdRbERBShRklcw==\r\n" +
+	"From: Joe SixPack <joe@example.com>\r\n" +
+	"To: Football fans <fans@list.example.org>\r\n" +
+	"Subject: [fans] Is dinner ready?\r\n" +
+	"Date: Fri, 01 Mar 2024 10:00:00 +0000\r\n" +
+	"Message-ID: <20240301100000.1234@example.com>\r\n" +
+	"List-Id: <fans.list.example.org>\r\n" +
+	"\r\n" +
+	"Hi.\r\n" +
+	"\r\n" +
+	"We lost the game.  Are you hungry yet?\r\n" +
+	"\r\n" +
+	"Joe.\r\n" +
+	"--\r\n" +
+	"fans mailing list\r\n"
+
+// arcVectorKeys are the key records of the author and the two intermediaries of arcVectorMessage
+var arcVectorKeys = map[string]string{
+	"mail._domainkey.example.com":     "v=DKIM1; k=rsa; p=MIIBIjANBgkqhkiG9w0BAQEFAAOCAQ8AMIIBCgKCAQEAsKVrfJP3Uh260USBRQfSzt2JxPgGB06EE8IHU6nf8BcZ8IuTfoGpqbufvxVft4mJQqCz39IkNZHPPfncdZOGYqTOt3Bp+tcZBu8T2Q650K+2gKMUFPHmDf9txUT+MLQpC57aMPZjt2UPP03sTk+WZb07CdQ4gKLN5hF4H4pDvrBhoaZjzYap1CkDpzChVvoaSqg/6XbgfRwHnEmR6IK7Z7I3vE7btXP4v1NHpAkONyjTSwDZd8mmLYPBGFpM2Wq4VIUmDsIfcowzpqMWPyEaT9rYdauRFMwRxx7FLDjYD0wyoL/ZWrRmCItubHurE+mfx+TcTDU47cvgz1Pr7w9XFQIDAQAB",
+	"arc._domainkey.list.example.org": "v=DKIM1; k=rsa; p=MIIBIjANBgkqhkiG9w0BAQEFAAOCAQ8AMIIBCgKCAQEA0/vJUaiFvegkdKnmJtArCdVx2vTxCzZv3C+7K+5HK1jtuPdVeeVFcZst0GFEYc+VhhcWj+kZTil5qgl8UTWjRS+62aG9ESNG9oEl0QCFQtHN+5ieDdsPF8gBBFgebI2fN12AMf6M9Y30/58qycggRoAymljHWePLKy/ep0Hq8vg1gFVR/Uh+EB0dhjxRACrTRCFGAJBFyNFPOdlBGw8a5OLjC90jvr3iCFr1UMWqa65UWlyowdr56ae+Awd2W46fjr0r47mZUtkdYL7mLe5apxh339M6ZtiOYCkXaIELrbzjP3gJYRq9I06OHzCXyvQP0st5ZVmWuMTxVQ4c3KXNeQIDAQAB",
+	"arc._domainkey.example.net":      "v=DKIM1; k=rsa; p=MIIBIjANBgkqhkiG9w0BAQEFAAOCAQ8AMIIBCgKCAQEAx65JHW3ekbt9IsIpVxbNmCwGKwwx9rt0FUDdUewbVXlP4YADyBG2fVgLPrN7sW83uibT2h9QhpVgdfvo8s6XXILn33RjrTfl3GSoZQrfti8vkhk/eixKInAMKgfw5bD7uM3yEmnAIriwr2mtdVDbONPNvBogMV2Iqfz8nC93zFKPhKvTY/icMCMl7iFqiRIlPJW8EAA2Ru9wayS7Jg+cnRFcy0ZudBvcNs2mGvFmIa2hzjwOHu09AnBJUKE/ytUm7h2Imw3LHBSmoDGq3k9AIBGd0zCv3YaSkWlTJDh4Q5FcRhuoZPArNm5vBvS0tCgpGaS531F8dv/IKBAMNxoLUwIDAQAB",
+}
+
+// TestVerifyARCVector tests validation of the fixed chain of arcVectorMessage
+func TestVerifyARCVector(t *testing.T) {
+	lookup := func(ctx context.Context, domain, selector string) (string, error) {
+		if record, ok := arcVectorKeys[selector+"._domainkey."+domain]; ok {
+			return record, nil
+		}
+		return "", ErrNoDKIMRecord
+	}
+
+	validation := VerifyARC(context.Background(), []byte(arcVectorMessage), lookup, time.Second)
+	if validation.Result != ARCPass || validation.Instances != 2 || validation.OldestPass != 0 {
+		t.Fatalf("validation = %+v", validation)
+	}
+	for i, domain := range []string{"list.example.org", "example.net"} {
+		set := validation.Sets[i]
+		if set.SealDomain != domain || set.SealResult != DKIMPass || set.MessageSignature == nil || set.MessageSignature.Result != DKIMPass {
+			t.Errorf("set %d = %+v", i+1, set)
+		}
+	}
+
+	// The list's changes break the author's signature, which is why the chain is needed
+	dkim := VerifyDKIM(context.Background(), []byte(arcVectorMessage), lookup, time.Second)
+	if len(dkim) != 1 || dkim[0].Result != DKIMFail {
+		t.Errorf("VerifyDKIM() = %+v, want one failed signature", dkim)
+	}
+
+	tampered := strings.Replace(arcVectorMessage, "dkim=pass header.d=example.com", "dkim=none header.d=example.com", 1)
+	if validation := VerifyARC(context.Background(), []byte(tampered), lookup, time.Second); validation.Result != ARCFail {
+		t.Errorf("tampered results: Result = %q, want %q", validation.Result, ARCFail)
+	}
+}
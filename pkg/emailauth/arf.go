@@ -0,0 +1,399 @@
+// Package emailauth provides functionality for checking email authentication mechanisms.
+package emailauth
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"strconv"
+	"strings"
+
+	"mxclone/pkg/types"
+)
+
+// maxHumanReadableLength bounds the human-readable part kept from a feedback report
+const maxHumanReadableLength = 2000
+
+// ParseFeedbackReport parses an ARF feedback report (RFC 5965), such as a DMARC failure report
+// (RFC 6591): a multipart/report message with a human-readable part, a message/feedback-report
+// part and the original message or its headers. The report and the original message are read
+// with the same header parser as message analysis.
+func ParseFeedbackReport(raw []byte) (*types.FeedbackReport, error) {
+	headers, body := splitMessage(raw)
+	report := &types.FeedbackReport{
+		ReportFrom:    decodeHeader(firstHeaderValue(headers, "from")),
+		ReportSubject: decodeHeader(firstHeaderValue(headers, "subject")),
+		ReportDate:    firstHeaderValue(headers, "date"),
+		Fields:        make(map[string][]string),
+	}
+
+	contentType := firstHeaderValue(headers, "content-type")
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType != "multipart/report" {
+		return nil, fmt.Errorf("not an ARF report: content type is %q, not multipart/report", contentType)
+	}
+	switch reportType := strings.ToLower(params["report-type"]); reportType {
+	case "feedback-report":
+	case "":
+		report.Warnings = append(report.Warnings, "multipart/report has no report-type parameter")
+	default:
+		return nil, fmt.Errorf("not an ARF report: report-type is %s, not feedback-report", reportType)
+	}
+	if params["boundary"] == "" {
+		return nil, fmt.Errorf("multipart/report has no boundary")
+	}
+
+	foundFeedback := false
+	reader := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if foundFeedback {
+				report.Warnings = append(report.Warnings, "MIME structure is truncated: "+err.Error())
+				break
+			}
+			return nil, fmt.Errorf("invalid multipart/report: %w", err)
+		}
+		data, err := readMIMEPart(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s part: %w", part.Header.Get("Content-Type"), err)
+		}
+
+		partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		if partType == "" {
+			partType = "text/plain"
+		}
+		switch partType {
+		case "text/plain":
+			if report.HumanReadable == "" {
+				text := strings.TrimSpace(string(data))
+				if len(text) > maxHumanReadableLength {
+					text = text[:maxHumanReadableLength] + "..."
+				}
+				report.HumanReadable = text
+			}
+		case "message/feedback-report":
+			if foundFeedback {
+				report.Warnings = append(report.Warnings, "more than one message/feedback-report part; the first is used")
+				continue
+			}
+			foundFeedback = true
+			parseFeedbackFields(report, data)
+		case "message/rfc822", "text/rfc822-headers", "message/rfc822-headers":
+			if report.Original == nil {
+				report.Original = parseFeedbackOriginal(data, partType != "message/rfc822")
+			}
+		}
+	}
+
+	if !foundFeedback {
+		return nil, fmt.Errorf("not an ARF report: no message/feedback-report part")
+	}
+	if report.Original == nil {
+		report.Warnings = append(report.Warnings, "no original message or headers included")
+	}
+	return report, nil
+}
+
+// readMIMEPart reads the body of a part, decoding base64. The multipart reader already decodes
+// quoted-printable.
+func readMIMEPart(part *multipart.Part) ([]byte, error) {
+	var r io.Reader = part
+	if strings.EqualFold(strings.TrimSpace(part.Header.Get("Content-Transfer-Encoding")), "base64") {
+		r = base64.NewDecoder(base64.StdEncoding, part)
+	}
+	return io.ReadAll(io.LimitReader(r, maxDMARCReportSize))
+}
+
+// parseFeedbackFields reads the fields of the message/feedback-report part.
+func parseFeedbackFields(report *types.FeedbackReport, data []byte) {
+	fields, _ := splitMessage(data)
+	for _, field := range fields {
+		report.Fields[field.name] = append(report.Fields[field.name], unfoldedValue(field))
+	}
+	first := func(name string) string {
+		if values := report.Fields[name]; len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+
+	report.FeedbackType = strings.ToLower(first("feedback-type"))
+	report.UserAgent = first("user-agent")
+	report.Version = first("version")
+	report.AuthFailure = strings.ToLower(first("auth-failure"))
+	report.IdentityAlignment = strings.ToLower(first("identity-alignment"))
+	report.DeliveryResult = strings.ToLower(first("delivery-result"))
+	report.DKIMDomain = strings.ToLower(first("dkim-domain"))
+	report.DKIMSelector = first("dkim-selector")
+	report.DKIMIdentity = first("dkim-identity")
+	report.SPFDNS = first("spf-dns")
+	report.OriginalMailFrom = strings.Trim(first("original-mail-from"), "<>")
+	for _, rcpt := range report.Fields["original-rcpt-to"] {
+		report.OriginalRcptTo = append(report.OriginalRcptTo, strings.Trim(rcpt, "<>"))
+	}
+	for _, domain := range report.Fields["reported-domain"] {
+		report.ReportedDomain = append(report.ReportedDomain, strings.ToLower(domain))
+	}
+	for _, value := range report.Fields["authentication-results"] {
+		report.AuthenticationResults = append(report.AuthenticationResults, ParseAuthenticationResults(value))
+	}
+
+	// Reporting-MTA: dns; mx.example.net
+	mta := first("reporting-mta")
+	if _, name, ok := strings.Cut(mta, ";"); ok {
+		mta = name
+	}
+	report.ReportingMTA = strings.TrimSpace(mta)
+
+	if sourceIP := first("source-ip"); sourceIP != "" {
+		if ip := net.ParseIP(strings.Trim(sourceIP, "[]")); ip != nil {
+			report.SourceIP = ip.String()
+		} else {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("Source-IP %q is not an IP address", sourceIP))
+		}
+	}
+
+	// Received-Date is the name used by drafts preceding RFC 5965
+	arrival := first("arrival-date")
+	if arrival == "" {
+		arrival = first("received-date")
+	}
+	if arrival != "" {
+		if date, err := mail.ParseDate(arrival); err == nil {
+			report.ArrivalDate = &date
+		} else {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("Arrival-Date %q is not an RFC 5322 date", arrival))
+		}
+	}
+
+	report.Incidents = 1
+	if incidents := first("incidents"); incidents != "" {
+		if n, err := strconv.Atoi(incidents); err == nil && n > 0 {
+			report.Incidents = n
+		} else {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("Incidents %q is not a positive number", incidents))
+		}
+	}
+
+	for _, name := range []string{"feedback-type", "user-agent", "version"} {
+		if first(name) == "" {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("required %s field is missing (RFC 5965)", headerDisplayName(name)))
+		}
+	}
+	if report.FeedbackType == "auth-failure" {
+		for _, name := range []string{"auth-failure", "authentication-results"} {
+			if first(name) == "" {
+				report.Warnings = append(report.Warnings, fmt.Sprintf("required %s field of an authentication failure report is missing (RFC 6591)", headerDisplayName(name)))
+			}
+		}
+	}
+}
+
+// parseFeedbackOriginal reads the original message, or only its headers, of a report.
+func parseFeedbackOriginal(data []byte, headersOnly bool) *types.FeedbackOriginalMessage {
+	headers, _ := splitMessage(data)
+	original := &types.FeedbackOriginalMessage{
+		HeadersOnly: headersOnly,
+		From:        decodeHeader(firstHeaderValue(headers, "from")),
+		ReturnPath:  strings.Trim(firstHeaderValue(headers, "return-path"), "<>"),
+		To:          decodeHeader(firstHeaderValue(headers, "to")),
+		Subject:     decodeHeader(firstHeaderValue(headers, "subject")),
+		Date:        firstHeaderValue(headers, "date"),
+		MessageID:   firstHeaderValue(headers, "message-id"),
+		Headers:     analyzeMessageHeaders(data),
+	}
+	if domain, err := headerFromDomain(firstHeaderValue(headers, "from")); err == nil {
+		original.FromDomain = domain
+	}
+
+	for _, field := range headers {
+		switch field.name {
+		case "dkim-signature":
+			if tags, err := parseTagList(field.value()); err == nil && tags["d"] != "" {
+				original.DKIMDomains = append(original.DKIMDomains, strings.ToLower(tags["d"]))
+			}
+		case "authentication-results":
+			original.AuthenticationResults = append(original.AuthenticationResults, ParseAuthenticationResults(unfoldedValue(field)))
+		}
+	}
+	return original
+}
+
+// ParseAuthenticationResults parses an Authentication-Results header value (RFC 8601):
+// authserv-id; method=result reason="..." ptype.property=value; ...
+// Comments are dropped. Results that cannot be parsed are skipped.
+func ParseAuthenticationResults(value string) types.AuthenticationResults {
+	statements := splitAuthResultsStatements(stripHeaderComments(value))
+	results := types.AuthenticationResults{}
+	if len(statements) == 0 {
+		return results
+	}
+
+	// The authserv-id may be followed by a version
+	if fields := strings.Fields(statements[0]); len(fields) > 0 {
+		results.AuthServID = strings.ToLower(fields[0])
+	}
+
+	for _, statement := range statements[1:] {
+		tokens := tokenizeAuthResult(statement)
+		if len(tokens) == 0 || strings.EqualFold(tokens[0], "none") {
+			continue
+		}
+		method, result, ok := strings.Cut(tokens[0], "=")
+		if !ok {
+			continue
+		}
+		// method/version
+		method, _, _ = strings.Cut(method, "/")
+		entry := types.AuthResultsEntry{
+			Method: strings.ToLower(strings.TrimSpace(method)),
+			Result: strings.ToLower(strings.TrimSpace(result)),
+		}
+		for _, token := range tokens[1:] {
+			name, value, ok := strings.Cut(token, "=")
+			if !ok {
+				continue
+			}
+			name = strings.ToLower(name)
+			value = strings.Trim(value, `"`)
+			if name == "reason" {
+				entry.Reason = value
+				continue
+			}
+			if entry.Properties == nil {
+				entry.Properties = make(map[string]string)
+			}
+			entry.Properties[name] = value
+		}
+		results.Results = append(results.Results, entry)
+	}
+	return results
+}
+
+// stripHeaderComments removes the (nested) comments of a structured header value, leaving
+// quoted strings untouched.
+func stripHeaderComments(value string) string {
+	var b strings.Builder
+	depth, quoted, escaped := 0, false, false
+	for _, r := range value {
+		switch {
+		case escaped:
+			escaped = false
+			if depth == 0 {
+				b.WriteRune(r)
+			}
+			continue
+		case r == '\\':
+			escaped = true
+		case quoted:
+			if r == '"' {
+				quoted = false
+			}
+		case r == '"' && depth == 0:
+			quoted = true
+		case r == '(':
+			depth++
+			continue
+		case r == ')' && depth > 0:
+			depth--
+			b.WriteRune(' ')
+			continue
+		}
+		if depth == 0 {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// splitAuthResultsStatements splits a header value on the semicolons outside quoted strings.
+func splitAuthResultsStatements(value string) []string {
+	var statements []string
+	var b strings.Builder
+	quoted := false
+	for _, r := range value {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == ';' && !quoted:
+			statements = append(statements, strings.TrimSpace(b.String()))
+			b.Reset()
+			continue
+		}
+		b.WriteRune(r)
+	}
+	if rest := strings.TrimSpace(b.String()); rest != "" {
+		statements = append(statements, rest)
+	}
+	return statements
+}
+
+// tokenizeAuthResult splits a result statement on whitespace outside quoted strings, joining
+// "name = value" written with spaces.
+func tokenizeAuthResult(statement string) []string {
+	var tokens []string
+	var b strings.Builder
+	quoted := false
+	flush := func() {
+		if b.Len() > 0 {
+			tokens = append(tokens, b.String())
+			b.Reset()
+		}
+	}
+	for _, r := range statement {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case (r == ' ' || r == '\t' || r == '\r' || r == '\n') && !quoted:
+			flush()
+			continue
+		}
+		b.WriteRune(r)
+	}
+	flush()
+
+	// Rejoin "name", "=", "value" and "name=", "value"
+	var joined []string
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+		for (strings.HasSuffix(token, "=") || (i+1 < len(tokens) && strings.HasPrefix(tokens[i+1], "="))) && i+1 < len(tokens) {
+			i++
+			token += tokens[i]
+		}
+		joined = append(joined, token)
+	}
+	return joined
+}
+
+// firstHeaderValue returns the unfolded value of the first header field with a name.
+func firstHeaderValue(headers []headerField, name string) string {
+	for _, field := range headers {
+		if field.name == name {
+			return unfoldedValue(field)
+		}
+	}
+	return ""
+}
+
+// unfoldedValue returns a field body on a single line, without surrounding whitespace.
+func unfoldedValue(field headerField) string {
+	return strings.TrimSpace(strings.ReplaceAll(field.value(), "\r\n", ""))
+}
+
+// decodeHeader decodes RFC 2047 encoded words, keeping the raw value when they are invalid.
+func decodeHeader(value string) string {
+	decoded, err := new(mime.WordDecoder).DecodeHeader(value)
+	if err != nil {
+		return value
+	}
+	return decoded
+}
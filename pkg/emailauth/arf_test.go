@@ -0,0 +1,169 @@
+// Package emailauth provides functionality for checking email authentication mechanisms.
+package emailauth
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sampleFailureReport is a DMARC failure report (RFC 6591) with the original headers
+const sampleFailureReport = `From: DMARC Reporter <dmarc-noreply@receiver.example>
+To: ruf@example.com
+Subject: =?UTF-8?Q?Report_Domain:_example.com?=
+Date: Fri, 1 Mar 2024 10:00:00 +0000
+MIME-Version: 1.0
+Content-Type: multipart/report; report-type=feedback-report;
+    boundary="report-boundary"
+
+--report-boundary
+Content-Type: text/plain; charset="US-ASCII"
+
+This is an authentication failure report for an email message received from
+IP 192.0.2.25 on Fri, 1 Mar 2024 09:58:12 +0000.
+
+--report-boundary
+Content-Type: message/feedback-report
+
+Feedback-Type: auth-failure
+User-Agent: ReceiverFilter/2.1
+Version: 1
+Original-Mail-From: <bounce@mailer.example.net>
+Original-Rcpt-To: <alice@receiver.example>
+Original-Rcpt-To: <bob@receiver.example>
+Arrival-Date: Fri, 1 Mar 2024 09:58:12 +0000
+Source-IP: 192.0.2.25
+Reporting-MTA: dns; mx1.receiver.example
+Authentication-Results: mx1.receiver.example; dmarc=fail (p=reject dis=none)
+  header.from=example.com; spf=pass smtp.mailfrom=mailer.example.net;
+  dkim=fail reason="signature verification failed" header.d=example.com header.s=s1
+Auth-Failure: dmarc
+Delivery-Result: reject
+Reported-Domain: Example.com
+Identity-Alignment: dkim, spf
+Incidents: 3
+
+--report-boundary
+Content-Type: text/rfc822-headers
+Content-Transfer-Encoding: base64
+
+` + "%ORIGINAL%" + `
+--report-boundary--
+`
+
+const sampleOriginalHeaders = "Return-Path: <bounce@mailer.example.net>\r\n" +
+	"DKIM-Signature: v=1; a=rsa-sha256; d=Example.com; s=s1; h=from:subject; bh=AAAA; b=BBBB\r\n" +
+	"Authentication-Results: mx1.receiver.example; spf=pass smtp.mailfrom=mailer.example.net\r\n" +
+	"From: \"Example Billing\" <billing@example.com>\r\n" +
+	"To: alice@receiver.example\r\n" +
+	"Subject: Your invoice 12345 is ready\r\n" +
+	"Date: Fri, 1 Mar 2024 09:58:10 +0000\r\n" +
+	"Message-ID: <abc@mailer.example.net>\r\n"
+
+func sampleReport() string {
+	encoded := base64.StdEncoding.EncodeToString([]byte(sampleOriginalHeaders))
+	var lines []string
+	for len(encoded) > 76 {
+		lines = append(lines, encoded[:76])
+		encoded = encoded[76:]
+	}
+	lines = append(lines, encoded)
+	return strings.Replace(sampleFailureReport, "%ORIGINAL%", strings.Join(lines, "\n"), 1)
+}
+
+// TestParseFeedbackReport tests a DMARC failure report
+func TestParseFeedbackReport(t *testing.T) {
+	report, err := ParseFeedbackReport([]byte(sampleReport()))
+	if err != nil {
+		t.Fatalf("ParseFeedbackReport() error = %v", err)
+	}
+	if report.ReportSubject != "Report Domain: example.com" || !strings.HasPrefix(report.HumanReadable, "This is an authentication failure report") {
+		t.Errorf("report message = %q, %q", report.ReportSubject, report.HumanReadable)
+	}
+	if report.FeedbackType != "auth-failure" || report.AuthFailure != "dmarc" || report.DeliveryResult != "reject" ||
+		report.SourceIP != "192.0.2.25" || report.ReportingMTA != "mx1.receiver.example" || report.Incidents != 3 {
+		t.Errorf("feedback fields = %+v", report)
+	}
+	if report.OriginalMailFrom != "bounce@mailer.example.net" || strings.Join(report.OriginalRcptTo, ",") != "alice@receiver.example,bob@receiver.example" {
+		t.Errorf("envelope = %q, %v", report.OriginalMailFrom, report.OriginalRcptTo)
+	}
+	if len(report.ReportedDomain) != 1 || report.ReportedDomain[0] != "example.com" {
+		t.Errorf("ReportedDomain = %v", report.ReportedDomain)
+	}
+	if report.ArrivalDate == nil || !report.ArrivalDate.Equal(time.Date(2024, 3, 1, 9, 58, 12, 0, time.UTC)) {
+		t.Errorf("ArrivalDate = %v", report.ArrivalDate)
+	}
+	if len(report.Warnings) != 0 {
+		t.Errorf("Warnings = %v", report.Warnings)
+	}
+
+	if len(report.AuthenticationResults) != 1 {
+		t.Fatalf("AuthenticationResults = %+v", report.AuthenticationResults)
+	}
+	results := report.AuthenticationResults[0]
+	if results.AuthServID != "mx1.receiver.example" || len(results.Results) != 3 {
+		t.Fatalf("Authentication-Results = %+v", results)
+	}
+	dkim := results.Results[2]
+	if dkim.Method != "dkim" || dkim.Result != "fail" || dkim.Reason != "signature verification failed" || dkim.Properties["header.s"] != "s1" {
+		t.Errorf("dkim result = %+v", dkim)
+	}
+	if results.Results[0].Properties["header.from"] != "example.com" {
+		t.Errorf("dmarc result = %+v", results.Results[0])
+	}
+
+	original := report.Original
+	if original == nil {
+		t.Fatal("Original = nil")
+	}
+	if !original.HeadersOnly || original.FromDomain != "example.com" || original.Subject != "Your invoice 12345 is ready" ||
+		original.ReturnPath != "bounce@mailer.example.net" || original.MessageID != "<abc@mailer.example.net>" {
+		t.Errorf("original = %+v", original)
+	}
+	if len(original.DKIMDomains) != 1 || original.DKIMDomains[0] != "example.com" || len(original.AuthenticationResults) != 1 {
+		t.Errorf("original DKIM domains = %v, results = %+v", original.DKIMDomains, original.AuthenticationResults)
+	}
+	if original.Headers == nil || original.Headers.From != `"Example Billing" <billing@example.com>` {
+		t.Errorf("original headers = %+v", original.Headers)
+	}
+}
+
+// TestParseFeedbackReportErrors tests messages that are not ARF reports and incomplete reports
+func TestParseFeedbackReportErrors(t *testing.T) {
+	for name, bad := range map[string]string{
+		"plain message":   "From: a@example.com\r\nSubject: hi\r\n\r\nhello\r\n",
+		"delivery status": strings.Replace(sampleReport(), "report-type=feedback-report", "report-type=delivery-status", 1),
+		"no feedback":     strings.Replace(sampleReport(), "Content-Type: message/feedback-report", "Content-Type: text/plain", 1),
+	} {
+		if _, err := ParseFeedbackReport([]byte(bad)); err == nil {
+			t.Errorf("%s: error = nil, want an error", name)
+		}
+	}
+
+	incomplete := strings.Replace(sampleReport(), "User-Agent: ReceiverFilter/2.1\n", "", 1)
+	incomplete = strings.Replace(incomplete, "Source-IP: 192.0.2.25", "Source-IP: unknown", 1)
+	report, err := ParseFeedbackReport([]byte(incomplete))
+	if err != nil {
+		t.Fatalf("incomplete report error = %v", err)
+	}
+	if len(report.Warnings) != 2 || report.SourceIP != "" {
+		t.Errorf("incomplete report warnings = %v, SourceIP = %q", report.Warnings, report.SourceIP)
+	}
+}
+
+// TestParseAuthenticationResults tests comments, quoting and versions
+func TestParseAuthenticationResults(t *testing.T) {
+	results := ParseAuthenticationResults(`MX.Example.org 1; spf=pass (sender; "permitted") smtp.mailfrom=a.example; ` +
+		`dkim/1 = pass header.d=a.example; arc=none; none`)
+	if results.AuthServID != "mx.example.org" || len(results.Results) != 3 {
+		t.Fatalf("results = %+v", results)
+	}
+	if results.Results[0].Properties["smtp.mailfrom"] != "a.example" || results.Results[1].Method != "dkim" ||
+		results.Results[1].Result != "pass" || results.Results[1].Properties["header.d"] != "a.example" {
+		t.Errorf("results = %+v", results.Results)
+	}
+	if none := ParseAuthenticationResults("mx.example.org; none"); len(none.Results) != 0 {
+		t.Errorf("none results = %+v", none.Results)
+	}
+}
@@ -0,0 +1,361 @@
+// Package emailauth provides functionality for checking email authentication mechanisms.
+package emailauth
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"mxclone/pkg/types"
+)
+
+// BIMIDefaultSelector is the selector queried when none is given.
+const BIMIDefaultSelector = "default"
+
+// bimiMaxDownload is the largest logo or certificate file fetched, in bytes.
+const bimiMaxDownload = 1 << 20
+
+// BIMIOptions configures a BIMI check.
+type BIMIOptions struct {
+	// Resolver is used for the BIMI and DMARC lookups (default net.DefaultResolver)
+	Resolver Resolver
+	// Selector is the BIMI selector (default "default")
+	Selector string
+	// Client fetches the logo and the certificate (default an http.Client with Timeout)
+	Client *http.Client
+	// Roots are the trusted roots for the certificate chain (default the system roots)
+	Roots *x509.CertPool
+	// Timeout applies to each lookup and download (default 10 seconds)
+	Timeout time.Duration
+	// Now returns the current time, for the certificate validity (default time.Now)
+	Now func() time.Time
+}
+
+// CheckBIMI checks the BIMI setup of a domain: the assertion record at <selector>._bimi.<domain>,
+// or at the organizational domain when the domain has none, the DMARC prerequisite, the SVG logo
+// of l= against the SVG Tiny Portable/Secure profile and the VMC or CMC of a=.
+func CheckBIMI(ctx context.Context, domain string, opts BIMIOptions) *types.BIMIResult {
+	if opts.Resolver == nil {
+		opts.Resolver = net.DefaultResolver
+	}
+	if opts.Selector == "" {
+		opts.Selector = BIMIDefaultSelector
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: opts.Timeout}
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	domain = strings.TrimSuffix(strings.ToLower(domain), ".")
+	opts.Selector = strings.ToLower(opts.Selector)
+
+	result := &types.BIMIResult{Domain: domain, Selector: opts.Selector}
+
+	name, raw, err := lookupBIMIRecord(ctx, opts, domain)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.RecordName = name
+
+	record, err := ParseBIMIRecord(raw)
+	if err != nil {
+		result.Error = fmt.Sprintf("invalid BIMI record at %s: %v", name, err)
+		return result
+	}
+	result.Record = record
+
+	result.DMARC = checkBIMIDMARC(ctx, opts, domain)
+
+	var logo []byte
+	if record.LogoURL != "" {
+		result.Logo, logo = fetchBIMILogo(ctx, opts, record.LogoURL)
+	}
+	if record.AuthorityURL != "" {
+		result.Certificate = fetchBIMICertificate(ctx, opts, record.AuthorityURL, domain, logo)
+	}
+
+	result.Ready, result.Findings = bimiFindings(result)
+	return result
+}
+
+// lookupBIMIRecord returns the BIMI record of a domain and the name it was found at,
+// falling back to the organizational domain when the domain has none.
+func lookupBIMIRecord(ctx context.Context, opts BIMIOptions, domain string) (string, string, error) {
+	names := []string{opts.Selector + "._bimi." + domain}
+	if org := OrganizationalDomain(domain); org != "" && org != domain {
+		names = append(names, opts.Selector+"._bimi."+org)
+	}
+
+	for _, name := range names {
+		lookupCtx, cancel := context.WithTimeout(ctx, opts.Timeout)
+		txts, err := opts.Resolver.LookupTXT(lookupCtx, name)
+		cancel()
+		if err != nil {
+			if isNotFound(err) {
+				continue
+			}
+			return name, "", fmt.Errorf("BIMI lookup of %s failed: %v", name, err)
+		}
+
+		var records []string
+		for _, txt := range txts {
+			if strings.HasPrefix(strings.ToLower(strings.TrimSpace(txt)), "v=bimi1") {
+				records = append(records, txt)
+			}
+		}
+		switch len(records) {
+		case 0:
+			continue
+		case 1:
+			return name, records[0], nil
+		default:
+			return name, "", fmt.Errorf("%s publishes %d BIMI records; receivers ignore them all", name, len(records))
+		}
+	}
+
+	return "", "", fmt.Errorf("no BIMI record at %s", strings.Join(names, " or "))
+}
+
+// ParseBIMIRecord parses a BIMI assertion record: v=BIMI1 first, then the l=, a= and avp= tags.
+func ParseBIMIRecord(record string) (*types.BIMIRecord, error) {
+	parsed := &types.BIMIRecord{Raw: record}
+
+	parts := strings.Split(record, ";")
+	version := strings.SplitN(strings.TrimSpace(parts[0]), "=", 2)
+	if len(version) != 2 || strings.TrimSpace(version[0]) != "v" || strings.TrimSpace(version[1]) != "BIMI1" {
+		return nil, errors.New("does not start with v=BIMI1")
+	}
+
+	seen := make(map[string]bool)
+	for _, part := range parts[1:] {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("malformed tag %q", part)
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		value = strings.TrimSpace(value)
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate tag %s=", name)
+		}
+		seen[name] = true
+
+		switch name {
+		case "l":
+			if value != "" {
+				if strings.Contains(value, ",") {
+					return nil, errors.New("l= must be a single URI")
+				}
+				if err := checkBIMIURL(value); err != nil {
+					return nil, fmt.Errorf("l=%s: %v", value, err)
+				}
+			}
+			parsed.LogoURL = value
+		case "a":
+			if value != "" {
+				if err := checkBIMIURL(value); err != nil {
+					return nil, fmt.Errorf("a=%s: %v", value, err)
+				}
+			}
+			parsed.AuthorityURL = value
+		case "avp":
+			value = strings.ToLower(value)
+			if value != "brand" && value != "personal" {
+				return nil, fmt.Errorf("avp=%s is not brand or personal", value)
+			}
+			parsed.AvatarPreference = value
+		default:
+			parsed.UnknownTags = append(parsed.UnknownTags, name)
+		}
+	}
+
+	if !seen["l"] {
+		return nil, errors.New("missing l= tag")
+	}
+	parsed.Declined = parsed.LogoURL == "" && parsed.AuthorityURL == ""
+	return parsed, nil
+}
+
+// checkBIMIURL checks that a logo or evidence location is an absolute HTTPS URL.
+func checkBIMIURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "https" || u.Host == "" {
+		return errors.New("must be an https:// URL")
+	}
+	return nil
+}
+
+// checkBIMIDMARC checks the DMARC prerequisite: the policy that applies to the domain must be
+// quarantine or reject, for all of its mail (pct=100).
+func checkBIMIDMARC(ctx context.Context, opts BIMIOptions, domain string) *types.BIMIDMARCCheck {
+	lookupCtx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+	discovery := DiscoverDMARCPolicy(lookupCtx, opts.Resolver, domain)
+
+	check := &types.BIMIDMARCCheck{
+		PolicyDomain: discovery.PolicyDomain,
+		Record:       discovery.Record,
+		AppliedTag:   discovery.AppliedTag,
+		Policy:       discovery.AppliedPolicy,
+	}
+	if discovery.Error != "" {
+		check.Reason = "DMARC lookup failed: " + discovery.Error
+		return check
+	}
+	if discovery.Record == "" {
+		check.Reason = discovery.Reason
+		return check
+	}
+
+	record, err := ParseDMARCRecord(discovery.Record)
+	if err != nil {
+		check.Reason = err.Error()
+		return check
+	}
+	check.Pct = record.Pct
+
+	switch {
+	case check.Policy != "quarantine" && check.Policy != "reject":
+		check.Reason = fmt.Sprintf("%s=%s of %s does not enforce DMARC; BIMI needs quarantine or reject", check.AppliedTag, valueOrNone(check.Policy), check.PolicyDomain)
+	case check.Pct != 100:
+		check.Reason = fmt.Sprintf("pct=%d applies the policy to part of the mail only; BIMI needs pct=100", check.Pct)
+	case record.SubPolicy == "none" && check.PolicyDomain == OrganizationalDomain(domain):
+		// Receivers also refuse BIMI when the organizational domain leaves its subdomains unprotected
+		check.Reason = fmt.Sprintf("sp=none of %s leaves subdomains unenforced; BIMI needs it to be quarantine or reject", check.PolicyDomain)
+	default:
+		check.Enforced = true
+	}
+	return check
+}
+
+// valueOrNone returns a value, or "(none)" when it is empty.
+func valueOrNone(value string) string {
+	if value == "" {
+		return "(none)"
+	}
+	return value
+}
+
+// fetchBIMI downloads a BIMI logo or certificate over HTTPS.
+func fetchBIMI(ctx context.Context, opts BIMIOptions, location string) ([]byte, string, error) {
+	fetchCtx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(fetchCtx, http.MethodGet, location, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	resp, err := opts.Client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("HTTP %s", resp.Status)
+	}
+	// The final URL must still be HTTPS after redirects
+	if resp.Request != nil && resp.Request.URL.Scheme != "https" {
+		return nil, "", fmt.Errorf("redirected to %s, which is not HTTPS", resp.Request.URL)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, bimiMaxDownload+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(data) > bimiMaxDownload {
+		return nil, "", fmt.Errorf("larger than %d bytes", bimiMaxDownload)
+	}
+	return data, resp.Header.Get("Content-Type"), nil
+}
+
+// gunzipIfCompressed returns the content of gzip data, or the data itself when it is not compressed.
+func gunzipIfCompressed(data []byte) ([]byte, bool, error) {
+	if len(data) < 2 || data[0] != 0x1f || data[1] != 0x8b {
+		return data, false, nil
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, true, err
+	}
+	defer reader.Close()
+	content, err := io.ReadAll(io.LimitReader(reader, bimiMaxDownload+1))
+	if err != nil {
+		return nil, true, err
+	}
+	if len(content) > bimiMaxDownload {
+		return nil, true, fmt.Errorf("decompresses to more than %d bytes", bimiMaxDownload)
+	}
+	return content, true, nil
+}
+
+// bimiFindings reports whether every check passed and explains what stops the logo from showing.
+func bimiFindings(result *types.BIMIResult) (bool, []string) {
+	var findings []string
+	record := result.Record
+
+	if result.RecordName != "" && result.RecordName != result.Selector+"._bimi."+result.Domain {
+		findings = append(findings, fmt.Sprintf("%s has no BIMI record of its own; the record of its organizational domain (%s) applies", result.Domain, result.RecordName))
+	}
+	if record.Declined {
+		findings = append(findings, "the record declines BIMI (empty l= and a=): no logo is shown for this domain")
+		return false, findings
+	}
+	if len(record.UnknownTags) > 0 {
+		findings = append(findings, "unknown tags are ignored: "+strings.Join(record.UnknownTags, ", "))
+	}
+
+	ready := true
+	if !result.DMARC.Enforced {
+		ready = false
+		findings = append(findings, "DMARC prerequisite not met: "+result.DMARC.Reason)
+	}
+
+	if logo := result.Logo; logo != nil {
+		switch {
+		case logo.Error != "":
+			ready = false
+			findings = append(findings, "the logo could not be fetched: "+logo.Error)
+		case !logo.Valid:
+			ready = false
+			findings = append(findings, fmt.Sprintf("the logo is not a valid SVG Tiny Portable/Secure document (%d problems)", len(logo.Errors)))
+		}
+	} else if result.Certificate == nil || !result.Certificate.LogoEmbedded {
+		ready = false
+		findings = append(findings, "no logo: l= is empty and no certificate carries one")
+	}
+
+	if cert := result.Certificate; cert != nil {
+		switch {
+		case cert.Error != "":
+			ready = false
+			findings = append(findings, "the mark certificate could not be used: "+cert.Error)
+		case !cert.Valid:
+			ready = false
+			findings = append(findings, "the mark certificate is not valid: "+strings.Join(cert.Errors, "; "))
+		}
+	} else {
+		findings = append(findings, "no mark certificate (a=): Gmail and Apple Mail only show logos with a VMC or CMC")
+	}
+
+	return ready, findings
+}
@@ -0,0 +1,213 @@
+// Package emailauth provides functionality for checking email authentication mechanisms.
+package emailauth
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"mxclone/pkg/types"
+)
+
+// svgNamespace is the namespace of SVG elements.
+const svgNamespace = "http://www.w3.org/2000/svg"
+
+// bimiRecommendedLogoSize is the largest logo size recommended by the BIMI Group, in bytes.
+const bimiRecommendedLogoSize = 32 * 1024
+
+// svgTinyPSForbidden are the elements the SVG Tiny Portable/Secure profile excludes from SVG Tiny 1.2:
+// scripting, animation, interactivity, external and raster content. style is not part of SVG Tiny 1.2.
+var svgTinyPSForbidden = map[string]string{
+	"script":           "scripts",
+	"handler":          "scripts",
+	"listener":         "event listeners",
+	"animate":          "animation",
+	"animatecolor":     "animation",
+	"animatemotion":    "animation",
+	"animatetransform": "animation",
+	"set":              "animation",
+	"discard":          "animation",
+	"a":                "hyperlinks",
+	"image":            "raster or external images",
+	"foreignobject":    "foreign content",
+	"video":            "multimedia",
+	"audio":            "multimedia",
+	"animation":        "external content",
+	"iframe":           "external content",
+	"style":            "style sheets (not part of SVG Tiny 1.2)",
+}
+
+// fetchBIMILogo downloads the logo of l= and validates it. It returns the SVG document, uncompressed.
+func fetchBIMILogo(ctx context.Context, opts BIMIOptions, location string) (*types.BIMILogo, []byte) {
+	logo := &types.BIMILogo{URL: location}
+
+	data, contentType, err := fetchBIMI(ctx, opts, location)
+	if err != nil {
+		logo.Error = err.Error()
+		return logo, nil
+	}
+	logo.ContentType = contentType
+	logo.Size = len(data)
+
+	svg, compressed, err := gunzipIfCompressed(data)
+	logo.Compressed = compressed
+	if err != nil {
+		logo.Error = "invalid gzip compression: " + err.Error()
+		return logo, nil
+	}
+	sum := sha256.Sum256(svg)
+	logo.SHA256 = hex.EncodeToString(sum[:])
+
+	if mediaType, _, _ := strings.Cut(contentType, ";"); contentType != "" && strings.TrimSpace(mediaType) != "image/svg+xml" {
+		logo.Warnings = append(logo.Warnings, fmt.Sprintf("served as %s instead of image/svg+xml", contentType))
+	}
+	if logo.Size > bimiRecommendedLogoSize {
+		logo.Warnings = append(logo.Warnings, fmt.Sprintf("%d bytes; logos should not exceed %d bytes", logo.Size, bimiRecommendedLogoSize))
+	}
+
+	ValidateSVGTinyPS(svg, logo)
+	return logo, svg
+}
+
+// ValidateSVGTinyPS checks an SVG document against the SVG Tiny Portable/Secure profile required by
+// BIMI and records what it finds in logo: a root svg element with version="1.2" and
+// baseProfile="tiny-ps", no x= or y= on it, a title, and no scripts, animation, event attributes,
+// external references or other excluded content.
+func ValidateSVGTinyPS(svg []byte, logo *types.BIMILogo) {
+	decoder := xml.NewDecoder(bytes.NewReader(svg))
+	decoder.Strict = true
+
+	var depth int
+	var root, inTitle bool
+	var title strings.Builder
+	reported := make(map[string]bool)
+	addError := func(message string) {
+		if !reported[message] {
+			reported[message] = true
+			logo.Errors = append(logo.Errors, message)
+		}
+	}
+
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			addError("not well-formed XML: " + err.Error())
+			break
+		}
+
+		switch t := token.(type) {
+		case xml.Directive:
+			if strings.Contains(string(t), "ENTITY") {
+				addError("the document declares XML entities")
+			}
+		case xml.ProcInst:
+			if t.Target == "xml-stylesheet" {
+				addError("the document references a style sheet")
+			}
+		case xml.StartElement:
+			depth++
+			name := strings.ToLower(t.Name.Local)
+
+			if depth == 1 {
+				root = true
+				checkSVGRoot(t, logo, addError)
+			} else if depth == 2 && name == "title" && t.Name.Space == svgNamespace && logo.Title == "" {
+				inTitle = true
+			}
+
+			if reason, ok := svgTinyPSForbidden[name]; ok && t.Name.Space == svgNamespace {
+				addError(fmt.Sprintf("<%s> element: %s are not allowed", t.Name.Local, reason))
+			}
+			for _, attr := range t.Attr {
+				attrName := strings.ToLower(attr.Name.Local)
+				switch {
+				case strings.HasPrefix(attrName, "on"):
+					addError(fmt.Sprintf("%s attribute on <%s>: event handlers are not allowed", attr.Name.Local, t.Name.Local))
+				case attrName == "href" && !strings.HasPrefix(strings.TrimSpace(attr.Value), "#"):
+					addError(fmt.Sprintf("<%s> references %q: external references are not allowed", t.Name.Local, attr.Value))
+				case attrName == "style" && strings.Contains(strings.ToLower(attr.Value), "url(") && !strings.Contains(attr.Value, "url(#"):
+					addError(fmt.Sprintf("<%s> style references an external resource", t.Name.Local))
+				}
+			}
+		case xml.CharData:
+			if inTitle {
+				title.Write(t)
+			}
+		case xml.EndElement:
+			if inTitle && depth == 2 {
+				inTitle = false
+				logo.Title = strings.TrimSpace(title.String())
+				if logo.Title == "" {
+					addError("the <title> element is empty")
+				}
+			}
+			depth--
+		}
+	}
+
+	switch {
+	case !root:
+		addError("no svg element")
+	case logo.Title == "" && !reported["the <title> element is empty"]:
+		addError("no <title> element: the logo needs one, with the brand name, as a child of <svg>")
+	}
+	logo.Valid = len(logo.Errors) == 0
+}
+
+// checkSVGRoot checks the attributes of the root element: the svg element of the tiny-ps profile, with a
+// square viewBox and no x= or y=.
+func checkSVGRoot(root xml.StartElement, logo *types.BIMILogo, addError func(string)) {
+	if root.Name.Local != "svg" || root.Name.Space != svgNamespace {
+		addError(fmt.Sprintf("the root element is <%s>, not an <svg> element in the %s namespace", root.Name.Local, svgNamespace))
+		return
+	}
+
+	var width, height string
+	for _, attr := range root.Attr {
+		if attr.Name.Space != "" {
+			continue
+		}
+		switch attr.Name.Local {
+		case "version":
+			logo.Version = attr.Value
+		case "baseProfile":
+			logo.BaseProfile = attr.Value
+		case "viewBox":
+			logo.ViewBox = attr.Value
+		case "width":
+			width = attr.Value
+		case "height":
+			height = attr.Value
+		case "x", "y":
+			addError(fmt.Sprintf("the svg element has an %s= attribute", attr.Name.Local))
+		}
+	}
+
+	if logo.Version != "1.2" {
+		addError(fmt.Sprintf("version=%q, want \"1.2\"", logo.Version))
+	}
+	if logo.BaseProfile != "tiny-ps" {
+		addError(fmt.Sprintf("baseProfile=%q, want \"tiny-ps\"", logo.BaseProfile))
+	}
+
+	if fields := strings.Fields(strings.ReplaceAll(logo.ViewBox, ",", " ")); len(fields) == 4 {
+		w, errW := strconv.ParseFloat(fields[2], 64)
+		h, errH := strconv.ParseFloat(fields[3], 64)
+		logo.Square = errW == nil && errH == nil && w > 0 && w == h
+	} else if width != "" {
+		logo.Square = width == height
+	}
+	if !logo.Square {
+		logo.Warnings = append(logo.Warnings, "the logo is not square; mail clients crop it to a square or circle")
+	}
+}
@@ -0,0 +1,269 @@
+// Package emailauth provides functionality for checking email authentication mechanisms.
+package emailauth
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"mxclone/pkg/types"
+)
+
+const testLogo = `<?xml version="1.0" encoding="UTF-8"?>
+<svg xmlns="http://www.w3.org/2000/svg" version="1.2" baseProfile="tiny-ps" viewBox="0 0 100 100">
+  <title>Example Inc.</title>
+  <circle cx="50" cy="50" r="40" fill="#0a6"/>
+</svg>
+`
+
+const otherLogo = `<svg xmlns="http://www.w3.org/2000/svg" version="1.2" baseProfile="tiny-ps" viewBox="0 0 64 64"><title>Other</title></svg>`
+
+// testMarkCertificate issues a mark certificate for the domains with the logo embedded, and returns it
+// as PEM with the CA that issued it.
+func testMarkCertificate(t *testing.T, logo string, domains []string, notAfter time.Time) ([]byte, *x509.CertPool) {
+	t.Helper()
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test Mark Root CA"},
+		NotBefore:             time.Now().Add(-48 * time.Hour),
+		NotAfter:              time.Now().Add(10 * 365 * 24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("CreateCertificate(CA) error = %v", err)
+	}
+	ca, _ := x509.ParseCertificate(caDER)
+
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	gz.Write([]byte(logo))
+	gz.Close()
+	sum := sha256.Sum256(compressed.Bytes())
+	direct, err := asn1.MarshalWithParams(logotypeData{Image: []logotypeImage{{ImageDetails: logotypeDetails{
+		MediaType:    "image/svg+xml",
+		LogotypeHash: []hashAlgAndValue{{HashAlg: pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}}, HashValue: sum[:]}},
+		LogotypeURI:  []string{"data:image/svg+xml;base64," + base64.StdEncoding.EncodeToString(compressed.Bytes())},
+	}}}}, "tag:0")
+	if err != nil {
+		t.Fatalf("Marshal(logotype) error = %v", err)
+	}
+	subjectLogo, _ := asn1.Marshal(asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 2, IsCompound: true, Bytes: direct})
+	extension, _ := asn1.Marshal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSequence, IsCompound: true, Bytes: subjectLogo})
+
+	leafKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	leafTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject: pkix.Name{
+			CommonName:   "Example Inc.",
+			Organization: []string{"Example Inc."},
+			ExtraNames:   []pkix.AttributeTypeAndValue{{Type: oidMarkType, Value: "Registered Mark"}},
+		},
+		NotBefore:          time.Now().Add(-24 * time.Hour),
+		NotAfter:           notAfter,
+		DNSNames:           domains,
+		UnknownExtKeyUsage: []asn1.ObjectIdentifier{oidBIMIUsage},
+		ExtraExtensions:    []pkix.Extension{{Id: oidLogotype, Value: extension}},
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, leafTemplate, ca, &leafKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("CreateCertificate(leaf) error = %v", err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leafDER}), roots
+}
+
+// TestCheckBIMI tests BIMI checks against a local HTTPS server for the logos and certificates
+func TestCheckBIMI(t *testing.T) {
+	vmc, roots := testMarkCertificate(t, testLogo, []string{"example.com"}, time.Now().Add(365*24*time.Hour))
+	var compressedLogo bytes.Buffer
+	gz := gzip.NewWriter(&compressedLogo)
+	gz.Write([]byte(testLogo))
+	gz.Close()
+
+	files := map[string]string{
+		"/logo.svg":  testLogo,
+		"/logo.svgz": compressedLogo.String(),
+		"/other.svg": otherLogo,
+		"/bad.svg":   `<svg xmlns="http://www.w3.org/2000/svg" version="1.2"><script>alert(1)</script></svg>`,
+		"/vmc.pem":   string(vmc),
+	}
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if strings.Contains(r.URL.Path, ".svg") {
+			w.Header().Set("Content-Type", "image/svg+xml")
+		}
+		w.Write([]byte(content))
+	}))
+	defer server.Close()
+
+	record := func(logo string) []string {
+		return []string{"v=BIMI1; l=" + server.URL + logo + "; a=" + server.URL + "/vmc.pem"}
+	}
+	resolver := &fakeResolver{txt: map[string][]string{
+		"default._bimi.example.com":  record("/logo.svg"),
+		"_dmarc.example.com":         {"v=DMARC1; p=reject"},
+		"default._bimi.example.net":  record("/logo.svgz"),
+		"_dmarc.example.net":         {"v=DMARC1; p=quarantine; pct=50"},
+		"default._bimi.example.org":  record("/other.svg"),
+		"_dmarc.example.org":         {"v=DMARC1; p=reject"},
+		"brand._bimi.example.org":    {"v=BIMI1; l=" + server.URL + "/bad.svg"},
+		"default._bimi.example.info": {"v=BIMI1; l=; a="},
+		"_dmarc.example.info":        {"v=DMARC1; p=none"},
+	}}
+	opts := BIMIOptions{Resolver: resolver, Client: server.Client(), Roots: roots, Timeout: 5 * time.Second}
+	ctx := context.Background()
+
+	// Complete setup
+	result := CheckBIMI(ctx, "example.com", opts)
+	if result.Error != "" || !result.Ready {
+		t.Fatalf("example.com: ready = %v, error = %q, findings = %v", result.Ready, result.Error, result.Findings)
+	}
+	if !result.DMARC.Enforced || result.Logo.Title != "Example Inc." || !result.Logo.Square || !result.Logo.Valid {
+		t.Errorf("example.com: dmarc = %+v, logo = %+v", result.DMARC, result.Logo)
+	}
+	cert := result.Certificate
+	if cert.Type != "VMC" || cert.Organization != "Example Inc." || !cert.ChainValid || !cert.BIMIUsage ||
+		!cert.DomainCovered || !cert.LogoHashValid || !cert.LogoMatches || !cert.Valid || len(cert.Chain) != 2 {
+		t.Errorf("example.com: certificate = %+v", cert)
+	}
+
+	// A subdomain uses the record and policy of its organizational domain
+	result = CheckBIMI(ctx, "news.example.com", opts)
+	if !result.Ready || result.RecordName != "default._bimi.example.com" || result.DMARC.PolicyDomain != "example.com" {
+		t.Errorf("news.example.com: ready = %v, record at %q, dmarc = %+v, findings = %v", result.Ready, result.RecordName, result.DMARC, result.Findings)
+	}
+
+	// Compressed logo, but DMARC at pct=50 and a certificate for another domain
+	result = CheckBIMI(ctx, "example.net", opts)
+	if result.Ready || result.DMARC.Enforced || !strings.Contains(result.DMARC.Reason, "pct=50") {
+		t.Errorf("example.net: ready = %v, dmarc = %+v", result.Ready, result.DMARC)
+	}
+	if !result.Logo.Compressed || !result.Logo.Valid || result.Certificate.DomainCovered || !result.Certificate.LogoMatches {
+		t.Errorf("example.net: logo = %+v, certificate = %+v", result.Logo, result.Certificate)
+	}
+
+	// The logo of l= is not the one in the certificate
+	result = CheckBIMI(ctx, "example.org", opts)
+	if result.Ready || result.Certificate.LogoMatches || !strings.Contains(strings.Join(result.Certificate.Errors, "\n"), "not the logo of l=") {
+		t.Errorf("example.org: certificate = %+v", result.Certificate)
+	}
+
+	// An invalid logo without a certificate
+	brand := opts
+	brand.Selector = "brand"
+	result = CheckBIMI(ctx, "example.org", brand)
+	if result.Ready || result.Logo.Valid || result.Certificate != nil {
+		t.Errorf("brand selector: ready = %v, logo = %+v", result.Ready, result.Logo)
+	}
+	findings := strings.Join(result.Findings, "\n")
+	if !strings.Contains(findings, "not a valid SVG Tiny Portable/Secure") || !strings.Contains(findings, "no mark certificate") {
+		t.Errorf("brand selector findings = %v", result.Findings)
+	}
+
+	// Declined, and missing records
+	if result = CheckBIMI(ctx, "example.info", opts); result.Ready || result.Record == nil || !result.Record.Declined {
+		t.Errorf("example.info: ready = %v, record = %+v", result.Ready, result.Record)
+	}
+	if result = CheckBIMI(ctx, "example.test", opts); !strings.Contains(result.Error, "no BIMI record") {
+		t.Errorf("example.test error = %q", result.Error)
+	}
+
+	// Expired certificate, and a chain to an unknown root
+	expired := opts
+	expired.Now = func() time.Time { return time.Now().Add(2 * 365 * 24 * time.Hour) }
+	if result = CheckBIMI(ctx, "example.com", expired); result.Ready || !result.Certificate.Expired {
+		t.Errorf("expired: certificate = %+v", result.Certificate)
+	}
+	untrusted := opts
+	untrusted.Roots = x509.NewCertPool()
+	if result = CheckBIMI(ctx, "example.com", untrusted); result.Ready || result.Certificate.ChainValid || result.Certificate.ChainError == "" {
+		t.Errorf("untrusted: certificate = %+v", result.Certificate)
+	}
+}
+
+// TestParseBIMIRecord tests the tags of BIMI records
+func TestParseBIMIRecord(t *testing.T) {
+	record, err := ParseBIMIRecord("v=BIMI1; l=https://example.com/logo.svg; a=https://example.com/vmc.pem; avp=Brand; x=1")
+	if err != nil {
+		t.Fatalf("ParseBIMIRecord() error = %v", err)
+	}
+	if record.LogoURL != "https://example.com/logo.svg" || record.AuthorityURL != "https://example.com/vmc.pem" ||
+		record.AvatarPreference != "brand" || record.Declined || len(record.UnknownTags) != 1 {
+		t.Errorf("record = %+v", record)
+	}
+
+	for _, bad := range []string{
+		"l=https://example.com/logo.svg",
+		"v=BIMI2; l=https://example.com/logo.svg",
+		"v=BIMI1; l=http://example.com/logo.svg",
+		"v=BIMI1; l=https://example.com/a.svg,https://example.com/b.svg",
+		"v=BIMI1; l=https://example.com/logo.svg; l=https://example.com/logo.svg",
+		"v=BIMI1; a=https://example.com/vmc.pem",
+		"v=BIMI1; l=; avp=company",
+	} {
+		if _, err := ParseBIMIRecord(bad); err == nil {
+			t.Errorf("ParseBIMIRecord(%q) error = nil", bad)
+		}
+	}
+}
+
+// TestValidateSVGTinyPS tests the SVG Tiny Portable/Secure profile checks
+func TestValidateSVGTinyPS(t *testing.T) {
+	tests := []struct {
+		name  string
+		svg   string
+		error string
+	}{
+		{"valid", testLogo, ""},
+		{"wrong profile", strings.Replace(testLogo, "tiny-ps", "tiny", 1), `baseProfile="tiny"`},
+		{"no version", strings.Replace(testLogo, ` version="1.2"`, "", 1), `version=""`},
+		{"no title", strings.Replace(testLogo, "<title>Example Inc.</title>", "", 1), "no <title> element"},
+		{"x attribute", strings.Replace(testLogo, "viewBox", `x="0" viewBox`, 1), "x= attribute"},
+		{"script", strings.Replace(testLogo, "<circle", "<script>alert(1)</script><circle", 1), "<script> element"},
+		{"animation", strings.Replace(testLogo, `fill="#0a6"/>`, `fill="#0a6"><animate attributeName="r" to="10"/></circle>`, 1), "<animate> element"},
+		{"event handler", strings.Replace(testLogo, "<circle", `<circle onclick="x()"`, 1), "event handlers"},
+		{"external image", strings.Replace(testLogo, "<circle", `<use xmlns:xlink="http://www.w3.org/1999/xlink" xlink:href="https://example.com/x.svg#a"/><circle`, 1), "external references"},
+		{"not svg", `<html xmlns="http://www.w3.org/1999/xhtml"><title>x</title></html>`, "not an <svg> element"},
+		{"not xml", `<svg`, "not well-formed XML"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logo := &types.BIMILogo{}
+			ValidateSVGTinyPS([]byte(tt.svg), logo)
+			if tt.error == "" {
+				if !logo.Valid {
+					t.Errorf("Errors = %v, want none", logo.Errors)
+				}
+				return
+			}
+			if logo.Valid || !strings.Contains(strings.Join(logo.Errors, "\n"), tt.error) {
+				t.Errorf("Errors = %v, want one mentioning %q", logo.Errors, tt.error)
+			}
+		})
+	}
+}
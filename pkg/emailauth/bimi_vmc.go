@@ -0,0 +1,279 @@
+// Package emailauth provides functionality for checking email authentication mechanisms.
+package emailauth
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+	"strings"
+
+	"mxclone/pkg/types"
+)
+
+var (
+	// oidBIMIUsage is id-kp-BrandIndicatorforMessageIdentification, the extended key usage of mark certificates.
+	oidBIMIUsage = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 3, 31}
+	// oidLogotype is id-pe-logotype, the certificate extension carrying the logo (RFC 3709).
+	oidLogotype = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 12}
+	// oidMarkType is the subject attribute naming the kind of mark a certificate verifies.
+	oidMarkType = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 53087, 1, 13}
+)
+
+// logotypeHashes maps the hash algorithms of logotype hashes to their functions.
+var logotypeHashes = map[string]func() hash.Hash{
+	"1.3.14.3.2.26":          sha1.New,
+	"2.16.840.1.101.3.4.2.1": sha256.New,
+	"2.16.840.1.101.3.4.2.2": sha512.New384,
+	"2.16.840.1.101.3.4.2.3": sha512.New,
+}
+
+// logotypeData is the LogotypeData of a direct logotype (RFC 3709 section 4.1).
+type logotypeData struct {
+	Image []logotypeImage `asn1:"optional"`
+	Audio asn1.RawValue   `asn1:"optional,tag:1"`
+}
+
+// logotypeImage is a LogotypeImage; imageInfo is not used.
+type logotypeImage struct {
+	ImageDetails logotypeDetails
+	ImageInfo    asn1.RawValue `asn1:"optional"`
+}
+
+// logotypeDetails gives the media type, hashes and locations of a logotype.
+type logotypeDetails struct {
+	MediaType    string `asn1:"ia5"`
+	LogotypeHash []hashAlgAndValue
+	LogotypeURI  []string
+}
+
+// hashAlgAndValue is one hash of a logotype.
+type hashAlgAndValue struct {
+	HashAlg   pkix.AlgorithmIdentifier
+	HashValue []byte
+}
+
+// fetchBIMICertificate downloads the VMC or CMC of a= and validates it for the domain: chain, validity,
+// extended key usage, subject alternative names and the embedded logo, compared with the l= logo
+// when there is one.
+func fetchBIMICertificate(ctx context.Context, opts BIMIOptions, location, domain string, logo []byte) *types.BIMICertificate {
+	cert := &types.BIMICertificate{URL: location}
+
+	data, _, err := fetchBIMI(ctx, opts, location)
+	if err != nil {
+		cert.Error = err.Error()
+		return cert
+	}
+
+	var certs []*x509.Certificate
+	for rest := data; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		parsed, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			cert.Error = "invalid certificate: " + err.Error()
+			return cert
+		}
+		certs = append(certs, parsed)
+	}
+	if len(certs) == 0 {
+		cert.Error = "no PEM certificate found"
+		return cert
+	}
+
+	ValidateMarkCertificate(certs, opts, domain, logo, cert)
+	return cert
+}
+
+// ValidateMarkCertificate validates a mark certificate, the leaf first followed by its intermediates,
+// and records what it finds in cert. logo is the SVG of l=, or nil.
+func ValidateMarkCertificate(certs []*x509.Certificate, opts BIMIOptions, domain string, logo []byte, cert *types.BIMICertificate) {
+	leaf := certs[0]
+	cert.Subject = leaf.Subject.String()
+	if len(leaf.Subject.Organization) > 0 {
+		cert.Organization = leaf.Subject.Organization[0]
+	}
+	cert.Issuer = leaf.Issuer.String()
+	cert.SerialNumber = leaf.SerialNumber.Text(16)
+	cert.DNSNames = leaf.DNSNames
+	notBefore, notAfter := leaf.NotBefore.UTC(), leaf.NotAfter.UTC()
+	cert.NotBefore, cert.NotAfter = &notBefore, &notAfter
+
+	for _, name := range leaf.Subject.Names {
+		if name.Type.Equal(oidMarkType) {
+			cert.MarkType, _ = name.Value.(string)
+		}
+	}
+	switch cert.MarkType {
+	case "Registered Mark", "Government Mark":
+		cert.Type = "VMC"
+	case "Prior Use Mark", "Modified Registered Mark":
+		cert.Type = "CMC"
+	}
+
+	// Validity and chain
+	now := opts.Now()
+	switch {
+	case now.After(leaf.NotAfter):
+		cert.Expired = true
+		cert.Errors = append(cert.Errors, "expired on "+notAfter.Format("2006-01-02"))
+	case now.Before(leaf.NotBefore):
+		cert.Errors = append(cert.Errors, "not valid before "+notBefore.Format("2006-01-02"))
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, c := range certs[1:] {
+		intermediates.AddCert(c)
+	}
+	chains, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         opts.Roots,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		cert.ChainError = err.Error()
+		var invalid x509.CertificateInvalidError
+		if !errors.As(err, &invalid) || invalid.Reason != x509.Expired || invalid.Cert != leaf {
+			// The expiry of the leaf is already reported
+			cert.Errors = append(cert.Errors, "the certificate chain does not verify: "+err.Error())
+		}
+	} else {
+		cert.ChainValid = true
+		for _, c := range chains[0] {
+			cert.Chain = append(cert.Chain, c.Subject.String())
+		}
+	}
+
+	// Usage and names
+	for _, usage := range leaf.UnknownExtKeyUsage {
+		cert.BIMIUsage = cert.BIMIUsage || usage.Equal(oidBIMIUsage)
+	}
+	if !cert.BIMIUsage {
+		cert.Errors = append(cert.Errors, "not a mark certificate: the extended key usage id-kp-BrandIndicatorforMessageIdentification is missing")
+	}
+	org := OrganizationalDomain(domain)
+	for _, name := range leaf.DNSNames {
+		name = strings.TrimSuffix(strings.ToLower(name), ".")
+		covered := name == domain || name == org
+		if _, bimiDomain, ok := strings.Cut(name, "._bimi."); ok {
+			covered = bimiDomain == domain || bimiDomain == org
+		}
+		cert.DomainCovered = cert.DomainCovered || covered
+	}
+	if !cert.DomainCovered {
+		cert.Errors = append(cert.Errors, fmt.Sprintf("%s is not among the subject alternative names (%s)", domain, strings.Join(leaf.DNSNames, ", ")))
+	}
+
+	// Embedded logo
+	embedded, err := certificateLogo(leaf, cert)
+	switch {
+	case err != nil:
+		cert.Errors = append(cert.Errors, "logotype: "+err.Error())
+	case logo != nil:
+		cert.LogoMatches = bytes.Equal(bytes.TrimSpace(embedded), bytes.TrimSpace(logo))
+		if !cert.LogoMatches {
+			cert.Errors = append(cert.Errors, "the logo in the certificate is not the logo of l=")
+		}
+	}
+
+	cert.Valid = len(cert.Errors) == 0
+}
+
+// certificateLogo extracts the SVG logo from the logotype extension of a certificate, checking it
+// against its hash. The logo is a data: URI, base64 encoded and usually gzip-compressed.
+func certificateLogo(leaf *x509.Certificate, cert *types.BIMICertificate) ([]byte, error) {
+	var extension []byte
+	for _, ext := range leaf.Extensions {
+		if ext.Id.Equal(oidLogotype) {
+			extension = ext.Value
+		}
+	}
+	if extension == nil {
+		return nil, errors.New("the certificate has no logotype extension")
+	}
+
+	// LogotypeExtn ::= SEQUENCE { ..., subjectLogo [2] EXPLICIT LogotypeInfo OPTIONAL, ... }
+	var outer asn1.RawValue
+	if _, err := asn1.Unmarshal(extension, &outer); err != nil || outer.Tag != asn1.TagSequence {
+		return nil, errors.New("malformed logotype extension")
+	}
+	var subjectLogo []byte
+	for rest := outer.Bytes; len(rest) > 0; {
+		var field asn1.RawValue
+		var err error
+		if rest, err = asn1.Unmarshal(rest, &field); err != nil {
+			return nil, errors.New("malformed logotype extension")
+		}
+		if field.Class == asn1.ClassContextSpecific && field.Tag == 2 {
+			subjectLogo = field.Bytes
+		}
+	}
+	if subjectLogo == nil {
+		return nil, errors.New("the logotype extension has no subject logo")
+	}
+
+	// LogotypeInfo ::= CHOICE { direct [0] LogotypeData, indirect [1] LogotypeReference }
+	var info asn1.RawValue
+	if _, err := asn1.Unmarshal(subjectLogo, &info); err != nil {
+		return nil, errors.New("malformed subject logo")
+	}
+	if info.Class != asn1.ClassContextSpecific || info.Tag != 0 {
+		return nil, errors.New("the subject logo is indirect; only embedded logos are supported")
+	}
+	var data logotypeData
+	if _, err := asn1.UnmarshalWithParams(info.FullBytes, &data, "tag:0"); err != nil {
+		return nil, fmt.Errorf("malformed subject logo: %v", err)
+	}
+	if len(data.Image) == 0 || len(data.Image[0].ImageDetails.LogotypeURI) == 0 {
+		return nil, errors.New("the subject logo has no image")
+	}
+
+	details := data.Image[0].ImageDetails
+	uri := details.LogotypeURI[0]
+	header, encoded, ok := strings.Cut(uri, ",")
+	if !ok || !strings.HasPrefix(header, "data:") || !strings.HasSuffix(header, ";base64") {
+		return nil, fmt.Errorf("the logo is not embedded as a base64 data: URI (%.40s...)", uri)
+	}
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errors.New("the embedded logo is not valid base64")
+	}
+	cert.LogoEmbedded = true
+
+	for _, h := range details.LogotypeHash {
+		newHash, ok := logotypeHashes[h.HashAlg.Algorithm.String()]
+		if !ok {
+			continue
+		}
+		sum := newHash()
+		sum.Write(raw)
+		if bytes.Equal(sum.Sum(nil), h.HashValue) {
+			cert.LogoHashValid = true
+		}
+	}
+	if !cert.LogoHashValid {
+		return nil, errors.New("the embedded logo does not match its logotype hash")
+	}
+
+	svg, _, err := gunzipIfCompressed(raw)
+	if err != nil {
+		return nil, fmt.Errorf("the embedded logo is not valid gzip: %v", err)
+	}
+	return svg, nil
+}
@@ -0,0 +1,317 @@
+// Package emailauth provides functionality for checking email authentication mechanisms.
+package emailauth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"mxclone/pkg/types"
+)
+
+// Sources of a guessed selector
+const (
+	DKIMSourceCustom     = "custom"
+	DKIMSourceProvider   = "provider"
+	DKIMSourceDictionary = "dictionary"
+	DKIMSourceDated      = "dated"
+)
+
+// DKIMDiscoveryOptions configures a DKIM selector discovery.
+type DKIMDiscoveryOptions struct {
+	// Resolver is used for every lookup (default net.DefaultResolver). CNAME targets of the selectors
+	// found are only reported when it also has a LookupCNAME method.
+	Resolver Resolver
+	// Selectors are tried before the guessed ones
+	Selectors []string
+	// Concurrency is the number of lookups in flight (default 10)
+	Concurrency int
+	// Timeout applies to each lookup (default 5 seconds)
+	Timeout time.Duration
+	// Now returns the current time, for dated selectors (default time.Now)
+	Now func() time.Time
+}
+
+// cnameResolver is implemented by resolvers that can report CNAME targets, like net.Resolver.
+type cnameResolver interface {
+	LookupCNAME(ctx context.Context, host string) (string, error)
+}
+
+// dkimCandidate is a selector to try and why it was chosen.
+type dkimCandidate struct {
+	selector string
+	source   string
+	provider string // Provider detected from MX/SPF that uses the selector
+}
+
+// DiscoverDKIMSelectors probes a domain for DKIM selectors: the given ones, those of the sending
+// services detected from its MX and SPF records, a bundled dictionary and dated selectors.
+// A random selector is queried first so that a wildcard record is not reported as hits.
+func DiscoverDKIMSelectors(ctx context.Context, domain string, opts DKIMDiscoveryOptions) *types.DKIMDiscovery {
+	if opts.Resolver == nil {
+		opts.Resolver = net.DefaultResolver
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 10
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 5 * time.Second
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	domain = strings.TrimSuffix(strings.ToLower(domain), ".")
+
+	discovery := &types.DKIMDiscovery{Domain: domain}
+
+	detected := detectDKIMProviders(ctx, domain, opts)
+	for _, provider := range detected {
+		discovery.Providers = append(discovery.Providers, provider.name)
+	}
+
+	// Wildcard detection: a random selector must not have a key
+	if record, err := lookupDKIMKey(ctx, opts, randomSelector()+"._domainkey."+domain); err == nil && record != "" {
+		discovery.Wildcard = true
+		discovery.WildcardRecord = record
+	}
+
+	candidates := dkimCandidates(opts, detected)
+	discovery.Tried = len(candidates)
+
+	hits := make([]*types.DKIMSelectorHit, len(candidates))
+	errs := make([]error, len(candidates))
+	sem := make(chan struct{}, opts.Concurrency)
+	var wg sync.WaitGroup
+	for i, candidate := range candidates {
+		wg.Add(1)
+		go func(i int, candidate dkimCandidate) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			name := candidate.selector + "._domainkey." + domain
+			record, err := lookupDKIMKey(ctx, opts, name)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			if record == "" || (discovery.Wildcard && record == discovery.WildcardRecord) {
+				return
+			}
+			hits[i] = newDKIMSelectorHit(ctx, opts, candidate, name, record)
+		}(i, candidate)
+	}
+	wg.Wait()
+
+	failed := 0
+	for i, hit := range hits {
+		if hit != nil {
+			discovery.Selectors = append(discovery.Selectors, *hit)
+		}
+		if errs[i] != nil {
+			failed++
+		}
+	}
+	if ctx.Err() != nil {
+		discovery.Error = "discovery interrupted: " + ctx.Err().Error()
+	} else if failed > 0 {
+		discovery.Error = fmt.Sprintf("%d of %d lookups failed", failed, len(candidates))
+	}
+
+	return discovery
+}
+
+// detectDKIMProviders returns the providers recognized in the MX hosts and the SPF record of a domain.
+func detectDKIMProviders(ctx context.Context, domain string, opts DKIMDiscoveryOptions) []dkimProvider {
+	lookupCtx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	var mxHosts []string
+	if mxs, err := opts.Resolver.LookupMX(lookupCtx, domain); err == nil {
+		for _, mx := range mxs {
+			mxHosts = append(mxHosts, strings.TrimSuffix(strings.ToLower(mx.Host), "."))
+		}
+	}
+	var spfTargets []string
+	if txts, err := opts.Resolver.LookupTXT(lookupCtx, domain); err == nil {
+		for _, txt := range txts {
+			if !isSPFRecord(txt) {
+				continue
+			}
+			for _, term := range strings.Fields(strings.ToLower(txt)) {
+				for _, prefix := range []string{"include:", "+include:", "~include:", "?include:", "redirect="} {
+					if strings.HasPrefix(term, prefix) {
+						spfTargets = append(spfTargets, strings.TrimPrefix(term, prefix))
+					}
+				}
+			}
+		}
+	}
+
+	var detected []dkimProvider
+	for _, provider := range dkimProviders {
+		found := false
+		for _, host := range mxHosts {
+			found = found || matchesDomainSuffix(host, provider.mx)
+		}
+		for _, target := range spfTargets {
+			for _, s := range provider.spf {
+				found = found || strings.Contains(target, s)
+			}
+		}
+		if found {
+			detected = append(detected, provider)
+		}
+	}
+	return detected
+}
+
+// isSPFRecord reports whether a TXT record is an SPF record.
+func isSPFRecord(txt string) bool {
+	return strings.EqualFold(txt, "v=spf1") || strings.HasPrefix(strings.ToLower(txt), "v=spf1 ")
+}
+
+// dkimCandidates returns the selectors to try, without duplicates: custom ones, those of the
+// detected providers, the dictionary, the selectors of other providers and dated selectors.
+func dkimCandidates(opts DKIMDiscoveryOptions, detected []dkimProvider) []dkimCandidate {
+	seen := make(map[string]bool)
+	var candidates []dkimCandidate
+	add := func(selector, source, provider string) {
+		selector = strings.ToLower(strings.TrimSpace(selector))
+		if selector == "" || seen[selector] {
+			return
+		}
+		seen[selector] = true
+		candidates = append(candidates, dkimCandidate{selector: selector, source: source, provider: provider})
+	}
+
+	for _, selector := range opts.Selectors {
+		add(selector, DKIMSourceCustom, "")
+	}
+	for _, provider := range detected {
+		for _, selector := range provider.selectors {
+			add(selector, DKIMSourceProvider, provider.name)
+		}
+	}
+	for _, selector := range dkimSelectorDictionary {
+		add(selector, DKIMSourceDictionary, "")
+	}
+	for _, provider := range dkimProviders {
+		for _, selector := range provider.selectors {
+			add(selector, DKIMSourceDictionary, "")
+		}
+	}
+	for _, selector := range datedSelectors(opts.Now()) {
+		add(selector, DKIMSourceDated, "")
+	}
+	return candidates
+}
+
+// datedSelectors returns selectors named after a year (2024, s2024, dkim2024) for the last four
+// years, and after a month (202405, s202405) for the last twelve months.
+func datedSelectors(now time.Time) []string {
+	var selectors []string
+	for year := now.Year(); year > now.Year()-4; year-- {
+		selectors = append(selectors, fmt.Sprint(year), fmt.Sprintf("s%d", year), fmt.Sprintf("dkim%d", year))
+	}
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 12; i++ {
+		stamp := month.AddDate(0, -i, 0).Format("200601")
+		selectors = append(selectors, stamp, "s"+stamp)
+	}
+	return selectors
+}
+
+// lookupDKIMKey returns the key record published at name, or "" when there is none.
+// Lookups that fail for another reason than a missing name return an error.
+func lookupDKIMKey(ctx context.Context, opts DKIMDiscoveryOptions, name string) (string, error) {
+	lookupCtx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	txts, err := opts.Resolver.LookupTXT(lookupCtx, name)
+	if err != nil {
+		if isNotFound(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	for _, txt := range txts {
+		if strings.Contains(txt, "v=DKIM1") || strings.Contains(txt, "p=") {
+			return txt, nil
+		}
+	}
+	return "", nil
+}
+
+// newDKIMSelectorHit describes a selector with a key: its CNAME target, provider and key strength.
+func newDKIMSelectorHit(ctx context.Context, opts DKIMDiscoveryOptions, candidate dkimCandidate, name, record string) *types.DKIMSelectorHit {
+	hit := &types.DKIMSelectorHit{
+		Selector: candidate.selector,
+		Record:   record,
+		Source:   candidate.source,
+	}
+
+	if resolver, ok := opts.Resolver.(cnameResolver); ok {
+		lookupCtx, cancel := context.WithTimeout(ctx, opts.Timeout)
+		target, err := resolver.LookupCNAME(lookupCtx, name)
+		cancel()
+		target = strings.TrimSuffix(strings.ToLower(target), ".")
+		if err == nil && target != "" && target != name {
+			hit.CNAME = target
+		}
+	}
+
+	for _, provider := range dkimProviders {
+		if hit.CNAME != "" && matchesDomainSuffix(hit.CNAME, provider.cname) {
+			hit.Provider, hit.Attribution = provider.name, "cname"
+			break
+		}
+	}
+	if hit.Provider == "" && candidate.provider != "" {
+		hit.Provider, hit.Attribution = candidate.provider, "mx/spf"
+	}
+	if hit.Provider == "" {
+		var providers []string
+		for _, provider := range dkimProviders {
+			for _, selector := range provider.selectors {
+				if selector == candidate.selector {
+					providers = append(providers, provider.name)
+				}
+			}
+		}
+		// Only selector names that belong to a single provider identify it
+		if len(providers) == 1 {
+			hit.Provider, hit.Attribution = providers[0], "selector"
+		}
+	}
+
+	if parsed, err := ParseDKIMRecord(record); err == nil {
+		hit.KeyType = parsed.KeyType
+		hit.KeyBits = parsed.KeyBits
+		hit.KeyStrength = parsed.KeyStrength
+	}
+
+	return hit
+}
+
+// matchesDomainSuffix reports whether name is one of the domains or a subdomain of one.
+func matchesDomainSuffix(name string, domains []string) bool {
+	for _, domain := range domains {
+		if name == domain || strings.HasSuffix(name, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// randomSelector returns a selector name no one publishes, to detect wildcard records.
+func randomSelector() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return "mxclone-" + hex.EncodeToString(b)
+}
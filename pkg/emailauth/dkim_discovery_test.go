@@ -0,0 +1,174 @@
+// Package emailauth provides functionality for checking email authentication mechanisms.
+package emailauth
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// cnameFakeResolver adds CNAME answers to fakeResolver.
+type cnameFakeResolver struct {
+	*fakeResolver
+	cname map[string]string
+}
+
+func (r *cnameFakeResolver) LookupCNAME(ctx context.Context, host string) (string, error) {
+	if target, ok := r.cname[host]; ok {
+		return target + ".", nil
+	}
+	return host + ".", nil
+}
+
+// wildcardResolver answers every selector of example.com with a wildcard key, unless it has its own
+// record, and records the largest number of concurrent lookups.
+type wildcardResolver struct {
+	*fakeResolver
+	wildcard    string
+	records     map[string]string
+	mu          sync.Mutex
+	inFlight    int
+	maxInFlight int
+}
+
+func (r *wildcardResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	r.mu.Lock()
+	r.inFlight++
+	if r.inFlight > r.maxInFlight {
+		r.maxInFlight = r.inFlight
+	}
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		r.inFlight--
+		r.mu.Unlock()
+	}()
+	time.Sleep(time.Millisecond)
+
+	if record, ok := r.records[name]; ok {
+		return []string{record}, nil
+	}
+	if strings.HasSuffix(name, "._domainkey.example.com") {
+		return []string{r.wildcard}, nil
+	}
+	return r.fakeResolver.LookupTXT(ctx, name)
+}
+
+// TestDiscoverDKIMSelectors tests provider guesses, attribution and the dictionary
+func TestDiscoverDKIMSelectors(t *testing.T) {
+	key := "v=DKIM1; k=rsa; p=" + rsaKeyRecordValue(t, 2048)
+	resolver := &cnameFakeResolver{
+		fakeResolver: &fakeResolver{
+			txt: map[string][]string{
+				"example.com":                        {"v=spf1 include:spf.protection.outlook.com include:servers.mcsv.net -all"},
+				"selector1._domainkey.example.com":   {key},
+				"k1._domainkey.example.com":          {key},
+				"default._domainkey.example.com":     {"v=DKIM1; p="},
+				"s1._domainkey.example.com":          {"google-site-verification=abc"},
+				"202405._domainkey.example.com":      {key},
+				"marketing._domainkey.example.com":   {key},
+				"hs1._domainkey.example.com":         {key},
+				"fm1._domainkey.fastmail.example":    {key},
+				"google._domainkey.fastmail.example": {key},
+			},
+			mx: map[string][]string{
+				"example.com":      {"example-com.mail.protection.outlook.com"},
+				"fastmail.example": {"in1-smtp.messagingengine.com"},
+			},
+		},
+		cname: map[string]string{
+			"selector1._domainkey.example.com": "selector1-example-com._domainkey.example.onmicrosoft.com",
+			"fm1._domainkey.fastmail.example":  "fm1.fastmail.example.dkim.fmhosted.com",
+		},
+	}
+	now := func() time.Time { return time.Date(2024, 8, 15, 0, 0, 0, 0, time.UTC) }
+
+	discovery := DiscoverDKIMSelectors(context.Background(), "example.com", DKIMDiscoveryOptions{
+		Resolver:  resolver,
+		Selectors: []string{"marketing"},
+		Now:       now,
+	})
+	if strings.Join(discovery.Providers, ",") != "Microsoft 365,Mailchimp" {
+		t.Errorf("Providers = %v, want Microsoft 365 and Mailchimp", discovery.Providers)
+	}
+	if discovery.Wildcard || discovery.Error != "" {
+		t.Errorf("Wildcard = %v, Error = %q", discovery.Wildcard, discovery.Error)
+	}
+
+	type want struct{ source, provider, attribution string }
+	wants := map[string]want{
+		"marketing": {DKIMSourceCustom, "", ""},
+		"selector1": {DKIMSourceProvider, "Microsoft 365", "cname"},
+		"k1":        {DKIMSourceProvider, "Mailchimp", "mx/spf"},
+		"default":   {DKIMSourceDictionary, "", ""},
+		"hs1":       {DKIMSourceDictionary, "HubSpot", "selector"},
+		"202405":    {DKIMSourceDated, "", ""},
+	}
+	if len(discovery.Selectors) != len(wants) {
+		t.Fatalf("Selectors = %+v, want %d hits", discovery.Selectors, len(wants))
+	}
+	// Custom and provider selectors are reported first
+	if discovery.Selectors[0].Selector != "marketing" {
+		t.Errorf("first hit = %q, want the custom selector", discovery.Selectors[0].Selector)
+	}
+	for _, hit := range discovery.Selectors {
+		w, ok := wants[hit.Selector]
+		if !ok {
+			t.Errorf("unexpected hit %+v", hit)
+			continue
+		}
+		if hit.Source != w.source || hit.Provider != w.provider || hit.Attribution != w.attribution {
+			t.Errorf("hit %s = %s/%q/%s, want %s/%q/%s", hit.Selector, hit.Source, hit.Provider, hit.Attribution, w.source, w.provider, w.attribution)
+		}
+	}
+	for _, hit := range discovery.Selectors {
+		switch hit.Selector {
+		case "selector1":
+			if hit.CNAME != "selector1-example-com._domainkey.example.onmicrosoft.com" || hit.KeyBits != 2048 || hit.KeyStrength != DKIMKeyStrong {
+				t.Errorf("selector1 = %+v", hit)
+			}
+		case "default":
+			if hit.KeyStrength != DKIMKeyFailing {
+				t.Errorf("revoked default key strength = %q, want failing", hit.KeyStrength)
+			}
+		}
+	}
+
+	// Provider detection from MX and attribution by CNAME target
+	discovery = DiscoverDKIMSelectors(context.Background(), "fastmail.example", DKIMDiscoveryOptions{Resolver: resolver, Now: now})
+	if strings.Join(discovery.Providers, ",") != "Fastmail" || len(discovery.Selectors) != 2 {
+		t.Fatalf("discovery = %+v, want Fastmail with 2 hits", discovery)
+	}
+	if hit := discovery.Selectors[0]; hit.Selector != "fm1" || hit.Provider != "Fastmail" || hit.Attribution != "cname" {
+		t.Errorf("fm1 = %+v", hit)
+	}
+	// google belongs to Google Workspace by name only, which is not used by this domain
+	if hit := discovery.Selectors[1]; hit.Selector != "google" || hit.Provider != "Google Workspace" || hit.Attribution != "selector" {
+		t.Errorf("google = %+v", hit)
+	}
+}
+
+// TestDiscoverDKIMSelectorsWildcard tests that a wildcard record is not reported as hits
+func TestDiscoverDKIMSelectorsWildcard(t *testing.T) {
+	wildcard := "v=DKIM1; k=rsa; p=" + rsaKeyRecordValue(t, 1024)
+	specific := "v=DKIM1; k=rsa; p=" + rsaKeyRecordValue(t, 2048)
+	resolver := &wildcardResolver{fakeResolver: &fakeResolver{}, wildcard: wildcard, records: map[string]string{
+		"selector1._domainkey.example.com": specific,
+	}}
+
+	discovery := DiscoverDKIMSelectors(context.Background(), "example.com", DKIMDiscoveryOptions{Resolver: resolver, Concurrency: 3})
+	if !discovery.Wildcard || discovery.WildcardRecord != wildcard {
+		t.Errorf("Wildcard = %v (%q), want the wildcard record", discovery.Wildcard, discovery.WildcardRecord)
+	}
+	if len(discovery.Selectors) != 1 || discovery.Selectors[0].Selector != "selector1" {
+		t.Errorf("Selectors = %+v, want only selector1", discovery.Selectors)
+	}
+	if discovery.Tried < 150 {
+		t.Errorf("Tried = %d, want the whole dictionary", discovery.Tried)
+	}
+	if resolver.maxInFlight > 3 {
+		t.Errorf("%d lookups in flight, want at most 3", resolver.maxInFlight)
+	}
+}
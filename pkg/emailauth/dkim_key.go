@@ -0,0 +1,167 @@
+// Package emailauth provides functionality for checking email authentication mechanisms.
+package emailauth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"mxclone/pkg/types"
+)
+
+// DKIM key strengths
+const (
+	DKIMKeyStrong  = "strong"
+	DKIMKeyWeak    = "weak"
+	DKIMKeyFailing = "failing"
+)
+
+// RSA key sizes: keys under dkimMinRSABits are rejected by large receivers (RFC 8301 section 3.2),
+// keys under dkimRecommendedRSABits are weak.
+const (
+	dkimMinRSABits         = 1024
+	dkimRecommendedRSABits = 2048
+)
+
+// auditDKIMRecord decodes the public key of a parsed key record and records its type, size and
+// strength, along with findings for revoked keys, testing mode, service and hash restrictions.
+func auditDKIMRecord(dkim *DKIMRecord) {
+	tags, err := parseTagList(dkim.Raw)
+	if err != nil {
+		dkim.addFinding(types.SeverityCritical, "dkim-syntax", "malformed key record: "+err.Error())
+		dkim.KeyStrength = DKIMKeyFailing
+		return
+	}
+
+	if v, ok := tags["v"]; ok && v != "DKIM1" {
+		dkim.addFinding(types.SeverityCritical, "dkim-version", fmt.Sprintf("unsupported version v=%s; verifiers ignore the record", v))
+	}
+
+	if dkim.KeyType == "" {
+		dkim.KeyType = "rsa"
+	}
+	dkim.KeyType = strings.ToLower(dkim.KeyType)
+
+	// Testing mode: receivers must not treat failures differently from unsigned mail
+	for _, flag := range strings.Split(tags["t"], ":") {
+		if strings.TrimSpace(flag) == "y" {
+			dkim.Testing = true
+			dkim.addFinding(types.SeverityWarning, "dkim-testing", "t=y: the domain is testing DKIM; receivers may ignore failed signatures")
+		}
+	}
+
+	if s, ok := tags["s"]; ok {
+		services := strings.Split(s, ":")
+		usable := false
+		for _, service := range services {
+			usable = usable || service == "*" || service == "email"
+		}
+		if !usable {
+			dkim.addFinding(types.SeverityCritical, "dkim-service", fmt.Sprintf("s=%s restricts the key to other services; it cannot verify email", s))
+		} else if s != "*" {
+			dkim.addFinding(types.SeverityInfo, "dkim-service", fmt.Sprintf("s=%s restricts the key to the listed services", s))
+		}
+	}
+
+	if h, ok := tags["h"]; ok {
+		var unsupported []string
+		hasSHA256 := false
+		for _, alg := range strings.Split(strings.ToLower(h), ":") {
+			switch alg {
+			case "sha256":
+				hasSHA256 = true
+			case "sha1":
+			default:
+				unsupported = append(unsupported, alg)
+			}
+		}
+		if len(unsupported) > 0 {
+			dkim.addFinding(types.SeverityWarning, "dkim-hash-unsupported", fmt.Sprintf("h= lists unsupported hash algorithms: %s", strings.Join(unsupported, ", ")))
+		}
+		if !hasSHA256 {
+			dkim.addFinding(types.SeverityCritical, "dkim-hash", fmt.Sprintf("h=%s excludes sha256; signatures with the required rsa-sha256 or ed25519-sha256 fail", h))
+		}
+	}
+
+	p, ok := tags["p"]
+	switch {
+	case !ok:
+		dkim.addFinding(types.SeverityCritical, "dkim-key-missing", "the record has no p= tag")
+		dkim.KeyStrength = DKIMKeyFailing
+		return
+	case p == "":
+		dkim.Revoked = true
+		dkim.addFinding(types.SeverityCritical, "dkim-key-revoked", "the key has been revoked (empty p=)")
+		dkim.KeyStrength = DKIMKeyFailing
+		return
+	}
+
+	if dkim.KeyType != "rsa" && dkim.KeyType != "ed25519" {
+		dkim.addFinding(types.SeverityCritical, "dkim-key-type", fmt.Sprintf("unsupported key type k=%s", dkim.KeyType))
+		dkim.KeyStrength = DKIMKeyFailing
+		return
+	}
+
+	_, bits, err := decodeDKIMPublicKey(dkim.KeyType, p)
+	if err != nil {
+		dkim.addFinding(types.SeverityCritical, "dkim-key-malformed", err.Error())
+		dkim.KeyStrength = DKIMKeyFailing
+		return
+	}
+	dkim.KeyBits = bits
+
+	switch {
+	case dkim.KeyType == "rsa" && bits < dkimMinRSABits:
+		dkim.KeyStrength = DKIMKeyFailing
+		dkim.addFinding(types.SeverityCritical, "dkim-key-too-short", fmt.Sprintf("the %d-bit RSA key is under %d bits; receivers reject its signatures", bits, dkimMinRSABits))
+	case dkim.KeyType == "rsa" && bits < dkimRecommendedRSABits:
+		dkim.KeyStrength = DKIMKeyWeak
+		dkim.addFinding(types.SeverityWarning, "dkim-key-weak", fmt.Sprintf("the %d-bit RSA key is weak; use %d bits", bits, dkimRecommendedRSABits))
+	default:
+		dkim.KeyStrength = DKIMKeyStrong
+	}
+}
+
+// addFinding records a problem of the key record.
+func (dkim *DKIMRecord) addFinding(severity, code, message string) {
+	dkim.Findings = append(dkim.Findings, types.Finding{
+		Severity: severity,
+		Code:     code,
+		Message:  message,
+	})
+}
+
+// decodeDKIMPublicKey decodes the base64 p= value of a key record and returns the key and its size in bits.
+func decodeDKIMPublicKey(keyType, p string) (crypto.PublicKey, int, error) {
+	data, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(p), ""))
+	if err != nil {
+		return nil, 0, errors.New("malformed public key p= (invalid base64)")
+	}
+
+	switch strings.ToLower(keyType) {
+	case "rsa":
+		if key, err := x509.ParsePKIXPublicKey(data); err == nil {
+			if rsaKey, ok := key.(*rsa.PublicKey); ok {
+				return rsaKey, rsaKey.N.BitLen(), nil
+			}
+			return nil, 0, errors.New("p= is not an RSA key")
+		}
+		// Some signers publish the bare PKCS#1 structure
+		key, err := x509.ParsePKCS1PublicKey(data)
+		if err != nil {
+			return nil, 0, errors.New("malformed RSA public key")
+		}
+		return key, key.N.BitLen(), nil
+	case "ed25519":
+		if len(data) != ed25519.PublicKeySize {
+			return nil, 0, errors.New("malformed Ed25519 public key")
+		}
+		return ed25519.PublicKey(data), 8 * ed25519.PublicKeySize, nil
+	}
+	return nil, 0, fmt.Errorf("unsupported key type k=%s", keyType)
+}
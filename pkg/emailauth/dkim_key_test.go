@@ -0,0 +1,100 @@
+// Package emailauth provides functionality for checking email authentication mechanisms.
+package emailauth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"math/big"
+	"testing"
+
+	"mxclone/pkg/types"
+)
+
+// rsaKeyRecordValue returns the base64 SubjectPublicKeyInfo of an RSA public key with a modulus of the given size.
+func rsaKeyRecordValue(t *testing.T, bits int) string {
+	t.Helper()
+	n, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), uint(bits-1)))
+	if err != nil {
+		t.Fatalf("rand.Int() error = %v", err)
+	}
+	n.SetBit(n, bits-1, 1).SetBit(n, 0, 1)
+	der, err := x509.MarshalPKIXPublicKey(&rsa.PublicKey{N: n, E: 65537})
+	if err != nil {
+		t.Fatalf("MarshalPKIXPublicKey() error = %v", err)
+	}
+	return base64.StdEncoding.EncodeToString(der)
+}
+
+// TestAuditDKIMRecord tests key decoding, strength and the findings of key records
+func TestAuditDKIMRecord(t *testing.T) {
+	edPub, _, _ := ed25519.GenerateKey(rand.Reader)
+	ed := base64.StdEncoding.EncodeToString(edPub)
+	rsa768 := rsaKeyRecordValue(t, 768)
+	rsa1024 := rsaKeyRecordValue(t, 1024)
+	rsa2048 := rsaKeyRecordValue(t, 2048)
+
+	tests := []struct {
+		name     string
+		record   string
+		keyType  string
+		bits     int
+		strength string
+		findings map[string]string
+	}{
+		{"rsa 2048", "v=DKIM1; k=rsa; p=" + rsa2048, "rsa", 2048, DKIMKeyStrong, nil},
+		{"rsa default type", "v=DKIM1; p=" + rsa2048, "rsa", 2048, DKIMKeyStrong, nil},
+		{"rsa 1024", "v=DKIM1; k=rsa; p=" + rsa1024, "rsa", 1024, DKIMKeyWeak, map[string]string{"dkim-key-weak": types.SeverityWarning}},
+		{"rsa 768", "v=DKIM1; k=rsa; p=" + rsa768, "rsa", 768, DKIMKeyFailing, map[string]string{"dkim-key-too-short": types.SeverityCritical}},
+		{"ed25519", "v=DKIM1; k=ed25519; p=" + ed, "ed25519", 256, DKIMKeyStrong, nil},
+		{"folded key", "v=DKIM1; k=rsa; p=" + rsa2048[:100] + " " + rsa2048[100:], "rsa", 2048, DKIMKeyStrong, nil},
+		{"revoked", "v=DKIM1; k=rsa; p=", "rsa", 0, DKIMKeyFailing, map[string]string{"dkim-key-revoked": types.SeverityCritical}},
+		{"no p=", "v=DKIM1; k=rsa", "rsa", 0, DKIMKeyFailing, map[string]string{"dkim-key-missing": types.SeverityCritical}},
+		{"bad base64", "v=DKIM1; k=rsa; p=not*base64", "rsa", 0, DKIMKeyFailing, map[string]string{"dkim-key-malformed": types.SeverityCritical}},
+		{"not a key", "v=DKIM1; k=rsa; p=" + base64.StdEncoding.EncodeToString([]byte("hello")), "rsa", 0, DKIMKeyFailing, map[string]string{"dkim-key-malformed": types.SeverityCritical}},
+		{"unknown key type", "v=DKIM1; k=dsa; p=" + rsa2048, "dsa", 0, DKIMKeyFailing, map[string]string{"dkim-key-type": types.SeverityCritical}},
+		{"testing mode", "v=DKIM1; t=y:s; p=" + ed + "; k=ed25519", "ed25519", 256, DKIMKeyStrong, map[string]string{"dkim-testing": types.SeverityWarning}},
+		{"email service", "v=DKIM1; s=email; p=" + rsa2048, "rsa", 2048, DKIMKeyStrong, map[string]string{"dkim-service": types.SeverityInfo}},
+		{"other service", "v=DKIM1; s=tlsrpt; p=" + rsa2048, "rsa", 2048, DKIMKeyStrong, map[string]string{"dkim-service": types.SeverityCritical}},
+		{"sha1 only", "v=DKIM1; h=sha1; p=" + rsa2048, "rsa", 2048, DKIMKeyStrong, map[string]string{"dkim-hash": types.SeverityCritical}},
+		{"unknown hash", "v=DKIM1; h=sha256:md5; p=" + rsa2048, "rsa", 2048, DKIMKeyStrong, map[string]string{"dkim-hash-unsupported": types.SeverityWarning}},
+		{"duplicate tag", "v=DKIM1; p=" + rsa2048 + "; p=", "rsa", 0, DKIMKeyFailing, map[string]string{"dkim-syntax": types.SeverityCritical}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record, err := ParseDKIMRecord(tt.record)
+			if err != nil {
+				t.Fatalf("ParseDKIMRecord() error = %v", err)
+			}
+			if tt.findings["dkim-syntax"] == "" && (record.KeyType != tt.keyType || record.KeyBits != tt.bits) {
+				t.Errorf("KeyType = %q, KeyBits = %d, want %q, %d", record.KeyType, record.KeyBits, tt.keyType, tt.bits)
+			}
+			if record.KeyStrength != tt.strength {
+				t.Errorf("KeyStrength = %q, want %q", record.KeyStrength, tt.strength)
+			}
+
+			codes := make(map[string]string)
+			for _, finding := range record.Findings {
+				codes[finding.Code] = finding.Severity
+			}
+			for code, severity := range tt.findings {
+				if codes[code] != severity {
+					t.Errorf("finding %s = %q, want %q (findings: %+v)", code, codes[code], severity, record.Findings)
+				}
+			}
+			if tt.findings == nil && len(record.Findings) > 0 {
+				t.Errorf("unexpected findings %+v", record.Findings)
+			}
+		})
+	}
+
+	if record, _ := ParseDKIMRecord("v=DKIM1; p="); !record.Revoked {
+		t.Errorf("Revoked = false for an empty p=")
+	}
+	if record, _ := ParseDKIMRecord("v=DKIM1; t=y; p=" + ed + "; k=ed25519"); !record.Testing {
+		t.Errorf("Testing = false for t=y")
+	}
+}
@@ -0,0 +1,208 @@
+// Package emailauth provides functionality for checking email authentication mechanisms.
+package emailauth
+
+// dkimProvider describes how a sending service publishes DKIM keys and how to recognize it
+// in the MX and SPF records of a domain or in the CNAME target of a delegated selector.
+type dkimProvider struct {
+	name      string
+	mx        []string // MX host suffixes
+	spf       []string // Substrings of SPF include: or redirect= targets
+	cname     []string // Suffixes of CNAME targets of delegated selectors
+	selectors []string
+}
+
+// dkimProviders are the sending services whose selectors can be guessed.
+var dkimProviders = []dkimProvider{
+	{
+		name:      "Google Workspace",
+		mx:        []string{"google.com", "googlemail.com"},
+		spf:       []string{"_spf.google.com"},
+		selectors: []string{"google", "google2048", "20161025", "20210112", "20221208", "20230601"},
+	},
+	{
+		name:      "Microsoft 365",
+		mx:        []string{"mail.protection.outlook.com"},
+		spf:       []string{"spf.protection.outlook.com"},
+		cname:     []string{"onmicrosoft.com"},
+		selectors: []string{"selector1", "selector2"},
+	},
+	{
+		name:      "Amazon SES",
+		mx:        []string{"amazonaws.com"},
+		spf:       []string{"amazonses.com"},
+		cname:     []string{"dkim.amazonses.com"},
+		selectors: []string{"amazonses"},
+	},
+	{
+		name:      "Mailchimp",
+		spf:       []string{"servers.mcsv.net", "spf.mandrillapp.com"},
+		cname:     []string{"dkim.mcsv.net", "mandrillapp.com"},
+		selectors: []string{"k1", "k2", "k3", "mandrill", "mte1", "mte2"},
+	},
+	{
+		name:      "SendGrid",
+		spf:       []string{"sendgrid.net"},
+		cname:     []string{"sendgrid.net"},
+		selectors: []string{"s1", "s2", "smtpapi", "em", "m1"},
+	},
+	{
+		name:      "Mailgun",
+		mx:        []string{"mailgun.org"},
+		spf:       []string{"mailgun.org"},
+		cname:     []string{"mailgun.org"},
+		selectors: []string{"mailo", "mg", "krs", "pic", "smtp", "k1", "mx", "email"},
+	},
+	{
+		name:      "Postmark",
+		spf:       []string{"mtasv.net"},
+		cname:     []string{"mtasv.net"},
+		selectors: []string{"pm", "pm-bounces"},
+	},
+	{
+		name:      "Zoho Mail",
+		mx:        []string{"zoho.com", "zoho.eu", "zoho.in", "zoho.com.au"},
+		spf:       []string{"zoho.com", "zoho.eu", "zoho.in"},
+		selectors: []string{"zoho", "zmail", "zmail1", "zmail2"},
+	},
+	{
+		name:      "Fastmail",
+		mx:        []string{"messagingengine.com"},
+		spf:       []string{"messagingengine.com"},
+		cname:     []string{"fmhosted.com"},
+		selectors: []string{"fm1", "fm2", "fm3", "mesmtp"},
+	},
+	{
+		name:      "Proton Mail",
+		mx:        []string{"protonmail.ch"},
+		spf:       []string{"protonmail.ch"},
+		cname:     []string{"protonmail.ch", "proton.ch"},
+		selectors: []string{"protonmail", "protonmail2", "protonmail3"},
+	},
+	{
+		name:      "Yahoo",
+		mx:        []string{"yahoodns.net"},
+		spf:       []string{"_spf.mail.yahoo.com"},
+		selectors: []string{"s1024", "s2048", "yahoo"},
+	},
+	{
+		name:      "iCloud Mail",
+		mx:        []string{"mail.icloud.com"},
+		spf:       []string{"icloud.com"},
+		selectors: []string{"sig1"},
+	},
+	{
+		name:      "Yandex",
+		mx:        []string{"yandex.net", "yandex.ru"},
+		spf:       []string{"_spf.yandex.net"},
+		selectors: []string{"mail", "yandex"},
+	},
+	{
+		name:      "Mailjet",
+		spf:       []string{"spf.mailjet.com"},
+		selectors: []string{"mailjet"},
+	},
+	{
+		name:      "SparkPost",
+		spf:       []string{"sparkpostmail.com"},
+		cname:     []string{"sparkpostmail.com"},
+		selectors: []string{"scph0316", "scph1220", "sparkpost"},
+	},
+	{
+		name:      "HubSpot",
+		spf:       []string{"hubspotemail.net"},
+		cname:     []string{"hubspotemail.net"},
+		selectors: []string{"hs1", "hs2"},
+	},
+	{
+		name:      "Salesforce",
+		spf:       []string{"_spf.salesforce.com", "exacttarget.com"},
+		cname:     []string{"salesforce.com", "exacttarget.com"},
+		selectors: []string{"sf1", "sf2", "200608"},
+	},
+	{
+		name:      "Zendesk",
+		spf:       []string{"mail.zendesk.com"},
+		cname:     []string{"zendesk.com"},
+		selectors: []string{"zendesk1", "zendesk2"},
+	},
+	{
+		name:      "Constant Contact",
+		spf:       []string{"spf.constantcontact.com"},
+		cname:     []string{"constantcontact.com"},
+		selectors: []string{"ctct1", "ctct2"},
+	},
+	{
+		name:      "Klaviyo",
+		spf:       []string{"klaviyomail.com"},
+		cname:     []string{"klaviyomail.com"},
+		selectors: []string{"kl", "kl2"},
+	},
+	{
+		name:      "Brevo",
+		spf:       []string{"sendinblue.com", "brevo.com"},
+		cname:     []string{"sendinblue.com", "brevo.com"},
+		selectors: []string{"brevo1", "brevo2", "mail"},
+	},
+	{
+		name:      "Freshdesk",
+		spf:       []string{"freshdesk.com", "freshemail.io"},
+		cname:     []string{"freshdesk.com", "freshemail.io"},
+		selectors: []string{"fd", "fd2", "freshdesk"},
+	},
+	{
+		name:      "Intercom",
+		spf:       []string{"intercom.io"},
+		cname:     []string{"intercom.io"},
+		selectors: []string{"intercom", "ic"},
+	},
+	{
+		name:      "Campaign Monitor",
+		spf:       []string{"_spf.createsend.com"},
+		cname:     []string{"createsend.com"},
+		selectors: []string{"cm"},
+	},
+	{
+		name:      "Mimecast",
+		mx:        []string{"mimecast.com", "mimecast.co.za"},
+		spf:       []string{"mimecast.com"},
+		selectors: []string{"mimecast", "mimecast20190104"},
+	},
+	{
+		name:      "Everlytic",
+		spf:       []string{"everlytic.net"},
+		selectors: []string{"everlytickey1", "everlytickey2", "eversrv"},
+	},
+	{
+		name:      "Gandi",
+		mx:        []string{"gandi.net"},
+		spf:       []string{"_mailcust.gandi.net"},
+		selectors: []string{"gm1", "gm2", "gm3"},
+	},
+	{
+		name:      "Hostinger",
+		mx:        []string{"hostinger.com"},
+		spf:       []string{"_spf.mail.hostinger.com"},
+		selectors: []string{"hostingermail1", "hostingermail2", "hostingermail3"},
+	},
+	{
+		name:      "OVHcloud",
+		mx:        []string{"ovh.net"},
+		spf:       []string{"mx.ovh.com"},
+		selectors: []string{"ovhmo", "ovh"},
+	},
+}
+
+// dkimSelectorDictionary holds selectors commonly used by mail servers and appliances that are
+// not tied to one provider.
+var dkimSelectorDictionary = []string{
+	"default", "dkim", "mail", "email", "selector", "selector1", "selector2", "selector3",
+	"s1", "s2", "s3", "s4", "s5", "k1", "k2", "k3", "key1", "key2", "key3",
+	"dkim1", "dkim2", "dkim3", "dkim-1", "dkim-2", "mail1", "mail2", "mail3",
+	"smtp", "smtp1", "smtp2", "mx", "mx1", "mx2", "mta", "mta1", "mta2", "mailer", "mailout",
+	"outbound", "out", "sig", "sig1", "sig2", "x", "m1", "m2", "ms", "main", "primary", "secondary",
+	"domainkey", "domk", "dk", "dk1", "dk2", "key", "private", "public", "postfix", "exim", "opendkim",
+	"zimbra", "plesk", "cpanel", "mdaemon", "kerio", "exchange", "office365", "o365", "gsuite",
+	"newsletter", "news", "marketing", "bulk", "bounce", "transactional", "notify", "notifications",
+	"mailing", "list", "lists", "info", "support", "web", "www", "server", "server1", "host", "test",
+	"a1", "b1", "c1", "rsa", "rsa1", "rsa2", "ed25519", "ed", "2048", "1024",
+}
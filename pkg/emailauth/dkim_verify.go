@@ -0,0 +1,507 @@
+// Package emailauth provides functionality for checking email authentication mechanisms.
+package emailauth
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+	"time"
+
+	"mxclone/pkg/types"
+)
+
+// DKIM verification results (RFC 8601 section 2.7.1)
+const (
+	DKIMPass      = "pass"
+	DKIMFail      = "fail"
+	DKIMNeutral   = "neutral"
+	DKIMPermError = "permerror"
+	DKIMTempError = "temperror"
+)
+
+// DKIMKeyLookup returns the key record published for a selector of a domain.
+type DKIMKeyLookup func(ctx context.Context, domain, selector string) (string, error)
+
+// dkimSignature is a parsed DKIM-Signature header field.
+type dkimSignature struct {
+	field         headerField
+	tags          map[string]string
+	domain        string
+	selector      string
+	algorithm     string
+	headerCanon   string
+	bodyCanon     string
+	signedHeaders []string
+	bodyHash      []byte
+	signature     []byte
+	identity      string
+	bodyLength    int64 // -1 when the l= tag is absent
+	timestamp     int64 // 0 when the t= tag is absent
+	expiration    int64 // 0 when the x= tag is absent
+}
+
+// dkimRecommendedHeaders are the header fields a signature should cover when the message has them
+// (RFC 6376 section 5.4.1); unsigned ones can be changed without breaking the signature.
+var dkimRecommendedHeaders = []string{
+	"from", "reply-to", "subject", "date", "to", "cc", "message-id",
+	"resent-date", "resent-from", "resent-to", "resent-cc",
+	"in-reply-to", "references", "mime-version", "content-type", "content-transfer-encoding",
+	"list-id", "list-help", "list-unsubscribe", "list-subscribe", "list-post", "list-owner", "list-archive",
+}
+
+// dkimClockSkew is the tolerance for signature timestamps in the future.
+const dkimClockSkew = 5 * time.Minute
+
+// VerifyDKIM verifies every DKIM-Signature header of a raw RFC 5322 message.
+// Keys are fetched with lookup; GetDKIMRecord is used when it is nil.
+func VerifyDKIM(ctx context.Context, raw []byte, lookup DKIMKeyLookup, timeout time.Duration) []types.DKIMSignatureResult {
+	if lookup == nil {
+		lookup = func(ctx context.Context, domain, selector string) (string, error) {
+			return GetDKIMRecord(ctx, domain, selector, timeout)
+		}
+	}
+
+	headers, body := splitMessage(raw)
+
+	var results []types.DKIMSignatureResult
+	for _, field := range headers {
+		if field.name != "dkim-signature" {
+			continue
+		}
+		results = append(results, verifySignature(ctx, field, headers, body, lookup))
+	}
+	return results
+}
+
+// verifySignature verifies one signature against the message.
+func verifySignature(ctx context.Context, field headerField, headers []headerField, body []byte, lookup DKIMKeyLookup) types.DKIMSignatureResult {
+	sig, err := parseDKIMSignature(field)
+	result := signatureResult(sig)
+	if err != nil {
+		result.Reason = err.Error()
+		return result
+	}
+
+	result.UnsignedHeaders, result.Warnings = checkHeaderCoverage(sig, headers)
+	now := time.Now()
+	if sig.timestamp > 0 && time.Unix(sig.timestamp, 0).After(now.Add(dkimClockSkew)) {
+		result.Warnings = append(result.Warnings, "signature timestamp t= is in the future")
+	}
+	if sig.timestamp > 0 && sig.expiration > 0 && sig.expiration <= sig.timestamp {
+		result.Warnings = append(result.Warnings, "expiration x= is not later than the timestamp t=")
+	}
+
+	if sig.expiration > 0 && now.Unix() > sig.expiration {
+		result.Result = DKIMFail
+		result.Reason = "signature expired at " + time.Unix(sig.expiration, 0).UTC().Format(time.RFC3339)
+		return result
+	}
+
+	checkHashes(ctx, sig, headers, body, lookup, &result)
+	return result
+}
+
+// signatureResult returns a permerror result carrying the tags of a parsed signature, which may be nil.
+func signatureResult(sig *dkimSignature) types.DKIMSignatureResult {
+	result := types.DKIMSignatureResult{Result: DKIMPermError}
+	if sig == nil {
+		return result
+	}
+	result.Domain = sig.domain
+	result.Selector = sig.selector
+	result.Algorithm = sig.algorithm
+	result.Canonicalization = sig.headerCanon + "/" + sig.bodyCanon
+	result.SignedHeaders = sig.signedHeaders
+	result.Identity = sig.identity
+	if sig.timestamp > 0 {
+		timestamp := time.Unix(sig.timestamp, 0).UTC()
+		result.Timestamp = &timestamp
+	}
+	if sig.expiration > 0 {
+		expiration := time.Unix(sig.expiration, 0).UTC()
+		result.Expiration = &expiration
+	}
+	if sig.bodyLength >= 0 {
+		bodyLength := sig.bodyLength
+		result.BodyLength = &bodyLength
+	}
+	return result
+}
+
+// checkHashes checks the body hash, then the signature over the signed headers, and sets the result.
+func checkHashes(ctx context.Context, sig *dkimSignature, headers []headerField, body []byte, lookup DKIMKeyLookup, result *types.DKIMSignatureResult) {
+	// Body hash
+	newHash, cryptoHash := signatureHash(sig.algorithm)
+	canonicalBody := canonicalizeBody(body, sig.bodyCanon)
+	if sig.bodyLength >= 0 {
+		if sig.bodyLength > int64(len(canonicalBody)) {
+			result.Result = DKIMFail
+			result.Reason = fmt.Sprintf("l=%d exceeds the body length (%d)", sig.bodyLength, len(canonicalBody))
+			return
+		}
+		result.UnsignedBodyBytes = int64(len(canonicalBody)) - sig.bodyLength
+		switch {
+		case sig.bodyLength == 0:
+			result.Warnings = append(result.Warnings, "l=0 signs none of the body; the whole body can be replaced")
+		case result.UnsignedBodyBytes > 0:
+			result.Warnings = append(result.Warnings, fmt.Sprintf("l=%d leaves %d body bytes unsigned; content was appended after signing", sig.bodyLength, result.UnsignedBodyBytes))
+		default:
+			result.Warnings = append(result.Warnings, fmt.Sprintf("l=%d lets content be appended to the body without breaking the signature", sig.bodyLength))
+		}
+		canonicalBody = canonicalBody[:sig.bodyLength]
+	}
+	bh := newHash()
+	bh.Write(canonicalBody)
+	if !bytes.Equal(bh.Sum(nil), sig.bodyHash) {
+		result.Result = DKIMFail
+		result.Reason = "body hash does not match (the body was modified after signing)"
+		return
+	}
+
+	// Header hash and signature
+	h := newHash()
+	writeSignedHeaders(h, sig, headers)
+	result.Result, result.Reason = checkSignature(ctx, sig, cryptoHash, h.Sum(nil), lookup)
+}
+
+// signatureHash returns the hash function of a signing algorithm. Both accepted algorithms,
+// rsa-sha256 and ed25519-sha256, use SHA-256.
+func signatureHash(algorithm string) (func() hash.Hash, crypto.Hash) {
+	return sha256.New, crypto.SHA256
+}
+
+// checkSignature fetches the key of the signing domain and selector and verifies the signature of a digest.
+// It returns the result and, unless it passed, the reason.
+func checkSignature(ctx context.Context, sig *dkimSignature, cryptoHash crypto.Hash, digest []byte, lookup DKIMKeyLookup) (string, string) {
+	record, err := lookup(ctx, sig.domain, sig.selector)
+	if err != nil {
+		if isNotFound(err) || errors.Is(err, ErrNoDKIMRecord) {
+			return DKIMPermError, fmt.Sprintf("no key published at %s._domainkey.%s", sig.selector, sig.domain)
+		}
+		return DKIMTempError, "key lookup failed: " + err.Error()
+	}
+	key, err := parseDKIMKey(record, sig.algorithm)
+	if err != nil {
+		return DKIMPermError, err.Error()
+	}
+
+	switch pub := key.(type) {
+	case *rsa.PublicKey:
+		err = rsa.VerifyPKCS1v15(pub, cryptoHash, digest, sig.signature)
+	case ed25519.PublicKey:
+		if !ed25519.Verify(pub, digest, sig.signature) {
+			err = errors.New("ed25519 verification failed")
+		}
+	}
+	if err != nil {
+		return DKIMFail, "signature does not verify (signed headers were modified or the key does not match)"
+	}
+	return DKIMPass, ""
+}
+
+// parseDKIMSignature parses and checks the tags of a DKIM-Signature header field (RFC 6376 section 3.5).
+func parseDKIMSignature(field headerField) (*dkimSignature, error) {
+	tags, err := parseTagList(field.value())
+	if err != nil {
+		return nil, err
+	}
+
+	sig := &dkimSignature{
+		field:       field,
+		tags:        tags,
+		domain:      strings.ToLower(tags["d"]),
+		selector:    strings.ToLower(tags["s"]),
+		algorithm:   strings.ToLower(tags["a"]),
+		headerCanon: "simple",
+		bodyCanon:   "simple",
+		bodyLength:  -1,
+	}
+
+	for _, tag := range []string{"v", "a", "b", "bh", "d", "h", "s"} {
+		if _, ok := tags[tag]; !ok {
+			return sig, fmt.Errorf("missing required tag %s=", tag)
+		}
+	}
+	if tags["v"] != "1" {
+		return sig, fmt.Errorf("unsupported version v=%s", tags["v"])
+	}
+	switch sig.algorithm {
+	case "rsa-sha256", "ed25519-sha256":
+	case "rsa-sha1":
+		// RFC 8301 section 3.1: verifiers must not consider rsa-sha1 signatures valid
+		return sig, errors.New("rsa-sha1 is not allowed (RFC 8301)")
+	default:
+		return sig, fmt.Errorf("unsupported algorithm a=%s", sig.algorithm)
+	}
+
+	if err := parseSignatureTags(sig); err != nil {
+		return sig, err
+	}
+	signsFrom := false
+	for _, name := range sig.signedHeaders {
+		signsFrom = signsFrom || name == "from"
+	}
+	if !signsFrom {
+		return sig, errors.New("the From header is not signed")
+	}
+
+	if l, ok := tags["l"]; ok {
+		if sig.bodyLength, err = strconv.ParseInt(l, 10, 64); err != nil || sig.bodyLength < 0 {
+			return sig, fmt.Errorf("malformed body length l=%s", l)
+		}
+	}
+	if t, ok := tags["t"]; ok {
+		if sig.timestamp, err = strconv.ParseInt(t, 10, 64); err != nil || sig.timestamp < 0 {
+			return sig, fmt.Errorf("malformed timestamp t=%s", t)
+		}
+	}
+	if x, ok := tags["x"]; ok {
+		if sig.expiration, err = strconv.ParseInt(x, 10, 64); err != nil {
+			return sig, fmt.Errorf("malformed expiration x=%s", x)
+		}
+	}
+
+	if i, ok := tags["i"]; ok {
+		sig.identity = i
+		at := strings.LastIndexByte(i, '@')
+		identity := strings.ToLower(i[at+1:])
+		if at < 0 || (identity != sig.domain && !strings.HasSuffix(identity, "."+sig.domain)) {
+			return sig, fmt.Errorf("identity i=%s is not in the signing domain %s", i, sig.domain)
+		}
+	}
+
+	return sig, nil
+}
+
+// parseSignatureTags parses the c=, h=, bh= and b= tags shared by DKIM-Signature and ARC-Message-Signature.
+func parseSignatureTags(sig *dkimSignature) error {
+	if c, ok := sig.tags["c"]; ok {
+		header, body, hasBody := strings.Cut(strings.ToLower(c), "/")
+		sig.headerCanon = header
+		if hasBody {
+			sig.bodyCanon = body
+		}
+		for _, canon := range []string{sig.headerCanon, sig.bodyCanon} {
+			if canon != "simple" && canon != "relaxed" {
+				return fmt.Errorf("unsupported canonicalization c=%s", c)
+			}
+		}
+	}
+
+	for _, name := range strings.Split(sig.tags["h"], ":") {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			sig.signedHeaders = append(sig.signedHeaders, name)
+		}
+	}
+
+	var err error
+	if sig.bodyHash, err = base64.StdEncoding.DecodeString(sig.tags["bh"]); err != nil {
+		return errors.New("malformed body hash bh=")
+	}
+	if sig.signature, err = base64.StdEncoding.DecodeString(sig.tags["b"]); err != nil {
+		return errors.New("malformed signature b=")
+	}
+	return nil
+}
+
+// checkHeaderCoverage returns the recommended headers of the message that the signature does not cover,
+// and warns about headers present more often than h= signs them: the extra instances sit above the
+// signed ones, where mail clients display them, and can be added without breaking the signature.
+func checkHeaderCoverage(sig *dkimSignature, headers []headerField) ([]string, []string) {
+	present := make(map[string]int)
+	for _, field := range headers {
+		present[field.name]++
+	}
+	signed := make(map[string]int)
+	for _, name := range sig.signedHeaders {
+		signed[name]++
+	}
+
+	var unsigned, warnings []string
+	for _, name := range dkimRecommendedHeaders {
+		if present[name] > 0 && signed[name] == 0 {
+			unsigned = append(unsigned, name)
+		}
+	}
+	if len(unsigned) > 0 {
+		warnings = append(warnings, "unsigned headers can be changed without breaking the signature: "+strings.Join(unsigned, ", "))
+	}
+
+	seen := make(map[string]bool)
+	for _, name := range sig.signedHeaders {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		if present[name] > signed[name] {
+			warnings = append(warnings, fmt.Sprintf("the message has %d %s headers but h= signs %d; the unsigned instance was added after signing", present[name], name, signed[name]))
+		}
+	}
+	return unsigned, warnings
+}
+
+// parseDKIMKey decodes the public key of a key record and checks it suits the algorithm.
+func parseDKIMKey(record, algorithm string) (crypto.PublicKey, error) {
+	tags, err := parseTagList(record)
+	if err != nil {
+		return nil, fmt.Errorf("malformed key record: %v", err)
+	}
+	if v, ok := tags["v"]; ok && v != "DKIM1" {
+		return nil, fmt.Errorf("unsupported key record version v=%s", v)
+	}
+	p, ok := tags["p"]
+	if !ok {
+		return nil, errors.New("key record has no p= tag")
+	}
+	if p == "" {
+		return nil, errors.New("key has been revoked (empty p=)")
+	}
+	keyType := strings.ToLower(tags["k"])
+	if keyType == "" {
+		keyType = "rsa"
+	}
+	if !strings.HasPrefix(algorithm, keyType+"-") {
+		return nil, fmt.Errorf("key type k=%s does not match algorithm a=%s", keyType, algorithm)
+	}
+	if h, ok := tags["h"]; ok {
+		hashAlg := algorithm[strings.IndexByte(algorithm, '-')+1:]
+		if !strings.Contains(":"+strings.ToLower(h)+":", ":"+hashAlg+":") {
+			return nil, fmt.Errorf("key record h=%s does not allow %s", h, hashAlg)
+		}
+	}
+
+	key, bits, err := decodeDKIMPublicKey(keyType, p)
+	if err != nil {
+		return nil, err
+	}
+	// RFC 8301 section 3.2: verifiers must not consider signatures with shorter keys valid
+	if keyType == "rsa" && bits < dkimMinRSABits {
+		return nil, fmt.Errorf("the %d-bit RSA key is under %d bits", bits, dkimMinRSABits)
+	}
+	return key, nil
+}
+
+// parseTagList parses a DKIM tag=value list; whitespace inside values is removed.
+func parseTagList(list string) (map[string]string, error) {
+	tags := make(map[string]string)
+	for _, part := range strings.Split(list, ";") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("malformed tag %q", strings.TrimSpace(part))
+		}
+		name = strings.TrimSpace(name)
+		if _, dup := tags[name]; dup {
+			return nil, fmt.Errorf("duplicate tag %s=", name)
+		}
+		tags[name] = strings.Join(strings.Fields(value), "")
+	}
+	return tags, nil
+}
+
+// writeSignedHeaders writes the canonicalized signed headers, then the signature header without its b= value.
+// Each name in h= selects the last instance not yet used, so repeated names select earlier instances.
+func writeSignedHeaders(h hash.Hash, sig *dkimSignature, headers []headerField) {
+	used := make(map[int]bool)
+	for _, name := range sig.signedHeaders {
+		for i := len(headers) - 1; i >= 0; i-- {
+			if used[i] || headers[i].name != name {
+				continue
+			}
+			used[i] = true
+			h.Write([]byte(canonicalizeHeader(headers[i].raw, sig.headerCanon)))
+			break
+		}
+	}
+
+	unsigned := canonicalizeHeader(stripSignatureValue(sig.field.raw), sig.headerCanon)
+	h.Write([]byte(strings.TrimSuffix(unsigned, "\r\n")))
+}
+
+// stripSignatureValue empties the b= tag of a DKIM-Signature header field.
+func stripSignatureValue(raw string) string {
+	colon := strings.IndexByte(raw, ':') + 1
+	value := raw[colon:]
+
+	start := 0
+	for start < len(value) {
+		end := strings.IndexByte(value[start:], ';')
+		if end < 0 {
+			end = len(value)
+		} else {
+			end += start
+		}
+		name, _, ok := strings.Cut(value[start:end], "=")
+		if ok && strings.TrimSpace(name) == "b" {
+			eq := start + strings.IndexByte(value[start:end], '=') + 1
+			return raw[:colon] + value[:eq] + value[end:]
+		}
+		start = end + 1
+	}
+	return raw
+}
+
+// canonicalizeHeader applies the simple or relaxed header canonicalization to one field.
+func canonicalizeHeader(raw, canon string) string {
+	if canon == "simple" {
+		return raw
+	}
+
+	name, value, _ := strings.Cut(raw, ":")
+	value = strings.ReplaceAll(value, "\r\n", "")
+	value = strings.Join(strings.FieldsFunc(value, isWSP), " ")
+	return strings.ToLower(strings.TrimSpace(name)) + ":" + value + "\r\n"
+}
+
+// canonicalizeBody applies the simple or relaxed body canonicalization. The body is handled as
+// bytes: 8-bit text that is not UTF-8 must hash exactly as the signer saw it.
+func canonicalizeBody(body []byte, canon string) []byte {
+	lines := bytes.Split(body, []byte("\r\n"))
+	if canon == "relaxed" {
+		for i, line := range lines {
+			line = bytes.TrimRight(line, " \t")
+			b := make([]byte, 0, len(line))
+			wsp := false
+			for _, c := range line {
+				if c == ' ' || c == '\t' {
+					wsp = true
+					continue
+				}
+				if wsp {
+					b = append(b, ' ')
+					wsp = false
+				}
+				b = append(b, c)
+			}
+			lines[i] = b
+		}
+	}
+
+	// Remove empty lines at the end of the body
+	for len(lines) > 0 && len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) == 0 {
+		if canon == "relaxed" {
+			return nil
+		}
+		return []byte("\r\n")
+	}
+	return append(bytes.Join(lines, []byte("\r\n")), '\r', '\n')
+}
+
+// isWSP reports whether r is a space or a tab.
+func isWSP(r rune) bool {
+	return r == ' ' || r == '\t'
+}
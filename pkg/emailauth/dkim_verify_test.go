@@ -0,0 +1,359 @@
+// Package emailauth provides functionality for checking email authentication mechanisms.
+package emailauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testMessage = "From: Alice <alice@example.com>\r\n" +
+	"To: bob@example.net\r\n" +
+	"Subject:   Hello   there\r\n" +
+	"Date: Mon, 2 Jan 2006 15:04:05 +0000\r\n" +
+	"Message-ID: <1@example.com>\r\n" +
+	"\r\n" +
+	"Hi Bob,  \r\n" +
+	"\r\n" +
+	"See you.\r\n" +
+	"\r\n"
+
+// signMessage adds a DKIM-Signature to a message with the given key.
+func signMessage(t *testing.T, message, domain, selector, algorithm, canon, extraTags string, key crypto.Signer) string {
+	t.Helper()
+	headers, body := splitMessage([]byte(message))
+	headerCanon, bodyCanon, _ := strings.Cut(canon, "/")
+
+	bh := sha256.Sum256(canonicalizeBody(body, bodyCanon))
+	field := fmt.Sprintf("DKIM-Signature: v=1; a=%s; c=%s; d=%s; s=%s;\r\n\th=from:to:subject:date; %sbh=%s;\r\n\tb=\r\n",
+		algorithm, canon, domain, selector, extraTags, base64.StdEncoding.EncodeToString(bh[:]))
+
+	sig := &dkimSignature{
+		field:         headerField{name: "dkim-signature", raw: field},
+		headerCanon:   headerCanon,
+		signedHeaders: []string{"from", "to", "subject", "date"},
+	}
+	h := sha256.New()
+	writeSignedHeaders(h, sig, headers)
+
+	var signature []byte
+	var err error
+	if _, ok := key.(ed25519.PrivateKey); ok {
+		signature, err = key.Sign(rand.Reader, h.Sum(nil), crypto.Hash(0))
+	} else {
+		signature, err = key.Sign(rand.Reader, h.Sum(nil), crypto.SHA256)
+	}
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+
+	field = strings.TrimSuffix(field, "\r\n") + base64.StdEncoding.EncodeToString(signature) + "\r\n"
+	return field + message
+}
+
+// TestVerifyDKIM tests verification of RSA and Ed25519 signatures and common failures
+func TestVerifyDKIM(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	rsaPub, _ := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	edPub, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	// Only the published key size matters: the check happens before the signature is verified
+	shortPub, _ := x509.MarshalPKIXPublicKey(&rsa.PublicKey{N: new(big.Int).SetBit(big.NewInt(1), 511, 1), E: 65537})
+
+	keys := map[string]string{
+		"rsa._domainkey.example.com":     "v=DKIM1; k=rsa; p=" + base64.StdEncoding.EncodeToString(rsaPub),
+		"ed._domainkey.example.com":      "v=DKIM1; k=ed25519; p=" + base64.StdEncoding.EncodeToString(edPub),
+		"revoked._domainkey.example.com": "v=DKIM1; p=",
+		"short._domainkey.example.com":   "v=DKIM1; k=rsa; p=" + base64.StdEncoding.EncodeToString(shortPub),
+	}
+	lookup := func(ctx context.Context, domain, selector string) (string, error) {
+		if record, ok := keys[selector+"._domainkey."+domain]; ok {
+			return record, nil
+		}
+		return "", ErrNoDKIMRecord
+	}
+
+	expired := fmt.Sprintf("x=%d; ", time.Now().Add(-time.Hour).Unix())
+
+	tests := []struct {
+		name   string
+		signed string
+		want   string
+		reason string
+	}{
+		{"rsa relaxed", signMessage(t, testMessage, "example.com", "rsa", "rsa-sha256", "relaxed/relaxed", "", rsaKey), DKIMPass, ""},
+		{"rsa simple", signMessage(t, testMessage, "example.com", "rsa", "rsa-sha256", "simple/simple", "", rsaKey), DKIMPass, ""},
+		{"ed25519", signMessage(t, testMessage, "example.com", "ed", "ed25519-sha256", "relaxed/simple", "", edKey), DKIMPass, ""},
+		{"relaxed survives whitespace changes", strings.Replace(signMessage(t, testMessage, "example.com", "rsa", "rsa-sha256", "relaxed/relaxed", "", rsaKey), "Hi Bob,  ", "Hi  Bob,", 1), DKIMPass, ""},
+		{"modified body", strings.Replace(signMessage(t, testMessage, "example.com", "rsa", "rsa-sha256", "relaxed/relaxed", "", rsaKey), "See you.", "See you!", 1), DKIMFail, "body hash"},
+		{"modified header", strings.Replace(signMessage(t, testMessage, "example.com", "ed", "ed25519-sha256", "relaxed/relaxed", "", edKey), "Hello", "Goodbye", 1), DKIMFail, "signature does not verify"},
+		{"wrong key", signMessage(t, testMessage, "example.com", "ed", "ed25519-sha256", "relaxed/relaxed", "", ed25519.NewKeyFromSeed(make([]byte, 32))), DKIMFail, "signature does not verify"},
+		{"expired", signMessage(t, testMessage, "example.com", "rsa", "rsa-sha256", "relaxed/relaxed", expired, rsaKey), DKIMFail, "expired"},
+		{"no key", signMessage(t, testMessage, "example.com", "missing", "rsa-sha256", "relaxed/relaxed", "", rsaKey), DKIMPermError, "no key"},
+		{"revoked key", signMessage(t, testMessage, "example.com", "revoked", "rsa-sha256", "relaxed/relaxed", "", rsaKey), DKIMPermError, "revoked"},
+		{"short rsa key", signMessage(t, testMessage, "example.com", "short", "rsa-sha256", "relaxed/relaxed", "", rsaKey), DKIMPermError, "under 1024 bits"},
+		{"rsa-sha1", strings.Replace(signMessage(t, testMessage, "example.com", "rsa", "rsa-sha256", "relaxed/relaxed", "", rsaKey), "a=rsa-sha256", "a=rsa-sha1", 1), DKIMPermError, "rsa-sha1 is not allowed (RFC 8301)"},
+		{"key type mismatch", signMessage(t, testMessage, "example.com", "ed", "rsa-sha256", "relaxed/relaxed", "", rsaKey), DKIMPermError, "does not match"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := VerifyDKIM(context.Background(), []byte(tt.signed), lookup, time.Second)
+			if len(results) != 1 {
+				t.Fatalf("VerifyDKIM() returned %d results, want 1", len(results))
+			}
+			if results[0].Result != tt.want {
+				t.Errorf("Result = %q (%s), want %q", results[0].Result, results[0].Reason, tt.want)
+			}
+			if !strings.Contains(results[0].Reason, tt.reason) {
+				t.Errorf("Reason = %q, want it to mention %q", results[0].Reason, tt.reason)
+			}
+		})
+	}
+}
+
+// TestVerifyDKIMWeaknesses tests the l= and header coverage warnings of signatures that still pass
+func TestVerifyDKIMWeaknesses(t *testing.T) {
+	edPub, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	lookup := func(ctx context.Context, domain, selector string) (string, error) {
+		return "v=DKIM1; k=ed25519; p=" + base64.StdEncoding.EncodeToString(edPub), nil
+	}
+
+	_, body := splitMessage([]byte(testMessage))
+	bodyLength := len(canonicalizeBody(body, "relaxed"))
+	now := time.Now().Unix()
+	tags := fmt.Sprintf("l=%d; t=%d; x=%d; i=news@mail.example.com; ", bodyLength, now, now+3600)
+
+	signed := signMessage(t, testMessage, "example.com", "ed", "ed25519-sha256", "relaxed/relaxed", tags, edKey)
+	appended := signed + "Click here: https://phish.example.net/\r\n"
+	injected := strings.Replace(appended, "From: Alice", "From: Mallory <ceo@example.com>\r\nFrom: Alice", 1)
+
+	tests := []struct {
+		name     string
+		raw      string
+		unsigned int64
+		warnings []string
+	}{
+		{"l= covers the body", signed, 0, []string{"message-id", "lets content be appended"}},
+		{"content appended past l=", appended, 42, []string{"leaves 42 body bytes unsigned"}},
+		{"unsigned From instance", injected, 42, []string{"2 from headers but h= signs 1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := VerifyDKIM(context.Background(), []byte(tt.raw), lookup, time.Second)
+			if len(results) != 1 {
+				t.Fatalf("VerifyDKIM() returned %d results, want 1", len(results))
+			}
+			result := results[0]
+			if result.Result != DKIMPass {
+				t.Fatalf("Result = %q (%s), want pass", result.Result, result.Reason)
+			}
+			if result.BodyLength == nil || *result.BodyLength != int64(bodyLength) || result.UnsignedBodyBytes != tt.unsigned {
+				t.Errorf("BodyLength = %v, UnsignedBodyBytes = %d, want %d, %d", result.BodyLength, result.UnsignedBodyBytes, bodyLength, tt.unsigned)
+			}
+			if result.Identity != "news@mail.example.com" || result.Timestamp == nil || result.Expiration == nil {
+				t.Errorf("Identity = %q, Timestamp = %v, Expiration = %v", result.Identity, result.Timestamp, result.Expiration)
+			}
+			if strings.Join(result.UnsignedHeaders, ",") != "message-id" {
+				t.Errorf("UnsignedHeaders = %v, want [message-id]", result.UnsignedHeaders)
+			}
+			warnings := strings.Join(result.Warnings, "\n")
+			for _, want := range tt.warnings {
+				if !strings.Contains(warnings, want) {
+					t.Errorf("Warnings = %q, want one mentioning %q", result.Warnings, want)
+				}
+			}
+		})
+	}
+}
+
+// TestAnalyzeMessage tests DMARC alignment of SPF and DKIM results against the From domain
+func TestAnalyzeMessage(t *testing.T) {
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	edPub := edKey.Public().(ed25519.PublicKey)
+	lookup := func(ctx context.Context, domain, selector string) (string, error) {
+		return "v=DKIM1; k=ed25519; p=" + base64.StdEncoding.EncodeToString(edPub), nil
+	}
+	resolver := &fakeResolver{
+		txt: map[string][]string{
+			"bounces.example.com": {"v=spf1 ip4:192.0.2.0/24 -all"},
+			"example.net":         {"v=spf1 ip4:192.0.2.0/24 -all"},
+			"_dmarc.example.com":  {"v=DMARC1; p=reject; sp=quarantine"},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		clientIP    string
+		mailFrom    string
+		dkimDomain  string
+		wantDMARC   string
+		wantSPFAlig bool
+		wantDKIMAl  bool
+	}{
+		{"spf aligned", "192.0.2.1", "b@bounces.example.com", "", DMARCPass, true, false},
+		{"dkim aligned", "203.0.113.1", "b@bounces.example.com", "mail.example.com", DMARCPass, false, true},
+		{"spf not aligned", "192.0.2.1", "b@example.net", "", DMARCFail, false, false},
+		{"third-party dkim", "203.0.113.1", "b@example.net", "example.net", DMARCFail, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw := testMessage
+			if tt.dkimDomain != "" {
+				raw = signMessage(t, testMessage, tt.dkimDomain, "ed", "ed25519-sha256", "relaxed/relaxed", "", edKey)
+			}
+			report := AnalyzeMessage(context.Background(), MessageAnalysisOptions{
+				ClientIP:      tt.clientIP,
+				Helo:          "mta.example.com",
+				MailFrom:      tt.mailFrom,
+				Raw:           []byte(raw),
+				Resolver:      resolver,
+				DKIMKeyLookup: lookup,
+			})
+
+			if report.DMARC.Result != tt.wantDMARC {
+				t.Errorf("DMARC = %q (%s), want %q", report.DMARC.Result, report.DMARC.Error, tt.wantDMARC)
+			}
+			if report.DMARC.SPFAligned != tt.wantSPFAlig || report.DMARC.DKIMAligned != tt.wantDKIMAl {
+				t.Errorf("aligned SPF = %v, DKIM = %v; want %v, %v", report.DMARC.SPFAligned, report.DMARC.DKIMAligned, tt.wantSPFAlig, tt.wantDKIMAl)
+			}
+			if tt.wantDMARC == DMARCFail && report.DMARC.Disposition != "reject" {
+				t.Errorf("Disposition = %q, want reject", report.DMARC.Disposition)
+			}
+			if report.Headers.From != "Alice <alice@example.com>" {
+				t.Errorf("Headers.From = %q", report.Headers.From)
+			}
+		})
+	}
+}
+
+// rfc8463Message is the signed message of RFC 8463 Appendix A. The Ed25519 signature is the one
+// from the RFC; the RSA signature covers the same headers and body and was made with openssl.
+const rfc8463Message = "DKIM-Signature: v=1; a=ed25519-sha256; c=relaxed/relaxed;\r\n" +
+	" d=football.example.com; i=@football.example.com;\r\n" +
+	" q=dns/txt; s=brisbane; t=1528637909; h=from : to :\r\n" +
+	" subject : date : message-id : from : subject : date;\r\n" +
+	" bh=2jUSOH9NhtVGCQWNr9BrIAPreKQjO6Sn7XIkfJVOzv8=;\r\n" +
+	" b=/gCrinpcQOoIfuHNQIbq4pgh9kyIK3AQUdt9OdqQehSwhEIug4D11Bus\r\n" +
+	" Fa3bT3FY5OsU7ZbnKELq+eXdp1Q1Dw==\r\n" +
+	"DKIM-Signature: v=1; a=rsa-sha256; c=relaxed/relaxed;\r\n" +
+	" d=football.example.com; i=@football.example.com;\r\n" +
+	" q=dns/txt; s=test; t=1528637909; h=from : to : subject :\r\n" +
+	" date : message-id : from : subject : date;\r\n" +
+	" bh=2jUSOH9NhtVGCQWNr9BrIAPreKQjO6Sn7XIkfJVOzv8=;\r\n" +
+	" b=rG/7uQeeD0pE/DNENYExMt8y7K0bVvhh2P8Fnq3B/fyAJCAjalItXIWxP8JV\r\n" +
+	" 6xBPgI6gW69LgblvZET/8owAp3VY8EGJsZqIu+AgW+CrDvWGwZjUGBlI9mM/\r\n" +
+	" qZek6ECcK0G+3lS7NdytIPBOUowKPQ2iITd0rxoZoOv/8geBPTL2M9puBSrn\r\n" +
+	" OM1dbhhgbhnpmglBoG4V+W/Mjb0DvOt6N5YxFybdaRKct1R7i4yuYeoG7ZhC\r\n" +
+	" MMVJGmC5G4U5GkHUHaiUsXVaseIjYgV7z/BaXuRpe1KDQmspW4f/F3qwi/fc\r\n" +
+	" 44RfrXRAjK3m/URs2RbHZ/Uk17faK6s591ZsgM434Q==\r\n" +
+	"From: Joe SixPack <joe@football.example.com>\r\n" +
+	"To: Suzie Q <suzie@shopping.example.net>\r\n" +
+	"Subject: Is dinner ready?\r\n" +
+	"Date: Fri, 11 Jul 2003 21:00:37 -0700 (PDT)\r\n" +
+	"Message-ID: <20030712040037.46341.5F8J@football.example.com>\r\n" +
+	"\r\n" +
+	"Hi.\r\n" +
+	"\r\n" +
+	"We lost the game.  Are you hungry yet?\r\n" +
+	"\r\n" +
+	"Joe.\r\n"
+
+// rfc8463Keys are the key records the signatures of rfc8463Message verify against
+var rfc8463Keys = map[string]string{
+	"brisbane._domainkey.football.example.com": "v=DKIM1; k=ed25519; p=11qYAYKxCrfVS/7TyWQHOg7hcvPapiMlrwIaaPcHURo=",
+	"test._domainkey.football.example.com":     "v=DKIM1; k=rsa; p=MIIBIjANBgkqhkiG9w0BAQEFAAOCAQ8AMIIBCgKCAQEAsKVrfJP3Uh260USBRQfSzt2JxPgGB06EE8IHU6nf8BcZ8IuTfoGpqbufvxVft4mJQqCz39IkNZHPPfncdZOGYqTOt3Bp+tcZBu8T2Q650K+2gKMUFPHmDf9txUT+MLQpC57aMPZjt2UPP03sTk+WZb07CdQ4gKLN5hF4H4pDvrBhoaZjzYap1CkDpzChVvoaSqg/6XbgfRwHnEmR6IK7Z7I3vE7btXP4v1NHpAkONyjTSwDZd8mmLYPBGFpM2Wq4VIUmDsIfcowzpqMWPyEaT9rYdauRFMwRxx7FLDjYD0wyoL/ZWrRmCItubHurE+mfx+TcTDU47cvgz1Pr7w9XFQIDAQAB",
+}
+
+// TestVerifyDKIMVector tests verification against the fixed signatures of rfc8463Message
+func TestVerifyDKIMVector(t *testing.T) {
+	lookup := func(ctx context.Context, domain, selector string) (string, error) {
+		if record, ok := rfc8463Keys[selector+"._domainkey."+domain]; ok {
+			return record, nil
+		}
+		return "", ErrNoDKIMRecord
+	}
+
+	results := VerifyDKIM(context.Background(), []byte(rfc8463Message), lookup, time.Second)
+	if len(results) != 2 {
+		t.Fatalf("VerifyDKIM() returned %d results, want 2", len(results))
+	}
+	for i, algorithm := range []string{"ed25519-sha256", "rsa-sha256"} {
+		result := results[i]
+		if result.Algorithm != algorithm || result.Result != DKIMPass {
+			t.Errorf("signature %d = %s %q (%s), want %s pass", i, result.Algorithm, result.Result, result.Reason, algorithm)
+		}
+		if result.Identity != "@football.example.com" || result.Timestamp == nil || result.Timestamp.Unix() != 1528637909 {
+			t.Errorf("signature %d = %+v", i, result)
+		}
+	}
+
+	for name, modified := range map[string]string{
+		"body":   strings.Replace(rfc8463Message, "hungry", "thirsty", 1),
+		"header": strings.Replace(rfc8463Message, "Is dinner ready?", "Is lunch ready?", 1),
+	} {
+		for _, result := range VerifyDKIM(context.Background(), []byte(modified), lookup, time.Second) {
+			if result.Result != DKIMFail {
+				t.Errorf("modified %s: %s result = %q, want %q", name, result.Algorithm, result.Result, DKIMFail)
+			}
+		}
+	}
+}
+
+// TestVerifyDKIMLatin1Body tests that a relaxed body in 8-bit text that is not UTF-8 is
+// canonicalized byte for byte and verifies
+func TestVerifyDKIMLatin1Body(t *testing.T) {
+	// Private key of the brisbane selector of RFC 8463 Appendix A
+	seed, _ := base64.StdEncoding.DecodeString("nWGxne/9WmC6hEr0kuwsxERJxWl7MmkZcDusAxyuf2A=")
+	key := ed25519.NewKeyFromSeed(seed)
+	lookup := func(ctx context.Context, domain, selector string) (string, error) {
+		if record, ok := rfc8463Keys[selector+"._domainkey."+domain]; ok {
+			return record, nil
+		}
+		return "", ErrNoDKIMRecord
+	}
+
+	message := "From: Joe SixPack <joe@football.example.com>\r\n" +
+		"To: Suzie Q <suzie@shopping.example.net>\r\n" +
+		"Subject: Gr\xfc\xdfe\r\n" +
+		"Date: Fri, 11 Jul 2003 21:00:37 -0700 (PDT)\r\n" +
+		"Content-Type: text/plain; charset=iso-8859-1\r\n" +
+		"Content-Transfer-Encoding: 8bit\r\n" +
+		"\r\n" +
+		"Gr\xfc\xdfe  aus\t M\xfcnchen \t\r\n" +
+		"\xa0\xe9t\xe9 \xff\r\n" +
+		"\r\n"
+	canonical := "Gr\xfc\xdfe aus M\xfcnchen\r\n\xa0\xe9t\xe9 \xff\r\n"
+	if got := canonicalizeBody([]byte(strings.SplitN(message, "\r\n\r\n", 2)[1]), "relaxed"); string(got) != canonical {
+		t.Fatalf("canonicalizeBody() = %q, want %q", got, canonical)
+	}
+
+	signed := signMessage(t, message, "football.example.com", "brisbane", "ed25519-sha256", "relaxed/relaxed", "", key)
+	bh := sha256.Sum256([]byte(canonical))
+	if !strings.Contains(signed, "bh="+base64.StdEncoding.EncodeToString(bh[:])) {
+		t.Fatalf("signature does not carry the hash of the byte-wise canonical body:\n%s", signed)
+	}
+	results := VerifyDKIM(context.Background(), []byte(signed), lookup, time.Second)
+	if len(results) != 1 || results[0].Result != DKIMPass {
+		t.Fatalf("VerifyDKIM() = %+v, want pass", results)
+	}
+
+	modified := strings.Replace(signed, "M\xfcnchen", "M\xfdnchen", 1)
+	if results := VerifyDKIM(context.Background(), []byte(modified), lookup, time.Second); results[0].Result != DKIMFail {
+		t.Errorf("modified body: result = %q, want %q", results[0].Result, DKIMFail)
+	}
+}
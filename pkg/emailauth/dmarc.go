@@ -0,0 +1,326 @@
+// Package emailauth provides functionality for checking email authentication mechanisms.
+package emailauth
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"mxclone/pkg/types"
+)
+
+// DMARC tag defaults (RFC 7489 section 6.3)
+const (
+	dmarcDefaultReportInterval = 86400
+	dmarcDefaultReportFormat   = "afrf"
+	dmarcDefaultFailureOption  = "0"
+)
+
+// dmarcSizeLimit matches the !size suffix of a report URI: a number and an optional k, m, g or t unit.
+var dmarcSizeLimit = regexp.MustCompile(`^([0-9]+)([kmgtKMGT]?)$`)
+
+// ParseDMARCRecord parses a DMARC record string into a structured format.
+// Only a missing v=DMARC1 is fatal; invalid or duplicate tags are reported in TagErrors
+// and leave the default value in place, and unknown tags are listed in UnknownTags.
+func ParseDMARCRecord(record string) (*DMARCRecord, error) {
+	if record == "" {
+		return nil, fmt.Errorf("empty DMARC record")
+	}
+
+	dmarc := &DMARCRecord{
+		Raw:            record,
+		Pct:            100, // Default value
+		FailureOptions: []string{dmarcDefaultFailureOption},
+		ReportFormats:  []string{dmarcDefaultReportFormat},
+		ReportInterval: dmarcDefaultReportInterval,
+	}
+
+	// Split the record into parts
+	parts := strings.Split(record, ";")
+
+	// First part should be the version, exactly v=DMARC1
+	version := strings.SplitN(strings.TrimSpace(parts[0]), "=", 2)
+	if len(version) != 2 || strings.TrimSpace(version[0]) != "v" || strings.TrimSpace(version[1]) != "DMARC1" {
+		return nil, fmt.Errorf("invalid DMARC record: does not start with v=DMARC1")
+	}
+	dmarc.Version = "v=DMARC1"
+
+	seen := make(map[string]bool)
+	for _, part := range parts[1:] {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			dmarc.addTagError(part, "", "malformed tag, expected tag=value")
+			continue
+		}
+
+		key := strings.ToLower(strings.TrimSpace(kv[0]))
+		value := strings.TrimSpace(kv[1])
+		if seen[key] {
+			dmarc.addTagError(key, value, "duplicate tag; the first value is used")
+			continue
+		}
+		seen[key] = true
+
+		switch key {
+		case "v":
+			dmarc.addTagError(key, value, "v= must be the first tag")
+		case "p", "sp", "np":
+			policy := strings.ToLower(value)
+			if !isDMARCPolicy(policy) {
+				dmarc.addTagError(key, value, "invalid policy, expected none, quarantine or reject")
+				continue
+			}
+			switch key {
+			case "p":
+				dmarc.Policy = policy
+			case "sp":
+				dmarc.SubPolicy = policy
+			case "np":
+				dmarc.NPPolicy = policy
+			}
+		case "pct":
+			pct, err := strconv.Atoi(value)
+			if err != nil || pct < 0 || pct > 100 {
+				dmarc.addTagError(key, value, "invalid percentage, expected an integer from 0 to 100")
+				continue
+			}
+			dmarc.Pct = pct
+		case "adkim", "aspf":
+			mode := strings.ToLower(value)
+			if mode != "r" && mode != "s" {
+				dmarc.addTagError(key, value, "invalid alignment mode, expected r or s")
+				continue
+			}
+			if key == "adkim" {
+				dmarc.ADKIM = mode
+			} else {
+				dmarc.ASPF = mode
+			}
+		case "fo":
+			options := splitDMARCList(value, ":")
+			valid := len(options) > 0
+			for _, option := range options {
+				valid = valid && (option == "0" || option == "1" || option == "d" || option == "s")
+			}
+			if !valid {
+				dmarc.addTagError(key, value, "invalid failure reporting options, expected 0, 1, d or s separated by colons")
+				continue
+			}
+			dmarc.FailureOptions = options
+		case "rf":
+			formats := splitDMARCList(value, ":")
+			valid := len(formats) > 0
+			for _, format := range formats {
+				valid = valid && format == dmarcDefaultReportFormat
+			}
+			if !valid {
+				dmarc.addTagError(key, value, "unsupported failure report format, only afrf is defined")
+				continue
+			}
+			dmarc.ReportFormats = formats
+		case "ri":
+			interval, err := strconv.ParseUint(value, 10, 32)
+			if err != nil {
+				dmarc.addTagError(key, value, "invalid report interval, expected a number of seconds")
+				continue
+			}
+			dmarc.ReportInterval = int(interval)
+		case "psd":
+			psd := strings.ToLower(value)
+			if psd != "y" && psd != "n" && psd != "u" {
+				dmarc.addTagError(key, value, "invalid public suffix flag, expected y, n or u")
+				continue
+			}
+			dmarc.PSD = psd
+		case "rua", "ruf":
+			uris, errs := parseDMARCURIList(value)
+			for _, err := range errs {
+				dmarc.addTagError(key, value, err)
+			}
+			if key == "rua" {
+				dmarc.RUA = value
+				dmarc.AggregateURIs = uris
+			} else {
+				dmarc.RUF = value
+				dmarc.FailureURIs = uris
+			}
+		default:
+			// Unknown tags must be ignored (RFC 7489 section 6.3)
+			dmarc.UnknownTags = append(dmarc.UnknownTags, key)
+		}
+	}
+
+	if !seen["p"] {
+		dmarc.addTagError("p", "", "missing required p= tag")
+	}
+
+	return dmarc, nil
+}
+
+// addTagError records an invalid tag of the record.
+func (dmarc *DMARCRecord) addTagError(tag, value, message string) {
+	dmarc.TagErrors = append(dmarc.TagErrors, types.DMARCTagError{
+		Tag:     tag,
+		Value:   value,
+		Message: message,
+	})
+}
+
+// isDMARCPolicy reports whether a value is a valid requested mail receiver policy.
+func isDMARCPolicy(policy string) bool {
+	return policy == "none" || policy == "quarantine" || policy == "reject"
+}
+
+// splitDMARCList splits a tag value into its lower-cased, trimmed elements.
+func splitDMARCList(value, sep string) []string {
+	var elements []string
+	for _, element := range strings.Split(value, sep) {
+		if element = strings.ToLower(strings.TrimSpace(element)); element != "" {
+			elements = append(elements, element)
+		}
+	}
+	return elements
+}
+
+// parseDMARCURIList parses the comma-separated URIs of a rua= or ruf= tag, each with an optional
+// !size limit, and returns them with the syntax errors found.
+func parseDMARCURIList(value string) ([]types.DMARCReportURI, []string) {
+	var uris []types.DMARCReportURI
+	var errs []string
+	for _, raw := range strings.Split(value, ",") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			errs = append(errs, "empty URI in the list")
+			continue
+		}
+		uri, err := parseDMARCURI(raw)
+		if err != nil {
+			uri.Error = err.Error()
+			errs = append(errs, fmt.Sprintf("%s: %v", raw, err))
+		}
+		uris = append(uris, uri)
+	}
+	return uris, errs
+}
+
+// parseDMARCURI parses one report URI with its optional !size suffix (RFC 7489 section 6.4).
+func parseDMARCURI(raw string) (types.DMARCReportURI, error) {
+	uri := types.DMARCReportURI{URI: raw}
+
+	// The size limit follows the last "!", which cannot otherwise appear unescaped
+	if i := strings.LastIndexByte(raw, '!'); i >= 0 {
+		uri.URI = raw[:i]
+		size, err := parseDMARCSize(raw[i+1:])
+		if err != nil {
+			return uri, err
+		}
+		uri.MaxSize = size
+	}
+
+	parsed, err := url.Parse(uri.URI)
+	if err != nil || parsed.Scheme == "" {
+		return uri, fmt.Errorf("not a URI")
+	}
+	uri.Scheme = strings.ToLower(parsed.Scheme)
+	if uri.Scheme != "mailto" {
+		// Other schemes are allowed by the syntax, but receivers only send reports by email
+		return uri, nil
+	}
+
+	address, err := mail.ParseAddress(parsed.Opaque)
+	if err != nil {
+		return uri, fmt.Errorf("invalid mailto: address")
+	}
+	at := strings.LastIndexByte(address.Address, '@')
+	uri.Address = address.Address
+	uri.Domain = strings.ToLower(strings.TrimSuffix(address.Address[at+1:], "."))
+	return uri, nil
+}
+
+// parseDMARCSize converts a size limit such as 10m into bytes; units are powers of two.
+func parseDMARCSize(size string) (int64, error) {
+	match := dmarcSizeLimit.FindStringSubmatch(size)
+	if match == nil {
+		return 0, fmt.Errorf("invalid size limit !%s, expected a number and an optional k, m, g or t unit", size)
+	}
+	n, err := strconv.ParseInt(match[1], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size limit !%s", size)
+	}
+	shift := map[string]uint{"": 0, "k": 10, "m": 20, "g": 30, "t": 40}[strings.ToLower(match[2])]
+	if n > (1<<62)>>shift {
+		return 0, fmt.Errorf("size limit !%s is too large", size)
+	}
+	return n << shift, nil
+}
+
+// VerifyDMARCReportDestinations checks the rua= and ruf= destinations of the DMARC record of a domain.
+// Destinations outside its organizational domain must publish a v=DMARC1 record at
+// <domain>._report._dmarc.<destination> (RFC 7489 section 7.1), otherwise receivers send them no report.
+func VerifyDMARCReportDestinations(ctx context.Context, resolver Resolver, domain string, dmarc *DMARCRecord) {
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+
+	// Several addresses often share a destination domain
+	type authorization struct {
+		record string
+		err    error
+	}
+	cache := make(map[string]authorization)
+
+	for _, uris := range [][]types.DMARCReportURI{dmarc.AggregateURIs, dmarc.FailureURIs} {
+		for i := range uris {
+			uri := &uris[i]
+			if uri.Domain == "" || OrganizationalDomain(uri.Domain) == OrganizationalDomain(domain) {
+				continue
+			}
+			uri.External = true
+
+			auth, ok := cache[uri.Domain]
+			if !ok {
+				auth.record, auth.err = lookupDMARCReportAuthorization(ctx, resolver, domain, uri.Domain)
+				cache[uri.Domain] = auth
+			}
+			switch {
+			case auth.err != nil:
+				uri.Error = auth.err.Error()
+			case auth.record == "":
+				uri.Error = fmt.Sprintf("%s does not authorize reports for %s: no v=DMARC1 record at %s._report._dmarc.%s", uri.Domain, domain, domain, uri.Domain)
+			default:
+				uri.Authorized = true
+				uri.AuthorizationRecord = auth.record
+			}
+		}
+	}
+}
+
+// lookupDMARCReportAuthorization returns the authorization record a destination domain publishes
+// for the reports of a domain, or "" when there is none.
+func lookupDMARCReportAuthorization(ctx context.Context, resolver Resolver, domain, destination string) (string, error) {
+	name := domain + "._report._dmarc." + destination
+	txts, err := resolver.LookupTXT(ctx, name)
+	if err != nil {
+		if isNotFound(err) {
+			return "", nil
+		}
+		return "", fmt.Errorf("authorization lookup for %s failed: %w", name, err)
+	}
+	for _, txt := range txts {
+		if txt = strings.TrimSpace(txt); txt == "v=DMARC1" || strings.HasPrefix(txt, "v=DMARC1;") || strings.HasPrefix(txt, "v=DMARC1 ") {
+			return txt, nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,157 @@
+// Package emailauth provides functionality for checking email authentication mechanisms.
+package emailauth
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"mxclone/pkg/types"
+)
+
+// dmarcMaxWalkLabels is the number of labels the DMARCbis tree walk jumps to for long domains,
+// so that a walk never needs more than eight queries.
+const dmarcMaxWalkLabels = 7
+
+// DMARC policy discovery lookup results
+const (
+	DMARCLookupRecord   = "record"
+	DMARCLookupNone     = "none"
+	DMARCLookupMultiple = "multiple"
+	DMARCLookupError    = "error"
+)
+
+// DiscoverDMARCPolicy finds the DMARC record that applies to a domain with the DMARCbis tree walk:
+// _dmarc.<domain> is queried first, then the parent domains up to the top-level domain, jumping
+// to the last seven labels for longer names. A domain without a record of its own inherits the
+// record found, with sp= applied, or np= when the domain does not exist (RFC 9091).
+func DiscoverDMARCPolicy(ctx context.Context, resolver Resolver, domain string) *types.DMARCPolicyDiscovery {
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	domain = strings.TrimSuffix(strings.ToLower(domain), ".")
+
+	discovery := &types.DMARCPolicyDiscovery{
+		Domain:               domain,
+		OrganizationalDomain: OrganizationalDomain(domain),
+	}
+
+	var record *DMARCRecord
+	for _, target := range dmarcTreeWalk(domain) {
+		lookup, parsed, err := lookupDMARCRecord(ctx, resolver, target)
+		discovery.Lookups = append(discovery.Lookups, lookup)
+		if err != nil {
+			// A failed query must not let a parent record apply in place of the domain's own
+			discovery.Error = err.Error()
+			discovery.Reason = "the policy discovery stopped on a DNS error"
+			return discovery
+		}
+		if parsed != nil {
+			record = parsed
+			discovery.PolicyDomain = target
+			discovery.Record = parsed.Raw
+			break
+		}
+	}
+
+	if record == nil {
+		discovery.Reason = fmt.Sprintf("no DMARC record at %s or any of its parent domains", domain)
+		return discovery
+	}
+
+	discovery.Inherited = discovery.PolicyDomain != domain
+	if !discovery.Inherited {
+		discovery.AppliedTag, discovery.AppliedPolicy = "p", record.Policy
+		discovery.Reason = fmt.Sprintf("%s publishes its own record; p= applies", domain)
+		return discovery
+	}
+
+	source := "organizational domain"
+	switch {
+	case record.PSD == "y":
+		source = "public suffix domain"
+	case discovery.PolicyDomain != discovery.OrganizationalDomain:
+		source = "parent domain"
+	}
+
+	discovery.NonExistent = !domainExists(ctx, resolver, domain)
+	switch {
+	case discovery.NonExistent && record.NPPolicy != "":
+		discovery.AppliedTag, discovery.AppliedPolicy = "np", record.NPPolicy
+		discovery.Reason = fmt.Sprintf("%s does not exist and has no record; np= of the %s %s applies", domain, source, discovery.PolicyDomain)
+	case record.SubPolicy != "":
+		discovery.AppliedTag, discovery.AppliedPolicy = "sp", record.SubPolicy
+		discovery.Reason = fmt.Sprintf("%s has no record; sp= of the %s %s applies", domain, source, discovery.PolicyDomain)
+	default:
+		discovery.AppliedTag, discovery.AppliedPolicy = "p", record.Policy
+		discovery.Reason = fmt.Sprintf("%s has no record; the %s %s sets no sp=, so its p= applies", domain, source, discovery.PolicyDomain)
+	}
+
+	return discovery
+}
+
+// dmarcTreeWalk returns the domains whose _dmarc record is queried, in order: the domain, then
+// its parents from at most seven labels down to the top-level domain.
+func dmarcTreeWalk(domain string) []string {
+	labels := strings.Split(domain, ".")
+	targets := []string{domain}
+
+	parents := labels[1:]
+	if len(parents) > dmarcMaxWalkLabels {
+		parents = parents[len(parents)-dmarcMaxWalkLabels:]
+	}
+	for i := range parents {
+		targets = append(targets, strings.Join(parents[i:], "."))
+	}
+	return targets
+}
+
+// lookupDMARCRecord queries _dmarc.<domain>. Exactly one v=DMARC1 record must be published;
+// none or several mean that the domain has no policy (RFC 7489 section 6.6.3).
+func lookupDMARCRecord(ctx context.Context, resolver Resolver, domain string) (types.DMARCLookup, *DMARCRecord, error) {
+	lookup := types.DMARCLookup{Name: "_dmarc." + domain, Result: DMARCLookupNone}
+
+	txts, err := resolver.LookupTXT(ctx, lookup.Name)
+	if err != nil {
+		if isNotFound(err) {
+			return lookup, nil, nil
+		}
+		lookup.Result = DMARCLookupError
+		return lookup, nil, fmt.Errorf("DMARC lookup for %s failed: %w", domain, err)
+	}
+
+	var records []*DMARCRecord
+	for _, txt := range txts {
+		if record, err := ParseDMARCRecord(strings.TrimSpace(txt)); err == nil {
+			records = append(records, record)
+		}
+	}
+	switch len(records) {
+	case 0:
+		return lookup, nil, nil
+	case 1:
+		lookup.Result = DMARCLookupRecord
+		lookup.Record = records[0].Raw
+		return lookup, records[0], nil
+	default:
+		lookup.Result = DMARCLookupMultiple
+		return lookup, nil, nil
+	}
+}
+
+// domainExists reports whether a domain exists for the np= tag. Resolvers do not tell NXDOMAIN
+// from an empty answer, so a name without A, AAAA, MX or TXT records counts as non-existent;
+// any other error is taken as existence.
+func domainExists(ctx context.Context, resolver Resolver, domain string) bool {
+	if _, err := resolver.LookupIP(ctx, "ip", domain); err == nil || !isNotFound(err) {
+		return true
+	}
+	if _, err := resolver.LookupMX(ctx, domain); err == nil || !isNotFound(err) {
+		return true
+	}
+	if _, err := resolver.LookupTXT(ctx, domain); err == nil || !isNotFound(err) {
+		return true
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+// Package emailauth provides functionality for checking email authentication mechanisms.
+package emailauth
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// TestDiscoverDMARCPolicy tests the DMARCbis tree walk and the policy applied to subdomains
+func TestDiscoverDMARCPolicy(t *testing.T) {
+	resolver := &fakeResolver{
+		txt: map[string][]string{
+			"_dmarc.example.com":       {"v=DMARC1; p=reject; sp=quarantine; np=reject"},
+			"_dmarc.own.example.com":   {"v=DMARC1; p=none"},
+			"_dmarc.example.net":       {"v=DMARC1; p=quarantine"},
+			"_dmarc.twice.example.org": {"v=DMARC1; p=none", "v=DMARC1; p=reject"},
+			"_dmarc.example.org":       {"v=DMARC1; p=reject"},
+			"_dmarc.broken.example":    {"v=DMARC1; p=none"},
+			"_dmarc.example.co.uk":     {"v=DMARC1; p=reject"},
+		},
+		mx: map[string][]string{
+			"mail.example.com": {"mx.example.com"},
+			"www.example.net":  {"mx.example.net"},
+		},
+		fail: map[string]bool{"_dmarc.sub.broken.example": true},
+	}
+
+	tests := []struct {
+		domain       string
+		policyDomain string
+		tag          string
+		policy       string
+		inherited    bool
+		nonExistent  bool
+		lookups      int
+	}{
+		{"example.com", "example.com", "p", "reject", false, false, 1},
+		{"own.example.com", "own.example.com", "p", "none", false, false, 1},
+		{"mail.example.com", "example.com", "sp", "quarantine", true, false, 2},
+		{"ghost.example.com", "example.com", "np", "reject", true, true, 2},
+		{"a.b.ghost.example.com", "example.com", "np", "reject", true, true, 4},
+		{"www.example.net", "example.net", "p", "quarantine", true, false, 2},
+		// Two records mean no policy at that level; the walk continues
+		{"twice.example.org", "example.org", "p", "reject", true, true, 2},
+		{"shop.example.co.uk", "example.co.uk", "p", "reject", true, true, 2},
+		{"nothing.example", "", "", "", false, false, 2},
+	}
+	for _, tt := range tests {
+		discovery := DiscoverDMARCPolicy(context.Background(), resolver, tt.domain)
+		if discovery.PolicyDomain != tt.policyDomain || discovery.AppliedTag != tt.tag || discovery.AppliedPolicy != tt.policy {
+			t.Errorf("%s: policy %s %s=%s, want %s %s=%s (%s)", tt.domain, discovery.PolicyDomain, discovery.AppliedTag, discovery.AppliedPolicy,
+				tt.policyDomain, tt.tag, tt.policy, discovery.Reason)
+		}
+		if discovery.Inherited != tt.inherited || discovery.NonExistent != tt.nonExistent {
+			t.Errorf("%s: Inherited = %v, NonExistent = %v, want %v, %v", tt.domain, discovery.Inherited, discovery.NonExistent, tt.inherited, tt.nonExistent)
+		}
+		if len(discovery.Lookups) != tt.lookups {
+			t.Errorf("%s: %d lookups %+v, want %d", tt.domain, len(discovery.Lookups), discovery.Lookups, tt.lookups)
+		}
+		if discovery.Reason == "" {
+			t.Errorf("%s: no reason", tt.domain)
+		}
+	}
+
+	discovery := DiscoverDMARCPolicy(context.Background(), resolver, "twice.example.org")
+	if discovery.Lookups[0].Result != DMARCLookupMultiple {
+		t.Errorf("Lookups[0] = %+v, want multiple records", discovery.Lookups[0])
+	}
+	if discovery.OrganizationalDomain != "example.org" {
+		t.Errorf("OrganizationalDomain = %q, want example.org", discovery.OrganizationalDomain)
+	}
+
+	// A DNS error stops the walk instead of applying the parent record
+	discovery = DiscoverDMARCPolicy(context.Background(), resolver, "sub.broken.example")
+	if discovery.Error == "" || discovery.Record != "" || len(discovery.Lookups) != 1 {
+		t.Errorf("discovery = %+v, want an error after one lookup", discovery)
+	}
+}
+
+// TestDMARCTreeWalk tests the queried names, with the jump to seven labels for long domains
+func TestDMARCTreeWalk(t *testing.T) {
+	tests := map[string]string{
+		"example.com":           "example.com,com",
+		"a.b.example.com":       "a.b.example.com,b.example.com,example.com,com",
+		"a.b.c.d.e.f.g.h.i.com": "a.b.c.d.e.f.g.h.i.com,d.e.f.g.h.i.com,e.f.g.h.i.com,f.g.h.i.com,g.h.i.com,h.i.com,i.com,com",
+	}
+	for domain, want := range tests {
+		if got := strings.Join(dmarcTreeWalk(domain), ","); got != want {
+			t.Errorf("dmarcTreeWalk(%q) = %s, want %s", domain, got, want)
+		}
+	}
+}
@@ -0,0 +1,288 @@
+// Package emailauth provides functionality for checking email authentication mechanisms.
+package emailauth
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"mxclone/pkg/types"
+)
+
+// maxDMARCReportSize bounds the decompressed size of an uploaded report file, so that a
+// compressed upload cannot expand without limit.
+const maxDMARCReportSize = 64 << 20
+
+// maxDMARCArchiveEntries bounds the number of files read from a zip archive of reports.
+const maxDMARCArchiveEntries = 1000
+
+// dmarcFeedback mirrors the aggregate report schema of RFC 7489 appendix C. Element names carry
+// no namespace so that DMARCbis reports, which declare one, are read the same way.
+type dmarcFeedback struct {
+	XMLName        xml.Name `xml:"feedback"`
+	ReportMetadata struct {
+		OrgName   string `xml:"org_name"`
+		Email     string `xml:"email"`
+		ReportID  string `xml:"report_id"`
+		DateRange struct {
+			Begin string `xml:"begin"`
+			End   string `xml:"end"`
+		} `xml:"date_range"`
+	} `xml:"report_metadata"`
+	PolicyPublished struct {
+		Domain string `xml:"domain"`
+		ADKIM  string `xml:"adkim"`
+		ASPF   string `xml:"aspf"`
+		P      string `xml:"p"`
+		SP     string `xml:"sp"`
+		Pct    string `xml:"pct"`
+	} `xml:"policy_published"`
+	Records []struct {
+		Row struct {
+			SourceIP        string `xml:"source_ip"`
+			Count           string `xml:"count"`
+			PolicyEvaluated struct {
+				Disposition string `xml:"disposition"`
+				DKIM        string `xml:"dkim"`
+				SPF         string `xml:"spf"`
+				Reasons     []struct {
+					Type    string `xml:"type"`
+					Comment string `xml:"comment"`
+				} `xml:"reason"`
+			} `xml:"policy_evaluated"`
+		} `xml:"row"`
+		Identifiers struct {
+			EnvelopeFrom string `xml:"envelope_from"`
+			HeaderFrom   string `xml:"header_from"`
+		} `xml:"identifiers"`
+		AuthResults struct {
+			DKIM []struct {
+				Domain   string `xml:"domain"`
+				Selector string `xml:"selector"`
+				Result   string `xml:"result"`
+			} `xml:"dkim"`
+			SPF []struct {
+				Domain string `xml:"domain"`
+				Scope  string `xml:"scope"`
+				Result string `xml:"result"`
+			} `xml:"spf"`
+		} `xml:"auth_results"`
+	} `xml:"record"`
+}
+
+// ParseDMARCAggregateReports reads DMARC aggregate reports from a file as mailbox providers send
+// them: plain XML, gzip-compressed XML or a zip archive of plain or gzip-compressed reports. The
+// format is detected from the content, not from the name, which is only used in error messages.
+// A file decompresses to at most maxDMARCReportSize bytes in total; an archive holds at most
+// maxDMARCArchiveEntries files and no other archive.
+func ParseDMARCAggregateReports(name string, data []byte) ([]*types.DMARCAggregateReport, error) {
+	budget := int64(maxDMARCReportSize)
+	if !bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		report, err := parseDMARCReportFile(name, data, &budget)
+		if err != nil {
+			return nil, err
+		}
+		return []*types.DMARCAggregateReport{report}, nil
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	var reports []*types.DMARCAggregateReport
+	entries := 0
+	for _, file := range zr.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+		if entries++; entries > maxDMARCArchiveEntries {
+			return nil, fmt.Errorf("%s: the archive holds more than %d files", name, maxDMARCArchiveEntries)
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", name, file.Name, err)
+		}
+		entry, err := readDMARCReport(rc, &budget)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", name, file.Name, err)
+		}
+		if bytes.HasPrefix(entry, []byte("PK\x03\x04")) {
+			return nil, fmt.Errorf("%s: %s: archives inside an archive are not read", name, file.Name)
+		}
+		// Archives sometimes hold compressed reports
+		report, err := parseDMARCReportFile(name+": "+path.Base(file.Name), entry, &budget)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, report)
+	}
+	if len(reports) == 0 {
+		return nil, fmt.Errorf("%s: the archive holds no report", name)
+	}
+	return reports, nil
+}
+
+// parseDMARCReportFile parses a plain or gzip-compressed report, taking the decompressed size
+// off budget.
+func parseDMARCReportFile(name string, data []byte, budget *int64) (*types.DMARCAggregateReport, error) {
+	if bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		defer zr.Close()
+		if data, err = readDMARCReport(zr, budget); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	report, err := ParseDMARCAggregateReport(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return report, nil
+}
+
+// readDMARCReport reads decompressed data, taking its size off budget and failing once the
+// file as a whole decompresses to more than maxDMARCReportSize bytes.
+func readDMARCReport(r io.Reader, budget *int64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, *budget+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > *budget {
+		return nil, fmt.Errorf("decompresses to more than %d bytes", maxDMARCReportSize)
+	}
+	*budget -= int64(len(data))
+	return data, nil
+}
+
+// ParseDMARCAggregateReport parses the XML of one aggregate report. The report ID, the
+// published domain and a valid source IP and message count for every record are required.
+func ParseDMARCAggregateReport(data []byte) (*types.DMARCAggregateReport, error) {
+	var feedback dmarcFeedback
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.CharsetReader = dmarcCharsetReader
+	if err := decoder.Decode(&feedback); err != nil {
+		return nil, fmt.Errorf("not a DMARC aggregate report: %w", err)
+	}
+
+	meta, policy := feedback.ReportMetadata, feedback.PolicyPublished
+	report := &types.DMARCAggregateReport{
+		OrgName:   strings.TrimSpace(meta.OrgName),
+		Email:     strings.TrimSpace(meta.Email),
+		ReportID:  strings.TrimSpace(meta.ReportID),
+		Domain:    strings.TrimSuffix(strings.ToLower(strings.TrimSpace(policy.Domain)), "."),
+		ADKIM:     strings.ToLower(strings.TrimSpace(policy.ADKIM)),
+		ASPF:      strings.ToLower(strings.TrimSpace(policy.ASPF)),
+		Policy:    strings.ToLower(strings.TrimSpace(policy.P)),
+		SubPolicy: strings.ToLower(strings.TrimSpace(policy.SP)),
+		Records:   make([]types.DMARCAggregateRecord, 0, len(feedback.Records)),
+	}
+	if report.ReportID == "" {
+		return nil, fmt.Errorf("the report has no report_id")
+	}
+	if report.Domain == "" {
+		return nil, fmt.Errorf("report %s has no policy_published domain", report.ReportID)
+	}
+	if pct := strings.TrimSpace(policy.Pct); pct != "" {
+		report.Percent, _ = strconv.Atoi(pct)
+	}
+
+	var err error
+	if report.Begin, err = parseDMARCTimestamp(meta.DateRange.Begin); err != nil {
+		return nil, fmt.Errorf("report %s: invalid date_range begin: %w", report.ReportID, err)
+	}
+	if report.End, err = parseDMARCTimestamp(meta.DateRange.End); err != nil {
+		return nil, fmt.Errorf("report %s: invalid date_range end: %w", report.ReportID, err)
+	}
+
+	for i, rec := range feedback.Records {
+		row := rec.Row
+		ip := net.ParseIP(strings.TrimSpace(row.SourceIP))
+		if ip == nil {
+			return nil, fmt.Errorf("report %s: record %d has an invalid source_ip %q", report.ReportID, i+1, row.SourceIP)
+		}
+		count, err := strconv.Atoi(strings.TrimSpace(row.Count))
+		if err != nil || count < 0 {
+			return nil, fmt.Errorf("report %s: record %d has an invalid count %q", report.ReportID, i+1, row.Count)
+		}
+
+		record := types.DMARCAggregateRecord{
+			SourceIP:     ip.String(),
+			Count:        count,
+			Disposition:  strings.ToLower(strings.TrimSpace(row.PolicyEvaluated.Disposition)),
+			DKIM:         strings.ToLower(strings.TrimSpace(row.PolicyEvaluated.DKIM)),
+			SPF:          strings.ToLower(strings.TrimSpace(row.PolicyEvaluated.SPF)),
+			HeaderFrom:   strings.TrimSuffix(strings.ToLower(strings.TrimSpace(rec.Identifiers.HeaderFrom)), "."),
+			EnvelopeFrom: strings.TrimSuffix(strings.ToLower(strings.TrimSpace(rec.Identifiers.EnvelopeFrom)), "."),
+		}
+		for _, reason := range row.PolicyEvaluated.Reasons {
+			text := strings.TrimSpace(reason.Type)
+			if comment := strings.TrimSpace(reason.Comment); comment != "" {
+				text += ": " + comment
+			}
+			if text != "" {
+				record.Reasons = append(record.Reasons, text)
+			}
+		}
+		for _, dkim := range rec.AuthResults.DKIM {
+			record.DKIMResults = append(record.DKIMResults, types.DMARCAuthResult{
+				Domain:   strings.ToLower(strings.TrimSpace(dkim.Domain)),
+				Selector: strings.TrimSpace(dkim.Selector),
+				Result:   strings.ToLower(strings.TrimSpace(dkim.Result)),
+			})
+		}
+		for _, spf := range rec.AuthResults.SPF {
+			record.SPFResults = append(record.SPFResults, types.DMARCAuthResult{
+				Domain: strings.ToLower(strings.TrimSpace(spf.Domain)),
+				Scope:  strings.ToLower(strings.TrimSpace(spf.Scope)),
+				Result: strings.ToLower(strings.TrimSpace(spf.Result)),
+			})
+		}
+		report.Records = append(report.Records, record)
+	}
+
+	return report, nil
+}
+
+// parseDMARCTimestamp parses a date_range bound, in seconds since the epoch.
+func parseDMARCTimestamp(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(seconds, 0).UTC(), nil
+}
+
+// dmarcCharsetReader accepts the single-byte encodings some reporters declare in place of UTF-8.
+// Windows-1252 is read as ISO-8859-1; the two differ only in characters reports do not use.
+func dmarcCharsetReader(charset string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(charset) {
+	case "us-ascii", "ascii":
+		return input, nil
+	case "iso-8859-1", "latin1", "windows-1252", "cp1252":
+		data, err := io.ReadAll(io.LimitReader(input, maxDMARCReportSize))
+		if err != nil {
+			return nil, err
+		}
+		runes := make([]rune, len(data))
+		for i, b := range data {
+			runes[i] = rune(b)
+		}
+		return strings.NewReader(string(runes)), nil
+	}
+	return nil, fmt.Errorf("unsupported charset %s", charset)
+}
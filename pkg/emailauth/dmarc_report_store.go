@@ -0,0 +1,393 @@
+// Package emailauth provides functionality for checking email authentication mechanisms.
+package emailauth
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"mxclone/pkg/types"
+)
+
+// DMARCReportStore keeps DMARC aggregate reports in a local file, one JSON report per line.
+// Reports are only ever appended; a report already stored is recognized by its reporting
+// organization, report ID and domain and skipped.
+//
+// The reports file has an index next to it (<path>.idx) with one line per report: a hash of
+// the unique key, where the report is in the reports file, and its domain and date range. Adding
+// reports reads only the index lines written since the store last looked, and queries read only
+// the reports that match. Processes sharing the store take an exclusive lock on <path>.lock for
+// every operation, so concurrent ingests never store a report twice.
+//
+// Growth limits: reports are never removed or compacted, so the reports file grows with every
+// ingest (a report of a few dozen sources is 2 to 10 KB). The index takes about 120 bytes per
+// report on disk and is held in memory at about twice that, so a store of a million reports needs
+// some 250 MB. To expire old reports, remove the reports file and its index; a missing or damaged
+// index is rebuilt from the reports file.
+type DMARCReportStore struct {
+	path string
+	mu   sync.Mutex
+
+	// Index entries read so far, the keys they hold and the length of index file they cover
+	entries   []dmarcReportIndexEntry
+	keys      map[string]bool
+	indexSize int64
+}
+
+// dmarcReportIndexEntry is a line of the index: a report's key hash, position and filter fields.
+type dmarcReportIndexEntry struct {
+	key    string
+	offset int64
+	length int64
+	begin  int64
+	end    int64
+	domain string
+}
+
+// DMARCReportFilter selects stored reports. Empty fields match every report.
+type DMARCReportFilter struct {
+	// Published domain; subdomains match too
+	Domain string
+	// Reports whose date range overlaps [Since, Until]
+	Since time.Time
+	Until time.Time
+}
+
+// NewDMARCReportStore returns the store kept in the file at path, which is created on the first Add.
+func NewDMARCReportStore(path string) *DMARCReportStore {
+	return &DMARCReportStore{path: path}
+}
+
+// Path returns the file the reports are kept in.
+func (s *DMARCReportStore) Path() string {
+	return s.path
+}
+
+// Add stores the reports that are not stored yet. It returns how many were added and how
+// many were skipped as duplicates.
+func (s *DMARCReportStore) Add(reports []*types.DMARCAggregateReport) (added, duplicates int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return 0, 0, err
+	}
+	data, index, unlock, err := s.open(os.O_CREATE)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer unlock()
+
+	end := s.dataEnd()
+	var records, lines bytes.Buffer
+	var pending []dmarcReportIndexEntry
+	seen := make(map[string]bool)
+	for _, report := range reports {
+		key := dmarcReportKeyHash(report)
+		if s.keys[key] || seen[key] {
+			duplicates++
+			continue
+		}
+		seen[key] = true
+
+		record, err := json.Marshal(report)
+		if err != nil {
+			return 0, 0, err
+		}
+		record = append(record, '\n')
+		entry := newDMARCReportIndexEntry(key, end+int64(records.Len()), int64(len(record)), report)
+		records.Write(record)
+		lines.WriteString(entry.String())
+		pending = append(pending, entry)
+	}
+	if len(pending) == 0 {
+		return 0, duplicates, nil
+	}
+
+	// Reports are written before their index lines: after a crash in between, the next
+	// operation finds reports past the end of the index and indexes them
+	if _, err := data.WriteAt(records.Bytes(), end); err != nil {
+		return 0, 0, err
+	}
+	if err := data.Sync(); err != nil {
+		return 0, 0, err
+	}
+	if _, err := index.WriteAt(lines.Bytes(), s.indexSize); err != nil {
+		return 0, 0, err
+	}
+	if err := index.Sync(); err != nil {
+		return 0, 0, err
+	}
+	s.indexSize += int64(lines.Len())
+	for _, entry := range pending {
+		s.addEntry(entry)
+	}
+	return len(pending), duplicates, nil
+}
+
+// Reports returns the stored reports matching a filter, in the order they were added.
+func (s *DMARCReportStore) Reports(filter DMARCReportFilter) ([]*types.DMARCAggregateReport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, _, unlock, err := s.open(0)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer unlock()
+
+	domain := strings.TrimSuffix(strings.ToLower(filter.Domain), ".")
+	var reports []*types.DMARCAggregateReport
+	for _, entry := range s.entries {
+		if domain != "" && entry.domain != domain && !strings.HasSuffix(entry.domain, "."+domain) {
+			continue
+		}
+		if !filter.Since.IsZero() && entry.end < filter.Since.Unix() {
+			continue
+		}
+		if !filter.Until.IsZero() && entry.begin > filter.Until.Unix() {
+			continue
+		}
+
+		record := make([]byte, entry.length)
+		if _, err := data.ReadAt(record, entry.offset); err != nil {
+			return nil, fmt.Errorf("%s: corrupt report store: %w", s.path, err)
+		}
+		var report types.DMARCAggregateReport
+		if err := json.Unmarshal(record, &report); err != nil {
+			return nil, fmt.Errorf("%s: corrupt report store: %w", s.path, err)
+		}
+		reports = append(reports, &report)
+	}
+	return reports, nil
+}
+
+// open locks the store and opens the reports and index files, with flag added to the open flags
+// of the reports file, and brings the index up to date. The returned function closes the files
+// and releases the lock.
+func (s *DMARCReportStore) open(flag int) (data, index *os.File, unlock func(), err error) {
+	if _, err := os.Stat(s.path); err != nil && flag&os.O_CREATE == 0 {
+		return nil, nil, nil, err
+	}
+
+	release, err := lockFile(s.path + ".lock")
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	data, err = os.OpenFile(s.path, os.O_RDWR|flag, 0o644)
+	if err != nil {
+		release()
+		return nil, nil, nil, err
+	}
+	index, err = os.OpenFile(s.path+".idx", os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		data.Close()
+		release()
+		return nil, nil, nil, err
+	}
+	unlock = func() {
+		index.Close()
+		data.Close()
+		release()
+	}
+
+	if err := s.refresh(data, index); err != nil {
+		unlock()
+		return nil, nil, nil, err
+	}
+	return data, index, unlock, nil
+}
+
+// refresh reads the index lines written since the last call, and indexes reports that are in
+// the reports file but not in the index. It must be called with the store locked.
+func (s *DMARCReportStore) refresh(data, index *os.File) error {
+	indexInfo, err := index.Stat()
+	if err != nil {
+		return err
+	}
+	if indexInfo.Size() < s.indexSize {
+		// The index was removed or truncated by another process: read it again
+		s.resetIndex()
+	}
+
+	if indexInfo.Size() > s.indexSize {
+		buf := make([]byte, indexInfo.Size()-s.indexSize)
+		if _, err := index.ReadAt(buf, s.indexSize); err != nil {
+			return err
+		}
+		// A line cut short by a crash is dropped; its report is indexed again below
+		complete := bytes.LastIndexByte(buf, '\n') + 1
+		var entries []dmarcReportIndexEntry
+		for _, line := range strings.Split(string(buf[:complete]), "\n") {
+			if line == "" {
+				continue
+			}
+			entry, err := parseDMARCReportIndexEntry(line)
+			if err != nil {
+				entries = nil
+				s.resetIndex()
+				complete = 0
+				break
+			}
+			entries = append(entries, entry)
+		}
+		for _, entry := range entries {
+			s.addEntry(entry)
+		}
+		s.indexSize += int64(complete)
+		if s.indexSize < indexInfo.Size() {
+			if err := index.Truncate(s.indexSize); err != nil {
+				return err
+			}
+		}
+	}
+
+	dataInfo, err := data.Stat()
+	if err != nil {
+		return err
+	}
+	end := s.dataEnd()
+	if end > dataInfo.Size() {
+		// The reports file was replaced: the index no longer describes it
+		s.resetIndex()
+		if err := index.Truncate(0); err != nil {
+			return err
+		}
+		end = 0
+	}
+	if end < dataInfo.Size() {
+		return s.indexReports(data, index, end, dataInfo.Size())
+	}
+	return nil
+}
+
+// indexReports adds the reports between offsets start and end of the reports file to the index.
+// A last report cut short by a crash is removed from the reports file.
+func (s *DMARCReportStore) indexReports(data, index *os.File, start, end int64) error {
+	reader := bufio.NewReader(io.NewSectionReader(data, start, end-start))
+	offset := start
+	var lines bytes.Buffer
+	var entries []dmarcReportIndexEntry
+	for {
+		record, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			if len(record) > 0 {
+				if err := data.Truncate(offset); err != nil {
+					return err
+				}
+			}
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		var report types.DMARCAggregateReport
+		if err := json.Unmarshal(record, &report); err != nil {
+			return fmt.Errorf("%s: corrupt report store at offset %d: %w", s.path, offset, err)
+		}
+		entry := newDMARCReportIndexEntry(dmarcReportKeyHash(&report), offset, int64(len(record)), &report)
+		lines.WriteString(entry.String())
+		entries = append(entries, entry)
+		offset += int64(len(record))
+	}
+
+	if lines.Len() == 0 {
+		return nil
+	}
+	if _, err := index.WriteAt(lines.Bytes(), s.indexSize); err != nil {
+		return err
+	}
+	if err := index.Sync(); err != nil {
+		return err
+	}
+	s.indexSize += int64(lines.Len())
+	for _, entry := range entries {
+		s.addEntry(entry)
+	}
+	return nil
+}
+
+// addEntry adds an index entry to the in-memory index.
+func (s *DMARCReportStore) addEntry(entry dmarcReportIndexEntry) {
+	if s.keys == nil {
+		s.keys = make(map[string]bool)
+	}
+	s.entries = append(s.entries, entry)
+	s.keys[entry.key] = true
+}
+
+// resetIndex forgets the in-memory index.
+func (s *DMARCReportStore) resetIndex() {
+	s.entries = nil
+	s.keys = nil
+	s.indexSize = 0
+}
+
+// dataEnd returns the offset in the reports file after the last indexed report.
+func (s *DMARCReportStore) dataEnd() int64 {
+	if len(s.entries) == 0 {
+		return 0
+	}
+	last := s.entries[len(s.entries)-1]
+	return last.offset + last.length
+}
+
+// newDMARCReportIndexEntry returns the index entry of a report stored at offset.
+func newDMARCReportIndexEntry(key string, offset, length int64, report *types.DMARCAggregateReport) dmarcReportIndexEntry {
+	domain := strings.ToLower(report.Domain)
+	if domain == "" {
+		domain = "-"
+	}
+	return dmarcReportIndexEntry{
+		key:    key,
+		offset: offset,
+		length: length,
+		begin:  report.Begin.Unix(),
+		end:    report.End.Unix(),
+		domain: domain,
+	}
+}
+
+// String returns the index line of an entry: key, offset, length, begin, end and domain.
+func (e dmarcReportIndexEntry) String() string {
+	return fmt.Sprintf("%s %d %d %d %d %s\n", e.key, e.offset, e.length, e.begin, e.end, e.domain)
+}
+
+// parseDMARCReportIndexEntry parses an index line.
+func parseDMARCReportIndexEntry(line string) (dmarcReportIndexEntry, error) {
+	fields := strings.Fields(line)
+	if len(fields) != 6 || len(fields[0]) != sha256.Size*2 {
+		return dmarcReportIndexEntry{}, fmt.Errorf("invalid index line %q", line)
+	}
+	entry := dmarcReportIndexEntry{key: fields[0], domain: fields[5]}
+	for i, field := range []*int64{&entry.offset, &entry.length, &entry.begin, &entry.end} {
+		value, err := strconv.ParseInt(fields[i+1], 10, 64)
+		if err != nil {
+			return dmarcReportIndexEntry{}, fmt.Errorf("invalid index line %q", line)
+		}
+		*field = value
+	}
+	return entry, nil
+}
+
+// dmarcReportKeyHash identifies a report: report IDs are only unique per reporting organization.
+func dmarcReportKeyHash(report *types.DMARCAggregateReport) string {
+	key := strings.ToLower(report.OrgName) + "\x00" + report.ReportID + "\x00" + report.Domain
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,424 @@
+// Package emailauth provides functionality for checking email authentication mechanisms.
+package emailauth
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"mxclone/pkg/types"
+)
+
+// sampleDMARCReport is an aggregate report as sent by a mailbox provider
+const sampleDMARCReport = `<?xml version="1.0" encoding="UTF-8" ?>
+<feedback>
+  <report_metadata>
+    <org_name>google.com</org_name>
+    <email>noreply-dmarc-support@google.com</email>
+    <report_id>12345678901234567890</report_id>
+    <date_range>
+      <begin>1700006400</begin>
+      <end>1700092799</end>
+    </date_range>
+  </report_metadata>
+  <policy_published>
+    <domain>example.com</domain>
+    <adkim>r</adkim>
+    <aspf>r</aspf>
+    <p>quarantine</p>
+    <sp>none</sp>
+    <pct>100</pct>
+  </policy_published>
+  <record>
+    <row>
+      <source_ip>192.0.2.10</source_ip>
+      <count>12</count>
+      <policy_evaluated>
+        <disposition>none</disposition>
+        <dkim>pass</dkim>
+        <spf>pass</spf>
+      </policy_evaluated>
+    </row>
+    <identifiers>
+      <header_from>example.com</header_from>
+    </identifiers>
+    <auth_results>
+      <dkim>
+        <domain>example.com</domain>
+        <selector>s1</selector>
+        <result>pass</result>
+      </dkim>
+      <spf>
+        <domain>example.com</domain>
+        <result>pass</result>
+      </spf>
+    </auth_results>
+  </record>
+  <record>
+    <row>
+      <source_ip>2001:DB8::25</source_ip>
+      <count>3</count>
+      <policy_evaluated>
+        <disposition>quarantine</disposition>
+        <dkim>fail</dkim>
+        <spf>fail</spf>
+        <reason>
+          <type>forwarded</type>
+          <comment>list</comment>
+        </reason>
+      </policy_evaluated>
+    </row>
+    <identifiers>
+      <envelope_from>bounces.example.net</envelope_from>
+      <header_from>Example.COM</header_from>
+    </identifiers>
+    <auth_results>
+      <spf>
+        <domain>bounces.example.net</domain>
+        <scope>mfrom</scope>
+        <result>softfail</result>
+      </spf>
+    </auth_results>
+  </record>
+</feedback>
+`
+
+// TestParseDMARCAggregateReports tests plain, gzip and zip reports
+func TestParseDMARCAggregateReports(t *testing.T) {
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte(sampleDMARCReport))
+	zw.Close()
+
+	var archive bytes.Buffer
+	aw := zip.NewWriter(&archive)
+	w, _ := aw.Create("google.com!example.com!1700006400!1700092799.xml")
+	w.Write([]byte(sampleDMARCReport))
+	w, _ = aw.Create("nested/report.xml.gz")
+	w.Write(gz.Bytes())
+	aw.Close()
+
+	tests := []struct {
+		name  string
+		data  []byte
+		count int
+	}{
+		{"report.xml", []byte(sampleDMARCReport), 1},
+		{"report.xml.gz", gz.Bytes(), 1},
+		{"report.zip", archive.Bytes(), 2},
+	}
+	for _, tt := range tests {
+		reports, err := ParseDMARCAggregateReports(tt.name, tt.data)
+		if err != nil {
+			t.Errorf("%s: error = %v", tt.name, err)
+			continue
+		}
+		if len(reports) != tt.count {
+			t.Errorf("%s: %d reports, want %d", tt.name, len(reports), tt.count)
+		}
+	}
+
+	report, err := ParseDMARCAggregateReport([]byte(sampleDMARCReport))
+	if err != nil {
+		t.Fatalf("ParseDMARCAggregateReport() error = %v", err)
+	}
+	if report.OrgName != "google.com" || report.ReportID != "12345678901234567890" || report.Domain != "example.com" ||
+		report.Policy != "quarantine" || report.SubPolicy != "none" || report.Percent != 100 {
+		t.Errorf("report = %+v", report)
+	}
+	if !report.Begin.Equal(time.Unix(1700006400, 0)) || !report.End.Equal(time.Unix(1700092799, 0)) {
+		t.Errorf("date range = %v - %v", report.Begin, report.End)
+	}
+	if len(report.Records) != 2 {
+		t.Fatalf("Records = %+v, want 2", report.Records)
+	}
+	second := report.Records[1]
+	if second.SourceIP != "2001:db8::25" || second.Count != 3 || second.Disposition != "quarantine" || second.HeaderFrom != "example.com" {
+		t.Errorf("second record = %+v", second)
+	}
+	if strings.Join(second.Reasons, ",") != "forwarded: list" {
+		t.Errorf("Reasons = %v", second.Reasons)
+	}
+	if len(second.SPFResults) != 1 || second.SPFResults[0].Result != "softfail" || second.SPFResults[0].Scope != "mfrom" {
+		t.Errorf("SPFResults = %+v", second.SPFResults)
+	}
+
+	// Reporters declaring a single-byte encoding
+	latin1 := strings.Replace(sampleDMARCReport, "UTF-8", "ISO-8859-1", 1)
+	latin1 = strings.Replace(latin1, "<org_name>google.com", "<org_name>r\xe9seau.example", 1)
+	if report, err := ParseDMARCAggregateReport([]byte(latin1)); err != nil || report.OrgName != "réseau.example" {
+		t.Errorf("ISO-8859-1 report: OrgName = %v, error = %v", report, err)
+	}
+
+	for name, bad := range map[string]string{
+		"not xml":      "hello",
+		"no report id": strings.Replace(sampleDMARCReport, "<report_id>12345678901234567890</report_id>", "", 1),
+		"no domain":    strings.Replace(sampleDMARCReport, "<domain>example.com</domain>\n    <adkim>", "<adkim>", 1),
+		"bad ip":       strings.Replace(sampleDMARCReport, "192.0.2.10", "192.0.2", 1),
+		"bad count":    strings.Replace(sampleDMARCReport, "<count>12</count>", "<count>many</count>", 1),
+		"other root":   "<html><body/></html>",
+	} {
+		if _, err := ParseDMARCAggregateReports(name, []byte(bad)); err == nil {
+			t.Errorf("%s: error = nil, want an error", name)
+		}
+	}
+}
+
+// TestParseDMARCAggregateReportsLimits tests that archives inside an archive, too many files and
+// archives decompressing past the size limit are refused
+func TestParseDMARCAggregateReportsLimits(t *testing.T) {
+	zipFiles := func(files map[string][]byte) []byte {
+		var archive bytes.Buffer
+		aw := zip.NewWriter(&archive)
+		for name, data := range files {
+			w, _ := aw.Create(name)
+			w.Write(data)
+		}
+		aw.Close()
+		return archive.Bytes()
+	}
+
+	inner := zipFiles(map[string][]byte{"report.xml": []byte(sampleDMARCReport)})
+	nested := zipFiles(map[string][]byte{"inner.zip": inner})
+
+	// Each report is valid on its own, but the two together decompress past the limit
+	padded := []byte(sampleDMARCReport + strings.Repeat(" ", maxDMARCReportSize/2))
+	oversized := zipFiles(map[string][]byte{"a.xml": padded, "b.xml": padded})
+
+	many := make(map[string][]byte)
+	for i := 0; i <= maxDMARCArchiveEntries; i++ {
+		many[fmt.Sprintf("%d.xml", i)] = []byte(sampleDMARCReport)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"nested.zip", nested, "archives inside an archive are not read"},
+		{"oversized.zip", oversized, fmt.Sprintf("decompresses to more than %d bytes", maxDMARCReportSize)},
+		{"many.zip", zipFiles(many), fmt.Sprintf("more than %d files", maxDMARCArchiveEntries)},
+	}
+	for _, tt := range tests {
+		reports, err := ParseDMARCAggregateReports(tt.name, tt.data)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: reports = %d, error = %v, want %q", tt.name, len(reports), err, tt.want)
+		}
+	}
+
+	if reports, err := ParseDMARCAggregateReports("inner.zip", inner); err != nil || len(reports) != 1 {
+		t.Errorf("inner.zip: reports = %d, error = %v", len(reports), err)
+	}
+}
+
+// TestDMARCReportStore tests duplicate detection and filters
+func TestDMARCReportStore(t *testing.T) {
+	store := NewDMARCReportStore(filepath.Join(t.TempDir(), "dmarc", "reports.jsonl"))
+
+	if reports, err := store.Reports(DMARCReportFilter{}); err != nil || len(reports) != 0 {
+		t.Fatalf("empty store: %d reports, error = %v", len(reports), err)
+	}
+
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	report := func(org, id, domain string, begin time.Time) *types.DMARCAggregateReport {
+		return &types.DMARCAggregateReport{OrgName: org, ReportID: id, Domain: domain, Begin: begin, End: begin.Add(24*time.Hour - time.Second),
+			Records: []types.DMARCAggregateRecord{{SourceIP: "192.0.2.1", Count: 1}}}
+	}
+
+	added, duplicates, err := store.Add([]*types.DMARCAggregateReport{
+		report("google.com", "1", "example.com", day),
+		report("Yahoo", "1", "example.com", day),
+		report("google.com", "2", "mail.example.com", day.AddDate(0, 0, 1)),
+		report("google.com", "1", "example.com", day),
+	})
+	if err != nil || added != 3 || duplicates != 1 {
+		t.Fatalf("Add() = %d, %d, %v, want 3 added and 1 duplicate", added, duplicates, err)
+	}
+	added, duplicates, err = store.Add([]*types.DMARCAggregateReport{
+		report("yahoo", "1", "example.com", day),
+		report("google.com", "3", "example.org", day.AddDate(0, 0, 2)),
+	})
+	if err != nil || added != 1 || duplicates != 1 {
+		t.Fatalf("second Add() = %d, %d, %v, want 1 added and 1 duplicate", added, duplicates, err)
+	}
+
+	tests := []struct {
+		filter DMARCReportFilter
+		want   int
+	}{
+		{DMARCReportFilter{}, 4},
+		{DMARCReportFilter{Domain: "example.com"}, 3},
+		{DMARCReportFilter{Domain: "mail.example.com"}, 1},
+		{DMARCReportFilter{Since: day.AddDate(0, 0, 1)}, 2},
+		{DMARCReportFilter{Until: day.Add(time.Hour)}, 2},
+		{DMARCReportFilter{Domain: "example.com", Since: day.AddDate(0, 0, 1), Until: day.AddDate(0, 0, 1)}, 1},
+	}
+	for _, tt := range tests {
+		reports, err := store.Reports(tt.filter)
+		if err != nil || len(reports) != tt.want {
+			t.Errorf("Reports(%+v) = %d reports, error = %v, want %d", tt.filter, len(reports), err, tt.want)
+		}
+	}
+
+	reports, _ := store.Reports(DMARCReportFilter{Domain: "example.org"})
+	if len(reports) != 1 || len(reports[0].Records) != 1 || reports[0].Records[0].SourceIP != "192.0.2.1" {
+		t.Errorf("stored report = %+v", reports)
+	}
+}
+
+// testDMARCReport returns a one-day aggregate report
+func testDMARCReport(org, id, domain string, begin time.Time) *types.DMARCAggregateReport {
+	return &types.DMARCAggregateReport{OrgName: org, ReportID: id, Domain: domain, Begin: begin, End: begin.Add(24*time.Hour - time.Second),
+		Records: []types.DMARCAggregateRecord{{SourceIP: "192.0.2.1", Count: 1}}}
+}
+
+// TestDMARCReportStoreRecovery tests that the index is built for a store written without one,
+// and rebuilt after a crash between the report and index writes or in the middle of either
+func TestDMARCReportStoreRecovery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "reports.jsonl")
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	// A store of one JSON report per line, as written before the index existed
+	var legacy bytes.Buffer
+	for i := 1; i <= 3; i++ {
+		line, _ := json.Marshal(testDMARCReport("google.com", fmt.Sprint(i), "example.com", day.AddDate(0, 0, i)))
+		legacy.Write(append(line, '\n'))
+	}
+	if err := os.WriteFile(path, legacy.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	store := NewDMARCReportStore(path)
+	added, duplicates, err := store.Add([]*types.DMARCAggregateReport{
+		testDMARCReport("google.com", "2", "example.com", day),
+		testDMARCReport("google.com", "4", "example.com", day),
+	})
+	if err != nil || added != 1 || duplicates != 1 {
+		t.Fatalf("Add() to a store without index = %d, %d, %v, want 1 added and 1 duplicate", added, duplicates, err)
+	}
+	if index, _ := os.ReadFile(path + ".idx"); strings.Count(string(index), "\n") != 4 {
+		t.Errorf("index = %q, want 4 lines", index)
+	}
+
+	// A report written without its index line, followed by a report cut short
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	line, _ := json.Marshal(testDMARCReport("yahoo", "1", "example.org", day))
+	f.Write(append(line, '\n'))
+	f.Write(line[:len(line)/2])
+	f.Close()
+
+	// Another process reading the store indexes the unindexed report and drops the partial one
+	reports, err := NewDMARCReportStore(path).Reports(DMARCReportFilter{})
+	if err != nil || len(reports) != 5 || reports[4].OrgName != "yahoo" {
+		t.Fatalf("Reports() after a crash = %d reports, error = %v, want 5", len(reports), err)
+	}
+	if data, _ := os.ReadFile(path); !bytes.HasSuffix(data, append(line, '\n')) {
+		t.Errorf("partial report was not removed from the reports file")
+	}
+
+	// The first store catches up with the index lines written by the other one
+	added, duplicates, err = store.Add([]*types.DMARCAggregateReport{testDMARCReport("yahoo", "1", "example.org", day)})
+	if err != nil || added != 0 || duplicates != 1 {
+		t.Errorf("Add() after another process indexed the report = %d, %d, %v, want 1 duplicate", added, duplicates, err)
+	}
+
+	// An index line cut short, and a damaged index, are rebuilt from the reports file
+	index, _ := os.ReadFile(path + ".idx")
+	os.WriteFile(path+".idx", index[:len(index)-10], 0o644)
+	if reports, err := NewDMARCReportStore(path).Reports(DMARCReportFilter{Domain: "example.org"}); err != nil || len(reports) != 1 {
+		t.Errorf("Reports() with a partial index line = %d reports, error = %v, want 1", len(reports), err)
+	}
+	os.WriteFile(path+".idx", []byte("garbage\n"), 0o644)
+	if reports, err := NewDMARCReportStore(path).Reports(DMARCReportFilter{}); err != nil || len(reports) != 5 {
+		t.Errorf("Reports() with a damaged index = %d reports, error = %v, want 5", len(reports), err)
+	}
+	if rebuilt, _ := os.ReadFile(path + ".idx"); !bytes.Equal(rebuilt, index) {
+		t.Errorf("rebuilt index = %q, want %q", rebuilt, index)
+	}
+
+	// Removing both files expires every report
+	os.Remove(path)
+	os.Remove(path + ".idx")
+	if reports, err := store.Reports(DMARCReportFilter{}); err != nil || len(reports) != 0 {
+		t.Errorf("Reports() of a removed store = %d reports, error = %v", len(reports), err)
+	}
+	if added, _, err := store.Add([]*types.DMARCAggregateReport{testDMARCReport("yahoo", "1", "example.org", day)}); err != nil || added != 1 {
+		t.Errorf("Add() to a removed store = %d, %v, want 1 added", added, err)
+	}
+}
+
+// TestDMARCReportStoreConcurrent tests that stores sharing a file, as separate processes do,
+// never store a report twice
+func TestDMARCReportStoreConcurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "reports.jsonl")
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < 8; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			store := NewDMARCReportStore(path)
+			for i := 0; i < 50; i++ {
+				// Every worker ingests the same reports, in a different order
+				id := fmt.Sprint((i + worker*7) % 50)
+				if _, _, err := store.Add([]*types.DMARCAggregateReport{testDMARCReport("google.com", id, "example.com", day)}); err != nil {
+					t.Errorf("Add() error = %v", err)
+				}
+			}
+		}(worker)
+	}
+	wg.Wait()
+
+	reports, err := NewDMARCReportStore(path).Reports(DMARCReportFilter{})
+	if err != nil || len(reports) != 50 {
+		t.Errorf("Reports() = %d reports, error = %v, want 50", len(reports), err)
+	}
+}
+
+// TestLookupIPOrigin tests the Team Cymru queries for IPv4 and IPv6 sources
+func TestLookupIPOrigin(t *testing.T) {
+	resolver := &fakeResolver{
+		txt: map[string][]string{
+			"10.2.0.192.origin.asn.cymru.com": {"64500 64501 | 192.0.2.0/24 | US | arin | 2010-01-01"},
+			"AS64500.asn.cymru.com":           {"64500 | US | arin | 2000-01-01 | EXAMPLE-NET - Example Networks, US"},
+			"5.2.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.origin6.asn.cymru.com": {"64510 | 2001:db8::/32 | DE | ripencc | 2012-01-01"},
+		},
+		ptr:  map[string][]string{"192.0.2.10": {"mail.example.net."}},
+		fail: map[string]bool{"11.2.0.192.origin.asn.cymru.com": true},
+	}
+
+	origin, err := LookupIPOrigin(context.Background(), resolver, "192.0.2.10")
+	if err != nil {
+		t.Fatalf("LookupIPOrigin() error = %v", err)
+	}
+	want := types.IPOrigin{IP: "192.0.2.10", PTR: "mail.example.net", ASN: 64500, ASName: "EXAMPLE-NET - Example Networks, US",
+		Prefix: "192.0.2.0/24", Country: "US", Registry: "arin"}
+	if *origin != want {
+		t.Errorf("origin = %+v, want %+v", *origin, want)
+	}
+
+	origin, err = LookupIPOrigin(context.Background(), resolver, "2001:db8::25")
+	if err != nil || origin.ASN != 64510 || origin.Country != "DE" || origin.ASName != "" || origin.PTR != "" {
+		t.Errorf("IPv6 origin = %+v, error = %v", origin, err)
+	}
+
+	if origin, err := LookupIPOrigin(context.Background(), resolver, "198.51.100.1"); err != nil || origin.ASN != 0 {
+		t.Errorf("unannounced origin = %+v, error = %v", origin, err)
+	}
+	if _, err := LookupIPOrigin(context.Background(), resolver, "192.0.2.11"); err == nil {
+		t.Errorf("failed lookup error = nil, want an error")
+	}
+	if _, err := LookupIPOrigin(context.Background(), resolver, "bogus"); err == nil {
+		t.Errorf("invalid IP error = nil, want an error")
+	}
+}
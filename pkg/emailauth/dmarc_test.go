@@ -0,0 +1,137 @@
+// Package emailauth provides functionality for checking email authentication mechanisms.
+package emailauth
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// TestParseDMARCRecordTags tests the optional tags, defaults and per-tag errors
+func TestParseDMARCRecordTags(t *testing.T) {
+	record, err := ParseDMARCRecord("v=DMARC1; p=Reject; np=quarantine; psd=n; fo=1:d; rf=afrf; ri=3600; " +
+		"rua=mailto:dmarc@example.com!10m, mailto:agg@reports.example.net!500k; ruf=mailto:ruf@example.com; foo=bar")
+	if err != nil {
+		t.Fatalf("ParseDMARCRecord() error = %v", err)
+	}
+	if len(record.TagErrors) > 0 {
+		t.Errorf("TagErrors = %+v, want none", record.TagErrors)
+	}
+	if record.Policy != "reject" || record.NPPolicy != "quarantine" || record.PSD != "n" || record.ReportInterval != 3600 {
+		t.Errorf("record = %+v", record)
+	}
+	if strings.Join(record.FailureOptions, ":") != "1:d" || strings.Join(record.ReportFormats, ":") != "afrf" {
+		t.Errorf("FailureOptions = %v, ReportFormats = %v", record.FailureOptions, record.ReportFormats)
+	}
+	if strings.Join(record.UnknownTags, ",") != "foo" {
+		t.Errorf("UnknownTags = %v, want foo", record.UnknownTags)
+	}
+	if len(record.AggregateURIs) != 2 || len(record.FailureURIs) != 1 {
+		t.Fatalf("AggregateURIs = %+v, FailureURIs = %+v", record.AggregateURIs, record.FailureURIs)
+	}
+	first, second := record.AggregateURIs[0], record.AggregateURIs[1]
+	if first.URI != "mailto:dmarc@example.com" || first.Address != "dmarc@example.com" || first.Domain != "example.com" || first.MaxSize != 10<<20 {
+		t.Errorf("first rua = %+v", first)
+	}
+	if second.Domain != "reports.example.net" || second.MaxSize != 500<<10 {
+		t.Errorf("second rua = %+v", second)
+	}
+
+	// Defaults
+	record, _ = ParseDMARCRecord("v=DMARC1; p=none")
+	if record.ReportInterval != 86400 || strings.Join(record.FailureOptions, ":") != "0" || strings.Join(record.ReportFormats, ":") != "afrf" {
+		t.Errorf("defaults = %+v", record)
+	}
+
+	tests := []struct {
+		record string
+		tag    string
+	}{
+		{"v=DMARC1; p=block", "p"},
+		{"v=DMARC1; sp=none", "p"},
+		{"v=DMARC1; p=none; sp=bogus", "sp"},
+		{"v=DMARC1; p=none; np=deny", "np"},
+		{"v=DMARC1; p=none; pct=150", "pct"},
+		{"v=DMARC1; p=none; pct=abc", "pct"},
+		{"v=DMARC1; p=none; adkim=x", "adkim"},
+		{"v=DMARC1; p=none; aspf=relaxed", "aspf"},
+		{"v=DMARC1; p=none; fo=2", "fo"},
+		{"v=DMARC1; p=none; rf=iodef", "rf"},
+		{"v=DMARC1; p=none; ri=-1", "ri"},
+		{"v=DMARC1; p=none; psd=yes", "psd"},
+		{"v=DMARC1; p=none; rua=dmarc@example.com", "rua"},
+		{"v=DMARC1; p=none; rua=mailto:dmarc@example.com!10x", "rua"},
+		{"v=DMARC1; p=none; ruf=mailto:not-an-address", "ruf"},
+		{"v=DMARC1; p=none; rua=mailto:a@example.com,", "rua"},
+		{"v=DMARC1; p=none; p=reject", "p"},
+		{"v=DMARC1; p=none; v=DMARC1", "v"},
+		{"v=DMARC1; p=none; garbage", "garbage"},
+	}
+	for _, tt := range tests {
+		record, err := ParseDMARCRecord(tt.record)
+		if err != nil {
+			t.Errorf("ParseDMARCRecord(%q) error = %v", tt.record, err)
+			continue
+		}
+		if len(record.TagErrors) != 1 || record.TagErrors[0].Tag != tt.tag {
+			t.Errorf("ParseDMARCRecord(%q).TagErrors = %+v, want one error for %s", tt.record, record.TagErrors, tt.tag)
+		}
+	}
+
+	// A duplicate keeps the first value
+	if record, _ := ParseDMARCRecord("v=DMARC1; p=none; p=reject"); record.Policy != "none" {
+		t.Errorf("Policy = %q, want the first value", record.Policy)
+	}
+
+	for _, bad := range []string{"v=DMARC2; p=none", "p=none; v=DMARC1", "v=dmarc1; p=none"} {
+		if _, err := ParseDMARCRecord(bad); err == nil {
+			t.Errorf("ParseDMARCRecord(%q) error = nil, want an error", bad)
+		}
+	}
+}
+
+// TestVerifyDMARCReportDestinations tests the external destination verification of RFC 7489 section 7.1
+func TestVerifyDMARCReportDestinations(t *testing.T) {
+	resolver := &fakeResolver{
+		txt: map[string][]string{
+			"example.com._report._dmarc.authorized.example": {"v=DMARC1"},
+			"example.com._report._dmarc.broken.example":     {"v=DMARC1"},
+		},
+		fail: map[string]bool{"example.com._report._dmarc.broken.example": true},
+	}
+	record, err := ParseDMARCRecord("v=DMARC1; p=none; rua=mailto:dmarc@example.com, mailto:a@authorized.example, mailto:b@unauthorized.example; " +
+		"ruf=mailto:ruf@mail.example.com, mailto:c@broken.example, https://example.org/reports")
+	if err != nil {
+		t.Fatalf("ParseDMARCRecord() error = %v", err)
+	}
+
+	VerifyDMARCReportDestinations(context.Background(), resolver, "example.com", record)
+
+	type want struct {
+		external, authorized, hasError bool
+	}
+	wants := map[string]want{
+		"mailto:dmarc@example.com":      {false, false, false},
+		"mailto:a@authorized.example":   {true, true, false},
+		"mailto:b@unauthorized.example": {true, false, true},
+		"mailto:ruf@mail.example.com":   {false, false, false},
+		"mailto:c@broken.example":       {true, false, true},
+		"https://example.org/reports":   {false, false, false},
+	}
+	uris := append(record.AggregateURIs, record.FailureURIs...)
+	if len(uris) != len(wants) {
+		t.Fatalf("URIs = %+v, want %d", uris, len(wants))
+	}
+	for _, uri := range uris {
+		w := wants[uri.URI]
+		if uri.External != w.external || uri.Authorized != w.authorized || (uri.Error != "") != w.hasError {
+			t.Errorf("%s: External = %v, Authorized = %v, Error = %q, want %+v", uri.URI, uri.External, uri.Authorized, uri.Error, w)
+		}
+	}
+	if uri := record.AggregateURIs[1]; uri.AuthorizationRecord != "v=DMARC1" {
+		t.Errorf("AuthorizationRecord = %q, want v=DMARC1", uri.AuthorizationRecord)
+	}
+	if uri := record.FailureURIs[1]; !strings.Contains(uri.Error, "lookup") {
+		t.Errorf("broken destination Error = %q, want a lookup failure", uri.Error)
+	}
+}
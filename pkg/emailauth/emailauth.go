@@ -3,6 +3,7 @@ package emailauth
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -32,17 +33,36 @@ type DMARCRecord struct {
 	RUF      string // ruf tag
 	ADKIM    string // adkim tag
 	ASPF     string // aspf tag
+
+	NPPolicy       string                 // np tag, policy for non-existent subdomains (RFC 9091)
+	PSD            string                 // psd tag: y, n or u
+	FailureOptions []string               // fo tag, 0 when absent
+	ReportFormats  []string               // rf tag, afrf when absent
+	ReportInterval int                    // ri tag in seconds, 86400 when absent
+	AggregateURIs  []types.DMARCReportURI // Parsed rua tag
+	FailureURIs    []types.DMARCReportURI // Parsed ruf tag
+	UnknownTags    []string
+	TagErrors      []types.DMARCTagError
 }
 
+// ErrNoDKIMRecord is returned when a selector publishes no DKIM key record.
+var ErrNoDKIMRecord = errors.New("no DKIM record found")
+
 // DKIMRecord represents a parsed DKIM record.
 type DKIMRecord struct {
-	Raw       string
-	Version   string
-	PublicKey string
-	KeyType   string
-	Notes     string
-	Service   string
-	Flags     string
+	Raw            string
+	Version        string
+	PublicKey      string
+	KeyType        string // k= tag, rsa when absent
+	Notes          string
+	Service        string
+	Flags          string
+	HashAlgorithms string // h= tag
+	KeyBits        int    // size of the decoded public key
+	KeyStrength    string // strong, weak or failing
+	Revoked        bool   // empty p=
+	Testing        bool   // t=y
+	Findings       []types.Finding
 }
 
 // GetSPFRecord retrieves the SPF record for a domain.
@@ -114,6 +134,9 @@ func ParseSPFRecord(record string) (*SPFRecord, error) {
 }
 
 // ValidateSPF validates an SPF record.
+//
+// Deprecated: the all qualifier is not the SPF result of a sender. Use CheckSPFSyntax to validate a
+// record and CheckHost to evaluate it for a sending IP.
 func ValidateSPF(spf *SPFRecord) (string, error) {
 	if spf == nil {
 		return "", fmt.Errorf("nil SPF record")
@@ -166,63 +189,6 @@ func GetDMARCRecord(ctx context.Context, domain string, timeout time.Duration) (
 	return "", fmt.Errorf("no DMARC record found for domain: %s", domain)
 }
 
-// ParseDMARCRecord parses a DMARC record string into a structured format.
-func ParseDMARCRecord(record string) (*DMARCRecord, error) {
-	if record == "" {
-		return nil, fmt.Errorf("empty DMARC record")
-	}
-
-	dmarc := &DMARCRecord{
-		Raw:     record,
-		Pct:     100, // Default value
-	}
-
-	// Split the record into parts
-	parts := strings.Split(record, ";")
-
-	// First part should be the version
-	if !strings.HasPrefix(strings.TrimSpace(parts[0]), "v=DMARC1") {
-		return nil, fmt.Errorf("invalid DMARC record: does not start with v=DMARC1")
-	}
-	dmarc.Version = strings.TrimSpace(parts[0])
-
-	// Process the rest of the parts
-	for _, part := range parts[1:] {
-		part = strings.TrimSpace(part)
-		if part == "" {
-			continue
-		}
-
-		kv := strings.SplitN(part, "=", 2)
-		if len(kv) != 2 {
-			continue
-		}
-
-		key := strings.TrimSpace(kv[0])
-		value := strings.TrimSpace(kv[1])
-
-		switch key {
-		case "p":
-			dmarc.Policy = value
-		case "sp":
-			dmarc.SubPolicy = value
-		case "pct":
-			// Ignore conversion errors, keep default
-			fmt.Sscanf(value, "%d", &dmarc.Pct)
-		case "rua":
-			dmarc.RUA = value
-		case "ruf":
-			dmarc.RUF = value
-		case "adkim":
-			dmarc.ADKIM = value
-		case "aspf":
-			dmarc.ASPF = value
-		}
-	}
-
-	return dmarc, nil
-}
-
 // GetDKIMRecord retrieves the DKIM record for a domain and selector.
 func GetDKIMRecord(ctx context.Context, domain, selector string, timeout time.Duration) (string, error) {
 	// DKIM records are stored as TXT records at selector._domainkey.domain
@@ -239,7 +205,7 @@ func GetDKIMRecord(ctx context.Context, domain, selector string, timeout time.Du
 		}
 	}
 
-	return "", fmt.Errorf("no DKIM record found for selector %s at domain: %s", selector, domain)
+	return "", fmt.Errorf("%w for selector %s at domain: %s", ErrNoDKIMRecord, selector, domain)
 }
 
 // ParseDKIMRecord parses a DKIM record string into a structured format.
@@ -283,46 +249,29 @@ func ParseDKIMRecord(record string) (*DKIMRecord, error) {
 			dkim.Service = value
 		case "t":
 			dkim.Flags = value
+		case "h":
+			dkim.HashAlgorithms = value
 		}
 	}
 
+	auditDKIMRecord(dkim)
+
 	return dkim, nil
 }
 
 // AnalyzeEmailHeader analyzes an email header for authentication results.
+// The first SPF, DKIM and DMARC results of the Authentication-Results headers are returned,
+// topmost header first; see AnalyzeHeaders for the complete analysis.
 func AnalyzeEmailHeader(header string) (map[string]string, error) {
 	results := make(map[string]string)
 
-	// Look for Authentication-Results header
-	lines := strings.Split(header, "\n")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if strings.HasPrefix(line, "Authentication-Results:") {
-			// Extract the authentication results
-			authResults := strings.TrimPrefix(line, "Authentication-Results:")
-			authResults = strings.TrimSpace(authResults)
-
-			// Parse the authentication results
-			parts := strings.Split(authResults, ";")
-			for _, part := range parts {
-				part = strings.TrimSpace(part)
-				if part == "" {
-					continue
-				}
-
-				// Check for SPF result
-				if strings.HasPrefix(part, "spf=") {
-					results["SPF"] = strings.TrimPrefix(part, "spf=")
-				}
-
-				// Check for DKIM result
-				if strings.HasPrefix(part, "dkim=") {
-					results["DKIM"] = strings.TrimPrefix(part, "dkim=")
-				}
-
-				// Check for DMARC result
-				if strings.HasPrefix(part, "dmarc=") {
-					results["DMARC"] = strings.TrimPrefix(part, "dmarc=")
+	analysis := AnalyzeHeaders([]byte(header))
+	for _, authResults := range analysis.AuthenticationResults {
+		for _, result := range authResults.Results {
+			switch key := strings.ToUpper(result.Method); key {
+			case "SPF", "DKIM", "DMARC":
+				if _, ok := results[key]; !ok {
+					results[key] = result.Result
 				}
 			}
 		}
@@ -342,16 +291,12 @@ func CheckEmailAuth(ctx context.Context, domain string, timeout time.Duration) (
 	} else {
 		result.SPFRecord = spfRecord
 
-		// Parse and validate SPF record
-		parsedSPF, err := ParseSPFRecord(spfRecord)
-		if err != nil {
-			result.SPFError = fmt.Sprintf("Failed to parse SPF record: %s", err.Error())
+		// Check the syntax of every term; the result for a sender needs CheckHost and an IP
+		if _, err := CheckSPFSyntax(spfRecord); err != nil {
+			result.SPFError = fmt.Sprintf("Invalid SPF record: %s", err.Error())
+			result.SPFResult = "invalid"
 		} else {
-			spfResult, err := ValidateSPF(parsedSPF)
-			if err != nil {
-				result.SPFError = fmt.Sprintf("SPF validation warning: %s", err.Error())
-			}
-			result.SPFResult = spfResult
+			result.SPFResult = "valid"
 		}
 	}
 
@@ -368,6 +313,10 @@ func CheckEmailAuth(ctx context.Context, domain string, timeout time.Duration) (
 			result.DMARCError = fmt.Sprintf("Failed to parse DMARC record: %s", err.Error())
 		} else {
 			result.DMARCPolicy = parsedDMARC.Policy
+			if len(parsedDMARC.TagErrors) > 0 {
+				tagErr := parsedDMARC.TagErrors[0]
+				result.DMARCError = fmt.Sprintf("Invalid DMARC %s= tag: %s", tagErr.Tag, tagErr.Message)
+			}
 		}
 	}
 
@@ -395,12 +344,14 @@ func CheckEmailAuthWithDKIM(ctx context.Context, domain string, selector string,
 			if err != nil {
 				result.DKIMError = fmt.Sprintf("Failed to parse DKIM record: %s", err.Error())
 			} else {
-				// Simple validation - check if public key exists
-				if parsedDKIM.PublicKey == "" {
-					result.DKIMResult = "invalid"
-					result.DKIMError = "No public key found in DKIM record"
-				} else {
-					result.DKIMResult = "valid"
+				// The record is invalid when the key audit found a critical problem
+				result.DKIMResult = "valid"
+				for _, finding := range parsedDKIM.Findings {
+					if finding.Severity == types.SeverityCritical {
+						result.DKIMResult = "invalid"
+						result.DKIMError = finding.Message
+						break
+					}
 				}
 			}
 		}
@@ -0,0 +1,503 @@
+// Package emailauth provides functionality for checking email authentication mechanisms.
+package emailauth
+
+import (
+	"fmt"
+	"net"
+	"net/mail"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"mxclone/pkg/types"
+)
+
+// slowHopDelay is the delay after which a hop is reported as slow
+const slowHopDelay = 5 * time.Minute
+
+var (
+	// receivedTLSProtocol matches the RFC 3848 protocol names of TLS sessions: ESMTPS, ESMTPSA, LMTPS...
+	receivedTLSProtocol = regexp.MustCompile(`^(UTF8)?(E?SMTP|LMTP)SA?$`)
+	// receivedIPLiteral matches an address literal such as [192.0.2.1] or [IPv6:2001:db8::1]
+	receivedIPLiteral = regexp.MustCompile(`\[(?:IPv6:)?([0-9A-Fa-f:.]+)\]`)
+)
+
+// receivedTLSMarkers are comment fragments receivers use to record TLS
+var receivedTLSMarkers = []string{"version=tls", "tls1", "tlsv1", "using tls", "cipher=", "transport security"}
+
+// AnalyzeHeaders parses the header section of a message, or a complete message: every Received
+// hop in transit order with delays, clock skew and TLS, the Authentication-Results, ARC,
+// Return-Path and List-* headers, and the problems found.
+func AnalyzeHeaders(raw []byte) *types.HeaderAnalysis {
+	headers, _ := splitMessage(raw)
+	basic := analyzeMessageHeaders(raw)
+	analysis := &types.HeaderAnalysis{
+		From:      basic.From,
+		To:        basic.To,
+		Subject:   decodeHeader(basic.Subject),
+		Date:      basic.Date,
+		MessageID: basic.MessageID,
+		Headers:   make([]types.HeaderField, 0, len(headers)),
+		Findings:  basic.Findings,
+	}
+	if domain, err := headerFromDomain(basic.From); err == nil {
+		analysis.FromDomain = domain
+	}
+
+	var received []string
+	var returnPaths []string
+	arcSets := make(map[int]*types.ARCSetHeaders)
+	list := &types.ListHeaders{}
+	hasList := false
+
+	for _, field := range headers {
+		value := unfoldedValue(field)
+		name := strings.TrimSpace(field.raw[:strings.IndexByte(field.raw, ':')])
+		analysis.Headers = append(analysis.Headers, types.HeaderField{Name: name, Value: value})
+
+		switch field.name {
+		case "received":
+			received = append(received, value)
+		case "return-path":
+			returnPaths = append(returnPaths, value)
+		case "reply-to":
+			analysis.ReplyTo = value
+		case "authentication-results":
+			analysis.AuthenticationResults = append(analysis.AuthenticationResults, ParseAuthenticationResults(value))
+		case "dkim-signature":
+			if tags, err := parseTagList(field.value()); err == nil && tags["d"] != "" {
+				analysis.DKIMDomains = append(analysis.DKIMDomains, strings.ToLower(tags["d"]))
+			}
+		case "arc-seal", "arc-message-signature", "arc-authentication-results":
+			if err := addARCHeader(arcSets, field.name, value); err != nil {
+				analysis.Findings = append(analysis.Findings, fmt.Sprintf("%s header is invalid: %v", headerDisplayName(field.name), err))
+			}
+		case "list-id":
+			list.ID, hasList = value, true
+		case "list-unsubscribe":
+			list.Unsubscribe, hasList = append(list.Unsubscribe, listURIs(value)...), true
+		case "list-unsubscribe-post":
+			list.UnsubscribePost, hasList = value, true
+		case "list-subscribe":
+			list.Subscribe, hasList = append(list.Subscribe, listURIs(value)...), true
+		case "list-post":
+			list.Post, hasList = append(list.Post, listURIs(value)...), true
+		case "list-help":
+			list.Help, hasList = append(list.Help, listURIs(value)...), true
+		case "list-owner":
+			list.Owner, hasList = append(list.Owner, listURIs(value)...), true
+		case "list-archive":
+			list.Archive, hasList = append(list.Archive, listURIs(value)...), true
+		}
+	}
+
+	analyzeReceived(analysis, received)
+	analyzeReturnPath(analysis, returnPaths)
+	analyzeLookalikes(analysis)
+	analyzeARCHeaders(analysis, arcSets)
+	if hasList {
+		analysis.List = list
+		analyzeListHeaders(analysis, list)
+	}
+
+	return analysis
+}
+
+// analyzeReceived parses the Received headers, topmost last, and computes the hop delays.
+func analyzeReceived(analysis *types.HeaderAnalysis, received []string) {
+	if len(received) == 0 {
+		analysis.Findings = append(analysis.Findings, "No Received headers: the message was not relayed, or its headers are incomplete")
+		return
+	}
+
+	// Receivers prepend their Received header, so the first relay is the last header
+	var previous *time.Time
+	if date, err := mail.ParseDate(analysis.Date); err == nil {
+		previous = &date
+	}
+	start := previous
+	for i := len(received) - 1; i >= 0; i-- {
+		hop := parseReceived(received[i])
+		hop.Hop = len(received) - i
+		label := hopLabel(&hop)
+
+		if hop.Timestamp == nil {
+			analysis.Findings = append(analysis.Findings, fmt.Sprintf("Hop %d (%s) has no valid timestamp", hop.Hop, label))
+		} else {
+			if start == nil {
+				start = hop.Timestamp
+			}
+			if previous != nil {
+				delay := hop.Timestamp.Sub(*previous)
+				seconds := delay.Seconds()
+				hop.DelaySeconds = &seconds
+				switch {
+				case delay < 0:
+					hop.ClockSkew = true
+					against := "the previous hop"
+					if hop.Hop == 1 {
+						against = "the Date header"
+					}
+					analysis.Findings = append(analysis.Findings, fmt.Sprintf("Hop %d (%s) is timestamped %s before %s: the clocks of the hosts disagree",
+						hop.Hop, label, -delay, against))
+				case delay > slowHopDelay:
+					analysis.Findings = append(analysis.Findings, fmt.Sprintf("Hop %d (%s) was delayed %s", hop.Hop, label, delay))
+				}
+			}
+			previous = hop.Timestamp
+		}
+
+		// Handoffs without a from clause are internal to the receiving system
+		if !hop.TLS && isSMTPProtocol(hop.With) && (hop.From != "" || hop.FromIP != "") && !isLoopback(hop.FromIP) {
+			analysis.Findings = append(analysis.Findings, fmt.Sprintf("Hop %d (%s) was not encrypted (with %s)", hop.Hop, label, hop.With))
+		}
+		analysis.Hops = append(analysis.Hops, hop)
+	}
+
+	if start != nil && previous != nil {
+		total := previous.Sub(*start).Seconds()
+		analysis.TotalDelaySeconds = &total
+	}
+}
+
+// hopLabel names a hop by its hosts
+func hopLabel(hop *types.ReceivedHop) string {
+	from := hop.From
+	if from == "" {
+		from = hop.FromIP
+	}
+	switch {
+	case from != "" && hop.By != "":
+		return from + " to " + hop.By
+	case hop.By != "":
+		return "to " + hop.By
+	case from != "":
+		return "from " + from
+	}
+	return "unnamed"
+}
+
+// isSMTPProtocol reports whether a with clause is plain SMTP or ESMTP, possibly authenticated
+func isSMTPProtocol(with string) bool {
+	switch strings.ToUpper(with) {
+	case "SMTP", "ESMTP", "ESMTPA", "UTF8SMTP", "UTF8SMTPA":
+		return true
+	}
+	return false
+}
+
+// isLoopback reports whether an address is a loopback address
+func isLoopback(ip string) bool {
+	parsed := net.ParseIP(ip)
+	return parsed != nil && parsed.IsLoopback()
+}
+
+// receivedToken is a word or a comment of a Received header
+type receivedToken struct {
+	text    string
+	comment bool
+}
+
+// parseReceived parses a Received header: "from x (host [ip]) by y with ESMTPS id z for <r>; date"
+func parseReceived(value string) types.ReceivedHop {
+	hop := types.ReceivedHop{Raw: value}
+
+	clauses := value
+	if i := strings.LastIndexByte(value, ';'); i >= 0 {
+		clauses = value[:i]
+		date := strings.TrimSpace(stripHeaderComments(value[i+1:]))
+		if t, err := mail.ParseDate(date); err == nil {
+			hop.Timestamp = &t
+		}
+	}
+
+	values := make(map[string]string)
+	comments := make(map[string][]string)
+	key := ""
+	for _, token := range tokenizeReceived(clauses) {
+		if token.comment {
+			if key != "" {
+				comments[key] = append(comments[key], token.text)
+			}
+			continue
+		}
+		lower := strings.ToLower(token.text)
+		switch lower {
+		case "from", "by", "via", "with", "id", "for":
+			if _, seen := values[lower]; !seen {
+				key = lower
+				values[key] = ""
+				continue
+			}
+		}
+		if key == "" {
+			continue
+		}
+		if values[key] == "" {
+			values[key] = token.text
+		} else {
+			values[key] += " " + token.text
+		}
+	}
+
+	hop.From = strings.TrimSuffix(values["from"], ".")
+	hop.By = strings.TrimSuffix(values["by"], ".")
+	hop.With = values["with"]
+	hop.ID = values["id"]
+	hop.For = strings.Trim(values["for"], "<>")
+
+	// The from comment holds what the receiver saw: "(host.example. [192.0.2.1])"
+	fromInfo := append([]string{values["from"]}, comments["from"]...)
+	for _, text := range fromInfo {
+		if match := receivedIPLiteral.FindStringSubmatch(text); match != nil {
+			if ip := net.ParseIP(match[1]); ip != nil {
+				hop.FromIP = ip.String()
+				break
+			}
+		}
+	}
+	for _, comment := range comments["from"] {
+		for _, word := range strings.Fields(comment) {
+			word = strings.TrimSuffix(strings.Trim(word, "[]"), ".")
+			if hop.FromIP == "" {
+				if ip := net.ParseIP(word); ip != nil {
+					hop.FromIP = ip.String()
+					continue
+				}
+			}
+			if hop.FromHost == "" && strings.Contains(word, ".") && !strings.Contains(word, "=") && net.ParseIP(word) == nil &&
+				!strings.HasPrefix(word, "IPv6:") {
+				hop.FromHost = strings.ToLower(word)
+			}
+		}
+	}
+
+	if protocol := strings.Fields(hop.With); len(protocol) > 0 && receivedTLSProtocol.MatchString(strings.ToUpper(protocol[0])) {
+		hop.TLS = true
+	}
+	for _, key := range []string{"with", "by", "id", "for", "from", "via"} {
+		for _, comment := range comments[key] {
+			lower := strings.ToLower(comment)
+			for _, marker := range receivedTLSMarkers {
+				if strings.Contains(lower, marker) {
+					hop.TLS = true
+					if hop.TLSDetails == "" {
+						hop.TLSDetails = comment
+					}
+					break
+				}
+			}
+		}
+	}
+
+	return hop
+}
+
+// tokenizeReceived splits the clauses of a Received header into words and comments
+func tokenizeReceived(value string) []receivedToken {
+	var tokens []receivedToken
+	var b strings.Builder
+	depth := 0
+	flush := func(comment bool) {
+		text := strings.TrimSpace(b.String())
+		if text != "" {
+			tokens = append(tokens, receivedToken{text: text, comment: comment})
+		}
+		b.Reset()
+	}
+	for _, r := range value {
+		switch {
+		case r == '(':
+			if depth == 0 {
+				flush(false)
+			} else {
+				b.WriteRune(r)
+			}
+			depth++
+		case r == ')' && depth > 0:
+			depth--
+			if depth == 0 {
+				flush(true)
+			} else {
+				b.WriteRune(r)
+			}
+		case depth == 0 && (r == ' ' || r == '\t' || r == '\r' || r == '\n'):
+			flush(false)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	flush(depth > 0)
+	return tokens
+}
+
+// analyzeReturnPath checks the Return-Path header added at final delivery
+func analyzeReturnPath(analysis *types.HeaderAnalysis, returnPaths []string) {
+	if len(returnPaths) == 0 {
+		return
+	}
+	if len(returnPaths) > 1 {
+		analysis.Findings = append(analysis.Findings, fmt.Sprintf("Return-Path header appears %d times", len(returnPaths)))
+	}
+
+	path := strings.TrimSpace(returnPaths[0])
+	analysis.ReturnPath = strings.Trim(path, "<>")
+	if analysis.ReturnPath == "" {
+		analysis.Findings = append(analysis.Findings, "Return-Path is the null sender <>: the message is a bounce or an auto-reply")
+		return
+	}
+	if at := strings.LastIndexByte(analysis.ReturnPath, '@'); at >= 0 {
+		analysis.ReturnPathDomain = strings.ToLower(analysis.ReturnPath[at+1:])
+	}
+	if analysis.ReturnPathDomain != "" && analysis.FromDomain != "" &&
+		OrganizationalDomain(analysis.ReturnPathDomain) != OrganizationalDomain(analysis.FromDomain) {
+		analysis.Findings = append(analysis.Findings, fmt.Sprintf("Return-Path domain %s is not aligned with the From domain %s: only DKIM can pass DMARC",
+			analysis.ReturnPathDomain, analysis.FromDomain))
+	}
+}
+
+// analyzeLookalikes flags Return-Path, Reply-To and DKIM domains that imitate the From domain
+func analyzeLookalikes(analysis *types.HeaderAnalysis) {
+	if analysis.FromDomain == "" {
+		return
+	}
+	seen := make(map[string]bool)
+	check := func(header, domain string) {
+		if domain == "" || seen[header+" "+domain] {
+			return
+		}
+		seen[header+" "+domain] = true
+		if lookalike := DetectLookalike(domain, analysis.FromDomain); lookalike != nil {
+			analysis.Findings = append(analysis.Findings, fmt.Sprintf("%s domain %s looks like the From domain %s: %s",
+				header, lookalike.Domain, lookalike.ImitatedDomain, lookalike.Reason))
+		}
+	}
+
+	check("Return-Path", analysis.ReturnPathDomain)
+	if analysis.ReplyTo != "" {
+		if addresses, err := mail.ParseAddressList(analysis.ReplyTo); err == nil {
+			for _, address := range addresses {
+				check("Reply-To", address.Address[strings.LastIndexByte(address.Address, '@')+1:])
+			}
+		}
+	}
+	for _, domain := range analysis.DKIMDomains {
+		check("DKIM signing", domain)
+	}
+}
+
+// addARCHeader records an ARC header in the set of its instance
+func addARCHeader(sets map[int]*types.ARCSetHeaders, name, value string) error {
+	var tags map[string]string
+	var results *types.AuthenticationResults
+	if name == "arc-authentication-results" {
+		// i=1; mx.example.net; spf=pass ...
+		instance, rest, _ := strings.Cut(value, ";")
+		tags = map[string]string{}
+		if key, number, ok := strings.Cut(strings.TrimSpace(instance), "="); ok && strings.TrimSpace(key) == "i" {
+			tags["i"] = strings.TrimSpace(number)
+		}
+		parsed := ParseAuthenticationResults(rest)
+		results = &parsed
+	} else {
+		var err error
+		if tags, err = parseTagList(value); err != nil {
+			return err
+		}
+	}
+
+	instance, err := strconv.Atoi(tags["i"])
+	if err != nil || instance < 1 || instance > 50 {
+		return fmt.Errorf("invalid instance i=%s", tags["i"])
+	}
+	set, ok := sets[instance]
+	if !ok {
+		set = &types.ARCSetHeaders{Instance: instance}
+		sets[instance] = set
+	}
+
+	switch name {
+	case "arc-seal":
+		set.SealDomain = strings.ToLower(tags["d"])
+		set.SealSelector = tags["s"]
+		set.ChainValidation = strings.ToLower(tags["cv"])
+	case "arc-message-signature":
+		set.SignatureDomain = strings.ToLower(tags["d"])
+		set.SignatureSelector = tags["s"]
+	default:
+		set.AuthenticationResults = results
+	}
+	return nil
+}
+
+// analyzeARCHeaders orders the ARC sets and checks that the chain is structurally complete
+func analyzeARCHeaders(analysis *types.HeaderAnalysis, sets map[int]*types.ARCSetHeaders) {
+	for _, set := range sets {
+		analysis.ARC = append(analysis.ARC, *set)
+	}
+	sort.Slice(analysis.ARC, func(i, j int) bool { return analysis.ARC[i].Instance < analysis.ARC[j].Instance })
+
+	for i, set := range analysis.ARC {
+		if set.Instance != i+1 {
+			analysis.Findings = append(analysis.Findings, fmt.Sprintf("ARC instances are not numbered consecutively from i=1: i=%d is missing", i+1))
+			break
+		}
+		var missing []string
+		if set.SealDomain == "" {
+			missing = append(missing, "ARC-Seal")
+		}
+		if set.SignatureDomain == "" {
+			missing = append(missing, "ARC-Message-Signature")
+		}
+		if set.AuthenticationResults == nil {
+			missing = append(missing, "ARC-Authentication-Results")
+		}
+		if len(missing) > 0 {
+			analysis.Findings = append(analysis.Findings, fmt.Sprintf("ARC set i=%d is missing %s", set.Instance, strings.Join(missing, ", ")))
+		}
+		if set.ChainValidation == "fail" {
+			analysis.Findings = append(analysis.Findings, fmt.Sprintf("ARC chain was recorded as failing at i=%d (%s)", set.Instance, set.SealDomain))
+		}
+	}
+}
+
+// listURIs returns the <URI> entries of a List-* header (RFC 2369)
+func listURIs(value string) []string {
+	var uris []string
+	for _, part := range strings.Split(stripHeaderComments(value), ",") {
+		part = strings.TrimSpace(part)
+		if strings.HasPrefix(part, "<") && strings.HasSuffix(part, ">") {
+			uris = append(uris, strings.TrimSpace(part[1:len(part)-1]))
+		} else if part != "" {
+			uris = append(uris, part)
+		}
+	}
+	return uris
+}
+
+// analyzeListHeaders checks the unsubscribe headers of list and bulk mail (RFC 8058)
+func analyzeListHeaders(analysis *types.HeaderAnalysis, list *types.ListHeaders) {
+	hasHTTPS := false
+	for _, uri := range list.Unsubscribe {
+		if strings.HasPrefix(strings.ToLower(uri), "https://") {
+			hasHTTPS = true
+		}
+	}
+
+	switch {
+	case len(list.Unsubscribe) == 0 && list.UnsubscribePost != "":
+		analysis.Findings = append(analysis.Findings, "List-Unsubscribe-Post is present without List-Unsubscribe")
+	case len(list.Unsubscribe) == 0:
+		if list.ID != "" {
+			analysis.Findings = append(analysis.Findings, "List mail has no List-Unsubscribe header")
+		}
+	case list.UnsubscribePost == "":
+		analysis.Findings = append(analysis.Findings, "List-Unsubscribe has no List-Unsubscribe-Post header: one-click unsubscribe (RFC 8058), required of bulk senders by Gmail and Yahoo, is not offered")
+	case !strings.EqualFold(strings.ReplaceAll(list.UnsubscribePost, " ", ""), "List-Unsubscribe=One-Click"):
+		analysis.Findings = append(analysis.Findings, fmt.Sprintf("List-Unsubscribe-Post is %q, not List-Unsubscribe=One-Click", list.UnsubscribePost))
+	case !hasHTTPS:
+		analysis.Findings = append(analysis.Findings, "One-click unsubscribe requires an https URI in List-Unsubscribe")
+	}
+}
@@ -0,0 +1,149 @@
+// Package emailauth provides functionality for checking email authentication mechanisms.
+package emailauth
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// sampleHeaders are the headers of a list message relayed through three hosts
+const sampleHeaders = `Return-Path: <bounce-123@lists.example.org>
+Delivered-To: alice@receiver.example
+Received: by 2002:a05:6400:1234 with SMTP id x12csp1;
+        Fri, 1 Mar 2024 10:00:09 -0800 (PST)
+Received: from mx.lists.example.org (mx.lists.example.org. [192.0.2.25])
+        by mx.receiver.example with ESMTPS id abc123
+        for <alice@receiver.example>
+        (version=TLS1_3 cipher=TLS_AES_256_GCM_SHA384 bits=256/256);
+        Fri, 01 Mar 2024 18:00:08 +0000
+Received: from [198.51.100.7] (unknown [198.51.100.7])
+	by mx.lists.example.org (Postfix) with ESMTP id 4F1A2;
+	Fri, 1 Mar 2024 18:06:00 +0000 (UTC)
+ARC-Seal: i=1; a=rsa-sha256; d=receiver.example; s=arc; cv=none; b=AAAA
+ARC-Message-Signature: i=1; a=rsa-sha256; d=receiver.example; s=arc; h=from; bh=BBBB; b=CCCC
+ARC-Authentication-Results: i=1; mx.receiver.example;
+       spf=pass smtp.mailfrom=lists.example.org
+Authentication-Results: mx.receiver.example;
+       dkim=pass header.i=@example.com header.s=s1 header.b=abcd;
+       spf=pass (domain of bounce-123@lists.example.org designates 192.0.2.25 as permitted sender) smtp.mailfrom=bounce-123@lists.example.org;
+       dmarc=pass (p=NONE sp=NONE dis=NONE) header.from=example.com
+DKIM-Signature: v=1; a=rsa-sha256; d=example.com; s=s1; h=from:subject; bh=AAAA; b=BBBB
+From: Example News <news@example.com>
+To: alice@receiver.example
+Subject: =?UTF-8?Q?Caf=C3=A9_news?=
+Date: Fri, 1 Mar 2024 18:05:30 +0000
+Message-ID: <news-1@example.com>
+List-Id: Example News <news.example.com>
+List-Unsubscribe: <mailto:unsub@lists.example.org>, <https://lists.example.org/u/1>
+`
+
+// TestAnalyzeHeaders tests the hop timeline and the authentication and list headers
+func TestAnalyzeHeaders(t *testing.T) {
+	analysis := AnalyzeHeaders([]byte(sampleHeaders))
+
+	if analysis.FromDomain != "example.com" || analysis.Subject != "Café news" || analysis.ReturnPathDomain != "lists.example.org" {
+		t.Errorf("analysis = %+v", analysis)
+	}
+	if len(analysis.Hops) != 3 {
+		t.Fatalf("Hops = %+v, want 3", analysis.Hops)
+	}
+
+	first := analysis.Hops[0]
+	if first.Hop != 1 || first.FromIP != "198.51.100.7" || first.By != "mx.lists.example.org" || first.With != "ESMTP" || first.ID != "4F1A2" || first.TLS {
+		t.Errorf("first hop = %+v", first)
+	}
+	if first.DelaySeconds == nil || *first.DelaySeconds != 30 {
+		t.Errorf("first hop delay = %v, want 30s after the Date header", first.DelaySeconds)
+	}
+
+	second := analysis.Hops[1]
+	if second.From != "mx.lists.example.org" || second.FromHost != "mx.lists.example.org" || second.FromIP != "192.0.2.25" ||
+		second.For != "alice@receiver.example" || !second.TLS || !strings.Contains(second.TLSDetails, "TLS1_3") {
+		t.Errorf("second hop = %+v", second)
+	}
+	if !second.ClockSkew || second.DelaySeconds == nil || *second.DelaySeconds != -352 {
+		t.Errorf("second hop skew = %v, delay = %v", second.ClockSkew, second.DelaySeconds)
+	}
+
+	third := analysis.Hops[2]
+	if third.From != "" || third.By != "2002:a05:6400:1234" || third.Timestamp == nil ||
+		!third.Timestamp.Equal(time.Date(2024, 3, 1, 18, 0, 9, 0, time.UTC)) {
+		t.Errorf("third hop = %+v", third)
+	}
+	if analysis.TotalDelaySeconds == nil || *analysis.TotalDelaySeconds != -321 {
+		t.Errorf("TotalDelaySeconds = %v", analysis.TotalDelaySeconds)
+	}
+
+	if len(analysis.AuthenticationResults) != 1 || len(analysis.AuthenticationResults[0].Results) != 3 {
+		t.Fatalf("AuthenticationResults = %+v", analysis.AuthenticationResults)
+	}
+	if spf := analysis.AuthenticationResults[0].Results[1]; spf.Method != "spf" || spf.Properties["smtp.mailfrom"] != "bounce-123@lists.example.org" {
+		t.Errorf("spf result = %+v", spf)
+	}
+
+	if len(analysis.ARC) != 1 || analysis.ARC[0].SealDomain != "receiver.example" || analysis.ARC[0].ChainValidation != "none" ||
+		analysis.ARC[0].AuthenticationResults == nil || analysis.ARC[0].AuthenticationResults.AuthServID != "mx.receiver.example" {
+		t.Errorf("ARC = %+v", analysis.ARC)
+	}
+	if analysis.List == nil || len(analysis.List.Unsubscribe) != 2 || analysis.List.Unsubscribe[1] != "https://lists.example.org/u/1" {
+		t.Errorf("List = %+v", analysis.List)
+	}
+	if len(analysis.Headers) != 17 || analysis.Headers[0].Name != "Return-Path" {
+		t.Errorf("Headers = %d, first %+v", len(analysis.Headers), analysis.Headers[0])
+	}
+
+	findings := strings.Join(analysis.Findings, "\n")
+	for _, want := range []string{
+		"Hop 1 ([198.51.100.7] to mx.lists.example.org) was not encrypted",
+		"Hop 2 (mx.lists.example.org to mx.receiver.example) is timestamped 5m52s before the previous hop",
+		"Return-Path domain lists.example.org is not aligned",
+		"no List-Unsubscribe-Post header",
+	} {
+		if !strings.Contains(findings, want) {
+			t.Errorf("findings do not mention %q:\n%s", want, findings)
+		}
+	}
+	if strings.Contains(findings, "Hop 3") {
+		t.Errorf("internal handoff reported:\n%s", findings)
+	}
+}
+
+// TestParseReceived tests the Received formats of common MTAs
+func TestParseReceived(t *testing.T) {
+	tests := []struct {
+		value    string
+		from     string
+		fromHost string
+		fromIP   string
+		by       string
+		with     string
+		tls      bool
+	}{
+		{
+			"from mail-sor-f41.google.com (mail-sor-f41.google.com. [209.85.220.41]) by mx.google.com with SMTPS id a1sor (Google Transport Security); Fri, 01 Mar 2024 10:00:00 -0800 (PST)",
+			"mail-sor-f41.google.com", "mail-sor-f41.google.com", "209.85.220.41", "mx.google.com", "SMTPS", true,
+		},
+		{
+			"from EUR05-VI1-obe.outbound.protection.outlook.com (2a01:111:f400:7d00::800) by AM0PR.outlook.office365.com (2603:10a6:208:11::14) with Microsoft SMTP Server (version=TLS1_2, cipher=TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384) id 15.20.7; Fri, 1 Mar 2024 18:00:00 +0000",
+			"EUR05-VI1-obe.outbound.protection.outlook.com", "", "2a01:111:f400:7d00::800", "AM0PR.outlook.office365.com", "Microsoft SMTP Server", true,
+		},
+		{
+			"from host.example ([IPv6:2001:db8::25] helo=host.example) by mail.example.net with esmtpsa (TLS1.3) tls TLS_AES_256_GCM_SHA384 (Exim 4.96) id 1rfX; Fri, 01 Mar 2024 18:00:00 +0000",
+			"host.example", "", "2001:db8::25", "mail.example.net", "esmtpsa tls TLS_AES_256_GCM_SHA384", true,
+		},
+		{
+			"from localhost (localhost [127.0.0.1]) by mail.example.net (Postfix) with ESMTP id 1; Fri, 01 Mar 2024 18:00:00 +0000",
+			"localhost", "", "127.0.0.1", "mail.example.net", "ESMTP", false,
+		},
+	}
+	for _, tt := range tests {
+		hop := parseReceived(tt.value)
+		if hop.From != tt.from || hop.FromHost != tt.fromHost || hop.FromIP != tt.fromIP || hop.By != tt.by || hop.With != tt.with || hop.TLS != tt.tls {
+			t.Errorf("parseReceived(%q) = %+v", tt.value, hop)
+		}
+		if hop.Timestamp == nil {
+			t.Errorf("parseReceived(%q) has no timestamp", tt.value)
+		}
+	}
+}
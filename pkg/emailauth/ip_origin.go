@@ -0,0 +1,92 @@
+// Package emailauth provides functionality for checking email authentication mechanisms.
+package emailauth
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"mxclone/pkg/types"
+)
+
+// Zones of the Team Cymru IP to ASN DNS service (https://www.team-cymru.com/ip-asn-mapping)
+const (
+	cymruOriginZone  = "origin.asn.cymru.com"
+	cymruOrigin6Zone = "origin6.asn.cymru.com"
+	cymruASNZone     = "asn.cymru.com"
+)
+
+// LookupIPOrigin finds the reverse DNS name of an IP address and the autonomous system that
+// announces it. Missing PTR or ASN data leaves the fields empty; an error is only returned
+// when the ASN query fails, with what was found so far.
+func LookupIPOrigin(ctx context.Context, resolver Resolver, ipStr string) (*types.IPOrigin, error) {
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	ip := net.ParseIP(ipStr)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid IP address: %s", ipStr)
+	}
+	origin := &types.IPOrigin{IP: ip.String()}
+
+	if names, err := resolver.LookupAddr(ctx, origin.IP); err == nil && len(names) > 0 {
+		origin.PTR = strings.TrimSuffix(strings.ToLower(names[0]), ".")
+	}
+
+	txts, err := resolver.LookupTXT(ctx, cymruOriginName(ip))
+	if err != nil {
+		if isNotFound(err) {
+			return origin, nil
+		}
+		return origin, fmt.Errorf("ASN lookup for %s failed: %w", origin.IP, err)
+	}
+	if len(txts) == 0 {
+		return origin, nil
+	}
+
+	// "15169 | 8.8.8.0/24 | US | arin | 2023-12-28"; multi-origin prefixes list several ASNs
+	fields := splitCymruFields(txts[0])
+	if len(fields) < 4 {
+		return origin, nil
+	}
+	if asns := strings.Fields(fields[0]); len(asns) > 0 {
+		origin.ASN, _ = strconv.Atoi(asns[0])
+	}
+	origin.Prefix, origin.Country, origin.Registry = fields[1], fields[2], fields[3]
+
+	if origin.ASN > 0 {
+		// "15169 | US | arin | 2000-03-30 | GOOGLE, US"
+		if txts, err := resolver.LookupTXT(ctx, fmt.Sprintf("AS%d.%s", origin.ASN, cymruASNZone)); err == nil && len(txts) > 0 {
+			if fields := splitCymruFields(txts[0]); len(fields) >= 5 {
+				origin.ASName = fields[4]
+			}
+		}
+	}
+
+	return origin, nil
+}
+
+// cymruOriginName returns the name queried for an address: reversed octets for IPv4,
+// reversed nibbles for IPv6.
+func cymruOriginName(ip net.IP) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		return fmt.Sprintf("%d.%d.%d.%d.%s", ip4[3], ip4[2], ip4[1], ip4[0], cymruOriginZone)
+	}
+	var b strings.Builder
+	ip16 := ip.To16()
+	for i := len(ip16) - 1; i >= 0; i-- {
+		fmt.Fprintf(&b, "%x.%x.", ip16[i]&0x0f, ip16[i]>>4)
+	}
+	return b.String() + cymruOrigin6Zone
+}
+
+// splitCymruFields splits a Team Cymru TXT answer on its | separators.
+func splitCymruFields(txt string) []string {
+	fields := strings.Split(txt, "|")
+	for i := range fields {
+		fields[i] = strings.TrimSpace(fields[i])
+	}
+	return fields
+}
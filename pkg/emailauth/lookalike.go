@@ -0,0 +1,152 @@
+// Package emailauth provides functionality for checking email authentication mechanisms.
+package emailauth
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/idna"
+	"golang.org/x/text/unicode/norm"
+
+	"mxclone/pkg/types"
+)
+
+// lookalikeMinLength is the shortest registrable label compared by edit distance. Shorter names are
+// one or two edits away from too many unrelated ones.
+const lookalikeMinLength = 4
+
+// confusableRunes maps characters to the ASCII letter they are mistaken for: digits, and Cyrillic
+// and Greek homoglyphs used in internationalized domain names.
+var confusableRunes = map[rune]rune{
+	'0': 'o', '1': 'l',
+	'а': 'a', 'в': 'b', 'е': 'e', 'һ': 'h', 'і': 'i', 'ј': 'j', 'к': 'k', 'ӏ': 'l', 'м': 'm',
+	'н': 'h', 'о': 'o', 'р': 'p', 'с': 'c', 'ԛ': 'q', 'ѕ': 's', 'т': 't', 'у': 'y', 'ԝ': 'w',
+	'х': 'x', 'ԁ': 'd', 'ɡ': 'g', 'ɩ': 'l', 'ı': 'i',
+	'α': 'a', 'β': 'b', 'ε': 'e', 'η': 'n', 'ι': 'i', 'κ': 'k', 'ν': 'v', 'ο': 'o', 'ρ': 'p',
+	'τ': 't', 'υ': 'u', 'χ': 'x', 'ω': 'w',
+}
+
+// confusableSequences are letter pairs that read as a single letter.
+var confusableSequences = strings.NewReplacer("rn", "m", "vv", "w")
+
+// DetectLookalike reports whether a domain imitates the organizational domain of reference
+// without belonging to it: the same name under another public suffix, a name that only differs
+// in confusable characters (rn for m, 0 for o, Cyrillic or Greek homoglyphs), or a name within a
+// small edit distance of the other. It returns nil for aligned and unrelated domains.
+func DetectLookalike(domain, reference string) *types.LookalikeDomain {
+	org, referenceOrg := OrganizationalDomain(domain), OrganizationalDomain(reference)
+	if org == "" || referenceOrg == "" || org == referenceOrg {
+		return nil
+	}
+	label, suffix, ok := strings.Cut(org, ".")
+	referenceLabel, referenceSuffix, referenceOK := strings.Cut(referenceOrg, ".")
+	if !ok || !referenceOK {
+		// A public suffix has no registrable label
+		return nil
+	}
+
+	display, referenceDisplay := unicodeLabel(label), unicodeLabel(referenceLabel)
+	skeleton, referenceSkeleton := confusableSkeleton(display), confusableSkeleton(referenceDisplay)
+	distance := levenshtein(skeleton, referenceSkeleton)
+
+	lookalike := &types.LookalikeDomain{
+		Domain:               strings.ToLower(strings.TrimSuffix(domain, ".")),
+		OrganizationalDomain: org,
+		ImitatedDomain:       referenceOrg,
+		Distance:             distance,
+	}
+	switch {
+	case label == referenceLabel:
+		lookalike.Reason = fmt.Sprintf("same name under the public suffix %s instead of %s", suffix, referenceSuffix)
+	case distance == 0 && display != label:
+		lookalike.Reason = fmt.Sprintf("internationalized name %s uses characters that look like %s", display, referenceDisplay)
+	case distance == 0:
+		lookalike.Reason = fmt.Sprintf("%s differs from %s only in characters that look alike", label, referenceDisplay)
+	case len([]rune(referenceSkeleton)) >= lookalikeMinLength && distance <= maxLookalikeDistance(referenceSkeleton):
+		lookalike.Reason = fmt.Sprintf("%s is %d edit(s) away from %s", display, distance, referenceDisplay)
+	default:
+		return nil
+	}
+	return lookalike
+}
+
+// maxLookalikeDistance is the largest edit distance at which a name still imitates another:
+// one edit for short names, two for names of nine characters or more.
+func maxLookalikeDistance(name string) int {
+	if len([]rune(name)) >= 9 {
+		return 2
+	}
+	return 1
+}
+
+// unicodeLabel decodes a punycode (xn--) label; other labels are returned as they are.
+func unicodeLabel(label string) string {
+	if decoded, err := idna.ToUnicode(label); err == nil {
+		return decoded
+	}
+	return label
+}
+
+// confusableSkeleton lowercases a label, strips diacritics and replaces confusable characters and
+// letter pairs with the letter they are mistaken for.
+func confusableSkeleton(label string) string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(strings.ToLower(label)) {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		if mapped, ok := confusableRunes[r]; ok {
+			r = mapped
+		}
+		b.WriteRune(r)
+	}
+	return confusableSequences.Replace(b.String())
+}
+
+// levenshtein returns the number of single-character insertions, deletions and substitutions
+// that turn a into b.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
+
+// findLookalikes checks the SPF and DKIM domains of a message against its From domain.
+func findLookalikes(fromDomain string, report *types.MessageAuthReport) []types.LookalikeDomain {
+	var lookalikes []types.LookalikeDomain
+	seen := make(map[string]bool)
+	add := func(domain, source string) {
+		if domain == "" || seen[source+" "+domain] {
+			return
+		}
+		seen[source+" "+domain] = true
+		if lookalike := DetectLookalike(domain, fromDomain); lookalike != nil {
+			lookalike.Source = source
+			lookalikes = append(lookalikes, *lookalike)
+		}
+	}
+
+	if report.SPF != nil {
+		add(report.SPF.Domain, "spf")
+	}
+	for _, sig := range report.DKIM {
+		add(sig.Domain, "dkim")
+	}
+	return lookalikes
+}
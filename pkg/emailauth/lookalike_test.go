@@ -0,0 +1,154 @@
+// Package emailauth provides functionality for checking email authentication mechanisms.
+package emailauth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+// TestDetectLookalike tests confusable characters, homoglyph IDNs and edit distance on the
+// registrable label, and that aligned and unrelated domains are not reported
+func TestDetectLookalike(t *testing.T) {
+	tests := []struct {
+		domain       string
+		reference    string
+		wantDistance int
+		wantReason   string // Empty when the domain is not a lookalike
+	}{
+		{"mail.example.com", "example.com", 0, ""},
+		{"bounces.paypal.com", "www.paypal.com", 0, ""},
+		{"paypa1.com", "paypal.com", 0, "only in characters that look alike"},
+		{"g00gle.com", "google.com", 0, "only in characters that look alike"},
+		{"rnicrosoft.com", "microsoft.com", 0, "only in characters that look alike"},
+		{"xn--pypal-4ve.com", "paypal.com", 0, "internationalized name pаypal uses characters that look like paypal"},
+		{"xn--paypl-jra.com", "paypal.com", 0, "internationalized name paypäl"},
+		{"xn--pple-43d.com", "apple.com", 0, "internationalized name"},
+		{"paypal.co.uk", "paypal.com", 0, "same name under the public suffix co.uk instead of com"},
+		{"paypall.com", "paypal.com", 1, "1 edit(s) away from paypal"},
+		{"mail.amazom.com", "amazon.com", 1, "1 edit(s) away from amazon"},
+		{"micorsoft.com", "microsoft.com", 2, "2 edit(s) away from microsoft"},
+		{"paypaal1.com", "paypal.com", 2, ""},
+		{"ibn.com", "ibm.com", 1, ""},
+		{"example.net", "unrelated.org", 9, ""},
+		{"co.uk", "example.co.uk", 0, ""},
+		{"", "example.com", 0, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.domain, func(t *testing.T) {
+			lookalike := DetectLookalike(tt.domain, tt.reference)
+			if tt.wantReason == "" {
+				if lookalike != nil {
+					t.Errorf("DetectLookalike(%q, %q) = %+v, want nil", tt.domain, tt.reference, lookalike)
+				}
+				return
+			}
+			if lookalike == nil {
+				t.Fatalf("DetectLookalike(%q, %q) = nil, want a lookalike", tt.domain, tt.reference)
+			}
+			if !strings.Contains(lookalike.Reason, tt.wantReason) {
+				t.Errorf("Reason = %q, want %q", lookalike.Reason, tt.wantReason)
+			}
+			if lookalike.Distance != tt.wantDistance {
+				t.Errorf("Distance = %d, want %d", lookalike.Distance, tt.wantDistance)
+			}
+			if lookalike.ImitatedDomain != OrganizationalDomain(tt.reference) || lookalike.OrganizationalDomain != OrganizationalDomain(tt.domain) {
+				t.Errorf("DetectLookalike() = %+v", lookalike)
+			}
+		})
+	}
+}
+
+// TestLevenshtein tests the edit distance on runes
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"", "abc", 3},
+		{"kitten", "sitting", 3},
+		{"paypal", "paypall", 1},
+		{"micorsoft", "microsoft", 2},
+		{"päypal", "paypal", 1},
+	}
+	for _, tt := range tests {
+		if got := levenshtein(tt.a, tt.b); got != tt.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := levenshtein(tt.b, tt.a); got != tt.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", tt.b, tt.a, got, tt.want)
+		}
+	}
+}
+
+// TestAnalyzeMessageLookalikes tests that SPF and DKIM domains imitating the From domain are
+// reported in the DMARC evaluation
+func TestAnalyzeMessageLookalikes(t *testing.T) {
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	lookup := func(ctx context.Context, domain, selector string) (string, error) {
+		return "v=DKIM1; k=ed25519; p=" + base64.StdEncoding.EncodeToString(edKey.Public().(ed25519.PublicKey)), nil
+	}
+	resolver := &fakeResolver{
+		txt: map[string][]string{
+			"examp1e.com":        {"v=spf1 ip4:192.0.2.0/24 -all"},
+			"_dmarc.example.com": {"v=DMARC1; p=reject"},
+		},
+	}
+
+	raw := signMessage(t, testMessage, "mail.exarnple.com", "ed", "ed25519-sha256", "relaxed/relaxed", "", edKey)
+	raw = signMessage(t, raw, "example.com", "ed", "ed25519-sha256", "relaxed/relaxed", "", edKey)
+	report := AnalyzeMessage(context.Background(), MessageAnalysisOptions{
+		ClientIP:      "192.0.2.1",
+		Helo:          "mta.examp1e.com",
+		MailFrom:      "b@examp1e.com",
+		Raw:           []byte(raw),
+		Resolver:      resolver,
+		DKIMKeyLookup: lookup,
+	})
+
+	if report.DMARC.Result != DMARCPass {
+		t.Errorf("DMARC = %q (%s), want pass from the aligned signature", report.DMARC.Result, report.DMARC.Error)
+	}
+	lookalikes := report.DMARC.Lookalikes
+	if len(lookalikes) != 2 {
+		t.Fatalf("Lookalikes = %+v, want the SPF and one DKIM domain", lookalikes)
+	}
+	if spf := lookalikes[0]; spf.Source != "spf" || spf.Domain != "examp1e.com" || spf.ImitatedDomain != "example.com" {
+		t.Errorf("SPF lookalike = %+v", spf)
+	}
+	if dkim := lookalikes[1]; dkim.Source != "dkim" || dkim.Domain != "mail.exarnple.com" || dkim.OrganizationalDomain != "exarnple.com" || dkim.Distance != 0 {
+		t.Errorf("DKIM lookalike = %+v", dkim)
+	}
+}
+
+// TestAnalyzeHeadersLookalikes tests the findings for Return-Path, Reply-To and DKIM domains
+// that imitate the From domain
+func TestAnalyzeHeadersLookalikes(t *testing.T) {
+	headers := "Return-Path: <bounce@mail.paypa1.com>\r\n" +
+		"DKIM-Signature: v=1; a=rsa-sha256; d=paypal.com; s=s1; h=from; bh=AAAA; b=BBBB\r\n" +
+		"DKIM-Signature: v=1; a=rsa-sha256; d=xn--pypal-4ve.com; s=s1; h=from; bh=AAAA; b=BBBB\r\n" +
+		"From: PayPal <service@paypal.com>\r\n" +
+		"Reply-To: Support <support@paypall.com>, billing@paypal.com\r\n" +
+		"Date: Fri, 1 Mar 2024 18:05:30 +0000\r\n" +
+		"Message-ID: <1@paypal.com>\r\n"
+
+	analysis := AnalyzeHeaders([]byte(headers))
+	findings := strings.Join(analysis.Findings, "\n")
+	for _, want := range []string{
+		"Return-Path domain mail.paypa1.com looks like the From domain paypal.com",
+		"Reply-To domain paypall.com looks like the From domain paypal.com: paypall is 1 edit(s) away from paypal",
+		"DKIM signing domain xn--pypal-4ve.com looks like the From domain paypal.com: internationalized name",
+	} {
+		if !strings.Contains(findings, want) {
+			t.Errorf("findings do not mention %q:\n%s", want, findings)
+		}
+	}
+	if strings.Count(findings, "looks like") != 3 {
+		t.Errorf("findings report aligned domains as lookalikes:\n%s", findings)
+	}
+}
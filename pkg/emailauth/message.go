@@ -0,0 +1,264 @@
+// Package emailauth provides functionality for checking email authentication mechanisms.
+package emailauth
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/mail"
+	"strings"
+	"time"
+
+	"mxclone/pkg/types"
+)
+
+// headerField is a header field of a message as it appeared on the wire, folding and CRLF included.
+type headerField struct {
+	name string
+	raw  string
+}
+
+// value returns the field body, after the colon.
+func (h headerField) value() string {
+	return h.raw[strings.IndexByte(h.raw, ':')+1:]
+}
+
+// DMARC results (RFC 7489 section 11.2)
+const (
+	DMARCPass      = "pass"
+	DMARCFail      = "fail"
+	DMARCNone      = "none"
+	DMARCPermError = "permerror"
+	DMARCTempError = "temperror"
+)
+
+// MessageAnalysisOptions describes a received message and how to evaluate it.
+type MessageAnalysisOptions struct {
+	// ClientIP is the address of the SMTP client that delivered the message
+	ClientIP string
+	// Helo is the name the client announced in EHLO or HELO
+	Helo string
+	// MailFrom is the envelope sender; empty for the null reverse-path
+	MailFrom string
+	// Raw is the message as received, headers and body
+	Raw []byte
+	// Resolver is used for SPF and DMARC lookups (default net.DefaultResolver)
+	Resolver Resolver
+	// DKIMKeyLookup fetches DKIM keys (default GetDKIMRecord)
+	DKIMKeyLookup DKIMKeyLookup
+	// Timeout applies to each DNS lookup
+	Timeout time.Duration
+}
+
+// AnalyzeMessage evaluates SPF for the connecting IP, verifies the DKIM signatures, checks DMARC
+// alignment against the From header and reports header problems of a received message.
+func AnalyzeMessage(ctx context.Context, opts MessageAnalysisOptions) *types.MessageAuthReport {
+	if opts.Resolver == nil {
+		opts.Resolver = net.DefaultResolver
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 5 * time.Second
+	}
+
+	report := &types.MessageAuthReport{}
+
+	// SPF applies to the MAIL FROM domain, or to the HELO name for the null reverse-path
+	spfDomain := opts.Helo
+	if at := strings.LastIndexByte(opts.MailFrom, '@'); at >= 0 {
+		spfDomain = opts.MailFrom[at+1:]
+	}
+	if ip := net.ParseIP(opts.ClientIP); ip != nil {
+		report.SPF = CheckHost(ctx, SPFCheckOptions{
+			Resolver: opts.Resolver,
+			IP:       ip,
+			Domain:   spfDomain,
+			Sender:   opts.MailFrom,
+			Helo:     opts.Helo,
+		})
+	} else {
+		report.Error = fmt.Sprintf("invalid client IP %q", opts.ClientIP)
+	}
+
+	report.DKIM = VerifyDKIM(ctx, opts.Raw, opts.DKIMKeyLookup, opts.Timeout)
+	report.Headers = analyzeMessageHeaders(opts.Raw)
+	report.DMARC = evaluateDMARC(ctx, opts.Resolver, report)
+
+	return report
+}
+
+// evaluateDMARC looks up the DMARC policy of the From domain and checks SPF and DKIM alignment.
+func evaluateDMARC(ctx context.Context, resolver Resolver, report *types.MessageAuthReport) *types.DMARCEvaluation {
+	evaluation := &types.DMARCEvaluation{Result: DMARCNone}
+
+	fromDomain, err := headerFromDomain(report.Headers.From)
+	if err != nil {
+		evaluation.Result = DMARCPermError
+		evaluation.Error = err.Error()
+		return evaluation
+	}
+	evaluation.FromDomain = fromDomain
+	evaluation.Lookalikes = findLookalikes(fromDomain, report)
+
+	discovery := DiscoverDMARCPolicy(ctx, resolver, fromDomain)
+	if discovery.Error != "" {
+		evaluation.Result = DMARCTempError
+		evaluation.Error = discovery.Error
+		return evaluation
+	}
+	if discovery.Record == "" {
+		evaluation.Error = "no DMARC record found for domain: " + fromDomain
+		return evaluation
+	}
+	record, err := ParseDMARCRecord(discovery.Record)
+	if err != nil {
+		evaluation.Result = DMARCPermError
+		evaluation.Error = err.Error()
+		return evaluation
+	}
+	evaluation.Record = record.Raw
+	evaluation.PolicyDomain = discovery.PolicyDomain
+	evaluation.Policy = discovery.AppliedPolicy
+
+	if report.SPF != nil && report.SPF.Result == SPFPass {
+		evaluation.SPFAligned = domainsAligned(report.SPF.Domain, fromDomain, record.ASPF)
+	}
+	for _, sig := range report.DKIM {
+		if sig.Result == DKIMPass && domainsAligned(sig.Domain, fromDomain, record.ADKIM) {
+			evaluation.DKIMAligned = true
+		}
+	}
+
+	if evaluation.SPFAligned || evaluation.DKIMAligned {
+		evaluation.Result = DMARCPass
+	} else {
+		evaluation.Result = DMARCFail
+		evaluation.Disposition = evaluation.Policy
+	}
+
+	return evaluation
+}
+
+// domainsAligned reports whether an authenticated domain aligns with the From domain in strict ("s") or relaxed mode.
+func domainsAligned(authenticated, from, mode string) bool {
+	authenticated = strings.ToLower(strings.TrimSuffix(authenticated, "."))
+	if mode == "s" {
+		return authenticated == from
+	}
+	return OrganizationalDomain(authenticated) == OrganizationalDomain(from)
+}
+
+// headerFromDomain returns the domain of the single author address of a From header.
+func headerFromDomain(from string) (string, error) {
+	if from == "" {
+		return "", fmt.Errorf("message has no From header")
+	}
+	addresses, err := mail.ParseAddressList(from)
+	if err != nil {
+		return "", fmt.Errorf("unparseable From header: %w", err)
+	}
+	if len(addresses) != 1 {
+		return "", fmt.Errorf("From header has %d addresses", len(addresses))
+	}
+	at := strings.LastIndexByte(addresses[0].Address, '@')
+	return strings.ToLower(addresses[0].Address[at+1:]), nil
+}
+
+// analyzeMessageHeaders extracts the main header fields and flags missing or duplicated ones.
+func analyzeMessageHeaders(raw []byte) *types.MessageHeaderReport {
+	headers, _ := splitMessage(raw)
+	report := &types.MessageHeaderReport{}
+
+	counts := make(map[string]int)
+	for _, field := range headers {
+		counts[field.name]++
+		value := strings.TrimSpace(strings.ReplaceAll(field.value(), "\r\n", ""))
+		switch field.name {
+		case "from":
+			report.From = value
+		case "to":
+			report.To = value
+		case "subject":
+			report.Subject = value
+		case "date":
+			report.Date = value
+		case "message-id":
+			report.MessageID = value
+		case "received":
+			report.ReceivedHops++
+		}
+	}
+
+	for _, name := range []string{"from", "date"} {
+		switch {
+		case counts[name] == 0:
+			report.Findings = append(report.Findings, fmt.Sprintf("Required %s header is missing", headerDisplayName(name)))
+		case counts[name] > 1:
+			report.Findings = append(report.Findings, fmt.Sprintf("%s header appears %d times", headerDisplayName(name), counts[name]))
+		}
+	}
+	for _, name := range []string{"message-id", "subject", "to", "reply-to"} {
+		if counts[name] > 1 {
+			report.Findings = append(report.Findings, fmt.Sprintf("%s header appears %d times", headerDisplayName(name), counts[name]))
+		}
+	}
+	if counts["message-id"] == 0 {
+		report.Findings = append(report.Findings, "Message-ID header is missing")
+	}
+	if report.Date != "" {
+		if _, err := mail.ParseDate(report.Date); err != nil {
+			report.Findings = append(report.Findings, "Date header is not a valid RFC 5322 date")
+		}
+	}
+	if counts["dkim-signature"] == 0 {
+		report.Findings = append(report.Findings, "Message is not DKIM-signed")
+	}
+
+	return report
+}
+
+// headerDisplayName returns the conventional capitalization of a header name.
+func headerDisplayName(name string) string {
+	if name == "message-id" {
+		return "Message-ID"
+	}
+	parts := strings.Split(name, "-")
+	for i, part := range parts {
+		if part != "" {
+			parts[i] = strings.ToUpper(part[:1]) + part[1:]
+		}
+	}
+	return strings.Join(parts, "-")
+}
+
+// splitMessage splits a raw message into header fields and body. Line endings are normalized to CRLF.
+func splitMessage(raw []byte) ([]headerField, []byte) {
+	text := strings.ReplaceAll(string(raw), "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\n", "\r\n")
+
+	headerPart, body, found := strings.Cut(text, "\r\n\r\n")
+	if !found {
+		// A message without a body may still end its header section with a single CRLF
+		headerPart = strings.TrimSuffix(text, "\r\n")
+	}
+
+	var headers []headerField
+	for _, line := range strings.SplitAfter(headerPart+"\r\n", "\r\n") {
+		if line == "" {
+			continue
+		}
+		if (line[0] == ' ' || line[0] == '\t') && len(headers) > 0 {
+			headers[len(headers)-1].raw += line
+			continue
+		}
+		name, _, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		headers = append(headers, headerField{
+			name: strings.ToLower(strings.TrimSpace(name)),
+			raw:  line,
+		})
+	}
+
+	return headers, []byte(body)
+}
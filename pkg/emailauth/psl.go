@@ -0,0 +1,213 @@
+// Package emailauth provides functionality for checking email authentication mechanisms.
+package emailauth
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	_ "embed"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/idna"
+)
+
+// PublicSuffixListURL is where the maintained Public Suffix List is published.
+const PublicSuffixListURL = "https://publicsuffix.org/list/public_suffix_list.dat"
+
+// bundledPublicSuffixList is the snapshot of the Public Suffix List shipped with the binary.
+//
+//go:embed public_suffix_list.dat
+var bundledPublicSuffixList []byte
+
+// PublicSuffixList holds the rules of the Public Suffix List (https://publicsuffix.org/list/).
+// Both the ICANN and the private sections are used, as DMARC implementations do.
+type PublicSuffixList struct {
+	rules      map[string]bool // Normal rules, e.g. co.uk
+	wildcards  map[string]bool // Parents of wildcard rules: ck for *.ck
+	exceptions map[string]bool // Exception rules, e.g. www.ck for !www.ck
+}
+
+var (
+	pslMu      sync.RWMutex
+	defaultPSL *PublicSuffixList
+)
+
+// ParsePublicSuffixList reads a list in the public_suffix_list.dat format: one rule per line,
+// // comments, *. wildcard rules and ! exception rules.
+func ParsePublicSuffixList(r io.Reader) (*PublicSuffixList, error) {
+	list := &PublicSuffixList{
+		rules:      make(map[string]bool),
+		wildcards:  make(map[string]bool),
+		exceptions: make(map[string]bool),
+	}
+
+	scanner := bufio.NewScanner(r)
+	count := 0
+	for scanner.Scan() {
+		// A rule ends at the first whitespace
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "//") {
+			continue
+		}
+		rule := strings.ToLower(fields[0])
+		// Internationalized rules are matched in their A-label form, as domains are looked up
+		if ascii, err := idna.ToASCII(rule); err == nil {
+			rule = ascii
+		}
+		switch {
+		case strings.HasPrefix(rule, "!"):
+			list.exceptions[rule[1:]] = true
+		case strings.HasPrefix(rule, "*."):
+			list.wildcards[rule[2:]] = true
+		default:
+			list.rules[rule] = true
+		}
+		count++
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read the public suffix list: %w", err)
+	}
+	if count == 0 {
+		return nil, fmt.Errorf("the public suffix list has no rules")
+	}
+
+	return list, nil
+}
+
+// Len returns the number of rules of the list.
+func (l *PublicSuffixList) Len() int {
+	return len(l.rules) + len(l.wildcards) + len(l.exceptions)
+}
+
+// PublicSuffix returns the public suffix of a domain, following the algorithm of
+// https://publicsuffix.org/list/: the longest matching rule wins, an exception rule
+// removes its leftmost label and the implicit * rule makes any TLD a public suffix.
+func (l *PublicSuffixList) PublicSuffix(domain string) string {
+	domain = strings.TrimSuffix(strings.ToLower(domain), ".")
+	labels := strings.Split(domain, ".")
+
+	// Candidates are tried from the longest to the shortest
+	for i := range labels {
+		candidate := strings.Join(labels[i:], ".")
+		if l.exceptions[candidate] {
+			return strings.Join(labels[i+1:], ".")
+		}
+		if l.rules[candidate] {
+			return candidate
+		}
+		if i+1 < len(labels) && l.wildcards[strings.Join(labels[i+1:], ".")] {
+			return candidate
+		}
+	}
+	return labels[len(labels)-1]
+}
+
+// OrganizationalDomain returns the public suffix of a domain plus one label, or the
+// domain itself when it is a public suffix.
+func (l *PublicSuffixList) OrganizationalDomain(domain string) string {
+	domain = strings.TrimSuffix(strings.ToLower(domain), ".")
+	suffix := l.PublicSuffix(domain)
+	if domain == suffix {
+		return domain
+	}
+	rest := strings.TrimSuffix(domain, "."+suffix)
+	return rest[strings.LastIndexByte(rest, '.')+1:] + "." + suffix
+}
+
+// currentPublicSuffixList returns the list in use, the bundled snapshot unless one was loaded.
+func currentPublicSuffixList() *PublicSuffixList {
+	pslMu.RLock()
+	list := defaultPSL
+	pslMu.RUnlock()
+	if list != nil {
+		return list
+	}
+
+	pslMu.Lock()
+	defer pslMu.Unlock()
+	if defaultPSL == nil {
+		bundled, err := ParsePublicSuffixList(bytes.NewReader(bundledPublicSuffixList))
+		if err != nil {
+			panic("bundled public suffix list: " + err.Error())
+		}
+		defaultPSL = bundled
+	}
+	return defaultPSL
+}
+
+// SetPublicSuffixList replaces the list used by OrganizationalDomain.
+func SetPublicSuffixList(list *PublicSuffixList) {
+	pslMu.Lock()
+	defaultPSL = list
+	pslMu.Unlock()
+}
+
+// LoadPublicSuffixList reads a list from a file and uses it in place of the bundled snapshot.
+func LoadPublicSuffixList(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	list, err := ParsePublicSuffixList(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	SetPublicSuffixList(list)
+	return nil
+}
+
+// UpdatePublicSuffixList downloads the list from url, checks that it parses, saves it to path
+// and uses it from now on. It returns the number of rules of the new list.
+func UpdatePublicSuffixList(ctx context.Context, url, path string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to download the public suffix list: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("failed to download the public suffix list: %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, fmt.Errorf("failed to download the public suffix list: %w", err)
+	}
+	list, err := ParsePublicSuffixList(bytes.NewReader(data))
+	if err != nil {
+		return 0, err
+	}
+
+	// Write to a temporary file first so that a failed update leaves the previous list intact
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return 0, err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return 0, err
+	}
+
+	SetPublicSuffixList(list)
+	return list.Len(), nil
+}
+
+// OrganizationalDomain returns the organizational domain of a domain (RFC 7489 section 3.2):
+// its public suffix according to the Public Suffix List, plus one label.
+func OrganizationalDomain(domain string) string {
+	return currentPublicSuffixList().OrganizationalDomain(domain)
+}
@@ -0,0 +1,74 @@
+// Package emailauth provides functionality for checking email authentication mechanisms.
+package emailauth
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestPublicSuffixList tests normal, wildcard and exception rules
+func TestPublicSuffixList(t *testing.T) {
+	list, err := ParsePublicSuffixList(strings.NewReader(`// ===BEGIN ICANN DOMAINS===
+com
+uk
+co.uk
+ck
+*.ck
+!www.ck
+// ===BEGIN PRIVATE DOMAINS===
+github.io
+`))
+	if err != nil {
+		t.Fatalf("ParsePublicSuffixList() error = %v", err)
+	}
+	if list.Len() != 7 {
+		t.Errorf("Len() = %d, want 7", list.Len())
+	}
+
+	tests := []struct {
+		domain, suffix, org string
+	}{
+		{"example.com", "com", "example.com"},
+		{"mail.example.com", "com", "example.com"},
+		{"Mail.Example.COM.", "com", "example.com"},
+		{"a.b.example.co.uk", "co.uk", "example.co.uk"},
+		{"co.uk", "co.uk", "co.uk"},
+		{"user.github.io", "github.io", "user.github.io"},
+		{"foo.ck", "foo.ck", "foo.ck"},
+		{"bar.foo.ck", "foo.ck", "bar.foo.ck"},
+		{"www.ck", "ck", "www.ck"},
+		{"a.www.ck", "ck", "www.ck"},
+		{"example.unknowntld", "unknowntld", "example.unknowntld"},
+	}
+	for _, tt := range tests {
+		if got := list.PublicSuffix(tt.domain); got != tt.suffix {
+			t.Errorf("PublicSuffix(%q) = %q, want %q", tt.domain, got, tt.suffix)
+		}
+		if got := list.OrganizationalDomain(tt.domain); got != tt.org {
+			t.Errorf("OrganizationalDomain(%q) = %q, want %q", tt.domain, got, tt.org)
+		}
+	}
+
+	if _, err := ParsePublicSuffixList(strings.NewReader("// only comments\n")); err == nil {
+		t.Errorf("ParsePublicSuffixList() of an empty list error = nil, want an error")
+	}
+}
+
+// TestOrganizationalDomain tests the bundled list
+func TestOrganizationalDomain(t *testing.T) {
+	tests := map[string]string{
+		"example.com":            "example.com",
+		"mail.example.com":       "example.com",
+		"news.bbc.co.uk":         "bbc.co.uk",
+		"a.b.example.com.au":     "example.com.au",
+		"project.github.io":      "project.github.io",
+		"www.city.kawasaki.jp":   "city.kawasaki.jp",
+		"mail.example.xn--p1ai":  "example.xn--p1ai",
+		"deep.sub.example.co.jp": "example.co.jp",
+	}
+	for domain, want := range tests {
+		if got := OrganizationalDomain(domain); got != want {
+			t.Errorf("OrganizationalDomain(%q) = %q, want %q", domain, got, want)
+		}
+	}
+}
@@ -0,0 +1,564 @@
+// Package emailauth provides functionality for checking email authentication mechanisms.
+package emailauth
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"mxclone/pkg/dns"
+	"mxclone/pkg/types"
+)
+
+// Limits checked by the lookup analysis (RFC 7208 sections 3.4 and 4.6.4)
+const (
+	spfMXNameLimit       = 10
+	spfRecordLengthLimit = 450 // Keeps the answer within a 512-byte UDP response
+)
+
+// SPFAnalysisOptions configures AnalyzeSPFLookups.
+type SPFAnalysisOptions struct {
+	// Resolver is used for a, mx and exists lookups (default net.DefaultResolver)
+	Resolver Resolver
+	// LookupTXT returns TXT records with their character-strings kept apart. The default
+	// queries the system name server, or uses Resolver.LookupTXT when a Resolver is set.
+	LookupTXT func(ctx context.Context, name string) ([][]string, error)
+	// MaxRecordLength is the longest flattened record before it is split (default 450)
+	MaxRecordLength int
+	// ChunkPrefix names the include: chunks of a split record: <prefix>1.<domain>, ... (default "_spf")
+	ChunkPrefix string
+}
+
+// spfAnalyzer holds the state of one lookup analysis.
+type spfAnalyzer struct {
+	opts     SPFAnalysisOptions
+	analysis *types.SPFLookupAnalysis
+	visiting map[string]bool
+}
+
+// AnalyzeSPFLookups expands the include/redirect tree of a domain's SPF record, counts DNS-querying
+// terms and void lookups against the limits of 10 and 2, checks the mx and ptr sub-limits and record
+// lengths, and generates a flattened candidate record.
+func AnalyzeSPFLookups(ctx context.Context, domain string, opts SPFAnalysisOptions) *types.SPFLookupAnalysis {
+	if opts.LookupTXT == nil {
+		if opts.Resolver != nil {
+			resolver := opts.Resolver
+			opts.LookupTXT = func(ctx context.Context, name string) ([][]string, error) {
+				txts, err := resolver.LookupTXT(ctx, name)
+				records := make([][]string, 0, len(txts))
+				for _, txt := range txts {
+					records = append(records, []string{txt})
+				}
+				return records, err
+			}
+		} else {
+			opts.LookupTXT = func(ctx context.Context, name string) ([][]string, error) {
+				return dns.LookupTXTStrings(ctx, name, "")
+			}
+		}
+	}
+	if opts.Resolver == nil {
+		opts.Resolver = net.DefaultResolver
+	}
+	if opts.MaxRecordLength <= 0 {
+		opts.MaxRecordLength = spfRecordLengthLimit
+	}
+	if opts.ChunkPrefix == "" {
+		opts.ChunkPrefix = "_spf"
+	}
+	domain = strings.TrimSuffix(strings.ToLower(domain), ".")
+
+	analysis := &types.SPFLookupAnalysis{
+		Domain:          domain,
+		LookupLimit:     spfLookupLimit,
+		VoidLookupLimit: spfVoidLookupLimit,
+	}
+	a := &spfAnalyzer{opts: opts, analysis: analysis, visiting: make(map[string]bool)}
+
+	analysis.Tree = a.expand(ctx, domain, 0)
+	analysis.Lookups = analysis.Tree.Lookups
+	analysis.VoidLookups = analysis.Tree.VoidLookups
+	analysis.RecordLength = analysis.Tree.Length
+	if analysis.Tree.Record == "" {
+		analysis.Error = analysis.Tree.Error
+		return analysis
+	}
+
+	switch {
+	case analysis.Lookups > spfLookupLimit:
+		a.addFinding(types.SeverityCritical, "spf-lookup-limit", domain,
+			fmt.Sprintf("The record needs %d DNS lookups, more than the limit of %d; receivers return permerror", analysis.Lookups, spfLookupLimit))
+	case analysis.Lookups >= spfLookupLimit-1:
+		a.addFinding(types.SeverityWarning, "spf-lookup-budget", domain,
+			fmt.Sprintf("The record needs %d of %d DNS lookups; a provider adding an include will break it", analysis.Lookups, spfLookupLimit))
+	}
+	switch {
+	case analysis.VoidLookups > spfVoidLookupLimit:
+		a.addFinding(types.SeverityCritical, "spf-void-lookup-limit", domain,
+			fmt.Sprintf("%d lookups return no answer, more than the limit of %d; receivers return permerror", analysis.VoidLookups, spfVoidLookupLimit))
+	case analysis.VoidLookups > 0:
+		a.addFinding(types.SeverityWarning, "spf-void-lookup", domain,
+			fmt.Sprintf("%d lookups return no answer (limit %d)", analysis.VoidLookups, spfVoidLookupLimit))
+	}
+
+	if analysis.Lookups > 0 {
+		analysis.Flattened = a.flatten(analysis.Tree)
+	}
+
+	return analysis
+}
+
+// expand fetches the SPF record of a domain and analyzes its terms, expanding include and redirect targets.
+func (a *spfAnalyzer) expand(ctx context.Context, domain string, depth int) *types.SPFTreeNode {
+	node := &types.SPFTreeNode{Domain: domain}
+
+	if depth > spfLookupLimit {
+		node.Error = "too many nested include or redirect terms"
+		a.addFinding(types.SeverityCritical, "spf-nesting", domain, node.Error)
+		return node
+	}
+	if a.visiting[domain] {
+		node.Error = "include loop"
+		a.addFinding(types.SeverityCritical, "spf-include-loop", domain,
+			fmt.Sprintf("%s includes itself through its include or redirect terms", domain))
+		return node
+	}
+	a.visiting[domain] = true
+	defer delete(a.visiting, domain)
+
+	txts, err := a.opts.LookupTXT(ctx, domain)
+	if err != nil && !isNotFound(err) {
+		node.Error = fmt.Sprintf("TXT lookup failed: %v", err)
+		a.addFinding(types.SeverityWarning, "spf-lookup-failed", domain, node.Error)
+		return node
+	}
+	var records [][]string
+	for _, txt := range txts {
+		record := strings.ToLower(strings.Join(txt, ""))
+		if record == "v=spf1" || strings.HasPrefix(record, "v=spf1 ") {
+			records = append(records, txt)
+		}
+	}
+	switch len(records) {
+	case 0:
+		node.Error = "no SPF record found"
+		if depth == 0 {
+			a.addFinding(types.SeverityCritical, "spf-missing", domain, fmt.Sprintf("%s publishes no SPF record", domain))
+		} else {
+			a.addFinding(types.SeverityCritical, "spf-target-missing", domain,
+				fmt.Sprintf("%s is included or redirected to but publishes no SPF record; receivers return permerror", domain))
+		}
+		return node
+	case 1:
+	default:
+		node.Error = fmt.Sprintf("%d SPF records", len(records))
+		a.addFinding(types.SeverityCritical, "spf-multiple-records", domain,
+			fmt.Sprintf("%s publishes %d SPF records; receivers return permerror", domain, len(records)))
+		return node
+	}
+
+	for _, s := range records[0] {
+		node.StringLengths = append(node.StringLengths, len(s))
+		node.Length += len(s)
+	}
+	node.Record = strings.Join(records[0], "")
+	if node.Length > spfRecordLengthLimit {
+		a.addFinding(types.SeverityWarning, "spf-record-length", domain,
+			fmt.Sprintf("The record is %d characters; answers over 512 bytes need EDNS or TCP, which some receivers do not retry", node.Length))
+	}
+	if len(node.StringLengths) > 1 {
+		a.addFinding(types.SeverityInfo, "spf-multi-string", domain,
+			fmt.Sprintf("The record is split into %d TXT strings %v, which receivers join without spaces", len(node.StringLengths), node.StringLengths))
+	}
+	if _, err := CheckSPFSyntax(node.Record); err != nil {
+		a.addFinding(types.SeverityCritical, "spf-syntax", domain, err.Error())
+	}
+
+	terms := strings.Fields(node.Record)[1:]
+	hasAll := false
+	for _, term := range terms {
+		hasAll = hasAll || strings.EqualFold(strings.TrimLeft(term, "+-~?"), "all")
+	}
+	for _, term := range terms {
+		t := a.analyzeTerm(ctx, domain, term, depth, hasAll)
+		node.Lookups += t.Lookups
+		node.VoidLookups += t.VoidLookups
+		if t.Target != nil {
+			node.Lookups += t.Target.Lookups
+			node.VoidLookups += t.Target.VoidLookups
+		}
+		node.Terms = append(node.Terms, t)
+	}
+
+	return node
+}
+
+// analyzeTerm counts the lookups of one term and resolves the addresses it authorizes.
+// A redirect is never evaluated when the record has an all mechanism.
+func (a *spfAnalyzer) analyzeTerm(ctx context.Context, domain, term string, depth int, hasAll bool) types.SPFTermAnalysis {
+	t := types.SPFTermAnalysis{Term: term}
+
+	if name, value, isModifier := splitSPFModifier(term); isModifier {
+		if name == "redirect" {
+			if hasAll {
+				t.Note = "ignored: the record has an all mechanism"
+				return t
+			}
+			t.Lookups = 1
+			if strings.Contains(value, "%") {
+				t.Note = "contains macros; not expanded"
+			} else {
+				t.Target = a.expand(ctx, strings.ToLower(value), depth+1)
+			}
+		}
+		return t
+	}
+
+	mechanism := strings.TrimLeft(term, "+-~?")
+	name, arg := mechanism, ""
+	if i := strings.IndexAny(mechanism, ":/"); i >= 0 {
+		name, arg = mechanism[:i], mechanism[i:]
+	}
+
+	switch strings.ToLower(name) {
+	case "ip4", "ip6":
+		if cidr, err := parseSPFNetwork(strings.ToLower(name), arg); err == nil {
+			t.Networks = []string{cidr.String()}
+		}
+
+	case "include":
+		t.Lookups = 1
+		spec := strings.ToLower(strings.TrimPrefix(arg, ":"))
+		if strings.Contains(spec, "%") {
+			t.Note = "contains macros; not expanded"
+		} else if spec != "" {
+			t.Target = a.expand(ctx, spec, depth+1)
+		}
+
+	case "a", "mx":
+		t.Lookups = 1
+		spec, cidr4, cidr6, err := parseSPFDomainCIDR(arg)
+		if err != nil {
+			return t
+		}
+		if strings.Contains(spec, "%") {
+			t.Note = "contains macros; not expanded"
+			return t
+		}
+		target := domain
+		if spec != "" {
+			target = strings.ToLower(spec)
+		}
+		hosts := []string{target}
+		if strings.EqualFold(name, "mx") {
+			mxs, err := a.opts.Resolver.LookupMX(ctx, target)
+			if err != nil {
+				if isNotFound(err) {
+					t.VoidLookups = 1
+					t.Note = "no MX records"
+				} else {
+					t.Note = fmt.Sprintf("MX lookup failed: %v", err)
+				}
+				return t
+			}
+			t.MXHosts = len(mxs)
+			if len(mxs) > spfMXNameLimit {
+				a.addFinding(types.SeverityCritical, "spf-mx-limit", domain,
+					fmt.Sprintf("%s resolves to %d MX names, more than the limit of %d; receivers return permerror", term, len(mxs), spfMXNameLimit))
+			}
+			hosts = hosts[:0]
+			for _, mx := range mxs {
+				hosts = append(hosts, strings.TrimSuffix(mx.Host, "."))
+			}
+		}
+		for _, host := range hosts {
+			networks, err := a.resolveNetworks(ctx, host, cidr4, cidr6)
+			if err != nil && !isNotFound(err) {
+				t.Note = fmt.Sprintf("lookup of %s failed: %v", host, err)
+			}
+			t.Networks = appendNetworks(t.Networks, networks...)
+		}
+		if len(t.Networks) == 0 && t.MXHosts == 0 && t.Note == "" {
+			t.VoidLookups = 1
+			t.Note = "no addresses"
+		}
+
+	case "ptr":
+		t.Lookups = 1
+		t.Note = "cannot be flattened"
+		a.addFinding(types.SeverityWarning, "spf-ptr", domain,
+			fmt.Sprintf("%s is slow and unreliable and should not be used (RFC 7208 section 5.5); at most %d PTR names are checked", term, spfLookupLimit))
+
+	case "exists":
+		t.Lookups = 1
+		t.Note = "cannot be flattened"
+		spec := strings.TrimPrefix(arg, ":")
+		if spec != "" && !strings.Contains(spec, "%") {
+			if _, err := a.opts.Resolver.LookupIP(ctx, "ip4", spec); isNotFound(err) {
+				t.VoidLookups = 1
+			}
+		}
+	}
+
+	return t
+}
+
+// resolveNetworks returns the A and AAAA addresses of host as networks of the given prefix lengths.
+func (a *spfAnalyzer) resolveNetworks(ctx context.Context, host string, cidr4, cidr6 int) ([]string, error) {
+	var networks []string
+	var firstErr error
+	for _, family := range []struct {
+		network string
+		bits    int
+		size    int
+	}{{"ip4", cidr4, 32}, {"ip6", cidr6, 128}} {
+		ips, err := a.opts.Resolver.LookupIP(ctx, family.network, host)
+		if err != nil {
+			if firstErr == nil || !isNotFound(err) {
+				firstErr = err
+			}
+			continue
+		}
+		mask := net.CIDRMask(family.bits, family.size)
+		for _, ip := range ips {
+			networks = appendNetworks(networks, (&net.IPNet{IP: ip.Mask(mask), Mask: mask}).String())
+		}
+	}
+	if len(networks) > 0 {
+		return networks, nil
+	}
+	return nil, firstErr
+}
+
+// flatten builds a candidate record that authorizes the addresses of the include, a and mx terms
+// directly. Terms that cannot be flattened (ptr, exists, macros, negative terms inside includes)
+// are kept, with the includes containing them.
+func (a *spfAnalyzer) flatten(root *types.SPFTreeNode) *types.SPFFlattening {
+	flattening := &types.SPFFlattening{}
+
+	var networks, kept, sources []string
+	firstNetwork := -1
+	var walk func(node *types.SPFTreeNode)
+	walk = func(node *types.SPFTreeNode) {
+		for _, t := range node.Terms {
+			term := strings.ToLower(t.Term)
+			name, _, isModifier := splitSPFModifier(term)
+			if isModifier {
+				if name == "redirect" && t.Target != nil && spfFlattenable(t.Target) && !spfHasAll(node) {
+					sources = append(sources, t.Target.Domain)
+					walk(t.Target)
+				} else if name == "redirect" && !spfHasAll(node) {
+					kept = append(kept, t.Term)
+					flattening.Lookups += t.Lookups + spfTargetLookups(t)
+				} else if name != "redirect" && node == root {
+					// exp= and unknown modifiers only apply to the record they appear in
+					kept = append(kept, t.Term)
+				}
+				continue
+			}
+
+			mechanism := strings.TrimPrefix(term, "+")
+			kind := mechanism
+			if i := strings.IndexAny(kind, ":/"); i >= 0 {
+				kind = kind[:i]
+			}
+			flattenable := false
+			switch kind {
+			case "ip4", "ip6":
+				flattenable = true
+			case "a", "mx":
+				flattenable = t.Note == "" || t.Note == "no addresses" || t.Note == "no MX records"
+			case "include":
+				flattenable = t.Target != nil && spfFlattenable(t.Target)
+			}
+			if !flattenable || strings.ContainsAny(mechanism[:1], "-~?") {
+				kept = append(kept, t.Term)
+				flattening.Lookups += t.Lookups + spfTargetLookups(t)
+				continue
+			}
+
+			if firstNetwork < 0 {
+				firstNetwork = len(kept)
+			}
+			switch kind {
+			case "include":
+				sources = append(sources, t.Target.Domain)
+				networks = appendNetworks(networks, spfTreeNetworks(t.Target)...)
+			case "a", "mx":
+				sources = append(sources, t.Term)
+				networks = appendNetworks(networks, t.Networks...)
+			default:
+				networks = appendNetworks(networks, t.Networks...)
+			}
+		}
+	}
+	walk(root)
+	if firstNetwork < 0 {
+		firstNetwork = len(kept)
+	}
+
+	tokens := make([]string, 0, len(networks))
+	for _, network := range networks {
+		tokens = append(tokens, spfNetworkTerm(network))
+	}
+	flattening.Networks = len(tokens)
+
+	// One record when everything fits, otherwise include: chunks in place of the addresses
+	record := spfJoinRecord(kept[:firstNetwork], tokens, kept[firstNetwork:])
+	if len(record) <= a.opts.MaxRecordLength {
+		flattening.Records = []types.SPFFlattenedRecord{{Name: root.Domain, Record: record, Length: len(record)}}
+	} else {
+		var chunks []types.SPFFlattenedRecord
+		current := "v=spf1"
+		for _, token := range tokens {
+			if len(current)+1+len(token) > a.opts.MaxRecordLength && current != "v=spf1" {
+				chunks = append(chunks, types.SPFFlattenedRecord{Record: current})
+				current = "v=spf1"
+			}
+			current += " " + token
+		}
+		if current != "v=spf1" {
+			chunks = append(chunks, types.SPFFlattenedRecord{Record: current})
+		}
+
+		includes := make([]string, 0, len(chunks))
+		for i := range chunks {
+			chunks[i].Name = fmt.Sprintf("%s%d.%s", a.opts.ChunkPrefix, i+1, root.Domain)
+			chunks[i].Length = len(chunks[i].Record)
+			includes = append(includes, "include:"+chunks[i].Name)
+		}
+		record = spfJoinRecord(kept[:firstNetwork], includes, kept[firstNetwork:])
+		flattening.Records = append([]types.SPFFlattenedRecord{{Name: root.Domain, Record: record, Length: len(record)}}, chunks...)
+		flattening.Lookups += len(chunks)
+	}
+
+	if len(sources) > 0 {
+		flattening.Warnings = append(flattening.Warnings, fmt.Sprintf(
+			"The flattened addresses are a snapshot of %s. Providers change their IP ranges without notice, so regenerate the record regularly or mail from new ranges will fail SPF.",
+			strings.Join(sources, ", ")))
+	}
+	for _, term := range kept {
+		if strings.HasPrefix(strings.ToLower(strings.TrimLeft(term, "+-~?")), "include:") {
+			flattening.Warnings = append(flattening.Warnings, fmt.Sprintf("%s was kept: it contains terms that cannot be flattened", term))
+		}
+	}
+	if flattening.Lookups > spfLookupLimit {
+		flattening.Warnings = append(flattening.Warnings, fmt.Sprintf("The flattened record still needs %d DNS lookups", flattening.Lookups))
+	}
+	if len(flattening.Records[0].Record) > a.opts.MaxRecordLength {
+		flattening.Warnings = append(flattening.Warnings, fmt.Sprintf("The flattened record for %s is still %d characters", root.Domain, flattening.Records[0].Length))
+	}
+
+	return flattening
+}
+
+// spfFlattenable reports whether every address a record authorizes is known: it has no errors, and
+// no ptr, exists, macro or negative terms, in itself or in the records it includes or redirects to.
+func spfFlattenable(node *types.SPFTreeNode) bool {
+	if node.Record == "" || node.Error != "" {
+		return false
+	}
+	for _, t := range node.Terms {
+		term := strings.ToLower(t.Term)
+		if _, _, isModifier := splitSPFModifier(term); !isModifier {
+			mechanism := strings.TrimLeft(term, "+-~?")
+			if mechanism != "all" && strings.ContainsAny(term[:1], "-~?") {
+				return false
+			}
+			if strings.HasPrefix(mechanism, "ptr") || strings.HasPrefix(mechanism, "exists:") {
+				return false
+			}
+		}
+		if strings.HasPrefix(t.Note, "contains macros") || strings.Contains(t.Note, "failed") {
+			return false
+		}
+		if t.Target != nil && !spfFlattenable(t.Target) {
+			return false
+		}
+	}
+	return true
+}
+
+// spfTreeNetworks returns the addresses authorized by a record and the records below it.
+func spfTreeNetworks(node *types.SPFTreeNode) []string {
+	var networks []string
+	for _, t := range node.Terms {
+		networks = appendNetworks(networks, t.Networks...)
+		if t.Target != nil {
+			networks = appendNetworks(networks, spfTreeNetworks(t.Target)...)
+		}
+	}
+	return networks
+}
+
+// spfHasAll reports whether a record has an all mechanism, which makes its redirect ineffective.
+func spfHasAll(node *types.SPFTreeNode) bool {
+	for _, t := range node.Terms {
+		if strings.EqualFold(strings.TrimLeft(t.Term, "+-~?"), "all") {
+			return true
+		}
+	}
+	return false
+}
+
+// spfTargetLookups returns the lookups below an include or redirect term.
+func spfTargetLookups(t types.SPFTermAnalysis) int {
+	if t.Target == nil {
+		return 0
+	}
+	return t.Target.Lookups
+}
+
+// spfNetworkTerm formats a network as an ip4 or ip6 term, without the prefix length of a single address.
+func spfNetworkTerm(network string) string {
+	_, cidr, err := net.ParseCIDR(network)
+	if err != nil {
+		return network
+	}
+	ones, bits := cidr.Mask.Size()
+	prefix := "ip6:"
+	if bits == 32 {
+		prefix = "ip4:"
+	}
+	if ones == bits {
+		return prefix + cidr.IP.String()
+	}
+	return prefix + cidr.String()
+}
+
+// spfJoinRecord joins groups of terms into a record.
+func spfJoinRecord(groups ...[]string) string {
+	terms := []string{"v=spf1"}
+	for _, group := range groups {
+		terms = append(terms, group...)
+	}
+	return strings.Join(terms, " ")
+}
+
+// appendNetworks appends the networks that are not in the list yet.
+func appendNetworks(list []string, networks ...string) []string {
+	for _, network := range networks {
+		found := false
+		for _, existing := range list {
+			if existing == network {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, network)
+		}
+	}
+	return list
+}
+
+// addFinding records a finding of the lookup analysis.
+func (a *spfAnalyzer) addFinding(severity, code, host, message string) {
+	a.analysis.Findings = append(a.analysis.Findings, types.Finding{
+		Severity: severity,
+		Code:     code,
+		Host:     host,
+		Message:  message,
+	})
+}
@@ -0,0 +1,177 @@
+// Package emailauth provides functionality for checking email authentication mechanisms.
+package emailauth
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"mxclone/pkg/types"
+)
+
+func spfFindingCodes(analysis *types.SPFLookupAnalysis) map[string]string {
+	codes := make(map[string]string)
+	for _, finding := range analysis.Findings {
+		codes[finding.Code] = finding.Severity
+	}
+	return codes
+}
+
+// TestAnalyzeSPFLookups tests lookup counting, limits and findings of the include/redirect tree
+func TestAnalyzeSPFLookups(t *testing.T) {
+	resolver := &fakeResolver{
+		txt: map[string][]string{
+			"example.com":         {"v=spf1 ip4:192.0.2.0/24 include:_spf.provider.net mx a:web.example.com/30 redirect=other.example.com -all"},
+			"_spf.provider.net":   {"v=spf1 include:_net1.provider.net include:_net2.provider.net ~all"},
+			"_net1.provider.net":  {"v=spf1 ip4:198.51.100.0/24 ~all"},
+			"_net2.provider.net":  {"v=spf1 ip6:2001:db8:1::/48 ~all"},
+			"other.example.com":   {"v=spf1 a -all"},
+			"many.example.com":    {"v=spf1 include:a.example.org include:a.example.org include:a.example.org include:a.example.org include:a.example.org include:a.example.org -all"},
+			"a.example.org":       {"v=spf1 a mx ~all"},
+			"void.example.com":    {"v=spf1 a:gone1.example.com a:gone2.example.com mx:gone3.example.com -all"},
+			"loop.example.com":    {"v=spf1 include:loop2.example.com -all"},
+			"loop2.example.com":   {"v=spf1 include:loop.example.com -all"},
+			"ptr.example.com":     {"v=spf1 ptr mx -all"},
+			"missing.example.com": {"v=spf1 include:nothing.example.com -all"},
+		},
+		ip: map[string][]string{
+			"mx1.example.com": {"192.0.2.25", "2001:db8::25"},
+			"web.example.com": {"203.0.113.10"},
+			"a.example.org":   {"203.0.113.20"},
+			"mx.example.org":  {"203.0.113.21"},
+		},
+		mx: map[string][]string{
+			"example.com":     {"mx1.example.com"},
+			"a.example.org":   {"mx.example.org"},
+			"ptr.example.com": {"mx1.example.com", "mx2.example.com", "mx3.example.com", "mx4.example.com", "mx5.example.com", "mx6.example.com", "mx7.example.com", "mx8.example.com", "mx9.example.com", "mx10.example.com", "mx11.example.com"},
+		},
+	}
+
+	tests := []struct {
+		domain  string
+		lookups int
+		voids   int
+		want    map[string]string
+	}{
+		{"example.com", 5, 0, nil},
+		{"many.example.com", 18, 0, map[string]string{"spf-lookup-limit": types.SeverityCritical}},
+		{"void.example.com", 3, 3, map[string]string{"spf-void-lookup-limit": types.SeverityCritical}},
+		{"loop.example.com", 2, 0, map[string]string{"spf-include-loop": types.SeverityCritical}},
+		{"ptr.example.com", 2, 0, map[string]string{"spf-ptr": types.SeverityWarning, "spf-mx-limit": types.SeverityCritical}},
+		{"missing.example.com", 1, 0, map[string]string{"spf-target-missing": types.SeverityCritical}},
+		{"none.example.com", 0, 0, map[string]string{"spf-missing": types.SeverityCritical}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.domain, func(t *testing.T) {
+			analysis := AnalyzeSPFLookups(context.Background(), tt.domain, SPFAnalysisOptions{Resolver: resolver})
+			if analysis.Lookups != tt.lookups || analysis.VoidLookups != tt.voids {
+				t.Errorf("Lookups = %d, VoidLookups = %d, want %d, %d", analysis.Lookups, analysis.VoidLookups, tt.lookups, tt.voids)
+			}
+			codes := spfFindingCodes(analysis)
+			for code, severity := range tt.want {
+				if codes[code] != severity {
+					t.Errorf("finding %s = %q, want %q (findings: %+v)", code, codes[code], severity, analysis.Findings)
+				}
+			}
+			if tt.want == nil && len(analysis.Findings) > 0 {
+				t.Errorf("unexpected findings %+v", analysis.Findings)
+			}
+		})
+	}
+
+	// The tree keeps the include structure
+	analysis := AnalyzeSPFLookups(context.Background(), "example.com", SPFAnalysisOptions{Resolver: resolver})
+	include := analysis.Tree.Terms[1]
+	if include.Target == nil || include.Target.Domain != "_spf.provider.net" || include.Target.Lookups != 2 || len(include.Target.Terms[0].Target.Terms) != 2 {
+		t.Errorf("include term = %+v, want the _spf.provider.net subtree", include)
+	}
+	if mx := analysis.Tree.Terms[2]; mx.MXHosts != 1 || strings.Join(mx.Networks, ",") != "192.0.2.25/32,2001:db8::25/128" {
+		t.Errorf("mx term = %+v", mx)
+	}
+	if a := analysis.Tree.Terms[3]; strings.Join(a.Networks, ",") != "203.0.113.8/30" {
+		t.Errorf("a term networks = %v, want 203.0.113.8/30", a.Networks)
+	}
+	if redirect := analysis.Tree.Terms[4]; redirect.Target != nil || redirect.Lookups != 0 {
+		t.Errorf("redirect term = %+v, want it ignored next to -all", redirect)
+	}
+}
+
+// TestAnalyzeSPFLookupsRecordStrings tests the length of multi-string records
+func TestAnalyzeSPFLookupsRecordStrings(t *testing.T) {
+	long := "v=spf1 " + strings.Repeat("ip4:192.0.2.1 ", 40) + "-all"
+	analysis := AnalyzeSPFLookups(context.Background(), "example.com", SPFAnalysisOptions{
+		Resolver: &fakeResolver{},
+		LookupTXT: func(ctx context.Context, name string) ([][]string, error) {
+			return [][]string{{"unrelated"}, {long[:255], long[255:]}}, nil
+		},
+	})
+	if analysis.RecordLength != len(long) || len(analysis.Tree.StringLengths) != 2 || analysis.Tree.StringLengths[0] != 255 {
+		t.Errorf("RecordLength = %d, StringLengths = %v, want %d split at 255", analysis.RecordLength, analysis.Tree.StringLengths, len(long))
+	}
+	codes := spfFindingCodes(analysis)
+	if codes["spf-record-length"] != types.SeverityWarning || codes["spf-multi-string"] != types.SeverityInfo {
+		t.Errorf("findings %+v, want spf-record-length and spf-multi-string", analysis.Findings)
+	}
+}
+
+// TestFlattenSPF tests the flattened candidate record and its split into include: chunks
+func TestFlattenSPF(t *testing.T) {
+	txt := map[string][]string{
+		"example.com":        {"v=spf1 ip4:192.0.2.0/24 include:_spf.provider.net a -all"},
+		"_spf.provider.net":  {"v=spf1 ip4:198.51.100.0/24 ip4:192.0.2.0/24 ip6:2001:db8::/32 ~all"},
+		"kept.example.com":   {"v=spf1 include:_spf.provider.net include:macro.provider.net ~all"},
+		"macro.provider.net": {"v=spf1 exists:%{i}._spf.provider.net ~all"},
+	}
+	big := make([]string, 0, 100)
+	for i := 0; i < 100; i++ {
+		big = append(big, fmt.Sprintf("ip4:10.0.%d.0/24", i))
+	}
+	txt["_big.provider.net"] = []string{"v=spf1 " + strings.Join(big, " ") + " ~all"}
+	txt["big.example.com"] = []string{"v=spf1 include:_big.provider.net mx -all"}
+
+	resolver := &fakeResolver{
+		txt: txt,
+		ip:  map[string][]string{"example.com": {"203.0.113.1"}, "mx.example.com": {"203.0.113.2"}},
+		mx:  map[string][]string{"big.example.com": {"mx.example.com"}},
+	}
+
+	analysis := AnalyzeSPFLookups(context.Background(), "example.com", SPFAnalysisOptions{Resolver: resolver})
+	flattened := analysis.Flattened
+	want := "v=spf1 ip4:192.0.2.0/24 ip4:198.51.100.0/24 ip6:2001:db8::/32 ip4:203.0.113.1 -all"
+	if flattened == nil || len(flattened.Records) != 1 || flattened.Records[0].Record != want || flattened.Lookups != 0 {
+		t.Fatalf("Flattened = %+v, want %q", flattened, want)
+	}
+	if len(flattened.Warnings) == 0 || !strings.Contains(flattened.Warnings[0], "_spf.provider.net") {
+		t.Errorf("Warnings = %q, want an IP churn warning naming the provider", flattened.Warnings)
+	}
+
+	// Includes with terms that cannot be flattened are kept
+	analysis = AnalyzeSPFLookups(context.Background(), "kept.example.com", SPFAnalysisOptions{Resolver: resolver})
+	want = "v=spf1 ip4:198.51.100.0/24 ip4:192.0.2.0/24 ip6:2001:db8::/32 include:macro.provider.net ~all"
+	if got := analysis.Flattened.Records[0].Record; got != want || analysis.Flattened.Lookups != 2 {
+		t.Errorf("Flattened = %q (%d lookups), want %q (2 lookups)", got, analysis.Flattened.Lookups, want)
+	}
+
+	// Long records are split into include: chunks
+	analysis = AnalyzeSPFLookups(context.Background(), "big.example.com", SPFAnalysisOptions{Resolver: resolver})
+	flattened = analysis.Flattened
+	if len(flattened.Records) < 3 {
+		t.Fatalf("Records = %+v, want the record and at least two chunks", flattened.Records)
+	}
+	if !strings.HasPrefix(flattened.Records[0].Record, "v=spf1 include:_spf1.big.example.com include:_spf2.big.example.com") ||
+		!strings.HasSuffix(flattened.Records[0].Record, " -all") {
+		t.Errorf("Record = %q, want include: chunks and -all", flattened.Records[0].Record)
+	}
+	networks := 0
+	for _, record := range flattened.Records[1:] {
+		if record.Length > spfRecordLengthLimit || record.Name == "" {
+			t.Errorf("chunk %+v exceeds %d characters or has no name", record, spfRecordLengthLimit)
+		}
+		networks += strings.Count(record.Record, "ip4:")
+	}
+	if networks != 101 || flattened.Networks != 101 || flattened.Lookups != len(flattened.Records)-1 {
+		t.Errorf("networks = %d, Networks = %d, Lookups = %d", networks, flattened.Networks, flattened.Lookups)
+	}
+}
@@ -0,0 +1,710 @@
+// Package emailauth provides functionality for checking email authentication mechanisms.
+package emailauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"mxclone/pkg/types"
+)
+
+// SPF results (RFC 7208 section 2.6)
+const (
+	SPFPass      = "pass"
+	SPFFail      = "fail"
+	SPFSoftFail  = "softfail"
+	SPFNeutral   = "neutral"
+	SPFNone      = "none"
+	SPFPermError = "permerror"
+	SPFTempError = "temperror"
+)
+
+// Trace step results other than SPF results
+const (
+	spfTraceRecord  = "record"
+	spfTraceMatch   = "match"
+	spfTraceNoMatch = "no-match"
+)
+
+// Limits on DNS-querying terms and on lookups returning no answer (RFC 7208 section 4.6.4)
+const (
+	spfLookupLimit     = 10
+	spfVoidLookupLimit = 2
+)
+
+// Resolver is the DNS interface used to evaluate SPF and DMARC; *net.Resolver implements it.
+type Resolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+	LookupIP(ctx context.Context, network, host string) ([]net.IP, error)
+	LookupMX(ctx context.Context, name string) ([]*net.MX, error)
+	LookupAddr(ctx context.Context, addr string) ([]string, error)
+}
+
+// SPFCheckOptions are the arguments of check_host (RFC 7208 section 4).
+type SPFCheckOptions struct {
+	// Resolver is used for every lookup (default net.DefaultResolver)
+	Resolver Resolver
+	// IP is the address of the SMTP client
+	IP net.IP
+	// Domain whose record is evaluated; defaults to the domain of Sender, then to Helo
+	Domain string
+	// Sender is the MAIL FROM address; postmaster@Helo is used for the null reverse-path
+	Sender string
+	// Helo is the name given in EHLO or HELO, used by the %{h} macro
+	Helo string
+	// Receiver is the name of the receiving MTA, used by the %{r} macro in explanations
+	Receiver string
+	// Now is used by the %{t} macro (default time.Now)
+	Now func() time.Time
+}
+
+// spfError ends an evaluation with a permerror or temperror result.
+type spfError struct {
+	result string
+	reason string
+}
+
+func (e *spfError) Error() string { return e.reason }
+
+// spfEvaluation holds the state shared by the recursive evaluation of one message.
+type spfEvaluation struct {
+	resolver    Resolver
+	ip          net.IP
+	sender      string
+	helo        string
+	receiver    string
+	now         func() time.Time
+	lookups     int
+	voids       int
+	explanation string
+	trace       []types.SPFTraceStep
+}
+
+// EvaluateSPF evaluates the SPF record of domain for a message sent from ip by sender.
+func EvaluateSPF(ctx context.Context, resolver Resolver, ip net.IP, domain, sender string) *types.SPFEvaluation {
+	return CheckHost(ctx, SPFCheckOptions{
+		Resolver: resolver,
+		IP:       ip,
+		Domain:   domain,
+		Sender:   sender,
+	})
+}
+
+// CheckHost implements check_host() of RFC 7208: it evaluates all mechanisms (all, include, a, mx, ptr,
+// ip4, ip6, exists) with their CIDR lengths, the redirect and exp modifiers and macro expansion,
+// enforces the DNS lookup limits, and records every record and term it evaluated in the trace.
+func CheckHost(ctx context.Context, opts SPFCheckOptions) *types.SPFEvaluation {
+	if opts.Resolver == nil {
+		opts.Resolver = net.DefaultResolver
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	if opts.Receiver == "" {
+		opts.Receiver = "unknown"
+	}
+
+	// A sender without a local-part is treated as postmaster (RFC 7208 section 4.3)
+	sender := opts.Sender
+	if sender == "" {
+		sender = "postmaster@" + opts.Helo
+	} else if !strings.Contains(sender, "@") {
+		sender = "postmaster@" + sender
+	} else if strings.HasPrefix(sender, "@") {
+		sender = "postmaster" + sender
+	}
+
+	domain := opts.Domain
+	if domain == "" {
+		domain = sender[strings.LastIndexByte(sender, '@')+1:]
+	}
+	domain = strings.TrimSuffix(strings.ToLower(domain), ".")
+
+	evaluation := &types.SPFEvaluation{
+		Domain: domain,
+		IP:     opts.IP.String(),
+		Sender: opts.Sender,
+		Helo:   opts.Helo,
+	}
+
+	e := &spfEvaluation{
+		resolver: opts.Resolver,
+		ip:       opts.IP,
+		sender:   sender,
+		helo:     opts.Helo,
+		receiver: opts.Receiver,
+		now:      opts.Now,
+	}
+	result, mechanism, record, err := e.checkHost(ctx, domain, 0, true)
+	evaluation.Result = result
+	evaluation.Mechanism = mechanism
+	evaluation.Record = record
+	evaluation.Lookups = e.lookups
+	evaluation.VoidLookups = e.voids
+	evaluation.Trace = e.trace
+	if result == SPFFail && e.explanation != "" {
+		evaluation.Explanation = e.explanation
+	} else if err != nil {
+		evaluation.Explanation = err.Error()
+	}
+
+	return evaluation
+}
+
+// checkHost evaluates the record of one domain; depth counts include and redirect nesting.
+// explain is false inside include, whose exp= modifiers are not used.
+func (e *spfEvaluation) checkHost(ctx context.Context, domain string, depth int, explain bool) (string, string, string, error) {
+	if depth > spfLookupLimit {
+		return SPFPermError, "", "", &spfError{SPFPermError, "too many nested include or redirect terms"}
+	}
+	if !isSPFDomainName(domain) {
+		return SPFNone, "", "", fmt.Errorf("%q is not a fully qualified domain name", domain)
+	}
+
+	record, err := e.lookupRecord(ctx, domain)
+	if err != nil {
+		var spfErr *spfError
+		result := SPFNone
+		if errors.As(err, &spfErr) {
+			result = spfErr.result
+		}
+		e.addTrace(depth, domain, "", "", result, err.Error())
+		return result, "", "", err
+	}
+	e.addTrace(depth, domain, "", "", spfTraceRecord, record)
+
+	// A syntax error anywhere in the record is a permerror before any term is evaluated
+	if _, err := CheckSPFSyntax(record); err != nil {
+		err = &spfError{SPFPermError, err.Error()}
+		e.addTrace(depth, domain, "", "", SPFPermError, err.Error())
+		return SPFPermError, "", record, err
+	}
+
+	terms := strings.Fields(record)[1:]
+	var redirect, exp string
+	for _, term := range terms {
+		name, value, isModifier := splitSPFModifier(term)
+		if !isModifier {
+			continue
+		}
+		switch name {
+		case "redirect":
+			redirect = value
+		case "exp":
+			exp = value
+		}
+		// Unknown modifiers do not affect the result
+	}
+
+	for _, term := range terms {
+		if _, _, isModifier := splitSPFModifier(term); isModifier {
+			continue
+		}
+
+		qualifier := SPFPass
+		mechanism := term
+		switch term[0] {
+		case '+':
+			mechanism = term[1:]
+		case '-':
+			qualifier, mechanism = SPFFail, term[1:]
+		case '~':
+			qualifier, mechanism = SPFSoftFail, term[1:]
+		case '?':
+			qualifier, mechanism = SPFNeutral, term[1:]
+		}
+
+		step := e.addTrace(depth, domain, term, "", "", "")
+		matched, target, err := e.matchMechanism(ctx, domain, mechanism, depth)
+		e.trace[step].Target = target
+		if err != nil {
+			result := SPFTempError
+			var spfErr *spfError
+			if errors.As(err, &spfErr) {
+				result = spfErr.result
+			}
+			e.trace[step].Result = result
+			e.trace[step].Detail = err.Error()
+			return result, mechanism, record, err
+		}
+		if matched {
+			e.trace[step].Result = spfTraceMatch
+			e.trace[step].Detail = "result " + qualifier
+			if qualifier == SPFFail && explain && exp != "" {
+				e.explanation = e.explain(ctx, domain, exp)
+			}
+			return qualifier, mechanism, record, nil
+		}
+		e.trace[step].Result = spfTraceNoMatch
+	}
+
+	if redirect != "" {
+		term := "redirect=" + redirect
+		step := e.addTrace(depth, domain, term, "", "", "")
+		target, err := e.expandDomainSpec(ctx, redirect, domain)
+		if err == nil {
+			e.trace[step].Target = target
+			err = e.countLookup()
+		}
+		if err != nil {
+			e.trace[step].Result = SPFPermError
+			e.trace[step].Detail = err.Error()
+			return SPFPermError, term, record, err
+		}
+		result, mechanism, _, err := e.checkHost(ctx, target, depth+1, explain)
+		if result == SPFNone {
+			err = &spfError{SPFPermError, "redirect target " + target + " has no SPF record"}
+			result = SPFPermError
+		}
+		e.trace[step].Result = result
+		if err != nil {
+			e.trace[step].Detail = err.Error()
+		}
+		return result, mechanism, record, err
+	}
+
+	e.addTrace(depth, domain, "", "", SPFNeutral, "no mechanism matched")
+	return SPFNeutral, "", record, nil
+}
+
+// CheckSPFSyntax checks the syntax of every term of an SPF record (RFC 7208 section 12) and returns
+// the mechanisms and modifiers it contains.
+func CheckSPFSyntax(record string) ([]string, error) {
+	fields := strings.Fields(record)
+	if len(fields) == 0 || !strings.EqualFold(fields[0], "v=spf1") {
+		return nil, errors.New("invalid SPF record: does not start with v=spf1")
+	}
+
+	terms := fields[1:]
+	seen := make(map[string]bool)
+	for _, term := range terms {
+		if name, value, isModifier := splitSPFModifier(term); isModifier {
+			if !isSPFModifierName(name) {
+				return nil, fmt.Errorf("invalid modifier name: %s", term)
+			}
+			if name == "redirect" || name == "exp" {
+				if seen[name] {
+					return nil, fmt.Errorf("%s modifier appears more than once", name)
+				}
+				seen[name] = true
+				if value == "" {
+					return nil, fmt.Errorf("missing domain: %s", term)
+				}
+			}
+			if err := checkMacroString(value, false); err != nil {
+				return nil, fmt.Errorf("%s: %v", term, err)
+			}
+			continue
+		}
+
+		mechanism := strings.TrimLeft(term, "+-~?")
+		if len(term)-len(mechanism) > 1 {
+			return nil, fmt.Errorf("invalid qualifier: %s", term)
+		}
+		name, arg := mechanism, ""
+		if i := strings.IndexAny(mechanism, ":/"); i >= 0 {
+			name, arg = mechanism[:i], mechanism[i:]
+		}
+
+		var spec string
+		switch strings.ToLower(name) {
+		case "all":
+			if arg != "" {
+				return nil, fmt.Errorf("invalid mechanism: %s", term)
+			}
+		case "ip4", "ip6":
+			if _, err := parseSPFNetwork(strings.ToLower(name), arg); err != nil {
+				return nil, fmt.Errorf("%s: %v", term, err)
+			}
+		case "include", "exists":
+			spec = strings.TrimPrefix(arg, ":")
+			if spec == "" || spec == arg {
+				return nil, fmt.Errorf("missing domain: %s", term)
+			}
+		case "a", "mx":
+			var err error
+			if spec, _, _, err = parseSPFDomainCIDR(arg); err != nil {
+				return nil, fmt.Errorf("%s: %v", term, err)
+			}
+		case "ptr":
+			spec = strings.TrimPrefix(arg, ":")
+			if arg != "" && (spec == arg || spec == "") {
+				return nil, fmt.Errorf("invalid mechanism: %s", term)
+			}
+		default:
+			return nil, fmt.Errorf("unknown mechanism: %s", term)
+		}
+		if err := checkMacroString(spec, false); err != nil {
+			return nil, fmt.Errorf("%s: %v", term, err)
+		}
+	}
+
+	return terms, nil
+}
+
+// isSPFModifierName reports whether name is ALPHA *( ALPHA / DIGIT / "-" / "_" / "." ).
+func isSPFModifierName(name string) bool {
+	for i, c := range name {
+		switch {
+		case 'a' <= c && c <= 'z':
+		case i > 0 && (('0' <= c && c <= '9') || c == '-' || c == '_' || c == '.'):
+		default:
+			return false
+		}
+	}
+	return name != ""
+}
+
+// addTrace appends a step to the evaluation trace and returns its index.
+func (e *spfEvaluation) addTrace(depth int, domain, term, target, result, detail string) int {
+	e.trace = append(e.trace, types.SPFTraceStep{
+		Depth:  depth,
+		Domain: domain,
+		Term:   term,
+		Target: target,
+		Result: result,
+		Detail: detail,
+	})
+	return len(e.trace) - 1
+}
+
+// explain fetches and expands the explanation string named by an exp= modifier (RFC 7208 section 6.2).
+// Any problem results in no explanation rather than an error.
+func (e *spfEvaluation) explain(ctx context.Context, domain, exp string) string {
+	target, err := e.expandDomainSpec(ctx, exp, domain)
+	if err != nil {
+		return ""
+	}
+	txts, err := e.resolver.LookupTXT(ctx, target)
+	if err != nil || len(txts) != 1 {
+		return ""
+	}
+	explanation, err := e.expandMacros(ctx, txts[0], domain, true)
+	if err != nil {
+		return ""
+	}
+	return explanation
+}
+
+// lookupRecord returns the single SPF record of a domain.
+func (e *spfEvaluation) lookupRecord(ctx context.Context, domain string) (string, error) {
+	txts, err := e.resolver.LookupTXT(ctx, domain)
+	if err != nil {
+		if isNotFound(err) {
+			return "", fmt.Errorf("no SPF record found for domain: %s", domain)
+		}
+		return "", &spfError{SPFTempError, fmt.Sprintf("TXT lookup for %s failed: %v", domain, err)}
+	}
+
+	var records []string
+	for _, txt := range txts {
+		lower := strings.ToLower(txt)
+		if lower == "v=spf1" || strings.HasPrefix(lower, "v=spf1 ") {
+			records = append(records, txt)
+		}
+	}
+	switch len(records) {
+	case 0:
+		return "", fmt.Errorf("no SPF record found for domain: %s", domain)
+	case 1:
+		return records[0], nil
+	default:
+		return "", &spfError{SPFPermError, fmt.Sprintf("%s publishes %d SPF records", domain, len(records))}
+	}
+}
+
+// matchMechanism reports whether a mechanism (without its qualifier) matches the connecting IP.
+// It also returns the domain the mechanism was evaluated against, after macro expansion.
+func (e *spfEvaluation) matchMechanism(ctx context.Context, domain, mechanism string, depth int) (bool, string, error) {
+	name, arg := mechanism, ""
+	if i := strings.IndexAny(mechanism, ":/"); i >= 0 {
+		name, arg = mechanism[:i], mechanism[i:]
+	}
+	name = strings.ToLower(name)
+
+	switch name {
+	case "all":
+		if arg != "" {
+			return false, "", &spfError{SPFPermError, "invalid mechanism: " + mechanism}
+		}
+		return true, "", nil
+
+	case "ip4", "ip6":
+		cidr, err := parseSPFNetwork(name, arg)
+		if err != nil {
+			return false, "", &spfError{SPFPermError, fmt.Sprintf("%s: %v", mechanism, err)}
+		}
+		return cidr.Contains(e.ip), "", nil
+
+	case "include":
+		spec := strings.TrimPrefix(arg, ":")
+		if spec == "" || spec == arg {
+			return false, "", &spfError{SPFPermError, "missing domain: " + mechanism}
+		}
+		target, err := e.expandDomainSpec(ctx, spec, domain)
+		if err != nil {
+			return false, "", err
+		}
+		if err := e.countLookup(); err != nil {
+			return false, target, err
+		}
+		result, _, _, err := e.checkHost(ctx, target, depth+1, false)
+		switch result {
+		case SPFPass:
+			return true, target, nil
+		case SPFFail, SPFSoftFail, SPFNeutral:
+			return false, target, nil
+		case SPFTempError:
+			return false, target, err
+		default:
+			return false, target, &spfError{SPFPermError, fmt.Sprintf("include:%s: %v", target, err)}
+		}
+
+	case "a", "mx":
+		spec, cidr4, cidr6, err := parseSPFDomainCIDR(arg)
+		if err != nil {
+			return false, "", &spfError{SPFPermError, fmt.Sprintf("%s: %v", mechanism, err)}
+		}
+		target, err := e.expandDomainSpec(ctx, spec, domain)
+		if err != nil {
+			return false, "", err
+		}
+		if err := e.countLookup(); err != nil {
+			return false, target, err
+		}
+		hosts := []string{target}
+		if name == "mx" {
+			mxs, err := e.resolver.LookupMX(ctx, target)
+			if err != nil {
+				return false, target, e.voidOrTemp(err, target)
+			}
+			if len(mxs) > spfLookupLimit {
+				return false, target, &spfError{SPFPermError, fmt.Sprintf("%s has more than %d MX records", target, spfLookupLimit)}
+			}
+			hosts = hosts[:0]
+			for _, mx := range mxs {
+				hosts = append(hosts, strings.TrimSuffix(mx.Host, "."))
+			}
+		}
+		for _, host := range hosts {
+			matched, err := e.matchHostAddresses(ctx, host, cidr4, cidr6)
+			if err != nil || matched {
+				return matched, target, err
+			}
+		}
+		return false, target, nil
+
+	case "ptr":
+		spec := ""
+		if strings.HasPrefix(arg, ":") {
+			spec = arg[1:]
+		} else if arg != "" {
+			return false, "", &spfError{SPFPermError, "invalid mechanism: " + mechanism}
+		}
+		target, err := e.expandDomainSpec(ctx, spec, domain)
+		if err != nil {
+			return false, "", err
+		}
+		if err := e.countLookup(); err != nil {
+			return false, target, err
+		}
+		return len(e.validatedNames(ctx, target)) > 0, target, nil
+
+	case "exists":
+		spec := strings.TrimPrefix(arg, ":")
+		if spec == "" || spec == arg {
+			return false, "", &spfError{SPFPermError, "missing domain: " + mechanism}
+		}
+		target, err := e.expandDomainSpec(ctx, spec, domain)
+		if err != nil {
+			return false, "", err
+		}
+		if err := e.countLookup(); err != nil {
+			return false, target, err
+		}
+		ips, err := e.resolver.LookupIP(ctx, "ip4", target)
+		if err != nil {
+			return false, target, e.voidOrTemp(err, target)
+		}
+		return len(ips) > 0, target, nil
+	}
+
+	return false, "", &spfError{SPFPermError, "unknown mechanism: " + mechanism}
+}
+
+// validatedNames returns the PTR names of the connecting IP, within target when given,
+// whose addresses include the IP (RFC 7208 section 5.5).
+func (e *spfEvaluation) validatedNames(ctx context.Context, target string) []string {
+	names, err := e.resolver.LookupAddr(ctx, e.ip.String())
+	if err != nil {
+		return nil
+	}
+	var validated []string
+	for i, name := range names {
+		if i >= spfLookupLimit {
+			break
+		}
+		name = strings.TrimSuffix(strings.ToLower(name), ".")
+		if target != "" && name != target && !strings.HasSuffix(name, "."+target) {
+			continue
+		}
+		if matched, _ := e.matchHostAddresses(ctx, name, 32, 128); matched {
+			validated = append(validated, name)
+		}
+	}
+	return validated
+}
+
+// matchHostAddresses reports whether an address of host, within the CIDR lengths, contains the connecting IP.
+func (e *spfEvaluation) matchHostAddresses(ctx context.Context, host string, cidr4, cidr6 int) (bool, error) {
+	network, bits, size := "ip6", cidr6, 128
+	if e.ip.To4() != nil {
+		network, bits, size = "ip4", cidr4, 32
+	}
+
+	ips, err := e.resolver.LookupIP(ctx, network, host)
+	if err != nil {
+		return false, e.voidOrTemp(err, host)
+	}
+	mask := net.CIDRMask(bits, size)
+	for _, ip := range ips {
+		if ip.Mask(mask).Equal(e.ip.Mask(mask)) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// countLookup counts a DNS-querying term against the lookup limit.
+func (e *spfEvaluation) countLookup() error {
+	e.lookups++
+	if e.lookups > spfLookupLimit {
+		return &spfError{SPFPermError, fmt.Sprintf("more than %d DNS lookups", spfLookupLimit)}
+	}
+	return nil
+}
+
+// voidOrTemp counts a lookup with no answer against the void lookup limit, or turns a failure into a temperror.
+func (e *spfEvaluation) voidOrTemp(err error, name string) error {
+	if !isNotFound(err) {
+		return &spfError{SPFTempError, fmt.Sprintf("lookup of %s failed: %v", name, err)}
+	}
+	e.voids++
+	if e.voids > spfVoidLookupLimit {
+		return &spfError{SPFPermError, fmt.Sprintf("more than %d lookups returned no answer", spfVoidLookupLimit)}
+	}
+	return nil
+}
+
+// splitSPFModifier splits a name=value modifier; mechanisms are reported as not modifiers.
+func splitSPFModifier(term string) (string, string, bool) {
+	i := strings.IndexByte(term, '=')
+	if i <= 0 || strings.ContainsAny(term[:i], ":/") {
+		return "", "", false
+	}
+	return strings.ToLower(term[:i]), term[i+1:], true
+}
+
+// parseSPFNetwork parses the ":address/length" argument of ip4 and ip6.
+func parseSPFNetwork(name, arg string) (*net.IPNet, error) {
+	if !strings.HasPrefix(arg, ":") {
+		return nil, errors.New("missing address")
+	}
+	network := arg[1:]
+	if !strings.Contains(network, "/") {
+		if name == "ip4" {
+			network += "/32"
+		} else {
+			network += "/128"
+		}
+	}
+	_, cidr, err := net.ParseCIDR(network)
+	if err != nil || (name == "ip4") != (cidr.IP.To4() != nil) {
+		return nil, fmt.Errorf("invalid network %q", arg[1:])
+	}
+	return cidr, nil
+}
+
+// parseSPFDomainCIDR parses the optional ":domain-spec" and "/cidr4//cidr6" arguments of a and mx.
+// The domain-spec is returned unexpanded; it is empty when the current domain applies.
+func parseSPFDomainCIDR(arg string) (string, int, int, error) {
+	spec, cidr4, cidr6 := "", 32, 128
+
+	rest := arg
+	if strings.HasPrefix(rest, ":") {
+		rest = rest[1:]
+		end := indexOutsideMacros(rest, '/')
+		if end < 0 {
+			end = len(rest)
+		}
+		spec = rest[:end]
+		rest = rest[end:]
+		if spec == "" {
+			return "", 0, 0, errors.New("empty domain")
+		}
+	}
+
+	if rest != "" {
+		v4, v6, dual := strings.Cut(rest, "//")
+		if dual {
+			v6 = "/" + v6
+		}
+		if v4 != "" {
+			n, err := strconv.Atoi(strings.TrimPrefix(v4, "/"))
+			if err != nil || n < 0 || n > 32 || !strings.HasPrefix(v4, "/") {
+				return "", 0, 0, fmt.Errorf("invalid IPv4 prefix length %q", v4)
+			}
+			cidr4 = n
+		}
+		if v6 != "" {
+			n, err := strconv.Atoi(strings.TrimPrefix(v6, "/"))
+			if err != nil || n < 0 || n > 128 {
+				return "", 0, 0, fmt.Errorf("invalid IPv6 prefix length %q", v6)
+			}
+			cidr6 = n
+		}
+	}
+
+	return spec, cidr4, cidr6, nil
+}
+
+// indexOutsideMacros returns the index of the first c in s that is not inside a %{...} macro, or -1.
+func indexOutsideMacros(s string, c byte) int {
+	inMacro := false
+	for i := 0; i < len(s); i++ {
+		switch {
+		case inMacro:
+			inMacro = s[i] != '}'
+		case s[i] == '%' && i+1 < len(s) && s[i+1] == '{':
+			inMacro = true
+			i++
+		case s[i] == c:
+			return i
+		}
+	}
+	return -1
+}
+
+// isSPFDomainName reports whether a name can be evaluated: a multi-label domain without empty labels.
+func isSPFDomainName(domain string) bool {
+	if domain == "" || !strings.Contains(domain, ".") || len(domain) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(domain, ".") {
+		if label == "" || len(label) > 63 {
+			return false
+		}
+	}
+	return true
+}
+
+// isNotFound reports whether a lookup failed because the name or the records do not exist.
+func isNotFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
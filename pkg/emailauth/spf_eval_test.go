@@ -0,0 +1,300 @@
+// Package emailauth provides functionality for checking email authentication mechanisms.
+package emailauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeResolver answers lookups from static tables; missing names are NXDOMAIN.
+type fakeResolver struct {
+	txt  map[string][]string
+	ip   map[string][]string
+	mx   map[string][]string
+	ptr  map[string][]string
+	fail map[string]bool // names whose lookups fail with a server error
+}
+
+func (r *fakeResolver) err(name string) error {
+	if r.fail[name] {
+		return &net.DNSError{Err: "server misbehaving", Name: name, IsTemporary: true}
+	}
+	return &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
+
+func (r *fakeResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	if txts, ok := r.txt[name]; ok && !r.fail[name] {
+		return txts, nil
+	}
+	return nil, r.err(name)
+}
+
+func (r *fakeResolver) LookupIP(ctx context.Context, network, host string) ([]net.IP, error) {
+	var ips []net.IP
+	for _, s := range r.ip[host] {
+		ip := net.ParseIP(s)
+		if (network == "ip4") == (ip.To4() != nil) {
+			ips = append(ips, ip)
+		}
+	}
+	if len(ips) == 0 || r.fail[host] {
+		return nil, r.err(host)
+	}
+	return ips, nil
+}
+
+func (r *fakeResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	hosts, ok := r.mx[name]
+	if !ok || r.fail[name] {
+		return nil, r.err(name)
+	}
+	var mxs []*net.MX
+	for i, host := range hosts {
+		mxs = append(mxs, &net.MX{Host: host + ".", Pref: uint16(10 * (i + 1))})
+	}
+	return mxs, nil
+}
+
+func (r *fakeResolver) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	names, ok := r.ptr[addr]
+	if !ok {
+		return nil, r.err(addr)
+	}
+	return names, nil
+}
+
+// TestEvaluateSPF tests SPF evaluation of the supported mechanisms and error conditions
+func TestEvaluateSPF(t *testing.T) {
+	resolver := &fakeResolver{
+		txt: map[string][]string{
+			"example.com":          {"v=spf1 ip4:192.0.2.0/24 include:_spf.example.net mx a:web.example.com/30 -all"},
+			"_spf.example.net":     {"v=spf1 ip6:2001:db8::/32 ~all"},
+			"redirect.example.com": {"v=spf1 redirect=example.com"},
+			"soft.example.com":     {"some other text", "v=spf1 ?a ~all"},
+			"double.example.com":   {"v=spf1 -all", "v=spf1 +all"},
+			"macro.example.com":    {"v=spf1 exists:%{ir}.allow.example.com -all"},
+			"loop.example.com":     {"v=spf1 include:loop.example.com -all"},
+			"ptr.example.com":      {"v=spf1 ptr -all"},
+			"broken.example.com":   {"v=spf1 include:down.example.com -all"},
+			"unknown.example.com":  {"v=spf1 foo:bar -all"},
+		},
+		ip: map[string][]string{
+			"mx1.example.com":             {"198.51.100.10"},
+			"web.example.com":             {"203.0.113.5"},
+			"soft.example.com":            {"203.0.113.200"},
+			"host.example.com":            {"203.0.113.99"},
+			"7.2.0.192.allow.example.com": {"127.0.0.2"},
+		},
+		mx:   map[string][]string{"example.com": {"mx1.example.com"}},
+		ptr:  map[string][]string{"203.0.113.99": {"host.example.com."}},
+		fail: map[string]bool{"down.example.com": true},
+	}
+
+	tests := []struct {
+		name      string
+		ip        string
+		domain    string
+		want      string
+		mechanism string
+	}{
+		{"ip4 match", "192.0.2.7", "example.com", SPFPass, "ip4:192.0.2.0/24"},
+		{"include passes", "2001:db8::1", "example.com", SPFPass, "include:_spf.example.net"},
+		{"mx match", "198.51.100.10", "example.com", SPFPass, "mx"},
+		{"a with prefix length", "203.0.113.6", "example.com", SPFPass, "a:web.example.com/30"},
+		{"fail", "203.0.113.50", "example.com", SPFFail, "all"},
+		{"redirect", "192.0.2.1", "redirect.example.com", SPFPass, "ip4:192.0.2.0/24"},
+		{"softfail", "192.0.2.1", "soft.example.com", SPFSoftFail, "all"},
+		{"neutral qualifier", "203.0.113.200", "soft.example.com", SPFNeutral, "a"},
+		{"no record", "192.0.2.1", "none.example.com", SPFNone, ""},
+		{"multiple records", "192.0.2.1", "double.example.com", SPFPermError, ""},
+		{"macro match", "192.0.2.7", "macro.example.com", SPFPass, "exists:%{ir}.allow.example.com"},
+		{"macro no match", "192.0.2.1", "macro.example.com", SPFFail, "all"},
+		{"include loop", "192.0.2.1", "loop.example.com", SPFPermError, ""},
+		{"ptr", "203.0.113.99", "ptr.example.com", SPFFail, "all"},
+		{"temporary failure", "192.0.2.1", "broken.example.com", SPFTempError, ""},
+		{"unknown mechanism", "192.0.2.1", "unknown.example.com", SPFPermError, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := EvaluateSPF(context.Background(), resolver, net.ParseIP(tt.ip), tt.domain, "user@"+tt.domain)
+			if got.Result != tt.want {
+				t.Errorf("Result = %q (%s), want %q", got.Result, got.Explanation, tt.want)
+			}
+			if tt.mechanism != "" && got.Mechanism != tt.mechanism {
+				t.Errorf("Mechanism = %q, want %q", got.Mechanism, tt.mechanism)
+			}
+		})
+	}
+}
+
+// TestEvaluateSPFLookupLimit tests that more than ten DNS-querying terms is a permerror
+func TestEvaluateSPFLookupLimit(t *testing.T) {
+	terms := []string{"v=spf1"}
+	resolver := &fakeResolver{txt: map[string][]string{}}
+	for i := 0; i < 11; i++ {
+		name := "n" + strings.Repeat("x", i) + ".example.com"
+		terms = append(terms, "include:"+name)
+		resolver.txt[name] = []string{"v=spf1 -all"}
+	}
+	resolver.txt["many.example.com"] = []string{strings.Join(append(terms, "-all"), " ")}
+
+	got := EvaluateSPF(context.Background(), resolver, net.ParseIP("192.0.2.1"), "many.example.com", "")
+	if got.Result != SPFPermError || !strings.Contains(got.Explanation, "DNS lookups") {
+		t.Errorf("EvaluateSPF() = %q (%s), want permerror for the lookup limit", got.Result, got.Explanation)
+	}
+}
+
+// TestIsNotFound tests classification of lookup errors
+func TestIsNotFound(t *testing.T) {
+	if !isNotFound(&net.DNSError{IsNotFound: true}) {
+		t.Errorf("isNotFound(NXDOMAIN) = false")
+	}
+	if isNotFound(errors.New("timeout")) {
+		t.Errorf("isNotFound(timeout) = true")
+	}
+}
+
+// TestExpandMacros tests macro expansion with the examples of RFC 7208 section 7.4
+func TestExpandMacros(t *testing.T) {
+	e := &spfEvaluation{
+		resolver: &fakeResolver{},
+		ip:       net.ParseIP("192.0.2.3"),
+		sender:   "strong-bad@email.example.com",
+		helo:     "mx.example.org",
+		receiver: "mx.example.net",
+		now:      func() time.Time { return time.Unix(1700000000, 0) },
+	}
+	domain := "email.example.com"
+
+	tests := []struct {
+		macro string
+		want  string
+	}{
+		{"%{s}", "strong-bad@email.example.com"},
+		{"%{o}", "email.example.com"},
+		{"%{d}", "email.example.com"},
+		{"%{d4}", "email.example.com"},
+		{"%{d2}", "example.com"},
+		{"%{d1}", "com"},
+		{"%{dr}", "com.example.email"},
+		{"%{d2r}", "example.email"},
+		{"%{l}", "strong-bad"},
+		{"%{l-}", "strong.bad"},
+		{"%{lr-}", "bad.strong"},
+		{"%{l1r-}", "strong"},
+		{"%{ir}.%{v}._spf.%{d2}", "3.2.0.192.in-addr._spf.example.com"},
+		{"%{lr-}.lp._spf.%{d2}", "bad.strong.lp._spf.example.com"},
+		{"%{h}", "mx.example.org"},
+		{"%{S}", "strong-bad%40email.example.com"},
+		{"%%%_%-", "% %20"},
+	}
+	for _, tt := range tests {
+		got, err := e.expandMacros(context.Background(), tt.macro, domain, false)
+		if err != nil || got != tt.want {
+			t.Errorf("expandMacros(%q) = %q, %v, want %q", tt.macro, got, err, tt.want)
+		}
+	}
+
+	// c, r and t are only allowed in explanations
+	if got, err := e.expandMacros(context.Background(), "%{c} %{r} %{t}", domain, true); err != nil || got != "192.0.2.3 mx.example.net 1700000000" {
+		t.Errorf("expandMacros(explanation) = %q, %v", got, err)
+	}
+	for _, invalid := range []string{"%{c}", "%{x}", "%{d0}", "%{d", "%a", "%"} {
+		if _, err := e.expandMacros(context.Background(), invalid, domain, false); err == nil {
+			t.Errorf("expandMacros(%q) succeeded, want an error", invalid)
+		}
+	}
+
+	e.ip = net.ParseIP("2001:db8::cb01")
+	want := "1.0.b.c.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6._spf.example.com"
+	if got, _ := e.expandMacros(context.Background(), "%{ir}.%{v}._spf.%{d2}", domain, false); got != want {
+		t.Errorf("expandMacros(IPv6) = %q, want %q", got, want)
+	}
+}
+
+// TestCheckHostExplanation tests exp= explanations and the evaluation trace
+func TestCheckHostExplanation(t *testing.T) {
+	resolver := &fakeResolver{
+		txt: map[string][]string{
+			"example.com":               {"v=spf1 include:inner.example.com ip4:192.0.2.0/24 -all exp=explain._spf.%{d}"},
+			"explain._spf.example.com":  {"%{i} is not one of %{d}'s designated mail servers"},
+			"inner.example.com":         {"v=spf1 -ip4:198.51.100.0/24 exp=inner-explain.example.com"},
+			"inner-explain.example.com": {"should not be used"},
+		},
+	}
+
+	got := CheckHost(context.Background(), SPFCheckOptions{
+		Resolver: resolver,
+		IP:       net.ParseIP("203.0.113.9"),
+		Sender:   "user@example.com",
+	})
+	if got.Result != SPFFail || got.Explanation != "203.0.113.9 is not one of example.com's designated mail servers" {
+		t.Errorf("CheckHost() = %q (%q), want fail with the exp= explanation", got.Result, got.Explanation)
+	}
+
+	// Trace: record, include (with the nested record and term), ip4, -all
+	var terms []string
+	for _, step := range got.Trace {
+		terms = append(terms, fmt.Sprintf("%d %s %s", step.Depth, step.Term, step.Result))
+	}
+	want := []string{
+		"0  record",
+		"0 include:inner.example.com no-match",
+		"1  record",
+		"1 -ip4:198.51.100.0/24 no-match",
+		"1  neutral",
+		"0 ip4:192.0.2.0/24 no-match",
+		"0 -all match",
+	}
+	if strings.Join(terms, "|") != strings.Join(want, "|") {
+		t.Errorf("Trace = %q, want %q", terms, want)
+	}
+	if got.Trace[1].Target != "inner.example.com" {
+		t.Errorf("include Target = %q", got.Trace[1].Target)
+	}
+}
+
+// TestCheckSPFSyntax tests the syntax check of SPF records
+func TestCheckSPFSyntax(t *testing.T) {
+	valid := []string{
+		"v=spf1 -all",
+		"v=spf1",
+		"v=spf1 a mx/24 a:mail.example.com/28//64 ptr ~all",
+		"v=spf1 ip4:192.0.2.0/24 ip6:2001:db8::/32 include:_spf.example.com ?all",
+		"v=spf1 exists:%{ir}.%{l1r+-}._spf.%{d} redirect=_spf.example.com exp=explain.%{d}",
+		"V=SPF1 Include:example.com unknown-mod=%{d}",
+	}
+	for _, record := range valid {
+		if _, err := CheckSPFSyntax(record); err != nil {
+			t.Errorf("CheckSPFSyntax(%q) = %v, want nil", record, err)
+		}
+	}
+
+	invalid := []string{
+		"",
+		"v=spf2 -all",
+		"v=spf1 ip4:192.0.2.300",
+		"v=spf1 ip4:2001:db8::1",
+		"v=spf1 include",
+		"v=spf1 a/33",
+		"v=spf1 all:example.com",
+		"v=spf1 ptr/24",
+		"v=spf1 foo:example.com",
+		"v=spf1 +-all",
+		"v=spf1 redirect=a.example.com redirect=b.example.com",
+		"v=spf1 exists:%{x}.example.com",
+		"v=spf1 exists:%{t}.example.com",
+		"v=spf1 1bad=value",
+	}
+	for _, record := range invalid {
+		if _, err := CheckSPFSyntax(record); err == nil {
+			t.Errorf("CheckSPFSyntax(%q) succeeded, want an error", record)
+		}
+	}
+}
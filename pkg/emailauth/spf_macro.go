@@ -0,0 +1,265 @@
+// Package emailauth provides functionality for checking email authentication mechanisms.
+package emailauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// spfMacroDelimiters are the characters allowed as macro delimiters (RFC 7208 section 7.1).
+const spfMacroDelimiters = ".-+,/_="
+
+// expandDomainSpec expands the macros of a domain-spec; an empty spec is the current domain.
+// Expanded names longer than 253 characters lose labels from the left (RFC 7208 section 7.3).
+func (e *spfEvaluation) expandDomainSpec(ctx context.Context, spec, domain string) (string, error) {
+	if spec == "" {
+		return domain, nil
+	}
+	expanded, err := e.expandMacros(ctx, spec, domain, false)
+	if err != nil {
+		return "", err
+	}
+	expanded = strings.TrimSuffix(strings.ToLower(expanded), ".")
+	for len(expanded) > 253 {
+		i := strings.IndexByte(expanded, '.')
+		if i < 0 {
+			break
+		}
+		expanded = expanded[i+1:]
+	}
+	if !isSPFDomainName(expanded) {
+		return "", &spfError{SPFPermError, fmt.Sprintf("%q expands to the invalid domain %q", spec, expanded)}
+	}
+	return expanded, nil
+}
+
+// expandMacros expands a macro-string (RFC 7208 section 7). The c, r and t macros are only
+// allowed in explanation strings.
+func (e *spfEvaluation) expandMacros(ctx context.Context, s, domain string, explanation bool) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i+1 >= len(s) {
+			return "", &spfError{SPFPermError, fmt.Sprintf("%q ends with a lone %%", s)}
+		}
+		i++
+		switch s[i] {
+		case '%':
+			b.WriteByte('%')
+		case '_':
+			b.WriteByte(' ')
+		case '-':
+			b.WriteString("%20")
+		case '{':
+			end := strings.IndexByte(s[i:], '}')
+			if end < 0 {
+				return "", &spfError{SPFPermError, fmt.Sprintf("unterminated macro in %q", s)}
+			}
+			value, err := e.expandMacro(ctx, s[i+1:i+end], domain, explanation)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(value)
+			i += end
+		default:
+			return "", &spfError{SPFPermError, fmt.Sprintf("invalid macro %%%c in %q", s[i], s)}
+		}
+	}
+	return b.String(), nil
+}
+
+// expandMacro expands the body of one %{...} macro: a letter, an optional number of parts to keep,
+// an optional r to reverse the parts and optional delimiters.
+func (e *spfEvaluation) expandMacro(ctx context.Context, macro, domain string, explanation bool) (string, error) {
+	if macro == "" {
+		return "", &spfError{SPFPermError, "empty macro"}
+	}
+	letter := macro[0]
+	lower := letter | 0x20
+
+	var value string
+	switch lower {
+	case 's':
+		value = e.sender
+	case 'l':
+		value = e.sender[:strings.LastIndexByte(e.sender, '@')]
+	case 'o':
+		value = e.sender[strings.LastIndexByte(e.sender, '@')+1:]
+	case 'd':
+		value = domain
+	case 'i':
+		value = spfMacroIP(e.ip)
+	case 'p':
+		value = e.macroValidatedName(ctx, domain)
+	case 'v':
+		value = "ip6"
+		if e.ip.To4() != nil {
+			value = "in-addr"
+		}
+	case 'h':
+		value = e.helo
+	case 'c', 'r', 't':
+		if !explanation {
+			return "", &spfError{SPFPermError, fmt.Sprintf("macro %%{%c} is only allowed in explanations", letter)}
+		}
+		switch lower {
+		case 'c':
+			value = e.ip.String()
+		case 'r':
+			value = e.receiver
+		default:
+			value = strconv.FormatInt(e.now().Unix(), 10)
+		}
+	default:
+		return "", &spfError{SPFPermError, fmt.Sprintf("unknown macro letter %q", letter)}
+	}
+
+	keep, reverse, delimiters, err := parseMacroTransformers(macro)
+	if err != nil {
+		return "", err
+	}
+
+	if keep > 0 || reverse || delimiters != "." {
+		parts := strings.FieldsFunc(value, func(r rune) bool { return strings.ContainsRune(delimiters, r) })
+		if reverse {
+			for i, j := 0, len(parts)-1; i < j; i, j = i+1, j-1 {
+				parts[i], parts[j] = parts[j], parts[i]
+			}
+		}
+		if keep > 0 && keep < len(parts) {
+			parts = parts[len(parts)-keep:]
+		}
+		value = strings.Join(parts, ".")
+	}
+
+	// Uppercase macro letters are URL-escaped
+	if letter != lower {
+		value = spfURLEscape(value)
+	}
+	return value, nil
+}
+
+// parseMacroTransformers parses the transformers following the macro letter: digits, then r,
+// then delimiters (RFC 7208 section 7.1).
+func parseMacroTransformers(macro string) (int, bool, string, error) {
+	rest := macro[1:]
+	digits := 0
+	for digits < len(rest) && rest[digits] >= '0' && rest[digits] <= '9' {
+		digits++
+	}
+	keep := 0
+	if digits > 0 {
+		n, err := strconv.Atoi(rest[:digits])
+		if err != nil || n == 0 {
+			return 0, false, "", &spfError{SPFPermError, fmt.Sprintf("invalid macro transformer in %%{%s}", macro)}
+		}
+		keep = n
+	}
+	rest = rest[digits:]
+	reverse := false
+	if rest != "" && (rest[0] == 'r' || rest[0] == 'R') {
+		reverse = true
+		rest = rest[1:]
+	}
+	delimiters := "."
+	if rest != "" {
+		if strings.Trim(rest, spfMacroDelimiters) != "" {
+			return 0, false, "", &spfError{SPFPermError, fmt.Sprintf("invalid macro delimiter in %%{%s}", macro)}
+		}
+		delimiters = rest
+	}
+	return keep, reverse, delimiters, nil
+}
+
+// checkMacroString checks the syntax of a macro-string without expanding it.
+func checkMacroString(s string, explanation bool) error {
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' {
+			continue
+		}
+		if i+1 >= len(s) {
+			return fmt.Errorf("%q ends with a lone %%", s)
+		}
+		i++
+		switch s[i] {
+		case '%', '_', '-':
+		case '{':
+			end := strings.IndexByte(s[i:], '}')
+			if end < 0 {
+				return fmt.Errorf("unterminated macro in %q", s)
+			}
+			macro := s[i+1 : i+end]
+			if macro == "" {
+				return errors.New("empty macro")
+			}
+			letter := macro[0] | 0x20
+			if !strings.ContainsRune("slodipvh", rune(letter)) && (!explanation || !strings.ContainsRune("crt", rune(letter))) {
+				return fmt.Errorf("invalid macro letter in %%{%s}", macro)
+			}
+			if _, _, _, err := parseMacroTransformers(macro); err != nil {
+				return err
+			}
+			i += end
+		default:
+			return fmt.Errorf("invalid macro %%%c in %q", s[i], s)
+		}
+	}
+	return nil
+}
+
+// macroValidatedName returns the value of %{p}: a validated PTR name of the connecting IP,
+// preferring the current domain and its subdomains, or "unknown".
+func (e *spfEvaluation) macroValidatedName(ctx context.Context, domain string) string {
+	names := e.validatedNames(ctx, "")
+	for _, name := range names {
+		if name == domain {
+			return name
+		}
+	}
+	for _, name := range names {
+		if strings.HasSuffix(name, "."+domain) {
+			return name
+		}
+	}
+	if len(names) > 0 {
+		return names[0]
+	}
+	return "unknown"
+}
+
+// spfMacroIP formats an IP for %{i}: dotted quad for IPv4, dot-separated nibbles for IPv6.
+func spfMacroIP(ip net.IP) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.String()
+	}
+	ip16 := ip.To16()
+	if ip16 == nil {
+		return ""
+	}
+	nibbles := make([]string, 0, 32)
+	for _, b := range ip16 {
+		nibbles = append(nibbles, strconv.FormatUint(uint64(b>>4), 16), strconv.FormatUint(uint64(b&0xf), 16))
+	}
+	return strings.Join(nibbles, ".")
+}
+
+// spfURLEscape escapes every character outside the URI unreserved set (RFC 3986 section 2.3).
+func spfURLEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') || strings.IndexByte("-._~", c) >= 0 {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
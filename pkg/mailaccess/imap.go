@@ -0,0 +1,268 @@
+package mailaccess
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"mxclone/pkg/types"
+)
+
+// imapConn is an IMAP session with its command tag counter.
+type imapConn struct {
+	*session
+	tags int
+}
+
+// CheckIMAP connects to an IMAP server and reports its greeting, capabilities, STARTTLS and
+// certificate, the login methods it offers before and after TLS, and optionally whether the
+// credentials are accepted. Credentials are only sent on an encrypted session.
+func CheckIMAP(ctx context.Context, opts CheckOptions) (*types.MailAccessReport, error) {
+	if opts.Port == 0 {
+		opts.Port = IMAPPort
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = 10 * time.Second
+	}
+
+	report := &types.MailAccessReport{
+		Protocol:    ProtocolIMAP,
+		Host:        opts.Host,
+		Port:        opts.Port,
+		ImplicitTLS: opts.ImplicitTLS || IsImplicitTLSPort(opts.Port),
+	}
+
+	s, err := dial(ctx, opts.Host, opts.Port, opts.Timeout, report.ImplicitTLS, report)
+	if err != nil {
+		report.Error = err.Error()
+		return report, err
+	}
+	c := &imapConn{session: s}
+	defer func() {
+		c.close()
+		report.Transcript = c.transcript
+	}()
+
+	if err := c.check(ctx, opts, report); err != nil {
+		report.Error = err.Error()
+		return report, err
+	}
+	return report, nil
+}
+
+// check runs the IMAP session after the connection is established.
+func (c *imapConn) check(ctx context.Context, opts CheckOptions, report *types.MailAccessReport) error {
+	if state, ok := c.tlsState(); ok {
+		recordTLS(report, state, opts.Host, opts.RootCAs)
+	}
+
+	greeting, err := c.readLine()
+	if err != nil {
+		return fmt.Errorf("failed to read greeting: %w", err)
+	}
+	report.Banner = greeting
+	switch {
+	case strings.HasPrefix(greeting, "* OK"), strings.HasPrefix(greeting, "* PREAUTH"):
+	case strings.HasPrefix(greeting, "* BYE"):
+		return fmt.Errorf("server refused the session: %s", greeting)
+	default:
+		return fmt.Errorf("unexpected IMAP greeting: %q", greeting)
+	}
+
+	caps := greetingCapabilities(greeting)
+	if caps == nil {
+		if caps, err = c.capability(); err != nil {
+			return err
+		}
+	}
+	report.Capabilities = caps
+	report.STARTTLS = containsFold(caps, "STARTTLS")
+
+	if !report.TLS {
+		report.LoginDisabled = containsFold(caps, "LOGINDISABLED")
+		report.PlaintextAuth = imapLoginMethods(caps)
+
+		if report.STARTTLS {
+			if err := c.startTLS(ctx); err != nil {
+				report.STARTTLSError = err.Error()
+				return nil
+			}
+			state, _ := c.tlsState()
+			recordTLS(report, state, opts.Host, opts.RootCAs)
+
+			// Capabilities advertised before TLS must be discarded (RFC 9051 section 6.2.1)
+			if caps, err = c.capability(); err != nil {
+				return err
+			}
+			report.TLSCapabilities = caps
+		}
+	}
+	report.AuthMechanisms = imapSASLMechanisms(caps)
+
+	if opts.Username != "" && opts.Password != "" {
+		c.login(opts, caps, report)
+	}
+
+	c.writeLine(c.nextTag()+" LOGOUT", c.tag()+" LOGOUT")
+	return nil
+}
+
+// nextTag returns a new command tag.
+func (c *imapConn) nextTag() string {
+	c.tags++
+	return c.tag()
+}
+
+// tag returns the current command tag.
+func (c *imapConn) tag() string {
+	return fmt.Sprintf("a%d", c.tags)
+}
+
+// command sends a tagged command and reads the response up to its completion or a continuation
+// request. It returns the status (OK, NO, BAD, or "+" for a continuation), the status text and
+// the untagged responses.
+func (c *imapConn) command(line, shown string) (string, string, []string, error) {
+	tag := c.nextTag()
+	if err := c.writeLine(tag+" "+line, tag+" "+shown); err != nil {
+		return "", "", nil, err
+	}
+	return c.response(tag)
+}
+
+// response reads the response to the command with the given tag.
+func (c *imapConn) response(tag string) (string, string, []string, error) {
+	var untagged []string
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return "", "", untagged, err
+		}
+		switch {
+		case strings.HasPrefix(line, "* "):
+			untagged = append(untagged, line[2:])
+		case line == "+" || strings.HasPrefix(line, "+ "):
+			return "+", strings.TrimSpace(strings.TrimPrefix(line, "+")), untagged, nil
+		case strings.HasPrefix(line, tag+" "):
+			status, text, _ := strings.Cut(line[len(tag)+1:], " ")
+			return strings.ToUpper(status), text, untagged, nil
+		}
+	}
+}
+
+// capability sends CAPABILITY and returns the advertised capabilities.
+func (c *imapConn) capability() ([]string, error) {
+	status, text, untagged, err := c.command("CAPABILITY", "CAPABILITY")
+	if err != nil {
+		return nil, fmt.Errorf("CAPABILITY failed: %w", err)
+	}
+	if status != "OK" {
+		return nil, fmt.Errorf("CAPABILITY refused: %s %s", status, text)
+	}
+	caps := []string{}
+	for _, line := range untagged {
+		if fields := strings.Fields(line); len(fields) > 0 && strings.EqualFold(fields[0], "CAPABILITY") {
+			caps = append(caps, fields[1:]...)
+		}
+	}
+	return caps, nil
+}
+
+// startTLS sends STARTTLS and performs the TLS handshake.
+func (c *imapConn) startTLS(ctx context.Context) error {
+	status, text, _, err := c.command("STARTTLS", "STARTTLS")
+	if err != nil {
+		return err
+	}
+	if status != "OK" {
+		return fmt.Errorf("STARTTLS refused: %s %s", status, text)
+	}
+	return c.handshake(ctx)
+}
+
+// login tests the credentials with LOGIN, or with AUTHENTICATE PLAIN when LOGIN is disabled.
+func (c *imapConn) login(opts CheckOptions, caps []string, report *types.MailAccessReport) {
+	if !report.TLS {
+		report.LoginResponse = "not attempted: the session is not encrypted"
+		return
+	}
+
+	report.LoginTested = true
+	var status, text string
+	var err error
+	switch {
+	case !containsFold(caps, "LOGINDISABLED"):
+		report.LoginMethod = "LOGIN"
+		status, text, _, err = c.command(
+			fmt.Sprintf("LOGIN %s %s", imapQuote(opts.Username), imapQuote(opts.Password)),
+			fmt.Sprintf("LOGIN %s %s", imapQuote(opts.Username), redacted))
+	case containsFold(caps, "AUTH=PLAIN"):
+		report.LoginMethod = "PLAIN"
+		status, text, _, err = c.command("AUTHENTICATE PLAIN", "AUTHENTICATE PLAIN")
+		if err == nil && status == "+" {
+			tag := c.tag()
+			response := base64.StdEncoding.EncodeToString([]byte("\x00" + opts.Username + "\x00" + opts.Password))
+			if err = c.writeLine(response, redacted); err == nil {
+				status, text, _, err = c.response(tag)
+			}
+		}
+	default:
+		report.LoginResponse = "LOGIN is disabled and AUTHENTICATE PLAIN is not offered"
+		return
+	}
+
+	if err != nil {
+		report.LoginResponse = err.Error()
+		return
+	}
+	report.LoginSucceeded = status == "OK"
+	report.LoginResponse = strings.TrimSpace(status + " " + text)
+}
+
+// greetingCapabilities returns the capabilities announced in a greeting response code, or nil.
+func greetingCapabilities(greeting string) []string {
+	start := strings.Index(strings.ToUpper(greeting), "[CAPABILITY ")
+	if start < 0 {
+		return nil
+	}
+	rest := greeting[start+len("[CAPABILITY "):]
+	end := strings.IndexByte(rest, ']')
+	if end < 0 {
+		return nil
+	}
+	return strings.Fields(rest[:end])
+}
+
+// imapSASLMechanisms returns the SASL mechanisms of the AUTH= capabilities.
+func imapSASLMechanisms(caps []string) []string {
+	var mechanisms []string
+	for _, capability := range caps {
+		if len(capability) > 5 && strings.EqualFold(capability[:5], "AUTH=") {
+			mechanisms = append(mechanisms, strings.ToUpper(capability[5:]))
+		}
+	}
+	return mechanisms
+}
+
+// imapLoginMethods returns the login methods a client may use with these capabilities:
+// the LOGIN command unless LOGINDISABLED is advertised, and the SASL mechanisms.
+func imapLoginMethods(caps []string) []string {
+	var methods []string
+	if !containsFold(caps, "LOGINDISABLED") {
+		methods = append(methods, "LOGIN")
+	}
+	for _, mechanism := range imapSASLMechanisms(caps) {
+		if !containsFold(methods, mechanism) {
+			methods = append(methods, mechanism)
+		}
+	}
+	return methods
+}
+
+// imapQuote returns s as an IMAP quoted string.
+func imapQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
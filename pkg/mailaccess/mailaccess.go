@@ -0,0 +1,253 @@
+// Package mailaccess provides IMAP and POP3 diagnostic functionality.
+package mailaccess
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"mxclone/pkg/types"
+)
+
+// Protocols
+const (
+	ProtocolIMAP = "imap"
+	ProtocolPOP3 = "pop3"
+)
+
+// Standard ports; the TLS ports negotiate TLS before the greeting.
+const (
+	IMAPPort    = 143
+	IMAPTLSPort = 993
+	POP3Port    = 110
+	POP3TLSPort = 995
+)
+
+// Transcript entry directions, as used by the SMTP transcripts
+const (
+	DirectionClient = "client"
+	DirectionServer = "server"
+	DirectionTLS    = "tls"
+	DirectionInfo   = "info"
+)
+
+// redacted replaces credentials in transcripts.
+const redacted = "<redacted>"
+
+// CheckOptions configures an IMAP or POP3 check.
+type CheckOptions struct {
+	// Host is the server to check
+	Host string
+	// Port defaults to 143 (IMAP) or 110 (POP3); 993 and 995 use implicit TLS
+	Port int
+	// ImplicitTLS negotiates TLS before the greeting on a non-standard port
+	ImplicitTLS bool
+	// Username and Password enable the login test when both are set
+	Username string
+	Password string
+	// Timeout applies to the connection and to each command
+	Timeout time.Duration
+	// RootCAs verifies the server certificate (default: system roots)
+	RootCAs *x509.CertPool
+}
+
+// IsImplicitTLSPort reports whether a port negotiates TLS before the greeting.
+func IsImplicitTLSPort(port int) bool {
+	return port == IMAPTLSPort || port == POP3TLSPort
+}
+
+// session is a line-oriented connection to an IMAP or POP3 server that records a transcript.
+type session struct {
+	conn     net.Conn
+	reader   *bufio.Reader
+	host     string
+	timeout  time.Duration
+	started  time.Time
+	lastSent time.Time
+
+	transcript []types.TranscriptEntry
+}
+
+// dial connects to a server, performing the TLS handshake first when implicitTLS is set.
+func dial(ctx context.Context, host string, port int, timeout time.Duration, implicitTLS bool, report *types.MailAccessReport) (*session, error) {
+	dialer := &net.Dialer{Timeout: timeout}
+
+	started := time.Now()
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+	if err != nil {
+		return nil, err
+	}
+	report.Connected = true
+	report.Latency = time.Since(started)
+
+	s := &session{
+		conn:     conn,
+		reader:   bufio.NewReader(conn),
+		host:     host,
+		timeout:  timeout,
+		started:  started,
+		lastSent: time.Now(),
+	}
+	s.record(DirectionInfo, fmt.Sprintf("Connected to %s in %s", conn.RemoteAddr(), report.Latency.Round(time.Millisecond)))
+
+	if implicitTLS {
+		if err := s.handshake(ctx); err != nil {
+			conn.Close()
+			report.Transcript = s.transcript
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// record appends an entry to the transcript.
+func (s *session) record(direction, line string) *types.TranscriptEntry {
+	now := time.Now()
+	s.transcript = append(s.transcript, types.TranscriptEntry{
+		Timestamp: now,
+		Elapsed:   now.Sub(s.started),
+		Direction: direction,
+		Line:      line,
+	})
+	return &s.transcript[len(s.transcript)-1]
+}
+
+// handshake upgrades the connection to TLS. Certificates are not verified here so that the
+// check can report on them; see CertificateInfo.
+func (s *session) handshake(ctx context.Context) error {
+	tlsConn := tls.Client(s.conn, &tls.Config{
+		ServerName:         s.host,
+		InsecureSkipVerify: true,
+		MinVersion:         tls.VersionTLS10,
+	})
+	tlsConn.SetDeadline(time.Now().Add(s.timeout))
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		s.record(DirectionTLS, "TLS handshake failed: "+err.Error())
+		return fmt.Errorf("TLS handshake failed: %w", err)
+	}
+	tlsConn.SetDeadline(time.Time{})
+
+	state := tlsConn.ConnectionState()
+	s.record(DirectionTLS, fmt.Sprintf("TLS handshake completed: %s, %s", tls.VersionName(state.Version), tls.CipherSuiteName(state.CipherSuite)))
+	s.conn = tlsConn
+	s.reader = bufio.NewReader(tlsConn)
+	return nil
+}
+
+// tlsState returns the TLS connection state, if the session is encrypted.
+func (s *session) tlsState() (tls.ConnectionState, bool) {
+	if tlsConn, ok := s.conn.(*tls.Conn); ok {
+		return tlsConn.ConnectionState(), true
+	}
+	return tls.ConnectionState{}, false
+}
+
+// readLine reads one line from the server.
+func (s *session) readLine() (string, error) {
+	s.conn.SetReadDeadline(time.Now().Add(s.timeout))
+	line, err := s.reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	entry := s.record(DirectionServer, line)
+	entry.Duration = entry.Timestamp.Sub(s.lastSent)
+	return line, nil
+}
+
+// writeLine sends a line terminated by CRLF and records shown in the transcript in its place,
+// so that credentials never reach the transcript.
+func (s *session) writeLine(line, shown string) error {
+	s.record(DirectionClient, shown)
+	s.lastSent = time.Now()
+	s.conn.SetWriteDeadline(time.Now().Add(s.timeout))
+	_, err := s.conn.Write([]byte(line + "\r\n"))
+	return err
+}
+
+// close closes the connection.
+func (s *session) close() error {
+	return s.conn.Close()
+}
+
+// recordTLS stores the negotiated TLS parameters and the certificate details in the report.
+func recordTLS(report *types.MailAccessReport, state tls.ConnectionState, host string, roots *x509.CertPool) {
+	report.TLS = true
+	report.TLSVersion = tls.VersionName(state.Version)
+	report.TLSCipher = tls.CipherSuiteName(state.CipherSuite)
+	report.Certificate = CertificateInfo(state.PeerCertificates, host, roots)
+}
+
+// CertificateInfo describes the leaf of a certificate chain sent by a server and verifies the
+// chain and the host name against roots (system roots when nil).
+func CertificateInfo(chain []*x509.Certificate, host string, roots *x509.CertPool) *types.TLSCertificateInfo {
+	if len(chain) == 0 {
+		return nil
+	}
+	leaf := chain[0]
+
+	info := &types.TLSCertificateInfo{
+		Subject:            leaf.Subject.String(),
+		Issuer:             leaf.Issuer.String(),
+		DNSNames:           leaf.DNSNames,
+		SerialNumber:       leaf.SerialNumber.String(),
+		NotBefore:          leaf.NotBefore,
+		NotAfter:           leaf.NotAfter,
+		SignatureAlgorithm: leaf.SignatureAlgorithm.String(),
+		PublicKeyAlgorithm: leaf.PublicKeyAlgorithm.String(),
+		PublicKeyBits:      publicKeyBits(leaf.PublicKey),
+		ChainLength:        len(chain),
+	}
+	info.SelfSigned = leaf.Subject.String() == leaf.Issuer.String() && leaf.CheckSignatureFrom(leaf) == nil
+	info.HostnameMatch = leaf.VerifyHostname(host) == nil
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range chain[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := leaf.Verify(x509.VerifyOptions{
+		DNSName:       host,
+		Roots:         roots,
+		Intermediates: intermediates,
+	})
+	if err != nil {
+		info.VerifyError = err.Error()
+	} else {
+		info.Trusted = true
+	}
+
+	return info
+}
+
+// publicKeyBits returns the size of a public key in bits.
+func publicKeyBits(key interface{}) int {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return k.N.BitLen()
+	case *ecdsa.PublicKey:
+		return k.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		return 256
+	}
+	return 0
+}
+
+// containsFold reports whether list contains value, ignoring case.
+func containsFold(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
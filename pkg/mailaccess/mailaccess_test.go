@@ -0,0 +1,455 @@
+package mailaccess
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"mxclone/pkg/types"
+)
+
+const (
+	testUser     = "alice"
+	testPassword = "s3cret"
+)
+
+// testCertificate returns a certificate for 127.0.0.1 and a pool that trusts it.
+func testCertificate(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(42),
+		Subject:               pkix.Name{CommonName: "mail.example"},
+		DNSNames:              []string{"mail.example"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate() error = %v", err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("ParseCertificate() error = %v", err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, pool
+}
+
+// fakeServer is a local IMAP or POP3 stand-in.
+type fakeServer struct {
+	// Protocol is imap or pop3
+	protocol string
+	// implicitTLS negotiates TLS before the greeting
+	implicitTLS bool
+	// Capabilities before and after STARTTLS/STLS; nil plainCaps hides the capability list
+	plainCaps []string
+	tlsCaps   []string
+	// noCapa makes POP3 CAPA fail
+	noCapa bool
+
+	config   *tls.Config
+	listener net.Listener
+}
+
+// start listens on a local port and serves sessions until the test ends.
+func (f *fakeServer) start(t *testing.T, cert tls.Certificate) int {
+	t.Helper()
+	f.config = &tls.Config{Certificates: []tls.Certificate{cert}}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	f.listener = listener
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+// serve runs one session.
+func (f *fakeServer) serve(conn net.Conn) {
+	defer func() { conn.Close() }()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	encrypted := f.implicitTLS
+	if encrypted {
+		tlsConn := tls.Server(conn, f.config)
+		if tlsConn.Handshake() != nil {
+			return
+		}
+		conn = tlsConn
+	}
+	reader := bufio.NewReader(conn)
+	send := func(format string, args ...interface{}) {
+		fmt.Fprintf(conn, format+"\r\n", args...)
+	}
+	caps := func() []string {
+		if encrypted {
+			return f.tlsCaps
+		}
+		return f.plainCaps
+	}
+	upgrade := func() bool {
+		tlsConn := tls.Server(conn, f.config)
+		if tlsConn.Handshake() != nil {
+			return false
+		}
+		conn = tlsConn
+		reader = bufio.NewReader(conn)
+		encrypted = true
+		return true
+	}
+
+	if f.protocol == ProtocolIMAP {
+		send("* OK IMAP4rev1 stand-in ready")
+	} else {
+		send("+OK POP3 stand-in ready")
+	}
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		if f.protocol == ProtocolIMAP {
+			tag, command, _ := strings.Cut(line, " ")
+			verb, args, _ := strings.Cut(command, " ")
+			switch strings.ToUpper(verb) {
+			case "CAPABILITY":
+				send("* CAPABILITY %s", strings.Join(caps(), " "))
+				send("%s OK CAPABILITY completed", tag)
+			case "STARTTLS":
+				send("%s OK Begin TLS negotiation now", tag)
+				if !upgrade() {
+					return
+				}
+			case "LOGIN":
+				if args == fmt.Sprintf("%q %q", testUser, testPassword) {
+					send("%s OK LOGIN completed", tag)
+				} else {
+					send("%s NO [AUTHENTICATIONFAILED] Invalid credentials", tag)
+				}
+			case "AUTHENTICATE":
+				send("+ ")
+				response, _ := reader.ReadString('\n')
+				if strings.TrimSpace(response) == base64.StdEncoding.EncodeToString([]byte("\x00"+testUser+"\x00"+testPassword)) {
+					send("%s OK AUTHENTICATE completed", tag)
+				} else {
+					send("%s NO [AUTHENTICATIONFAILED] Invalid credentials", tag)
+				}
+			case "LOGOUT":
+				send("* BYE Logging out")
+				send("%s OK LOGOUT completed", tag)
+				return
+			default:
+				send("%s BAD Unknown command", tag)
+			}
+			continue
+		}
+
+		verb, args, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "CAPA":
+			if f.noCapa {
+				send("-ERR Unknown command")
+				continue
+			}
+			send("+OK Capability list follows")
+			for _, capability := range caps() {
+				send("%s", capability)
+			}
+			send(".")
+		case "STLS":
+			send("+OK Begin TLS negotiation")
+			if !upgrade() {
+				return
+			}
+		case "USER":
+			send("+OK")
+		case "PASS":
+			if args == testPassword {
+				send("+OK Logged in")
+			} else {
+				send("-ERR [AUTH] Invalid credentials")
+			}
+		case "AUTH":
+			send("+ ")
+			response, _ := reader.ReadString('\n')
+			if strings.TrimSpace(response) == base64.StdEncoding.EncodeToString([]byte("\x00"+testUser+"\x00"+testPassword)) {
+				send("+OK Logged in")
+			} else {
+				send("-ERR [AUTH] Invalid credentials")
+			}
+		case "QUIT":
+			send("+OK Bye")
+			return
+		default:
+			send("-ERR Unknown command")
+		}
+	}
+}
+
+// TestCheckIMAP tests the IMAP check against local stand-ins
+func TestCheckIMAP(t *testing.T) {
+	cert, roots := testCertificate(t)
+
+	tests := []struct {
+		name              string
+		server            *fakeServer
+		password          string
+		wantTLS           bool
+		wantSTARTTLS      bool
+		wantLoginDisabled bool
+		wantPlaintext     []string
+		wantMechanisms    []string
+		wantLoginTested   bool
+		wantLoginOK       bool
+		wantLoginMethod   string
+	}{
+		{
+			name: "STARTTLS with LOGINDISABLED before TLS",
+			server: &fakeServer{
+				plainCaps: []string{"IMAP4rev1", "STARTTLS", "LOGINDISABLED"},
+				tlsCaps:   []string{"IMAP4rev1", "AUTH=PLAIN", "AUTH=LOGIN"},
+			},
+			password:          testPassword,
+			wantTLS:           true,
+			wantSTARTTLS:      true,
+			wantLoginDisabled: true,
+			wantMechanisms:    []string{"PLAIN", "LOGIN"},
+			wantLoginTested:   true,
+			wantLoginOK:       true,
+			wantLoginMethod:   "LOGIN",
+		},
+		{
+			name: "cleartext login without STARTTLS",
+			server: &fakeServer{
+				plainCaps: []string{"IMAP4rev1", "AUTH=PLAIN"},
+			},
+			password:       testPassword,
+			wantPlaintext:  []string{"LOGIN", "PLAIN"},
+			wantMechanisms: []string{"PLAIN"},
+		},
+		{
+			name: "implicit TLS with a wrong password",
+			server: &fakeServer{
+				implicitTLS: true,
+				tlsCaps:     []string{"IMAP4rev1", "AUTH=PLAIN"},
+			},
+			password:        "wrong",
+			wantTLS:         true,
+			wantMechanisms:  []string{"PLAIN"},
+			wantLoginTested: true,
+			wantLoginMethod: "LOGIN",
+		},
+		{
+			name: "AUTHENTICATE PLAIN when LOGIN is disabled after TLS",
+			server: &fakeServer{
+				implicitTLS: true,
+				tlsCaps:     []string{"IMAP4rev1", "LOGINDISABLED", "AUTH=PLAIN"},
+			},
+			password:        testPassword,
+			wantTLS:         true,
+			wantMechanisms:  []string{"PLAIN"},
+			wantLoginTested: true,
+			wantLoginOK:     true,
+			wantLoginMethod: "PLAIN",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.server.protocol = ProtocolIMAP
+			port := tt.server.start(t, cert)
+
+			report, err := CheckIMAP(context.Background(), CheckOptions{
+				Host:        "127.0.0.1",
+				Port:        port,
+				ImplicitTLS: tt.server.implicitTLS,
+				Username:    testUser,
+				Password:    tt.password,
+				Timeout:     2 * time.Second,
+				RootCAs:     roots,
+			})
+			if err != nil {
+				t.Fatalf("CheckIMAP() error = %v", err)
+			}
+			checkReport(t, report, tt.wantTLS, tt.wantSTARTTLS, tt.wantPlaintext, tt.wantMechanisms, tt.wantLoginTested, tt.wantLoginOK, tt.wantLoginMethod)
+			if report.LoginDisabled != tt.wantLoginDisabled {
+				t.Errorf("LoginDisabled = %v, want %v", report.LoginDisabled, tt.wantLoginDisabled)
+			}
+		})
+	}
+}
+
+// TestCheckPOP3 tests the POP3 check against local stand-ins
+func TestCheckPOP3(t *testing.T) {
+	cert, roots := testCertificate(t)
+
+	tests := []struct {
+		name            string
+		server          *fakeServer
+		password        string
+		wantTLS         bool
+		wantSTARTTLS    bool
+		wantPlaintext   []string
+		wantMechanisms  []string
+		wantLoginTested bool
+		wantLoginOK     bool
+		wantLoginMethod string
+	}{
+		{
+			name: "STLS with USER",
+			server: &fakeServer{
+				plainCaps: []string{"TOP", "UIDL", "STLS"},
+				tlsCaps:   []string{"TOP", "UIDL", "USER", "SASL PLAIN"},
+			},
+			password:        testPassword,
+			wantTLS:         true,
+			wantSTARTTLS:    true,
+			wantMechanisms:  []string{"PLAIN"},
+			wantLoginTested: true,
+			wantLoginOK:     true,
+			wantLoginMethod: "USER",
+		},
+		{
+			name: "USER offered before STLS with a wrong password",
+			server: &fakeServer{
+				plainCaps: []string{"USER", "SASL PLAIN", "STLS"},
+				tlsCaps:   []string{"USER", "SASL PLAIN"},
+			},
+			password:        "wrong",
+			wantTLS:         true,
+			wantSTARTTLS:    true,
+			wantPlaintext:   []string{"USER", "PLAIN"},
+			wantMechanisms:  []string{"PLAIN"},
+			wantLoginTested: true,
+			wantLoginMethod: "USER",
+		},
+		{
+			name: "implicit TLS with AUTH PLAIN only",
+			server: &fakeServer{
+				implicitTLS: true,
+				tlsCaps:     []string{"SASL PLAIN"},
+			},
+			password:        testPassword,
+			wantTLS:         true,
+			wantMechanisms:  []string{"PLAIN"},
+			wantLoginTested: true,
+			wantLoginOK:     true,
+			wantLoginMethod: "PLAIN",
+		},
+		{
+			name:          "no CAPA support",
+			server:        &fakeServer{noCapa: true},
+			password:      testPassword,
+			wantPlaintext: []string{"USER"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.server.protocol = ProtocolPOP3
+			port := tt.server.start(t, cert)
+
+			report, err := CheckPOP3(context.Background(), CheckOptions{
+				Host:        "127.0.0.1",
+				Port:        port,
+				ImplicitTLS: tt.server.implicitTLS,
+				Username:    testUser,
+				Password:    tt.password,
+				Timeout:     2 * time.Second,
+				RootCAs:     roots,
+			})
+			if err != nil {
+				t.Fatalf("CheckPOP3() error = %v", err)
+			}
+			checkReport(t, report, tt.wantTLS, tt.wantSTARTTLS, tt.wantPlaintext, tt.wantMechanisms, tt.wantLoginTested, tt.wantLoginOK, tt.wantLoginMethod)
+		})
+	}
+}
+
+// checkReport compares the fields shared by the IMAP and POP3 tests.
+func checkReport(t *testing.T, report *types.MailAccessReport, wantTLS, wantSTARTTLS bool, wantPlaintext, wantMechanisms []string, wantLoginTested, wantLoginOK bool, wantLoginMethod string) {
+	t.Helper()
+	if !report.Connected || report.Banner == "" {
+		t.Errorf("Connected = %v, Banner = %q, want a greeting", report.Connected, report.Banner)
+	}
+	if report.TLS != wantTLS {
+		t.Errorf("TLS = %v, want %v", report.TLS, wantTLS)
+	}
+	if report.STARTTLS != wantSTARTTLS {
+		t.Errorf("STARTTLS = %v, want %v", report.STARTTLS, wantSTARTTLS)
+	}
+	if strings.Join(report.PlaintextAuth, ",") != strings.Join(wantPlaintext, ",") {
+		t.Errorf("PlaintextAuth = %v, want %v", report.PlaintextAuth, wantPlaintext)
+	}
+	if strings.Join(report.AuthMechanisms, ",") != strings.Join(wantMechanisms, ",") {
+		t.Errorf("AuthMechanisms = %v, want %v", report.AuthMechanisms, wantMechanisms)
+	}
+	if report.LoginTested != wantLoginTested || report.LoginSucceeded != wantLoginOK || report.LoginMethod != wantLoginMethod {
+		t.Errorf("login tested/succeeded/method = %v/%v/%q (%s), want %v/%v/%q",
+			report.LoginTested, report.LoginSucceeded, report.LoginMethod, report.LoginResponse, wantLoginTested, wantLoginOK, wantLoginMethod)
+	}
+	if wantTLS {
+		if report.Certificate == nil || !report.Certificate.Trusted || !report.Certificate.HostnameMatch {
+			t.Errorf("Certificate = %+v, want a trusted certificate matching the host", report.Certificate)
+		}
+	}
+	for _, entry := range report.Transcript {
+		if strings.Contains(entry.Line, testPassword) || strings.Contains(entry.Line, base64.StdEncoding.EncodeToString([]byte("\x00"+testUser+"\x00"+testPassword))) {
+			t.Errorf("transcript leaks the password: %q", entry.Line)
+		}
+	}
+}
+
+// TestCertificateInfo tests certificate verification and expiry details
+func TestCertificateInfo(t *testing.T) {
+	cert, roots := testCertificate(t)
+
+	info := CertificateInfo([]*x509.Certificate{cert.Leaf}, "mail.example", roots)
+	if !info.Trusted || !info.HostnameMatch || !info.SelfSigned || info.PublicKeyBits != 256 {
+		t.Errorf("CertificateInfo() = %+v, want a trusted self-signed P-256 certificate", info)
+	}
+
+	info = CertificateInfo([]*x509.Certificate{cert.Leaf}, "other.example", x509.NewCertPool())
+	if info.Trusted || info.HostnameMatch || info.VerifyError == "" {
+		t.Errorf("CertificateInfo() = %+v, want an untrusted certificate not matching the host", info)
+	}
+
+	if CertificateInfo(nil, "mail.example", roots) != nil {
+		t.Error("CertificateInfo(nil) should be nil")
+	}
+}
@@ -0,0 +1,211 @@
+package mailaccess
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"mxclone/pkg/types"
+)
+
+// pop3Conn is a POP3 session.
+type pop3Conn struct {
+	*session
+}
+
+// CheckPOP3 connects to a POP3 server and reports its greeting, CAPA, STLS and certificate,
+// the login methods it offers before and after TLS, and optionally whether the credentials are
+// accepted. Credentials are only sent on an encrypted session.
+func CheckPOP3(ctx context.Context, opts CheckOptions) (*types.MailAccessReport, error) {
+	if opts.Port == 0 {
+		opts.Port = POP3Port
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = 10 * time.Second
+	}
+
+	report := &types.MailAccessReport{
+		Protocol:    ProtocolPOP3,
+		Host:        opts.Host,
+		Port:        opts.Port,
+		ImplicitTLS: opts.ImplicitTLS || IsImplicitTLSPort(opts.Port),
+	}
+
+	s, err := dial(ctx, opts.Host, opts.Port, opts.Timeout, report.ImplicitTLS, report)
+	if err != nil {
+		report.Error = err.Error()
+		return report, err
+	}
+	c := &pop3Conn{session: s}
+	defer func() {
+		c.close()
+		report.Transcript = c.transcript
+	}()
+
+	if err := c.check(ctx, opts, report); err != nil {
+		report.Error = err.Error()
+		return report, err
+	}
+	return report, nil
+}
+
+// check runs the POP3 session after the connection is established.
+func (c *pop3Conn) check(ctx context.Context, opts CheckOptions, report *types.MailAccessReport) error {
+	if state, ok := c.tlsState(); ok {
+		recordTLS(report, state, opts.Host, opts.RootCAs)
+	}
+
+	greeting, err := c.readLine()
+	if err != nil {
+		return fmt.Errorf("failed to read greeting: %w", err)
+	}
+	report.Banner = greeting
+	if !strings.HasPrefix(greeting, "+OK") {
+		return fmt.Errorf("server refused the session: %s", greeting)
+	}
+
+	// CAPA is optional (RFC 2449); a server without it is assumed to accept USER/PASS
+	caps, capaSupported, err := c.capa()
+	if err != nil {
+		return err
+	}
+	report.Capabilities = caps
+	report.STARTTLS = containsFold(caps, "STLS")
+
+	if !report.TLS {
+		if capaSupported {
+			report.PlaintextAuth = pop3LoginMethods(caps)
+		} else {
+			report.PlaintextAuth = []string{"USER"}
+		}
+
+		if report.STARTTLS {
+			if err := c.stls(ctx); err != nil {
+				report.STARTTLSError = err.Error()
+				return nil
+			}
+			state, _ := c.tlsState()
+			recordTLS(report, state, opts.Host, opts.RootCAs)
+
+			if caps, capaSupported, err = c.capa(); err != nil {
+				return err
+			}
+			report.TLSCapabilities = caps
+		}
+	}
+	report.AuthMechanisms = pop3SASLMechanisms(caps)
+
+	if opts.Username != "" && opts.Password != "" {
+		c.login(opts, caps, capaSupported, report)
+	}
+
+	c.writeLine("QUIT", "QUIT")
+	return nil
+}
+
+// command sends a command and returns its single-line status response.
+func (c *pop3Conn) command(line, shown string) (string, error) {
+	if err := c.writeLine(line, shown); err != nil {
+		return "", err
+	}
+	return c.readLine()
+}
+
+// capa sends CAPA and returns the capability lines, and whether the server supports CAPA.
+func (c *pop3Conn) capa() ([]string, bool, error) {
+	status, err := c.command("CAPA", "CAPA")
+	if err != nil {
+		return nil, false, fmt.Errorf("CAPA failed: %w", err)
+	}
+	if !strings.HasPrefix(status, "+OK") {
+		return nil, false, nil
+	}
+
+	caps := []string{}
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return caps, true, fmt.Errorf("CAPA failed: %w", err)
+		}
+		if line == "." {
+			return caps, true, nil
+		}
+		caps = append(caps, strings.TrimPrefix(line, "."))
+	}
+}
+
+// stls sends STLS and performs the TLS handshake.
+func (c *pop3Conn) stls(ctx context.Context) error {
+	status, err := c.command("STLS", "STLS")
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(status, "+OK") {
+		return fmt.Errorf("STLS refused: %s", status)
+	}
+	return c.handshake(ctx)
+}
+
+// login tests the credentials with USER/PASS, or with AUTH PLAIN when USER is not offered.
+func (c *pop3Conn) login(opts CheckOptions, caps []string, capaSupported bool, report *types.MailAccessReport) {
+	if !report.TLS {
+		report.LoginResponse = "not attempted: the session is not encrypted"
+		return
+	}
+
+	report.LoginTested = true
+	var status string
+	var err error
+	switch {
+	case !capaSupported || containsFold(caps, "USER"):
+		report.LoginMethod = "USER"
+		status, err = c.command("USER "+opts.Username, "USER "+opts.Username)
+		if err == nil && strings.HasPrefix(status, "+OK") {
+			status, err = c.command("PASS "+opts.Password, "PASS "+redacted)
+		}
+	case containsFold(pop3SASLMechanisms(caps), "PLAIN"):
+		report.LoginMethod = "PLAIN"
+		status, err = c.command("AUTH PLAIN", "AUTH PLAIN")
+		if err == nil && (status == "+" || strings.HasPrefix(status, "+ ")) {
+			response := base64.StdEncoding.EncodeToString([]byte("\x00" + opts.Username + "\x00" + opts.Password))
+			status, err = c.command(response, redacted)
+		}
+	default:
+		report.LoginResponse = "neither USER nor AUTH PLAIN is offered"
+		return
+	}
+
+	if err != nil {
+		report.LoginResponse = err.Error()
+		return
+	}
+	report.LoginSucceeded = strings.HasPrefix(status, "+OK")
+	report.LoginResponse = status
+}
+
+// pop3SASLMechanisms returns the mechanisms of the SASL capability.
+func pop3SASLMechanisms(caps []string) []string {
+	for _, capability := range caps {
+		fields := strings.Fields(capability)
+		if len(fields) > 0 && strings.EqualFold(fields[0], "SASL") {
+			mechanisms := make([]string, 0, len(fields)-1)
+			for _, mechanism := range fields[1:] {
+				mechanisms = append(mechanisms, strings.ToUpper(mechanism))
+			}
+			return mechanisms
+		}
+	}
+	return nil
+}
+
+// pop3LoginMethods returns the login methods a client may use with these capabilities:
+// USER/PASS when USER is advertised, and the SASL mechanisms.
+func pop3LoginMethods(caps []string) []string {
+	var methods []string
+	if containsFold(caps, "USER") {
+		methods = append(methods, "USER")
+	}
+	return append(methods, pop3SASLMechanisms(caps)...)
+}
@@ -0,0 +1,375 @@
+// Package smtp provides SMTP diagnostic functionality.
+package smtp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"mxclone/pkg/types"
+)
+
+// Thresholds used to interpret greeting and command timings.
+const (
+	// PreGreetPauseThreshold is the greeting delay from which a deliberate pre-greet pause is assumed
+	PreGreetPauseThreshold = 3 * time.Second
+	// TarpitThreshold is the average command response time from which the server is considered to tarpit
+	TarpitThreshold = 2 * time.Second
+)
+
+// versionPatterns match banners that disclose MTA software and its version.
+// The last pattern catches generic "version 1.2.3" strings of unknown software.
+var versionPatterns = []struct {
+	software string
+	pattern  *regexp.Regexp
+}{
+	{"Postfix", regexp.MustCompile(`(?i)\bPostfix[ /(]*v?(\d+(?:\.\d+)+)`)},
+	{"Exim", regexp.MustCompile(`(?i)\bExim[ /]*v?(\d+(?:\.\d+)+)`)},
+	{"Sendmail", regexp.MustCompile(`(?i)\bSendmail[ /]*v?(\d+(?:\.\d+)+)`)},
+	{"Microsoft Exchange", regexp.MustCompile(`(?i)Microsoft ESMTP MAIL Service.*?Version:? *(\d+(?:\.\d+)+)`)},
+	{"OpenSMTPD", regexp.MustCompile(`(?i)\bOpenSMTPD[ /]*v?(\d+(?:\.\d+)+)`)},
+	{"Haraka", regexp.MustCompile(`(?i)\bHaraka[ /]*v?(\d+(?:\.\d+)+)`)},
+	{"MDaemon", regexp.MustCompile(`(?i)\bMDaemon[ /]*v?(\d+(?:\.\d+)+)`)},
+	{"", regexp.MustCompile(`(?i)\b(?:version|ver\.?)[ :]*v?(\d+\.\d+(?:\.\d+)*)`)},
+}
+
+// softwarePatterns identify MTA software named without a version.
+var softwarePatterns = []struct {
+	software string
+	pattern  *regexp.Regexp
+}{
+	{"Postfix", regexp.MustCompile(`(?i)\bPostfix\b`)},
+	{"Exim", regexp.MustCompile(`(?i)\bExim\b`)},
+	{"Sendmail", regexp.MustCompile(`(?i)\bSendmail\b`)},
+	{"Microsoft Exchange", regexp.MustCompile(`(?i)Microsoft ESMTP MAIL Service`)},
+	{"OpenSMTPD", regexp.MustCompile(`(?i)\bOpenSMTPD\b`)},
+	{"qmail", regexp.MustCompile(`(?i)\bqmail\b`)},
+	{"Haraka", regexp.MustCompile(`(?i)\bHaraka\b`)},
+}
+
+// greylistKeywords appear in the text of typical greylisting replies.
+var greylistKeywords = []string{"greylist", "graylist", "try again later", "please retry", "temporarily deferred"}
+
+// BannerOptions configures a banner and greeting behavior analysis.
+type BannerOptions struct {
+	// Host is the SMTP server to analyze
+	Host string
+	// Address is the address to dial; Host is used when empty
+	Address string
+	// Port is the SMTP port (default 25; 465 uses implicit TLS)
+	Port int
+	// MXHost is the MX name the server was reached through, compared with the banner hostname
+	MXHost string
+	// RecipientDomain is the domain whose postmaster is used to probe greylisting; derived from Host when empty
+	RecipientDomain string
+	// GreylistRetry is how long to wait before retrying a temporarily refused recipient; zero disables the retry
+	GreylistRetry time.Duration
+	// SkipEarlyTalker disables the pre-greet (early talker) probe, which needs a second connection
+	SkipEarlyTalker bool
+	// HeloName is the name announced in EHLO
+	HeloName string
+	// Timeout applies to the connection and to each command
+	Timeout time.Duration
+}
+
+// AnalyzeBanner measures how an SMTP server greets and answers a client: the greeting delay,
+// pre-greet (early talker) protection, tarpitting, greylisting, whether the banner hostname
+// matches the PTR and MX names, and whether the banner discloses software versions.
+// The probe sends MAIL FROM:<> and RCPT TO:<postmaster@domain> followed by RSET; DATA is never sent.
+func AnalyzeBanner(ctx context.Context, opts BannerOptions) (*types.BannerAnalysis, error) {
+	if opts.Port == 0 {
+		opts.Port = 25
+	}
+	if opts.Address == "" {
+		opts.Address = opts.Host
+	}
+	if opts.RecipientDomain == "" {
+		opts.RecipientDomain = deriveTargetDomain(opts.Host)
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = 10 * time.Second
+	}
+
+	analysis := &types.BannerAnalysis{
+		Findings: []string{},
+	}
+	address := net.JoinHostPort(opts.Address, strconv.Itoa(opts.Port))
+	implicitTLS := opts.Port == 465
+
+	client, err := DialAddress(ctx, opts.Host, address, opts.Timeout, implicitTLS)
+	if err != nil {
+		analysis.Error = err.Error()
+		return analysis, err
+	}
+	defer client.Close()
+
+	analyzeGreeting(client, analysis)
+	analyzeBannerText(analysis)
+	if addr, ok := client.Conn().RemoteAddr().(*net.TCPAddr); ok {
+		analyzeBannerHostname(ctx, analysis, addr.IP.String(), opts.MXHost)
+	}
+
+	// Command response times reveal tarpitting
+	var timings []time.Duration
+	timed := func(cmd func() (*Reply, error)) (*Reply, error) {
+		start := time.Now()
+		reply, err := cmd()
+		if err == nil {
+			timings = append(timings, time.Since(start))
+		}
+		return reply, err
+	}
+
+	if _, err := timed(func() (*Reply, error) { return client.Hello(opts.HeloName) }); err != nil {
+		analysis.Error = fmt.Sprintf("EHLO failed: %v", err)
+	} else {
+		timed(func() (*Reply, error) { return client.Cmd("NOOP") })
+		probeGreylisting(ctx, client, opts, address, analysis, timed)
+		client.Quit()
+	}
+
+	analyzeTimings(analysis, timings)
+
+	if !opts.SkipEarlyTalker && !implicitTLS {
+		probeEarlyTalker(ctx, opts, address, analysis)
+	}
+
+	return analysis, nil
+}
+
+// analyzeGreeting records the greeting and how long the server took to send it.
+func analyzeGreeting(client *Client, analysis *types.BannerAnalysis) {
+	analysis.Banner = client.Banner.Text()
+	analysis.GreetingCode = client.Banner.Code
+	analysis.MultiLineGreeting = len(client.Banner.Lines) > 1
+
+	// The first server entry of the transcript is the greeting, timed from the end of the connect
+	for _, entry := range client.Transcript {
+		if entry.Direction == DirectionServer {
+			analysis.GreetingDelay = entry.Duration
+			break
+		}
+	}
+
+	if analysis.GreetingDelay >= PreGreetPauseThreshold {
+		analysis.PreGreetPause = true
+		analysis.Findings = append(analysis.Findings, fmt.Sprintf("Greeting delayed by %v (pre-greet pause)", analysis.GreetingDelay.Round(time.Millisecond)))
+	}
+	if analysis.MultiLineGreeting {
+		analysis.Findings = append(analysis.Findings, "Multi-line greeting (often used to catch clients that do not wait for the full greeting)")
+	}
+	if client.Banner.Code != 220 {
+		analysis.Findings = append(analysis.Findings, fmt.Sprintf("Server refused the session in its greeting: %s", client.Banner))
+	}
+}
+
+// analyzeBannerText extracts the banner hostname and looks for software and version disclosure.
+func analyzeBannerText(analysis *types.BannerAnalysis) {
+	if fields := strings.Fields(analysis.Banner); len(fields) > 0 {
+		hostname := strings.TrimSuffix(strings.Trim(fields[0], "[]"), ".")
+		if strings.Contains(hostname, ".") {
+			analysis.BannerHostname = strings.ToLower(hostname)
+		}
+	}
+
+	for _, v := range versionPatterns {
+		if m := v.pattern.FindStringSubmatch(analysis.Banner); m != nil {
+			analysis.Software = v.software
+			analysis.SoftwareVersion = m[1]
+			analysis.LeaksVersion = true
+			break
+		}
+	}
+	if analysis.Software == "" {
+		for _, s := range softwarePatterns {
+			if s.pattern.MatchString(analysis.Banner) {
+				analysis.Software = s.software
+				break
+			}
+		}
+	}
+
+	if analysis.LeaksVersion {
+		software := analysis.Software
+		if software == "" {
+			software = "Software"
+		}
+		analysis.Findings = append(analysis.Findings, fmt.Sprintf("Banner discloses %s version %s", software, analysis.SoftwareVersion))
+	}
+}
+
+// analyzeBannerHostname compares the banner hostname with the PTR names of the server address and the MX name.
+func analyzeBannerHostname(ctx context.Context, analysis *types.BannerAnalysis, ip string, mxHost string) {
+	analysis.ServerIP = ip
+
+	if names, err := net.DefaultResolver.LookupAddr(ctx, ip); err == nil {
+		for _, name := range names {
+			analysis.PTRNames = append(analysis.PTRNames, strings.ToLower(strings.TrimSuffix(name, ".")))
+		}
+	}
+
+	if analysis.BannerHostname == "" {
+		analysis.Findings = append(analysis.Findings, "Banner does not start with a fully qualified hostname")
+		return
+	}
+
+	for _, name := range analysis.PTRNames {
+		if name == analysis.BannerHostname {
+			analysis.MatchesPTR = true
+			break
+		}
+	}
+	if len(analysis.PTRNames) == 0 {
+		analysis.Findings = append(analysis.Findings, fmt.Sprintf("No PTR record for %s", ip))
+	} else if !analysis.MatchesPTR {
+		analysis.Findings = append(analysis.Findings, fmt.Sprintf("Banner hostname %s does not match PTR %s", analysis.BannerHostname, strings.Join(analysis.PTRNames, ", ")))
+	}
+
+	if mxHost != "" {
+		analysis.MatchesMX = strings.EqualFold(strings.TrimSuffix(mxHost, "."), analysis.BannerHostname)
+		if !analysis.MatchesMX {
+			analysis.Findings = append(analysis.Findings, fmt.Sprintf("Banner hostname %s does not match MX name %s", analysis.BannerHostname, mxHost))
+		}
+	}
+}
+
+// analyzeTimings flags tarpitting from the command response times.
+func analyzeTimings(analysis *types.BannerAnalysis, timings []time.Duration) {
+	if len(timings) == 0 {
+		return
+	}
+
+	var total time.Duration
+	for _, t := range timings {
+		total += t
+	}
+	analysis.AverageResponseTime = total / time.Duration(len(timings))
+
+	if analysis.AverageResponseTime >= TarpitThreshold {
+		analysis.Tarpitting = true
+		analysis.Findings = append(analysis.Findings, fmt.Sprintf("Commands answered after %v on average (tarpitting)", analysis.AverageResponseTime.Round(time.Millisecond)))
+	}
+}
+
+// probeGreylisting asks for the postmaster of the recipient domain, which every mail domain must accept.
+// A temporary refusal suggests greylisting; when a retry delay is configured the probe is repeated on a
+// new session and a later acceptance confirms it.
+func probeGreylisting(ctx context.Context, client *Client, opts BannerOptions, address string, analysis *types.BannerAnalysis, timed func(func() (*Reply, error)) (*Reply, error)) {
+	recipient := "postmaster@" + opts.RecipientDomain
+
+	reply, err := timed(func() (*Reply, error) { return client.Mail("") })
+	if err != nil || !reply.Positive() {
+		return
+	}
+	reply, err = timed(func() (*Reply, error) { return client.Rcpt(recipient) })
+	if err != nil {
+		return
+	}
+	client.Reset()
+
+	analysis.RcptCode = reply.Code
+	analysis.RcptResponse = reply.Text()
+	if !reply.Transient() {
+		return
+	}
+
+	text := strings.ToLower(reply.Text())
+	for _, keyword := range greylistKeywords {
+		if strings.Contains(text, keyword) {
+			analysis.GreylistSuspected = true
+			break
+		}
+	}
+	if reply.Code == 450 || reply.Code == 451 {
+		analysis.GreylistSuspected = true
+	}
+
+	if opts.GreylistRetry <= 0 {
+		if analysis.GreylistSuspected {
+			analysis.Findings = append(analysis.Findings, fmt.Sprintf("Temporary refusal of %s suggests greylisting: %s", recipient, reply))
+		}
+		return
+	}
+
+	select {
+	case <-ctx.Done():
+		return
+	case <-time.After(opts.GreylistRetry):
+	}
+
+	retry, err := DialAddress(ctx, opts.Host, address, opts.Timeout, opts.Port == 465)
+	if err != nil {
+		return
+	}
+	defer retry.Close()
+	if _, err := retry.Hello(opts.HeloName); err != nil {
+		return
+	}
+	if reply, err := retry.Mail(""); err != nil || !reply.Positive() {
+		return
+	}
+	reply, err = retry.Rcpt(recipient)
+	retry.Reset()
+	retry.Quit()
+	if err != nil {
+		return
+	}
+
+	analysis.RetryCode = reply.Code
+	if reply.Positive() {
+		analysis.Greylisting = true
+		analysis.Findings = append(analysis.Findings, fmt.Sprintf("Greylisting: %s was refused temporarily and accepted on retry after %v", recipient, opts.GreylistRetry))
+	}
+}
+
+// probeEarlyTalker opens a second session and sends EHLO before the greeting.
+// Servers with pre-greet protection (e.g. Postfix postscreen) reject or drop such clients.
+func probeEarlyTalker(ctx context.Context, opts BannerOptions, address string, analysis *types.BannerAnalysis) {
+	dialer := &net.Dialer{Timeout: opts.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return
+	}
+	client := NewClient(conn, opts.Host, opts.Timeout)
+	defer client.Close()
+
+	name := opts.HeloName
+	if name == "" {
+		name = DefaultHeloName
+	}
+	if err := client.WriteLine("EHLO " + name); err != nil {
+		return
+	}
+	analysis.EarlyTalkerTested = true
+
+	greeting, err := client.ReadReply()
+	switch {
+	case err == io.EOF || (err != nil && !isTimeout(err)):
+		analysis.EarlyTalkerRejected = true
+	case err != nil:
+		return
+	case greeting.Code != 220:
+		analysis.EarlyTalkerRejected = true
+	default:
+		// The greeting came; a protected server refuses the premature command
+		reply, err := client.ReadReply()
+		if err == io.EOF || (err == nil && reply.Code >= 500) {
+			analysis.EarlyTalkerRejected = true
+		}
+	}
+
+	if analysis.EarlyTalkerRejected {
+		analysis.Findings = append(analysis.Findings, "Pre-greet protection: clients talking before the greeting are rejected")
+	}
+}
+
+// isTimeout reports whether an error is a network timeout.
+func isTimeout(err error) bool {
+	netErr, ok := err.(net.Error)
+	return ok && netErr.Timeout()
+}
@@ -0,0 +1,152 @@
+// Package smtp provides SMTP diagnostic functionality.
+package smtp
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"mxclone/pkg/types"
+)
+
+// TestAnalyzeBannerText tests hostname extraction and software version disclosure
+func TestAnalyzeBannerText(t *testing.T) {
+	tests := []struct {
+		banner       string
+		wantHostname string
+		wantSoftware string
+		wantVersion  string
+		wantLeak     bool
+	}{
+		{"mx.example.com ESMTP Postfix", "mx.example.com", "Postfix", "", false},
+		{"mx.example.com ESMTP Postfix 3.4.13 (Debian)", "mx.example.com", "Postfix", "3.4.13", true},
+		{"mail.example.com ESMTP Exim 4.96 Mon, 01 Jan 2024 00:00:00 +0000", "mail.example.com", "Exim", "4.96", true},
+		{"mail.example.com ESMTP Sendmail 8.15.2/8.15.2; Mon, 1 Jan 2024", "mail.example.com", "Sendmail", "8.15.2", true},
+		{"EX01.corp.example.com Microsoft ESMTP MAIL Service, Version: 8.0.1234.5 ready", "ex01.corp.example.com", "Microsoft Exchange", "8.0.1234.5", true},
+		{"smtp.example.com ESMTP MailServer version 2.1.0", "smtp.example.com", "", "2.1.0", true},
+		{"ESMTP ready", "", "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.banner, func(t *testing.T) {
+			analysis := &types.BannerAnalysis{Banner: tt.banner}
+			analyzeBannerText(analysis)
+
+			if analysis.BannerHostname != tt.wantHostname {
+				t.Errorf("BannerHostname = %q, want %q", analysis.BannerHostname, tt.wantHostname)
+			}
+			if analysis.Software != tt.wantSoftware {
+				t.Errorf("Software = %q, want %q", analysis.Software, tt.wantSoftware)
+			}
+			if analysis.SoftwareVersion != tt.wantVersion {
+				t.Errorf("SoftwareVersion = %q, want %q", analysis.SoftwareVersion, tt.wantVersion)
+			}
+			if analysis.LeaksVersion != tt.wantLeak {
+				t.Errorf("LeaksVersion = %v, want %v", analysis.LeaksVersion, tt.wantLeak)
+			}
+		})
+	}
+}
+
+// TestAnalyzeBanner tests greeting behavior detection against local server stand-ins
+func TestAnalyzeBanner(t *testing.T) {
+	// greylister refuses each recipient once, then accepts it
+	greylister := func() func(cmd string) string {
+		var mu sync.Mutex
+		seen := map[string]bool{}
+		return func(cmd string) string {
+			if !strings.HasPrefix(cmd, "RCPT") {
+				return ""
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if seen[cmd] {
+				return "250 2.1.5 OK"
+			}
+			seen[cmd] = true
+			return "450 4.7.1 Greylisted, please retry later"
+		}
+	}
+
+	tests := []struct {
+		name              string
+		banner            string
+		greetDelay        time.Duration
+		rejectEarly       bool
+		respond           func(cmd string) string
+		mxHost            string
+		wantEarlyRejected bool
+		wantGreylisting   bool
+		wantMatchesMX     bool
+		wantLeak          bool
+	}{
+		{
+			name:          "Plain server leaking version",
+			banner:        "220 fake.example.com ESMTP Postfix 3.7.2",
+			mxHost:        "fake.example.com",
+			wantMatchesMX: true,
+			wantLeak:      true,
+		},
+		{
+			name:              "Pre-greet protection",
+			banner:            "220 fake.example.com ESMTP",
+			greetDelay:        200 * time.Millisecond,
+			rejectEarly:       true,
+			mxHost:            "mx.example.com",
+			wantEarlyRejected: true,
+		},
+		{
+			name:            "Greylisting",
+			banner:          "220 fake.example.com ESMTP",
+			respond:         greylister(),
+			wantGreylisting: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeServer(t, tt.respond)
+			server.banner = tt.banner
+			server.greetDelay = tt.greetDelay
+			server.rejectEarlyTalkers = tt.rejectEarly
+			host, port := server.addr()
+
+			analysis, err := AnalyzeBanner(context.Background(), BannerOptions{
+				Host:            host,
+				Port:            port,
+				MXHost:          tt.mxHost,
+				RecipientDomain: "example.com",
+				GreylistRetry:   10 * time.Millisecond,
+				Timeout:         2 * time.Second,
+			})
+			if err != nil {
+				t.Fatalf("AnalyzeBanner() error = %v", err)
+			}
+
+			if !analysis.EarlyTalkerTested {
+				t.Errorf("EarlyTalkerTested = false, want true")
+			}
+			if analysis.EarlyTalkerRejected != tt.wantEarlyRejected {
+				t.Errorf("EarlyTalkerRejected = %v, want %v", analysis.EarlyTalkerRejected, tt.wantEarlyRejected)
+			}
+			if analysis.Greylisting != tt.wantGreylisting {
+				t.Errorf("Greylisting = %v, want %v", analysis.Greylisting, tt.wantGreylisting)
+			}
+			if tt.mxHost != "" && analysis.MatchesMX != tt.wantMatchesMX {
+				t.Errorf("MatchesMX = %v, want %v", analysis.MatchesMX, tt.wantMatchesMX)
+			}
+			if analysis.LeaksVersion != tt.wantLeak {
+				t.Errorf("LeaksVersion = %v, want %v", analysis.LeaksVersion, tt.wantLeak)
+			}
+			if tt.greetDelay > 0 && analysis.GreetingDelay < tt.greetDelay {
+				t.Errorf("GreetingDelay = %v, want at least %v", analysis.GreetingDelay, tt.greetDelay)
+			}
+
+			if countCommands(server.received(), "DATA") != 0 {
+				t.Errorf("banner analysis sent DATA")
+			}
+		})
+	}
+}
@@ -0,0 +1,533 @@
+// Package smtp provides SMTP diagnostic functionality.
+package smtp
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"mxclone/pkg/types"
+)
+
+// DefaultHeloName is the name announced in EHLO/HELO when none is configured.
+const DefaultHeloName = "mxclone.example.com"
+
+// Transcript entry directions
+const (
+	DirectionClient = "client"
+	DirectionServer = "server"
+	DirectionTLS    = "tls"
+	DirectionInfo   = "info"
+)
+
+// redacted replaces credentials in transcripts.
+const redacted = "<redacted>"
+
+// enhancedCodeRegex matches an RFC 3463 enhanced status code at the start of a reply line.
+var enhancedCodeRegex = regexp.MustCompile(`^([245])\.(\d{1,3})\.(\d{1,3})\b`)
+
+// Reply represents a (possibly multi-line) reply from an SMTP server.
+type Reply struct {
+	Code  int
+	Lines []string
+}
+
+// Text returns the reply text with the lines joined by a single space.
+func (r *Reply) Text() string {
+	if r == nil {
+		return ""
+	}
+	return strings.Join(r.Lines, " ")
+}
+
+// String returns the reply formatted as it would appear on the wire (first line only).
+func (r *Reply) String() string {
+	if r == nil {
+		return ""
+	}
+	return fmt.Sprintf("%d %s", r.Code, r.Text())
+}
+
+// EnhancedCode returns the RFC 3463 enhanced status code of the reply, if present.
+func (r *Reply) EnhancedCode() string {
+	if r == nil || len(r.Lines) == 0 {
+		return ""
+	}
+	return enhancedCodeRegex.FindString(r.Lines[0])
+}
+
+// Positive reports whether the reply is a 2xx or 3xx reply.
+func (r *Reply) Positive() bool {
+	return r != nil && r.Code >= 200 && r.Code < 400
+}
+
+// Transient reports whether the reply is a 4xx reply.
+func (r *Reply) Transient() bool {
+	return r != nil && r.Code >= 400 && r.Code < 500
+}
+
+// Permanent reports whether the reply is a 5xx reply.
+func (r *Reply) Permanent() bool {
+	return r != nil && r.Code >= 500
+}
+
+// Client is a minimal SMTP client that keeps every server reply available to the caller.
+// Unlike net/smtp it never hides negative replies behind errors: errors returned by its
+// methods are transport or protocol errors, and the caller decides what a reply code means.
+type Client struct {
+	conn       net.Conn
+	reader     *bufio.Reader
+	host       string
+	timeout    time.Duration
+	tls        bool
+	extensions map[string]string
+	started    time.Time
+	lastSent   time.Time
+
+	// Banner is the greeting sent by the server when the connection was opened
+	Banner *Reply
+	// Transcript records the commands sent, the replies received and the TLS upgrades
+	Transcript []types.TranscriptEntry
+}
+
+// Dial connects to an SMTP server and reads its greeting.
+// When implicitTLS is true the TLS handshake is performed before the greeting (SMTPS, port 465).
+func Dial(ctx context.Context, host string, port int, timeout time.Duration, implicitTLS bool) (*Client, error) {
+	return DialAddress(ctx, host, net.JoinHostPort(host, strconv.Itoa(port)), timeout, implicitTLS)
+}
+
+// DialAddress is like Dial but connects to an explicit address (such as one IP of a host name)
+// while using host for TLS and reporting.
+func DialAddress(ctx context.Context, host, address string, timeout time.Duration, implicitTLS bool) (*Client, error) {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+	}
+
+	started := time.Now()
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	c := NewClient(conn, host, timeout)
+	c.started = started
+	c.record(DirectionInfo, fmt.Sprintf("Connected to %s in %s", conn.RemoteAddr(), time.Since(started).Round(time.Millisecond)))
+
+	if implicitTLS {
+		tlsConn := tls.Client(conn, tlsConfigFor(host))
+		tlsConn.SetDeadline(time.Now().Add(timeout))
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			c.record(DirectionTLS, "TLS handshake failed: "+err.Error())
+			conn.Close()
+			return nil, fmt.Errorf("TLS handshake failed: %w", err)
+		}
+		c.upgraded(tlsConn)
+	}
+	c.lastSent = time.Now()
+
+	banner, err := c.ReadReply()
+	if err != nil {
+		c.Close()
+		return nil, fmt.Errorf("failed to read banner: %w", err)
+	}
+	c.Banner = banner
+
+	return c, nil
+}
+
+// NewClient wraps an already established connection. The greeting is not read.
+func NewClient(conn net.Conn, host string, timeout time.Duration) *Client {
+	now := time.Now()
+	return &Client{
+		conn:       conn,
+		reader:     bufio.NewReader(conn),
+		host:       host,
+		timeout:    timeout,
+		extensions: make(map[string]string),
+		started:    now,
+		lastSent:   now,
+	}
+}
+
+// record appends an entry to the transcript.
+func (c *Client) record(direction, line string) *types.TranscriptEntry {
+	now := time.Now()
+	c.Transcript = append(c.Transcript, types.TranscriptEntry{
+		Timestamp: now,
+		Elapsed:   now.Sub(c.started),
+		Direction: direction,
+		Line:      line,
+	})
+	return &c.Transcript[len(c.Transcript)-1]
+}
+
+// upgraded switches the client to a freshly negotiated TLS connection and records the upgrade.
+func (c *Client) upgraded(tlsConn *tls.Conn) {
+	state := tlsConn.ConnectionState()
+	c.record(DirectionTLS, fmt.Sprintf("TLS handshake completed: %s, %s", tls.VersionName(state.Version), tls.CipherSuiteName(state.CipherSuite)))
+
+	c.conn = tlsConn
+	c.reader = bufio.NewReader(tlsConn)
+	c.tls = true
+	c.extensions = make(map[string]string)
+}
+
+// Note adds an informational entry to the transcript.
+func (c *Client) Note(format string, args ...interface{}) {
+	c.record(DirectionInfo, fmt.Sprintf(format, args...))
+}
+
+// TLSState returns the TLS connection state, if the session is encrypted.
+func (c *Client) TLSState() (tls.ConnectionState, bool) {
+	if tlsConn, ok := c.conn.(*tls.Conn); ok {
+		return tlsConn.ConnectionState(), true
+	}
+	return tls.ConnectionState{}, false
+}
+
+// Conn returns the underlying connection.
+func (c *Client) Conn() net.Conn {
+	return c.conn
+}
+
+// TLS reports whether the session is encrypted.
+func (c *Client) TLS() bool {
+	return c.tls
+}
+
+// Close closes the underlying connection without sending QUIT.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// ReadReply reads a complete, possibly multi-line, reply from the server.
+func (c *Client) ReadReply() (*Reply, error) {
+	return c.ReadReplyWithin(c.timeout)
+}
+
+// ReadReplyWithin is like ReadReply but waits at most wait for the reply.
+// Probes use it to tell a server that answers from one that is still waiting for input.
+func (c *Client) ReadReplyWithin(wait time.Duration) (*Reply, error) {
+	c.conn.SetReadDeadline(time.Now().Add(wait))
+
+	reply := &Reply{}
+	for {
+		line, err := c.reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		entry := c.record(DirectionServer, line)
+		entry.Duration = entry.Timestamp.Sub(c.lastSent)
+
+		if len(line) < 3 {
+			return nil, fmt.Errorf("malformed SMTP reply: %q", line)
+		}
+		code, err := strconv.Atoi(line[:3])
+		if err != nil {
+			return nil, fmt.Errorf("malformed SMTP reply code: %q", line)
+		}
+		if reply.Code != 0 && reply.Code != code {
+			return nil, fmt.Errorf("inconsistent codes in multi-line reply: %d and %d", reply.Code, code)
+		}
+		reply.Code = code
+		entry.Code = code
+
+		text := ""
+		if len(line) > 4 {
+			text = line[4:]
+		}
+		reply.Lines = append(reply.Lines, text)
+		entry.EnhancedCode = enhancedCodeRegex.FindString(text)
+
+		// A space (or nothing) after the code marks the last line of the reply
+		if len(line) == 3 || line[3] != '-' {
+			return reply, nil
+		}
+	}
+}
+
+// WriteLine sends a single command line terminated by CRLF.
+func (c *Client) WriteLine(line string) error {
+	return c.writeLine(line, line)
+}
+
+// writeLine sends a command line and records shown in the transcript in its place,
+// so that credentials never reach the transcript.
+func (c *Client) writeLine(line, shown string) error {
+	c.record(DirectionClient, shown)
+	c.lastSent = time.Now()
+	c.conn.SetWriteDeadline(time.Now().Add(c.timeout))
+	_, err := c.conn.Write([]byte(line + "\r\n"))
+	return err
+}
+
+// WriteRaw sends data exactly as given, without a line terminator, and records shown in the
+// transcript. Probes use it to send non-standard line endings or several commands at once.
+func (c *Client) WriteRaw(data []byte, shown string) error {
+	c.record(DirectionClient, shown)
+	c.lastSent = time.Now()
+	c.conn.SetWriteDeadline(time.Now().Add(c.timeout))
+	_, err := c.conn.Write(data)
+	return err
+}
+
+// sensitiveCmd sends a command whose transcript entry is replaced by shown.
+func (c *Client) sensitiveCmd(line, shown string) (*Reply, error) {
+	if err := c.writeLine(line, shown); err != nil {
+		return nil, err
+	}
+	return c.ReadReply()
+}
+
+// Cmd sends a command and reads the reply.
+func (c *Client) Cmd(format string, args ...interface{}) (*Reply, error) {
+	if err := c.WriteLine(fmt.Sprintf(format, args...)); err != nil {
+		return nil, err
+	}
+	return c.ReadReply()
+}
+
+// Hello sends EHLO and falls back to HELO if the server rejects it.
+// The extensions advertised in the EHLO reply are recorded for Extension.
+func (c *Client) Hello(name string) (*Reply, error) {
+	if name == "" {
+		name = DefaultHeloName
+	}
+
+	reply, err := c.Cmd("EHLO %s", name)
+	if err != nil {
+		return nil, err
+	}
+
+	if reply.Permanent() {
+		return c.Cmd("HELO %s", name)
+	}
+
+	c.extensions = make(map[string]string)
+	if reply.Code == 250 {
+		// The first line is the greeting, the rest are extensions
+		for _, line := range reply.Lines[1:] {
+			keyword, params, _ := strings.Cut(line, " ")
+			c.extensions[strings.ToUpper(keyword)] = params
+		}
+	}
+
+	return reply, nil
+}
+
+// Extension reports whether the server advertised an extension and returns its parameters.
+func (c *Client) Extension(name string) (bool, string) {
+	params, ok := c.extensions[strings.ToUpper(name)]
+	return ok, params
+}
+
+// AuthMechanisms returns the SASL mechanisms advertised by the server.
+func (c *Client) AuthMechanisms() []string {
+	ok, params := c.Extension("AUTH")
+	if !ok {
+		return nil
+	}
+	return strings.Fields(strings.ToUpper(params))
+}
+
+// StartTLS issues STARTTLS and upgrades the connection.
+// The returned reply is the server's answer to STARTTLS; the connection is only upgraded on 220.
+func (c *Client) StartTLS(config *tls.Config) (*Reply, error) {
+	reply, err := c.Cmd("STARTTLS")
+	if err != nil {
+		return nil, err
+	}
+	if reply.Code != 220 {
+		return reply, nil
+	}
+
+	return reply, c.Handshake(config)
+}
+
+// Handshake performs the TLS handshake on the current connection and switches the session to it.
+// StartTLS calls it after a 220 reply; probes that send STARTTLS themselves call it directly.
+func (c *Client) Handshake(config *tls.Config) error {
+	if config == nil {
+		config = tlsConfigFor(c.host)
+	}
+
+	tlsConn := tls.Client(c.conn, config)
+	tlsConn.SetDeadline(time.Now().Add(c.timeout))
+	if err := tlsConn.Handshake(); err != nil {
+		c.record(DirectionTLS, "TLS handshake failed: "+err.Error())
+		return fmt.Errorf("TLS handshake failed: %w", err)
+	}
+	c.upgraded(tlsConn)
+
+	return nil
+}
+
+// Auth authenticates with the first mechanism supported by both sides (PLAIN, then LOGIN).
+func (c *Client) Auth(username, password string) (*Reply, error) {
+	mechanisms := c.AuthMechanisms()
+	switch {
+	case c.supportsAuth("PLAIN"):
+		return c.authPlain(username, password)
+	case c.supportsAuth("LOGIN"):
+		return c.authLogin(username, password)
+	case len(mechanisms) == 0:
+		return nil, fmt.Errorf("server does not advertise AUTH")
+	default:
+		return nil, fmt.Errorf("no supported AUTH mechanism (server offers %s)", strings.Join(mechanisms, ", "))
+	}
+}
+
+// AuthWithMechanism authenticates with a specific SASL mechanism: PLAIN, LOGIN, CRAM-MD5 or XOAUTH2.
+// For XOAUTH2 the secret is the OAuth 2.0 access token.
+func (c *Client) AuthWithMechanism(mechanism, username, secret string) (*Reply, error) {
+	switch strings.ToUpper(mechanism) {
+	case "PLAIN":
+		return c.authPlain(username, secret)
+	case "LOGIN":
+		return c.authLogin(username, secret)
+	case "CRAM-MD5":
+		return c.authCRAMMD5(username, secret)
+	case "XOAUTH2":
+		return c.authXOAuth2(username, secret)
+	default:
+		return nil, fmt.Errorf("unsupported AUTH mechanism: %s", mechanism)
+	}
+}
+
+// supportsAuth reports whether the server advertised a SASL mechanism.
+func (c *Client) supportsAuth(mechanism string) bool {
+	for _, m := range c.AuthMechanisms() {
+		if m == mechanism {
+			return true
+		}
+	}
+	return false
+}
+
+// authPlain performs AUTH PLAIN with an initial response (RFC 4616).
+func (c *Client) authPlain(username, password string) (*Reply, error) {
+	credentials := base64.StdEncoding.EncodeToString([]byte("\x00" + username + "\x00" + password))
+	return c.sensitiveCmd("AUTH PLAIN "+credentials, "AUTH PLAIN "+redacted)
+}
+
+// authLogin performs the AUTH LOGIN challenge/response exchange.
+func (c *Client) authLogin(username, password string) (*Reply, error) {
+	reply, err := c.Cmd("AUTH LOGIN")
+	if err != nil || reply.Code != 334 {
+		return reply, err
+	}
+
+	reply, err = c.sensitiveCmd(base64.StdEncoding.EncodeToString([]byte(username)), redacted)
+	if err != nil || reply.Code != 334 {
+		return reply, err
+	}
+
+	return c.sensitiveCmd(base64.StdEncoding.EncodeToString([]byte(password)), redacted)
+}
+
+// authCRAMMD5 performs the CRAM-MD5 challenge/response exchange (RFC 2195).
+func (c *Client) authCRAMMD5(username, password string) (*Reply, error) {
+	reply, err := c.Cmd("AUTH CRAM-MD5")
+	if err != nil || reply.Code != 334 {
+		return reply, err
+	}
+
+	challenge, err := base64.StdEncoding.DecodeString(reply.Text())
+	if err != nil {
+		return nil, fmt.Errorf("malformed CRAM-MD5 challenge: %w", err)
+	}
+	mac := hmac.New(md5.New, []byte(password))
+	mac.Write(challenge)
+	response := fmt.Sprintf("%s %x", username, mac.Sum(nil))
+
+	return c.sensitiveCmd(base64.StdEncoding.EncodeToString([]byte(response)), redacted)
+}
+
+// authXOAuth2 performs AUTH XOAUTH2 with an OAuth 2.0 bearer token.
+// On failure the server sends a 334 error challenge that must be answered with an empty line.
+func (c *Client) authXOAuth2(username, token string) (*Reply, error) {
+	credentials := base64.StdEncoding.EncodeToString([]byte("user=" + username + "\x01auth=Bearer " + token + "\x01\x01"))
+	reply, err := c.sensitiveCmd("AUTH XOAUTH2 "+credentials, "AUTH XOAUTH2 "+redacted)
+	if err != nil || reply.Code != 334 {
+		return reply, err
+	}
+
+	return c.Cmd("")
+}
+
+// Mail sends MAIL FROM. An empty address sends the null reverse-path.
+func (c *Client) Mail(from string) (*Reply, error) {
+	return c.Cmd("MAIL FROM:<%s>", from)
+}
+
+// Rcpt sends RCPT TO.
+func (c *Client) Rcpt(to string) (*Reply, error) {
+	return c.Cmd("RCPT TO:<%s>", to)
+}
+
+// Reset sends RSET.
+func (c *Client) Reset() (*Reply, error) {
+	return c.Cmd("RSET")
+}
+
+// Data sends DATA followed by the message, which is dot-stuffed and terminated with <CRLF>.<CRLF>.
+// The returned reply is the server's answer to the end of data; a reply to DATA other than 354
+// is returned as is without sending the message.
+func (c *Client) Data(message []byte) (*Reply, error) {
+	reply, err := c.Cmd("DATA")
+	if err != nil || reply.Code != 354 {
+		return reply, err
+	}
+
+	lines := strings.Split(strings.ReplaceAll(string(message), "\r\n", "\n"), "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	var b strings.Builder
+	for _, line := range lines {
+		if strings.HasPrefix(line, ".") {
+			line = "." + line
+		}
+		b.WriteString(line + "\r\n")
+	}
+	b.WriteString(".\r\n")
+
+	c.record(DirectionClient, fmt.Sprintf("<message body: %d lines>", len(lines)))
+	c.record(DirectionClient, ".")
+	c.lastSent = time.Now()
+	c.conn.SetWriteDeadline(time.Now().Add(c.timeout))
+	if _, err := c.conn.Write([]byte(b.String())); err != nil {
+		return nil, err
+	}
+
+	return c.ReadReply()
+}
+
+// Quit sends QUIT and closes the connection.
+func (c *Client) Quit() error {
+	defer c.conn.Close()
+	_, err := c.Cmd("QUIT")
+	return err
+}
+
+// tlsConfigFor returns the TLS configuration used for diagnostics.
+// Certificate problems must not prevent the test from observing the server's behavior,
+// so verification is left to the callers that report on certificates.
+func tlsConfigFor(host string) *tls.Config {
+	return &tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: true,
+		MinVersion:         tls.VersionTLS10,
+	}
+}
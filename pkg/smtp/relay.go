@@ -0,0 +1,321 @@
+// Package smtp provides SMTP diagnostic functionality.
+package smtp
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"mxclone/pkg/emailauth"
+	"mxclone/pkg/types"
+)
+
+// Relay test verdicts
+const (
+	// RelayVerdictRelayed means the server accepted a recipient it should not relay to
+	RelayVerdictRelayed = "relayed"
+	// RelayVerdictRejected means the server permanently refused the probe
+	RelayVerdictRejected = "rejected"
+	// RelayVerdictTempFail means the server answered with a temporary (4xx) failure
+	RelayVerdictTempFail = "tempfail"
+	// RelayVerdictError means the probe could not be completed
+	RelayVerdictError = "error"
+)
+
+// Default addresses used when a relay test is not given explicit ones.
+const (
+	DefaultRelayFromAddress = "test@example.com"
+	DefaultRelayToAddress   = "test@example.org"
+)
+
+// RelayTestOptions configures an open relay test battery.
+type RelayTestOptions struct {
+	// Host is the SMTP server to test
+	Host string
+	// Port is the SMTP port (default 25; 465 uses implicit TLS)
+	Port int
+	// FromAddress is an external sender address
+	FromAddress string
+	// ToAddress is an external recipient the server must not relay to
+	ToAddress string
+	// TargetDomain is the domain the server is responsible for; derived from Host when empty
+	TargetDomain string
+	// Username and Password enable AUTH before the tests when both are set
+	Username string
+	Password string
+	// HeloName is the name announced in EHLO
+	HeloName string
+	// Timeout applies to the connection and to each command
+	Timeout time.Duration
+}
+
+// relayProbe is a single MAIL FROM/RCPT TO combination of the test battery.
+type relayProbe struct {
+	name        string
+	description string
+	mailFrom    string
+	rcptTo      string
+}
+
+// relayProbes builds the classic battery of relay tests.
+// Every probe targets the external recipient, disguised in a different address syntax that
+// badly written or misconfigured MTAs have historically routed off-site.
+func relayProbes(from, to, targetDomain, targetIP string) []relayProbe {
+	user, extDomain, found := strings.Cut(to, "@")
+	if !found {
+		user, extDomain = to, "example.org"
+	}
+
+	probes := []relayProbe{
+		{"unrelated-sender", "External sender to external recipient", from, to},
+		{"null-sender", "Null reverse-path to external recipient", "", to},
+		{"local-sender", "Sender in the target domain to external recipient", "postmaster@" + targetDomain, to},
+		{"percent-hack", "Percent hack routed through the target domain", from, fmt.Sprintf("%s%%%s@%s", user, extDomain, targetDomain)},
+		{"quoted-percent-hack", "Quoted percent hack routed through the target domain", from, fmt.Sprintf("\"%s%%%s\"@%s", user, extDomain, targetDomain)},
+		{"bang-path", "UUCP bang path routed through the target domain", from, fmt.Sprintf("%s!%s@%s", extDomain, user, targetDomain)},
+		{"bang-path-unqualified", "Unqualified UUCP bang path", from, fmt.Sprintf("%s!%s", extDomain, user)},
+		{"quoted-local-part", "External address quoted as a local part", from, fmt.Sprintf("\"%s@%s\"", user, extDomain)},
+		{"quoted-local-part-at-target", "Quoted external address at the target domain", from, fmt.Sprintf("\"%s@%s\"@%s", user, extDomain, targetDomain)},
+		{"double-at", "External address with the target domain appended", from, fmt.Sprintf("%s@%s@%s", user, extDomain, targetDomain)},
+		{"source-route", "Source route through the target domain", from, fmt.Sprintf("@%s:%s@%s", targetDomain, user, extDomain)},
+	}
+
+	// The literal probes repeat the domain probes when the target domain is already the literal
+	if literal := addressLiteral(targetIP); literal != "" && literal != targetDomain {
+		probes = append(probes,
+			relayProbe{"local-sender-literal", "Sender at the server's IP literal to external recipient", "postmaster@" + literal, to},
+			relayProbe{"percent-hack-literal", "Percent hack routed through the server's IP literal", from, fmt.Sprintf("%s%%%s@%s", user, extDomain, literal)},
+			relayProbe{"source-route-literal", "Source route through the server's IP literal", from, fmt.Sprintf("@%s:%s@%s", literal, user, extDomain)},
+		)
+	}
+
+	return probes
+}
+
+// deriveTargetDomain guesses the mail domain served by an MX host: the organizational domain of a
+// host name (mx1.mail.example.co.uk -> example.co.uk), or the address literal of an IP address.
+func deriveTargetDomain(host string) string {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if literal := addressLiteral(host); literal != "" {
+		return literal
+	}
+	return emailauth.OrganizationalDomain(host)
+}
+
+// addressLiteral returns the RFC 5321 address literal of an IP address ([192.0.2.1] or
+// [IPv6:2001:db8::1]), or "" when ip is not an IP address.
+func addressLiteral(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ""
+	}
+	if parsed.To4() != nil {
+		return "[" + parsed.String() + "]"
+	}
+	return "[IPv6:" + parsed.String() + "]"
+}
+
+// requiresAuth reports whether a reply indicates that authentication is needed to relay.
+func requiresAuth(reply *Reply) bool {
+	if reply == nil {
+		return false
+	}
+	if reply.Code == 530 {
+		return true
+	}
+	text := strings.ToLower(reply.Text())
+	return reply.Permanent() && strings.Contains(text, "auth")
+}
+
+// relaySession opens and prepares a session for relay probes: EHLO, STARTTLS when offered, and AUTH when configured.
+func relaySession(ctx context.Context, opts RelayTestOptions) (*Client, bool, error) {
+	client, err := Dial(ctx, opts.Host, opts.Port, opts.Timeout, opts.Port == 465)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if _, err := client.Hello(opts.HeloName); err != nil {
+		client.Close()
+		return nil, false, fmt.Errorf("EHLO failed: %w", err)
+	}
+
+	if ok, _ := client.Extension("STARTTLS"); ok && !client.TLS() {
+		if reply, err := client.StartTLS(nil); err == nil && reply.Code == 220 {
+			if _, err := client.Hello(opts.HeloName); err != nil {
+				client.Close()
+				return nil, false, fmt.Errorf("EHLO after STARTTLS failed: %w", err)
+			}
+		} else if err != nil {
+			// A failed handshake leaves the connection unusable; continue in plaintext on a new one
+			client.Close()
+			client, err = Dial(ctx, opts.Host, opts.Port, opts.Timeout, false)
+			if err != nil {
+				return nil, false, err
+			}
+			if _, err := client.Hello(opts.HeloName); err != nil {
+				client.Close()
+				return nil, false, fmt.Errorf("EHLO failed: %w", err)
+			}
+		}
+	}
+
+	authenticated := false
+	if opts.Username != "" && opts.Password != "" {
+		reply, err := client.Auth(opts.Username, opts.Password)
+		if err != nil {
+			client.Close()
+			return nil, false, fmt.Errorf("AUTH failed: %w", err)
+		}
+		if reply.Code != 235 {
+			client.Close()
+			return nil, false, fmt.Errorf("AUTH rejected: %s", reply)
+		}
+		authenticated = true
+	}
+
+	return client, authenticated, nil
+}
+
+// RunRelayTests runs the open relay test battery against an SMTP server.
+// Each probe is a MAIL FROM/RCPT TO pair followed by RSET; DATA is never sent, so no mail is delivered.
+func RunRelayTests(ctx context.Context, opts RelayTestOptions) (*types.RelayTestReport, error) {
+	if opts.Port == 0 {
+		opts.Port = 25
+	}
+	if opts.FromAddress == "" {
+		opts.FromAddress = DefaultRelayFromAddress
+	}
+	if opts.ToAddress == "" {
+		opts.ToAddress = DefaultRelayToAddress
+	}
+	if opts.TargetDomain == "" {
+		opts.TargetDomain = deriveTargetDomain(opts.Host)
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = 10 * time.Second
+	}
+
+	report := &types.RelayTestReport{
+		Host:  opts.Host,
+		Port:  opts.Port,
+		Tests: []types.RelayTest{},
+	}
+
+	client, authenticated, err := relaySession(ctx, opts)
+	if err != nil {
+		report.Error = err.Error()
+		return report, err
+	}
+	report.Authenticated = authenticated
+	report.Banner = client.Banner.Text()
+
+	targetIP := ""
+	if addr, ok := client.Conn().RemoteAddr().(*net.TCPAddr); ok {
+		targetIP = addr.IP.String()
+	}
+
+	for _, probe := range relayProbes(opts.FromAddress, opts.ToAddress, opts.TargetDomain, targetIP) {
+		if ctx.Err() != nil {
+			break
+		}
+
+		// Reconnect if the server dropped the previous session
+		if client == nil {
+			client, _, err = relaySession(ctx, opts)
+			if err != nil {
+				report.Tests = append(report.Tests, types.RelayTest{
+					Name:        probe.name,
+					Description: probe.description,
+					MailFrom:    probe.mailFrom,
+					RcptTo:      probe.rcptTo,
+					Verdict:     RelayVerdictError,
+					Error:       err.Error(),
+				})
+				continue
+			}
+		}
+
+		test, alive := runRelayProbe(client, probe)
+		if requiresAuth(&Reply{Code: test.ResponseCode, Lines: []string{test.Response}}) {
+			report.AuthRequired = true
+		}
+		report.Tests = append(report.Tests, test)
+
+		if !alive {
+			client.Close()
+			client = nil
+		}
+	}
+
+	if client != nil {
+		client.Quit()
+	}
+
+	// Relaying while authenticated is expected behavior, not an open relay
+	if !authenticated {
+		for _, test := range report.Tests {
+			if test.Accepted {
+				report.IsOpenRelay = true
+				break
+			}
+		}
+	}
+
+	return report, nil
+}
+
+// runRelayProbe runs a single probe on an open session.
+// It reports whether the session is still usable for the next probe.
+func runRelayProbe(client *Client, probe relayProbe) (test types.RelayTest, alive bool) {
+	test = types.RelayTest{
+		Name:        probe.name,
+		Description: probe.description,
+		MailFrom:    probe.mailFrom,
+		RcptTo:      probe.rcptTo,
+	}
+	start := len(client.Transcript)
+	defer func() {
+		test.Transcript = append([]types.TranscriptEntry(nil), client.Transcript[start:]...)
+	}()
+
+	reply, err := client.Mail(probe.mailFrom)
+	if err != nil {
+		test.Verdict = RelayVerdictError
+		test.Error = fmt.Sprintf("MAIL FROM failed: %v", err)
+		return test, false
+	}
+
+	if reply.Positive() {
+		reply, err = client.Rcpt(probe.rcptTo)
+		if err != nil {
+			test.Verdict = RelayVerdictError
+			test.Error = fmt.Sprintf("RCPT TO failed: %v", err)
+			return test, false
+		}
+	}
+
+	test.ResponseCode = reply.Code
+	test.Response = reply.Text()
+	switch {
+	case reply.Positive():
+		test.Accepted = true
+		test.Verdict = RelayVerdictRelayed
+	case reply.Transient():
+		test.Verdict = RelayVerdictTempFail
+	default:
+		test.Verdict = RelayVerdictRejected
+	}
+
+	// 421 means the server is closing the channel
+	if reply.Code == 421 {
+		return test, false
+	}
+
+	if _, err := client.Reset(); err != nil {
+		return test, false
+	}
+
+	return test, true
+}
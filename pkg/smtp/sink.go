@@ -0,0 +1,670 @@
+// Package smtp provides SMTP diagnostic functionality.
+package smtp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"mxclone/pkg/types"
+)
+
+// Sink defaults
+const (
+	// DefaultSinkAddress is the address the sink listens on when none is given
+	DefaultSinkAddress = ":2525"
+	// DefaultSinkMaxMessageSize is the largest message the sink accepts, in bytes
+	DefaultSinkMaxMessageSize = 10 << 20
+	// DefaultSinkMaxSessions is the number of sessions the sink serves at the same time
+	DefaultSinkMaxSessions = 100
+	// DefaultSinkTestTTL is how long a test address receives and keeps messages
+	DefaultSinkTestTTL = 24 * time.Hour
+	// DefaultSinkMaxTestMessages is the number of messages kept for one test
+	DefaultSinkMaxTestMessages = 20
+	// sinkMaxTests limits the tests kept at the same time
+	sinkMaxTests = 10000
+	// sinkTestPrefix starts the local part of generated test addresses
+	sinkTestPrefix = "mxclone-"
+	// sinkMaxRecipients limits the recipients of one transaction
+	sinkMaxRecipients = 100
+	// sinkMaxErrors is the number of bad commands after which a session is closed
+	sinkMaxErrors = 10
+	// sinkMaxCommandLine is the longest command line including CRLF (RFC 5321 section 4.5.3.1.4)
+	sinkMaxCommandLine = 512
+)
+
+// ErrSinkClosed is returned when a closed sink is used.
+var ErrSinkClosed = errors.New("the SMTP sink is not running")
+
+// ErrSinkFull is returned when the sink already keeps the largest number of tests.
+var ErrSinkFull = errors.New("the SMTP sink has too many active tests, try again later")
+
+// SinkOptions configures an SMTP sink.
+type SinkOptions struct {
+	// Address is the address to listen on (default DefaultSinkAddress)
+	Address string
+	// Domain is the domain of generated test addresses; it must route to the sink (default Hostname)
+	Domain string
+	// Hostname is the name announced in the greeting (default the system hostname)
+	Hostname string
+	// TLSConfig enables STARTTLS; a self-signed certificate for Hostname is generated when nil
+	TLSConfig *tls.Config
+	// MaxMessageSize is the largest accepted message in bytes (default DefaultSinkMaxMessageSize)
+	MaxMessageSize int
+	// MaxSessions is the number of sessions served at the same time; further connections are
+	// turned away with a 421 reply (default DefaultSinkMaxSessions)
+	MaxSessions int
+	// Timeout applies to each command of a session (default 5 minutes)
+	Timeout time.Duration
+	// TestTTL is how long a test exists after it was created; its messages are then discarded
+	// (default DefaultSinkTestTTL)
+	TestTTL time.Duration
+	// MaxTestMessages is the number of messages kept for a test; further messages are refused
+	// (default DefaultSinkMaxTestMessages)
+	MaxTestMessages int
+	// Analyze is called for each received message before it is stored, to attach its authentication
+	// report. The raw message is not kept once it has been analyzed.
+	Analyze func(ctx context.Context, message *types.SinkMessage)
+}
+
+// Sink is a receive-only SMTP server that accepts mail for generated test addresses.
+type Sink struct {
+	opts     SinkOptions
+	listener net.Listener
+
+	mu       sync.Mutex
+	tests    map[string]*sinkTest
+	closed   bool
+	sessions sync.WaitGroup
+	slots    chan struct{}
+	ctx      context.Context
+	cancel   context.CancelFunc
+}
+
+// sinkTest is a generated test address and the messages it received.
+type sinkTest struct {
+	expires  time.Time
+	messages []*types.SinkMessage
+}
+
+// NewSink creates a sink; Start begins accepting connections.
+func NewSink(opts SinkOptions) (*Sink, error) {
+	if opts.Address == "" {
+		opts.Address = DefaultSinkAddress
+	}
+	if opts.Hostname == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			hostname = "localhost"
+		}
+		opts.Hostname = hostname
+	}
+	if opts.Domain == "" {
+		opts.Domain = opts.Hostname
+	}
+	opts.Domain = strings.ToLower(strings.TrimSuffix(opts.Domain, "."))
+	if opts.MaxMessageSize <= 0 {
+		opts.MaxMessageSize = DefaultSinkMaxMessageSize
+	}
+	if opts.MaxSessions <= 0 {
+		opts.MaxSessions = DefaultSinkMaxSessions
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 5 * time.Minute
+	}
+	if opts.TestTTL <= 0 {
+		opts.TestTTL = DefaultSinkTestTTL
+	}
+	if opts.MaxTestMessages <= 0 {
+		opts.MaxTestMessages = DefaultSinkMaxTestMessages
+	}
+	if opts.TLSConfig == nil {
+		cert, err := SelfSignedCertificate(opts.Hostname)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate a certificate: %w", err)
+		}
+		opts.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Sink{
+		opts:   opts,
+		tests:  make(map[string]*sinkTest),
+		slots:  make(chan struct{}, opts.MaxSessions),
+		ctx:    ctx,
+		cancel: cancel,
+	}, nil
+}
+
+// Start listens on the configured address and serves sessions in the background.
+func (s *Sink) Start() error {
+	listener, err := net.Listen("tcp", s.opts.Address)
+	if err != nil {
+		return err
+	}
+	s.listener = listener
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			select {
+			case s.slots <- struct{}{}:
+			default:
+				// Busy: refuse without reading, so idle connections cannot pile up goroutines
+				conn.SetWriteDeadline(time.Now().Add(time.Second))
+				fmt.Fprintf(conn, "421 4.3.2 %s Too many connections, try again later\r\n", s.opts.Hostname)
+				conn.Close()
+				continue
+			}
+			s.sessions.Add(1)
+			go func() {
+				defer s.sessions.Done()
+				defer func() { <-s.slots }()
+				s.serve(conn)
+			}()
+		}
+	}()
+
+	return nil
+}
+
+// Addr returns the address the sink listens on.
+func (s *Sink) Addr() string {
+	if s.listener == nil {
+		return s.opts.Address
+	}
+	return s.listener.Addr().String()
+}
+
+// Domain returns the domain of the generated test addresses.
+func (s *Sink) Domain() string {
+	return s.opts.Domain
+}
+
+// Close stops accepting connections and waits for open sessions to end.
+func (s *Sink) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.mu.Unlock()
+
+	s.cancel()
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	s.sessions.Wait()
+	return err
+}
+
+// NewTest registers a test and returns its ID and the address that receives its messages.
+func (s *Sink) NewTest() (string, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return "", "", ErrSinkClosed
+	}
+
+	now := time.Now()
+	for id, test := range s.tests {
+		if now.After(test.expires) {
+			delete(s.tests, id)
+		}
+	}
+	if len(s.tests) >= sinkMaxTests {
+		return "", "", ErrSinkFull
+	}
+
+	id := strings.ToLower(newTrackingID())
+	s.tests[id] = &sinkTest{expires: now.Add(s.opts.TestTTL)}
+	return id, s.TestAddress(id), nil
+}
+
+// TestAddress returns the address that receives the messages of a test.
+func (s *Sink) TestAddress(id string) string {
+	return sinkTestPrefix + strings.ToLower(id) + "@" + s.opts.Domain
+}
+
+// Messages returns the messages received for a test, and whether the test exists. A test no
+// longer exists once its TTL has passed.
+func (s *Sink) Messages(id string) ([]*types.SinkMessage, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	test := s.test(strings.ToLower(id))
+	if test == nil {
+		return nil, false
+	}
+	return append([]*types.SinkMessage(nil), test.messages...), true
+}
+
+// test returns a test that has not expired, removing it once it has; the caller holds mu.
+func (s *Sink) test(id string) *sinkTest {
+	test, ok := s.tests[id]
+	if !ok {
+		return nil
+	}
+	if time.Now().After(test.expires) {
+		delete(s.tests, id)
+		return nil
+	}
+	return test
+}
+
+// testForRecipient returns the test a recipient address belongs to, whether that test exists
+// and whether it already holds MaxTestMessages messages.
+func (s *Sink) testForRecipient(address string) (id string, ok, full bool) {
+	at := strings.LastIndexByte(address, '@')
+	if at < 0 || !strings.EqualFold(address[at+1:], s.opts.Domain) {
+		return "", false, false
+	}
+	local := strings.ToLower(address[:at])
+	if !strings.HasPrefix(local, sinkTestPrefix) {
+		return "", false, false
+	}
+	id = strings.TrimPrefix(local, sinkTestPrefix)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	test := s.test(id)
+	if test == nil {
+		return id, false, false
+	}
+	return id, true, len(test.messages) >= s.opts.MaxTestMessages
+}
+
+// store records a message under every test it was addressed to that still exists and has room.
+func (s *Sink) store(testIDs []string, message *types.SinkMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range testIDs {
+		test := s.test(id)
+		if test == nil || len(test.messages) >= s.opts.MaxTestMessages {
+			continue
+		}
+		copied := *message
+		copied.TestID = id
+		test.messages = append(test.messages, &copied)
+	}
+}
+
+// sinkSession is the state of one SMTP session.
+type sinkSession struct {
+	sink   *Sink
+	conn   net.Conn
+	reader *bufio.Reader
+	writer *bufio.Writer
+
+	helo       string
+	tls        bool
+	tlsVersion string
+	mailFrom   string
+	inMail     bool
+	recipients []string
+	testIDs    []string
+	errors     int
+}
+
+// serve runs one SMTP session.
+func (s *Sink) serve(conn net.Conn) {
+	defer conn.Close()
+
+	// Closing the sink interrupts sessions waiting for input
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-s.ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	session := &sinkSession{
+		sink:   s,
+		conn:   conn,
+		reader: bufio.NewReader(conn),
+		writer: bufio.NewWriter(conn),
+	}
+	session.reply("220 %s ESMTP mxclone test sink", s.opts.Hostname)
+
+	for {
+		session.conn.SetReadDeadline(time.Now().Add(s.opts.Timeout))
+		line, tooLong, err := session.readLine(sinkMaxCommandLine)
+		if err != nil {
+			return
+		}
+		if tooLong {
+			session.errors++
+			session.reply("500 5.5.2 Line too long")
+		} else if !session.handle(strings.TrimRight(string(line), "\r\n")) {
+			return
+		}
+		if session.errors >= sinkMaxErrors {
+			session.reply("421 4.7.0 %s Too many errors", s.opts.Hostname)
+			return
+		}
+	}
+}
+
+// readLine reads a line of at most limit bytes including the line ending. A longer line is read
+// to its end without being kept and reported as too long.
+func (ss *sinkSession) readLine(limit int) (line []byte, tooLong bool, err error) {
+	for {
+		chunk, err := ss.reader.ReadSlice('\n')
+		if !tooLong && len(line)+len(chunk) > limit {
+			tooLong = true
+			line = nil
+		}
+		if !tooLong {
+			line = append(line, chunk...)
+		}
+		if err != bufio.ErrBufferFull {
+			return line, tooLong, err
+		}
+	}
+}
+
+// reply writes a reply line and flushes it.
+func (ss *sinkSession) reply(format string, args ...interface{}) {
+	ss.conn.SetWriteDeadline(time.Now().Add(ss.sink.opts.Timeout))
+	fmt.Fprintf(ss.writer, format+"\r\n", args...)
+	ss.writer.Flush()
+}
+
+// reset ends the current mail transaction.
+func (ss *sinkSession) reset() {
+	ss.mailFrom = ""
+	ss.inMail = false
+	ss.recipients = nil
+	ss.testIDs = nil
+}
+
+// handle processes one command and reports whether the session continues.
+func (ss *sinkSession) handle(line string) bool {
+	verb, arg, _ := strings.Cut(line, " ")
+	verb = strings.ToUpper(verb)
+	opts := ss.sink.opts
+
+	switch verb {
+	case "EHLO":
+		if arg == "" {
+			ss.errors++
+			ss.reply("501 5.5.4 EHLO requires a domain")
+			return true
+		}
+		ss.helo = arg
+		ss.reset()
+		lines := []string{opts.Hostname, fmt.Sprintf("SIZE %d", opts.MaxMessageSize), "8BITMIME", "ENHANCEDSTATUSCODES", "PIPELINING"}
+		if !ss.tls {
+			lines = append(lines, "STARTTLS")
+		}
+		for i, l := range lines {
+			sep := "-"
+			if i == len(lines)-1 {
+				sep = " "
+			}
+			ss.reply("250%s%s", sep, l)
+		}
+
+	case "HELO":
+		if arg == "" {
+			ss.errors++
+			ss.reply("501 5.5.4 HELO requires a domain")
+			return true
+		}
+		ss.helo = arg
+		ss.reset()
+		ss.reply("250 %s", opts.Hostname)
+
+	case "STARTTLS":
+		if ss.tls {
+			ss.reply("503 5.5.1 TLS already active")
+			return true
+		}
+		if ss.reader.Buffered() > 0 {
+			// Input pipelined after STARTTLS is refused rather than carried into the TLS session
+			ss.reply("501 5.5.2 Pipelining after STARTTLS is not allowed")
+			return false
+		}
+		ss.reply("220 2.0.0 Ready to start TLS")
+		tlsConn := tls.Server(ss.conn, opts.TLSConfig)
+		tlsConn.SetDeadline(time.Now().Add(opts.Timeout))
+		if err := tlsConn.Handshake(); err != nil {
+			return false
+		}
+		state := tlsConn.ConnectionState()
+		ss.conn = tlsConn
+		ss.reader = bufio.NewReader(tlsConn)
+		ss.writer = bufio.NewWriter(tlsConn)
+		ss.tls = true
+		ss.tlsVersion = tls.VersionName(state.Version)
+		ss.helo = ""
+		ss.reset()
+
+	case "MAIL":
+		if ss.helo == "" {
+			ss.errors++
+			ss.reply("503 5.5.1 Send EHLO first")
+			return true
+		}
+		if ss.inMail {
+			ss.errors++
+			ss.reply("503 5.5.1 Sender already given")
+			return true
+		}
+		address, ok := pathArgument(arg, "FROM:")
+		if !ok {
+			ss.errors++
+			ss.reply("501 5.5.4 Syntax: MAIL FROM:<address>")
+			return true
+		}
+		ss.mailFrom = address
+		ss.inMail = true
+		ss.reply("250 2.1.0 Sender OK")
+
+	case "RCPT":
+		if !ss.inMail {
+			ss.errors++
+			ss.reply("503 5.5.1 Send MAIL first")
+			return true
+		}
+		address, ok := pathArgument(arg, "TO:")
+		if !ok || address == "" {
+			ss.errors++
+			ss.reply("501 5.5.4 Syntax: RCPT TO:<address>")
+			return true
+		}
+		if len(ss.recipients) >= sinkMaxRecipients {
+			ss.reply("452 4.5.3 Too many recipients")
+			return true
+		}
+		id, ok, full := ss.sink.testForRecipient(address)
+		if !ok {
+			ss.errors++
+			ss.reply("550 5.1.1 <%s>: Not a test address of this sink", address)
+			return true
+		}
+		if full {
+			ss.reply("452 4.2.2 <%s>: The test has received its %d messages", address, opts.MaxTestMessages)
+			return true
+		}
+		ss.recipients = append(ss.recipients, address)
+		// A test addressed twice in one transaction receives the message once
+		if !slices.Contains(ss.testIDs, id) {
+			ss.testIDs = append(ss.testIDs, id)
+		}
+		ss.reply("250 2.1.5 Recipient OK")
+
+	case "DATA":
+		if len(ss.recipients) == 0 {
+			ss.errors++
+			ss.reply("503 5.5.1 Send RCPT first")
+			return true
+		}
+		ss.reply("354 End data with <CR><LF>.<CR><LF>")
+		return ss.receiveData()
+
+	case "RSET":
+		ss.reset()
+		ss.reply("250 2.0.0 OK")
+
+	case "NOOP":
+		ss.reply("250 2.0.0 OK")
+
+	case "VRFY":
+		ss.reply("252 2.5.2 Cannot verify the user")
+
+	case "QUIT":
+		ss.reply("221 2.0.0 %s Bye", opts.Hostname)
+		return false
+
+	default:
+		ss.errors++
+		ss.reply("502 5.5.2 Command not recognized")
+	}
+
+	return true
+}
+
+// receiveData reads a dot-terminated message, stores it and reports whether the session continues.
+func (ss *sinkSession) receiveData() bool {
+	opts := ss.sink.opts
+	var data bytes.Buffer
+	tooLarge := false
+
+	for {
+		ss.conn.SetReadDeadline(time.Now().Add(opts.Timeout))
+		// Only what still fits is kept; the rest of an oversized message is read and dropped
+		// until the end of data. One more byte allows for a dot-stuffed line.
+		limit := opts.MaxMessageSize - data.Len() + 1
+		if tooLarge {
+			limit = 0
+		}
+		line, long, err := ss.readLine(max(limit, len(".\r\n")))
+		if err != nil {
+			return false
+		}
+		if string(line) == ".\r\n" || string(line) == ".\n" {
+			break
+		}
+		line = bytes.TrimPrefix(line, []byte("."))
+		if long || tooLarge || data.Len()+len(line) > opts.MaxMessageSize {
+			tooLarge = true
+			data.Reset()
+			continue
+		}
+		data.Write(line)
+	}
+
+	if tooLarge {
+		ss.reset()
+		ss.reply("552 5.3.4 Message exceeds the size limit of %d bytes", opts.MaxMessageSize)
+		return true
+	}
+
+	message := &types.SinkMessage{
+		ReceivedAt: time.Now(),
+		ClientIP:   remoteIP(ss.conn),
+		Helo:       ss.helo,
+		MailFrom:   ss.mailFrom,
+		Recipients: ss.recipients,
+		TLS:        ss.tls,
+		TLSVersion: ss.tlsVersion,
+		Size:       data.Len(),
+		Raw:        data.Bytes(),
+	}
+	testIDs := ss.testIDs
+	ss.reset()
+	ss.reply("250 2.0.0 Message accepted for analysis")
+
+	if opts.Analyze != nil {
+		opts.Analyze(ss.sink.ctx, message)
+		// Only the report is kept
+		message.Raw = nil
+	}
+	ss.sink.store(testIDs, message)
+	return true
+}
+
+// pathArgument extracts the address of a "FROM:<address>" or "TO:<address>" argument; ESMTP parameters are ignored.
+func pathArgument(arg, prefix string) (string, bool) {
+	if len(arg) < len(prefix) || !strings.EqualFold(arg[:len(prefix)], prefix) {
+		return "", false
+	}
+	path := strings.TrimSpace(arg[len(prefix):])
+	if !strings.HasPrefix(path, "<") {
+		return "", false
+	}
+	end := strings.IndexByte(path, '>')
+	if end < 0 {
+		return "", false
+	}
+	return path[1:end], true
+}
+
+// remoteIP returns the IP address of the peer of a connection.
+func remoteIP(conn net.Conn) string {
+	host, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+	if err != nil {
+		return conn.RemoteAddr().String()
+	}
+	return host
+}
+
+// SelfSignedCertificate generates a self-signed ECDSA certificate for the given host names, valid for one year.
+func SelfSignedCertificate(hosts ...string) (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{Organization: []string{"mxclone"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().AddDate(1, 0, 0),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else if host != "" {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+	if len(template.DNSNames) > 0 {
+		template.Subject.CommonName = template.DNSNames[0]
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
@@ -0,0 +1,270 @@
+// Package smtp provides SMTP diagnostic functionality.
+package smtp
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"mxclone/pkg/types"
+)
+
+// startTestSink starts a sink on a local port and stops it at the end of the test.
+func startTestSink(t *testing.T, analyze func(ctx context.Context, message *types.SinkMessage)) *Sink {
+	t.Helper()
+	sink, err := NewSink(SinkOptions{
+		Address:  "127.0.0.1:0",
+		Domain:   "sink.example",
+		Hostname: "sink.example",
+		Timeout:  2 * time.Second,
+		Analyze:  analyze,
+	})
+	if err != nil {
+		t.Fatalf("NewSink() error = %v", err)
+	}
+	if err := sink.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	t.Cleanup(func() { sink.Close() })
+	return sink
+}
+
+// TestSinkReceivesTestMessages tests delivery over STARTTLS to a generated test address
+func TestSinkReceivesTestMessages(t *testing.T) {
+	analyzed := 0
+	var raw string
+	sink := startTestSink(t, func(ctx context.Context, message *types.SinkMessage) {
+		analyzed++
+		raw = string(message.Raw)
+		message.Auth = &types.MessageAuthReport{}
+	})
+	id, address, err := sink.NewTest()
+	if err != nil {
+		t.Fatalf("NewTest() error = %v", err)
+	}
+	if !strings.HasSuffix(address, "@sink.example") {
+		t.Errorf("address = %q, want a sink.example address", address)
+	}
+
+	client, err := DialAddress(context.Background(), "sink.example", sink.Addr(), 2*time.Second, false)
+	if err != nil {
+		t.Fatalf("DialAddress() error = %v", err)
+	}
+	defer client.Close()
+
+	if _, err := client.Hello("sender.example"); err != nil {
+		t.Fatalf("Hello() error = %v", err)
+	}
+	if reply, err := client.StartTLS(nil); err != nil || reply.Code != 220 {
+		t.Fatalf("StartTLS() = %v, %v", reply, err)
+	}
+	if _, err := client.Hello("sender.example"); err != nil {
+		t.Fatalf("Hello() after STARTTLS error = %v", err)
+	}
+	if reply, _ := client.Mail("bounce@sender.example"); !reply.Positive() {
+		t.Fatalf("MAIL FROM refused: %v", reply)
+	}
+	if reply, _ := client.Rcpt("someone@sink.example"); reply.Code != 550 {
+		t.Errorf("RCPT TO for an unknown address = %v, want 550", reply)
+	}
+	if reply, _ := client.Rcpt(address); !reply.Positive() {
+		t.Fatalf("RCPT TO for the test address refused: %v", reply)
+	}
+	message := "From: <a@sender.example>\r\nSubject: test\r\n\r\n.leading dot\r\nbody\r\n"
+	if reply, err := client.Data([]byte(message)); err != nil || !reply.Positive() {
+		t.Fatalf("Data() = %v, %v", reply, err)
+	}
+	client.Quit()
+
+	messages, ok := sink.Messages(id)
+	if !ok || len(messages) != 1 {
+		t.Fatalf("Messages() = %d messages, %v; want 1, true", len(messages), ok)
+	}
+	got := messages[0]
+	if got.TestID != id || got.MailFrom != "bounce@sender.example" || got.Helo != "sender.example" {
+		t.Errorf("message = %+v", got)
+	}
+	if !got.TLS || got.ClientIP != "127.0.0.1" {
+		t.Errorf("TLS = %v, ClientIP = %q; want true, 127.0.0.1", got.TLS, got.ClientIP)
+	}
+	if !strings.Contains(raw, "\r\n.leading dot\r\n") {
+		t.Errorf("dot-stuffing was not undone: %q", raw)
+	}
+	if analyzed != 1 || got.Auth == nil {
+		t.Errorf("Analyze called %d times, Auth = %v", analyzed, got.Auth)
+	}
+	if got.Raw != nil || got.Size != len(raw) {
+		t.Errorf("Raw = %d bytes, Size = %d; want the raw message dropped after analysis", len(got.Raw), got.Size)
+	}
+
+	if _, ok := sink.Messages("unknown"); ok {
+		t.Errorf("Messages(unknown) reported an existing test")
+	}
+}
+
+// TestSinkRefusesPipelinedSTARTTLS tests that input pipelined after STARTTLS is not carried into TLS
+func TestSinkRefusesPipelinedSTARTTLS(t *testing.T) {
+	sink := startTestSink(t, nil)
+	tcpAddr := sink.listener.Addr().(*net.TCPAddr)
+	host, port := tcpAddr.IP.String(), tcpAddr.Port
+
+	report, err := CheckSTARTTLSSecurity(context.Background(), STARTTLSSecurityOptions{
+		Host:    host,
+		Port:    port,
+		Wait:    200 * time.Millisecond,
+		Timeout: 2 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("CheckSTARTTLSSecurity() error = %v", err)
+	}
+	if !report.HandshakeSucceeded || report.InjectionVulnerable {
+		t.Errorf("HandshakeSucceeded = %v, InjectionVulnerable = %v; want true, false", report.HandshakeSucceeded, report.InjectionVulnerable)
+	}
+}
+
+// TestSinkBoundsInput tests that overlong command lines, oversized messages and connections past
+// the session limit are refused without buffering them
+func TestSinkBoundsInput(t *testing.T) {
+	sink, err := NewSink(SinkOptions{
+		Address:        "127.0.0.1:0",
+		Domain:         "sink.example",
+		Hostname:       "sink.example",
+		MaxMessageSize: 1024,
+		MaxSessions:    1,
+		Timeout:        2 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("NewSink() error = %v", err)
+	}
+	if err := sink.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer sink.Close()
+	_, address, _ := sink.NewTest()
+
+	conn, err := net.Dial("tcp", sink.Addr())
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+	reader := bufio.NewReader(conn)
+	expect := func(command, code string) {
+		t.Helper()
+		if command != "" {
+			fmt.Fprint(conn, command)
+		}
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				t.Fatalf("after %.40q: %v", command, err)
+			}
+			if len(line) < 4 || line[3] != '-' {
+				if !strings.HasPrefix(line, code) {
+					t.Fatalf("after %.40q: reply %q, want %s", command, line, code)
+				}
+				return
+			}
+		}
+	}
+
+	expect("", "220")
+	// The session slot is taken: a second connection is turned away
+	second, err := net.Dial("tcp", sink.Addr())
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	second.SetDeadline(time.Now().Add(2 * time.Second))
+	if line, _ := bufio.NewReader(second).ReadString('\n'); !strings.HasPrefix(line, "421") {
+		t.Errorf("second session greeting = %q, want 421", line)
+	}
+	second.Close()
+
+	expect("EHLO "+strings.Repeat("a", 100000)+"\r\n", "500")
+	expect("EHLO sender.example\r\n", "250")
+	expect("MAIL FROM:<a@sender.example>\r\n", "250")
+	expect("RCPT TO:<"+address+">\r\n", "250")
+	expect("DATA\r\n", "354")
+	expect("Subject: big\r\n\r\n"+strings.Repeat("x", 100000)+"\r\n.\r\n", "552")
+	expect("MAIL FROM:<a@sender.example>\r\n", "250")
+	expect("RCPT TO:<"+address+">\r\n", "250")
+	expect("DATA\r\n", "354")
+	expect("Subject: small\r\n\r\n"+strings.Repeat("x", 1010)+"\r\n.\r\n", "552")
+	expect("MAIL FROM:<a@sender.example>\r\n", "250")
+	expect("RCPT TO:<"+address+">\r\n", "250")
+	expect("DATA\r\n", "354")
+	expect("Subject: small\r\n\r\nfits\r\n.\r\n", "250")
+	expect("QUIT\r\n", "221")
+}
+
+// TestSinkTestLimits tests that a test receives each message once, keeps a bounded number of
+// messages and expires after its TTL
+func TestSinkTestLimits(t *testing.T) {
+	sink, err := NewSink(SinkOptions{
+		Address:         "127.0.0.1:0",
+		Domain:          "sink.example",
+		Hostname:        "sink.example",
+		Timeout:         2 * time.Second,
+		TestTTL:         300 * time.Millisecond,
+		MaxTestMessages: 2,
+	})
+	if err != nil {
+		t.Fatalf("NewSink() error = %v", err)
+	}
+	if err := sink.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer sink.Close()
+	id, address, _ := sink.NewTest()
+
+	// send delivers one message to the recipients and returns the RCPT TO reply codes
+	send := func(recipients ...string) []int {
+		t.Helper()
+		client, err := DialAddress(context.Background(), "sink.example", sink.Addr(), 2*time.Second, false)
+		if err != nil {
+			t.Fatalf("DialAddress() error = %v", err)
+		}
+		defer client.Close()
+		client.Hello("sender.example")
+		client.Mail("a@sender.example")
+		var codes []int
+		accepted := false
+		for _, recipient := range recipients {
+			reply, _ := client.Rcpt(recipient)
+			codes = append(codes, reply.Code)
+			accepted = accepted || reply.Positive()
+		}
+		if accepted {
+			if reply, err := client.Data([]byte("Subject: test\r\n\r\nbody\r\n")); err != nil || !reply.Positive() {
+				t.Fatalf("Data() = %v, %v", reply, err)
+			}
+		}
+		client.Quit()
+		return codes
+	}
+
+	// The same test twice in one transaction
+	send(address, strings.ToUpper(address))
+	if messages, _ := sink.Messages(id); len(messages) != 1 || len(messages[0].Recipients) != 2 {
+		t.Fatalf("Messages() = %d messages, want 1 with both recipients", len(messages))
+	}
+	send(address)
+	if codes := send(address); len(codes) != 1 || codes[0] != 452 {
+		t.Errorf("RCPT TO past the message limit = %v, want 452", codes)
+	}
+	if messages, _ := sink.Messages(id); len(messages) != 2 {
+		t.Errorf("Messages() = %d messages, want the limit of 2", len(messages))
+	}
+
+	time.Sleep(400 * time.Millisecond)
+	if _, ok := sink.Messages(id); ok {
+		t.Error("Messages() found the test after its TTL")
+	}
+	if codes := send(address); len(codes) != 1 || codes[0] != 550 {
+		t.Errorf("RCPT TO for an expired test = %v, want 550", codes)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"net"
 	"net/smtp"
 	"strings"
+	"sync"
 	"time"
 
 	"mxclone/pkg/dns"
@@ -116,23 +117,19 @@ func CheckSTARTTLS(ctx context.Context, host string, port int, timeout time.Dura
 }
 
 // CheckOpenRelay checks if the SMTP server is an open relay.
-// This is a simplified check and should be used with caution.
+// It runs the full relay test battery with the default external addresses; use RunRelayTests
+// to choose the addresses, credentials or target domain.
 func CheckOpenRelay(ctx context.Context, host string, port int, timeout time.Duration) (*types.SMTPResult, error) {
 	result := &types.SMTPResult{}
 
 	// Measure response time
 	startTime := time.Now()
 
-	// Create a connection with timeout
-	dialer := &net.Dialer{
+	report, err := RunRelayTests(ctx, RelayTestOptions{
+		Host:    host,
+		Port:    port,
 		Timeout: timeout,
-	}
-
-	// Construct the address
-	address := fmt.Sprintf("%s:%d", host, port)
-
-	// Connect to the server
-	conn, err := dialer.DialContext(ctx, "tcp", address)
+	})
 
 	// Calculate response time
 	result.ResponseTime = time.Since(startTime)
@@ -143,43 +140,9 @@ func CheckOpenRelay(ctx context.Context, host string, port int, timeout time.Dur
 		return result, err
 	}
 
-	// Close the connection when done
-	defer conn.Close()
-
 	result.ConnectSuccess = true
-
-	// Create an SMTP client
-	client, err := smtp.NewClient(conn, host)
-	if err != nil {
-		result.ConnectSuccess = false
-		result.ConnectError = err.Error()
-		return result, err
-	}
-
-	// Try to send a test email to check if the server is an open relay
-	// This is a simplified check and may not be accurate in all cases
-
-	// Set a from address that is clearly not from the server's domain
-	err = client.Mail("test@example.com")
-	if err != nil {
-		// Server rejected the from address, not an open relay
-		isOpenRelay := false
-		result.IsOpenRelay = &isOpenRelay
-		return result, nil
-	}
-
-	// Set a to address that is clearly not from the server's domain
-	err = client.Rcpt("test@example.org")
-	if err != nil {
-		// Server rejected the to address, not an open relay
-		isOpenRelay := false
-		result.IsOpenRelay = &isOpenRelay
-		return result, nil
-	}
-
-	// If we got this far, the server might be an open relay
-	// In a real implementation, we would check more thoroughly
-	isOpenRelay := true
+	result.RelayTests = report.Tests
+	isOpenRelay := report.IsOpenRelay
 	result.IsOpenRelay = &isOpenRelay
 
 	return result, nil
@@ -224,43 +187,105 @@ func VerifyPTR(ctx context.Context, host string, timeout time.Duration) (bool, s
 }
 
 // CheckSMTP performs a comprehensive SMTP check.
+// Every port is connected to and checked for STARTTLS; the STARTTLS security and open relay
+// checks run on the first port, in the given order, that accepts a connection.
 func CheckSMTP(ctx context.Context, host string, ports []int, timeout time.Duration) (*types.SMTPResult, error) {
 	// If no ports are specified, use the default ports
 	if len(ports) == 0 {
 		ports = DefaultPorts
 	}
 
-	// Try each port until we find one that works
-	for _, port := range ports {
-		// Connect to the server
-		result, err := Connect(ctx, host, port, timeout)
-		if err != nil {
-			continue
-		}
+	// Check each port, so a closed submission port is reported even when port 25 works
+	portResults := make([]types.SMTPPortResult, len(ports))
+	var wg sync.WaitGroup
+	for i, port := range ports {
+		wg.Add(1)
+		go func(i, port int) {
+			defer wg.Done()
+			portResults[i] = checkSMTPPort(ctx, host, port, timeout)
+		}(i, port)
+	}
+	wg.Wait()
 
-		// Check STARTTLS support
-		starttlsResult, err := CheckSTARTTLS(ctx, host, port, timeout)
-		if err == nil && starttlsResult.SupportsSTARTTLS != nil {
-			result.SupportsSTARTTLS = starttlsResult.SupportsSTARTTLS
-			result.STARTTLSError = starttlsResult.STARTTLSError
+	var primary *types.SMTPPortResult
+	for i := range portResults {
+		if portResults[i].ConnectSuccess {
+			primary = &portResults[i]
+			break
 		}
+	}
+
+	// If no port accepted a connection, report the failure with every port's error
+	if primary == nil {
+		return &types.SMTPResult{
+			ConnectSuccess: false,
+			ConnectError:   "Failed to connect to any SMTP port. This may be expected for properly configured mail servers that restrict connections.",
+			Ports:          portResults,
+		}, nil
+	}
 
-		// Check if the server is an open relay
-		relayResult, err := CheckOpenRelay(ctx, host, port, timeout)
-		if err == nil && relayResult.IsOpenRelay != nil {
-			result.IsOpenRelay = relayResult.IsOpenRelay
-			result.RelayCheckError = relayResult.RelayCheckError
+	result := &types.SMTPResult{
+		ConnectSuccess:   true,
+		SupportsSTARTTLS: primary.SupportsSTARTTLS,
+		STARTTLSError:    primary.STARTTLSError,
+		ResponseTime:     primary.ResponseTime,
+		Port:             primary.Port,
+		Ports:            portResults,
+	}
+	port := primary.Port
+
+	// Check STARTTLS for command injection and a handshake that fails after being advertised
+	if result.SupportsSTARTTLS != nil && *result.SupportsSTARTTLS {
+		security, err := CheckSTARTTLSSecurity(ctx, STARTTLSSecurityOptions{
+			Host:    host,
+			Port:    port,
+			Timeout: timeout,
+		})
+		if err == nil {
+			if security.InjectionTested {
+				result.STARTTLSInjection = &security.InjectionVulnerable
+			}
+			result.SecurityFindings = append(result.SecurityFindings, security.Findings...)
 		}
+	}
+
+	// Check if the server is an open relay
+	relayResult, err := CheckOpenRelay(ctx, host, port, timeout)
+	if err == nil && relayResult.IsOpenRelay != nil {
+		result.IsOpenRelay = relayResult.IsOpenRelay
+		result.RelayCheckError = relayResult.RelayCheckError
+		result.RelayTests = relayResult.RelayTests
+	}
+
+	return result, nil
+}
+
+// checkSMTPPort connects to a single port and, unless it uses implicit TLS, checks STARTTLS
+func checkSMTPPort(ctx context.Context, host string, port int, timeout time.Duration) types.SMTPPortResult {
+	portResult := types.SMTPPortResult{Port: port}
+
+	// Connect to the server
+	result, err := Connect(ctx, host, port, timeout)
+	portResult.ResponseTime = result.ResponseTime
+	if err != nil {
+		portResult.ConnectError = result.ConnectError
+		return portResult
+	}
+	portResult.ConnectSuccess = true
+
+	// Port 465 is TLS from the first byte and never offers STARTTLS
+	if port == 465 {
+		return portResult
+	}
 
-		// Return the result for the first successful connection
-		return result, nil
+	// Check STARTTLS support
+	starttlsResult, err := CheckSTARTTLS(ctx, host, port, timeout)
+	if err == nil && starttlsResult.SupportsSTARTTLS != nil {
+		portResult.SupportsSTARTTLS = starttlsResult.SupportsSTARTTLS
+		portResult.STARTTLSError = starttlsResult.STARTTLSError
 	}
 
-	// If we get here, we couldn't connect to any of the ports
-	return &types.SMTPResult{
-		ConnectSuccess: false,
-		ConnectError:   "Failed to connect to any SMTP port. This may be expected for properly configured mail servers that restrict connections.",
-	}, nil
+	return portResult
 }
 
 // CheckSMTPWithPTR performs a comprehensive SMTP check including PTR verification.
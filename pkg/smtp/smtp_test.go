@@ -0,0 +1,567 @@
+// Package smtp provides SMTP diagnostic functionality.
+package smtp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeServer is a scripted SMTP server stand-in listening on the loopback interface.
+type fakeServer struct {
+	listener net.Listener
+	banner   string
+	respond  func(cmd string) string
+	// tlsConfig enables STARTTLS when set
+	tlsConfig *tls.Config
+	// greetDelay delays the greeting; with rejectEarlyTalkers a client talking during the delay is rejected
+	greetDelay         time.Duration
+	rejectEarlyTalkers bool
+	// dataTerminators are accepted as end of data in addition to <CRLF>.<CRLF>, like a vulnerable server
+	dataTerminators []string
+	// rejectBareLF refuses commands and message data containing a bare LF, like a hardened server
+	rejectBareLF bool
+	// starttlsInjection processes input pipelined after STARTTLS inside the TLS session, like a vulnerable server
+	starttlsInjection bool
+	// brokenTLS advertises STARTTLS and accepts the command, then drops the connection instead of negotiating
+	brokenTLS bool
+
+	mu       sync.Mutex
+	commands []string
+	messages []string
+}
+
+// newFakeServer starts a fake SMTP server. respond returns the reply for a command;
+// an empty reply falls back to defaultReply. Multi-line replies are separated by CRLF.
+func newFakeServer(t *testing.T, respond func(cmd string) string) *fakeServer {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	server := &fakeServer{
+		listener: listener,
+		banner:   "220 fake.example.com ESMTP ready",
+		respond:  respond,
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+
+	return server
+}
+
+// serve handles a single SMTP session.
+func (s *fakeServer) serve(conn net.Conn) {
+	defer func() { conn.Close() }()
+	reader := bufio.NewReader(conn)
+	encrypted := false
+
+	if s.greetDelay > 0 {
+		conn.SetReadDeadline(time.Now().Add(s.greetDelay))
+		_, err := reader.Peek(1)
+		conn.SetReadDeadline(time.Time{})
+		if err == nil && s.rejectEarlyTalkers {
+			conn.Write([]byte("554 5.5.1 Protocol error: talking too early\r\n"))
+			return
+		}
+	}
+
+	conn.Write([]byte(s.banner + "\r\n"))
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.TrimRight(line, "\r\n")
+
+		s.mu.Lock()
+		s.commands = append(s.commands, cmd)
+		s.mu.Unlock()
+
+		if s.rejectBareLF && !strings.HasSuffix(line, "\r\n") {
+			conn.Write([]byte("500 5.5.2 Bare LF received\r\n"))
+			continue
+		}
+
+		reply := ""
+		if s.respond != nil {
+			reply = s.respond(cmd)
+		}
+		if reply == "" && s.tlsConfig != nil && !encrypted && strings.EqualFold(cmd, "STARTTLS") {
+			conn.Write([]byte("220 2.0.0 Ready to start TLS\r\n"))
+			if s.brokenTLS {
+				return
+			}
+			// Input received with STARTTLS is discarded unless the server is vulnerable to injection
+			pending, _ := reader.Peek(reader.Buffered())
+			pending = append([]byte(nil), pending...)
+			tlsConn := tls.Server(conn, s.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn = tlsConn
+			reader = bufio.NewReader(conn)
+			if s.starttlsInjection {
+				reader = bufio.NewReader(io.MultiReader(bytes.NewReader(pending), conn))
+			}
+			encrypted = true
+			continue
+		}
+		if reply == "" {
+			reply = defaultReply(cmd)
+		}
+		// Advertise STARTTLS in the first EHLO line's continuation
+		if s.tlsConfig != nil && !encrypted && strings.HasPrefix(strings.ToUpper(cmd), "EHLO") && strings.HasPrefix(reply, "250") {
+			first, rest, _ := strings.Cut(reply, "\r\n")
+			reply = "250-" + first[4:] + "\r\n250-STARTTLS"
+			if rest != "" {
+				reply += "\r\n" + rest
+			} else {
+				reply = strings.Replace(reply, "250-STARTTLS", "250 STARTTLS", 1)
+			}
+		}
+		conn.Write([]byte(reply + "\r\n"))
+
+		if strings.HasPrefix(reply, "354") {
+			reply = s.readMessage(reader)
+			conn.Write([]byte(reply + "\r\n"))
+		}
+
+		if strings.EqualFold(cmd, "QUIT") || strings.HasPrefix(reply, "421") || strings.HasPrefix(reply, "521") {
+			return
+		}
+	}
+}
+
+// readMessage reads a message after DATA up to the terminating dot and stores it.
+func (s *fakeServer) readMessage(reader *bufio.Reader) string {
+	terminators := append([]string{"\r\n.\r\n"}, s.dataTerminators...)
+	var data []byte
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return "451 4.3.0 Error reading message"
+		}
+		data = append(data, b)
+		if s.rejectBareLF && b == '\n' && (len(data) < 2 || data[len(data)-2] != '\r') {
+			return "521 5.5.2 Bare LF received"
+		}
+		if string(data) == ".\r\n" {
+			data = data[:0]
+			break
+		}
+		if end := messageEnd(data, terminators); end >= 0 {
+			data = data[:end]
+			break
+		}
+	}
+
+	s.mu.Lock()
+	s.messages = append(s.messages, string(data))
+	s.mu.Unlock()
+	return "250 2.0.0 Queued"
+}
+
+// messageEnd returns where the message ends when data ends with one of the terminators, or -1.
+// The line break before the dot of <CRLF>.<CRLF> belongs to the message.
+func messageEnd(data []byte, terminators []string) int {
+	for _, terminator := range terminators {
+		if bytes.HasSuffix(data, []byte(terminator)) {
+			if terminator == "\r\n.\r\n" {
+				return len(data) - 3
+			}
+			return len(data) - len(terminator)
+		}
+	}
+	return -1
+}
+
+// receivedMessages returns the messages received after DATA.
+func (s *fakeServer) receivedMessages() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.messages...)
+}
+
+// selfSignedTLSConfig returns a server TLS configuration with a throwaway certificate.
+func selfSignedTLSConfig(t *testing.T) *tls.Config {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "fake.example.com"},
+		DNSNames:     []string{"fake.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+	}
+}
+
+// addr returns the host and port the server listens on.
+func (s *fakeServer) addr() (string, int) {
+	tcpAddr := s.listener.Addr().(*net.TCPAddr)
+	return tcpAddr.IP.String(), tcpAddr.Port
+}
+
+// received returns the commands received so far.
+func (s *fakeServer) received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.commands...)
+}
+
+// defaultReply answers like a well-behaved server that does not relay.
+func defaultReply(cmd string) string {
+	verb := strings.ToUpper(strings.Fields(cmd + " ")[0])
+	switch verb {
+	case "EHLO":
+		return "250-fake.example.com Hello\r\n250-PIPELINING\r\n250 8BITMIME"
+	case "HELO", "MAIL", "RSET", "NOOP":
+		return "250 2.0.0 OK"
+	case "DATA":
+		return "354 End data with <CR><LF>.<CR><LF>"
+	case "RCPT":
+		return "554 5.7.1 Relay access denied"
+	case "QUIT":
+		return "221 2.0.0 Bye"
+	default:
+		return "502 5.5.2 Command not recognized"
+	}
+}
+
+// countCommands counts the received commands starting with a verb.
+func countCommands(commands []string, verb string) int {
+	count := 0
+	for _, cmd := range commands {
+		if strings.HasPrefix(strings.ToUpper(cmd), verb) {
+			count++
+		}
+	}
+	return count
+}
+
+// TestReadReply tests parsing of single and multi-line replies
+func TestReadReply(t *testing.T) {
+	server := newFakeServer(t, nil)
+	host, port := server.addr()
+
+	client, err := Dial(context.Background(), host, port, 2*time.Second, false)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer client.Close()
+
+	if client.Banner.Code != 220 {
+		t.Errorf("Banner code = %d, want 220", client.Banner.Code)
+	}
+
+	reply, err := client.Hello("")
+	if err != nil {
+		t.Fatalf("Hello() error = %v", err)
+	}
+	if reply.Code != 250 || len(reply.Lines) != 3 {
+		t.Errorf("Hello() reply = %+v, want 250 with 3 lines", reply)
+	}
+	if ok, _ := client.Extension("pipelining"); !ok {
+		t.Errorf("Extension(PIPELINING) = false, want true")
+	}
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		t.Errorf("Extension(STARTTLS) = true, want false")
+	}
+}
+
+// TestDeriveTargetDomain tests guessing the mail domain from an MX host name
+func TestDeriveTargetDomain(t *testing.T) {
+	tests := []struct {
+		host     string
+		expected string
+	}{
+		{"mx1.example.com", "example.com"},
+		{"example.com", "example.com"},
+		{"MX.Mail.Example.COM.", "example.com"},
+		{"mx1.mail.example.co.uk", "example.co.uk"},
+		{"192.0.2.1", "[192.0.2.1]"},
+		{"2001:db8::1", "[IPv6:2001:db8::1]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			if got := deriveTargetDomain(tt.host); got != tt.expected {
+				t.Errorf("deriveTargetDomain(%q) = %q, want %q", tt.host, got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestRunRelayTests tests the open relay battery against closed and open servers
+func TestRunRelayTests(t *testing.T) {
+	tests := []struct {
+		name             string
+		respond          func(cmd string) string
+		wantOpenRelay    bool
+		wantAuthRequired bool
+		wantRelayed      []string
+	}{
+		{
+			name:          "Closed relay",
+			respond:       nil,
+			wantOpenRelay: false,
+		},
+		{
+			name: "Open relay",
+			respond: func(cmd string) string {
+				if strings.HasPrefix(cmd, "RCPT") {
+					return "250 2.1.5 OK"
+				}
+				return ""
+			},
+			wantOpenRelay: true,
+		},
+		{
+			name: "Percent hack relay",
+			respond: func(cmd string) string {
+				if strings.HasPrefix(cmd, "RCPT") && strings.Contains(cmd, "%") {
+					return "250 2.1.5 OK"
+				}
+				return ""
+			},
+			wantOpenRelay: true,
+			wantRelayed:   []string{"percent-hack", "quoted-percent-hack", "percent-hack-literal"},
+		},
+		{
+			name: "Authentication required",
+			respond: func(cmd string) string {
+				if strings.HasPrefix(cmd, "RCPT") {
+					return "530 5.7.0 Authentication required"
+				}
+				return ""
+			},
+			wantOpenRelay:    false,
+			wantAuthRequired: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeServer(t, tt.respond)
+			host, port := server.addr()
+
+			report, err := RunRelayTests(context.Background(), RelayTestOptions{
+				Host:         host,
+				Port:         port,
+				FromAddress:  "sender@external.test",
+				ToAddress:    "victim@elsewhere.test",
+				TargetDomain: "example.com",
+				Timeout:      2 * time.Second,
+			})
+			if err != nil {
+				t.Fatalf("RunRelayTests() error = %v", err)
+			}
+
+			if report.IsOpenRelay != tt.wantOpenRelay {
+				t.Errorf("IsOpenRelay = %v, want %v", report.IsOpenRelay, tt.wantOpenRelay)
+			}
+			if report.AuthRequired != tt.wantAuthRequired {
+				t.Errorf("AuthRequired = %v, want %v", report.AuthRequired, tt.wantAuthRequired)
+			}
+			if len(report.Tests) != len(relayProbes("", "victim@elsewhere.test", "example.com", host)) {
+				t.Errorf("ran %d tests, want the full battery", len(report.Tests))
+			}
+
+			if tt.wantRelayed != nil {
+				relayed := []string{}
+				for _, test := range report.Tests {
+					if test.Verdict == RelayVerdictRelayed {
+						relayed = append(relayed, test.Name)
+					}
+				}
+				if strings.Join(relayed, ",") != strings.Join(tt.wantRelayed, ",") {
+					t.Errorf("relayed tests = %v, want %v", relayed, tt.wantRelayed)
+				}
+			}
+
+			for _, test := range report.Tests {
+				if len(test.Transcript) == 0 {
+					t.Errorf("test %s has no transcript", test.Name)
+				}
+			}
+
+			commands := server.received()
+			if countCommands(commands, "DATA") != 0 {
+				t.Errorf("relay test sent DATA")
+			}
+			if got := countCommands(commands, "RSET"); got != len(report.Tests) {
+				t.Errorf("sent %d RSET commands, want one per test (%d)", got, len(report.Tests))
+			}
+		})
+	}
+}
+
+// TestRunRelayTestsConnectionRefused tests the error path when the server is unreachable
+func TestRunRelayTestsConnectionRefused(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	report, err := RunRelayTests(context.Background(), RelayTestOptions{
+		Host:    "127.0.0.1",
+		Port:    port,
+		Timeout: time.Second,
+	})
+	if err == nil {
+		t.Fatalf("RunRelayTests() error = nil, want connection error")
+	}
+	if report == nil || report.Error == "" {
+		t.Errorf("report.Error is empty, want connection error")
+	}
+}
+
+// TestTranscript tests that the transcript records directions, reply codes and redacted credentials
+func TestTranscript(t *testing.T) {
+	tests := []struct {
+		name      string
+		mechanism string
+	}{
+		{"AUTH PLAIN", "PLAIN"},
+		{"AUTH LOGIN", "LOGIN"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeServer(t, func(cmd string) string {
+				switch {
+				case strings.HasPrefix(cmd, "EHLO"):
+					return "250-fake.example.com Hello\r\n250 AUTH " + tt.mechanism
+				case cmd == "AUTH LOGIN":
+					return "334 VXNlcm5hbWU6"
+				case strings.HasPrefix(cmd, "AUTH PLAIN"):
+					return "235 2.7.0 Authentication successful"
+				case cmd == "dXNlcg==":
+					return "334 UGFzc3dvcmQ6"
+				case cmd == "cGFzc3dvcmQ=":
+					return "235 2.7.0 Authentication successful"
+				}
+				return ""
+			})
+			host, port := server.addr()
+
+			client, err := Dial(context.Background(), host, port, 2*time.Second, false)
+			if err != nil {
+				t.Fatalf("Dial() error = %v", err)
+			}
+			defer client.Close()
+
+			if _, err := client.Hello(""); err != nil {
+				t.Fatalf("Hello() error = %v", err)
+			}
+			reply, err := client.Auth("user", "password")
+			if err != nil || reply.Code != 235 {
+				t.Fatalf("Auth() = %v, %v; want 235", reply, err)
+			}
+			if got := reply.EnhancedCode(); got != "2.7.0" {
+				t.Errorf("EnhancedCode() = %q, want 2.7.0", got)
+			}
+
+			if client.Transcript[0].Direction != DirectionInfo {
+				t.Errorf("first entry direction = %q, want %q", client.Transcript[0].Direction, DirectionInfo)
+			}
+			secrets := []string{"dXNlcg==", "cGFzc3dvcmQ=", "AHVzZXIAcGFzc3dvcmQ="}
+			for i, entry := range client.Transcript {
+				for _, secret := range secrets {
+					if strings.Contains(entry.Line, secret) {
+						t.Errorf("transcript entry %d leaks credentials: %q", i, entry.Line)
+					}
+				}
+				if i > 0 && entry.Timestamp.Before(client.Transcript[i-1].Timestamp) {
+					t.Errorf("transcript entry %d is out of order", i)
+				}
+				if entry.Direction == DirectionServer && entry.Code == 0 {
+					t.Errorf("server entry %d has no reply code: %q", i, entry.Line)
+				}
+			}
+
+			last := client.Transcript[len(client.Transcript)-1]
+			if last.Code != 235 || last.EnhancedCode != "2.7.0" {
+				t.Errorf("last entry = %+v, want 235 with enhanced code 2.7.0", last)
+			}
+		})
+	}
+}
+
+// TestCheckSMTP tests that every port is reported and the further checks use the first open port
+func TestCheckSMTP(t *testing.T) {
+	server := newFakeServer(t, nil)
+	host, openPort := server.addr()
+
+	// A port nothing listens on
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	closedPort := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	result, err := CheckSMTP(context.Background(), host, []int{closedPort, openPort}, 2*time.Second)
+	if err != nil {
+		t.Fatalf("CheckSMTP() error = %v", err)
+	}
+	if !result.ConnectSuccess || result.Port != openPort {
+		t.Fatalf("CheckSMTP() = connected %t on port %d, want port %d", result.ConnectSuccess, result.Port, openPort)
+	}
+	if len(result.Ports) != 2 {
+		t.Fatalf("Ports = %+v, want one entry per port", result.Ports)
+	}
+	if closed := result.Ports[0]; closed.Port != closedPort || closed.ConnectSuccess || closed.ConnectError == "" {
+		t.Errorf("closed port = %+v", closed)
+	}
+	open := result.Ports[1]
+	if open.Port != openPort || !open.ConnectSuccess || open.SupportsSTARTTLS == nil || *open.SupportsSTARTTLS {
+		t.Errorf("open port = %+v, want connected without STARTTLS", open)
+	}
+	if result.IsOpenRelay == nil || *result.IsOpenRelay {
+		t.Errorf("IsOpenRelay = %v, want false", result.IsOpenRelay)
+	}
+
+	result, err = CheckSMTP(context.Background(), host, []int{closedPort}, 2*time.Second)
+	if err != nil || result.ConnectSuccess || len(result.Ports) != 1 || result.Ports[0].ConnectError == "" {
+		t.Errorf("CheckSMTP(closed) = %+v, %v", result, err)
+	}
+}
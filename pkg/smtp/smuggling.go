@@ -0,0 +1,283 @@
+// Package smtp provides SMTP diagnostic functionality.
+package smtp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"mxclone/pkg/types"
+)
+
+// Outcomes of a smuggling probe step
+const (
+	// SmugglingEndOfData means the server answered as if the message had ended
+	SmugglingEndOfData = "end-of-data"
+	// SmugglingRejected means the server refused the sequence or closed the connection
+	SmugglingRejected = "rejected"
+	// SmugglingIgnored means the server kept waiting for more input
+	SmugglingIgnored = "ignored"
+	// SmugglingAccepted means the server answered a command terminated by a bare LF
+	SmugglingAccepted = "accepted"
+	// SmugglingError means the step could not run
+	SmugglingError = "error"
+)
+
+// SmugglingSequences are the non-standard end-of-data sequences probed, by name.
+// A server that ends the message on any of them can be used to smuggle a second message
+// past an outbound server that passes the sequence through (CVE-2023-51766 class).
+var SmugglingSequences = []struct {
+	Name     string
+	Sequence string
+}{
+	{"<LF>.<LF>", "\n.\n"},
+	{"<CR>.<CR>", "\r.\r"},
+	{"<LF>.<CR><LF>", "\n.\r\n"},
+	{"<CR><LF>.<LF>", "\r\n.\n"},
+	{"<CR>.<CR><LF>", "\r.\r\n"},
+}
+
+// DefaultSmugglingWait is how long the probe waits for an answer after a sequence.
+const DefaultSmugglingWait = 5 * time.Second
+
+// ErrSmugglingNotConfirmed is returned when the probe is run without explicit opt-in.
+var ErrSmugglingNotConfirmed = errors.New("the smuggling probe sends test messages to the postmaster and must be explicitly confirmed")
+
+// SmugglingProbeOptions configures an SMTP smuggling probe.
+type SmugglingProbeOptions struct {
+	// Host is the mail exchanger to probe
+	Host string
+	// Port is the SMTP port (default 25)
+	Port int
+	// Domain is the domain whose postmaster receives the probe messages; it is the only recipient used
+	Domain string
+	// FromAddress is the envelope sender; empty uses the null reverse-path
+	FromAddress string
+	// Confirm must be set: a server that accepts a sequence delivers a probe message to the postmaster
+	Confirm bool
+	// Wait is how long to wait for an answer after each sequence (default DefaultSmugglingWait)
+	Wait time.Duration
+	// HeloName is the name announced in EHLO
+	HeloName string
+	// Timeout applies to the connection and to each command
+	Timeout time.Duration
+}
+
+// RunSmugglingProbe checks how a mail exchanger handles non-standard end-of-data sequences and
+// commands terminated by a bare LF. Each sequence is sent in its own session, at the end of a short
+// message to postmaster@Domain. A server that answers right after the sequence treats it as the end
+// of data and has accepted the message; a server that keeps waiting is left without the standard
+// <CRLF>.<CRLF>, so nothing is delivered.
+func RunSmugglingProbe(ctx context.Context, opts SmugglingProbeOptions) (*types.SmugglingReport, error) {
+	if opts.Port == 0 {
+		opts.Port = 25
+	}
+	if opts.Wait <= 0 {
+		opts.Wait = DefaultSmugglingWait
+	}
+
+	report := &types.SmugglingReport{
+		Host:      opts.Host,
+		Port:      opts.Port,
+		Recipient: "postmaster@" + opts.Domain,
+	}
+	if !opts.Confirm {
+		report.Error = ErrSmugglingNotConfirmed.Error()
+		return report, ErrSmugglingNotConfirmed
+	}
+	if opts.Domain == "" {
+		err := errors.New("a recipient domain is required")
+		report.Error = err.Error()
+		return report, err
+	}
+
+	// Bare LF in commands
+	if err := probeBareLFCommand(ctx, opts, report); err != nil {
+		report.Error = err.Error()
+		return report, err
+	}
+
+	// Non-standard end-of-data sequences
+	for _, seq := range SmugglingSequences {
+		result := types.SmugglingSequenceResult{Name: seq.Name}
+		if err := probeEndOfData(ctx, opts, seq.Name, seq.Sequence, &result, report); err != nil {
+			result.Outcome = SmugglingError
+			result.Error = err.Error()
+		}
+		report.Sequences = append(report.Sequences, result)
+		if result.Outcome == SmugglingEndOfData {
+			report.Vulnerable = true
+			report.Findings = append(report.Findings, fmt.Sprintf("Server treats %s as end of data (SMTP smuggling)", seq.Name))
+		}
+	}
+
+	switch report.BareLFCommand {
+	case SmugglingAccepted:
+		report.Findings = append(report.Findings, "Server accepts commands terminated by a bare LF")
+	case SmugglingRejected:
+		report.Findings = append(report.Findings, "Server rejects commands terminated by a bare LF")
+	}
+
+	return report, nil
+}
+
+// smugglingSession opens a session and runs EHLO, opportunistic STARTTLS, MAIL FROM and RCPT TO.
+func smugglingSession(ctx context.Context, opts SmugglingProbeOptions, report *types.SmugglingReport, withRecipient bool) (*Client, error) {
+	client, err := Dial(ctx, opts.Host, opts.Port, opts.Timeout, opts.Port == 465)
+	if err != nil {
+		return nil, err
+	}
+	fail := func(err error) (*Client, error) {
+		report.Transcript = append(report.Transcript, client.Transcript...)
+		client.Close()
+		return nil, err
+	}
+
+	if !client.Banner.Positive() {
+		return fail(fmt.Errorf("server refused the connection: %s", client.Banner))
+	}
+	if _, err := client.Hello(opts.HeloName); err != nil {
+		return fail(err)
+	}
+	if ok, _ := client.Extension("STARTTLS"); ok && !client.TLS() {
+		if reply, err := client.StartTLS(tlsConfigFor(opts.Host)); err == nil && reply.Positive() {
+			if _, err := client.Hello(opts.HeloName); err != nil {
+				return fail(err)
+			}
+		}
+	}
+	if !withRecipient {
+		return client, nil
+	}
+
+	reply, err := client.Mail(opts.FromAddress)
+	if err != nil {
+		return fail(err)
+	}
+	if !reply.Positive() {
+		return fail(fmt.Errorf("sender refused: %s", reply))
+	}
+	reply, err = client.Rcpt(report.Recipient)
+	if err != nil {
+		return fail(err)
+	}
+	if !reply.Positive() {
+		return fail(fmt.Errorf("recipient %s refused: %s", report.Recipient, reply))
+	}
+
+	return client, nil
+}
+
+// probeBareLFCommand sends NOOP terminated by a bare LF and records whether the server answers it.
+func probeBareLFCommand(ctx context.Context, opts SmugglingProbeOptions, report *types.SmugglingReport) error {
+	client, err := smugglingSession(ctx, opts, report, false)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		report.Transcript = append(report.Transcript, client.Transcript...)
+		client.Close()
+	}()
+
+	if err := client.WriteRaw([]byte("NOOP\n"), "NOOP<LF>"); err != nil {
+		return err
+	}
+	reply, err := client.ReadReplyWithin(opts.Wait)
+	switch {
+	case err != nil && isTimeout(err):
+		report.BareLFCommand = SmugglingIgnored
+		client.Note("No answer within %s", opts.Wait)
+	case err != nil:
+		report.BareLFCommand = SmugglingRejected
+		report.BareLFCommandResponse = "connection closed"
+	case reply.Positive():
+		report.BareLFCommand = SmugglingAccepted
+		report.BareLFCommandCode = reply.Code
+		report.BareLFCommandResponse = reply.Text()
+		client.Quit()
+	default:
+		report.BareLFCommand = SmugglingRejected
+		report.BareLFCommandCode = reply.Code
+		report.BareLFCommandResponse = reply.Text()
+	}
+
+	return nil
+}
+
+// probeEndOfData sends a short message ended by a non-standard sequence and records whether the
+// server answers it as the end of data.
+func probeEndOfData(ctx context.Context, opts SmugglingProbeOptions, name, sequence string, result *types.SmugglingSequenceResult, report *types.SmugglingReport) error {
+	client, err := smugglingSession(ctx, opts, report, true)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		report.Transcript = append(report.Transcript, client.Transcript...)
+		client.Close()
+	}()
+
+	reply, err := client.Cmd("DATA")
+	if err != nil {
+		return err
+	}
+	if reply.Code != 354 {
+		return fmt.Errorf("DATA refused: %s", reply)
+	}
+
+	trackingID := newTrackingID()
+	message := buildSmugglingMessage(opts, report.Recipient, name, trackingID)
+	if err := client.WriteRaw([]byte(message+sequence), fmt.Sprintf("<message body> %s", name)); err != nil {
+		return err
+	}
+
+	reply, err = client.ReadReplyWithin(opts.Wait)
+	switch {
+	case err != nil && isTimeout(err):
+		// The server is still in DATA; closing without <CRLF>.<CRLF> discards the message
+		result.Outcome = SmugglingIgnored
+		client.Note("No answer within %s; closing without ending the message", opts.Wait)
+	case err != nil && errors.Is(err, io.EOF):
+		result.Outcome = SmugglingRejected
+		result.Response = "connection closed"
+	case err != nil:
+		return err
+	case reply.Positive():
+		result.Outcome = SmugglingEndOfData
+		result.Code = reply.Code
+		result.Response = reply.Text()
+		result.TrackingID = trackingID
+		client.Quit()
+	default:
+		result.Outcome = SmugglingRejected
+		result.Code = reply.Code
+		result.Response = reply.Text()
+	}
+
+	return nil
+}
+
+// buildSmugglingMessage generates the probe message, without its end-of-data sequence.
+func buildSmugglingMessage(opts SmugglingProbeOptions, recipient, sequenceName, trackingID string) string {
+	from := opts.FromAddress
+	if from == "" {
+		from = recipient
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: <%s>\r\n", from)
+	fmt.Fprintf(&b, "To: <%s>\r\n", recipient)
+	fmt.Fprintf(&b, "Subject: mxclone SMTP smuggling probe %s\r\n", trackingID)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "Message-ID: <mxclone-smuggling-%s@%s>\r\n", trackingID, addressDomain(recipient))
+	fmt.Fprintf(&b, "%s: %s\r\n", TrackingHeader, trackingID)
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	fmt.Fprintf(&b, "This message was sent by mxclone to check how %s handles the\r\n", opts.Host)
+	fmt.Fprintf(&b, "non-standard end-of-data sequence %s. Its delivery means the server\r\n", sequenceName)
+	b.WriteString("treats that sequence as the end of a message, which enables SMTP smuggling.")
+	return b.String()
+}
@@ -0,0 +1,140 @@
+// Package smtp provides SMTP diagnostic functionality.
+package smtp
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"mxclone/pkg/types"
+)
+
+// postmasterResponder accepts postmaster@example.com and refuses every other recipient.
+func postmasterResponder(cmd string) string {
+	switch {
+	case cmd == "RCPT TO:<postmaster@example.com>":
+		return "250 2.1.5 OK"
+	case strings.HasPrefix(cmd, "RCPT"):
+		return "550 5.7.1 Only postmaster may be probed"
+	}
+	return ""
+}
+
+// sequenceOutcomes maps each probed sequence to its outcome.
+func sequenceOutcomes(report *types.SmugglingReport) map[string]string {
+	outcomes := make(map[string]string)
+	for _, seq := range report.Sequences {
+		outcomes[seq.Name] = seq.Outcome
+	}
+	return outcomes
+}
+
+// TestRunSmugglingProbe tests the probe against vulnerable, conforming and hardened servers
+func TestRunSmugglingProbe(t *testing.T) {
+	tests := []struct {
+		name           string
+		terminators    []string
+		rejectBareLF   bool
+		wantVulnerable bool
+		wantBareLF     string
+		wantOutcomes   map[string]string
+		wantMessages   int
+	}{
+		{
+			name:           "vulnerable",
+			terminators:    []string{"\n.\n", "\n.\r\n"},
+			wantVulnerable: true,
+			wantBareLF:     SmugglingAccepted,
+			wantOutcomes: map[string]string{
+				"<LF>.<LF>":     SmugglingEndOfData,
+				"<LF>.<CR><LF>": SmugglingEndOfData,
+				"<CR><LF>.<LF>": SmugglingEndOfData,
+				"<CR>.<CR>":     SmugglingIgnored,
+				"<CR>.<CR><LF>": SmugglingIgnored,
+			},
+			wantMessages: 3,
+		},
+		{
+			name:       "conforming",
+			wantBareLF: SmugglingAccepted,
+			wantOutcomes: map[string]string{
+				"<LF>.<LF>":     SmugglingIgnored,
+				"<CR>.<CR>":     SmugglingIgnored,
+				"<LF>.<CR><LF>": SmugglingIgnored,
+			},
+		},
+		{
+			name:         "hardened",
+			rejectBareLF: true,
+			wantBareLF:   SmugglingRejected,
+			wantOutcomes: map[string]string{
+				"<LF>.<LF>":     SmugglingRejected,
+				"<CR>.<CR>":     SmugglingIgnored,
+				"<CR><LF>.<LF>": SmugglingRejected,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeServer(t, postmasterResponder)
+			server.dataTerminators = tt.terminators
+			server.rejectBareLF = tt.rejectBareLF
+			host, port := server.addr()
+
+			report, err := RunSmugglingProbe(context.Background(), SmugglingProbeOptions{
+				Host:    host,
+				Port:    port,
+				Domain:  "example.com",
+				Confirm: true,
+				Wait:    200 * time.Millisecond,
+				Timeout: 2 * time.Second,
+			})
+			if err != nil {
+				t.Fatalf("RunSmugglingProbe() error = %v", err)
+			}
+
+			if report.Vulnerable != tt.wantVulnerable {
+				t.Errorf("Vulnerable = %v, want %v", report.Vulnerable, tt.wantVulnerable)
+			}
+			if report.BareLFCommand != tt.wantBareLF {
+				t.Errorf("BareLFCommand = %q, want %q", report.BareLFCommand, tt.wantBareLF)
+			}
+			outcomes := sequenceOutcomes(report)
+			for name, want := range tt.wantOutcomes {
+				if outcomes[name] != want {
+					t.Errorf("outcome of %s = %q, want %q", name, outcomes[name], want)
+				}
+			}
+			if got := len(server.receivedMessages()); got != tt.wantMessages {
+				t.Errorf("server received %d messages, want %d", got, tt.wantMessages)
+			}
+			for _, cmd := range server.received() {
+				if strings.HasPrefix(cmd, "RCPT") && cmd != "RCPT TO:<postmaster@example.com>" {
+					t.Errorf("probe sent to a recipient other than postmaster: %s", cmd)
+				}
+			}
+		})
+	}
+}
+
+// TestRunSmugglingProbeRequiresConfirm tests that nothing is sent without opt-in
+func TestRunSmugglingProbeRequiresConfirm(t *testing.T) {
+	server := newFakeServer(t, postmasterResponder)
+	host, port := server.addr()
+
+	_, err := RunSmugglingProbe(context.Background(), SmugglingProbeOptions{
+		Host:    host,
+		Port:    port,
+		Domain:  "example.com",
+		Timeout: 2 * time.Second,
+	})
+	if err != ErrSmugglingNotConfirmed {
+		t.Fatalf("error = %v, want ErrSmugglingNotConfirmed", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if len(server.received()) != 0 {
+		t.Errorf("commands sent without confirmation: %v", server.received())
+	}
+}
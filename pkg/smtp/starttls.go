@@ -0,0 +1,172 @@
+// Package smtp provides SMTP diagnostic functionality.
+package smtp
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"mxclone/pkg/types"
+)
+
+// DefaultInjectionWait is how long the injection probe waits for an answer to the injected command.
+const DefaultInjectionWait = 3 * time.Second
+
+// STARTTLSSecurityOptions configures a STARTTLS security check.
+type STARTTLSSecurityOptions struct {
+	// Host is the SMTP server to check
+	Host string
+	// Address is the address to dial; Host is used when empty
+	Address string
+	// Port is the SMTP port (default 25)
+	Port int
+	// Wait is how long to wait for an answer to the injected command (default DefaultInjectionWait)
+	Wait time.Duration
+	// HeloName is the name announced in EHLO
+	HeloName string
+	// Timeout applies to the connection and to each command
+	Timeout time.Duration
+}
+
+// CheckSTARTTLSSecurity looks for two STARTTLS weaknesses. The first session checks that an advertised
+// STARTTLS actually completes a handshake: a server that advertises it and then fails lets senders fall
+// back to plaintext (an opportunistic downgrade). The second session pipelines "STARTTLS\r\nRSET\r\n"
+// in one packet: a server that answers the RSET after the upgrade has carried plaintext input into the
+// encrypted session, the response-injection flaw class (CVE-2011-0411 and its descendants).
+func CheckSTARTTLSSecurity(ctx context.Context, opts STARTTLSSecurityOptions) (*types.STARTTLSSecurityReport, error) {
+	if opts.Port == 0 {
+		opts.Port = 25
+	}
+	if opts.Address == "" {
+		opts.Address = net.JoinHostPort(opts.Host, strconv.Itoa(opts.Port))
+	}
+	if opts.Wait <= 0 {
+		opts.Wait = DefaultInjectionWait
+	}
+
+	report := &types.STARTTLSSecurityReport{
+		Host: opts.Host,
+		Port: opts.Port,
+	}
+
+	if err := checkSTARTTLSHandshake(ctx, opts, report); err != nil {
+		report.Error = err.Error()
+		return report, err
+	}
+	if !report.Advertised || !report.HandshakeSucceeded {
+		return report, nil
+	}
+
+	if err := checkSTARTTLSInjection(ctx, opts, report); err != nil {
+		report.Error = err.Error()
+		return report, err
+	}
+
+	return report, nil
+}
+
+// starttlsSession opens a plaintext session and sends EHLO.
+func starttlsSession(ctx context.Context, opts STARTTLSSecurityOptions) (*Client, error) {
+	client, err := DialAddress(ctx, opts.Host, opts.Address, opts.Timeout, false)
+	if err != nil {
+		return nil, err
+	}
+	if !client.Banner.Positive() {
+		client.Close()
+		return nil, fmt.Errorf("server refused the connection: %s", client.Banner)
+	}
+	if _, err := client.Hello(opts.HeloName); err != nil {
+		client.Close()
+		return nil, err
+	}
+	return client, nil
+}
+
+// checkSTARTTLSHandshake checks that an advertised STARTTLS completes a TLS handshake.
+func checkSTARTTLSHandshake(ctx context.Context, opts STARTTLSSecurityOptions, report *types.STARTTLSSecurityReport) error {
+	client, err := starttlsSession(ctx, opts)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		report.Transcript = append(report.Transcript, client.Transcript...)
+		client.Close()
+	}()
+
+	report.Advertised, _ = client.Extension("STARTTLS")
+	if !report.Advertised {
+		client.Quit()
+		return nil
+	}
+
+	reply, err := client.StartTLS(tlsConfigFor(opts.Host))
+	switch {
+	case reply == nil:
+		report.HandshakeError = err.Error()
+		report.Findings = append(report.Findings, "STARTTLS is advertised but the server dropped the connection when it was requested; senders fall back to plaintext")
+	case reply.Code != 220:
+		report.HandshakeError = reply.String()
+		report.Findings = append(report.Findings, fmt.Sprintf("STARTTLS is advertised but refused (%s); senders fall back to plaintext", reply))
+	case err != nil:
+		report.HandshakeError = err.Error()
+		report.Findings = append(report.Findings, "STARTTLS is advertised but the TLS handshake fails; senders fall back to plaintext (opportunistic downgrade)")
+	default:
+		report.HandshakeSucceeded = true
+		if state, ok := client.TLSState(); ok {
+			report.TLSVersion = tls.VersionName(state.Version)
+		}
+		client.Quit()
+	}
+
+	return nil
+}
+
+// checkSTARTTLSInjection pipelines STARTTLS and RSET and checks whether the RSET is answered after the upgrade.
+func checkSTARTTLSInjection(ctx context.Context, opts STARTTLSSecurityOptions, report *types.STARTTLSSecurityReport) error {
+	client, err := starttlsSession(ctx, opts)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		report.Transcript = append(report.Transcript, client.Transcript...)
+		client.Close()
+	}()
+
+	if err := client.WriteRaw([]byte("STARTTLS\r\nRSET\r\n"), "STARTTLS<CRLF>RSET (pipelined in one packet)"); err != nil {
+		return err
+	}
+	reply, err := client.ReadReply()
+	if err != nil {
+		return err
+	}
+	report.InjectionTested = true
+	if reply.Code != 220 {
+		// Refusing pipelined input after STARTTLS is a safe reaction
+		report.InjectionResponse = reply.String()
+		client.Note("STARTTLS refused when pipelined")
+		return nil
+	}
+
+	if err := client.Handshake(tlsConfigFor(opts.Host)); err != nil {
+		return err
+	}
+
+	reply, err = client.ReadReplyWithin(opts.Wait)
+	switch {
+	case err != nil && isTimeout(err):
+		client.Note("No answer to the injected command within %s", opts.Wait)
+		client.Quit()
+	case err != nil:
+		// The server ended the session instead of answering; nothing was injected
+		client.Note("Connection closed after the upgrade: %v", err)
+	default:
+		report.InjectionVulnerable = true
+		report.InjectionResponse = reply.String()
+		report.Findings = append(report.Findings, fmt.Sprintf("STARTTLS command injection: a command sent before the TLS upgrade was answered inside the encrypted session (%s)", reply))
+	}
+
+	return nil
+}
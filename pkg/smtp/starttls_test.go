@@ -0,0 +1,92 @@
+// Package smtp provides SMTP diagnostic functionality.
+package smtp
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestCheckSTARTTLSSecurity tests the STARTTLS checks against vulnerable, safe and broken servers
+func TestCheckSTARTTLSSecurity(t *testing.T) {
+	tests := []struct {
+		name           string
+		withTLS        bool
+		injection      bool
+		brokenTLS      bool
+		wantAdvertised bool
+		wantHandshake  bool
+		wantTested     bool
+		wantVulnerable bool
+		wantFindings   int
+	}{
+		{
+			name:           "vulnerable to injection",
+			withTLS:        true,
+			injection:      true,
+			wantAdvertised: true,
+			wantHandshake:  true,
+			wantTested:     true,
+			wantVulnerable: true,
+			wantFindings:   1,
+		},
+		{
+			name:           "discards pipelined input",
+			withTLS:        true,
+			wantAdvertised: true,
+			wantHandshake:  true,
+			wantTested:     true,
+		},
+		{
+			name:           "advertised but broken",
+			withTLS:        true,
+			brokenTLS:      true,
+			wantAdvertised: true,
+			wantFindings:   1,
+		},
+		{
+			name: "not advertised",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeServer(t, func(cmd string) string { return "" })
+			if tt.withTLS {
+				server.tlsConfig = selfSignedTLSConfig(t)
+			}
+			server.starttlsInjection = tt.injection
+			server.brokenTLS = tt.brokenTLS
+			host, port := server.addr()
+
+			report, err := CheckSTARTTLSSecurity(context.Background(), STARTTLSSecurityOptions{
+				Host:    host,
+				Port:    port,
+				Wait:    200 * time.Millisecond,
+				Timeout: 2 * time.Second,
+			})
+			if err != nil {
+				t.Fatalf("CheckSTARTTLSSecurity() error = %v", err)
+			}
+
+			if report.Advertised != tt.wantAdvertised {
+				t.Errorf("Advertised = %v, want %v", report.Advertised, tt.wantAdvertised)
+			}
+			if report.HandshakeSucceeded != tt.wantHandshake {
+				t.Errorf("HandshakeSucceeded = %v, want %v", report.HandshakeSucceeded, tt.wantHandshake)
+			}
+			if report.InjectionTested != tt.wantTested {
+				t.Errorf("InjectionTested = %v, want %v", report.InjectionTested, tt.wantTested)
+			}
+			if report.InjectionVulnerable != tt.wantVulnerable {
+				t.Errorf("InjectionVulnerable = %v, want %v", report.InjectionVulnerable, tt.wantVulnerable)
+			}
+			if len(report.Findings) != tt.wantFindings {
+				t.Errorf("Findings = %v, want %d", report.Findings, tt.wantFindings)
+			}
+			if tt.wantHandshake && report.TLSVersion == "" {
+				t.Errorf("TLSVersion is empty after a successful handshake")
+			}
+		})
+	}
+}
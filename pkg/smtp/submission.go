@@ -0,0 +1,371 @@
+// Package smtp provides SMTP diagnostic functionality.
+package smtp
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"mxclone/pkg/types"
+)
+
+// Submission test stages, in the order they run
+const (
+	StageConnect = "connect"
+	StageTLS     = "tls"
+	StageEHLO    = "ehlo"
+	StageAuth    = "auth"
+	StageMail    = "mail"
+	StageRcpt    = "rcpt"
+	StageData    = "data"
+)
+
+// TrackingHeader is the header carrying the test ID of generated test messages.
+const TrackingHeader = "X-MXClone-Test-ID"
+
+// submissionMechanisms are the mechanisms tried, in order, when none is requested.
+// XOAUTH2 needs a token instead of a password, so it is only used when asked for.
+var submissionMechanisms = []string{"PLAIN", "LOGIN", "CRAM-MD5"}
+
+// SubmissionTestOptions configures an authenticated submission test.
+type SubmissionTestOptions struct {
+	// Host is the submission server to test
+	Host string
+	// Port is the submission port (default 587; 465 uses implicit TLS)
+	Port int
+	// Username and Password are the credentials; for XOAUTH2 Password is the access token
+	Username string
+	Password string
+	// Mechanism forces a SASL mechanism (PLAIN, LOGIN, CRAM-MD5, XOAUTH2); empty picks the best offered
+	Mechanism string
+	// AllowPlaintextAuth permits AUTH on a session that could not be encrypted
+	AllowPlaintextAuth bool
+	// FromAddress and ToAddress are the envelope and header addresses of the test message
+	FromAddress string
+	ToAddress   string
+	// SendMessage sends a generated test message after MAIL FROM and RCPT TO
+	SendMessage bool
+	// HeloName is the name announced in EHLO
+	HeloName string
+	// Timeout applies to the connection and to each command
+	Timeout time.Duration
+}
+
+// RunSubmissionTest connects to a submission server, secures the session, authenticates and
+// optionally submits a test message, recording the outcome of every stage.
+// A stage that cannot run because an earlier one failed is reported as skipped.
+func RunSubmissionTest(ctx context.Context, opts SubmissionTestOptions) (*types.SubmissionTestReport, error) {
+	if opts.Port == 0 {
+		opts.Port = 587
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	if opts.FromAddress == "" {
+		opts.FromAddress = opts.Username
+	}
+
+	report := &types.SubmissionTestReport{
+		Host:        opts.Host,
+		Port:        opts.Port,
+		ImplicitTLS: opts.Port == 465,
+		Stages:      []types.SubmissionStage{},
+	}
+
+	client, err := runSubmissionStages(ctx, opts, report)
+	if client != nil {
+		client.Quit()
+		report.Transcript = client.Transcript
+	}
+	if err != nil {
+		report.Error = err.Error()
+	}
+
+	// Every stage after a failure is skipped so that the report always lists the full sequence
+	for _, name := range submissionStageNames(opts) {
+		if !hasStage(report, name) {
+			report.Stages = append(report.Stages, types.SubmissionStage{Name: name, Skipped: true})
+		}
+	}
+
+	return report, err
+}
+
+// runSubmissionStages runs the stages in order and stops at the first failure.
+// The client is returned whenever a connection was made so that the caller can close it.
+func runSubmissionStages(ctx context.Context, opts SubmissionTestOptions, report *types.SubmissionTestReport) (*Client, error) {
+	// connect, with the TLS handshake first on port 465
+	start := time.Now()
+	client, err := Dial(ctx, opts.Host, opts.Port, opts.Timeout, report.ImplicitTLS)
+	stage := types.SubmissionStage{Name: StageConnect, Duration: time.Since(start)}
+	if err != nil {
+		stage.Error = err.Error()
+		report.Stages = append(report.Stages, stage)
+		return nil, fmt.Errorf("connect failed: %w", err)
+	}
+	setStageReply(&stage, client.Banner)
+	report.Stages = append(report.Stages, stage)
+	if client.Banner.Code != 220 {
+		return client, fmt.Errorf("server refused the session: %s", client.Banner)
+	}
+
+	// ehlo, then STARTTLS when the port does not use implicit TLS
+	if err := submissionHello(client, opts, report); err != nil {
+		return client, err
+	}
+
+	start = time.Now()
+	stage = types.SubmissionStage{Name: StageTLS}
+	upgraded := false
+	if report.ImplicitTLS {
+		stage.Success = true
+		stage.Response = "implicit TLS"
+	} else if ok, _ := client.Extension("STARTTLS"); !ok {
+		stage.Error = "STARTTLS not offered"
+	} else {
+		reply, err := client.StartTLS(nil)
+		setStageReply(&stage, reply)
+		switch {
+		case err != nil:
+			stage.Success = false
+			stage.Error = err.Error()
+		case reply.Code != 220:
+			stage.Success = false
+			stage.Error = "STARTTLS refused"
+		default:
+			upgraded = true
+		}
+	}
+	stage.Duration = time.Since(start)
+	if state, ok := client.TLSState(); ok {
+		report.TLSVersion = tls.VersionName(state.Version)
+	}
+	report.Stages = append(report.Stages, stage)
+
+	switch {
+	case upgraded:
+		// Capabilities must be discovered again on the encrypted session (RFC 3207)
+		if err := submissionHello(client, opts, report); err != nil {
+			return client, err
+		}
+	case !stage.Success && !opts.AllowPlaintextAuth:
+		return client, fmt.Errorf("TLS failed: %s; refusing to send credentials over an unencrypted session", stage.Error)
+	case !stage.Success && stage.ResponseCode == 220:
+		// A failed handshake leaves the connection unusable, so start over in plaintext
+		client.Close()
+		client, err = Dial(ctx, opts.Host, opts.Port, opts.Timeout, false)
+		if err != nil {
+			return nil, fmt.Errorf("reconnect failed: %w", err)
+		}
+		if err := submissionHello(client, opts, report); err != nil {
+			return client, err
+		}
+	}
+	report.AuthMechanisms = client.AuthMechanisms()
+
+	// auth
+	if err := submissionAuth(client, opts, report); err != nil {
+		return client, err
+	}
+
+	// mail, rcpt and data
+	if opts.ToAddress == "" {
+		return client, nil
+	}
+
+	start = time.Now()
+	reply, err := client.Mail(opts.FromAddress)
+	if !recordCommandStage(report, StageMail, start, reply, err) {
+		return client, fmt.Errorf("MAIL FROM failed: %s", stageError(reply, err))
+	}
+
+	start = time.Now()
+	reply, err = client.Rcpt(opts.ToAddress)
+	if !recordCommandStage(report, StageRcpt, start, reply, err) {
+		return client, fmt.Errorf("RCPT TO failed: %s", stageError(reply, err))
+	}
+
+	if !opts.SendMessage {
+		client.Reset()
+		return client, nil
+	}
+
+	trackingID := newTrackingID()
+	report.TrackingID = trackingID
+	report.MessageID = fmt.Sprintf("<mxclone-test-%s@%s>", trackingID, addressDomain(opts.FromAddress))
+
+	start = time.Now()
+	reply, err = client.Data(buildTestMessage(opts, report.MessageID, trackingID))
+	if !recordCommandStage(report, StageData, start, reply, err) {
+		return client, fmt.Errorf("DATA failed: %s", stageError(reply, err))
+	}
+	report.MessageSent = true
+
+	return client, nil
+}
+
+// submissionHello sends EHLO and records the ehlo stage; an EHLO repeated after STARTTLS replaces the first one.
+func submissionHello(client *Client, opts SubmissionTestOptions, report *types.SubmissionTestReport) error {
+	start := time.Now()
+	reply, err := client.Hello(opts.HeloName)
+	stage := types.SubmissionStage{Name: StageEHLO, Duration: time.Since(start)}
+	setStageReply(&stage, reply)
+	if err != nil {
+		stage.Success = false
+		stage.Error = err.Error()
+	}
+
+	replaced := false
+	for i := range report.Stages {
+		if report.Stages[i].Name == StageEHLO {
+			report.Stages[i] = stage
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		report.Stages = append(report.Stages, stage)
+	}
+
+	if !stage.Success {
+		return fmt.Errorf("EHLO failed: %s", stageError(reply, err))
+	}
+	return nil
+}
+
+// submissionAuth picks a mechanism and authenticates, recording the auth stage.
+func submissionAuth(client *Client, opts SubmissionTestOptions, report *types.SubmissionTestReport) error {
+	stage := types.SubmissionStage{Name: StageAuth}
+	defer func() {
+		report.Stages = append(report.Stages, stage)
+	}()
+
+	if opts.Username == "" || opts.Password == "" {
+		stage.Skipped = true
+		stage.Response = "no credentials given"
+		return nil
+	}
+
+	mechanism := strings.ToUpper(opts.Mechanism)
+	if mechanism == "" {
+		for _, m := range submissionMechanisms {
+			if client.supportsAuth(m) {
+				mechanism = m
+				break
+			}
+		}
+	}
+	if mechanism == "" {
+		stage.Error = fmt.Sprintf("no supported AUTH mechanism (server offers %s)", strings.Join(report.AuthMechanisms, ", "))
+		return fmt.Errorf("AUTH failed: %s", stage.Error)
+	}
+	if !client.supportsAuth(mechanism) {
+		stage.Error = fmt.Sprintf("server does not offer %s", mechanism)
+		return fmt.Errorf("AUTH failed: %s", stage.Error)
+	}
+	report.Mechanism = mechanism
+
+	start := time.Now()
+	reply, err := client.AuthWithMechanism(mechanism, opts.Username, opts.Password)
+	stage.Duration = time.Since(start)
+	setStageReply(&stage, reply)
+	if err != nil || reply.Code != 235 {
+		stage.Success = false
+		stage.Error = stageError(reply, err)
+		return fmt.Errorf("AUTH %s failed: %s", mechanism, stage.Error)
+	}
+
+	report.Authenticated = true
+	return nil
+}
+
+// recordCommandStage records the stage of a single command and reports whether it succeeded.
+func recordCommandStage(report *types.SubmissionTestReport, name string, start time.Time, reply *Reply, err error) bool {
+	stage := types.SubmissionStage{Name: name, Duration: time.Since(start)}
+	setStageReply(&stage, reply)
+	if err != nil {
+		stage.Success = false
+		stage.Error = err.Error()
+	}
+	report.Stages = append(report.Stages, stage)
+	return stage.Success
+}
+
+// setStageReply fills the reply fields of a stage; a positive reply marks the stage successful.
+func setStageReply(stage *types.SubmissionStage, reply *Reply) {
+	if reply == nil {
+		return
+	}
+	stage.ResponseCode = reply.Code
+	stage.EnhancedCode = reply.EnhancedCode()
+	stage.Response = reply.Text()
+	stage.Success = reply.Positive()
+}
+
+// stageError describes why a command failed.
+func stageError(reply *Reply, err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return reply.String()
+}
+
+// submissionStageNames lists the stages a test with these options runs.
+func submissionStageNames(opts SubmissionTestOptions) []string {
+	names := []string{StageConnect, StageEHLO, StageTLS, StageAuth}
+	if opts.ToAddress != "" {
+		names = append(names, StageMail, StageRcpt)
+		if opts.SendMessage {
+			names = append(names, StageData)
+		}
+	}
+	return names
+}
+
+// hasStage reports whether a stage was recorded.
+func hasStage(report *types.SubmissionTestReport, name string) bool {
+	for _, stage := range report.Stages {
+		if stage.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// newTrackingID returns a random identifier for a test message.
+func newTrackingID() string {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// addressDomain returns the domain part of an address, or a placeholder.
+func addressDomain(address string) string {
+	if _, domain, found := strings.Cut(address, "@"); found && domain != "" {
+		return domain
+	}
+	return "mxclone.invalid"
+}
+
+// buildTestMessage generates the test message with a unique Message-ID and tracking header.
+func buildTestMessage(opts SubmissionTestOptions, messageID, trackingID string) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: <%s>\r\n", opts.FromAddress)
+	fmt.Fprintf(&b, "To: <%s>\r\n", opts.ToAddress)
+	fmt.Fprintf(&b, "Subject: mxclone submission test %s\r\n", trackingID)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "Message-ID: %s\r\n", messageID)
+	fmt.Fprintf(&b, "%s: %s\r\n", TrackingHeader, trackingID)
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	fmt.Fprintf(&b, "This is a test message sent by mxclone to verify authenticated submission\r\n")
+	fmt.Fprintf(&b, "through %s:%d. Test ID: %s\r\n", opts.Host, opts.Port, trackingID)
+	return []byte(b.String())
+}
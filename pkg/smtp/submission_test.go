@@ -0,0 +1,203 @@
+// Package smtp provides SMTP diagnostic functionality.
+package smtp
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"mxclone/pkg/types"
+)
+
+// authResponder answers EHLO with the given mechanisms and accepts user/secret for each of them.
+func authResponder(mechanisms string) func(cmd string) string {
+	const challenge = "<1896.697170952@fake.example.com>"
+	plain := base64.StdEncoding.EncodeToString([]byte("\x00user\x00secret"))
+	xoauth2 := base64.StdEncoding.EncodeToString([]byte("user=user\x01auth=Bearer secret\x01\x01"))
+	mac := hmac.New(md5.New, []byte("secret"))
+	mac.Write([]byte(challenge))
+	cramMD5 := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("user %x", mac.Sum(nil))))
+
+	return func(cmd string) string {
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"):
+			return "250-fake.example.com Hello\r\n250-AUTH " + mechanisms + "\r\n250 8BITMIME"
+		case cmd == "AUTH PLAIN "+plain, cmd == "AUTH XOAUTH2 "+xoauth2, cmd == cramMD5:
+			return "235 2.7.0 Authentication successful"
+		case strings.HasPrefix(cmd, "AUTH PLAIN"), strings.HasPrefix(cmd, "AUTH XOAUTH2"):
+			return "535 5.7.8 Authentication credentials invalid"
+		case cmd == "AUTH CRAM-MD5":
+			return "334 " + base64.StdEncoding.EncodeToString([]byte(challenge))
+		case cmd == "AUTH LOGIN":
+			return "334 VXNlcm5hbWU6"
+		case cmd == base64.StdEncoding.EncodeToString([]byte("user")):
+			return "334 UGFzc3dvcmQ6"
+		case cmd == base64.StdEncoding.EncodeToString([]byte("secret")):
+			return "235 2.7.0 Authentication successful"
+		case strings.HasPrefix(cmd, "RCPT"):
+			return "250 2.1.5 OK"
+		}
+		return ""
+	}
+}
+
+// stageByName returns the named stage of a report.
+func stageByName(t *testing.T, stages []types.SubmissionStage, name string) types.SubmissionStage {
+	t.Helper()
+	for _, stage := range stages {
+		if stage.Name == name {
+			return stage
+		}
+	}
+	t.Fatalf("stage %s missing", name)
+	return types.SubmissionStage{}
+}
+
+// TestRunSubmissionTestMechanisms tests authentication with each supported mechanism
+func TestRunSubmissionTestMechanisms(t *testing.T) {
+	tests := []struct {
+		mechanism string
+		password  string
+		wantAuth  bool
+	}{
+		{"PLAIN", "secret", true},
+		{"LOGIN", "secret", true},
+		{"CRAM-MD5", "secret", true},
+		{"XOAUTH2", "secret", true},
+		{"PLAIN", "wrong", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mechanism+"/"+tt.password, func(t *testing.T) {
+			server := newFakeServer(t, authResponder("PLAIN LOGIN CRAM-MD5 XOAUTH2"))
+			server.tlsConfig = selfSignedTLSConfig(t)
+			host, port := server.addr()
+
+			report, err := RunSubmissionTest(context.Background(), SubmissionTestOptions{
+				Host:      host,
+				Port:      port,
+				Username:  "user",
+				Password:  tt.password,
+				Mechanism: tt.mechanism,
+				Timeout:   2 * time.Second,
+			})
+			if (err == nil) != tt.wantAuth {
+				t.Fatalf("RunSubmissionTest() error = %v, want success %v", err, tt.wantAuth)
+			}
+
+			if report.Authenticated != tt.wantAuth {
+				t.Errorf("Authenticated = %v, want %v", report.Authenticated, tt.wantAuth)
+			}
+			if report.Mechanism != tt.mechanism {
+				t.Errorf("Mechanism = %q, want %q", report.Mechanism, tt.mechanism)
+			}
+			if report.TLSVersion == "" {
+				t.Errorf("TLSVersion is empty, want the session upgraded with STARTTLS")
+			}
+			if tls := stageByName(t, report.Stages, StageTLS); !tls.Success {
+				t.Errorf("tls stage = %+v, want success", tls)
+			}
+			if auth := stageByName(t, report.Stages, StageAuth); auth.Success != tt.wantAuth {
+				t.Errorf("auth stage = %+v, want success %v", auth, tt.wantAuth)
+			}
+
+			for _, entry := range report.Transcript {
+				if strings.Contains(entry.Line, base64.StdEncoding.EncodeToString([]byte("secret"))) {
+					t.Errorf("transcript leaks the password: %q", entry.Line)
+				}
+			}
+		})
+	}
+}
+
+// TestRunSubmissionTestMessage tests sending the generated test message
+func TestRunSubmissionTestMessage(t *testing.T) {
+	server := newFakeServer(t, authResponder("PLAIN"))
+	server.tlsConfig = selfSignedTLSConfig(t)
+	host, port := server.addr()
+
+	report, err := RunSubmissionTest(context.Background(), SubmissionTestOptions{
+		Host:        host,
+		Port:        port,
+		Username:    "user",
+		Password:    "secret",
+		FromAddress: "user@example.com",
+		ToAddress:   "probe@example.org",
+		SendMessage: true,
+		Timeout:     2 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("RunSubmissionTest() error = %v", err)
+	}
+
+	if !report.MessageSent {
+		t.Fatalf("MessageSent = false, want true (stages: %+v)", report.Stages)
+	}
+	for _, name := range []string{StageConnect, StageEHLO, StageTLS, StageAuth, StageMail, StageRcpt, StageData} {
+		if stage := stageByName(t, report.Stages, name); !stage.Success {
+			t.Errorf("stage %s = %+v, want success", name, stage)
+		}
+	}
+	if data := stageByName(t, report.Stages, StageData); data.EnhancedCode != "2.0.0" {
+		t.Errorf("data stage enhanced code = %q, want 2.0.0", data.EnhancedCode)
+	}
+
+	messages := server.receivedMessages()
+	if len(messages) != 1 {
+		t.Fatalf("server received %d messages, want 1", len(messages))
+	}
+	if !strings.Contains(messages[0], "Message-ID: "+report.MessageID) {
+		t.Errorf("message does not carry Message-ID %s", report.MessageID)
+	}
+	if !strings.Contains(messages[0], TrackingHeader+": "+report.TrackingID) {
+		t.Errorf("message does not carry tracking header %s", report.TrackingID)
+	}
+}
+
+// TestRunSubmissionTestRequiresTLS tests that credentials are not sent over an unencrypted session
+func TestRunSubmissionTestRequiresTLS(t *testing.T) {
+	tests := []struct {
+		name           string
+		allowPlaintext bool
+		wantAuth       bool
+	}{
+		{"Plaintext refused", false, false},
+		{"Plaintext allowed", true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeServer(t, authResponder("PLAIN"))
+			host, port := server.addr()
+
+			report, _ := RunSubmissionTest(context.Background(), SubmissionTestOptions{
+				Host:               host,
+				Port:               port,
+				Username:           "user",
+				Password:           "secret",
+				AllowPlaintextAuth: tt.allowPlaintext,
+				Timeout:            2 * time.Second,
+			})
+
+			if report.Authenticated != tt.wantAuth {
+				t.Errorf("Authenticated = %v, want %v", report.Authenticated, tt.wantAuth)
+			}
+			if stage := stageByName(t, report.Stages, StageTLS); stage.Success {
+				t.Errorf("tls stage = %+v, want failure without STARTTLS", stage)
+			}
+			if !tt.allowPlaintext {
+				if auth := stageByName(t, report.Stages, StageAuth); !auth.Skipped {
+					t.Errorf("auth stage = %+v, want skipped", auth)
+				}
+				if countCommands(server.received(), "AUTH") != 0 {
+					t.Errorf("credentials were sent over an unencrypted session")
+				}
+			}
+		})
+	}
+}
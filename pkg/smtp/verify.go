@@ -0,0 +1,102 @@
+// Package smtp provides SMTP diagnostic functionality.
+package smtp
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"mxclone/pkg/types"
+)
+
+// RecipientProbeOptions configures an RCPT TO probe.
+type RecipientProbeOptions struct {
+	// Host is the mail exchanger to probe
+	Host string
+	// Port is the SMTP port (default 25)
+	Port int
+	// Recipients are the addresses offered in RCPT TO, in order, within a single transaction
+	Recipients []string
+	// HeloName is the name announced in EHLO
+	HeloName string
+	// Timeout applies to the connection and to each command
+	Timeout time.Duration
+}
+
+// ProbeRecipients asks a mail exchanger whether it accepts each recipient without sending mail.
+// The probe uses the null sender (MAIL FROM:<>), offers every recipient in one transaction and
+// ends it with RSET; DATA is never sent. STARTTLS is used when the server offers it.
+func ProbeRecipients(ctx context.Context, opts RecipientProbeOptions) (*types.RecipientProbeReport, error) {
+	if opts.Port == 0 {
+		opts.Port = 25
+	}
+
+	report := &types.RecipientProbeReport{
+		Host: opts.Host,
+		Port: opts.Port,
+	}
+
+	client, err := Dial(ctx, opts.Host, opts.Port, opts.Timeout, opts.Port == 465)
+	if err != nil {
+		report.Error = err.Error()
+		return report, err
+	}
+	defer func() {
+		report.Transcript = client.Transcript
+		client.Close()
+	}()
+
+	report.Banner = client.Banner.Text()
+	if !client.Banner.Positive() {
+		err := fmt.Errorf("server refused the connection: %s", client.Banner)
+		report.Error = err.Error()
+		return report, err
+	}
+
+	if _, err := client.Hello(opts.HeloName); err != nil {
+		report.Error = err.Error()
+		return report, err
+	}
+	if ok, _ := client.Extension("STARTTLS"); ok && !client.TLS() {
+		if reply, err := client.StartTLS(tlsConfigFor(opts.Host)); err == nil && reply.Positive() {
+			if _, err := client.Hello(opts.HeloName); err != nil {
+				report.Error = err.Error()
+				return report, err
+			}
+		}
+	}
+	report.TLS = client.TLS()
+
+	reply, err := client.Mail("")
+	if err != nil {
+		report.Error = err.Error()
+		return report, err
+	}
+	report.MailCode = reply.Code
+	if !reply.Positive() {
+		err := fmt.Errorf("null sender refused: %s", reply)
+		report.Error = err.Error()
+		return report, err
+	}
+
+	for _, recipient := range opts.Recipients {
+		result := types.RecipientProbeResult{Address: recipient}
+		reply, err := client.Rcpt(recipient)
+		if err != nil {
+			result.Error = err.Error()
+			report.Results = append(report.Results, result)
+			break
+		}
+		result.Code = reply.Code
+		result.EnhancedCode = reply.EnhancedCode()
+		result.Response = reply.Text()
+		result.Accepted = reply.Positive()
+		result.Deferred = reply.Transient()
+		result.Rejected = reply.Permanent()
+		report.Results = append(report.Results, result)
+	}
+
+	client.Reset()
+	client.Quit()
+	return report, nil
+}
@@ -0,0 +1,106 @@
+// Package smtp provides SMTP diagnostic functionality.
+package smtp
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestProbeRecipients tests RCPT TO probing with the null sender and no DATA
+func TestProbeRecipients(t *testing.T) {
+	server := newFakeServer(t, func(cmd string) string {
+		switch {
+		case cmd == "RCPT TO:<known@example.com>":
+			return "250 2.1.5 OK"
+		case cmd == "RCPT TO:<busy@example.com>":
+			return "451 4.7.1 Greylisted, try again later"
+		case strings.HasPrefix(cmd, "RCPT"):
+			return "550 5.1.1 No such user"
+		}
+		return ""
+	})
+	host, port := server.addr()
+
+	report, err := ProbeRecipients(context.Background(), RecipientProbeOptions{
+		Host:       host,
+		Port:       port,
+		Recipients: []string{"known@example.com", "busy@example.com", "random@example.com"},
+		Timeout:    2 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("ProbeRecipients() error = %v", err)
+	}
+
+	tests := []struct {
+		address      string
+		code         int
+		enhancedCode string
+		accepted     bool
+		deferred     bool
+		rejected     bool
+	}{
+		{"known@example.com", 250, "2.1.5", true, false, false},
+		{"busy@example.com", 451, "4.7.1", false, true, false},
+		{"random@example.com", 550, "5.1.1", false, false, true},
+	}
+	if len(report.Results) != len(tests) {
+		t.Fatalf("got %d results, want %d", len(report.Results), len(tests))
+	}
+	for i, tt := range tests {
+		got := report.Results[i]
+		if got.Address != tt.address || got.Code != tt.code || got.EnhancedCode != tt.enhancedCode ||
+			got.Accepted != tt.accepted || got.Deferred != tt.deferred || got.Rejected != tt.rejected {
+			t.Errorf("result %d = %+v, want %+v", i, got, tt)
+		}
+	}
+
+	commands := server.received()
+	if !containsCommand(commands, "MAIL FROM:<>") {
+		t.Errorf("null sender not used, commands: %v", commands)
+	}
+	if countCommands(commands, "DATA") != 0 {
+		t.Errorf("DATA must never be sent, commands: %v", commands)
+	}
+	if countCommands(commands, "RSET") != 1 {
+		t.Errorf("transaction not reset, commands: %v", commands)
+	}
+	if len(report.Transcript) == 0 {
+		t.Error("transcript not recorded")
+	}
+}
+
+// TestProbeRecipientsNullSenderRefused tests a server refusing the null sender
+func TestProbeRecipientsNullSenderRefused(t *testing.T) {
+	server := newFakeServer(t, func(cmd string) string {
+		if strings.HasPrefix(cmd, "MAIL") {
+			return "550 5.7.1 Null sender not accepted"
+		}
+		return ""
+	})
+	host, port := server.addr()
+
+	report, err := ProbeRecipients(context.Background(), RecipientProbeOptions{
+		Host:       host,
+		Port:       port,
+		Recipients: []string{"known@example.com"},
+		Timeout:    2 * time.Second,
+	})
+	if err == nil {
+		t.Fatal("expected an error when the null sender is refused")
+	}
+	if report.MailCode != 550 || len(report.Results) != 0 {
+		t.Errorf("report = %+v, want MAIL refused and no RCPT", report)
+	}
+}
+
+// containsCommand reports whether a command was received verbatim
+func containsCommand(commands []string, command string) bool {
+	for _, cmd := range commands {
+		if cmd == command {
+			return true
+		}
+	}
+	return false
+}
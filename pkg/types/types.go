@@ -34,13 +34,835 @@ type SMTPResult struct {
 	STARTTLSError   string        `json:"starttlsError,omitempty"`
 	IsOpenRelay     *bool         `json:"isOpenRelay,omitempty"`
 	RelayCheckError string        `json:"relayCheckError,omitempty"`
+	RelayTests      []RelayTest   `json:"relayTests,omitempty"`
 	ResponseTime    time.Duration `json:"responseTime,omitempty"`
+	STARTTLSInjection *bool       `json:"starttlsInjection,omitempty"` // Nil when not tested
+	SecurityFindings []string     `json:"securityFindings,omitempty"`
+	Port            int           `json:"port,omitempty"`  // Port the results above were taken from
+	Ports           []SMTPPortResult `json:"ports,omitempty"` // One entry per port that was checked
+}
+
+// SMTPPortResult represents the connection and STARTTLS check of a single SMTP port.
+type SMTPPortResult struct {
+	Port             int           `json:"port"`
+	ConnectSuccess   bool          `json:"connectSuccess"`
+	ConnectError     string        `json:"connectError,omitempty"`
+	SupportsSTARTTLS *bool         `json:"supportsStarttls,omitempty"` // Nil for implicit TLS (465) or when the check failed
+	STARTTLSError    string        `json:"starttlsError,omitempty"`
+	ResponseTime     time.Duration `json:"responseTime,omitempty"`
+}
+
+// TranscriptEntry represents a single event of an SMTP session transcript.
+type TranscriptEntry struct {
+	Timestamp    time.Time     `json:"timestamp"`
+	Elapsed      time.Duration `json:"elapsed"`   // Time since the session started
+	Direction    string        `json:"direction"` // client, server, tls, info
+	Line         string        `json:"line"`
+	Code         int           `json:"code,omitempty"`
+	EnhancedCode string        `json:"enhancedCode,omitempty"`
+	Duration     time.Duration `json:"duration,omitempty"` // For server replies, time since the command was sent
+}
+
+// RelayTest represents a single open relay probe (one MAIL FROM/RCPT TO pair).
+type RelayTest struct {
+	Name         string            `json:"name"`
+	Description  string            `json:"description,omitempty"`
+	MailFrom     string            `json:"mailFrom"`
+	RcptTo       string            `json:"rcptTo"`
+	Accepted     bool              `json:"accepted"`
+	Verdict      string            `json:"verdict"` // relayed, rejected, tempfail, error
+	ResponseCode int               `json:"responseCode,omitempty"`
+	Response     string            `json:"response,omitempty"`
+	Transcript   []TranscriptEntry `json:"transcript,omitempty"`
+	Error        string            `json:"error,omitempty"`
+}
+
+// RelayTestReport represents the result of a full open relay test battery.
+type RelayTestReport struct {
+	Host          string      `json:"host"`
+	Port          int         `json:"port"`
+	Banner        string      `json:"banner,omitempty"`
+	IsOpenRelay   bool        `json:"isOpenRelay"`
+	AuthRequired  bool        `json:"authRequired"`
+	Authenticated bool        `json:"authenticated"`
+	Tests         []RelayTest `json:"tests"`
+	Error         string      `json:"error,omitempty"`
+}
+
+// BannerAnalysis represents how an SMTP server greets and answers a client.
+type BannerAnalysis struct {
+	Banner              string        `json:"banner"`
+	GreetingCode        int           `json:"greetingCode"`
+	GreetingDelay       time.Duration `json:"greetingDelay"`
+	MultiLineGreeting   bool          `json:"multiLineGreeting"`
+	PreGreetPause       bool          `json:"preGreetPause"`       // Greeting deliberately delayed
+	EarlyTalkerTested   bool          `json:"earlyTalkerTested"`   // Whether the early talker probe ran
+	EarlyTalkerRejected bool          `json:"earlyTalkerRejected"` // Clients talking before the greeting are rejected
+	AverageResponseTime time.Duration `json:"averageResponseTime"`
+	Tarpitting          bool          `json:"tarpitting"`
+	RcptCode            int           `json:"rcptCode,omitempty"` // Reply to RCPT TO:<postmaster@domain>
+	RcptResponse        string        `json:"rcptResponse,omitempty"`
+	GreylistSuspected   bool          `json:"greylistSuspected"`
+	Greylisting         bool          `json:"greylisting"` // Confirmed by a successful retry
+	RetryCode           int           `json:"retryCode,omitempty"`
+	BannerHostname      string        `json:"bannerHostname,omitempty"`
+	ServerIP            string        `json:"serverIp,omitempty"`
+	PTRNames            []string      `json:"ptrNames,omitempty"`
+	MatchesPTR          bool          `json:"matchesPtr"`
+	MatchesMX           bool          `json:"matchesMx"`
+	Software            string        `json:"software,omitempty"`
+	SoftwareVersion     string        `json:"softwareVersion,omitempty"`
+	LeaksVersion        bool          `json:"leaksVersion"`
+	Findings            []string      `json:"findings"`
+	Error               string        `json:"error,omitempty"`
+}
+
+// SubmissionStage represents the outcome of one stage of a submission test (connect, tls, ehlo, auth, mail, rcpt, data).
+type SubmissionStage struct {
+	Name         string        `json:"name"`
+	Success      bool          `json:"success"`
+	Skipped      bool          `json:"skipped,omitempty"`
+	ResponseCode int           `json:"responseCode,omitempty"`
+	EnhancedCode string        `json:"enhancedCode,omitempty"`
+	Response     string        `json:"response,omitempty"`
+	Duration     time.Duration `json:"duration"`
+	Error        string        `json:"error,omitempty"`
+}
+
+// SubmissionTestReport represents the result of an authenticated submission test.
+type SubmissionTestReport struct {
+	Host           string            `json:"host"`
+	Port           int               `json:"port"`
+	ImplicitTLS    bool              `json:"implicitTLS"`
+	TLSVersion     string            `json:"tlsVersion,omitempty"`
+	AuthMechanisms []string          `json:"authMechanisms,omitempty"` // Mechanisms offered by the server
+	Mechanism      string            `json:"mechanism,omitempty"`      // Mechanism used
+	Authenticated  bool              `json:"authenticated"`
+	MessageSent    bool              `json:"messageSent"`
+	MessageID      string            `json:"messageId,omitempty"`
+	TrackingID     string            `json:"trackingId,omitempty"`
+	Stages         []SubmissionStage `json:"stages"`
+	Transcript     []TranscriptEntry `json:"transcript,omitempty"`
+	Error          string            `json:"error,omitempty"`
+}
+
+// RecipientProbeResult represents the reply of a mail exchanger to one RCPT TO.
+type RecipientProbeResult struct {
+	Address      string `json:"address"`
+	Code         int    `json:"code,omitempty"`
+	EnhancedCode string `json:"enhancedCode,omitempty"`
+	Response     string `json:"response,omitempty"`
+	Accepted     bool   `json:"accepted"`
+	Deferred     bool   `json:"deferred"`
+	Rejected     bool   `json:"rejected"`
+	Error        string `json:"error,omitempty"`
+}
+
+// RecipientProbeReport represents the result of an RCPT TO probe against a mail exchanger.
+type RecipientProbeReport struct {
+	Host       string                 `json:"host"`
+	Port       int                    `json:"port"`
+	Banner     string                 `json:"banner,omitempty"`
+	TLS        bool                   `json:"tls"`
+	MailCode   int                    `json:"mailCode,omitempty"` // Reply to MAIL FROM:<>
+	Results    []RecipientProbeResult `json:"results,omitempty"`
+	Transcript []TranscriptEntry      `json:"transcript,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+}
+
+// SmugglingSequenceResult represents how a server handled one non-standard end-of-data sequence.
+type SmugglingSequenceResult struct {
+	Name       string `json:"name"`              // e.g. "<LF>.<LF>"
+	Outcome    string `json:"outcome"`           // end-of-data, rejected, ignored or error
+	Code       int    `json:"code,omitempty"`
+	Response   string `json:"response,omitempty"`
+	TrackingID string `json:"trackingId,omitempty"` // Of the probe message, when it was accepted
+	Error      string `json:"error,omitempty"`
+}
+
+// SmugglingReport represents the result of an SMTP smuggling probe.
+type SmugglingReport struct {
+	Host                  string                    `json:"host"`
+	Port                  int                       `json:"port"`
+	Recipient             string                    `json:"recipient"`
+	BareLFCommand         string                    `json:"bareLFCommand,omitempty"` // accepted, rejected or ignored
+	BareLFCommandCode     int                       `json:"bareLFCommandCode,omitempty"`
+	BareLFCommandResponse string                    `json:"bareLFCommandResponse,omitempty"`
+	Sequences             []SmugglingSequenceResult `json:"sequences,omitempty"`
+	Vulnerable            bool                      `json:"vulnerable"`
+	Findings              []string                  `json:"findings,omitempty"`
+	Transcript            []TranscriptEntry         `json:"transcript,omitempty"`
+	Error                 string                    `json:"error,omitempty"`
+}
+
+// STARTTLSSecurityReport represents the result of the STARTTLS downgrade and injection checks.
+type STARTTLSSecurityReport struct {
+	Host                string            `json:"host"`
+	Port                int               `json:"port"`
+	Advertised          bool              `json:"advertised"`
+	HandshakeSucceeded  bool              `json:"handshakeSucceeded"`
+	HandshakeError      string            `json:"handshakeError,omitempty"`
+	TLSVersion          string            `json:"tlsVersion,omitempty"`
+	InjectionTested     bool              `json:"injectionTested"`
+	InjectionVulnerable bool              `json:"injectionVulnerable"`
+	InjectionResponse   string            `json:"injectionResponse,omitempty"` // Reply to the injected command, or to the pipelined STARTTLS when refused
+	Findings            []string          `json:"findings,omitempty"`
+	Transcript          []TranscriptEntry `json:"transcript,omitempty"`
+	Error               string            `json:"error,omitempty"`
+}
+
+// SinkMessage represents a message received by the SMTP sink.
+type SinkMessage struct {
+	TestID     string             `json:"testId"`
+	ReceivedAt time.Time          `json:"receivedAt"`
+	ClientIP   string             `json:"clientIp"`
+	Helo       string             `json:"helo,omitempty"`
+	MailFrom   string             `json:"mailFrom"` // Empty for the null reverse-path
+	Recipients []string           `json:"recipients"`
+	TLS        bool               `json:"tls"`
+	TLSVersion string             `json:"tlsVersion,omitempty"`
+	Size       int                `json:"size"`
+	Raw        []byte             `json:"-"`
+	Auth       *MessageAuthReport `json:"auth,omitempty"`
+}
+
+// SPFTraceStep represents one step of an SPF evaluation: a record that was fetched or a term that was evaluated.
+type SPFTraceStep struct {
+	Depth  int    `json:"depth"` // Include and redirect nesting
+	Domain string `json:"domain"`
+	Term   string `json:"term,omitempty"`   // Empty for the record lookup
+	Target string `json:"target,omitempty"` // Domain-spec after macro expansion
+	Result string `json:"result"`           // record, match, no-match, or an SPF result
+	Detail string `json:"detail,omitempty"`
+}
+
+// SPFEvaluation represents the SPF result for a connecting IP and a sender domain.
+type SPFEvaluation struct {
+	Domain      string         `json:"domain"`
+	IP          string         `json:"ip"`
+	Sender      string         `json:"sender,omitempty"`
+	Helo        string         `json:"helo,omitempty"`
+	Result      string         `json:"result"` // pass, fail, softfail, neutral, none, permerror, temperror
+	Record      string         `json:"record,omitempty"`
+	Mechanism   string         `json:"mechanism,omitempty"`   // Mechanism that matched
+	Lookups     int            `json:"lookups"`               // DNS-querying terms evaluated
+	VoidLookups int            `json:"voidLookups"`           // Lookups that returned no answer
+	Explanation string         `json:"explanation,omitempty"` // exp= text for a fail, or why an error result was reached
+	Trace       []SPFTraceStep `json:"trace,omitempty"`
+}
+
+// SPFTermAnalysis represents one term of an SPF record in the lookup tree.
+type SPFTermAnalysis struct {
+	Term        string       `json:"term"`
+	Lookups     int          `json:"lookups"`               // 1 for include, a, mx, ptr, exists and redirect
+	VoidLookups int          `json:"voidLookups,omitempty"` // Lookups of this term that returned no answer
+	MXHosts     int          `json:"mxHosts,omitempty"`     // MX names an mx term resolves (limit 10)
+	Networks    []string     `json:"networks,omitempty"`    // Addresses the term authorizes, as CIDRs
+	Target      *SPFTreeNode `json:"target,omitempty"`      // Record of an include or redirect target
+	Note        string       `json:"note,omitempty"`
+}
+
+// SPFTreeNode represents an SPF record and the records it includes or redirects to.
+type SPFTreeNode struct {
+	Domain        string            `json:"domain"`
+	Record        string            `json:"record,omitempty"`
+	Length        int               `json:"length"`                  // Characters of the record, all strings joined
+	StringLengths []int             `json:"stringLengths,omitempty"` // Length of each TXT character-string
+	Lookups       int               `json:"lookups"`                 // DNS-querying terms in this record and below
+	VoidLookups   int               `json:"voidLookups"`
+	Terms         []SPFTermAnalysis `json:"terms,omitempty"`
+	Error         string            `json:"error,omitempty"`
+}
+
+// SPFFlattenedRecord represents one TXT record of a flattened SPF policy.
+type SPFFlattenedRecord struct {
+	Name   string `json:"name"`
+	Record string `json:"record"`
+	Length int    `json:"length"`
+}
+
+// SPFFlattening represents a candidate SPF policy with include, a and mx terms replaced by addresses.
+type SPFFlattening struct {
+	Records  []SPFFlattenedRecord `json:"records"`  // The domain's record first, then the include: chunks
+	Networks int                  `json:"networks"` // ip4 and ip6 terms across all records
+	Lookups  int                  `json:"lookups"`  // DNS-querying terms left after flattening
+	Warnings []string             `json:"warnings,omitempty"`
+}
+
+// SPFLookupAnalysis represents the include/redirect tree of an SPF record and its DNS lookup budget.
+type SPFLookupAnalysis struct {
+	Domain          string         `json:"domain"`
+	Tree            *SPFTreeNode   `json:"tree,omitempty"`
+	Lookups         int            `json:"lookups"`
+	LookupLimit     int            `json:"lookupLimit"`
+	VoidLookups     int            `json:"voidLookups"`
+	VoidLookupLimit int            `json:"voidLookupLimit"`
+	RecordLength    int            `json:"recordLength"` // Length of the domain's own record
+	Flattened       *SPFFlattening `json:"flattened,omitempty"`
+	Findings        []Finding      `json:"findings,omitempty"`
+	Error           string         `json:"error,omitempty"`
+}
+
+// DMARCReportURI represents one destination of a DMARC rua= or ruf= tag.
+type DMARCReportURI struct {
+	URI                 string `json:"uri"`
+	Scheme              string `json:"scheme"`
+	Address             string `json:"address,omitempty"` // mailto: address
+	Domain              string `json:"domain,omitempty"`  // Domain of the address
+	MaxSize             int64  `json:"maxSize,omitempty"` // In bytes, from the !size suffix
+	External            bool   `json:"external"`          // Outside the organizational domain of the policy
+	Authorized          bool   `json:"authorized"`        // External destination publishes the RFC 7489 section 7.1 record
+	AuthorizationRecord string `json:"authorizationRecord,omitempty"`
+	Error               string `json:"error,omitempty"`
+}
+
+// DMARCLookup represents one DNS query of the DMARC policy discovery.
+type DMARCLookup struct {
+	Name   string `json:"name"`   // _dmarc.<domain>
+	Result string `json:"result"` // record, none, multiple or error
+	Record string `json:"record,omitempty"`
+}
+
+// DMARCPolicyDiscovery represents the DMARC record that applies to a domain and why.
+type DMARCPolicyDiscovery struct {
+	Domain               string        `json:"domain"`
+	OrganizationalDomain string        `json:"organizationalDomain"`   // From the Public Suffix List
+	PolicyDomain         string        `json:"policyDomain,omitempty"` // Domain whose record applies
+	Record               string        `json:"record,omitempty"`
+	Inherited            bool          `json:"inherited"`            // The record belongs to a parent domain
+	NonExistent          bool          `json:"nonExistent"`          // The domain has no A, AAAA, MX or TXT record
+	AppliedTag           string        `json:"appliedTag,omitempty"` // p, sp or np
+	AppliedPolicy        string        `json:"appliedPolicy,omitempty"`
+	Reason               string        `json:"reason"`
+	Lookups              []DMARCLookup `json:"lookups"`
+	Error                string        `json:"error,omitempty"`
+}
+
+// DMARCTagError represents an invalid tag of a DMARC record.
+type DMARCTagError struct {
+	Tag     string `json:"tag"`
+	Value   string `json:"value,omitempty"`
+	Message string `json:"message"`
+}
+
+// DKIMSelectorHit represents a DKIM selector found to publish a key.
+type DKIMSelectorHit struct {
+	Selector    string `json:"selector"`
+	Record      string `json:"record"`
+	CNAME       string `json:"cname,omitempty"`       // Target when the selector is delegated
+	Source      string `json:"source"`                // custom, provider, dictionary or dated
+	Provider    string `json:"provider,omitempty"`    // Sending service the key belongs to
+	Attribution string `json:"attribution,omitempty"` // How the provider was identified: cname, mx/spf or selector
+	KeyType     string `json:"keyType,omitempty"`
+	KeyBits     int    `json:"keyBits,omitempty"`
+	KeyStrength string `json:"keyStrength,omitempty"`
+}
+
+// DKIMDiscovery represents the result of probing a domain for DKIM selectors.
+type DKIMDiscovery struct {
+	Domain         string            `json:"domain"`
+	Providers      []string          `json:"providers,omitempty"` // Sending services detected from MX and SPF
+	Tried          int               `json:"tried"`
+	Wildcard       bool              `json:"wildcard"` // Any selector answers with the same record
+	WildcardRecord string            `json:"wildcardRecord,omitempty"`
+	Selectors      []DKIMSelectorHit `json:"selectors,omitempty"`
+	Error          string            `json:"error,omitempty"`
+}
+
+// DKIMSignatureResult represents the verification of one DKIM-Signature header.
+type DKIMSignatureResult struct {
+	Domain            string     `json:"domain"`
+	Selector          string     `json:"selector"`
+	Algorithm         string     `json:"algorithm"`
+	Canonicalization  string     `json:"canonicalization"`
+	SignedHeaders     []string   `json:"signedHeaders,omitempty"`
+	Identity          string     `json:"identity,omitempty"`
+	Timestamp         *time.Time `json:"timestamp,omitempty"`
+	Expiration        *time.Time `json:"expiration,omitempty"`
+	BodyLength        *int64     `json:"bodyLength,omitempty"`        // l= tag
+	UnsignedBodyBytes int64      `json:"unsignedBodyBytes,omitempty"` // canonical body bytes past l=
+	UnsignedHeaders   []string   `json:"unsignedHeaders,omitempty"`   // present in the message but not covered by h=
+	Warnings          []string   `json:"warnings,omitempty"`
+	Result            string     `json:"result"` // pass, fail, neutral, permerror, temperror
+	Reason            string     `json:"reason,omitempty"`
+}
+
+// DMARCEvaluation represents the DMARC result for the RFC5322.From domain of a message.
+type DMARCEvaluation struct {
+	FromDomain  string `json:"fromDomain"`
+	PolicyDomain string `json:"policyDomain,omitempty"` // Domain whose record applied
+	Record      string `json:"record,omitempty"`
+	Policy      string `json:"policy,omitempty"`
+	SPFAligned  bool   `json:"spfAligned"`
+	DKIMAligned bool   `json:"dkimAligned"`
+	Result      string `json:"result"` // pass, fail, none, permerror, temperror
+	Disposition string `json:"disposition,omitempty"` // Policy applied to a failing message
+	Lookalikes  []LookalikeDomain `json:"lookalikes,omitempty"` // SPF and DKIM domains imitating the From domain
+	Error       string `json:"error,omitempty"`
+}
+
+// LookalikeDomain represents a domain that imitates another organizational domain without belonging to it.
+type LookalikeDomain struct {
+	Domain               string `json:"domain"`
+	Source               string `json:"source,omitempty"` // Where the domain was found: spf or dkim
+	OrganizationalDomain string `json:"organizationalDomain"`
+	ImitatedDomain       string `json:"imitatedDomain"` // Organizational domain it resembles
+	Distance             int    `json:"distance"`       // Edit distance of the registrable labels once confusable characters are replaced
+	Reason               string `json:"reason"`
+}
+
+// MessageHeaderReport represents the header checks of a message.
+type MessageHeaderReport struct {
+	From         string   `json:"from,omitempty"`
+	To           string   `json:"to,omitempty"`
+	Subject      string   `json:"subject,omitempty"`
+	Date         string   `json:"date,omitempty"`
+	MessageID    string   `json:"messageId,omitempty"`
+	ReceivedHops int      `json:"receivedHops"`
+	Findings     []string `json:"findings,omitempty"`
+}
+
+// MessageAuthReport represents the authentication verdict of a received message.
+type MessageAuthReport struct {
+	SPF     *SPFEvaluation        `json:"spf,omitempty"`
+	DKIM    []DKIMSignatureResult `json:"dkim,omitempty"`
+	DMARC   *DMARCEvaluation      `json:"dmarc,omitempty"`
+	Headers *MessageHeaderReport  `json:"headers,omitempty"`
+	Error   string                `json:"error,omitempty"`
+}
+
+// DMARCAggregateReport represents a DMARC aggregate (rua) feedback report (RFC 7489 appendix C).
+type DMARCAggregateReport struct {
+	OrgName   string                 `json:"orgName"` // Reporting organization
+	Email     string                 `json:"email,omitempty"`
+	ReportID  string                 `json:"reportId"`
+	Begin     time.Time              `json:"begin"`
+	End       time.Time              `json:"end"`
+	Domain    string                 `json:"domain"` // policy_published domain
+	ADKIM     string                 `json:"adkim,omitempty"`
+	ASPF      string                 `json:"aspf,omitempty"`
+	Policy    string                 `json:"policy,omitempty"`
+	SubPolicy string                 `json:"subPolicy,omitempty"`
+	Percent   int                    `json:"percent,omitempty"`
+	Records   []DMARCAggregateRecord `json:"records"`
+}
+
+// DMARCAggregateRecord represents one row of an aggregate report: the messages of one source
+// IP with the same identifiers and results.
+type DMARCAggregateRecord struct {
+	SourceIP     string            `json:"sourceIp"`
+	Count        int               `json:"count"`
+	Disposition  string            `json:"disposition"`        // none, quarantine or reject
+	DKIM         string            `json:"dkim"`               // DMARC-aligned DKIM result: pass or fail
+	SPF          string            `json:"spf"`                // DMARC-aligned SPF result: pass or fail
+	Reasons      []string          `json:"reasons,omitempty"`  // Policy override reasons, e.g. forwarded
+	HeaderFrom   string            `json:"headerFrom"`
+	EnvelopeFrom string            `json:"envelopeFrom,omitempty"`
+	DKIMResults  []DMARCAuthResult `json:"dkimResults,omitempty"` // Raw results, aligned or not
+	SPFResults   []DMARCAuthResult `json:"spfResults,omitempty"`
+}
+
+// DMARCAuthResult represents one DKIM or SPF result of an aggregate report record.
+type DMARCAuthResult struct {
+	Domain   string `json:"domain"`
+	Selector string `json:"selector,omitempty"` // DKIM only
+	Scope    string `json:"scope,omitempty"`    // SPF only: mfrom or helo
+	Result   string `json:"result"`
+}
+
+// AuthResultsEntry represents one method result of an Authentication-Results header (RFC 8601).
+type AuthResultsEntry struct {
+	Method     string            `json:"method"` // spf, dkim, dmarc, arc, ...
+	Result     string            `json:"result"` // pass, fail, softfail, none, ...
+	Reason     string            `json:"reason,omitempty"`
+	Properties map[string]string `json:"properties,omitempty"` // e.g. header.d, smtp.mailfrom
+}
+
+// AuthenticationResults represents an Authentication-Results header.
+type AuthenticationResults struct {
+	AuthServID string             `json:"authServId"` // Host that evaluated the message
+	Results    []AuthResultsEntry `json:"results"`
+}
+
+// FeedbackOriginalMessage represents the message (or headers) an ARF report was generated for.
+type FeedbackOriginalMessage struct {
+	HeadersOnly           bool                    `json:"headersOnly"` // text/rfc822-headers part
+	From                  string                  `json:"from,omitempty"`
+	FromDomain            string                  `json:"fromDomain,omitempty"`
+	ReturnPath            string                  `json:"returnPath,omitempty"`
+	To                    string                  `json:"to,omitempty"`
+	Subject               string                  `json:"subject,omitempty"`
+	Date                  string                  `json:"date,omitempty"`
+	MessageID             string                  `json:"messageId,omitempty"`
+	DKIMDomains           []string                `json:"dkimDomains,omitempty"` // d= of the DKIM-Signature headers
+	AuthenticationResults []AuthenticationResults `json:"authenticationResults,omitempty"`
+	Headers               *MessageHeaderReport    `json:"headers,omitempty"`
+}
+
+// FeedbackReport represents an ARF feedback report (RFC 5965), such as a DMARC failure report (RFC 6591).
+type FeedbackReport struct {
+	// Report message
+	ReportFrom    string `json:"reportFrom,omitempty"`
+	ReportSubject string `json:"reportSubject,omitempty"`
+	ReportDate    string `json:"reportDate,omitempty"`
+	HumanReadable string `json:"humanReadable,omitempty"` // First text/plain part
+	// message/feedback-report fields
+	FeedbackType          string                  `json:"feedbackType"` // auth-failure, abuse, fraud, ...
+	UserAgent             string                  `json:"userAgent,omitempty"`
+	Version               string                  `json:"version,omitempty"`
+	AuthFailure           string                  `json:"authFailure,omitempty"`       // dmarc, dkim, spf, ...
+	IdentityAlignment     string                  `json:"identityAlignment,omitempty"` // RFC 7489 section 7.3
+	SourceIP              string                  `json:"sourceIp,omitempty"`
+	ReportingMTA          string                  `json:"reportingMta,omitempty"`
+	ArrivalDate           *time.Time              `json:"arrivalDate,omitempty"`
+	OriginalMailFrom      string                  `json:"originalMailFrom,omitempty"`
+	OriginalRcptTo        []string                `json:"originalRcptTo,omitempty"`
+	ReportedDomain        []string                `json:"reportedDomain,omitempty"`
+	DeliveryResult        string                  `json:"deliveryResult,omitempty"`
+	Incidents             int                     `json:"incidents"`
+	DKIMDomain            string                  `json:"dkimDomain,omitempty"`
+	DKIMSelector          string                  `json:"dkimSelector,omitempty"`
+	DKIMIdentity          string                  `json:"dkimIdentity,omitempty"`
+	SPFDNS                string                  `json:"spfDns,omitempty"`
+	AuthenticationResults []AuthenticationResults `json:"authenticationResults,omitempty"`
+	Fields                map[string][]string     `json:"fields"` // Every field, by lowercase name
+	// Original message
+	Original *FeedbackOriginalMessage `json:"original,omitempty"`
+	Warnings []string                 `json:"warnings,omitempty"`
+}
+
+// HeaderField represents one unfolded header field of a message.
+type HeaderField struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// ReceivedHop represents one Received header (RFC 5321 section 4.4), in transit order.
+type ReceivedHop struct {
+	Hop          int        `json:"hop"`                    // 1 for the first relay after the sender
+	From         string     `json:"from,omitempty"`         // Name the client announced
+	FromHost     string     `json:"fromHost,omitempty"`     // Reverse DNS name recorded by the receiver
+	FromIP       string     `json:"fromIp,omitempty"`       // Address of the client
+	By           string     `json:"by,omitempty"`           // Receiving host
+	With         string     `json:"with,omitempty"`         // Protocol: SMTP, ESMTPS, LMTP, HTTP, ...
+	ID           string     `json:"id,omitempty"`           // Queue ID
+	For          string     `json:"for,omitempty"`          // Recipient
+	Timestamp    *time.Time `json:"timestamp,omitempty"`
+	DelaySeconds *float64   `json:"delaySeconds,omitempty"` // Since the previous hop, or the Date header for the first
+	ClockSkew    bool       `json:"clockSkew"`              // Timestamped before the previous hop
+	TLS          bool       `json:"tls"`
+	TLSDetails   string     `json:"tlsDetails,omitempty"` // e.g. version=TLS1_3 cipher=TLS_AES_256_GCM_SHA384
+	Raw          string     `json:"raw"`
+}
+
+// ARCSetHeaders represents the headers of one ARC set (RFC 8617) as found in a message.
+type ARCSetHeaders struct {
+	Instance              int                    `json:"instance"` // i= tag
+	SealDomain            string                 `json:"sealDomain,omitempty"`
+	SealSelector          string                 `json:"sealSelector,omitempty"`
+	ChainValidation       string                 `json:"chainValidation,omitempty"` // cv= tag: none, pass or fail
+	SignatureDomain       string                 `json:"signatureDomain,omitempty"`
+	SignatureSelector     string                 `json:"signatureSelector,omitempty"`
+	AuthenticationResults *AuthenticationResults `json:"authenticationResults,omitempty"`
+}
+
+// ListHeaders represents the mailing list headers of a message (RFC 2369, RFC 2919, RFC 8058).
+type ListHeaders struct {
+	ID              string   `json:"id,omitempty"`
+	Unsubscribe     []string `json:"unsubscribe,omitempty"`     // URIs of List-Unsubscribe
+	UnsubscribePost string   `json:"unsubscribePost,omitempty"` // List-Unsubscribe=One-Click
+	Subscribe       []string `json:"subscribe,omitempty"`
+	Post            []string `json:"post,omitempty"`
+	Help            []string `json:"help,omitempty"`
+	Owner           []string `json:"owner,omitempty"`
+	Archive         []string `json:"archive,omitempty"`
+}
+
+// HeaderAnalysis represents the analysis of the header section of a message.
+type HeaderAnalysis struct {
+	From                  string                  `json:"from,omitempty"`
+	FromDomain            string                  `json:"fromDomain,omitempty"`
+	To                    string                  `json:"to,omitempty"`
+	Subject               string                  `json:"subject,omitempty"`
+	Date                  string                  `json:"date,omitempty"`
+	MessageID             string                  `json:"messageId,omitempty"`
+	ReturnPath            string                  `json:"returnPath,omitempty"`
+	ReturnPathDomain      string                  `json:"returnPathDomain,omitempty"`
+	ReplyTo               string                  `json:"replyTo,omitempty"`
+	Hops                  []ReceivedHop           `json:"hops,omitempty"`
+	TotalDelaySeconds     *float64                `json:"totalDelaySeconds,omitempty"` // From the Date header or first hop to the last hop
+	AuthenticationResults []AuthenticationResults `json:"authenticationResults,omitempty"`
+	ARC                   []ARCSetHeaders         `json:"arc,omitempty"`
+	DKIMDomains           []string                `json:"dkimDomains,omitempty"`
+	List                  *ListHeaders            `json:"list,omitempty"`
+	Headers               []HeaderField           `json:"headers"`
+	Findings              []string                `json:"findings,omitempty"`
+}
+
+// ARCSetResult represents the verification of one ARC set (RFC 8617).
+type ARCSetResult struct {
+	Instance              int                    `json:"instance"` // i= tag
+	SealDomain            string                 `json:"sealDomain,omitempty"`
+	SealSelector          string                 `json:"sealSelector,omitempty"`
+	SealAlgorithm         string                 `json:"sealAlgorithm,omitempty"`
+	SealTimestamp         *time.Time             `json:"sealTimestamp,omitempty"`
+	ChainValidation       string                 `json:"chainValidation,omitempty"` // cv= recorded by the sealer: none, pass or fail
+	SealResult            string                 `json:"sealResult"`                // pass, fail, permerror or temperror
+	SealReason            string                 `json:"sealReason,omitempty"`
+	MessageSignature      *DKIMSignatureResult   `json:"messageSignature,omitempty"` // ARC-Message-Signature
+	AuthenticationResults *AuthenticationResults `json:"authenticationResults,omitempty"`
+}
+
+// ARCValidation represents the validation of the Authenticated Received Chain of a message (RFC 8617).
+type ARCValidation struct {
+	Result     string         `json:"result"` // none, pass or fail
+	Reason     string         `json:"reason,omitempty"`
+	Instances  int            `json:"instances"`  // Highest instance, the number of intermediaries
+	OldestPass int            `json:"oldestPass"` // Oldest instance whose ARC-Message-Signature still validates; 0 when all do
+	Sets       []ARCSetResult `json:"sets,omitempty"`
+	Findings   []string       `json:"findings,omitempty"`
+}
+
+// BIMIRecord represents a parsed BIMI assertion record.
+type BIMIRecord struct {
+	Raw              string   `json:"raw"`
+	LogoURL          string   `json:"logoUrl,omitempty"`          // l= tag
+	AuthorityURL     string   `json:"authorityUrl,omitempty"`     // a= tag, the VMC or CMC
+	AvatarPreference string   `json:"avatarPreference,omitempty"` // avp= tag: brand or personal
+	Declined         bool     `json:"declined"`                   // Empty l= and a=: the domain declines to show a logo
+	UnknownTags      []string `json:"unknownTags,omitempty"`
+}
+
+// BIMIDMARCCheck represents the DMARC prerequisite of BIMI: an enforced policy applied to all mail.
+type BIMIDMARCCheck struct {
+	PolicyDomain string `json:"policyDomain,omitempty"` // Domain whose record applies
+	Record       string `json:"record,omitempty"`
+	AppliedTag   string `json:"appliedTag,omitempty"` // p, sp or np
+	Policy       string `json:"policy,omitempty"`
+	Pct          int    `json:"pct"`
+	Enforced     bool   `json:"enforced"` // quarantine or reject at pct=100
+	Reason       string `json:"reason,omitempty"`
+}
+
+// BIMILogo represents the validation of a BIMI logo against the SVG Tiny Portable/Secure profile.
+type BIMILogo struct {
+	URL         string   `json:"url"`
+	ContentType string   `json:"contentType,omitempty"`
+	Size        int      `json:"size"`       // Bytes as served
+	Compressed  bool     `json:"compressed"` // Served gzip-compressed (svgz)
+	SHA256      string   `json:"sha256,omitempty"`
+	Title       string   `json:"title,omitempty"`
+	Version     string   `json:"version,omitempty"`
+	BaseProfile string   `json:"baseProfile,omitempty"`
+	ViewBox     string   `json:"viewBox,omitempty"`
+	Square      bool     `json:"square"`
+	Valid       bool     `json:"valid"`
+	Errors      []string `json:"errors,omitempty"`   // Violations of the profile
+	Warnings    []string `json:"warnings,omitempty"` // Recommendations not followed
+	Error       string   `json:"error,omitempty"`    // The logo could not be fetched
+}
+
+// BIMICertificate represents the validation of a Verified Mark Certificate or Common Mark Certificate.
+type BIMICertificate struct {
+	URL           string     `json:"url"`
+	Type          string     `json:"type,omitempty"`     // VMC or CMC
+	MarkType      string     `json:"markType,omitempty"` // Registered Mark, Government Mark, Prior Use Mark...
+	Subject       string     `json:"subject,omitempty"`
+	Organization  string     `json:"organization,omitempty"`
+	Issuer        string     `json:"issuer,omitempty"`
+	SerialNumber  string     `json:"serialNumber,omitempty"`
+	DNSNames      []string   `json:"dnsNames,omitempty"`
+	NotBefore     *time.Time `json:"notBefore,omitempty"`
+	NotAfter      *time.Time `json:"notAfter,omitempty"`
+	Expired       bool       `json:"expired"`
+	Chain         []string   `json:"chain,omitempty"` // Subjects from the leaf to the root
+	ChainValid    bool       `json:"chainValid"`
+	ChainError    string     `json:"chainError,omitempty"`
+	BIMIUsage     bool       `json:"bimiUsage"`     // Extended key usage id-kp-BrandIndicatorforMessageIdentification
+	DomainCovered bool       `json:"domainCovered"` // The domain is one of the subject alternative names
+	LogoEmbedded  bool       `json:"logoEmbedded"`  // The certificate carries a logotype (RFC 3709)
+	LogoHashValid bool       `json:"logoHashValid"` // The embedded logo matches the logotype hash
+	LogoMatches   bool       `json:"logoMatches"`   // The embedded logo is the logo of l=
+	Valid         bool       `json:"valid"`
+	Errors        []string   `json:"errors,omitempty"`
+	Error         string     `json:"error,omitempty"` // The certificate could not be fetched or parsed
+}
+
+// BIMIResult represents the BIMI check of a domain.
+type BIMIResult struct {
+	Domain      string           `json:"domain"`
+	Selector    string           `json:"selector"`
+	RecordName  string           `json:"recordName,omitempty"` // Name the record was found at, the domain's or its organizational domain's
+	Record      *BIMIRecord      `json:"record,omitempty"`
+	DMARC       *BIMIDMARCCheck  `json:"dmarc,omitempty"`
+	Logo        *BIMILogo        `json:"logo,omitempty"`
+	Certificate *BIMICertificate `json:"certificate,omitempty"`
+	Ready       bool             `json:"ready"` // Every check passed
+	Findings    []string         `json:"findings,omitempty"`
+	Error       string           `json:"error,omitempty"`
+}
+
+// IPOrigin describes who announces an IP address, from the Team Cymru IP to ASN service.
+type IPOrigin struct {
+	IP       string `json:"ip"`
+	PTR      string `json:"ptr,omitempty"`
+	ASN      int    `json:"asn,omitempty"`
+	ASName   string `json:"asName,omitempty"`
+	Prefix   string `json:"prefix,omitempty"`
+	Country  string `json:"country,omitempty"`
+	Registry string `json:"registry,omitempty"`
+}
+
+// TLSCertificateInfo describes the leaf certificate presented by a server.
+type TLSCertificateInfo struct {
+	Subject            string    `json:"subject"`
+	Issuer             string    `json:"issuer"`
+	DNSNames           []string  `json:"dnsNames,omitempty"`
+	SerialNumber       string    `json:"serialNumber"`
+	NotBefore          time.Time `json:"notBefore"`
+	NotAfter           time.Time `json:"notAfter"`
+	SignatureAlgorithm string    `json:"signatureAlgorithm"`
+	PublicKeyAlgorithm string    `json:"publicKeyAlgorithm"`
+	PublicKeyBits      int       `json:"publicKeyBits,omitempty"`
+	SelfSigned         bool      `json:"selfSigned"`
+	HostnameMatch      bool      `json:"hostnameMatch"`
+	Trusted            bool      `json:"trusted"`               // Chain verifies against the trusted roots
+	VerifyError        string    `json:"verifyError,omitempty"` // Why the chain or hostname did not verify
+	ChainLength        int       `json:"chainLength"`           // Certificates sent by the server
+}
+
+// MailAccessReport represents an IMAP or POP3 server check.
+type MailAccessReport struct {
+	Protocol        string              `json:"protocol"` // imap or pop3
+	Host            string              `json:"host"`
+	Port            int                 `json:"port"`
+	ImplicitTLS     bool                `json:"implicitTls"` // 993 and 995
+	Connected       bool                `json:"connected"`
+	Latency         time.Duration       `json:"latency"`
+	Banner          string              `json:"banner"`
+	Capabilities    []string            `json:"capabilities,omitempty"`    // Before STARTTLS/STLS, or on the implicit TLS session
+	TLSCapabilities []string            `json:"tlsCapabilities,omitempty"` // After STARTTLS/STLS
+	STARTTLS        bool                `json:"starttls"`                  // STARTTLS (IMAP) or STLS (POP3) advertised
+	STARTTLSError   string              `json:"starttlsError,omitempty"`
+	TLS             bool                `json:"tls"`
+	TLSVersion      string              `json:"tlsVersion,omitempty"`
+	TLSCipher       string              `json:"tlsCipher,omitempty"`
+	Certificate     *TLSCertificateInfo `json:"certificate,omitempty"`
+	PlaintextAuth   []string            `json:"plaintextAuth,omitempty"`  // Login methods offered before TLS
+	AuthMechanisms  []string            `json:"authMechanisms,omitempty"` // SASL mechanisms on the most secure session
+	LoginDisabled   bool                `json:"loginDisabled"`            // IMAP LOGINDISABLED before TLS
+	LoginTested     bool                `json:"loginTested"`
+	LoginSucceeded  bool                `json:"loginSucceeded"`
+	LoginMethod     string              `json:"loginMethod,omitempty"`
+	LoginResponse   string              `json:"loginResponse,omitempty"`
+	Transcript      []TranscriptEntry   `json:"transcript,omitempty"`
+	Error           string              `json:"error,omitempty"`
+}
+
+// MailServerSetting represents a server that mail clients are told to use for a domain.
+type MailServerSetting struct {
+	Source         string   `json:"source"`   // srv, autoconfig, autodiscover
+	Protocol       string   `json:"protocol"` // imap, pop3, smtp
+	Host           string   `json:"host"`
+	Port           int      `json:"port"`
+	Security       string   `json:"security"` // tls, starttls, none
+	Username       string   `json:"username,omitempty"`
+	Authentication []string `json:"authentication,omitempty"`
+	Priority       int      `json:"priority,omitempty"` // SRV priority and weight
+	Weight         int      `json:"weight,omitempty"`
+}
+
+// SRVServiceResult represents the lookup of one RFC 6186 / RFC 8314 SRV service.
+type SRVServiceResult struct {
+	Service     string              `json:"service"` // e.g. _imaps._tcp
+	Name        string              `json:"name"`
+	Found       bool                `json:"found"`
+	NotProvided bool                `json:"notProvided"` // Single record with target "."
+	Servers     []MailServerSetting `json:"servers,omitempty"`
+	Error       string              `json:"error,omitempty"`
+}
+
+// AutoconfigDocument represents one autoconfig or Autodiscover URL that was tried.
+type AutoconfigDocument struct {
+	Source       string              `json:"source"` // autoconfig, autodiscover
+	URL          string              `json:"url"`
+	Found        bool                `json:"found"`
+	StatusCode   int                 `json:"statusCode,omitempty"`
+	RequiresAuth bool                `json:"requiresAuth"`
+	DisplayName  string              `json:"displayName,omitempty"`
+	Redirect     string              `json:"redirect,omitempty"` // Autodiscover redirectAddr / redirectUrl
+	Servers      []MailServerSetting `json:"servers,omitempty"`
+	Error        string              `json:"error,omitempty"`
+}
+
+// AutoconfigDiscovery represents everything a mail client can discover about a domain.
+type AutoconfigDiscovery struct {
+	Domain       string               `json:"domain"`
+	Email        string               `json:"email"`
+	SRV          []SRVServiceResult   `json:"srv"`
+	Autoconfig   []AutoconfigDocument `json:"autoconfig"`
+	Autodiscover []AutoconfigDocument `json:"autodiscover"`
+}
+
+// MailServerProbe represents a connection test of an advertised mail server.
+type MailServerProbe struct {
+	Protocol    string              `json:"protocol"`
+	Host        string              `json:"host"`
+	Port        int                 `json:"port"`
+	Security    string              `json:"security"`
+	Connected   bool                `json:"connected"`
+	Banner      string              `json:"banner,omitempty"`
+	STARTTLS    bool                `json:"starttls"` // Advertised by the server
+	TLS         bool                `json:"tls"`
+	TLSVersion  string              `json:"tlsVersion,omitempty"`
+	Certificate *TLSCertificateInfo `json:"certificate,omitempty"`
+	Error       string              `json:"error,omitempty"`
+}
+
+// Finding severities
+const (
+	SeverityCritical = "critical"
+	SeverityWarning  = "warning"
+	SeverityInfo     = "info"
+)
+
+// Finding represents an observation of a check, graded by severity.
+type Finding struct {
+	Severity string `json:"severity"` // critical, warning, info
+	Code     string `json:"code"`     // Stable identifier, e.g. mx-cname
+	Host     string `json:"host,omitempty"`
+	Message  string `json:"message"`
+}
+
+// MXHostAnalysis represents the checks of one MX record.
+type MXHostAnalysis struct {
+	Host         string   `json:"host"`
+	Preference   uint16   `json:"preference"`
+	IPLiteral    bool     `json:"ipLiteral"`
+	CNAME        string   `json:"cname,omitempty"` // Canonical name when the MX target is an alias
+	Addresses    []string `json:"addresses,omitempty"`
+	NonPublic    []string `json:"nonPublic,omitempty"` // Private, loopback, link-local or unspecified addresses
+	Resolves     bool     `json:"resolves"`
+	ResolveError string   `json:"resolveError,omitempty"`
+	Reachable    *bool    `json:"reachable,omitempty"` // Nil when reachability was not tested
+	ReachError   string   `json:"reachError,omitempty"`
+}
+
+// MXAnalysis represents the MX configuration of a domain.
+type MXAnalysis struct {
+	Domain      string           `json:"domain"`
+	NullMX      bool             `json:"nullMx"`      // RFC 7505 "0 ." record
+	AcceptsMail bool             `json:"acceptsMail"` // At least one usable mail exchanger
+	ImplicitMX  bool             `json:"implicitMx"`  // No MX records, mail goes to the domain's A/AAAA
+	Hosts       []MXHostAnalysis `json:"hosts,omitempty"`
+	Findings    []Finding        `json:"findings,omitempty"`
+	Error       string           `json:"error,omitempty"`
 }
 
 // AuthResult represents the result of an email authentication check.
 type AuthResult struct {
 	SPFRecord   string `json:"spfRecord,omitempty"`
-	SPFResult   string `json:"spfResult,omitempty"` // Pass, Fail, SoftFail, Neutral, etc.
+	SPFResult   string `json:"spfResult,omitempty"` // valid or invalid: the syntax of the record
 	SPFError    string `json:"spfError,omitempty"`
 	DMARCRecord string `json:"dmarcRecord,omitempty"`
 	DMARCPolicy string `json:"dmarcPolicy,omitempty"` // p= tag
@@ -56,6 +878,7 @@ type DomainHealthReport struct {
 	Domain        string           `json:"domain"`
 	Timestamp     time.Time        `json:"timestamp"`
 	DNS           *DNSResult       `json:"dns,omitempty"`
+	MX            *MXAnalysis      `json:"mx,omitempty"`
 	Blacklist     *BlacklistResult `json:"blacklist,omitempty"` // Could be multiple for different IPs
 	SMTP          *SMTPResult      `json:"smtp,omitempty"`      // Check each MX server
 	Auth          *AuthResult      `json:"auth,omitempty"`
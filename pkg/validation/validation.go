@@ -10,6 +10,8 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+
+	"golang.org/x/net/idna"
 )
 
 // Common validation errors
@@ -214,6 +216,46 @@ func ValidateEmail(email string) error {
 	return nil
 }
 
+// NormalizeEmail validates an RFC 5322 addr-spec and returns it with the domain converted
+// to lowercase ASCII (internationalized domain names are converted to their A-label form).
+// Display names and angle brackets are not accepted; the local part is left unchanged.
+func NormalizeEmail(email string) (string, error) {
+	email = strings.TrimSpace(email)
+	if email == "" {
+		return "", ErrEmptyInput
+	}
+
+	parsed, err := mail.ParseAddress(email)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidEmail, err)
+	}
+	if parsed.Name != "" || parsed.Address != email {
+		return "", fmt.Errorf("%w: expected a bare address without display name", ErrInvalidEmail)
+	}
+
+	at := strings.LastIndex(parsed.Address, "@")
+	local, domain := parsed.Address[:at], parsed.Address[at+1:]
+	if len(local) > 64 {
+		return "", fmt.Errorf("%w: local part exceeds 64 octets", ErrInvalidEmail)
+	}
+
+	asciiDomain, err := idna.Lookup.ToASCII(strings.TrimSuffix(domain, "."))
+	if err != nil {
+		return "", fmt.Errorf("%w: invalid domain %q: %v", ErrInvalidEmail, domain, err)
+	}
+	asciiDomain = strings.ToLower(asciiDomain)
+	if !strings.Contains(asciiDomain, ".") {
+		return "", fmt.Errorf("%w: domain %q is not fully qualified", ErrInvalidEmail, domain)
+	}
+
+	normalized := local + "@" + asciiDomain
+	if len(normalized) > 254 {
+		return "", fmt.Errorf("%w: address exceeds 254 octets", ErrInvalidEmail)
+	}
+
+	return normalized, nil
+}
+
 // SanitizeDomain sanitizes a domain name.
 func SanitizeDomain(domain string) string {
 	// Remove any whitespace
@@ -0,0 +1,17 @@
+// Package input contains the input ports (interfaces) for the application
+package input
+
+import (
+	"context"
+	"mxclone/domain/autoconfig"
+)
+
+// AutoconfigPort defines the input interface for mail client autoconfiguration checks
+type AutoconfigPort interface {
+	// CheckDomain discovers the SRV records, autoconfig and Autodiscover settings of a domain and,
+	// when requested, connects to every advertised server to verify that it answers with valid TLS
+	CheckDomain(ctx context.Context, req *autoconfig.CheckRequest) (*autoconfig.CheckResult, error)
+
+	// GetCheckSummary returns a human-readable summary of an autoconfiguration check
+	GetCheckSummary(result *autoconfig.CheckResult) string
+}
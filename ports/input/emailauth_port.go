@@ -12,15 +12,84 @@ type EmailAuthPort interface {
 	// CheckSPF checks SPF (Sender Policy Framework) records for a domain
 	CheckSPF(ctx context.Context, domain string, timeout time.Duration) (*emailauth.SPFResult, error)
 
+	// EvaluateSPF evaluates the SPF policy for a message from ip, returning the result and the evaluation trace;
+	// the domain defaults to the domain of sender
+	EvaluateSPF(ctx context.Context, ip, domain, sender, helo string, timeout time.Duration) (*emailauth.SPFEvaluation, error)
+
+	// AnalyzeSPFLookups expands the include/redirect tree of a domain's SPF record, counts its DNS and void
+	// lookups against the limits and generates a flattened candidate record
+	AnalyzeSPFLookups(ctx context.Context, domain string, timeout time.Duration) (*emailauth.SPFLookupAnalysis, error)
+
 	// CheckDKIM checks DKIM (DomainKeys Identified Mail) records for a domain
 	CheckDKIM(ctx context.Context, domain string, selectors []string, timeout time.Duration) (*emailauth.DKIMResult, error)
 
+	// DiscoverDKIMSelectors probes a domain for DKIM selectors: the given ones, those of the sending services
+	// detected from its MX and SPF records, a bundled dictionary and dated selectors
+	DiscoverDKIMSelectors(ctx context.Context, domain string, selectors []string, timeout time.Duration) (*emailauth.DKIMDiscovery, error)
+
+	// VerifyDKIM verifies every DKIM-Signature header of a raw RFC 5322 message against the published keys
+	VerifyDKIM(ctx context.Context, raw []byte, timeout time.Duration) (*emailauth.DKIMVerification, error)
+
+	// VerifyARC validates the Authenticated Received Chain (RFC 8617) of a raw message: every ARC-Seal,
+	// the ARC-Message-Signatures and the chain validation status, with the results of each intermediary
+	VerifyARC(ctx context.Context, raw []byte, timeout time.Duration) (*emailauth.ARCValidation, error)
+
+	// AnalyzeHeaders analyzes the header section of a message: Received hops with delays and TLS,
+	// Authentication-Results, ARC, Return-Path and List-* headers
+	AnalyzeHeaders(ctx context.Context, raw []byte) (*emailauth.HeaderAnalysis, error)
+
 	// CheckDMARC checks DMARC (Domain-based Message Authentication) records for a domain
 	CheckDMARC(ctx context.Context, domain string, timeout time.Duration) (*emailauth.DMARCResult, error)
 
+	// IngestDMARCReports parses DMARC aggregate report files (XML, gzip or zip) and stores the
+	// reports not stored yet in the local report database
+	IngestDMARCReports(ctx context.Context, files []emailauth.DMARCReportUpload) (*emailauth.DMARCIngestResult, error)
+
+	// SummarizeDMARCReports summarizes the stored aggregate reports per source IP, ASN and reverse DNS
+	// domain, and lists the unknown senders failing alignment
+	SummarizeDMARCReports(ctx context.Context, query emailauth.DMARCReportQuery, timeout time.Duration) (*emailauth.DMARCReportSummary, error)
+
+	// AnalyzeForensicReports parses ARF failure report files (RFC 5965 and RFC 6591) and groups
+	// them by campaign
+	AnalyzeForensicReports(ctx context.Context, files []emailauth.DMARCReportUpload) (*emailauth.ForensicReportAnalysis, error)
+
+	// CheckBIMI checks the BIMI setup of a domain for a selector (default "default"): the assertion record,
+	// the DMARC prerequisite, the SVG Tiny PS logo and the VMC or CMC with its embedded logo
+	CheckBIMI(ctx context.Context, domain, selector string, timeout time.Duration) (*emailauth.BIMIResult, error)
+
 	// CheckAll performs SPF, DKIM, and DMARC checks for a domain
 	CheckAll(ctx context.Context, domain string, dkimSelectors []string, timeout time.Duration) (*emailauth.AuthResult, error)
 
 	// GetAuthSummary returns a human-readable summary of email authentication checks
 	GetAuthSummary(result *emailauth.AuthResult) string
+
+	// GetSPFEvaluationSummary returns a human-readable summary of an SPF evaluation and its trace
+	GetSPFEvaluationSummary(result *emailauth.SPFEvaluation) string
+
+	// GetSPFLookupSummary returns the SPF lookup tree as an indented text view with findings and the flattened record
+	GetSPFLookupSummary(result *emailauth.SPFLookupAnalysis) string
+
+	// GetDKIMVerificationSummary returns a human-readable summary of the DKIM signatures of a message
+	GetDKIMVerificationSummary(result *emailauth.DKIMVerification) string
+
+	// GetDKIMDiscoverySummary returns a human-readable summary of the DKIM selectors found for a domain
+	GetDKIMDiscoverySummary(result *emailauth.DKIMDiscovery) string
+
+	// GetARCValidationSummary returns a human-readable summary of the ARC chain of a message
+	GetARCValidationSummary(result *emailauth.ARCValidation) string
+
+	// GetHeaderAnalysisSummary returns a human-readable report of a message header analysis
+	GetHeaderAnalysisSummary(result *emailauth.HeaderAnalysis) string
+
+	// GetDMARCIngestSummary returns a human-readable summary of a report ingestion
+	GetDMARCIngestSummary(result *emailauth.DMARCIngestResult) string
+
+	// GetDMARCReportSummary returns a human-readable summary of aggregate report analytics
+	GetDMARCReportSummary(result *emailauth.DMARCReportSummary) string
+
+	// GetBIMISummary returns a human-readable summary of the BIMI check of a domain
+	GetBIMISummary(result *emailauth.BIMIResult) string
+
+	// GetForensicReportSummary returns a human-readable summary of failure reports by campaign
+	GetForensicReportSummary(result *emailauth.ForensicReportAnalysis) string
 }
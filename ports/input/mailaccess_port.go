@@ -0,0 +1,24 @@
+// Package input contains the input ports (interfaces) for the application
+package input
+
+import (
+	"context"
+	"mxclone/domain/mailaccess"
+	"time"
+)
+
+// MailAccessPort defines the input interface for IMAP and POP3 operations
+type MailAccessPort interface {
+	// CheckServer checks one IMAP or POP3 port: banner, capabilities, STARTTLS/STLS, certificate,
+	// login methods and, when credentials are given, a login test
+	CheckServer(ctx context.Context, req *mailaccess.CheckRequest) (*mailaccess.CheckResult, error)
+
+	// CheckHost checks the STARTTLS and implicit TLS ports of a protocol on a host concurrently
+	CheckHost(ctx context.Context, protocol, host string, timeout time.Duration) (*mailaccess.HostCheckResult, error)
+
+	// GetCheckSummary returns a human-readable summary of a server check
+	GetCheckSummary(result *mailaccess.CheckResult) string
+
+	// GetHostCheckSummary returns a human-readable summary of the checks of a host
+	GetHostCheckSummary(result *mailaccess.HostCheckResult) string
+}
@@ -15,6 +15,71 @@ type SMTPPort interface {
 	// TestSMTPConnection tests connection to a specific SMTP server
 	TestSMTPConnection(ctx context.Context, server string, port int, timeout time.Duration) (*smtp.ConnectionResult, error)
 
+	// CheckSMTPMatrix tests every address of every MX host of a domain on each port
+	// When ports is empty the configured SMTP ports are used
+	CheckSMTPMatrix(ctx context.Context, domain string, ports []int, timeout time.Duration) (*smtp.MatrixResult, error)
+
+	// GetSMTPMatrixSummary returns the MX matrix as a human-readable grid
+	GetSMTPMatrixSummary(result *smtp.MatrixResult) string
+
+	// TestOpenRelay runs the open relay test battery against an SMTP server
+	TestOpenRelay(ctx context.Context, req *smtp.RelayTestRequest) (*smtp.RelayTestResult, error)
+
+	// GetRelayTestSummary returns a human-readable summary of an open relay test
+	GetRelayTestSummary(result *smtp.RelayTestResult) string
+
+	// TestSubmission runs an authenticated submission test (TLS, AUTH and optional test message)
+	TestSubmission(ctx context.Context, req *smtp.SubmissionTestRequest) (*smtp.SubmissionTestResult, error)
+
+	// GetSubmissionTestSummary returns a human-readable summary of a submission test
+	GetSubmissionTestSummary(result *smtp.SubmissionTestResult) string
+
+	// TestSmuggling probes a mail exchanger for SMTP smuggling (non-standard end-of-data sequences)
+	TestSmuggling(ctx context.Context, req *smtp.SmugglingTestRequest) (*smtp.SmugglingTestResult, error)
+
+	// GetSmugglingTestSummary returns a human-readable summary of an SMTP smuggling probe
+	GetSmugglingTestSummary(result *smtp.SmugglingTestResult) string
+
+	// VerifyEmail checks the syntax, domain and mailbox of an email address without sending mail
+	VerifyEmail(ctx context.Context, address string, timeout time.Duration) (*smtp.EmailVerificationResult, error)
+
+	// VerifyEmails verifies several email addresses concurrently
+	VerifyEmails(ctx context.Context, addresses []string, timeout time.Duration) (*smtp.BulkEmailVerificationResult, error)
+
+	// GetEmailVerificationSummary returns a human-readable summary of an email verification
+	GetEmailVerificationSummary(result *smtp.EmailVerificationResult) string
+
+	// GetBulkEmailVerificationSummary returns a human-readable summary of a bulk email verification
+	GetBulkEmailVerificationSummary(result *smtp.BulkEmailVerificationResult) string
+
+	// StartSink starts the receive-only SMTP sink that authenticates inbound test messages
+	StartSink(ctx context.Context, config *smtp.SinkConfig) (*smtp.SinkStatus, error)
+
+	// StopSink stops the SMTP sink
+	StopSink(ctx context.Context) error
+
+	// GetSinkStatus describes the SMTP sink
+	GetSinkStatus(ctx context.Context) *smtp.SinkStatus
+
+	// CreateSinkTest generates a unique test address on the running sink
+	CreateSinkTest(ctx context.Context) (*smtp.SinkTest, error)
+
+	// GetSinkTest returns the messages received for a sink test with their authentication verdicts
+	GetSinkTest(ctx context.Context, id string) (*smtp.SinkTest, error)
+
+	// GetSinkStatusSummary returns a human-readable description of the SMTP sink
+	GetSinkStatusSummary(status *smtp.SinkStatus) string
+
+	// GetSinkTestSummary returns a human-readable summary of a sink test
+	GetSinkTestSummary(test *smtp.SinkTest) string
+
+	// ExplainReply classifies an SMTP reply or bounce text (permanent/transient, policy/reputation/auth/mailbox)
+	// and suggests a remediation
+	ExplainReply(reply string) *smtp.ReplyExplanation
+
+	// GetReplyExplanationSummary returns a human-readable explanation of an SMTP reply
+	GetReplyExplanationSummary(explanation *smtp.ReplyExplanation) string
+
 	// GetSMTPSummary returns a human-readable summary of SMTP check results
 	GetSMTPSummary(result *smtp.SMTPResult) string
 }
@@ -0,0 +1,18 @@
+// Package output contains the output ports (interfaces) for the application
+package output
+
+import (
+	"context"
+	"mxclone/domain/autoconfig"
+	"time"
+)
+
+// AutoconfigRepository defines the output interface for mail client autoconfiguration operations
+type AutoconfigRepository interface {
+	// Discover looks up the SRV records and fetches the autoconfig and Autodiscover documents
+	// Unreachable sources are part of the result, not errors
+	Discover(ctx context.Context, req *autoconfig.CheckRequest) (*autoconfig.CheckResult, error)
+
+	// ProbeServer connects to an advertised server with the security it is advertised with
+	ProbeServer(ctx context.Context, server autoconfig.ServerSetting, timeout time.Duration) *autoconfig.ServerProbe
+}
@@ -4,6 +4,8 @@ package output
 import (
 	"context"
 	"time"
+
+	"mxclone/domain/emailauth"
 )
 
 // EmailAuthRepository defines the output interface for email authentication operations
@@ -14,15 +16,64 @@ type EmailAuthRepository interface {
 	// ValidateSPFRecord validates the SPF record and extracts mechanisms
 	ValidateSPFRecord(record string) (bool, []string, error)
 
+	// EvaluateSPF evaluates the SPF policy of domain for a message from ip (check_host, RFC 7208)
+	EvaluateSPF(ctx context.Context, ip, domain, sender, helo string, timeout time.Duration) (*emailauth.SPFEvaluation, error)
+
+	// AnalyzeSPFLookups expands the include/redirect tree of a domain's SPF record and counts its DNS lookups
+	AnalyzeSPFLookups(ctx context.Context, domain string, timeout time.Duration) (*emailauth.SPFLookupAnalysis, error)
+
 	// GetDKIMRecord retrieves the DKIM record for a domain and selector
 	GetDKIMRecord(ctx context.Context, domain string, selector string, timeout time.Duration) (string, bool, error)
 
 	// ValidateDKIMRecord validates a DKIM record
 	ValidateDKIMRecord(record string) (bool, error)
 
+	// AnalyzeDKIMKey decodes the public key of a DKIM record and reports its type, size, strength and problems
+	AnalyzeDKIMKey(record string) (*emailauth.DKIMKey, error)
+
+	// DiscoverDKIMSelectors probes a domain for DKIM selectors, with provider guesses and wildcard detection
+	DiscoverDKIMSelectors(ctx context.Context, domain string, selectors []string, timeout time.Duration) (*emailauth.DKIMDiscovery, error)
+
+	// VerifyDKIM verifies the DKIM signatures of a raw message, fetching keys with GetDKIMRecord
+	VerifyDKIM(ctx context.Context, raw []byte, timeout time.Duration) (*emailauth.DKIMVerification, error)
+
+	// VerifyARC validates the ARC chain of a raw message, fetching keys with GetDKIMRecord
+	VerifyARC(ctx context.Context, raw []byte, timeout time.Duration) (*emailauth.ARCValidation, error)
+
+	// CheckBIMI looks up the BIMI record of a domain and validates its DMARC policy, logo and mark certificate
+	CheckBIMI(ctx context.Context, domain, selector string, timeout time.Duration) (*emailauth.BIMIResult, error)
+
+	// AnalyzeHeaders parses the header section of a message
+	AnalyzeHeaders(raw []byte) *emailauth.HeaderAnalysis
+
+	// DiscoverDMARCPolicy finds the DMARC record that applies to a domain, walking up its parent domains
+	DiscoverDMARCPolicy(ctx context.Context, domain string, timeout time.Duration) (*emailauth.DMARCDiscovery, error)
+
 	// GetDMARCRecord retrieves the DMARC record for a domain
 	GetDMARCRecord(ctx context.Context, domain string, timeout time.Duration) (string, bool, error)
 
 	// ParseDMARCRecord parses a DMARC record to extract policy information
 	ParseDMARCRecord(record string) (bool, string, string, int, error)
+
+	// AnalyzeDMARCRecord parses every tag of a DMARC record and verifies that external report
+	// destinations authorize the reports of the domain
+	AnalyzeDMARCRecord(ctx context.Context, domain, record string, timeout time.Duration) (*emailauth.DMARCResult, error)
+
+	// ParseDMARCReports reads the aggregate reports of a file: plain XML, gzip or a zip archive
+	ParseDMARCReports(name string, data []byte) ([]*emailauth.DMARCAggregateReport, error)
+
+	// StoreDMARCReports adds reports to the local report database, skipping those already stored
+	StoreDMARCReports(ctx context.Context, reports []*emailauth.DMARCAggregateReport) (added, duplicates int, err error)
+
+	// LoadDMARCReports returns the stored reports of a domain that overlap a date range
+	LoadDMARCReports(ctx context.Context, domain string, since, until time.Time) ([]*emailauth.DMARCAggregateReport, error)
+
+	// DMARCReportDatabase returns where the reports are stored
+	DMARCReportDatabase() string
+
+	// ParseForensicReport reads an ARF feedback report, such as a DMARC failure report
+	ParseForensicReport(name string, data []byte) (*emailauth.ForensicReport, error)
+
+	// LookupSourceIP finds the reverse DNS name and the ASN of a report source
+	LookupSourceIP(ctx context.Context, ip string, timeout time.Duration) (*emailauth.SourceOrigin, error)
 }
@@ -0,0 +1,18 @@
+// Package output contains the output ports (interfaces) for the application
+package output
+
+import (
+	"context"
+	"mxclone/domain/mailaccess"
+)
+
+// MailAccessRepository defines the output interface for IMAP and POP3 operations
+type MailAccessRepository interface {
+	// CheckIMAP runs an IMAP session against a server
+	// The result is returned even on error, with what was learned before the failure
+	CheckIMAP(ctx context.Context, req *mailaccess.CheckRequest) (*mailaccess.CheckResult, error)
+
+	// CheckPOP3 runs a POP3 session against a server
+	// The result is returned even on error, with what was learned before the failure
+	CheckPOP3(ctx context.Context, req *mailaccess.CheckRequest) (*mailaccess.CheckResult, error)
+}
@@ -3,14 +3,66 @@ package output
 
 import (
 	"context"
+	"mxclone/domain/smtp"
 	"time"
 )
 
 // SMTPRepository defines the output interface for SMTP operations
 type SMTPRepository interface {
-	// GetMXRecords retrieves the MX records for a domain
+	// GetMXRecords retrieves the MX host names of a domain in preference order, without a null MX
 	GetMXRecords(ctx context.Context, domain string) ([]string, error)
 
+	// GetMXHosts retrieves the MX hosts of a domain with their preferences
+	GetMXHosts(ctx context.Context, domain string) ([]smtp.MXHost, error)
+
+	// ResolveHostAddresses resolves the IPv4 and IPv6 addresses of a host
+	ResolveHostAddresses(ctx context.Context, host string) ([]string, error)
+
 	// ConnectToSMTPServer connects to an SMTP server and tests its capabilities
-	ConnectToSMTPServer(ctx context.Context, server string, port int, timeout time.Duration) (bool, time.Duration, bool, []string, string, error)
+	// The session info is returned even on error, with whatever was learned before the failure
+	ConnectToSMTPServer(ctx context.Context, server string, port int, timeout time.Duration) (*smtp.SessionInfo, error)
+
+	// ConnectToSMTPAddress is like ConnectToSMTPServer but dials a specific address of the server
+	ConnectToSMTPAddress(ctx context.Context, server string, address string, port int, timeout time.Duration) (*smtp.SessionInfo, error)
+
+	// AnalyzeBanner analyzes the greeting and reply behavior of an SMTP server reached through an MX host
+	// The recipient domain is used to probe greylisting with its postmaster address
+	AnalyzeBanner(ctx context.Context, server string, port int, recipientDomain string, timeout time.Duration) (*smtp.BannerAnalysis, error)
+
+	// CheckSTARTTLSSecurity checks an SMTP server for STARTTLS command injection and an advertised STARTTLS that fails
+	CheckSTARTTLSSecurity(ctx context.Context, server string, port int, timeout time.Duration) (*smtp.STARTTLSSecurity, error)
+
+	// RunSmugglingTest probes how a mail exchanger handles non-standard end-of-data sequences
+	// The result is returned even on error, with the sequences that were probed
+	RunSmugglingTest(ctx context.Context, req *smtp.SmugglingTestRequest) (*smtp.SmugglingTestResult, error)
+
+	// NormalizeEmailAddress checks the syntax of an email address and returns it with an ASCII domain
+	NormalizeEmailAddress(address string) (string, error)
+
+	// ProbeRecipients offers each recipient to a mail exchanger in one RCPT TO transaction without sending mail
+	// The probe is returned even on error, with the replies received before the failure
+	ProbeRecipients(ctx context.Context, host string, recipients []string, timeout time.Duration) (*smtp.RecipientProbe, error)
+
+	// RunRelayTests runs the open relay test battery against a server
+	// Returns: banner, test results, authenticated, error
+	RunRelayTests(ctx context.Context, req *smtp.RelayTestRequest) (string, []*smtp.RelayTestCase, bool, error)
+
+	// RunSubmissionTest runs an authenticated submission test against a server
+	// The result is returned even on error, with the stages that ran
+	RunSubmissionTest(ctx context.Context, req *smtp.SubmissionTestRequest) (*smtp.SubmissionTestResult, error)
+
+	// StartSink starts the receive-only SMTP sink; received messages are authenticated as they arrive
+	StartSink(ctx context.Context, config *smtp.SinkConfig) (*smtp.SinkStatus, error)
+
+	// StopSink stops the SMTP sink and discards its tests
+	StopSink(ctx context.Context) error
+
+	// GetSinkStatus describes the SMTP sink
+	GetSinkStatus(ctx context.Context) *smtp.SinkStatus
+
+	// CreateSinkTest generates a test address on the running sink
+	CreateSinkTest(ctx context.Context) (*smtp.SinkTest, error)
+
+	// GetSinkTest returns a sink test with the reports of the messages it received
+	GetSinkTest(ctx context.Context, id string) (*smtp.SinkTest, error)
 }
@@ -38,6 +38,56 @@ export interface SMTPResponse {
   error?: string;
 }
 
+export interface SMTPTranscriptEntry {
+  timestamp: string;
+  elapsed: string;
+  direction: 'client' | 'server' | 'tls' | 'info';
+  line: string;
+  code?: number;
+  enhancedCode?: string;
+  duration?: string;
+}
+
+export interface SMTPBannerAnalysis {
+  banner?: string;
+  greetingCode?: number;
+  greetingDelay: string;
+  multiLineGreeting: boolean;
+  preGreetPause: boolean;
+  earlyTalkerTested: boolean;
+  earlyTalkerRejected: boolean;
+  averageResponseTime?: string;
+  tarpitting: boolean;
+  rcptCode?: number;
+  rcptResponse?: string;
+  greylistSuspected: boolean;
+  greylisting: boolean;
+  retryCode?: number;
+  bannerHostname?: string;
+  serverIP?: string;
+  ptrNames?: string[];
+  matchesPTR: boolean;
+  matchesMX: boolean;
+  software?: string;
+  softwareVersion?: string;
+  leaksVersion: boolean;
+  findings?: string[];
+  error?: string;
+}
+
+export interface SMTPSTARTTLSSecurity {
+  advertised: boolean;
+  handshakeSucceeded: boolean;
+  handshakeError?: string;
+  tlsVersion?: string;
+  injectionTested: boolean;
+  injectionVulnerable: boolean;
+  injectionResponse?: string;
+  findings?: string[];
+  transcript?: SMTPTranscriptEntry[];
+  error?: string;
+}
+
 export interface SMTPConnectionResponse {
   host: string;
   port: number;
@@ -46,6 +96,33 @@ export interface SMTPConnectionResponse {
   supportsStartTLS?: boolean;
   authMethods?: string[];
   banner?: string;
+  tlsVersion?: string;
+  tlsCipher?: string;
+  transcript?: SMTPTranscriptEntry[];
+  bannerAnalysis?: SMTPBannerAnalysis;
+  starttlsSecurity?: SMTPSTARTTLSSecurity;
+  error?: string;
+}
+
+export interface EmailVerifyResponse {
+  address: string;
+  normalizedAddress?: string;
+  verdict: 'deliverable' | 'undeliverable' | 'risky' | 'unknown';
+  reasons?: string[];
+  validSyntax: boolean;
+  syntaxError?: string;
+  roleAccount: boolean;
+  disposable: boolean;
+  mxHosts?: { host: string; preference: number }[];
+  implicitMX: boolean;
+  mailServer?: string;
+  rcptCode?: number;
+  enhancedCode?: string;
+  rcptResponse?: string;
+  accepted: boolean;
+  catchAllTested: boolean;
+  catchAll: boolean;
+  transcript?: SMTPTranscriptEntry[];
   error?: string;
 }
 
@@ -54,19 +131,37 @@ export interface SMTPRelayTestRequest {
   port?: number;
   fromAddress: string;
   toAddress: string;
+  targetDomain?: string;
   timeout?: number;
   authentication?: boolean;
   username?: string;
   password?: string;
+  transcript?: boolean;
+}
+
+export interface SMTPRelayTestCase {
+  name: string;
+  description?: string;
+  mailFrom: string;
+  rcptTo: string;
+  accepted: boolean;
+  verdict: string;
+  responseCode?: number;
+  responseText?: string;
+  transcript?: SMTPTranscriptEntry[];
+  error?: string;
 }
 
 export interface SMTPRelayTestResponse {
   host: string;
   port: number;
+  banner?: string;
   isOpenRelay: boolean;
   authRequired?: boolean;
+  authenticated?: boolean;
   responseCode?: number;
   responseText?: string;
+  tests?: SMTPRelayTestCase[];
   testDetails?: string;
   error?: string;
 }
@@ -81,12 +176,454 @@ export interface SPFResponse {
   error?: string;
 }
 
+export interface SPFEvaluateRequest {
+  ip: string;
+  domain?: string;
+  sender?: string;
+  helo?: string;
+  timeout?: number;
+}
+
+export interface SPFTraceStep {
+  depth: number;
+  domain: string;
+  term?: string;
+  target?: string;
+  result: string;
+  detail?: string;
+}
+
+export interface SPFEvaluationResponse {
+  domain: string;
+  ip: string;
+  sender?: string;
+  helo?: string;
+  result: string;
+  record?: string;
+  mechanism?: string;
+  lookups: number;
+  voidLookups: number;
+  explanation?: string;
+  trace: SPFTraceStep[];
+  error?: string;
+}
+
+export interface SPFTermAnalysis {
+  term: string;
+  lookups: number;
+  voidLookups?: number;
+  mxHosts?: number;
+  networks?: string[];
+  target?: SPFTreeNode;
+  note?: string;
+}
+
+export interface SPFTreeNode {
+  domain: string;
+  record?: string;
+  length: number;
+  stringLengths?: number[];
+  lookups: number;
+  voidLookups: number;
+  terms?: SPFTermAnalysis[];
+  error?: string;
+}
+
+export interface SPFLookupAnalysisResponse {
+  domain: string;
+  tree?: SPFTreeNode;
+  treeText?: string;
+  lookups: number;
+  lookupLimit: number;
+  voidLookups: number;
+  voidLookupLimit: number;
+  recordLength: number;
+  flattened?: {
+    records: { name: string; record: string; length: number }[];
+    networks: number;
+    lookups: number;
+    warnings?: string[];
+  };
+  findings: { severity: string; code: string; domain?: string; message: string }[];
+  error?: string;
+}
+
+export interface DKIMSignatureVerification {
+  domain: string;
+  selector: string;
+  algorithm?: string;
+  canonicalization?: string;
+  signedHeaders?: string[];
+  identity?: string;
+  timestamp?: string;
+  expiration?: string;
+  bodyLength?: number;
+  unsignedBodyBytes?: number;
+  unsignedHeaders?: string[];
+  warnings?: string[];
+  result: string;
+  reason?: string;
+}
+
+export interface DKIMVerificationResponse {
+  signatures: DKIMSignatureVerification[];
+  passed: number;
+  summary?: string;
+  error?: string;
+}
+
+export interface ARCSetVerification {
+  instance: number;
+  sealDomain?: string;
+  sealSelector?: string;
+  sealAlgorithm?: string;
+  sealTimestamp?: string;
+  chainValidation?: string; // cv= recorded by the sealer
+  sealResult: string;
+  sealReason?: string;
+  messageSignature?: DKIMSignatureVerification;
+  authenticationResults?: AuthResults; // ARC-Authentication-Results
+}
+
+export interface ARCValidationResponse {
+  result: 'none' | 'pass' | 'fail';
+  reason?: string;
+  instances: number;
+  oldestPass: number;
+  sets: ARCSetVerification[];
+  findings?: string[];
+  summary?: string;
+}
+
+export interface DKIMSelectorHit {
+  selector: string;
+  record: string;
+  cname?: string;
+  source: 'custom' | 'provider' | 'dictionary' | 'dated';
+  provider?: string;
+  attribution?: 'cname' | 'mx/spf' | 'selector';
+  keyType?: string;
+  keyBits?: number;
+  keyStrength?: string;
+}
+
+export interface DKIMDiscoveryResponse {
+  domain: string;
+  providers?: string[];
+  tried: number;
+  wildcard: boolean;
+  wildcardRecord?: string;
+  selectors: DKIMSelectorHit[];
+  summary?: string;
+  error?: string;
+}
+
+export interface BIMIRecord {
+  raw: string;
+  logoUrl?: string; // l= tag
+  authorityUrl?: string; // a= tag
+  avatarPreference?: string;
+  declined: boolean;
+  unknownTags?: string[];
+}
+
+export interface BIMIDMARCCheck {
+  policyDomain?: string;
+  record?: string;
+  appliedTag?: string; // p, sp or np
+  policy?: string;
+  pct: number;
+  enforced: boolean;
+  reason?: string;
+}
+
+export interface BIMILogo {
+  url: string;
+  contentType?: string;
+  size: number;
+  compressed: boolean;
+  sha256?: string;
+  title?: string;
+  version?: string;
+  baseProfile?: string;
+  viewBox?: string;
+  square: boolean;
+  valid: boolean;
+  errors?: string[];
+  warnings?: string[];
+  error?: string;
+}
+
+export interface BIMICertificate {
+  url: string;
+  type?: string; // VMC or CMC
+  markType?: string;
+  subject?: string;
+  organization?: string;
+  issuer?: string;
+  serialNumber?: string;
+  dnsNames?: string[];
+  notBefore?: string;
+  notAfter?: string;
+  expired: boolean;
+  chain?: string[];
+  chainValid: boolean;
+  chainError?: string;
+  bimiUsage: boolean;
+  domainCovered: boolean;
+  logoEmbedded: boolean;
+  logoHashValid: boolean;
+  logoMatches: boolean;
+  valid: boolean;
+  errors?: string[];
+  error?: string;
+}
+
+export interface BIMIResponse {
+  domain: string;
+  selector: string;
+  recordName?: string;
+  record?: BIMIRecord;
+  dmarc?: BIMIDMARCCheck;
+  logo?: BIMILogo;
+  certificate?: BIMICertificate;
+  ready: boolean;
+  findings?: string[];
+  summary?: string;
+  error?: string;
+}
+
+export interface DMARCReportUploadFile {
+  name: string;
+  content: string; // Base64: plain XML, gzip or zip aggregate reports, or an ARF message
+}
+
+export interface DMARCReportFileResult {
+  name: string;
+  reports: number;
+  added: number;
+  duplicates: number;
+  error?: string;
+}
+
+export interface DMARCReportIngestResponse {
+  files: DMARCReportFileResult[];
+  reports: number;
+  added: number;
+  duplicates: number;
+  failed: number;
+  summary?: string;
+}
+
+export interface DMARCReportSummaryRequest {
+  domain?: string;
+  since?: string; // YYYY-MM-DD
+  until?: string;
+  days?: number;
+  top?: number;
+  noLookup?: boolean;
+  knownSenders?: string[]; // IPs, CIDR ranges, ASNs (AS64500) or reverse DNS domains
+  timeout?: number;
+}
+
+export interface DMARCTally {
+  messages: number;
+  spfPass: number;
+  spfPassRate: number;
+  spfAligned: number;
+  spfAlignedRate: number;
+  dkimPass: number;
+  dkimPassRate: number;
+  dkimAligned: number;
+  dkimAlignedRate: number;
+  dmarcPass: number;
+  dmarcPassRate: number;
+  dispositions: Record<string, number>;
+}
+
+export interface DMARCReportSource extends DMARCTally {
+  sourceIp: string;
+  ptr?: string;
+  ptrDomain?: string;
+  asn?: number;
+  asName?: string;
+  country?: string;
+  headerFromDomains?: string[];
+  reporters?: string[];
+  known: boolean;
+  knownBy?: string;
+}
+
+export interface DMARCReportSourceGroup extends DMARCTally {
+  name: string;
+  sources: number;
+}
+
+export interface DMARCReportSummaryResponse extends DMARCTally {
+  reports: number;
+  reporters: string[];
+  domains: string[];
+  begin: string;
+  end: string;
+  totalSources: number;
+  sources: DMARCReportSource[];
+  byAsn?: DMARCReportSourceGroup[];
+  byReverseDns?: DMARCReportSourceGroup[];
+  unknownFailing: DMARCReportSource[]; // Unknown senders failing alignment
+  findings?: string[];
+  summary?: string;
+}
+
+export interface AuthResults {
+  authServId: string;
+  results: { method: string; result: string; reason?: string; properties?: Record<string, string> }[];
+}
+
+export interface ReceivedHop {
+  hop: number; // 1 for the first relay after the sender
+  from?: string;
+  fromHost?: string;
+  fromIp?: string;
+  by?: string;
+  with?: string;
+  id?: string;
+  for?: string;
+  timestamp?: string;
+  delaySeconds?: number; // Since the previous hop, or the Date header for the first
+  clockSkew: boolean;
+  tls: boolean;
+  tlsDetails?: string;
+  raw: string;
+}
+
+export interface ARCSetHeaders {
+  instance: number;
+  sealDomain?: string;
+  sealSelector?: string;
+  chainValidation?: string;
+  signatureDomain?: string;
+  signatureSelector?: string;
+  authenticationResults?: AuthResults;
+}
+
+export interface ListHeaders {
+  id?: string;
+  unsubscribe?: string[];
+  unsubscribePost?: string;
+  subscribe?: string[];
+  post?: string[];
+  help?: string[];
+  owner?: string[];
+  archive?: string[];
+}
+
+export interface HeaderAnalysisResponse {
+  from?: string;
+  fromDomain?: string;
+  to?: string;
+  subject?: string;
+  date?: string;
+  messageId?: string;
+  returnPath?: string;
+  returnPathDomain?: string;
+  replyTo?: string;
+  hops: ReceivedHop[];
+  totalDelaySeconds?: number;
+  authenticationResults?: AuthResults[];
+  arc?: ARCSetHeaders[];
+  dkimDomains?: string[];
+  list?: ListHeaders;
+  headers: { name: string; value: string }[];
+  findings?: string[];
+  summary?: string;
+}
+
+export interface DMARCForensicReport {
+  reportFrom?: string;
+  reportSubject?: string;
+  reportDate?: string;
+  feedbackType: string;
+  userAgent?: string;
+  version?: string;
+  authFailure?: string; // dmarc, dkim, spf, ...
+  identityAlignment?: string;
+  sourceIp?: string;
+  reportingMta?: string;
+  arrivalDate?: string;
+  originalMailFrom?: string;
+  originalRcptTo?: string[];
+  reportedDomain?: string[];
+  deliveryResult?: string;
+  incidents: number;
+  dkimDomain?: string;
+  dkimSelector?: string;
+  dkimIdentity?: string;
+  spfDns?: string;
+  authenticationResults?: AuthResults[];
+  fields?: Record<string, string[]>;
+  original?: {
+    headersOnly: boolean;
+    from?: string;
+    fromDomain?: string;
+    returnPath?: string;
+    to?: string;
+    subject?: string;
+    date?: string;
+    messageId?: string;
+    dkimDomains?: string[];
+    authenticationResults?: AuthResults[];
+    receivedHops: number;
+    headerFindings?: string[];
+  };
+  warnings?: string[];
+}
+
+export interface DMARCForensicCampaign {
+  fromDomain: string;
+  subjectPattern: string; // Lowercase, numbers masked as #
+  subject?: string;
+  reports: number;
+  incidents: number;
+  sourceIps: string[];
+  reporters: string[];
+  returnPathDomains?: string[];
+  dkimDomains?: string[];
+  authFailures?: Record<string, number>;
+  deliveryResults?: Record<string, number>;
+  firstSeen?: string;
+  lastSeen?: string;
+  assessment: string;
+}
+
+export interface DMARCForensicResponse {
+  files: { name: string; report?: DMARCForensicReport; error?: string }[];
+  reports: number;
+  incidents: number;
+  failed: number;
+  sourceIps: number;
+  campaigns: DMARCForensicCampaign[];
+  findings?: string[];
+  summary?: string;
+}
+
+export interface DKIMKey {
+  keyType: string;
+  keyBits?: number;
+  strength: 'strong' | 'weak' | 'failing';
+  hashAlgorithms?: string[];
+  services?: string[];
+  revoked: boolean;
+  testing: boolean;
+  findings: { severity: string; code: string; message: string }[];
+}
+
 export interface DKIMResponse {
   domain: string;
   selector: string;
   hasRecords: boolean;
   records?: Record<string, string>;
   isValid: boolean;
+  keys?: Record<string, DKIMKey>;
   error?: string;
   // For combined response
   results?: Array<{
@@ -95,6 +632,7 @@ export interface DKIMResponse {
     hasRecords: boolean;
     records?: Record<string, string>;
     isValid: boolean;
+    keys?: Record<string, DKIMKey>;
     error?: string;
   }>;
   selectors?: string[];
@@ -108,9 +646,50 @@ export interface DMARCResponse {
   policy?: string;
   subdomainPolicy?: string;
   percentage?: number;
+  nonexistentPolicy?: string;
+  psd?: 'y' | 'n' | 'u';
+  alignmentDkim?: 'r' | 's';
+  alignmentSpf?: 'r' | 's';
+  failureOptions?: string[];
+  reportFormats?: string[];
+  reportInterval?: number;
+  aggregateReports?: DMARCReportURI[];
+  failureReports?: DMARCReportURI[];
+  unknownTags?: string[];
+  tagErrors?: DMARCTagError[];
+  organizationalDomain?: string;
+  policyDomain?: string;
+  inherited: boolean;
+  appliedPolicy?: string;
+  appliedTag?: 'p' | 'sp' | 'np';
+  policyReason?: string;
+  lookups?: DMARCLookup[];
   error?: string;
 }
 
+export interface DMARCLookup {
+  name: string;
+  result: 'record' | 'none' | 'multiple' | 'error';
+  record?: string;
+}
+
+export interface DMARCReportURI {
+  uri: string;
+  address?: string;
+  domain?: string;
+  maxSize?: number; // bytes
+  external: boolean;
+  authorized: boolean;
+  authorizationRecord?: string;
+  error?: string;
+}
+
+export interface DMARCTagError {
+  tag: string;
+  value?: string;
+  message: string;
+}
+
 // Network Tools Types
 export interface PingResponse {
   target: string;
@@ -239,12 +818,13 @@ export async function smtpCheck(target: string): Promise<SMTPResponse> {
   }
 }
 
-export async function smtpConnect(host: string, port?: number, timeout?: string): Promise<SMTPConnectionResponse> {
+export async function smtpConnect(host: string, port?: number, timeout?: string, transcript?: boolean): Promise<SMTPConnectionResponse> {
   try {
     // Build the query parameters
     const params = new URLSearchParams();
     if (port !== undefined) params.append('port', port.toString());
     if (timeout !== undefined) params.append('timeout', timeout);
+    if (transcript) params.append('transcript', 'true');
     
     const url = `${API_BASE}/smtp/connect/${encodeURIComponent(host)}`;
     const fullUrl = params.toString() ? `${url}?${params.toString()}` : url;
@@ -256,11 +836,12 @@ export async function smtpConnect(host: string, port?: number, timeout?: string)
   }
 }
 
-export async function smtpStartTLS(host: string, timeout?: string): Promise<Record<string, any>> {
+export async function smtpStartTLS(host: string, timeout?: string, transcript?: boolean): Promise<Record<string, any>> {
   try {
     // Build the query parameters
     const params = new URLSearchParams();
     if (timeout !== undefined) params.append('timeout', timeout);
+    if (transcript) params.append('transcript', 'true');
     
     const url = `${API_BASE}/smtp/starttls/${encodeURIComponent(host)}`;
     const fullUrl = params.toString() ? `${url}?${params.toString()}` : url;
@@ -291,6 +872,98 @@ export async function spfCheck(domain: string): Promise<SPFResponse> {
   }
 }
 
+export async function spfEvaluate(request: SPFEvaluateRequest): Promise<SPFEvaluationResponse> {
+  try {
+    const response = await axios.post(`${API_BASE}/auth/spf/evaluate`, request);
+    return response.data;
+  } catch (error: any) {
+    return handleAxiosError(error);
+  }
+}
+
+export async function spfLookupAnalysis(domain: string, timeout?: number): Promise<SPFLookupAnalysisResponse> {
+  try {
+    const response = await axios.post(`${API_BASE}/auth/spf/lookups`, { domain, timeout });
+    return response.data;
+  } catch (error: any) {
+    return handleAxiosError(error);
+  }
+}
+
+// message is the raw RFC 5322 message, e.g. the text of an uploaded .eml file
+export async function dkimVerify(message: string, timeout?: number): Promise<DKIMVerificationResponse> {
+  try {
+    const response = await axios.post(`${API_BASE}/auth/dkim/verify`, { message, timeout });
+    return response.data;
+  } catch (error: any) {
+    return handleAxiosError(error);
+  }
+}
+
+export async function arcVerify(message: string, timeout?: number): Promise<ARCValidationResponse> {
+  try {
+    const response = await axios.post(`${API_BASE}/auth/arc/verify`, { message, timeout });
+    return response.data;
+  } catch (error: any) {
+    return handleAxiosError(error);
+  }
+}
+
+// selectors are tried before the guessed ones
+export async function dkimDiscover(domain: string, selectors?: string[], timeout?: number): Promise<DKIMDiscoveryResponse> {
+  try {
+    const response = await axios.post(`${API_BASE}/auth/dkim/discover`, { domain, selectors, timeout });
+    return response.data;
+  } catch (error: any) {
+    return handleAxiosError(error);
+  }
+}
+
+export async function bimiCheck(domain: string, selector?: string, timeout?: number): Promise<BIMIResponse> {
+  try {
+    const response = await axios.post(`${API_BASE}/auth/bimi`, { domain, selector, timeout });
+    return response.data;
+  } catch (error: any) {
+    return handleAxiosError(error);
+  }
+}
+
+export async function dmarcReportUpload(files: DMARCReportUploadFile[]): Promise<DMARCReportIngestResponse> {
+  try {
+    const response = await axios.post(`${API_BASE}/dmarc/reports`, { files });
+    return response.data;
+  } catch (error: any) {
+    return handleAxiosError(error);
+  }
+}
+
+export async function dmarcReportSummary(request: DMARCReportSummaryRequest): Promise<DMARCReportSummaryResponse> {
+  try {
+    const response = await axios.post(`${API_BASE}/dmarc/reports/summary`, request);
+    return response.data;
+  } catch (error: any) {
+    return handleAxiosError(error);
+  }
+}
+
+export async function dmarcForensic(files: DMARCReportUploadFile[]): Promise<DMARCForensicResponse> {
+  try {
+    const response = await axios.post(`${API_BASE}/dmarc/forensic`, { files });
+    return response.data;
+  } catch (error: any) {
+    return handleAxiosError(error);
+  }
+}
+
+export async function headersAnalyze(headers: string): Promise<HeaderAnalysisResponse> {
+  try {
+    const response = await axios.post(`${API_BASE}/headers/analyze`, { headers });
+    return response.data;
+  } catch (error: any) {
+    return handleAxiosError(error);
+  }
+}
+
 export async function dkimCheck(domain: string, selector?: string): Promise<DKIMResponse> {
   try {
     // If selector is provided, include it in the URL, otherwise the backend will use defaults